@@ -0,0 +1,283 @@
+package visitor
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Dictionary is a set of correctly spelled words with an index for finding
+// similar words. Words are stored in lower case.
+//
+// Suggestions are found through a BK-tree keyed on the Damerau-Levenshtein
+// distance, so only a small part of the dictionary has to be compared with a
+// misspelled word.
+type Dictionary struct {
+	words map[string]int // word -> rank, lower ranks were added first
+	index *bkNode
+}
+
+// NewDictionary creates a dictionary containing the given words
+func NewDictionary(words ...string) *Dictionary {
+	d := &Dictionary{words: make(map[string]int)}
+	for _, word := range words {
+		d.Add(word)
+	}
+	return d
+}
+
+// LoadDictionary creates a dictionary from one or more word-list files.
+// See ReadWords for the file format.
+func LoadDictionary(paths ...string) (*Dictionary, error) {
+	d := NewDictionary()
+	for _, path := range paths {
+		if err := d.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Add adds a word to the dictionary. Words that are already present keep
+// their original rank.
+func (d *Dictionary) Add(word string) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return
+	}
+	if _, ok := d.words[word]; ok {
+		return
+	}
+	d.words[word] = len(d.words)
+	d.index = d.index.insert(word)
+}
+
+// LoadFile adds all words of a word-list file to the dictionary
+func (d *Dictionary) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening word list: %w", err)
+	}
+	defer f.Close()
+
+	if err := d.ReadWords(f); err != nil {
+		return fmt.Errorf("reading word list %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadWords adds words from r to the dictionary. The input has one word per
+// line; blank lines and lines starting with # are skipped. Gzipped input is
+// detected from its magic bytes and decompressed transparently.
+//
+// Word lists are usually sorted by frequency, so words read first rank higher
+// among suggestions at the same distance.
+func (d *Dictionary) ReadWords(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	scanner := bufio.NewScanner(br)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		d.Add(line)
+	}
+	return scanner.Err()
+}
+
+// Len returns the number of words in the dictionary
+func (d *Dictionary) Len() int {
+	return len(d.words)
+}
+
+// Contains reports whether word is in the dictionary, ignoring case
+func (d *Dictionary) Contains(word string) bool {
+	_, ok := d.words[strings.ToLower(word)]
+	return ok
+}
+
+// Suggestion is a dictionary word close to a misspelled word
+type Suggestion struct {
+	Word     string
+	Distance int
+}
+
+// Suggest returns up to limit dictionary words within maxDistance edits of
+// word, closest first. Words at the same distance are ordered by rank.
+func (d *Dictionary) Suggest(word string, maxDistance, limit int) []Suggestion {
+	word = strings.ToLower(word)
+	var found []Suggestion
+	d.index.search(word, maxDistance, func(candidate string, distance int) {
+		found = append(found, Suggestion{Word: candidate, Distance: distance})
+	})
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Distance != found[j].Distance {
+			return found[i].Distance < found[j].Distance
+		}
+		return d.words[found[i].Word] < d.words[found[j].Word]
+	})
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// bkNode is a node of a BK-tree. Children are keyed by their distance to
+// the node's word, which lets a search skip every subtree whose key is
+// further than maxDistance from the distance to the query.
+type bkNode struct {
+	word     string
+	children map[int]*bkNode
+}
+
+// insert adds word below n and returns the (possibly new) root
+func (n *bkNode) insert(word string) *bkNode {
+	if n == nil {
+		return &bkNode{word: word}
+	}
+	node := n
+	for {
+		distance := damerauLevenshtein(word, node.word)
+		if distance == 0 {
+			return n
+		}
+		child, ok := node.children[distance]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[distance] = &bkNode{word: word}
+			return n
+		}
+		node = child
+	}
+}
+
+// search calls found for every word within maxDistance of word
+func (n *bkNode) search(word string, maxDistance int, found func(string, int)) {
+	if n == nil {
+		return
+	}
+	pending := []*bkNode{n}
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		distance := damerauLevenshtein(word, node.word)
+		if distance <= maxDistance {
+			found(node.word, distance)
+		}
+		for key, child := range node.children {
+			if key >= distance-maxDistance && key <= distance+maxDistance {
+				pending = append(pending, child)
+			}
+		}
+	}
+}
+
+// damerauLevenshtein returns the number of insertions, deletions,
+// substitutions and transpositions of adjacent characters needed to turn a
+// into b.
+//
+// This is the unrestricted variant (Lowrance-Wagner), not the simpler
+// optimal string alignment distance, because a BK-tree needs a true metric
+// that satisfies the triangle inequality.
+func damerauLevenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	maxDist := len(s) + len(t)
+
+	// d has an extra leading row and column holding maxDist
+	d := make([][]int, len(s)+2)
+	for i := range d {
+		d[i] = make([]int, len(t)+2)
+	}
+	d[0][0] = maxDist
+	for i := 0; i <= len(s); i++ {
+		d[i+1][0] = maxDist
+		d[i+1][1] = i
+	}
+	for j := 0; j <= len(t); j++ {
+		d[0][j+1] = maxDist
+		d[1][j+1] = j
+	}
+
+	lastRow := make(map[rune]int) // last row where each character of s was seen
+	for i := 1; i <= len(s); i++ {
+		lastMatchCol := 0
+		for j := 1; j <= len(t); j++ {
+			k := lastRow[t[j-1]]
+			l := lastMatchCol
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+				lastMatchCol = j
+			}
+			d[i+1][j+1] = minInt(
+				d[i][j]+cost,              // substitution
+				d[i+1][j]+1,               // insertion
+				d[i][j+1]+1,               // deletion
+				d[k][l]+(i-k-1)+1+(j-l-1), // transposition
+			)
+		}
+		lastRow[s[i-1]] = i
+	}
+	return d[len(s)+1][len(t)+1]
+}
+
+// minInt returns the smallest of its arguments
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// wordSpan is a word of a text together with its character offset
+type wordSpan struct {
+	word   string
+	offset int
+}
+
+// splitWords returns the words of text with the character (rune) offset of
+// each word. Leading and trailing punctuation is not part of a word, so the
+// offset points at the first letter.
+func splitWords(text string) []wordSpan {
+	var spans []wordSpan
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		for i < len(runes) && !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			i++
+		}
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		// Trim trailing punctuation such as "word," or "word)."
+		end := i
+		for end > start && !unicode.IsLetter(runes[end-1]) && !unicode.IsDigit(runes[end-1]) {
+			end--
+		}
+		if end > start {
+			spans = append(spans, wordSpan{word: string(runes[start:end]), offset: start})
+		}
+	}
+	return spans
+}
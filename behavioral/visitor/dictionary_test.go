@@ -0,0 +1,197 @@
+package visitor
+
+import (
+	"compress/gzip"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDamerauLevenshtein tests the edit distance on known pairs
+func TestDamerauLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"teh", "the", 1}, // transposition
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 2},      // 3 with optimal string alignment
+		{"naïve", "naive", 1}, // runes, not bytes
+	}
+	for _, c := range cases {
+		if got := damerauLevenshtein(c.a, c.b); got != c.distance {
+			t.Errorf("damerauLevenshtein(%q, %q) = %d, expected %d", c.a, c.b, got, c.distance)
+		}
+		if got := damerauLevenshtein(c.b, c.a); got != c.distance {
+			t.Errorf("damerauLevenshtein(%q, %q) = %d, expected %d", c.b, c.a, got, c.distance)
+		}
+	}
+}
+
+// TestDictionarySuggestMatchesBruteForce tests that the BK-tree finds exactly
+// the words a full scan finds
+func TestDictionarySuggestMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomWord := func() string {
+		b := make([]byte, 2+rng.Intn(6))
+		for i := range b {
+			b[i] = "abcdeo"[rng.Intn(6)]
+		}
+		return string(b)
+	}
+
+	var words []string
+	for i := 0; i < 500; i++ {
+		words = append(words, randomWord())
+	}
+	dict := NewDictionary(words...)
+
+	for i := 0; i < 100; i++ {
+		query := randomWord()
+		expected := map[string]bool{}
+		for word := range dict.words {
+			if damerauLevenshtein(query, word) <= 2 {
+				expected[word] = true
+			}
+		}
+
+		got := map[string]bool{}
+		suggestions := dict.Suggest(query, 2, 0)
+		for j, s := range suggestions {
+			got[s.Word] = true
+			if j > 0 && s.Distance < suggestions[j-1].Distance {
+				t.Fatalf("Suggestions for %q are not sorted by distance: %v", query, suggestions)
+			}
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Suggest(%q) found %v, a full scan found %v", query, got, expected)
+		}
+	}
+}
+
+// TestDictionarySuggestRanking tests that earlier words win ties and limit is honoured
+func TestDictionarySuggestRanking(t *testing.T) {
+	dict := NewDictionary("then", "the", "they", "ten", "tea")
+
+	suggestions := dict.Suggest("teh", 2, 3)
+	if len(suggestions) != 3 {
+		t.Fatalf("Expected 3 suggestions, got %v", suggestions)
+	}
+	if suggestions[0] != (Suggestion{Word: "the", Distance: 1}) {
+		t.Errorf("Expected 'the' first, got %v", suggestions)
+	}
+	if suggestions[1].Word != "ten" || suggestions[2].Word != "tea" {
+		t.Errorf("Expected ties at distance 1 in insertion order, got %v", suggestions)
+	}
+}
+
+// TestLoadDictionary tests loading plain and gzipped word lists
+func TestLoadDictionary(t *testing.T) {
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "words.txt")
+	if err := os.WriteFile(plain, []byte("# common words\nHello\n\nworld\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	zipped := filepath.Join(dir, "extra.dic")
+	f, err := os.Create(zipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("visitor\npattern\n"))
+	gz.Close()
+	f.Close()
+
+	dict, err := LoadDictionary(plain, zipped)
+	if err != nil {
+		t.Fatalf("LoadDictionary returned error: %v", err)
+	}
+	if dict.Len() != 4 {
+		t.Errorf("Expected 4 words, got %d", dict.Len())
+	}
+	for _, word := range []string{"hello", "WORLD", "visitor", "pattern"} {
+		if !dict.Contains(word) {
+			t.Errorf("Expected dictionary to contain %q", word)
+		}
+	}
+	if dict.Contains("# common words") {
+		t.Error("Comment lines should be skipped")
+	}
+
+	if _, err := LoadDictionary(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("Expected an error for a missing word list")
+	}
+}
+
+// TestSpellCheckVisitorReportsLocations tests paths, offsets and suggestions
+func TestSpellCheckVisitorReportsLocations(t *testing.T) {
+	dict := NewDictionary("the", "cat", "sat", "on", "mat", "intro", "summary", "photo", "name", "value")
+	visitor := NewSpellCheckVisitorWithDictionary(dict)
+	visitor.IgnoreWords("Gopher")
+	visitor.MaxDistance = 1
+
+	doc := &CompositeElement{
+		Name: "Intro",
+		Children: []Element{
+			&TextElement{Content: "The Gopher sat on teh mat."},
+			&CompositeElement{
+				Name: "Summary",
+				Children: []Element{
+					&ImageElement{Alt: "photo of a cat"},
+					&TableElement{Rows: 2, Columns: 2, Data: [][]string{{"name", "value"}, {"cat", "(mta)"}}},
+					&CodeBlockElement{Code: "zzz qqq"},
+				},
+			},
+		},
+	}
+	if err := doc.Accept(visitor); err != nil {
+		t.Fatalf("SpellCheckVisitor returned error: %v", err)
+	}
+
+	var got []string
+	for _, e := range visitor.GetErrors() {
+		got = append(got, e.Error())
+	}
+	expected := []string{
+		`Intro/text[0]:18: possible spelling error "teh" (did you mean the?)`,
+		`Intro/Summary[1]/image[0] alt:6: possible spelling error "of" (did you mean on?)`,
+		`Intro/Summary[1]/image[0] alt:9: possible spelling error "a"`,
+		`Intro/Summary[1]/table[1] cell[1,1]:1: possible spelling error "mta" (did you mean mat?)`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected spelling errors.\nExpected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+// TestSpellCheckVisitorIgnoreList tests loading a per-project ignore list
+func TestSpellCheckVisitorIgnoreList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ignore.txt")
+	if err := os.WriteFile(path, []byte("golang\nKubernetes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	visitor := NewSpellCheckVisitor()
+	if err := visitor.LoadIgnoreList(path); err != nil {
+		t.Fatalf("LoadIgnoreList returned error: %v", err)
+	}
+	if err := visitor.VisitText(&TextElement{Content: "golang and kubernetes 2025"}); err != nil {
+		t.Fatalf("VisitText returned error: %v", err)
+	}
+	if len(visitor.Errors) != 0 {
+		t.Errorf("Expected ignored words and numbers to pass, got %v", visitor.Errors)
+	}
+
+	// Ignored words are never suggested
+	visitor.VisitText(&TextElement{Content: "golamg"})
+	if len(visitor.Errors) != 1 || len(visitor.Errors[0].Suggestions) != 0 {
+		t.Errorf("Expected one error without suggestions, got %+v", visitor.Errors)
+	}
+}
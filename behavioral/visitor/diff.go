@@ -0,0 +1,971 @@
+package visitor
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"html"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind describes how an element differs between two documents
+type ChangeKind int
+
+const (
+	// Unchanged elements are the same in both documents
+	Unchanged ChangeKind = iota
+	// Inserted elements only exist in the new document
+	Inserted
+	// Deleted elements only exist in the old document
+	Deleted
+	// Moved elements exist unchanged in both documents, at different positions
+	Moved
+	// Modified elements exist in both documents with different content
+	Modified
+)
+
+// String returns the name of the change kind
+func (k ChangeKind) String() string {
+	switch k {
+	case Unchanged:
+		return "unchanged"
+	case Inserted:
+		return "inserted"
+	case Deleted:
+		return "deleted"
+	case Moved:
+		return "moved"
+	case Modified:
+		return "modified"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+}
+
+// TextEdit is a run of words that is unchanged, inserted or deleted
+type TextEdit struct {
+	Kind ChangeKind
+	Text string
+}
+
+// CellChange is the change of a single table cell
+type CellChange struct {
+	Kind     ChangeKind
+	Column   int
+	Old, New string
+}
+
+// RowChange is the change of a table row. OldRow or NewRow is -1 for rows
+// that only exist in one of the tables.
+type RowChange struct {
+	Kind           ChangeKind
+	OldRow, NewRow int
+	Cells          []CellChange
+}
+
+// Change describes how one element differs between two documents.
+//
+// Path is the element's path in the new document, OldPath its path in the
+// old one. Deleted elements only have an OldPath and Inserted elements only
+// a Path. Paths use the same form as ElementPath.
+type Change struct {
+	Kind     ChangeKind
+	Path     string
+	OldPath  string
+	Old, New Element
+
+	Words    []TextEdit  // Word-level changes of modified text, headings and quotes
+	Rows     []RowChange // Cell-level changes of modified tables
+	Children []*Change   // Changes of a composite's children, in document order
+}
+
+// DocumentDiff is the result of comparing two element trees
+type DocumentDiff struct {
+	Root *Change
+}
+
+// HasChanges reports whether the two documents differ
+func (d *DocumentDiff) HasChanges() bool {
+	return d.Root.Kind != Unchanged
+}
+
+// Changes returns every inserted, deleted, moved and modified element in
+// document order. Composites are only included when they were inserted,
+// deleted, moved or renamed, not when just their children changed.
+func (d *DocumentDiff) Changes() []*Change {
+	var changes []*Change
+	var walk func(c *Change)
+	walk = func(c *Change) {
+		if c.Kind == Unchanged {
+			return
+		}
+		if c.Kind != Modified || !isCompositeChange(c) || isRename(c) {
+			changes = append(changes, c)
+		}
+		for _, child := range c.Children {
+			walk(child)
+		}
+	}
+	walk(d.Root)
+	return changes
+}
+
+// Diff compares two element trees.
+//
+// Children of composites are aligned on a longest common subsequence, where
+// composites match by name and other elements by content. Elements left
+// over on both sides are moves if an identical element was removed somewhere
+// else in the document, modifications if an element of the same kind was
+// removed at the same position, and plain insertions and deletions
+// otherwise. Modified text gets a word-level diff and modified tables a
+// cell-level diff.
+func Diff(a, b Element) *DocumentDiff {
+	d := &differ{}
+
+	var root *Change
+	oldRoot, oldIsComposite := a.(*CompositeElement)
+	newRoot, newIsComposite := b.(*CompositeElement)
+	if oldIsComposite && newIsComposite {
+		root = d.composite(oldRoot, newRoot, compositePathName(oldRoot), compositePathName(newRoot))
+	} else {
+		// Compare the elements as one-element lists below a virtual root
+		root = &Change{Kind: Unchanged}
+		d.children(root, elementList(a), elementList(b), "", "")
+	}
+
+	d.findMoves()
+	d.assemble()
+	return &DocumentDiff{Root: root}
+}
+
+// elementList returns e as a list, or an empty list for nil
+func elementList(e Element) []Element {
+	if e == nil {
+		return nil
+	}
+	return []Element{e}
+}
+
+// differ holds the state of a diff between the alignment of the trees and
+// the assembly of the result, since moves can only be found once the whole
+// tree has been aligned
+type differ struct {
+	pending []*pendingComposite // In pre-order
+	gaps    []*diffGap
+}
+
+// pendingComposite is a composite whose children are still being resolved
+type pendingComposite struct {
+	change   *Change
+	segments []diffSegment
+}
+
+// diffSegment is either a matched pair of children or a gap of unmatched ones
+type diffSegment struct {
+	change *Change
+	gap    *diffGap
+}
+
+// diffGap is a run of children that only exist in the old or new document
+type diffGap struct {
+	deleted  []*diffItem
+	inserted []*diffItem
+}
+
+// diffItem is an unmatched element. partner is the identical element on the
+// other side when the element was moved.
+type diffItem struct {
+	element Element
+	key     string // diffKey of element
+	path    string
+	partner *diffItem
+}
+
+// composite compares two composites matched by name
+func (d *differ) composite(a, b *CompositeElement, oldPath, newPath string) *Change {
+	change := &Change{Kind: Unchanged, Path: newPath, OldPath: oldPath, Old: a, New: b}
+	if a.Name != b.Name {
+		change.Kind = Modified
+	}
+	d.children(change, a.Children, b.Children, oldPath, newPath)
+	return change
+}
+
+// children aligns the children of a composite and records the matches and gaps
+func (d *differ) children(change *Change, as, bs []Element, oldPath, newPath string) {
+	pending := &pendingComposite{change: change}
+	d.pending = append(d.pending, pending)
+
+	oldKeys, newKeys := diffKeys(as), diffKeys(bs)
+	pairs := lcsPairs(len(as), len(bs), func(i, j int) bool {
+		return oldKeys[i] == newKeys[j]
+	})
+	walkAlignment(len(as), len(bs), pairs,
+		func(i, j int) {
+			op, np := childPath(oldPath, as[i], i), childPath(newPath, bs[j], j)
+			var c *Change
+			if composite, ok := as[i].(*CompositeElement); ok {
+				c = d.composite(composite, bs[j].(*CompositeElement), op, np)
+			} else {
+				c = &Change{Kind: Unchanged, Path: np, OldPath: op, Old: as[i], New: bs[j]}
+			}
+			pending.segments = append(pending.segments, diffSegment{change: c})
+		},
+		func(dels, ins []int) {
+			gap := &diffGap{}
+			for _, i := range dels {
+				gap.deleted = append(gap.deleted, &diffItem{element: as[i], key: oldKeys[i], path: childPath(oldPath, as[i], i)})
+			}
+			for _, j := range ins {
+				gap.inserted = append(gap.inserted, &diffItem{element: bs[j], key: newKeys[j], path: childPath(newPath, bs[j], j)})
+			}
+			d.gaps = append(d.gaps, gap)
+			pending.segments = append(pending.segments, diffSegment{gap: gap})
+		})
+}
+
+// findMoves pairs deleted and inserted elements with identical content,
+// anywhere in the document. Insertions are indexed by a hash of their
+// content, so each deletion only looks at the insertions it could match.
+func (d *differ) findMoves() {
+	candidates := make(map[uint64][]*diffItem)
+	for _, gap := range d.gaps {
+		for _, ins := range gap.inserted {
+			h := contentHash(ins)
+			candidates[h] = append(candidates[h], ins)
+		}
+	}
+
+	for _, gap := range d.gaps {
+		for _, del := range gap.deleted {
+			h := contentHash(del)
+			bucket := candidates[h]
+			for len(bucket) > 0 && bucket[0].partner != nil {
+				bucket = bucket[1:]
+			}
+			candidates[h] = bucket
+			for _, ins := range bucket {
+				if ins.partner == nil && reflect.DeepEqual(del.element, ins.element) {
+					del.partner, ins.partner = ins, del
+					break
+				}
+			}
+		}
+	}
+}
+
+// contentHash hashes the whole content of an unmatched element, including
+// the descendants of a composite, so that identical elements hash alike
+func contentHash(item *diffItem) uint64 {
+	h := fnv.New64a()
+	if composite, ok := item.element.(*CompositeElement); ok {
+		writeContentHash(h, composite)
+	} else {
+		io.WriteString(h, item.key)
+	}
+	return h.Sum64()
+}
+
+// writeContentHash writes the content of an element to h
+func writeContentHash(h hash.Hash, e Element) {
+	composite, ok := e.(*CompositeElement)
+	if !ok {
+		io.WriteString(h, diffKey(e))
+		return
+	}
+	fmt.Fprintf(h, "composite %q %d{", composite.Name, len(composite.Children))
+	for _, child := range composite.Children {
+		writeContentHash(h, child)
+		io.WriteString(h, "\x00")
+	}
+	io.WriteString(h, "}")
+}
+
+// assemble builds the children of every composite, innermost first so that
+// a composite knows whether any of its children changed
+func (d *differ) assemble() {
+	for i := len(d.pending) - 1; i >= 0; i-- {
+		p := d.pending[i]
+		for _, segment := range p.segments {
+			if segment.gap == nil {
+				p.change.Children = append(p.change.Children, segment.change)
+				if segment.change.Kind != Unchanged {
+					p.change.Kind = Modified
+				}
+				continue
+			}
+			p.change.Children = append(p.change.Children, resolveGap(segment.gap)...)
+			p.change.Kind = Modified
+		}
+	}
+}
+
+// resolveGap turns the unmatched elements of a gap into changes. Deletions
+// come first, like in a unified diff. Elements that moved away from the gap
+// are reported at their new position only.
+func resolveGap(gap *diffGap) []*Change {
+	paired := make(map[*diffItem]*diffItem) // insertion -> deletion
+	used := make(map[*diffItem]bool)
+	for _, ins := range gap.inserted {
+		if ins.partner != nil || elementKind(ins.element) == "composite" {
+			continue
+		}
+		for _, del := range gap.deleted {
+			if del.partner == nil && !used[del] && elementKind(del.element) == elementKind(ins.element) {
+				paired[ins] = del
+				used[del] = true
+				break
+			}
+		}
+	}
+
+	var changes []*Change
+	for _, del := range gap.deleted {
+		if del.partner == nil && !used[del] {
+			changes = append(changes, &Change{Kind: Deleted, OldPath: del.path, Old: del.element})
+		}
+	}
+	for _, ins := range gap.inserted {
+		switch del := paired[ins]; {
+		case ins.partner != nil:
+			changes = append(changes, &Change{Kind: Moved, Path: ins.path, OldPath: ins.partner.path,
+				Old: ins.partner.element, New: ins.element})
+		case del != nil:
+			changes = append(changes, diffLeaves(del.element, ins.element, del.path, ins.path))
+		default:
+			changes = append(changes, &Change{Kind: Inserted, Path: ins.path, New: ins.element})
+		}
+	}
+	return changes
+}
+
+// diffLeaves compares two elements of the same kind
+func diffLeaves(a, b Element, oldPath, newPath string) *Change {
+	change := &Change{Kind: Modified, Path: newPath, OldPath: oldPath, Old: a, New: b}
+	switch old := a.(type) {
+	case *TextElement:
+		change.Words = diffWords(old.Content, b.(*TextElement).Content)
+	case *HeadingElement:
+		change.Words = diffWords(old.Text, b.(*HeadingElement).Text)
+	case *QuoteElement:
+		change.Words = diffWords(old.Content, b.(*QuoteElement).Content)
+	case *TableElement:
+		change.Rows = diffTable(old, b.(*TableElement))
+	}
+	return change
+}
+
+// diffWords returns the word-level changes between two texts. Adjacent
+// words with the same kind of change are merged into one edit.
+func diffWords(a, b string) []TextEdit {
+	as, bs := strings.Fields(a), strings.Fields(b)
+	var edits []TextEdit
+	add := func(kind ChangeKind, word string) {
+		if n := len(edits); n > 0 && edits[n-1].Kind == kind {
+			edits[n-1].Text += " " + word
+			return
+		}
+		edits = append(edits, TextEdit{Kind: kind, Text: word})
+	}
+
+	walkAlignment(len(as), len(bs), lcsPairs(len(as), len(bs), func(i, j int) bool { return as[i] == bs[j] }),
+		func(i, j int) {
+			add(Unchanged, bs[j])
+		},
+		func(dels, ins []int) {
+			for _, i := range dels {
+				add(Deleted, as[i])
+			}
+			for _, j := range ins {
+				add(Inserted, bs[j])
+			}
+		})
+	return edits
+}
+
+// diffTable returns the row and cell changes between two tables. Rows are
+// aligned first, so an inserted row does not show up as every following row
+// being modified.
+func diffTable(a, b *TableElement) []RowChange {
+	oldRows, newRows := tableCells(a), tableCells(b)
+	var rows []RowChange
+
+	oldKeys, newKeys := rowKeys(oldRows), rowKeys(newRows)
+	pairs := lcsPairs(len(oldRows), len(newRows), func(i, j int) bool {
+		return oldKeys[i] == newKeys[j]
+	})
+	walkAlignment(len(oldRows), len(newRows), pairs,
+		func(i, j int) {
+			rows = append(rows, diffRow(i, j, oldRows[i], newRows[j]))
+		},
+		func(dels, ins []int) {
+			k := 0
+			for ; k < len(dels) && k < len(ins); k++ {
+				rows = append(rows, diffRow(dels[k], ins[k], oldRows[dels[k]], newRows[ins[k]]))
+			}
+			for _, i := range dels[k:] {
+				rows = append(rows, diffRow(i, -1, oldRows[i], nil))
+			}
+			for _, j := range ins[k:] {
+				rows = append(rows, diffRow(-1, j, nil, newRows[j]))
+			}
+		})
+	return rows
+}
+
+// rowKeys returns a key per row that is equal for rows with equal cells
+func rowKeys(rows [][]string) []string {
+	keys := make([]string, len(rows))
+	for i, row := range rows {
+		keys[i] = fmt.Sprintf("%q", row)
+	}
+	return keys
+}
+
+// diffRow compares the cells of two rows; a nil row does not exist
+func diffRow(oldRow, newRow int, a, b []string) RowChange {
+	row := RowChange{Kind: Unchanged, OldRow: oldRow, NewRow: newRow}
+	switch {
+	case a == nil:
+		row.Kind = Inserted
+	case b == nil:
+		row.Kind = Deleted
+	}
+
+	columns := len(a)
+	if len(b) > columns {
+		columns = len(b)
+	}
+	for col := 0; col < columns; col++ {
+		cell := CellChange{Kind: Unchanged, Column: col}
+		switch {
+		case col >= len(a):
+			cell.Kind, cell.New = Inserted, b[col]
+		case col >= len(b):
+			cell.Kind, cell.Old = Deleted, a[col]
+		default:
+			cell.Old, cell.New = a[col], b[col]
+			if cell.Old != cell.New {
+				cell.Kind = Modified
+			}
+		}
+		if cell.Kind != Unchanged && row.Kind == Unchanged {
+			row.Kind = Modified
+		}
+		row.Cells = append(row.Cells, cell)
+	}
+	return row
+}
+
+// tableCells returns the cells of a table as the exporters see them, with
+// missing cells empty
+func tableCells(table *TableElement) [][]string {
+	cells := make([][]string, table.Rows)
+	for i := range cells {
+		cells[i] = make([]string, table.Columns)
+		for j := range cells[i] {
+			if i < len(table.Data) && j < len(table.Data[i]) {
+				cells[i][j] = table.Data[i][j]
+			}
+		}
+	}
+	return cells
+}
+
+// lcsPairs returns the index pairs of a longest common subsequence of two
+// sequences of length n and m. It uses Myers' linear space algorithm, which
+// takes O((n+m)D) time for D differences and O(n+m) memory, so comparing
+// mostly equal sequences stays fast however long they are.
+func lcsPairs(n, m int, equal func(i, j int) bool) [][2]int {
+	l := &lcs{equal: equal}
+	l.compare(0, n, 0, m)
+	return l.pairs
+}
+
+// lcs holds the state of a longest common subsequence search
+type lcs struct {
+	equal func(i, j int) bool
+	pairs [][2]int
+}
+
+// compare finds the common subsequence of a[a0:a1] and b[b0:b1]. The common
+// prefix and suffix are matched directly; what is left is split at the
+// middle snake of its shortest edit script and compared recursively.
+func (l *lcs) compare(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && l.equal(a0, b0) {
+		l.pairs = append(l.pairs, [2]int{a0, b0})
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a0 < a1 && b0 < b1 && l.equal(a1-1, b1-1) {
+		a1--
+		b1--
+		suffix++
+	}
+
+	if a0 < a1 && b0 < b1 {
+		if x, y, ok := l.middleSnake(a0, a1, b0, b1); ok {
+			l.compare(a0, x, b0, y)
+			l.compare(x, a1, y, b1)
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		l.pairs = append(l.pairs, [2]int{a1 + k, b1 + k})
+	}
+}
+
+// middleSnake runs the forward and backward searches for the shortest edit
+// script of a[a0:a1] and b[b0:b1] until they overlap, and returns where the
+// forward search reached. Both halves of the split are then strictly smaller
+// than the whole. ok is false when the sequences have nothing in common.
+func (l *lcs) middleSnake(a0, a1, b0, b1 int) (x, y int, ok bool) {
+	n, m := a1-a0, b1-b0
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward := make([]int, 2*maxD+2)  // Furthest x on each diagonal from the start
+	backward := make([]int, 2*maxD+2) // Furthest distance on each diagonal from the end
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonals that ran off the edit graph are trimmed from the search
+	var fStart, fEnd, bStart, bEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -d || (k != d && forward[i-1] < forward[i+1]) {
+				fx = forward[i+1]
+			} else {
+				fx = forward[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && l.equal(a0+fx, b0+fy) {
+				fx++
+				fy++
+			}
+			forward[i] = fx
+			switch {
+			case fx > n:
+				fEnd += 2
+			case fy > m:
+				fStart += 2
+			case odd:
+				if j := offset + delta - k; j >= 0 && j < len(backward) && backward[j] != -1 && fx >= n-backward[j] {
+					return a0 + fx, b0 + fy, true
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -d || (k != d && backward[i-1] < backward[i+1]) {
+				bx = backward[i+1]
+			} else {
+				bx = backward[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && l.equal(a1-bx-1, b1-by-1) {
+				bx++
+				by++
+			}
+			backward[i] = bx
+			switch {
+			case bx > n:
+				bEnd += 2
+			case by > m:
+				bStart += 2
+			case !odd:
+				if j := offset + delta - k; j >= 0 && j < len(forward) && forward[j] != -1 {
+					fx := forward[j]
+					fy := offset + fx - j
+					if fx >= n-bx {
+						return a0 + fx, b0 + fy, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// walkAlignment calls match for every matched pair and gap for every run of
+// unmatched indices between them, in order
+func walkAlignment(n, m int, pairs [][2]int, match func(i, j int), gap func(dels, ins []int)) {
+	i, j := 0, 0
+	flush := func(toI, toJ int) {
+		var dels, ins []int
+		for ; i < toI; i++ {
+			dels = append(dels, i)
+		}
+		for ; j < toJ; j++ {
+			ins = append(ins, j)
+		}
+		if len(dels) > 0 || len(ins) > 0 {
+			gap(dels, ins)
+		}
+	}
+	for _, pair := range pairs {
+		flush(pair[0], pair[1])
+		match(pair[0], pair[1])
+		i, j = pair[0]+1, pair[1]+1
+	}
+	flush(n, m)
+}
+
+// diffKeys returns the diffKey of every element, so that aligning two lists
+// computes each key once rather than on every comparison
+func diffKeys(elements []Element) []string {
+	keys := make([]string, len(elements))
+	for i, e := range elements {
+		keys[i] = diffKey(e)
+	}
+	return keys
+}
+
+// diffKey identifies an element for aligning children: composites by name,
+// everything else by content
+func diffKey(e Element) string {
+	if composite, ok := e.(*CompositeElement); ok {
+		return "composite\x00" + composite.Name
+	}
+	return fmt.Sprintf("%#v", e)
+}
+
+// elementKind returns the kind name of an element, as used in element paths
+func elementKind(e Element) string {
+	switch e.(type) {
+	case *TextElement:
+		return "text"
+	case *ImageElement:
+		return "image"
+	case *TableElement:
+		return "table"
+	case *LinkElement:
+		return "link"
+	case *HeadingElement:
+		return "heading"
+	case *ListElement:
+		return "list"
+	case *CodeBlockElement:
+		return "code"
+	case *QuoteElement:
+		return "quote"
+	case *CompositeElement:
+		return "composite"
+	default:
+		return fmt.Sprintf("%T", e)
+	}
+}
+
+// compositePathName returns the name of a composite in element paths
+func compositePathName(composite *CompositeElement) string {
+	if composite.Name == "" {
+		return "composite"
+	}
+	return composite.Name
+}
+
+// childPath returns the path of the child at index of the element at parent
+func childPath(parent string, e Element, index int) string {
+	kind := elementKind(e)
+	if composite, ok := e.(*CompositeElement); ok {
+		kind = compositePathName(composite)
+	}
+	segment := fmt.Sprintf("%s[%d]", kind, index)
+	if parent == "" {
+		return segment
+	}
+	return parent + "/" + segment
+}
+
+// isCompositeChange reports whether c compares composites, or is the
+// virtual root used when comparing other elements
+func isCompositeChange(c *Change) bool {
+	if c.Old == nil && c.New == nil {
+		return true
+	}
+	_, ok := c.New.(*CompositeElement)
+	return ok
+}
+
+// isRename reports whether c compares two composites with different names
+func isRename(c *Change) bool {
+	a, okA := c.Old.(*CompositeElement)
+	b, okB := c.New.(*CompositeElement)
+	return okA && okB && a.Name != b.Name
+}
+
+// String renders the diff as a unified text diff
+func (d *DocumentDiff) String() string {
+	return d.Unified()
+}
+
+// Unified renders the diff as text. Every changed element gets a line
+// starting with + (inserted), - (deleted), ~ (moved) or ! (modified),
+// followed by its path. Word changes are marked [-like this-]{+like that+},
+// as in git's word diff.
+func (d *DocumentDiff) Unified() string {
+	var sb strings.Builder
+	sb.WriteString("--- a/" + d.Root.OldPath + "\n")
+	sb.WriteString("+++ b/" + d.Root.Path + "\n")
+	writeUnified(&sb, d.Root)
+	return sb.String()
+}
+
+// writeUnified writes the lines for a change and its children
+func writeUnified(sb *strings.Builder, c *Change) {
+	switch c.Kind {
+	case Inserted:
+		writeUnifiedElement(sb, "+", c.Path, c.New)
+	case Deleted:
+		writeUnifiedElement(sb, "-", c.OldPath, c.Old)
+	case Moved:
+		sb.WriteString(fmt.Sprintf("~ %s: %s (moved from %s)\n", c.Path, elementSummary(c.New), c.OldPath))
+	case Modified:
+		switch {
+		case isCompositeChange(c):
+			if isRename(c) {
+				sb.WriteString(fmt.Sprintf("! %s: renamed from %q\n", c.Path, c.Old.(*CompositeElement).Name))
+			}
+			for _, child := range c.Children {
+				writeUnified(sb, child)
+			}
+		case c.Rows != nil:
+			sb.WriteString(fmt.Sprintf("! %s:\n", c.Path))
+			writeUnifiedRows(sb, c.Rows)
+		case c.Words != nil:
+			sb.WriteString(fmt.Sprintf("! %s: %s%s\n", c.Path, headingLevelDiff(c), unifiedWords(c.Words)))
+		default:
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", c.OldPath, elementSummary(c.Old)))
+			sb.WriteString(fmt.Sprintf("+ %s: %s\n", c.Path, elementSummary(c.New)))
+		}
+	}
+}
+
+// writeUnifiedElement writes an inserted or deleted element and, for
+// composites, all of its descendants
+func writeUnifiedElement(sb *strings.Builder, sign, path string, e Element) {
+	sb.WriteString(fmt.Sprintf("%s %s: %s\n", sign, path, elementSummary(e)))
+	if composite, ok := e.(*CompositeElement); ok {
+		for i, child := range composite.Children {
+			writeUnifiedElement(sb, sign, childPath(path, child, i), child)
+		}
+	}
+}
+
+// writeUnifiedRows writes the changed rows and cells of a table
+func writeUnifiedRows(sb *strings.Builder, rows []RowChange) {
+	for _, row := range rows {
+		switch row.Kind {
+		case Inserted:
+			sb.WriteString(fmt.Sprintf("+   row[%d]: %s\n", row.NewRow, joinCells(row.Cells, func(c CellChange) string { return c.New })))
+		case Deleted:
+			sb.WriteString(fmt.Sprintf("-   row[%d]: %s\n", row.OldRow, joinCells(row.Cells, func(c CellChange) string { return c.Old })))
+		case Modified:
+			for _, cell := range row.Cells {
+				if cell.Kind == Unchanged {
+					continue
+				}
+				sb.WriteString(fmt.Sprintf("!   cell[%d,%d]: %s\n", row.NewRow, cell.Column, unifiedCell(cell)))
+			}
+		}
+	}
+}
+
+// joinCells joins one side of a row's cells with " | "
+func joinCells(cells []CellChange, side func(CellChange) string) string {
+	values := make([]string, len(cells))
+	for i, cell := range cells {
+		values[i] = side(cell)
+	}
+	return strings.Join(values, " | ")
+}
+
+// unifiedCell marks up the change of a single cell
+func unifiedCell(cell CellChange) string {
+	var parts []string
+	if cell.Kind != Inserted {
+		parts = append(parts, "[-"+cell.Old+"-]")
+	}
+	if cell.Kind != Deleted {
+		parts = append(parts, "{+"+cell.New+"+}")
+	}
+	return strings.Join(parts, "")
+}
+
+// unifiedWords marks up word changes in git's word diff style
+func unifiedWords(edits []TextEdit) string {
+	parts := make([]string, len(edits))
+	for i, edit := range edits {
+		switch edit.Kind {
+		case Deleted:
+			parts[i] = "[-" + edit.Text + "-]"
+		case Inserted:
+			parts[i] = "{+" + edit.Text + "+}"
+		default:
+			parts[i] = edit.Text
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// headingLevelDiff marks up a change of heading level, e.g. "[-##-]{+###+} "
+func headingLevelDiff(c *Change) string {
+	old, ok := c.Old.(*HeadingElement)
+	if !ok {
+		return ""
+	}
+	oldLevel, newLevel := clampHeadingLevel(old.Level), clampHeadingLevel(c.New.(*HeadingElement).Level)
+	if oldLevel == newLevel {
+		return strings.Repeat("#", newLevel) + " "
+	}
+	return fmt.Sprintf("[-%s-]{+%s+} ", strings.Repeat("#", oldLevel), strings.Repeat("#", newLevel))
+}
+
+// elementSummary returns a one-line description of an element
+func elementSummary(e Element) string {
+	if composite, ok := e.(*CompositeElement); ok {
+		return fmt.Sprintf("section %q", composite.Name)
+	}
+	markdown := &MarkdownExportVisitor{}
+	if err := e.Accept(markdown); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return collapseSpace(markdown.GetMarkdown())
+}
+
+// HTML renders the new document with the changes marked up: inserted and
+// deleted elements and words are wrapped in <ins> and <del>, and moved
+// elements in <ins class="moved"> with the old path in data-moved-from.
+func (d *DocumentDiff) HTML() string {
+	var sb strings.Builder
+	sb.WriteString("<div class=\"diff\">\n")
+	writeDiffHTML(&sb, d.Root, 1)
+	sb.WriteString("</div>\n")
+	return sb.String()
+}
+
+// writeDiffHTML writes a change as HTML at the given indentation level
+func writeDiffHTML(sb *strings.Builder, c *Change, level int) {
+	indent := strings.Repeat("  ", level)
+	if c.Old == nil && c.New == nil {
+		// The virtual root of a diff between elements other than composites
+		for _, child := range c.Children {
+			writeDiffHTML(sb, child, level)
+		}
+		return
+	}
+
+	switch c.Kind {
+	case Unchanged:
+		sb.WriteString(elementHTML(c.New, level))
+	case Inserted:
+		sb.WriteString(indent + "<ins>\n" + elementHTML(c.New, level+1) + indent + "</ins>\n")
+	case Deleted:
+		sb.WriteString(indent + "<del>\n" + elementHTML(c.Old, level+1) + indent + "</del>\n")
+	case Moved:
+		sb.WriteString(fmt.Sprintf("%s<ins class=\"moved\" data-moved-from=\"%s\">\n", indent, html.EscapeString(c.OldPath)))
+		sb.WriteString(elementHTML(c.New, level+1) + indent + "</ins>\n")
+	case Modified:
+		writeModifiedHTML(sb, c, level)
+	}
+}
+
+// writeModifiedHTML writes a modified element with its inner changes marked up
+func writeModifiedHTML(sb *strings.Builder, c *Change, level int) {
+	indent := strings.Repeat("  ", level)
+	switch n := c.New.(type) {
+	case *CompositeElement:
+		renamed := ""
+		if isRename(c) {
+			renamed = fmt.Sprintf(" data-renamed-from=\"%s\"", html.EscapeString(strings.ToLower(c.Old.(*CompositeElement).Name)))
+		}
+		sb.WriteString(fmt.Sprintf("%s<div class=\"%s\"%s>\n", indent, html.EscapeString(strings.ToLower(n.Name)), renamed))
+		for _, child := range c.Children {
+			writeDiffHTML(sb, child, level+1)
+		}
+		sb.WriteString(indent + "</div>\n")
+	case *TextElement:
+		sb.WriteString(indent + "<p>" + wordsHTML(c.Words) + "</p>\n")
+	case *HeadingElement:
+		headingLevel := clampHeadingLevel(n.Level)
+		sb.WriteString(fmt.Sprintf("%s<h%d>%s</h%d>\n", indent, headingLevel, wordsHTML(c.Words), headingLevel))
+	case *QuoteElement:
+		if n.Cite != "" {
+			sb.WriteString(fmt.Sprintf("%s<blockquote cite=\"%s\">", indent, html.EscapeString(n.Cite)))
+		} else {
+			sb.WriteString(indent + "<blockquote>")
+		}
+		sb.WriteString(wordsHTML(c.Words) + "</blockquote>\n")
+	case *TableElement:
+		writeTableDiffHTML(sb, c.Rows, level)
+	default:
+		sb.WriteString(indent + "<del>\n" + elementHTML(c.Old, level+1) + indent + "</del>\n")
+		sb.WriteString(indent + "<ins>\n" + elementHTML(c.New, level+1) + indent + "</ins>\n")
+	}
+}
+
+// writeTableDiffHTML writes a table with every row of both versions and
+// changed cells marked up
+func writeTableDiffHTML(sb *strings.Builder, rows []RowChange, level int) {
+	indent := strings.Repeat("  ", level)
+	sb.WriteString(indent + "<table>\n")
+	for _, row := range rows {
+		if row.Kind == Unchanged {
+			sb.WriteString(indent + "  <tr>\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("%s  <tr class=\"%s\">\n", indent, row.Kind))
+		}
+		for _, cell := range row.Cells {
+			var content string
+			switch cell.Kind {
+			case Unchanged:
+				content = html.EscapeString(cell.New)
+			case Inserted:
+				content = "<ins>" + html.EscapeString(cell.New) + "</ins>"
+			case Deleted:
+				content = "<del>" + html.EscapeString(cell.Old) + "</del>"
+			default:
+				content = "<del>" + html.EscapeString(cell.Old) + "</del><ins>" + html.EscapeString(cell.New) + "</ins>"
+			}
+			sb.WriteString(indent + "    <td>" + content + "</td>\n")
+		}
+		sb.WriteString(indent + "  </tr>\n")
+	}
+	sb.WriteString(indent + "</table>\n")
+}
+
+// wordsHTML marks up word changes with <ins> and <del>
+func wordsHTML(edits []TextEdit) string {
+	parts := make([]string, len(edits))
+	for i, edit := range edits {
+		text := html.EscapeString(edit.Text)
+		switch edit.Kind {
+		case Deleted:
+			parts[i] = "<del>" + text + "</del>"
+		case Inserted:
+			parts[i] = "<ins>" + text + "</ins>"
+		default:
+			parts[i] = text
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// elementHTML exports an element with the HTMLExportVisitor at the given
+// indentation level
+func elementHTML(e Element, level int) string {
+	exporter := &HTMLExportVisitor{indentLevel: level}
+	if err := e.Accept(exporter); err != nil {
+		return fmt.Sprintf("%s<!-- %s -->\n", strings.Repeat("  ", level), html.EscapeString(err.Error()))
+	}
+	return exporter.GetHTML()
+}
@@ -0,0 +1,329 @@
+package visitor
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// diffDocuments returns an old and new version of a document with every
+// kind of change
+func diffDocuments() (*CompositeElement, *CompositeElement) {
+	old := &CompositeElement{
+		Name: "Doc",
+		Children: []Element{
+			&HeadingElement{Level: 1, Text: "Release notes"},
+			&TextElement{Content: "The quick brown fox jumps"},
+			&LinkElement{URL: "https://example.com", Text: "Home"},
+			&CompositeElement{
+				Name: "Details",
+				Children: []Element{
+					&TableElement{Rows: 2, Columns: 2, Data: [][]string{{"name", "value"}, {"a", "1"}}},
+					&ImageElement{Source: "old.png", Alt: "Old"},
+				},
+			},
+			&TextElement{Content: "Removed paragraph"},
+		},
+	}
+	new := &CompositeElement{
+		Name: "Doc",
+		Children: []Element{
+			&HeadingElement{Level: 1, Text: "Release notes"},
+			&TextElement{Content: "The quick red fox leaps"},
+			&CompositeElement{
+				Name: "Details",
+				Children: []Element{
+					&TableElement{Rows: 3, Columns: 2, Data: [][]string{{"name", "value"}, {"a", "2"}, {"b", "3"}}},
+					&ImageElement{Source: "new.png", Alt: "New"},
+					&LinkElement{URL: "https://example.com", Text: "Home"},
+				},
+			},
+			&QuoteElement{Content: "Added quote"},
+		},
+	}
+	return old, new
+}
+
+// TestDiffChanges tests that every kind of change is detected
+func TestDiffChanges(t *testing.T) {
+	old, new := diffDocuments()
+	diff := Diff(old, new)
+	if !diff.HasChanges() {
+		t.Fatal("Expected changes")
+	}
+
+	var got []string
+	for _, c := range diff.Changes() {
+		path := c.Path
+		if c.Kind == Deleted {
+			path = c.OldPath
+		}
+		got = append(got, c.Kind.String()+" "+path)
+	}
+	expected := []string{
+		"modified Doc/text[1]",
+		"modified Doc/Details[2]/table[0]",
+		"modified Doc/Details[2]/image[1]",
+		"moved Doc/Details[2]/link[2]",
+		"deleted Doc/text[4]",
+		"inserted Doc/quote[3]",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected changes.\nExpected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	if moved := diff.Changes()[3]; moved.OldPath != "Doc/link[2]" {
+		t.Errorf("Expected the link to be moved from Doc/link[2], got %q", moved.OldPath)
+	}
+}
+
+// TestDiffUnchanged tests that equal documents have no changes
+func TestDiffUnchanged(t *testing.T) {
+	old, _ := diffDocuments()
+	same, _ := diffDocuments()
+	diff := Diff(old, same)
+	if diff.HasChanges() || len(diff.Changes()) != 0 {
+		t.Errorf("Expected no changes, got %v", diff.Changes())
+	}
+	if diff.Unified() != "--- a/Doc\n+++ b/Doc\n" {
+		t.Errorf("Expected only the header, got:\n%s", diff.Unified())
+	}
+}
+
+// TestDiffWords tests word-level edits
+func TestDiffWords(t *testing.T) {
+	edits := diffWords("The quick brown fox jumps", "The quick red fox leaps high")
+	expected := []TextEdit{
+		{Unchanged, "The quick"},
+		{Deleted, "brown"},
+		{Inserted, "red"},
+		{Unchanged, "fox"},
+		{Deleted, "jumps"},
+		{Inserted, "leaps high"},
+	}
+	if len(edits) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, edits)
+	}
+	for i := range expected {
+		if edits[i] != expected[i] {
+			t.Errorf("Edit %d: expected %v, got %v", i, expected[i], edits[i])
+		}
+	}
+}
+
+// TestDiffTable tests that rows are aligned before cells are compared
+func TestDiffTable(t *testing.T) {
+	old := &TableElement{Rows: 3, Columns: 2, Data: [][]string{{"h1", "h2"}, {"a", "1"}, {"b", "2"}}}
+	new := &TableElement{Rows: 4, Columns: 2, Data: [][]string{{"h1", "h2"}, {"new", "0"}, {"a", "1"}, {"b", "3"}}}
+
+	rows := diffTable(old, new)
+	kinds := make([]string, len(rows))
+	for i, row := range rows {
+		kinds[i] = row.Kind.String()
+	}
+	if strings.Join(kinds, ",") != "unchanged,inserted,unchanged,modified" {
+		t.Fatalf("Unexpected row changes: %v", kinds)
+	}
+	cell := rows[3].Cells[1]
+	if cell.Kind != Modified || cell.Old != "2" || cell.New != "3" {
+		t.Errorf("Expected cell 1 of the last row to change from 2 to 3, got %+v", cell)
+	}
+	if rows[3].Cells[0].Kind != Unchanged {
+		t.Errorf("Expected cell 0 of the last row to be unchanged, got %+v", rows[3].Cells[0])
+	}
+}
+
+// TestDiffUnified tests the text rendering
+func TestDiffUnified(t *testing.T) {
+	old, new := diffDocuments()
+	expected := `--- a/Doc
++++ b/Doc
+! Doc/text[1]: The quick [-brown-] {+red+} fox [-jumps-] {+leaps+}
+! Doc/Details[2]/table[0]:
+!   cell[1,1]: [-1-]{+2+}
++   row[2]: b | 3
+- Doc/Details[3]/image[1]: ![Old](old.png)
++ Doc/Details[2]/image[1]: ![New](new.png)
+~ Doc/Details[2]/link[2]: [Home](https://example.com) (moved from Doc/link[2])
+- Doc/text[4]: Removed paragraph
++ Doc/quote[3]: > Added quote
+`
+	if got := Diff(old, new).Unified(); got != expected {
+		t.Errorf("Unexpected unified diff.\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+// TestDiffHTML tests the annotated HTML rendering
+func TestDiffHTML(t *testing.T) {
+	old, new := diffDocuments()
+	got := Diff(old, new).HTML()
+	for _, fragment := range []string{
+		"<div class=\"diff\">\n  <div class=\"doc\">\n    <h1>Release notes</h1>\n",
+		"<p>The quick <del>brown</del> <ins>red</ins> fox <del>jumps</del> <ins>leaps</ins></p>",
+		"<td><del>1</del><ins>2</ins></td>",
+		"<tr class=\"inserted\">",
+		"<ins class=\"moved\" data-moved-from=\"Doc/link[2]\">",
+		"<del>\n      <p>Removed paragraph</p>\n    </del>",
+		"<ins>\n      <blockquote>Added quote</blockquote>\n    </ins>",
+	} {
+		if !strings.Contains(got, fragment) {
+			t.Errorf("Expected HTML to contain %q, got:\n%s", fragment, got)
+		}
+	}
+}
+
+// TestDiffNestedMovesAndRenames tests moving a whole section and renaming the root
+func TestDiffNestedMovesAndRenames(t *testing.T) {
+	section := func() *CompositeElement {
+		return &CompositeElement{Name: "FAQ", Children: []Element{&TextElement{Content: "Question"}}}
+	}
+	old := &CompositeElement{Name: "Old", Children: []Element{
+		section(),
+		&CompositeElement{Name: "Body", Children: []Element{&TextElement{Content: "Body text"}}},
+	}}
+	new := &CompositeElement{Name: "New", Children: []Element{
+		&CompositeElement{Name: "Body", Children: []Element{&TextElement{Content: "Body text"}, section()}},
+	}}
+
+	changes := Diff(old, new).Changes()
+	if len(changes) != 2 {
+		t.Fatalf("Expected a rename and a move, got %d changes", len(changes))
+	}
+	if changes[0].Kind != Modified || changes[0].Path != "New" {
+		t.Errorf("Expected the root to be renamed, got %+v", changes[0])
+	}
+	if changes[1].Kind != Moved || changes[1].Path != "New/Body[0]/FAQ[1]" || changes[1].OldPath != "Old/FAQ[0]" {
+		t.Errorf("Expected the FAQ section to move into Body, got %+v", changes[1])
+	}
+}
+
+// TestDiffLeaves tests comparing elements other than composites
+func TestDiffLeaves(t *testing.T) {
+	diff := Diff(&TextElement{Content: "hello world"}, &TextElement{Content: "hello there"})
+	if got := diff.Unified(); !strings.Contains(got, "! text[0]: hello [-world-] {+there+}\n") {
+		t.Errorf("Unexpected diff of two text elements:\n%s", got)
+	}
+	if got := diff.HTML(); got != "<div class=\"diff\">\n  <p>hello <del>world</del> <ins>there</ins></p>\n</div>\n" {
+		t.Errorf("Unexpected HTML:\n%s", got)
+	}
+
+	if changes := Diff(nil, &LinkElement{URL: "x"}).Changes(); len(changes) != 1 || changes[0].Kind != Inserted {
+		t.Errorf("Expected an insertion, got %v", changes)
+	}
+}
+
+// TestLCSPairs compares the alignment with the length of a longest common
+// subsequence found by dynamic programming
+func TestLCSPairs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for run := 0; run < 500; run++ {
+		a, b := make([]int, rng.Intn(30)), make([]int, rng.Intn(30))
+		for i := range a {
+			a[i] = rng.Intn(4)
+		}
+		for j := range b {
+			b[j] = rng.Intn(4)
+		}
+
+		lengths := make([][]int, len(a)+1)
+		for i := range lengths {
+			lengths[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lengths[i][j] = lengths[i+1][j+1] + 1
+				} else {
+					lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+				}
+			}
+		}
+
+		pairs := lcsPairs(len(a), len(b), func(i, j int) bool { return a[i] == b[j] })
+		if len(pairs) != lengths[0][0] {
+			t.Fatalf("Expected %d pairs for %v and %v, got %v", lengths[0][0], a, b, pairs)
+		}
+		for k, pair := range pairs {
+			if a[pair[0]] != b[pair[1]] || (k > 0 && (pair[0] <= pairs[k-1][0] || pair[1] <= pairs[k-1][1])) {
+				t.Fatalf("Invalid alignment of %v and %v: %v", a, b, pairs)
+			}
+		}
+	}
+}
+
+// paragraphs returns a document of n paragraphs that are all the same
+func paragraphs(n int) *CompositeElement {
+	doc := &CompositeElement{Name: "Doc"}
+	for i := 0; i < n; i++ {
+		doc.Children = append(doc.Children, &TextElement{Content: "The same paragraph"})
+	}
+	return doc
+}
+
+// words returns a text of n words with every hundredth word changed when
+// edited is set
+func words(n int, edited bool) string {
+	ws := make([]string, n)
+	for i := range ws {
+		ws[i] = fmt.Sprintf("w%d", i%50)
+		if edited && i%100 == 0 {
+			ws[i] = "edited"
+		}
+	}
+	return strings.Join(ws, " ")
+}
+
+// allocated returns the bytes allocated by f
+func allocated(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+// TestDiffScalesLinearly checks that four times the input needs far less
+// than sixteen times the memory, which a quadratic alignment would
+func TestDiffScalesLinearly(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(n int)
+	}{
+		{"identical documents", func(n int) { doc := paragraphs(n); Diff(doc, doc) }},
+		{"words", func(n int) { diffWords(words(n, false), words(n, true)) }},
+		{"moves", func(n int) {
+			old, new := paragraphs(0), paragraphs(0)
+			for i := 0; i < n; i++ {
+				old.Children = append(old.Children, &TextElement{Content: fmt.Sprint(i)})
+				new.Children = append(new.Children, &TextElement{Content: fmt.Sprint((i + n/2) % n)})
+			}
+			Diff(old, new)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			small := allocated(func() { test.run(2000) })
+			large := allocated(func() { test.run(8000) })
+			if large > 8*small {
+				t.Errorf("Expected roughly linear memory use, 2000 elements took %d bytes and 8000 took %d", small, large)
+			}
+		})
+	}
+}
+
+func BenchmarkDiffIdentical(b *testing.B) {
+	doc := paragraphs(5000)
+	for i := 0; i < b.N; i++ {
+		Diff(doc, doc)
+	}
+}
+
+func BenchmarkDiffWords(b *testing.B) {
+	old, new := words(8000, false), words(8000, true)
+	for i := 0; i < b.N; i++ {
+		diffWords(old, new)
+	}
+}
@@ -0,0 +1,811 @@
+package visitor
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultDocumentName is the name given to the root composite when an
+// imported document does not consist of a single top-level section
+const DefaultDocumentName = "Document"
+
+// ParseMarkdown builds an element tree from a subset of Markdown.
+//
+// Supported constructs are ATX headings (# to ######), which open nested
+// CompositeElements, paragraphs, images (![alt](src)), links
+// ([text](url "title")), pipe tables, bulleted and numbered lists, fenced
+// code blocks and block quotes. This is the same subset produced by
+// MarkdownExportVisitor, so exported documents can be read back in.
+//
+// Markdown has no separate notion of sections, so a HeadingElement that was
+// exported to Markdown is read back as a section.
+func ParseMarkdown(r io.Reader) (*CompositeElement, error) {
+	p := &markdownParser{}
+	p.root = &CompositeElement{Name: DefaultDocumentName}
+	p.stack = []markdownSection{{element: p.root, level: 0}}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if err := p.parseLine(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("markdown line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading markdown: %w", err)
+	}
+	if p.code != nil {
+		return nil, fmt.Errorf("markdown line %d: unterminated code block", lineNo)
+	}
+	if err := p.flush(); err != nil {
+		return nil, fmt.Errorf("markdown line %d: %w", lineNo, err)
+	}
+
+	return unwrapRoot(p.root), nil
+}
+
+// markdownSection is an open heading together with its level
+type markdownSection struct {
+	element *CompositeElement
+	level   int
+}
+
+// markdownParser keeps the state needed while reading Markdown line by line.
+// At most one of paragraph, table, quote and list is pending at a time.
+type markdownParser struct {
+	root      *CompositeElement
+	stack     []markdownSection
+	paragraph []string
+	table     []string
+	quote     []string
+	list      *ListElement
+	code      *CodeBlockElement
+	codeLines []string
+}
+
+var (
+	markdownHeading  = regexp.MustCompile(`^(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	markdownInline   = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*([^)\s]*)(?:\s+"([^"]*)")?\s*\)`)
+	markdownListItem = regexp.MustCompile(`^(?:[-*+]|(\d+)[.)])\s+(.*)$`)
+)
+
+// current returns the section new elements are appended to
+func (p *markdownParser) current() *CompositeElement {
+	return p.stack[len(p.stack)-1].element
+}
+
+// parseLine consumes a single line of input
+func (p *markdownParser) parseLine(line string) error {
+	trimmed := strings.TrimSpace(line)
+
+	// Inside a fenced code block every line is kept verbatim
+	if p.code != nil {
+		if strings.HasPrefix(trimmed, "```") {
+			p.code.Code = strings.Join(p.codeLines, "\n")
+			p.current().AddChild(p.code)
+			p.code, p.codeLines = nil, nil
+			return nil
+		}
+		p.codeLines = append(p.codeLines, line)
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(trimmed, "```"):
+		if err := p.flush(); err != nil {
+			return err
+		}
+		p.code = &CodeBlockElement{Language: strings.TrimSpace(trimmed[3:])}
+
+	case strings.HasPrefix(trimmed, "|"):
+		// Table rows are collected until the first line that is not a row
+		if len(p.table) == 0 {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		p.table = append(p.table, trimmed)
+
+	case trimmed == "":
+		return p.flush()
+
+	case markdownHeading.MatchString(trimmed):
+		if err := p.flush(); err != nil {
+			return err
+		}
+		m := markdownHeading.FindStringSubmatch(trimmed)
+		level := len(m[1])
+		for len(p.stack) > 1 && p.stack[len(p.stack)-1].level >= level {
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+		section := &CompositeElement{Name: m[2]}
+		p.current().AddChild(section)
+		p.stack = append(p.stack, markdownSection{element: section, level: level})
+
+	case strings.HasPrefix(trimmed, ">"):
+		if len(p.quote) == 0 {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		p.quote = append(p.quote, strings.TrimSpace(trimmed[1:]))
+
+	case markdownListItem.MatchString(trimmed):
+		m := markdownListItem.FindStringSubmatch(trimmed)
+		ordered := m[1] != ""
+		if p.list == nil || p.list.Ordered != ordered {
+			if err := p.flush(); err != nil {
+				return err
+			}
+			p.list = &ListElement{Ordered: ordered}
+		}
+		p.list.Items = append(p.list.Items, m[2])
+
+	case p.list != nil:
+		// A line that is not an item continues the previous item
+		last := len(p.list.Items) - 1
+		p.list.Items[last] += " " + trimmed
+
+	default:
+		if len(p.paragraph) == 0 {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		p.paragraph = append(p.paragraph, trimmed)
+	}
+	return nil
+}
+
+// flush turns any pending paragraph, table, quote or list into elements
+func (p *markdownParser) flush() error {
+	if len(p.table) > 0 {
+		table, err := parseMarkdownTable(p.table)
+		p.table = nil
+		if err != nil {
+			return err
+		}
+		p.current().AddChild(table)
+	}
+
+	if len(p.paragraph) > 0 {
+		for _, element := range parseMarkdownInline(strings.Join(p.paragraph, " ")) {
+			p.current().AddChild(element)
+		}
+		p.paragraph = nil
+	}
+
+	if len(p.quote) > 0 {
+		p.current().AddChild(&QuoteElement{Content: collapseSpace(strings.Join(p.quote, " "))})
+		p.quote = nil
+	}
+
+	if p.list != nil {
+		p.current().AddChild(p.list)
+		p.list = nil
+	}
+	return nil
+}
+
+// parseMarkdownInline splits a paragraph into text, image and link elements
+func parseMarkdownInline(text string) []Element {
+	var elements []Element
+	addText := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			elements = append(elements, &TextElement{Content: s})
+		}
+	}
+
+	last := 0
+	for _, m := range markdownInline.FindAllStringSubmatchIndex(text, -1) {
+		addText(text[last:m[0]])
+		label, target := text[m[4]:m[5]], text[m[6]:m[7]]
+		title := ""
+		if m[8] >= 0 {
+			title = text[m[8]:m[9]]
+		}
+		if m[3] > m[2] {
+			elements = append(elements, &ImageElement{Source: target, Alt: label})
+		} else {
+			elements = append(elements, &LinkElement{URL: target, Text: label, Title: title})
+		}
+		last = m[1]
+	}
+	addText(text[last:])
+
+	return elements
+}
+
+// parseMarkdownTable converts pipe table rows into a TableElement.
+// The second row must be a separator row such as | --- | --- |
+func parseMarkdownTable(lines []string) (*TableElement, error) {
+	table := &TableElement{}
+	for i, line := range lines {
+		cells := splitMarkdownRow(line)
+		if i == 1 {
+			if !isMarkdownSeparatorRow(cells) {
+				return nil, fmt.Errorf("table is missing a separator row after the header")
+			}
+			continue
+		}
+		table.Data = append(table.Data, cells)
+	}
+	padTable(table)
+	return table, nil
+}
+
+// padTable sets Rows and Columns from Data and pads short rows with empty
+// cells, which is how the exporters write ragged tables
+func padTable(table *TableElement) {
+	table.Rows = len(table.Data)
+	table.Columns = 0
+	for _, row := range table.Data {
+		if len(row) > table.Columns {
+			table.Columns = len(row)
+		}
+	}
+	for i, row := range table.Data {
+		for len(row) < table.Columns {
+			row = append(row, "")
+		}
+		table.Data[i] = row
+	}
+}
+
+// splitMarkdownRow returns the trimmed cells of a | a | b | row
+func splitMarkdownRow(line string) []string {
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// isMarkdownSeparatorRow reports whether cells look like | --- | :-: |
+func isMarkdownSeparatorRow(cells []string) bool {
+	for _, cell := range cells {
+		cell = strings.Trim(cell, ":")
+		if cell == "" || strings.Trim(cell, "-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseHTML builds an element tree from a subset of HTML.
+//
+// Block containers (div, section, article, ...) become CompositeElements
+// named after their class or id attribute. p becomes a TextElement, h1-h6
+// a HeadingElement, ul and ol a ListElement, pre a CodeBlockElement and
+// blockquote a QuoteElement, while img, a and table map to ImageElement,
+// LinkElement and TableElement. Unknown tags are ignored while their text
+// is kept; head, script and style are skipped entirely. This is the same
+// subset produced by HTMLExportVisitor.
+func ParseHTML(r io.Reader) (*CompositeElement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading html: %w", err)
+	}
+
+	tokens, err := tokenizeHTML(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &htmlParser{root: &CompositeElement{Name: strings.ToLower(DefaultDocumentName)}}
+	p.stack = []htmlContainer{{element: p.root}}
+	for _, tok := range tokens {
+		if err := p.handle(tok); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case len(p.stack) > 1:
+		return nil, fmt.Errorf("unclosed <%s> element", p.stack[len(p.stack)-1].tag)
+	case p.block != nil:
+		return nil, fmt.Errorf("unclosed <%s> element", p.block.tag)
+	case p.list != nil:
+		return nil, fmt.Errorf("unclosed list element")
+	case p.table != nil:
+		return nil, fmt.Errorf("unclosed <table> element")
+	}
+	p.flushText()
+
+	return unwrapRoot(p.root), nil
+}
+
+// htmlContainerTags are the tags that open a new CompositeElement
+var htmlContainerTags = map[string]bool{
+	"div": true, "section": true, "article": true, "main": true,
+	"header": true, "footer": true, "nav": true, "aside": true,
+}
+
+// htmlSkippedTags are dropped together with everything inside them
+var htmlSkippedTags = map[string]bool{
+	"head": true, "script": true, "style": true, "template": true,
+}
+
+// htmlContainer is an open composite together with the tag that opened it
+type htmlContainer struct {
+	element *CompositeElement
+	tag     string
+}
+
+// htmlBlock is a heading, pre or blockquote whose text is being captured.
+// Tags inside a block are ignored, only their text is kept.
+type htmlBlock struct {
+	tag      string
+	language string
+	cite     string
+	text     strings.Builder
+}
+
+// htmlParser builds the element tree from a token stream
+type htmlParser struct {
+	root  *CompositeElement
+	stack []htmlContainer
+
+	text      strings.Builder // pending inline text
+	skipDepth int
+	skipTag   string
+	link      *LinkElement
+	table     *TableElement
+	row       []string
+	cell      *strings.Builder
+	block     *htmlBlock
+	list      *ListElement
+	item      *strings.Builder
+}
+
+// current returns the composite new elements are appended to
+func (p *htmlParser) current() *CompositeElement {
+	return p.stack[len(p.stack)-1].element
+}
+
+// flushText turns pending inline text into a TextElement
+func (p *htmlParser) flushText() {
+	content := collapseSpace(p.text.String())
+	p.text.Reset()
+	if content != "" {
+		p.current().AddChild(&TextElement{Content: content})
+	}
+}
+
+// handle processes a single token
+func (p *htmlParser) handle(tok htmlToken) error {
+	if p.skipDepth > 0 {
+		switch {
+		case tok.kind == htmlStartTag && tok.tag == p.skipTag:
+			p.skipDepth++
+		case tok.kind == htmlEndTag && tok.tag == p.skipTag:
+			p.skipDepth--
+		}
+		return nil
+	}
+
+	switch tok.kind {
+	case htmlText:
+		switch {
+		case p.cell != nil:
+			p.cell.WriteString(tok.data)
+		case p.block != nil:
+			p.block.text.WriteString(tok.data)
+		case p.item != nil:
+			p.item.WriteString(tok.data)
+		case p.list != nil:
+			// Whitespace between list items
+		case p.link != nil:
+			p.link.Text += tok.data
+		default:
+			p.text.WriteString(tok.data)
+		}
+		return nil
+	case htmlStartTag, htmlSelfClosingTag:
+		return p.startTag(tok)
+	case htmlEndTag:
+		return p.endTag(tok)
+	}
+	return nil
+}
+
+// startTag handles opening and self-closing tags
+func (p *htmlParser) startTag(tok htmlToken) error {
+	switch {
+	case p.table != nil:
+		switch tok.tag {
+		case "tr":
+			p.row = []string{}
+		case "td", "th":
+			p.cell = &strings.Builder{}
+		case "br":
+			if p.cell != nil {
+				p.cell.WriteString(" ")
+			}
+		}
+		return nil
+	case p.block != nil:
+		switch {
+		case tok.tag == "code" && p.block.tag == "pre":
+			p.block.language = strings.TrimPrefix(tok.attrs["class"], "language-")
+		case tok.tag == "br" && p.block.tag == "pre":
+			p.block.text.WriteString("\n")
+		case tok.tag == "br":
+			p.block.text.WriteString(" ")
+		}
+		return nil
+	case p.list != nil:
+		switch tok.tag {
+		case "ul", "ol":
+			return fmt.Errorf("nested lists are not supported")
+		case "li":
+			if p.item != nil {
+				p.endItem()
+			}
+			p.item = &strings.Builder{}
+		case "br":
+			if p.item != nil {
+				p.item.WriteString(" ")
+			}
+		}
+		return nil
+	}
+
+	switch {
+	case htmlSkippedTags[tok.tag]:
+		if tok.kind == htmlStartTag {
+			p.skipDepth, p.skipTag = 1, tok.tag
+		}
+	case htmlContainerTags[tok.tag]:
+		p.flushText()
+		name := tok.attrs["class"]
+		if name == "" {
+			name = tok.attrs["id"]
+		}
+		section := &CompositeElement{Name: name}
+		p.current().AddChild(section)
+		if tok.kind == htmlStartTag {
+			p.stack = append(p.stack, htmlContainer{element: section, tag: tok.tag})
+		}
+	case tok.tag == "p":
+		p.flushText()
+	case htmlHeadingLevel(tok.tag) > 0, tok.tag == "pre", tok.tag == "blockquote":
+		p.flushText()
+		p.block = &htmlBlock{tag: tok.tag, cite: tok.attrs["cite"]}
+	case tok.tag == "ul", tok.tag == "ol":
+		p.flushText()
+		p.list = &ListElement{Ordered: tok.tag == "ol"}
+	case tok.tag == "img":
+		p.flushText()
+		width, err := parseHTMLDimension(tok.attrs["width"])
+		if err != nil {
+			return fmt.Errorf("img width: %w", err)
+		}
+		height, err := parseHTMLDimension(tok.attrs["height"])
+		if err != nil {
+			return fmt.Errorf("img height: %w", err)
+		}
+		p.current().AddChild(&ImageElement{
+			Source: tok.attrs["src"],
+			Alt:    tok.attrs["alt"],
+			Width:  width,
+			Height: height,
+		})
+	case tok.tag == "a":
+		if p.link != nil {
+			return fmt.Errorf("nested <a> elements are not supported")
+		}
+		p.flushText()
+		p.link = &LinkElement{URL: tok.attrs["href"], Title: tok.attrs["title"]}
+		if tok.kind == htmlSelfClosingTag {
+			p.endLink()
+		}
+	case tok.tag == "table":
+		p.flushText()
+		p.table = &TableElement{}
+	case tok.tag == "br":
+		p.text.WriteString(" ")
+	}
+	return nil
+}
+
+// endTag handles closing tags
+func (p *htmlParser) endTag(tok htmlToken) error {
+	switch {
+	case p.table != nil:
+		switch tok.tag {
+		case "td", "th":
+			if p.cell != nil && p.row != nil {
+				p.row = append(p.row, collapseSpace(p.cell.String()))
+			}
+			p.cell = nil
+		case "tr":
+			if p.row != nil {
+				p.table.Data = append(p.table.Data, p.row)
+			}
+			p.row = nil
+		case "table":
+			padTable(p.table)
+			p.current().AddChild(p.table)
+			p.table = nil
+		}
+		return nil
+	case p.block != nil:
+		if tok.tag == p.block.tag {
+			p.endBlock()
+		}
+		return nil
+	case p.list != nil:
+		switch tok.tag {
+		case "li":
+			if p.item != nil {
+				p.endItem()
+			}
+		case "ul", "ol":
+			if p.item != nil {
+				p.endItem()
+			}
+			p.current().AddChild(p.list)
+			p.list = nil
+		}
+		return nil
+	}
+
+	switch {
+	case htmlContainerTags[tok.tag]:
+		top := p.stack[len(p.stack)-1]
+		if len(p.stack) == 1 || top.tag != tok.tag {
+			return fmt.Errorf("unexpected </%s>", tok.tag)
+		}
+		p.flushText()
+		p.stack = p.stack[:len(p.stack)-1]
+	case tok.tag == "p":
+		p.flushText()
+	case tok.tag == "a":
+		if p.link == nil {
+			return fmt.Errorf("unexpected </a>")
+		}
+		p.endLink()
+	}
+	return nil
+}
+
+// endLink appends the link being built to the current composite
+func (p *htmlParser) endLink() {
+	p.link.Text = collapseSpace(p.link.Text)
+	p.current().AddChild(p.link)
+	p.link = nil
+}
+
+// endItem adds the list item being built to the current list
+func (p *htmlParser) endItem() {
+	p.list.Items = append(p.list.Items, collapseSpace(p.item.String()))
+	p.item = nil
+}
+
+// endBlock turns the captured block into a heading, code block or quote
+func (p *htmlParser) endBlock() {
+	block := p.block
+	p.block = nil
+
+	switch {
+	case block.tag == "pre":
+		// Whitespace is significant in code, so it is kept as is
+		p.current().AddChild(&CodeBlockElement{Language: block.language, Code: block.text.String()})
+	case block.tag == "blockquote":
+		p.current().AddChild(&QuoteElement{Content: collapseSpace(block.text.String()), Cite: block.cite})
+	default:
+		p.current().AddChild(&HeadingElement{
+			Level: htmlHeadingLevel(block.tag),
+			Text:  collapseSpace(block.text.String()),
+		})
+	}
+}
+
+// htmlHeadingLevel returns 1-6 for h1-h6 and 0 for any other tag
+func htmlHeadingLevel(tag string) int {
+	if len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6' {
+		return int(tag[1] - '0')
+	}
+	return 0
+}
+
+// parseHTMLDimension parses an optional width or height attribute
+func parseHTMLDimension(value string) (int, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// collapseSpace trims s and replaces runs of whitespace with a single space
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// unwrapRoot returns the only child of root when the document consists of a
+// single top-level section, so that importing an exported document does not
+// add an extra level of nesting
+func unwrapRoot(root *CompositeElement) *CompositeElement {
+	if len(root.Children) == 1 {
+		if section, ok := root.Children[0].(*CompositeElement); ok {
+			return section
+		}
+	}
+	return root
+}
+
+// htmlTokenKind identifies the type of an htmlToken
+type htmlTokenKind int
+
+const (
+	htmlText htmlTokenKind = iota
+	htmlStartTag
+	htmlEndTag
+	htmlSelfClosingTag
+)
+
+// htmlToken is a single piece of an HTML document
+type htmlToken struct {
+	kind  htmlTokenKind
+	tag   string            // lower-cased tag name for tag tokens
+	attrs map[string]string // unescaped attribute values for start tags
+	data  string            // unescaped text for text tokens
+}
+
+// htmlVoidTags never have a closing tag
+var htmlVoidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// tokenizeHTML splits an HTML document into text and tag tokens.
+// Comments, doctypes and processing instructions are dropped.
+func tokenizeHTML(src string) ([]htmlToken, error) {
+	var tokens []htmlToken
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt < 0 {
+			tokens = append(tokens, htmlToken{kind: htmlText, data: html.UnescapeString(src)})
+			break
+		}
+		if lt > 0 {
+			tokens = append(tokens, htmlToken{kind: htmlText, data: html.UnescapeString(src[:lt])})
+			src = src[lt:]
+		}
+
+		switch {
+		case strings.HasPrefix(src, "<!--"):
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated html comment")
+			}
+			src = src[end+3:]
+		case strings.HasPrefix(src, "<!"), strings.HasPrefix(src, "<?"):
+			end := strings.IndexByte(src, '>')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated html declaration")
+			}
+			src = src[end+1:]
+		default:
+			tok, rest, err := readHTMLTag(src)
+			if err != nil {
+				return nil, err
+			}
+			if tok == nil {
+				// A lone '<' that does not start a tag is plain text
+				tokens = append(tokens, htmlToken{kind: htmlText, data: "<"})
+				src = src[1:]
+				continue
+			}
+			tokens = append(tokens, *tok)
+			src = rest
+		}
+	}
+	return tokens, nil
+}
+
+// readHTMLTag reads a tag starting at src[0] == '<'. It returns a nil token
+// if src does not start with a tag.
+func readHTMLTag(src string) (*htmlToken, string, error) {
+	i := 1
+	tok := &htmlToken{kind: htmlStartTag}
+	if i < len(src) && src[i] == '/' {
+		tok.kind = htmlEndTag
+		i++
+	}
+
+	start := i
+	if i >= len(src) || !isHTMLLetter(src[i]) {
+		return nil, src, nil
+	}
+	for i < len(src) && (isHTMLLetter(src[i]) || src[i] >= '0' && src[i] <= '9' || src[i] == '-') {
+		i++
+	}
+	tok.tag = strings.ToLower(src[start:i])
+	tok.attrs = map[string]string{}
+
+	for {
+		for i < len(src) && isHTMLSpace(src[i]) {
+			i++
+		}
+		if i >= len(src) {
+			return nil, src, fmt.Errorf("unterminated <%s> tag", tok.tag)
+		}
+		switch src[i] {
+		case '>':
+			if tok.kind == htmlStartTag && htmlVoidTags[tok.tag] {
+				tok.kind = htmlSelfClosingTag
+			}
+			return tok, src[i+1:], nil
+		case '/':
+			if i+1 < len(src) && src[i+1] == '>' {
+				if tok.kind == htmlStartTag {
+					tok.kind = htmlSelfClosingTag
+				}
+				return tok, src[i+2:], nil
+			}
+			i++
+			continue
+		}
+
+		// Attribute name
+		start = i
+		for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '=' && src[i] != '>' && src[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(src[start:i])
+		for i < len(src) && isHTMLSpace(src[i]) {
+			i++
+		}
+		if i >= len(src) || src[i] != '=' {
+			tok.attrs[name] = ""
+			continue
+		}
+		i++
+		for i < len(src) && isHTMLSpace(src[i]) {
+			i++
+		}
+		if i >= len(src) {
+			return nil, src, fmt.Errorf("unterminated <%s> tag", tok.tag)
+		}
+
+		// Attribute value, quoted or bare
+		var value string
+		if quote := src[i]; quote == '"' || quote == '\'' {
+			end := strings.IndexByte(src[i+1:], quote)
+			if end < 0 {
+				return nil, src, fmt.Errorf("unterminated attribute %q in <%s>", name, tok.tag)
+			}
+			value = src[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			start = i
+			for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '>' {
+				i++
+			}
+			value = src[start:i]
+		}
+		tok.attrs[name] = html.UnescapeString(value)
+	}
+}
+
+// isHTMLLetter reports whether c is an ASCII letter
+func isHTMLLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isHTMLSpace reports whether c is HTML whitespace
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,355 @@
+package visitor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sampleImportDocument builds a document that uses every element type
+func sampleImportDocument() *CompositeElement {
+	return &CompositeElement{
+		Name: "Guide",
+		Children: []Element{
+			&CompositeElement{
+				Name: "Intro",
+				Children: []Element{
+					&TextElement{Content: "The visitor pattern separates algorithms & data."},
+					&ImageElement{Source: "diagram.png", Alt: "Class diagram", Width: 200, Height: 100},
+					&LinkElement{URL: "https://example.com/visitor", Text: "Read more", Title: "Visitor docs"},
+				},
+			},
+			&CompositeElement{
+				Name: "Details",
+				Children: []Element{
+					&TableElement{
+						Rows:    3,
+						Columns: 2,
+						Data: [][]string{
+							{"Name", "Role"},
+							{"Element", "Accepts visitors"},
+							{"Visitor", "Implements operations"},
+						},
+					},
+					&ListElement{Items: []string{"Accept", "Visit"}},
+					&CodeBlockElement{Language: "go", Code: "func main() {\n\tdoc.Accept(v)\n}"},
+					&QuoteElement{Content: "Represent an operation to be performed on elements."},
+					&ListElement{Ordered: true, Items: []string{"Define elements", "Write visitors"}},
+					&TextElement{Content: "That is all."},
+				},
+			},
+		},
+	}
+}
+
+// TestParseMarkdown tests parsing of every supported Markdown construct
+func TestParseMarkdown(t *testing.T) {
+	input := `# Guide
+
+Some intro text
+that spans two lines.
+
+## Setup
+
+![Logo](logo.png)
+
+See [the docs](https://example.com "Docs") for details.
+
+| Key | Value |
+| --- | :---: |
+| a | 1 |
+| b |
+
+## Usage
+
+Done.
+`
+	doc, err := ParseMarkdown(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseMarkdown returned error: %v", err)
+	}
+
+	expected := &CompositeElement{
+		Name: "Guide",
+		Children: []Element{
+			&TextElement{Content: "Some intro text that spans two lines."},
+			&CompositeElement{
+				Name: "Setup",
+				Children: []Element{
+					&ImageElement{Source: "logo.png", Alt: "Logo"},
+					&TextElement{Content: "See"},
+					&LinkElement{URL: "https://example.com", Text: "the docs", Title: "Docs"},
+					&TextElement{Content: "for details."},
+					&TableElement{
+						Rows:    3,
+						Columns: 2,
+						Data:    [][]string{{"Key", "Value"}, {"a", "1"}, {"b", ""}},
+					},
+				},
+			},
+			&CompositeElement{
+				Name:     "Usage",
+				Children: []Element{&TextElement{Content: "Done."}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("ParseMarkdown produced an unexpected tree.\nExpected: %s\nGot: %s", dumpElement(expected), dumpElement(doc))
+	}
+}
+
+// TestParseMarkdownBlocks tests lists, code blocks and quotes
+func TestParseMarkdownBlocks(t *testing.T) {
+	input := "- one\n* two\n  continued\n\n1. first\n2) second\n\n" +
+		"```python\ndef f():\n\n    return 1\n```\n" +
+		"> To be\n> or not\n"
+	doc, err := ParseMarkdown(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseMarkdown returned error: %v", err)
+	}
+
+	expected := &CompositeElement{
+		Name: DefaultDocumentName,
+		Children: []Element{
+			&ListElement{Items: []string{"one", "two continued"}},
+			&ListElement{Ordered: true, Items: []string{"first", "second"}},
+			&CodeBlockElement{Language: "python", Code: "def f():\n\n    return 1"},
+			&QuoteElement{Content: "To be or not"},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("ParseMarkdown produced an unexpected tree.\nExpected: %s\nGot: %s", dumpElement(expected), dumpElement(doc))
+	}
+
+	if _, err := ParseMarkdown(strings.NewReader("```\nnever closed\n")); err == nil {
+		t.Error("Expected an error for an unterminated code block")
+	}
+}
+
+// TestParseMarkdownWrapsMultipleSections tests that several top-level
+// sections are wrapped in a default root
+func TestParseMarkdownWrapsMultipleSections(t *testing.T) {
+	doc, err := ParseMarkdown(strings.NewReader("# One\n\n# Two\n"))
+	if err != nil {
+		t.Fatalf("ParseMarkdown returned error: %v", err)
+	}
+	if doc.Name != DefaultDocumentName || len(doc.Children) != 2 {
+		t.Errorf("Expected a %q root with 2 children, got %s", DefaultDocumentName, dumpElement(doc))
+	}
+}
+
+// TestParseMarkdownInvalidTable tests that a table without separator is rejected
+func TestParseMarkdownInvalidTable(t *testing.T) {
+	_, err := ParseMarkdown(strings.NewReader("| a | b |\n| c | d |\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error mentioning line 2, got %v", err)
+	}
+}
+
+// TestParseHTML tests parsing of every supported HTML construct
+func TestParseHTML(t *testing.T) {
+	input := `<!DOCTYPE html>
+<html>
+<head><title>Ignored</title><style>p { color: red; }</style></head>
+<body>
+<section id="Guide">
+  <!-- a comment -->
+  <h1>Welcome</h1>
+  <p>Fish &amp; chips<br>are <em>great</em>.</p>
+  <img src="fish.png" alt="A fish" width="64" height="32"/>
+  <p>Visit <a href="https://example.com" title='Example'>our site</a> today</p>
+  <table>
+    <tr><th>Dish</th><th>Price</th></tr>
+    <tr><td>Cod</td><td>9</td></tr>
+  </table>
+  <script>document.write("<p>nope</p>")</script>
+</section>
+</body>
+</html>`
+	doc, err := ParseHTML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+
+	expected := &CompositeElement{
+		Name: "Guide",
+		Children: []Element{
+			&HeadingElement{Level: 1, Text: "Welcome"},
+			&TextElement{Content: "Fish & chips are great."},
+			&ImageElement{Source: "fish.png", Alt: "A fish", Width: 64, Height: 32},
+			&TextElement{Content: "Visit"},
+			&LinkElement{URL: "https://example.com", Text: "our site", Title: "Example"},
+			&TextElement{Content: "today"},
+			&TableElement{
+				Rows:    2,
+				Columns: 2,
+				Data:    [][]string{{"Dish", "Price"}, {"Cod", "9"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("ParseHTML produced an unexpected tree.\nExpected: %s\nGot: %s", dumpElement(expected), dumpElement(doc))
+	}
+}
+
+// TestParseHTMLBlocks tests lists, code blocks and quotes
+func TestParseHTMLBlocks(t *testing.T) {
+	input := `<ul><li>one</li><li>two <b>bold</b></li></ul>
+<ol>
+  <li>first
+  <li>second
+</ol>
+<pre><code class="language-go">if a &lt; b {
+    return
+}</code></pre>
+<blockquote cite="Hamlet"><p>To be,</p> or not</blockquote>
+<h7>not a heading</h7>`
+	doc, err := ParseHTML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+
+	expected := &CompositeElement{
+		Name: "document",
+		Children: []Element{
+			&ListElement{Items: []string{"one", "two bold"}},
+			&ListElement{Ordered: true, Items: []string{"first", "second"}},
+			&CodeBlockElement{Language: "go", Code: "if a < b {\n    return\n}"},
+			&QuoteElement{Content: "To be, or not", Cite: "Hamlet"},
+			&TextElement{Content: "not a heading"},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("ParseHTML produced an unexpected tree.\nExpected: %s\nGot: %s", dumpElement(expected), dumpElement(doc))
+	}
+}
+
+// TestParseHTMLErrors tests that malformed HTML is reported
+func TestParseHTMLErrors(t *testing.T) {
+	cases := map[string]string{
+		"unclosed container": `<div class="a"><p>text</p>`,
+		"mismatched close":   `<div class="a"></section>`,
+		"unterminated tag":   `<img src="a.png"`,
+		"bad dimension":      `<img src="a.png" width="wide">`,
+		"nested links":       `<a href="a"><a href="b">x</a></a>`,
+		"nested lists":       `<ul><li><ul><li>x</li></ul></li></ul>`,
+		"unclosed heading":   `<h2>Title`,
+	}
+	for name, input := range cases {
+		if _, err := ParseHTML(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// TestMarkdownRoundTrip tests that import→export→import is stable
+func TestMarkdownRoundTrip(t *testing.T) {
+	first := importMarkdown(t, exportMarkdown(t, sampleImportDocument()))
+	second := importMarkdown(t, exportMarkdown(t, first))
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Markdown round trip is not stable.\nFirst: %s\nSecond: %s", dumpElement(first), dumpElement(second))
+	}
+	assertSameStatistics(t, sampleImportDocument(), second)
+}
+
+// TestHTMLRoundTrip tests that import→export→import is stable
+func TestHTMLRoundTrip(t *testing.T) {
+	first := importHTML(t, exportHTML(t, sampleImportDocument()))
+	second := importHTML(t, exportHTML(t, first))
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("HTML round trip is not stable.\nFirst: %s\nSecond: %s", dumpElement(first), dumpElement(second))
+	}
+	assertSameStatistics(t, sampleImportDocument(), second)
+}
+
+// TestImportedDocumentSpellCheck tests running visitors over an imported document
+func TestImportedDocumentSpellCheck(t *testing.T) {
+	doc := importMarkdown(t, "# Notes\n\nthe cat sat with the dgo\n")
+
+	visitor := NewSpellCheckVisitor()
+	if err := doc.Accept(visitor); err != nil {
+		t.Fatalf("SpellCheckVisitor returned error: %v", err)
+	}
+	var found string
+	for _, spellingError := range visitor.GetErrors() {
+		found += spellingError.Word + "\n"
+	}
+	if !strings.Contains(found, "dgo") || !strings.Contains(found, "Notes") {
+		t.Errorf("Expected errors for 'dgo' and 'Notes', got: %v", visitor.GetErrors())
+	}
+}
+
+func exportMarkdown(t *testing.T, doc Element) string {
+	t.Helper()
+	visitor := NewMarkdownExportVisitor()
+	if err := doc.Accept(visitor); err != nil {
+		t.Fatalf("MarkdownExportVisitor returned error: %v", err)
+	}
+	return visitor.GetMarkdown()
+}
+
+func exportHTML(t *testing.T, doc Element) string {
+	t.Helper()
+	visitor := NewHTMLExportVisitor()
+	if err := doc.Accept(visitor); err != nil {
+		t.Fatalf("HTMLExportVisitor returned error: %v", err)
+	}
+	return visitor.GetHTML()
+}
+
+func importMarkdown(t *testing.T, input string) *CompositeElement {
+	t.Helper()
+	doc, err := ParseMarkdown(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseMarkdown returned error: %v\nInput:\n%s", err, input)
+	}
+	return doc
+}
+
+func importHTML(t *testing.T, input string) *CompositeElement {
+	t.Helper()
+	doc, err := ParseHTML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v\nInput:\n%s", err, input)
+	}
+	return doc
+}
+
+// assertSameStatistics checks that two documents have the same element and word counts
+func assertSameStatistics(t *testing.T, expected, actual Element) {
+	t.Helper()
+	want, got := NewStatisticsVisitor(), NewStatisticsVisitor()
+	if err := expected.Accept(want); err != nil {
+		t.Fatalf("StatisticsVisitor returned error: %v", err)
+	}
+	if err := actual.Accept(got); err != nil {
+		t.Fatalf("StatisticsVisitor returned error: %v", err)
+	}
+	if *want != *got {
+		t.Errorf("Statistics differ after round trip.\nExpected: %+v\nGot: %+v", *want, *got)
+	}
+}
+
+// dumpElement renders an element tree for test failure messages
+func dumpElement(e Element) string {
+	visitor := NewPlainTextExportVisitor()
+	var sb strings.Builder
+	var dump func(e Element, depth int)
+	dump = func(e Element, depth int) {
+		sb.WriteString("\n" + strings.Repeat("  ", depth))
+		if c, ok := e.(*CompositeElement); ok {
+			sb.WriteString("[" + c.Name + "]")
+			for _, child := range c.Children {
+				dump(child, depth+1)
+			}
+			return
+		}
+		visitor.Output.Reset()
+		e.Accept(visitor)
+		sb.WriteString(strings.TrimSpace(visitor.GetText()))
+	}
+	dump(e, 0)
+	return sb.String()
+}
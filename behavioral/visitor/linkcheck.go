@@ -0,0 +1,318 @@
+package visitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LinkResult is the outcome of checking one URL
+type LinkResult struct {
+	URL        string
+	Locations  []string // Element paths where the URL is used
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+	Skipped    bool // The URL was not checked, e.g. a mailto: link or a fragment
+}
+
+// Broken reports whether the URL could not be reached or returned an error status
+func (r LinkResult) Broken() bool {
+	if r.Skipped {
+		return false
+	}
+	return r.Err != nil || r.StatusCode >= 400
+}
+
+// String formats the result as a single report line
+func (r LinkResult) String() string {
+	where := strings.Join(r.Locations, ", ")
+	switch {
+	case r.Skipped:
+		return fmt.Sprintf("SKIP %s (%s)", r.URL, where)
+	case r.Err != nil:
+		return fmt.Sprintf("FAIL %s: %v (%s)", r.URL, r.Err, where)
+	case r.StatusCode >= 400:
+		return fmt.Sprintf("FAIL %s: %d %s (%s)", r.URL, r.StatusCode, http.StatusText(r.StatusCode), where)
+	default:
+		return fmt.Sprintf("OK   %s: %d (%s)", r.URL, r.StatusCode, where)
+	}
+}
+
+// LinkReport holds the results of a link check, in the order the URLs first
+// appear in the document
+type LinkReport struct {
+	Results []LinkResult
+}
+
+// Broken returns the results for URLs that failed
+func (r *LinkReport) Broken() []LinkResult {
+	var broken []LinkResult
+	for _, result := range r.Results {
+		if result.Broken() {
+			broken = append(broken, result)
+		}
+	}
+	return broken
+}
+
+// String formats the report with one line per URL
+func (r *LinkReport) String() string {
+	var sb strings.Builder
+	for _, result := range r.Results {
+		sb.WriteString(result.String())
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("%d links checked, %d broken\n", len(r.Results), len(r.Broken())))
+	return sb.String()
+}
+
+// LinkCheckVisitor collects the URLs of links and images in a document and
+// checks that they can be reached.
+//
+// Visiting only collects URLs, each one once no matter how often it is used.
+// Check then sends the requests from a bounded pool of workers, so a
+// document with hundreds of links does not open hundreds of connections.
+type LinkCheckVisitor struct {
+	Workers int           // Number of concurrent requests, defaults to 4
+	Timeout time.Duration // Timeout per request, defaults to 10 seconds
+	Client  *http.Client  // Defaults to http.DefaultClient
+	BaseURL string        // Relative URLs are resolved against this, or skipped if empty
+
+	urls      []string
+	locations map[string][]string
+	path      ElementPath
+}
+
+// NewLinkCheckVisitor creates a new LinkCheckVisitor
+func NewLinkCheckVisitor() *LinkCheckVisitor {
+	return &LinkCheckVisitor{
+		Workers:   4,
+		Timeout:   10 * time.Second,
+		locations: make(map[string][]string),
+	}
+}
+
+// URLs returns the collected URLs in the order they first appear
+func (v *LinkCheckVisitor) URLs() []string {
+	return append([]string(nil), v.urls...)
+}
+
+// collect records that rawURL is used at path
+func (v *LinkCheckVisitor) collect(rawURL, path string) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return
+	}
+	if _, seen := v.locations[rawURL]; !seen {
+		v.urls = append(v.urls, rawURL)
+	}
+	v.locations[rawURL] = append(v.locations[rawURL], path)
+}
+
+// Check requests every collected URL and returns the results. Cancelling ctx
+// stops the check; URLs that were not checked yet are reported with the
+// context's error.
+func (v *LinkCheckVisitor) Check(ctx context.Context) *LinkReport {
+	workers := v.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	results := make([]LinkResult, len(v.urls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = v.checkURL(ctx, v.urls[i])
+			}
+		}()
+	}
+
+	for i := range v.urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := range results {
+		results[i].Locations = v.locations[v.urls[i]]
+	}
+	return &LinkReport{Results: results}
+}
+
+// checkURL sends a HEAD request for rawURL, falling back to GET for servers
+// that do not support HEAD
+func (v *LinkCheckVisitor) checkURL(ctx context.Context, rawURL string) LinkResult {
+	result := LinkResult{URL: rawURL}
+
+	target, ok, err := v.resolve(rawURL)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if !ok {
+		result.Skipped = true
+		return result
+	}
+
+	start := time.Now()
+	status, err := v.request(ctx, http.MethodHead, target)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = v.request(ctx, http.MethodGet, target)
+	}
+	result.Duration = time.Since(start)
+	result.StatusCode = status
+	result.Err = err
+	return result
+}
+
+// resolve returns the absolute URL to request, or false if the URL should
+// not be checked
+func (v *LinkCheckVisitor) resolve(rawURL string) (string, bool, error) {
+	if strings.HasPrefix(rawURL, "#") {
+		return "", false, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid URL: %w", err)
+	}
+	if !u.IsAbs() {
+		if v.BaseURL == "" {
+			return "", false, nil
+		}
+		base, err := url.Parse(v.BaseURL)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid base URL: %w", err)
+		}
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false, nil
+	}
+	u.Fragment = ""
+	return u.String(), true, nil
+}
+
+// request sends a single request and returns its status code
+func (v *LinkCheckVisitor) request(ctx context.Context, method, target string) (int, error) {
+	timeout := v.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// VisitText implements the Visitor interface for TextElement
+func (v *LinkCheckVisitor) VisitText(text *TextElement) error {
+	if text == nil {
+		return fmt.Errorf("nil text element")
+	}
+	v.path.Next("text")
+	return nil
+}
+
+// VisitImage implements the Visitor interface for ImageElement
+func (v *LinkCheckVisitor) VisitImage(image *ImageElement) error {
+	if image == nil {
+		return fmt.Errorf("nil image element")
+	}
+	v.collect(image.Source, v.path.Next("image"))
+	return nil
+}
+
+// VisitTable implements the Visitor interface for TableElement
+func (v *LinkCheckVisitor) VisitTable(table *TableElement) error {
+	if table == nil {
+		return fmt.Errorf("nil table element")
+	}
+	v.path.Next("table")
+	return nil
+}
+
+// VisitLink implements the Visitor interface for LinkElement
+func (v *LinkCheckVisitor) VisitLink(link *LinkElement) error {
+	if link == nil {
+		return fmt.Errorf("nil link element")
+	}
+	v.collect(link.URL, v.path.Next("link"))
+	return nil
+}
+
+// VisitHeading implements the Visitor interface for HeadingElement
+func (v *LinkCheckVisitor) VisitHeading(heading *HeadingElement) error {
+	if heading == nil {
+		return fmt.Errorf("nil heading element")
+	}
+	v.path.Next("heading")
+	return nil
+}
+
+// VisitList implements the Visitor interface for ListElement
+func (v *LinkCheckVisitor) VisitList(list *ListElement) error {
+	if list == nil {
+		return fmt.Errorf("nil list element")
+	}
+	v.path.Next("list")
+	return nil
+}
+
+// VisitCodeBlock implements the Visitor interface for CodeBlockElement
+func (v *LinkCheckVisitor) VisitCodeBlock(code *CodeBlockElement) error {
+	if code == nil {
+		return fmt.Errorf("nil code block element")
+	}
+	v.path.Next("code")
+	return nil
+}
+
+// VisitQuote implements the Visitor interface for QuoteElement
+func (v *LinkCheckVisitor) VisitQuote(quote *QuoteElement) error {
+	if quote == nil {
+		return fmt.Errorf("nil quote element")
+	}
+	path := v.path.Next("quote")
+	if strings.Contains(quote.Cite, "://") {
+		v.collect(quote.Cite, path)
+	}
+	return nil
+}
+
+// VisitComposite implements the Visitor interface for CompositeElement
+func (v *LinkCheckVisitor) VisitComposite(composite *CompositeElement) error {
+	if composite == nil {
+		return fmt.Errorf("nil composite element")
+	}
+	v.path.Enter(composite)
+	return nil
+}
+
+// LeaveComposite implements the CompositeLeaver interface
+func (v *LinkCheckVisitor) LeaveComposite(composite *CompositeElement) error {
+	v.path.Leave()
+	return nil
+}
@@ -0,0 +1,140 @@
+package visitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLinkCheckVisitor tests status handling, skipping and locations
+func TestLinkCheckVisitor(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	doc := &CompositeElement{
+		Name: "Doc",
+		Children: []Element{
+			&LinkElement{URL: server.URL + "/ok", Text: "ok"},
+			&LinkElement{URL: server.URL + "/missing", Text: "missing"},
+			&CompositeElement{
+				Name: "Media",
+				Children: []Element{
+					&ImageElement{Source: "/ok?size=small"},
+					&ImageElement{Source: server.URL + "/missing"},
+				},
+			},
+			&LinkElement{URL: server.URL + "/slow", Text: "slow"},
+			&LinkElement{URL: server.URL + "/get-only", Text: "head"},
+			&LinkElement{URL: "mailto:someone@example.com", Text: "mail"},
+			&LinkElement{URL: "#section", Text: "anchor"},
+		},
+	}
+
+	linkVisitor := NewLinkCheckVisitor()
+	linkVisitor.Timeout = 200 * time.Millisecond
+	linkVisitor.BaseURL = server.URL
+	if err := doc.Accept(linkVisitor); err != nil {
+		t.Fatalf("LinkCheckVisitor returned error: %v", err)
+	}
+	if len(linkVisitor.URLs()) != 7 {
+		t.Fatalf("Expected 7 distinct URLs, got %v", linkVisitor.URLs())
+	}
+
+	report := linkVisitor.Check(context.Background())
+	byURL := map[string]LinkResult{}
+	for _, result := range report.Results {
+		byURL[strings.TrimPrefix(result.URL, server.URL)] = result
+	}
+
+	if r := byURL["/ok"]; r.Broken() || r.StatusCode != http.StatusOK {
+		t.Errorf("Expected /ok to pass, got %v", r)
+	}
+	missing := byURL["/missing"]
+	if !missing.Broken() || missing.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected /missing to be broken, got %v", missing)
+	}
+	if strings.Join(missing.Locations, ",") != "Doc/link[1],Doc/Media[2]/image[1]" {
+		t.Errorf("Unexpected locations for /missing: %v", missing.Locations)
+	}
+	if r := byURL["/slow"]; !r.Broken() || r.Err == nil {
+		t.Errorf("Expected /slow to time out, got %v", r)
+	}
+	if r := byURL["/get-only"]; r.Broken() {
+		t.Errorf("Expected /get-only to pass after falling back to GET, got %v", r)
+	}
+	if r := byURL["mailto:someone@example.com"]; !r.Skipped {
+		t.Errorf("Expected mailto link to be skipped, got %v", r)
+	}
+	if r := byURL["#section"]; !r.Skipped {
+		t.Errorf("Expected fragment link to be skipped, got %v", r)
+	}
+	if r := byURL["/ok?size=small"]; r.Skipped || r.StatusCode != http.StatusOK {
+		t.Errorf("Expected relative image to be resolved against BaseURL, got %v", r)
+	}
+
+	if len(report.Broken()) != 2 {
+		t.Errorf("Expected 2 broken links, got %v", report.Broken())
+	}
+	if !strings.HasSuffix(report.String(), "7 links checked, 2 broken\n") {
+		t.Errorf("Unexpected report summary:\n%s", report)
+	}
+}
+
+// TestLinkCheckVisitorBoundsConcurrency tests that no more than Workers
+// requests are in flight at once
+func TestLinkCheckVisitorBoundsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	doc := &CompositeElement{Name: "Doc"}
+	for i := 0; i < 30; i++ {
+		doc.Children = append(doc.Children, &LinkElement{URL: server.URL + "/page/" + string(rune('a'+i))})
+	}
+
+	linkVisitor := NewLinkCheckVisitor()
+	linkVisitor.Workers = 3
+	doc.Accept(linkVisitor)
+	report := linkVisitor.Check(context.Background())
+
+	if len(report.Results) != 30 || len(report.Broken()) != 0 {
+		t.Fatalf("Expected 30 working links, got:\n%s", report)
+	}
+	if maxInFlight > 3 {
+		t.Errorf("Expected at most 3 concurrent requests, got %d", maxInFlight)
+	}
+}
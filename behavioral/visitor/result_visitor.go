@@ -0,0 +1,205 @@
+package visitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResultVisitor is the generic counterpart of Visitor. Instead of collecting
+// output in its own fields, each visit method returns a value of type R.
+// Composites receive the results of their children, so results are built
+// bottom-up like a fold over the tree.
+type ResultVisitor[R any] interface {
+	VisitText(text *TextElement) (R, error)
+	VisitImage(image *ImageElement) (R, error)
+	VisitTable(table *TableElement) (R, error)
+	VisitLink(link *LinkElement) (R, error)
+	VisitHeading(heading *HeadingElement) (R, error)
+	VisitList(list *ListElement) (R, error)
+	VisitCodeBlock(code *CodeBlockElement) (R, error)
+	VisitQuote(quote *QuoteElement) (R, error)
+	VisitComposite(composite *CompositeElement, children []R) (R, error)
+}
+
+// Accept runs a ResultVisitor over element and returns its result.
+//
+// Go methods cannot have type parameters, so Element.Accept cannot return
+// an R directly. Accept bridges the two forms by wrapping the ResultVisitor
+// in a Visitor that records each result, which keeps the double dispatch in
+// the elements' own Accept methods.
+func Accept[R any](element Element, visitor ResultVisitor[R]) (R, error) {
+	var zero R
+	if element == nil {
+		return zero, fmt.Errorf("nil element")
+	}
+
+	adapter := &resultAdapter[R]{visitor: visitor, frames: [][]R{nil}}
+	if err := element.Accept(adapter); err != nil {
+		return zero, err
+	}
+	if len(adapter.frames) != 1 || len(adapter.frames[0]) != 1 {
+		return zero, fmt.Errorf("element produced %d results, expected 1", len(adapter.frames[0]))
+	}
+	return adapter.frames[0][0], nil
+}
+
+// resultAdapter implements Visitor and CompositeLeaver on top of a
+// ResultVisitor. frames holds one slice of results per open composite.
+type resultAdapter[R any] struct {
+	visitor ResultVisitor[R]
+	frames  [][]R
+}
+
+// push appends a result to the innermost open composite
+func (a *resultAdapter[R]) push(result R, err error) error {
+	if err != nil {
+		return err
+	}
+	top := len(a.frames) - 1
+	a.frames[top] = append(a.frames[top], result)
+	return nil
+}
+
+// VisitText implements the Visitor interface for TextElement
+func (a *resultAdapter[R]) VisitText(text *TextElement) error {
+	return a.push(a.visitor.VisitText(text))
+}
+
+// VisitImage implements the Visitor interface for ImageElement
+func (a *resultAdapter[R]) VisitImage(image *ImageElement) error {
+	return a.push(a.visitor.VisitImage(image))
+}
+
+// VisitTable implements the Visitor interface for TableElement
+func (a *resultAdapter[R]) VisitTable(table *TableElement) error {
+	return a.push(a.visitor.VisitTable(table))
+}
+
+// VisitLink implements the Visitor interface for LinkElement
+func (a *resultAdapter[R]) VisitLink(link *LinkElement) error {
+	return a.push(a.visitor.VisitLink(link))
+}
+
+// VisitHeading implements the Visitor interface for HeadingElement
+func (a *resultAdapter[R]) VisitHeading(heading *HeadingElement) error {
+	return a.push(a.visitor.VisitHeading(heading))
+}
+
+// VisitList implements the Visitor interface for ListElement
+func (a *resultAdapter[R]) VisitList(list *ListElement) error {
+	return a.push(a.visitor.VisitList(list))
+}
+
+// VisitCodeBlock implements the Visitor interface for CodeBlockElement
+func (a *resultAdapter[R]) VisitCodeBlock(code *CodeBlockElement) error {
+	return a.push(a.visitor.VisitCodeBlock(code))
+}
+
+// VisitQuote implements the Visitor interface for QuoteElement
+func (a *resultAdapter[R]) VisitQuote(quote *QuoteElement) error {
+	return a.push(a.visitor.VisitQuote(quote))
+}
+
+// VisitComposite opens a new frame for the composite's children
+func (a *resultAdapter[R]) VisitComposite(composite *CompositeElement) error {
+	if composite == nil {
+		return fmt.Errorf("nil composite element")
+	}
+	a.frames = append(a.frames, nil)
+	return nil
+}
+
+// LeaveComposite closes the composite's frame and hands the children's
+// results to the ResultVisitor
+func (a *resultAdapter[R]) LeaveComposite(composite *CompositeElement) error {
+	top := len(a.frames) - 1
+	children := a.frames[top]
+	a.frames = a.frames[:top]
+	return a.push(a.visitor.VisitComposite(composite, children))
+}
+
+// PlainTextRenderer is a ResultVisitor that renders each element as plain
+// text, using the same format as PlainTextExportVisitor
+type PlainTextRenderer struct{}
+
+// render runs a PlainTextExportVisitor over a single element
+func (PlainTextRenderer) render(element Element) (string, error) {
+	v := NewPlainTextExportVisitor()
+	if err := element.Accept(v); err != nil {
+		return "", err
+	}
+	return v.GetText(), nil
+}
+
+// VisitText implements the ResultVisitor interface for TextElement
+func (r PlainTextRenderer) VisitText(text *TextElement) (string, error) {
+	if text == nil {
+		return "", fmt.Errorf("nil text element")
+	}
+	return r.render(text)
+}
+
+// VisitImage implements the ResultVisitor interface for ImageElement
+func (r PlainTextRenderer) VisitImage(image *ImageElement) (string, error) {
+	if image == nil {
+		return "", fmt.Errorf("nil image element")
+	}
+	return r.render(image)
+}
+
+// VisitTable implements the ResultVisitor interface for TableElement
+func (r PlainTextRenderer) VisitTable(table *TableElement) (string, error) {
+	if table == nil {
+		return "", fmt.Errorf("nil table element")
+	}
+	return r.render(table)
+}
+
+// VisitLink implements the ResultVisitor interface for LinkElement
+func (r PlainTextRenderer) VisitLink(link *LinkElement) (string, error) {
+	if link == nil {
+		return "", fmt.Errorf("nil link element")
+	}
+	return r.render(link)
+}
+
+// VisitHeading implements the ResultVisitor interface for HeadingElement
+func (r PlainTextRenderer) VisitHeading(heading *HeadingElement) (string, error) {
+	if heading == nil {
+		return "", fmt.Errorf("nil heading element")
+	}
+	return r.render(heading)
+}
+
+// VisitList implements the ResultVisitor interface for ListElement
+func (r PlainTextRenderer) VisitList(list *ListElement) (string, error) {
+	if list == nil {
+		return "", fmt.Errorf("nil list element")
+	}
+	return r.render(list)
+}
+
+// VisitCodeBlock implements the ResultVisitor interface for CodeBlockElement
+func (r PlainTextRenderer) VisitCodeBlock(code *CodeBlockElement) (string, error) {
+	if code == nil {
+		return "", fmt.Errorf("nil code block element")
+	}
+	return r.render(code)
+}
+
+// VisitQuote implements the ResultVisitor interface for QuoteElement
+func (r PlainTextRenderer) VisitQuote(quote *QuoteElement) (string, error) {
+	if quote == nil {
+		return "", fmt.Errorf("nil quote element")
+	}
+	return r.render(quote)
+}
+
+// VisitComposite implements the ResultVisitor interface for CompositeElement
+// by concatenating the text of its children
+func (PlainTextRenderer) VisitComposite(composite *CompositeElement, children []string) (string, error) {
+	if composite == nil {
+		return "", fmt.Errorf("nil composite element")
+	}
+	return strings.Join(children, ""), nil
+}
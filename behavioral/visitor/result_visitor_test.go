@@ -0,0 +1,111 @@
+package visitor
+
+import (
+	"errors"
+	"testing"
+)
+
+// depthVisitor returns the depth of the deepest leaf below each element
+type depthVisitor struct{}
+
+func (depthVisitor) VisitText(*TextElement) (int, error)           { return 0, nil }
+func (depthVisitor) VisitImage(*ImageElement) (int, error)         { return 0, nil }
+func (depthVisitor) VisitTable(*TableElement) (int, error)         { return 0, nil }
+func (depthVisitor) VisitLink(*LinkElement) (int, error)           { return 0, nil }
+func (depthVisitor) VisitHeading(*HeadingElement) (int, error)     { return 0, nil }
+func (depthVisitor) VisitList(*ListElement) (int, error)           { return 0, nil }
+func (depthVisitor) VisitCodeBlock(*CodeBlockElement) (int, error) { return 0, nil }
+func (depthVisitor) VisitQuote(*QuoteElement) (int, error)         { return 0, nil }
+
+func (depthVisitor) VisitComposite(_ *CompositeElement, children []int) (int, error) {
+	deepest := 0
+	for _, child := range children {
+		if child > deepest {
+			deepest = child
+		}
+	}
+	return deepest + 1, nil
+}
+
+// failingVisitor fails on code blocks
+type failingVisitor struct{ depthVisitor }
+
+var errCodeBlock = errors.New("code blocks are not allowed")
+
+func (failingVisitor) VisitCodeBlock(*CodeBlockElement) (int, error) { return 0, errCodeBlock }
+
+// TestAcceptFoldsChildren tests that composites receive their children's results
+func TestAcceptFoldsChildren(t *testing.T) {
+	doc := &CompositeElement{
+		Name: "Root",
+		Children: []Element{
+			&TextElement{Content: "a"},
+			&CompositeElement{
+				Name:     "Nested",
+				Children: []Element{&CompositeElement{Name: "Empty"}},
+			},
+			&CodeBlockElement{Code: "x := 1"},
+		},
+	}
+
+	depth, err := Accept[int](doc, depthVisitor{})
+	if err != nil {
+		t.Fatalf("Accept returned error: %v", err)
+	}
+	if depth != 3 {
+		t.Errorf("Expected depth 3, got %d", depth)
+	}
+
+	leaf, err := Accept[int](&TextElement{Content: "leaf"}, depthVisitor{})
+	if err != nil || leaf != 0 {
+		t.Errorf("Expected depth 0 for a leaf, got %d (%v)", leaf, err)
+	}
+
+	if _, err := Accept[int](doc, failingVisitor{}); !errors.Is(err, errCodeBlock) {
+		t.Errorf("Expected the visitor's error to be returned, got %v", err)
+	}
+	if _, err := Accept[int](nil, depthVisitor{}); err == nil {
+		t.Error("Expected an error for a nil element")
+	}
+}
+
+// TestStatisticsCounter tests that the result form matches StatisticsVisitor
+func TestStatisticsCounter(t *testing.T) {
+	doc := sampleImportDocument()
+	doc.AddChild(&HeadingElement{Level: 2, Text: "Appendix notes"})
+
+	stats, err := Accept[Statistics](doc, StatisticsCounter{})
+	if err != nil {
+		t.Fatalf("Accept returned error: %v", err)
+	}
+
+	visitor := NewStatisticsVisitor()
+	if err := doc.Accept(visitor); err != nil {
+		t.Fatalf("StatisticsVisitor returned error: %v", err)
+	}
+	if stats != visitor.Statistics {
+		t.Errorf("StatisticsCounter and StatisticsVisitor disagree.\nCounter: %+v\nVisitor: %+v", stats, visitor.Statistics)
+	}
+
+	if stats.HeadingCount != 1 || stats.ListCount != 2 || stats.CodeBlockCount != 1 || stats.QuoteCount != 1 {
+		t.Errorf("Unexpected counts for the new element types: %+v", stats)
+	}
+}
+
+// TestPlainTextRenderer tests that the result form matches PlainTextExportVisitor
+func TestPlainTextRenderer(t *testing.T) {
+	doc := sampleImportDocument()
+
+	text, err := Accept[string](doc, PlainTextRenderer{})
+	if err != nil {
+		t.Fatalf("Accept returned error: %v", err)
+	}
+
+	visitor := NewPlainTextExportVisitor()
+	if err := doc.Accept(visitor); err != nil {
+		t.Fatalf("PlainTextExportVisitor returned error: %v", err)
+	}
+	if text != visitor.GetText() {
+		t.Errorf("PlainTextRenderer output differs.\nExpected: %q\nGot: %q", visitor.GetText(), text)
+	}
+}
@@ -0,0 +1,257 @@
+package visitor
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// TOCEntry is a single entry of a table of contents
+type TOCEntry struct {
+	Title    string
+	Anchor   string
+	Level    int
+	Children []*TOCEntry
+}
+
+// TableOfContents is a nested list of the sections and headings of a
+// document, with a unique anchor for each of them
+type TableOfContents struct {
+	Entries []*TOCEntry
+	anchors map[Element]string
+}
+
+// Anchor returns the anchor generated for a composite or heading, or an
+// empty string if the element is not part of the table of contents
+func (t *TableOfContents) Anchor(element Element) string {
+	if t == nil {
+		return ""
+	}
+	return t.anchors[element]
+}
+
+// HTML renders the table of contents as nested lists inside a nav element
+func (t *TableOfContents) HTML(indent string) string {
+	var sb strings.Builder
+	sb.WriteString(indent + "<nav class=\"toc\">\n")
+	writeTOCHTML(&sb, t.Entries, indent+"  ")
+	sb.WriteString(indent + "</nav>\n")
+	return sb.String()
+}
+
+// writeTOCHTML writes one level of entries as an unordered list
+func writeTOCHTML(sb *strings.Builder, entries []*TOCEntry, indent string) {
+	if len(entries) == 0 {
+		return
+	}
+	sb.WriteString(indent + "<ul>\n")
+	for _, entry := range entries {
+		sb.WriteString(fmt.Sprintf("%s  <li><a href=\"#%s\">%s</a>", indent,
+			html.EscapeString(entry.Anchor), html.EscapeString(entry.Title)))
+		if len(entry.Children) > 0 {
+			sb.WriteString("\n")
+			writeTOCHTML(sb, entry.Children, indent+"    ")
+			sb.WriteString(indent + "  ")
+		}
+		sb.WriteString("</li>\n")
+	}
+	sb.WriteString(indent + "</ul>\n")
+}
+
+// Markdown renders the table of contents as a nested bulleted list of links
+func (t *TableOfContents) Markdown() string {
+	var sb strings.Builder
+	var write func(entries []*TOCEntry, depth int)
+	write = func(entries []*TOCEntry, depth int) {
+		for _, entry := range entries {
+			sb.WriteString(fmt.Sprintf("%s- [%s](#%s)\n", strings.Repeat("  ", depth), entry.Title, entry.Anchor))
+			write(entry.Children, depth+1)
+		}
+	}
+	write(t.Entries, 0)
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// TOCVisitor builds a table of contents from the composites and headings of
+// a document. The root composite is the document itself and is left out.
+//
+// Composites are nested by their depth in the tree. Headings are nested
+// below the composite that contains them, by their own level, so an h1
+// inside a section becomes a child of that section.
+type TOCVisitor struct {
+	MaxLevel int // Entries deeper than this are left out, 0 means no limit
+
+	toc   *TableOfContents
+	open  []*TOCEntry // Entries that can still receive children, by level
+	depth int         // Depth of the current composite, the root is 0
+	slugs map[string]int
+}
+
+// NewTOCVisitor creates a new TOCVisitor
+func NewTOCVisitor() *TOCVisitor {
+	return &TOCVisitor{
+		toc:   &TableOfContents{anchors: make(map[Element]string)},
+		depth: -1,
+		slugs: make(map[string]int),
+	}
+}
+
+// GetTOC returns the table of contents built so far
+func (v *TOCVisitor) GetTOC() *TableOfContents {
+	return v.toc
+}
+
+// add places an entry for element at the given level
+func (v *TOCVisitor) add(element Element, title string, level int) {
+	if v.MaxLevel > 0 && level > v.MaxLevel {
+		return
+	}
+
+	entry := &TOCEntry{Title: title, Anchor: v.anchorFor(title), Level: level}
+	v.toc.anchors[element] = entry.Anchor
+
+	for len(v.open) > 0 && v.open[len(v.open)-1].Level >= level {
+		v.open = v.open[:len(v.open)-1]
+	}
+	if len(v.open) == 0 {
+		v.toc.Entries = append(v.toc.Entries, entry)
+	} else {
+		parent := v.open[len(v.open)-1]
+		parent.Children = append(parent.Children, entry)
+	}
+	v.open = append(v.open, entry)
+}
+
+// anchorFor returns a unique anchor for title, numbering repeated titles
+// the way GitHub does ("usage", "usage-1", ...)
+func (v *TOCVisitor) anchorFor(title string) string {
+	slug := Slugify(title)
+	if slug == "" {
+		slug = "section"
+	}
+	anchor := slug
+	for {
+		n, seen := v.slugs[anchor]
+		if !seen {
+			break
+		}
+		v.slugs[anchor] = n + 1
+		anchor = fmt.Sprintf("%s-%d", slug, n+1)
+	}
+	v.slugs[anchor] = 0
+	return anchor
+}
+
+// Slugify turns a title into an anchor: lower case letters and digits, with
+// spaces replaced by hyphens and other punctuation removed. This matches the
+// anchors GitHub generates for Markdown headings.
+func Slugify(title string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			sb.WriteRune(r)
+		case r == ' ':
+			sb.WriteRune('-')
+		}
+	}
+	return sb.String()
+}
+
+// VisitText implements the Visitor interface for TextElement
+func (v *TOCVisitor) VisitText(text *TextElement) error {
+	if text == nil {
+		return fmt.Errorf("nil text element")
+	}
+	return nil
+}
+
+// VisitImage implements the Visitor interface for ImageElement
+func (v *TOCVisitor) VisitImage(image *ImageElement) error {
+	if image == nil {
+		return fmt.Errorf("nil image element")
+	}
+	return nil
+}
+
+// VisitTable implements the Visitor interface for TableElement
+func (v *TOCVisitor) VisitTable(table *TableElement) error {
+	if table == nil {
+		return fmt.Errorf("nil table element")
+	}
+	return nil
+}
+
+// VisitLink implements the Visitor interface for LinkElement
+func (v *TOCVisitor) VisitLink(link *LinkElement) error {
+	if link == nil {
+		return fmt.Errorf("nil link element")
+	}
+	return nil
+}
+
+// VisitHeading implements the Visitor interface for HeadingElement
+func (v *TOCVisitor) VisitHeading(heading *HeadingElement) error {
+	if heading == nil {
+		return fmt.Errorf("nil heading element")
+	}
+
+	depth := v.depth
+	if depth < 0 {
+		depth = 0
+	}
+	v.add(heading, heading.Text, depth+clampHeadingLevel(heading.Level))
+	return nil
+}
+
+// VisitList implements the Visitor interface for ListElement
+func (v *TOCVisitor) VisitList(list *ListElement) error {
+	if list == nil {
+		return fmt.Errorf("nil list element")
+	}
+	return nil
+}
+
+// VisitCodeBlock implements the Visitor interface for CodeBlockElement
+func (v *TOCVisitor) VisitCodeBlock(code *CodeBlockElement) error {
+	if code == nil {
+		return fmt.Errorf("nil code block element")
+	}
+	return nil
+}
+
+// VisitQuote implements the Visitor interface for QuoteElement
+func (v *TOCVisitor) VisitQuote(quote *QuoteElement) error {
+	if quote == nil {
+		return fmt.Errorf("nil quote element")
+	}
+	return nil
+}
+
+// VisitComposite implements the Visitor interface for CompositeElement
+func (v *TOCVisitor) VisitComposite(composite *CompositeElement) error {
+	if composite == nil {
+		return fmt.Errorf("nil composite element")
+	}
+
+	v.depth++
+	if v.depth > 0 {
+		v.add(composite, composite.Name, v.depth)
+	}
+	return nil
+}
+
+// LeaveComposite implements the CompositeLeaver interface
+func (v *TOCVisitor) LeaveComposite(composite *CompositeElement) error {
+	v.depth--
+
+	// Headings inside the composite must not adopt entries that follow it
+	for len(v.open) > 0 && v.open[len(v.open)-1].Level > v.depth {
+		v.open = v.open[:len(v.open)-1]
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package visitor
+
+import (
+	"strings"
+	"testing"
+)
+
+// tocDocument returns a document with nested sections, headings and a
+// repeated section name
+func tocDocument() *CompositeElement {
+	return &CompositeElement{
+		Name: "Guide",
+		Children: []Element{
+			&HeadingElement{Level: 1, Text: "Overview"},
+			&TextElement{Content: "Intro text"},
+			&CompositeElement{
+				Name: "Getting Started",
+				Children: []Element{
+					&HeadingElement{Level: 1, Text: "Install"},
+					&HeadingElement{Level: 2, Text: "From source"},
+					&CompositeElement{Name: "Usage"},
+				},
+			},
+			&CompositeElement{Name: "Usage"},
+		},
+	}
+}
+
+// TestTOCVisitor tests nesting and anchor generation
+func TestTOCVisitor(t *testing.T) {
+	doc := tocDocument()
+	tocVisitor := NewTOCVisitor()
+	if err := doc.Accept(tocVisitor); err != nil {
+		t.Fatalf("TOCVisitor returned error: %v", err)
+	}
+
+	expected := `- [Overview](#overview)
+- [Getting Started](#getting-started)
+  - [Install](#install)
+    - [From source](#from-source)
+  - [Usage](#usage)
+- [Usage](#usage-1)
+
+`
+	toc := tocVisitor.GetTOC()
+	if got := toc.Markdown(); got != expected {
+		t.Errorf("Unexpected TOC.\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+
+	second := doc.Children[3]
+	if anchor := toc.Anchor(second); anchor != "usage-1" {
+		t.Errorf("Expected anchor usage-1 for the second Usage section, got %q", anchor)
+	}
+	if anchor := toc.Anchor(doc); anchor != "" {
+		t.Errorf("The root composite should not be in the TOC, got anchor %q", anchor)
+	}
+}
+
+// TestTOCVisitorMaxLevel tests that deep entries are left out
+func TestTOCVisitorMaxLevel(t *testing.T) {
+	tocVisitor := NewTOCVisitor()
+	tocVisitor.MaxLevel = 1
+	tocDocument().Accept(tocVisitor)
+
+	var titles []string
+	for _, entry := range tocVisitor.GetTOC().Entries {
+		titles = append(titles, entry.Title)
+		if len(entry.Children) != 0 {
+			t.Errorf("Expected no children below level 1, got %v", entry.Children)
+		}
+	}
+	if strings.Join(titles, ",") != "Overview,Getting Started,Usage" {
+		t.Errorf("Unexpected top-level entries: %v", titles)
+	}
+}
+
+// TestSlugify tests GitHub-style anchors
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Getting Started":    "getting-started",
+		"  C++ & Go!  ":      "c--go",
+		"snake_case-title":   "snake_case-title",
+		"Résumé 2":           "résumé-2",
+		"What's new in v1.2": "whats-new-in-v12",
+	}
+	for title, expected := range cases {
+		if got := Slugify(title); got != expected {
+			t.Errorf("Slugify(%q) = %q, expected %q", title, got, expected)
+		}
+	}
+}
+
+// TestExportWithTOC tests that the exporters insert the TOC and anchors
+func TestExportWithTOC(t *testing.T) {
+	doc := tocDocument()
+	tocVisitor := NewTOCVisitor()
+	doc.Accept(tocVisitor)
+
+	htmlVisitor := NewHTMLExportVisitor()
+	htmlVisitor.TOC = tocVisitor.GetTOC()
+	if err := doc.Accept(htmlVisitor); err != nil {
+		t.Fatalf("HTMLExportVisitor returned error: %v", err)
+	}
+	html := htmlVisitor.GetHTML()
+	for _, fragment := range []string{
+		"<div class=\"guide\">\n  <nav class=\"toc\">\n    <ul>\n",
+		"<li><a href=\"#from-source\">From source</a></li>",
+		"<h1 id=\"overview\">Overview</h1>",
+		"<div class=\"getting started\" id=\"getting-started\">",
+		"<div class=\"usage\" id=\"usage-1\">",
+	} {
+		if !strings.Contains(html, fragment) {
+			t.Errorf("Expected HTML to contain %q, got:\n%s", fragment, html)
+		}
+	}
+	if strings.Count(html, "<nav") != 1 {
+		t.Errorf("Expected the TOC once, got:\n%s", html)
+	}
+
+	markdownVisitor := NewMarkdownExportVisitor()
+	markdownVisitor.TOC = tocVisitor.GetTOC()
+	doc.Accept(markdownVisitor)
+	if !strings.HasPrefix(markdownVisitor.GetMarkdown(), "# Guide\n\n- [Overview](#overview)\n") {
+		t.Errorf("Expected the TOC below the title, got:\n%s", markdownVisitor.GetMarkdown())
+	}
+}
@@ -6,6 +6,7 @@ package visitor
 
 import (
 	"fmt"
+	"html"
 	"strings"
 	"unicode"
 )
@@ -62,6 +63,62 @@ func (l *LinkElement) Accept(visitor Visitor) error {
 	return visitor.VisitLink(l)
 }
 
+// HeadingElement represents a section heading in a document
+type HeadingElement struct {
+	Level int // 1 to 6, like HTML h1-h6
+	Text  string
+}
+
+// Accept implements the Element interface for HeadingElement
+func (h *HeadingElement) Accept(visitor Visitor) error {
+	return visitor.VisitHeading(h)
+}
+
+// clampHeadingLevel keeps a heading level within the 1-6 range supported by
+// HTML and Markdown
+func clampHeadingLevel(level int) int {
+	if level < 1 {
+		return 1
+	}
+	if level > 6 {
+		return 6
+	}
+	return level
+}
+
+// ListElement represents a bulleted or numbered list in a document
+type ListElement struct {
+	Ordered bool
+	Items   []string
+}
+
+// Accept implements the Element interface for ListElement
+func (l *ListElement) Accept(visitor Visitor) error {
+	return visitor.VisitList(l)
+}
+
+// CodeBlockElement represents preformatted source code in a document
+type CodeBlockElement struct {
+	Language string
+	Code     string
+}
+
+// Accept implements the Element interface for CodeBlockElement
+func (c *CodeBlockElement) Accept(visitor Visitor) error {
+	return visitor.VisitCodeBlock(c)
+}
+
+// QuoteElement represents a block quotation in a document
+type QuoteElement struct {
+	Content string
+	Cite    string // Optional source of the quotation
+}
+
+// Accept implements the Element interface for QuoteElement
+func (q *QuoteElement) Accept(visitor Visitor) error {
+	return visitor.VisitQuote(q)
+}
+
 // CompositeElement can contain multiple elements
 type CompositeElement struct {
 	Name     string
@@ -83,6 +140,11 @@ func (c *CompositeElement) Accept(visitor Visitor) error {
 		}
 	}
 	
+	// Finally let the visitor close the composite if it cares about that
+	if leaver, ok := visitor.(CompositeLeaver); ok {
+		return leaver.LeaveComposite(c)
+	}
+	
 	return nil
 }
 
@@ -97,13 +159,88 @@ type Visitor interface {
 	VisitImage(image *ImageElement) error
 	VisitTable(table *TableElement) error
 	VisitLink(link *LinkElement) error
+	VisitHeading(heading *HeadingElement) error
+	VisitList(list *ListElement) error
+	VisitCodeBlock(code *CodeBlockElement) error
+	VisitQuote(quote *QuoteElement) error
 	VisitComposite(composite *CompositeElement) error
 }
 
+// CompositeLeaver is an optional interface for visitors that need to know
+// when all children of a composite have been visited, e.g. to close a tag
+// or to decrease a nesting level
+type CompositeLeaver interface {
+	LeaveComposite(composite *CompositeElement) error
+}
+
+// ElementPath tracks the position of the element being visited, for
+// visitors that report where in a document they found something. Paths look
+// like "Document/Introduction[1]/text[0]": the root is named after the
+// composite, nested composites after their name and position so that
+// sections with the same name stay distinct, and other elements after their
+// kind and position.
+//
+// The zero value is ready to use. Visitors call Enter from VisitComposite,
+// Leave from LeaveComposite and Next from every other visit method.
+type ElementPath struct {
+	frames []elementPathFrame
+}
+
+// elementPathFrame is an open composite and the number of its children seen so far
+type elementPathFrame struct {
+	path     string
+	children int
+}
+
+// Next returns the path of the next child of the current composite
+func (p *ElementPath) Next(kind string) string {
+	if len(p.frames) == 0 {
+		p.frames = append(p.frames, elementPathFrame{})
+	}
+	parent := &p.frames[len(p.frames)-1]
+	index := parent.children
+	parent.children++
+	
+	segment := fmt.Sprintf("%s[%d]", kind, index)
+	if parent.path == "" {
+		return segment
+	}
+	return parent.path + "/" + segment
+}
+
+// Enter returns the path of composite and makes it the current composite
+func (p *ElementPath) Enter(composite *CompositeElement) string {
+	kind := composite.Name
+	if kind == "" {
+		kind = "composite"
+	}
+	
+	var path string
+	if len(p.frames) == 0 {
+		path = kind
+	} else {
+		path = p.Next(kind)
+	}
+	p.frames = append(p.frames, elementPathFrame{path: path})
+	return path
+}
+
+// Leave returns to the parent of the current composite
+func (p *ElementPath) Leave() {
+	if len(p.frames) > 0 {
+		p.frames = p.frames[:len(p.frames)-1]
+	}
+}
+
 // HTMLExportVisitor converts elements to HTML
 type HTMLExportVisitor struct {
 	Output      strings.Builder
 	indentLevel int
+
+	// TOC, when set, is written as a nav element at the top of the root
+	// composite, and sections and headings get matching id attributes
+	TOC        *TableOfContents
+	tocWritten bool
 }
 
 // NewHTMLExportVisitor creates a new HTMLExportVisitor
@@ -124,7 +261,7 @@ func (v *HTMLExportVisitor) VisitText(text *TextElement) error {
 	
 	v.Output.WriteString(v.indent())
 	v.Output.WriteString("<p>")
-	v.Output.WriteString(text.Content)
+	v.Output.WriteString(html.EscapeString(text.Content))
 	v.Output.WriteString("</p>\n")
 	return nil
 }
@@ -137,7 +274,7 @@ func (v *HTMLExportVisitor) VisitImage(image *ImageElement) error {
 	
 	v.Output.WriteString(v.indent())
 	v.Output.WriteString(fmt.Sprintf("<img src=\"%s\" alt=\"%s\" width=\"%d\" height=\"%d\">\n", 
-		image.Source, image.Alt, image.Width, image.Height))
+		html.EscapeString(image.Source), html.EscapeString(image.Alt), image.Width, image.Height))
 	return nil
 }
 
@@ -162,7 +299,7 @@ func (v *HTMLExportVisitor) VisitTable(table *TableElement) error {
 			if i < len(table.Data) && j < len(table.Data[i]) {
 				cellData = table.Data[i][j]
 			}
-			v.Output.WriteString(fmt.Sprintf("<td>%s</td>\n", cellData))
+			v.Output.WriteString(fmt.Sprintf("<td>%s</td>\n", html.EscapeString(cellData)))
 		}
 		
 		v.indentLevel--
@@ -185,11 +322,81 @@ func (v *HTMLExportVisitor) VisitLink(link *LinkElement) error {
 	v.Output.WriteString(v.indent())
 	if link.Title != "" {
 		v.Output.WriteString(fmt.Sprintf("<a href=\"%s\" title=\"%s\">%s</a>\n", 
-			link.URL, link.Title, link.Text))
+			html.EscapeString(link.URL), html.EscapeString(link.Title), html.EscapeString(link.Text)))
 	} else {
 		v.Output.WriteString(fmt.Sprintf("<a href=\"%s\">%s</a>\n", 
-			link.URL, link.Text))
+			html.EscapeString(link.URL), html.EscapeString(link.Text)))
+	}
+	return nil
+}
+
+// VisitHeading implements the Visitor interface for HeadingElement
+func (v *HTMLExportVisitor) VisitHeading(heading *HeadingElement) error {
+	if heading == nil {
+		return fmt.Errorf("nil heading element")
+	}
+	
+	level := clampHeadingLevel(heading.Level)
+	v.Output.WriteString(v.indent())
+	v.Output.WriteString(fmt.Sprintf("<h%d%s>%s</h%d>\n", level, v.idAttr(heading), html.EscapeString(heading.Text), level))
+	return nil
+}
+
+// VisitList implements the Visitor interface for ListElement
+func (v *HTMLExportVisitor) VisitList(list *ListElement) error {
+	if list == nil {
+		return fmt.Errorf("nil list element")
+	}
+	
+	tag := "ul"
+	if list.Ordered {
+		tag = "ol"
+	}
+	v.Output.WriteString(v.indent())
+	v.Output.WriteString("<" + tag + ">\n")
+	v.indentLevel++
+	for _, item := range list.Items {
+		v.Output.WriteString(v.indent())
+		v.Output.WriteString(fmt.Sprintf("<li>%s</li>\n", html.EscapeString(item)))
+	}
+	v.indentLevel--
+	v.Output.WriteString(v.indent())
+	v.Output.WriteString("</" + tag + ">\n")
+	return nil
+}
+
+// VisitCodeBlock implements the Visitor interface for CodeBlockElement
+func (v *HTMLExportVisitor) VisitCodeBlock(code *CodeBlockElement) error {
+	if code == nil {
+		return fmt.Errorf("nil code block element")
+	}
+	
+	// The code itself is not indented, whitespace inside <pre> is significant
+	v.Output.WriteString(v.indent())
+	if code.Language != "" {
+		v.Output.WriteString(fmt.Sprintf("<pre><code class=\"language-%s\">", html.EscapeString(code.Language)))
+	} else {
+		v.Output.WriteString("<pre><code>")
+	}
+	v.Output.WriteString(html.EscapeString(code.Code))
+	v.Output.WriteString("</code></pre>\n")
+	return nil
+}
+
+// VisitQuote implements the Visitor interface for QuoteElement
+func (v *HTMLExportVisitor) VisitQuote(quote *QuoteElement) error {
+	if quote == nil {
+		return fmt.Errorf("nil quote element")
+	}
+	
+	v.Output.WriteString(v.indent())
+	if quote.Cite != "" {
+		v.Output.WriteString(fmt.Sprintf("<blockquote cite=\"%s\">", html.EscapeString(quote.Cite)))
+	} else {
+		v.Output.WriteString("<blockquote>")
 	}
+	v.Output.WriteString(html.EscapeString(quote.Content))
+	v.Output.WriteString("</blockquote>\n")
 	return nil
 }
 
@@ -200,14 +407,41 @@ func (v *HTMLExportVisitor) VisitComposite(composite *CompositeElement) error {
 	}
 	
 	v.Output.WriteString(v.indent())
-	v.Output.WriteString(fmt.Sprintf("<div class=\"%s\">\n", strings.ToLower(composite.Name)))
+	v.Output.WriteString(fmt.Sprintf("<div class=\"%s\"%s>\n", html.EscapeString(strings.ToLower(composite.Name)), v.idAttr(composite)))
 	v.indentLevel++
+
+	if v.TOC != nil && !v.tocWritten {
+		v.tocWritten = true
+		v.Output.WriteString(v.TOC.HTML(v.indent()))
+	}
 	
 	// Note: We don't process children here because the composite's Accept method does that
 	
 	return nil
 }
 
+// idAttr returns the id attribute for an element listed in the TOC
+func (v *HTMLExportVisitor) idAttr(element Element) string {
+	anchor := v.TOC.Anchor(element)
+	if anchor == "" {
+		return ""
+	}
+	return fmt.Sprintf(" id=\"%s\"", html.EscapeString(anchor))
+}
+
+// LeaveComposite implements the CompositeLeaver interface by closing the div
+// opened in VisitComposite
+func (v *HTMLExportVisitor) LeaveComposite(composite *CompositeElement) error {
+	if composite == nil {
+		return fmt.Errorf("nil composite element")
+	}
+	
+	v.indentLevel--
+	v.Output.WriteString(v.indent())
+	v.Output.WriteString("</div>\n")
+	return nil
+}
+
 // GetHTML returns the generated HTML as a string
 func (v *HTMLExportVisitor) GetHTML() string {
 	return v.Output.String()
@@ -217,6 +451,11 @@ func (v *HTMLExportVisitor) GetHTML() string {
 type MarkdownExportVisitor struct {
 	Output strings.Builder
 	nesting int
+
+	// TOC, when set, is written as a list of links below the heading of the
+	// root composite
+	TOC        *TableOfContents
+	tocWritten bool
 }
 
 // NewMarkdownExportVisitor creates a new MarkdownExportVisitor
@@ -291,7 +530,63 @@ func (v *MarkdownExportVisitor) VisitLink(link *LinkElement) error {
 		return fmt.Errorf("nil link element")
 	}
 	
-	v.Output.WriteString(fmt.Sprintf("[%s](%s)", link.Text, link.URL))
+	if link.Title != "" {
+		v.Output.WriteString(fmt.Sprintf("[%s](%s \"%s\")\n\n", link.Text, link.URL, link.Title))
+	} else {
+		v.Output.WriteString(fmt.Sprintf("[%s](%s)\n\n", link.Text, link.URL))
+	}
+	return nil
+}
+
+// VisitHeading implements the Visitor interface for HeadingElement
+func (v *MarkdownExportVisitor) VisitHeading(heading *HeadingElement) error {
+	if heading == nil {
+		return fmt.Errorf("nil heading element")
+	}
+	
+	v.Output.WriteString(fmt.Sprintf("%s %s\n\n", strings.Repeat("#", clampHeadingLevel(heading.Level)), heading.Text))
+	return nil
+}
+
+// VisitList implements the Visitor interface for ListElement
+func (v *MarkdownExportVisitor) VisitList(list *ListElement) error {
+	if list == nil {
+		return fmt.Errorf("nil list element")
+	}
+	
+	for i, item := range list.Items {
+		if list.Ordered {
+			v.Output.WriteString(fmt.Sprintf("%d. %s\n", i+1, item))
+		} else {
+			v.Output.WriteString(fmt.Sprintf("- %s\n", item))
+		}
+	}
+	v.Output.WriteString("\n")
+	return nil
+}
+
+// VisitCodeBlock implements the Visitor interface for CodeBlockElement
+func (v *MarkdownExportVisitor) VisitCodeBlock(code *CodeBlockElement) error {
+	if code == nil {
+		return fmt.Errorf("nil code block element")
+	}
+	
+	v.Output.WriteString("```" + code.Language + "\n")
+	v.Output.WriteString(code.Code)
+	if !strings.HasSuffix(code.Code, "\n") {
+		v.Output.WriteString("\n")
+	}
+	v.Output.WriteString("```\n\n")
+	return nil
+}
+
+// VisitQuote implements the Visitor interface for QuoteElement
+func (v *MarkdownExportVisitor) VisitQuote(quote *QuoteElement) error {
+	if quote == nil {
+		return fmt.Errorf("nil quote element")
+	}
+	
+	v.Output.WriteString(fmt.Sprintf("> %s\n\n", quote.Content))
 	return nil
 }
 
@@ -303,12 +598,28 @@ func (v *MarkdownExportVisitor) VisitComposite(composite *CompositeElement) erro
 	
 	v.nesting++
 	v.Output.WriteString(fmt.Sprintf("%s %s\n\n", strings.Repeat("#", v.nesting), composite.Name))
+
+	if v.TOC != nil && !v.tocWritten {
+		v.tocWritten = true
+		v.Output.WriteString(v.TOC.Markdown())
+	}
 	
 	// Note: We don't process children here because the composite's Accept method does that
 	
 	return nil
 }
 
+// LeaveComposite implements the CompositeLeaver interface so that sibling
+// sections get the same heading level
+func (v *MarkdownExportVisitor) LeaveComposite(composite *CompositeElement) error {
+	if composite == nil {
+		return fmt.Errorf("nil composite element")
+	}
+	
+	v.nesting--
+	return nil
+}
+
 // GetMarkdown returns the generated markdown as a string
 func (v *MarkdownExportVisitor) GetMarkdown() string {
 	return v.Output.String()
@@ -380,6 +691,60 @@ func (v *PlainTextExportVisitor) VisitLink(link *LinkElement) error {
 	return nil
 }
 
+// VisitHeading implements the Visitor interface for HeadingElement
+func (v *PlainTextExportVisitor) VisitHeading(heading *HeadingElement) error {
+	if heading == nil {
+		return fmt.Errorf("nil heading element")
+	}
+	
+	v.Output.WriteString(heading.Text)
+	v.Output.WriteString("\n\n")
+	return nil
+}
+
+// VisitList implements the Visitor interface for ListElement
+func (v *PlainTextExportVisitor) VisitList(list *ListElement) error {
+	if list == nil {
+		return fmt.Errorf("nil list element")
+	}
+	
+	for i, item := range list.Items {
+		if list.Ordered {
+			v.Output.WriteString(fmt.Sprintf("%d. %s\n", i+1, item))
+		} else {
+			v.Output.WriteString(fmt.Sprintf("* %s\n", item))
+		}
+	}
+	v.Output.WriteString("\n")
+	return nil
+}
+
+// VisitCodeBlock implements the Visitor interface for CodeBlockElement
+func (v *PlainTextExportVisitor) VisitCodeBlock(code *CodeBlockElement) error {
+	if code == nil {
+		return fmt.Errorf("nil code block element")
+	}
+	
+	v.Output.WriteString(strings.TrimRight(code.Code, "\n"))
+	v.Output.WriteString("\n\n")
+	return nil
+}
+
+// VisitQuote implements the Visitor interface for QuoteElement
+func (v *PlainTextExportVisitor) VisitQuote(quote *QuoteElement) error {
+	if quote == nil {
+		return fmt.Errorf("nil quote element")
+	}
+	
+	v.Output.WriteString(fmt.Sprintf("\"%s\"", quote.Content))
+	if quote.Cite != "" {
+		v.Output.WriteString(" - ")
+		v.Output.WriteString(quote.Cite)
+	}
+	v.Output.WriteString("\n\n")
+	return nil
+}
+
 // VisitComposite implements the Visitor interface for CompositeElement
 func (v *PlainTextExportVisitor) VisitComposite(composite *CompositeElement) error {
 	if composite == nil {
@@ -395,72 +760,212 @@ func (v *PlainTextExportVisitor) GetText() string {
 	return v.Output.String()
 }
 
-// StatisticsVisitor collects document statistics
-type StatisticsVisitor struct {
-	TextCount     int
-	ImageCount    int
-	TableCount    int
-	LinkCount     int
-	WordCount     int
+// Statistics holds counts collected over a document
+type Statistics struct {
+	TextCount      int
+	ImageCount     int
+	TableCount     int
+	LinkCount      int
+	HeadingCount   int
+	ListCount      int
+	CodeBlockCount int
+	QuoteCount     int
+	WordCount      int
 	CharacterCount int
 }
 
-// NewStatisticsVisitor creates a new StatisticsVisitor
-func NewStatisticsVisitor() *StatisticsVisitor {
-	return &StatisticsVisitor{}
+// Add returns the sum of s and other
+func (s Statistics) Add(other Statistics) Statistics {
+	s.TextCount += other.TextCount
+	s.ImageCount += other.ImageCount
+	s.TableCount += other.TableCount
+	s.LinkCount += other.LinkCount
+	s.HeadingCount += other.HeadingCount
+	s.ListCount += other.ListCount
+	s.CodeBlockCount += other.CodeBlockCount
+	s.QuoteCount += other.QuoteCount
+	s.WordCount += other.WordCount
+	s.CharacterCount += other.CharacterCount
+	return s
 }
 
-// VisitText implements the Visitor interface for TextElement
-func (v *StatisticsVisitor) VisitText(text *TextElement) error {
+// addProse counts the words and characters of a piece of prose
+func (s *Statistics) addProse(text string) {
+	s.WordCount += len(strings.Fields(text))
+	s.CharacterCount += len(text)
+}
+
+// StatisticsCounter is a ResultVisitor that returns the statistics of each
+// element, summing them up for composites. Use it with Accept:
+//
+//	stats, err := visitor.Accept[visitor.Statistics](doc, visitor.StatisticsCounter{})
+type StatisticsCounter struct{}
+
+// VisitText implements the ResultVisitor interface for TextElement
+func (StatisticsCounter) VisitText(text *TextElement) (Statistics, error) {
 	if text == nil {
-		return fmt.Errorf("nil text element")
+		return Statistics{}, fmt.Errorf("nil text element")
 	}
 	
-	v.TextCount++
-	v.WordCount += len(strings.Fields(text.Content))
-	v.CharacterCount += len(text.Content)
-	return nil
+	s := Statistics{TextCount: 1}
+	s.addProse(text.Content)
+	return s, nil
 }
 
-// VisitImage implements the Visitor interface for ImageElement
-func (v *StatisticsVisitor) VisitImage(image *ImageElement) error {
+// VisitImage implements the ResultVisitor interface for ImageElement
+func (StatisticsCounter) VisitImage(image *ImageElement) (Statistics, error) {
 	if image == nil {
-		return fmt.Errorf("nil image element")
+		return Statistics{}, fmt.Errorf("nil image element")
 	}
 	
-	v.ImageCount++
-	return nil
+	return Statistics{ImageCount: 1}, nil
 }
 
-// VisitTable implements the Visitor interface for TableElement
-func (v *StatisticsVisitor) VisitTable(table *TableElement) error {
+// VisitTable implements the ResultVisitor interface for TableElement
+func (StatisticsCounter) VisitTable(table *TableElement) (Statistics, error) {
 	if table == nil {
-		return fmt.Errorf("nil table element")
+		return Statistics{}, fmt.Errorf("nil table element")
 	}
 	
-	v.TableCount++
+	s := Statistics{TableCount: 1}
 	for i := 0; i < len(table.Data); i++ {
 		for j := 0; j < len(table.Data[i]); j++ {
-			content := table.Data[i][j]
-			v.WordCount += len(strings.Fields(content))
-			v.CharacterCount += len(content)
+			s.addProse(table.Data[i][j])
 		}
 	}
-	return nil
+	return s, nil
 }
 
-// VisitLink implements the Visitor interface for LinkElement
-func (v *StatisticsVisitor) VisitLink(link *LinkElement) error {
+// VisitLink implements the ResultVisitor interface for LinkElement
+func (StatisticsCounter) VisitLink(link *LinkElement) (Statistics, error) {
 	if link == nil {
-		return fmt.Errorf("nil link element")
+		return Statistics{}, fmt.Errorf("nil link element")
+	}
+	
+	s := Statistics{LinkCount: 1}
+	s.addProse(link.Text)
+	return s, nil
+}
+
+// VisitHeading implements the ResultVisitor interface for HeadingElement
+func (StatisticsCounter) VisitHeading(heading *HeadingElement) (Statistics, error) {
+	if heading == nil {
+		return Statistics{}, fmt.Errorf("nil heading element")
+	}
+	
+	s := Statistics{HeadingCount: 1}
+	s.addProse(heading.Text)
+	return s, nil
+}
+
+// VisitList implements the ResultVisitor interface for ListElement
+func (StatisticsCounter) VisitList(list *ListElement) (Statistics, error) {
+	if list == nil {
+		return Statistics{}, fmt.Errorf("nil list element")
+	}
+	
+	s := Statistics{ListCount: 1}
+	for _, item := range list.Items {
+		s.addProse(item)
+	}
+	return s, nil
+}
+
+// VisitCodeBlock implements the ResultVisitor interface for CodeBlockElement.
+// Code is not prose, so it does not add to the word and character counts.
+func (StatisticsCounter) VisitCodeBlock(code *CodeBlockElement) (Statistics, error) {
+	if code == nil {
+		return Statistics{}, fmt.Errorf("nil code block element")
+	}
+	
+	return Statistics{CodeBlockCount: 1}, nil
+}
+
+// VisitQuote implements the ResultVisitor interface for QuoteElement
+func (StatisticsCounter) VisitQuote(quote *QuoteElement) (Statistics, error) {
+	if quote == nil {
+		return Statistics{}, fmt.Errorf("nil quote element")
+	}
+	
+	s := Statistics{QuoteCount: 1}
+	s.addProse(quote.Content)
+	return s, nil
+}
+
+// VisitComposite implements the ResultVisitor interface for CompositeElement.
+// The composite itself is not counted, only its children.
+func (StatisticsCounter) VisitComposite(composite *CompositeElement, children []Statistics) (Statistics, error) {
+	if composite == nil {
+		return Statistics{}, fmt.Errorf("nil composite element")
 	}
 	
-	v.LinkCount++
-	v.WordCount += len(strings.Fields(link.Text))
-	v.CharacterCount += len(link.Text)
+	var s Statistics
+	for _, child := range children {
+		s = s.Add(child)
+	}
+	return s, nil
+}
+
+// StatisticsVisitor collects document statistics
+type StatisticsVisitor struct {
+	Statistics
+	counter StatisticsCounter
+}
+
+// NewStatisticsVisitor creates a new StatisticsVisitor
+func NewStatisticsVisitor() *StatisticsVisitor {
+	return &StatisticsVisitor{}
+}
+
+// add accumulates the result of one of the counter's visit methods
+func (v *StatisticsVisitor) add(s Statistics, err error) error {
+	if err != nil {
+		return err
+	}
+	v.Statistics = v.Statistics.Add(s)
 	return nil
 }
 
+// VisitText implements the Visitor interface for TextElement
+func (v *StatisticsVisitor) VisitText(text *TextElement) error {
+	return v.add(v.counter.VisitText(text))
+}
+
+// VisitImage implements the Visitor interface for ImageElement
+func (v *StatisticsVisitor) VisitImage(image *ImageElement) error {
+	return v.add(v.counter.VisitImage(image))
+}
+
+// VisitTable implements the Visitor interface for TableElement
+func (v *StatisticsVisitor) VisitTable(table *TableElement) error {
+	return v.add(v.counter.VisitTable(table))
+}
+
+// VisitLink implements the Visitor interface for LinkElement
+func (v *StatisticsVisitor) VisitLink(link *LinkElement) error {
+	return v.add(v.counter.VisitLink(link))
+}
+
+// VisitHeading implements the Visitor interface for HeadingElement
+func (v *StatisticsVisitor) VisitHeading(heading *HeadingElement) error {
+	return v.add(v.counter.VisitHeading(heading))
+}
+
+// VisitList implements the Visitor interface for ListElement
+func (v *StatisticsVisitor) VisitList(list *ListElement) error {
+	return v.add(v.counter.VisitList(list))
+}
+
+// VisitCodeBlock implements the Visitor interface for CodeBlockElement
+func (v *StatisticsVisitor) VisitCodeBlock(code *CodeBlockElement) error {
+	return v.add(v.counter.VisitCodeBlock(code))
+}
+
+// VisitQuote implements the Visitor interface for QuoteElement
+func (v *StatisticsVisitor) VisitQuote(quote *QuoteElement) error {
+	return v.add(v.counter.VisitQuote(quote))
+}
+
 // VisitComposite implements the Visitor interface for CompositeElement
 func (v *StatisticsVisitor) VisitComposite(composite *CompositeElement) error {
 	if composite == nil {
@@ -472,56 +977,131 @@ func (v *StatisticsVisitor) VisitComposite(composite *CompositeElement) error {
 	return nil
 }
 
+// SpellingError describes a word that was not found in the dictionary
+type SpellingError struct {
+	Path        string   // Element path, e.g. "Document/Introduction/text[0]"
+	Field       string   // Part of the element, e.g. "alt" or "cell[1,0]"; empty for the main text
+	Offset      int      // Character offset of the word within the field
+	Word        string
+	Suggestions []string // Closest dictionary words, best first
+}
+
+// Error implements the error interface
+func (e SpellingError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Path)
+	if e.Field != "" {
+		sb.WriteString(" ")
+		sb.WriteString(e.Field)
+	}
+	sb.WriteString(fmt.Sprintf(":%d: possible spelling error %q", e.Offset, e.Word))
+	if len(e.Suggestions) > 0 {
+		sb.WriteString(fmt.Sprintf(" (did you mean %s?)", strings.Join(e.Suggestions, ", ")))
+	}
+	return sb.String()
+}
+
 // SpellCheckVisitor checks spelling across document elements
 type SpellCheckVisitor struct {
-	Errors []string
-	dictionary map[string]bool // Simple dictionary implementation
+	Errors         []SpellingError
+	MaxSuggestions int // Maximum number of suggestions per error, 0 disables suggestions
+	MaxDistance    int // Maximum edit distance of a suggestion
+	
+	dictionary *Dictionary
+	ignored    map[string]bool
+	path       ElementPath
+}
+
+// defaultWords is a very small dictionary for demonstration purposes.
+// Real documents should use NewSpellCheckVisitorWithDictionary together
+// with a proper word list.
+var defaultWords = []string{
+	"the", "and", "a", "to", "in", "that", "it", "with", "as", "for",
+	"was", "on", "are", "by", "this", "be", "is", "from", "at", "an",
+	"but", "not", "or", "what", "all", "were", "when", "we", "there", "can",
+	"your", "which", "their", "said", "if", "will", "each", "about", "how",
+	"up", "out", "them", "then", "she", "many", "some", "so", "these", "would",
+	"other", "into", "has", "more", "two", "like", "him", "see", "time", "could",
+	"no", "make", "than", "first", "been", "its", "who", "now", "people", "my",
+	"made", "over", "did", "down", "only", "way", "find", "use", "may", "water",
+	"long", "little", "very", "after", "words", "called", "just", "where", "most", "know",
 }
 
 // NewSpellCheckVisitor creates a new SpellCheckVisitor with a basic dictionary
 func NewSpellCheckVisitor() *SpellCheckVisitor {
-	// This is a very simple dictionary for demonstration purposes
-	// In a real implementation, you would use a proper spell checking library
-	dictionary := map[string]bool{
-		"the": true, "and": true, "a": true, "to": true, "in": true,
-		"that": true, "it": true, "with": true, "as": true, "for": true,
-		"was": true, "on": true, "are": true, "by": true, "this": true,
-		"be": true, "is": true, "from": true, "at": true, "an": true,
-		"but": true, "not": true, "or": true, "what": true, "all": true,
-		"were": true, "when": true, "we": true, "there": true, "can": true,
-		"an": true, "your": true, "which": true, "their": true, "said": true,
-		"if": true, "will": true, "each": true, "about": true, "how": true,
-		"up": true, "out": true, "them": true, "then": true, "she": true,
-		"many": true, "some": true, "so": true, "these": true, "would": true,
-		"other": true, "into": true, "has": true, "more": true, "two": true,
-		"like": true, "him": true, "see": true, "time": true, "could": true,
-		"no": true, "make": true, "than": true, "first": true, "been": true,
-		"its": true, "who": true, "now": true, "people": true, "my": true,
-		"made": true, "over": true, "did": true, "down": true, "only": true,
-		"way": true, "find": true, "use": true, "may": true, "water": true,
-		"long": true, "little": true, "very": true, "after": true, "words": true,
-		"called": true, "just": true, "where": true, "most": true, "know": true,
-		// Add more common words as needed
-	}
-	
+	return NewSpellCheckVisitorWithDictionary(NewDictionary(defaultWords...))
+}
+
+// NewSpellCheckVisitorWithDictionary creates a new SpellCheckVisitor that
+// checks words against the given dictionary
+func NewSpellCheckVisitorWithDictionary(dictionary *Dictionary) *SpellCheckVisitor {
 	return &SpellCheckVisitor{
-		dictionary: dictionary,
+		MaxSuggestions: 5,
+		MaxDistance:    2,
+		dictionary:     dictionary,
+		ignored:        make(map[string]bool),
+	}
+}
+
+// IgnoreWords accepts the given words without adding them to the dictionary,
+// so they are never offered as suggestions. This is meant for project
+// specific names and jargon.
+func (v *SpellCheckVisitor) IgnoreWords(words ...string) {
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			v.ignored[word] = true
+		}
+	}
+}
+
+// LoadIgnoreList reads words to ignore from a word-list file, using the same
+// format as dictionary files
+func (v *SpellCheckVisitor) LoadIgnoreList(path string) error {
+	list, err := LoadDictionary(path)
+	if err != nil {
+		return err
+	}
+	for word := range list.words {
+		v.ignored[word] = true
 	}
+	return nil
+}
+
+// GetErrors returns all the spelling errors found
+func (v *SpellCheckVisitor) GetErrors() []SpellingError {
+	return v.Errors
 }
 
-// checkWord validates if a word is in the dictionary
+// checkWord validates if a word is in the dictionary or the ignore list
 func (v *SpellCheckVisitor) checkWord(word string) bool {
-	// Convert to lowercase and strip punctuation for checking
-	cleaned := strings.TrimFunc(word, func(r rune) bool {
-		return !unicode.IsLetter(r)
-	})
-	
-	cleaned = strings.ToLower(cleaned)
-	if cleaned == "" {
-		return true // Skip empty strings
+	cleaned := strings.ToLower(word)
+	if !strings.ContainsFunc(cleaned, unicode.IsLetter) {
+		return true // Skip numbers
+	}
+	return v.ignored[cleaned] || v.dictionary.Contains(cleaned)
+}
+
+// checkText records an error for every unknown word of text
+func (v *SpellCheckVisitor) checkText(path, field, text string) {
+	for _, span := range splitWords(text) {
+		if v.checkWord(span.word) {
+			continue
+		}
+		
+		var suggestions []string
+		if v.MaxSuggestions > 0 {
+			for _, s := range v.dictionary.Suggest(span.word, v.MaxDistance, v.MaxSuggestions) {
+				suggestions = append(suggestions, s.Word)
+			}
+		}
+		v.Errors = append(v.Errors, SpellingError{
+			Path:        path,
+			Field:       field,
+			Offset:      span.offset,
+			Word:        span.word,
+			Suggestions: suggestions,
+		})
 	}
-	
-	return v.dictionary[cleaned]
 }
 
 // VisitText implements the Visitor interface for TextElement
@@ -530,12 +1110,7 @@ func (v *SpellCheckVisitor) VisitText(text *TextElement) error {
 		return fmt.Errorf("nil text element")
 	}
 	
-	words := strings.Fields(text.Content)
-	for _, word := range words {
-		if !v.checkWord(word) {
-			v.Errors = append(v.Errors, fmt.Sprintf("Possible spelling error: %s", word))
-		}
-	}
+	v.checkText(v.path.Next("text"), "", text.Content)
 	return nil
 }
 
@@ -546,12 +1121,7 @@ func (v *SpellCheckVisitor) VisitImage(image *ImageElement) error {
 	}
 	
 	// Check alt text
-	words := strings.Fields(image.Alt)
-	for _, word := range words {
-		if !v.checkWord(word) {
-			v.Errors = append(v.Errors, fmt.Sprintf("Possible spelling error in image alt text: %s", word))
-		}
-	}
+	v.checkText(v.path.Next("image"), "alt", image.Alt)
 	return nil
 }
 
@@ -561,16 +1131,10 @@ func (v *SpellCheckVisitor) VisitTable(table *TableElement) error {
 		return fmt.Errorf("nil table element")
 	}
 	
+	path := v.path.Next("table")
 	for i := 0; i < len(table.Data); i++ {
 		for j := 0; j < len(table.Data[i]); j++ {
-			content := table.Data[i][j]
-			words := strings.Fields(content)
-			for _, word := range words {
-				if !v.checkWord(word) {
-					v.Errors = append(v.Errors, 
-						fmt.Sprintf("Possible spelling error in table at [%d,%d]: %s", i, j, word))
-				}
-			}
+			v.checkText(path, fmt.Sprintf("cell[%d,%d]", i, j), table.Data[i][j])
 		}
 	}
 	return nil
@@ -582,23 +1146,54 @@ func (v *SpellCheckVisitor) VisitLink(link *LinkElement) error {
 		return fmt.Errorf("nil link element")
 	}
 	
-	// Check link text
-	words := strings.Fields(link.Text)
-	for _, word := range words {
-		if !v.checkWord(word) {
-			v.Errors = append(v.Errors, fmt.Sprintf("Possible spelling error in link text: %s", word))
-		}
+	path := v.path.Next("link")
+	v.checkText(path, "", link.Text)
+	v.checkText(path, "title", link.Title)
+	return nil
+}
+
+// VisitHeading implements the Visitor interface for HeadingElement
+func (v *SpellCheckVisitor) VisitHeading(heading *HeadingElement) error {
+	if heading == nil {
+		return fmt.Errorf("nil heading element")
 	}
 	
-	// Check title if available
-	if link.Title != "" {
-		words = strings.Fields(link.Title)
-		for _, word := range words {
-			if !v.checkWord(word) {
-				v.Errors = append(v.Errors, fmt.Sprintf("Possible spelling error in link title: %s", word))
-			}
-		}
+	v.checkText(v.path.Next("heading"), "", heading.Text)
+	return nil
+}
+
+// VisitList implements the Visitor interface for ListElement
+func (v *SpellCheckVisitor) VisitList(list *ListElement) error {
+	if list == nil {
+		return fmt.Errorf("nil list element")
 	}
+	
+	path := v.path.Next("list")
+	for i, item := range list.Items {
+		v.checkText(path, fmt.Sprintf("item[%d]", i), item)
+	}
+	return nil
+}
+
+// VisitCodeBlock implements the Visitor interface for CodeBlockElement
+func (v *SpellCheckVisitor) VisitCodeBlock(code *CodeBlockElement) error {
+	if code == nil {
+		return fmt.Errorf("nil code block element")
+	}
+	
+	// Source code is not prose, so it is not spell checked, but it still
+	// takes up a position in the element path
+	v.path.Next("code")
+	return nil
+}
+
+// VisitQuote implements the Visitor interface for QuoteElement
+func (v *SpellCheckVisitor) VisitQuote(quote *QuoteElement) error {
+	if quote == nil {
+		return fmt.Errorf("nil quote element")
+	}
+	
+	v.checkText(v.path.Next("quote"), "", quote.Content)
 	return nil
 }
 
@@ -608,17 +1203,15 @@ func (v *SpellCheckVisitor) VisitComposite(composite *CompositeElement) error {
 		return fmt.Errorf("nil composite element")
 	}
 	
+	path := v.path.Enter(composite)
+	
 	// Check name
-	words := strings.Fields(composite.Name)
-	for _, word := range words {
-		if !v.checkWord(word) {
-			v.Errors = append(v.Errors, fmt.Sprintf("Possible spelling error in section name: %s", word))
-		}
-	}
+	v.checkText(path, "name", composite.Name)
 	return nil
 }
 
-// GetErrors returns all the spelling errors found
-func (v *SpellCheckVisitor) GetErrors() []string {
-	return v.Errors
+// LeaveComposite implements the CompositeLeaver interface
+func (v *SpellCheckVisitor) LeaveComposite(composite *CompositeElement) error {
+	v.path.Leave()
+	return nil
 }
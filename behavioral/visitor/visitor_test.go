@@ -1,7 +1,6 @@
 package visitor
 
 import (
-	"reflect"
 	"strings"
 	"testing"
 )
@@ -101,7 +100,7 @@ func TestCompositeElementVisitorAcceptance(t *testing.T) {
 		t.Errorf("CompositeElement.Accept returned error: %v", err)
 	}
 	
-	expected := "<div class=\"section\">\n<p>Hello</p>\n"
+	expected := "<div class=\"section\">\n  <p>Hello</p>\n</div>\n"
 	if visitor.Output.String() != expected {
 		t.Errorf("CompositeElement.Accept produced incorrect HTML.\nExpected: %q\nGot: %q", expected, visitor.Output.String())
 	}
@@ -381,8 +380,8 @@ func TestStatisticsVisitor(t *testing.T) {
 	if visitor.TableCount != 1 {
 		t.Errorf("StatisticsVisitor.TableCount is incorrect. Expected 1, got %d", visitor.TableCount)
 	}
-	if visitor.WordCount != 7 { // "One two three" + "Example Link" + 4 words in table
-		t.Errorf("StatisticsVisitor.WordCount is incorrect. Expected 7, got %d", visitor.WordCount)
+	if visitor.WordCount != 9 { // "One two three" + "Example Link" + 4 words in table
+		t.Errorf("StatisticsVisitor.WordCount is incorrect. Expected 9, got %d", visitor.WordCount)
 	}
 }
 
@@ -401,7 +400,7 @@ func TestSpellCheckVisitor(t *testing.T) {
 	}
 	
 	// Reset errors
-	visitor.Errors = []string{}
+	visitor.Errors = nil
 	
 	// Test visit text element with invalid words
 	textElement = &TextElement{Content: "the and xyzzyx flubbergasted"}
@@ -462,8 +461,65 @@ func TestEmptyCompositeElement(t *testing.T) {
 		t.Errorf("Empty CompositeElement.Accept returned error: %v", err)
 	}
 	
-	expected := "<div class=\"empty\">\n"
+	expected := "<div class=\"empty\">\n</div>\n"
 	if visitor.Output.String() != expected {
 		t.Errorf("Empty CompositeElement.Accept produced incorrect HTML.\nExpected: %q\nGot: %q", expected, visitor.Output.String())
 	}
 }
+
+// TestStructuralElementExport tests the exporters on headings, lists, code blocks and quotes
+func TestStructuralElementExport(t *testing.T) {
+	elements := []Element{
+		&HeadingElement{Level: 2, Text: "Usage"},
+		&ListElement{Items: []string{"one", "two"}},
+		&ListElement{Ordered: true, Items: []string{"first"}},
+		&CodeBlockElement{Language: "go", Code: "a := b < c"},
+		&QuoteElement{Content: "Less is more", Cite: "Mies"},
+	}
+	
+	htmlVisitor := NewHTMLExportVisitor()
+	mdVisitor := NewMarkdownExportVisitor()
+	txtVisitor := NewPlainTextExportVisitor()
+	for _, element := range elements {
+		for _, v := range []Visitor{htmlVisitor, mdVisitor, txtVisitor} {
+			if err := element.Accept(v); err != nil {
+				t.Fatalf("Accept returned error: %v", err)
+			}
+		}
+	}
+	
+	expectedHTML := "<h2>Usage</h2>\n" +
+		"<ul>\n  <li>one</li>\n  <li>two</li>\n</ul>\n" +
+		"<ol>\n  <li>first</li>\n</ol>\n" +
+		"<pre><code class=\"language-go\">a := b &lt; c</code></pre>\n" +
+		"<blockquote cite=\"Mies\">Less is more</blockquote>\n"
+	if htmlVisitor.GetHTML() != expectedHTML {
+		t.Errorf("HTMLExportVisitor output is incorrect.\nExpected: %q\nGot: %q", expectedHTML, htmlVisitor.GetHTML())
+	}
+	
+	expectedMarkdown := "## Usage\n\n" +
+		"- one\n- two\n\n" +
+		"1. first\n\n" +
+		"```go\na := b < c\n```\n\n" +
+		"> Less is more\n\n"
+	if mdVisitor.GetMarkdown() != expectedMarkdown {
+		t.Errorf("MarkdownExportVisitor output is incorrect.\nExpected: %q\nGot: %q", expectedMarkdown, mdVisitor.GetMarkdown())
+	}
+	
+	expectedText := "Usage\n\n" +
+		"* one\n* two\n\n" +
+		"1. first\n\n" +
+		"a := b < c\n\n" +
+		"\"Less is more\" - Mies\n\n"
+	if txtVisitor.GetText() != expectedText {
+		t.Errorf("PlainTextExportVisitor output is incorrect.\nExpected: %q\nGot: %q", expectedText, txtVisitor.GetText())
+	}
+	
+	// Nil elements are rejected like the other element types
+	if err := htmlVisitor.VisitHeading(nil); err == nil {
+		t.Error("HTMLExportVisitor.VisitHeading did not return error for nil element")
+	}
+	if err := mdVisitor.VisitList(nil); err == nil {
+		t.Error("MarkdownExportVisitor.VisitList did not return error for nil element")
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	fmt.Println("Generated Builder Example")
+	fmt.Println("=========================")
+
+	// Host is required, so Build reports it as missing
+	if _, err := NewServerBuilder().WithPort(9090).Build(); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	// Fields that are not set get their defaults
+	server, err := NewServerBuilder().
+		WithHost("localhost").
+		WithAllowOrigins("https://example.com", "https://example.org").
+		Build()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Server: %s:%d (read timeout %s, write timeout %s)\n",
+		server.Host, server.Port, server.ReadTimeout, server.WriteTimeout)
+	fmt.Printf("Allowed origins: %v\n", server.AllowOrigins)
+}
@@ -0,0 +1,16 @@
+package main
+
+import "time"
+
+// Server is the configuration of an HTTP server. ServerBuilder in
+// server_builder.go is generated from it.
+//
+//go:generate go run github.com/edgardnogueira/go-patterns/cmd/genbuilder -type=Server
+type Server struct {
+	Host         string        `builder:"required"`
+	Port         int           `builder:"default=8080"`
+	ReadTimeout  time.Duration `builder:"default=5s"`
+	WriteTimeout time.Duration `builder:"default=10s"`
+	AllowOrigins []string
+	Debug        bool
+}
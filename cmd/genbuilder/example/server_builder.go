@@ -0,0 +1,86 @@
+// Code generated by "genbuilder -type=Server"; DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ServerBuilder builds a Server one field at a time
+type ServerBuilder struct {
+	value Server
+	set   map[string]bool
+}
+
+// NewServerBuilder creates a new ServerBuilder
+func NewServerBuilder() *ServerBuilder {
+	return &ServerBuilder{set: make(map[string]bool)}
+}
+
+// WithHost sets the Host field
+func (b *ServerBuilder) WithHost(host string) *ServerBuilder {
+	b.value.Host = host
+	b.set["Host"] = true
+	return b
+}
+
+// WithPort sets the Port field
+func (b *ServerBuilder) WithPort(port int) *ServerBuilder {
+	b.value.Port = port
+	b.set["Port"] = true
+	return b
+}
+
+// WithReadTimeout sets the ReadTimeout field
+func (b *ServerBuilder) WithReadTimeout(readTimeout time.Duration) *ServerBuilder {
+	b.value.ReadTimeout = readTimeout
+	b.set["ReadTimeout"] = true
+	return b
+}
+
+// WithWriteTimeout sets the WriteTimeout field
+func (b *ServerBuilder) WithWriteTimeout(writeTimeout time.Duration) *ServerBuilder {
+	b.value.WriteTimeout = writeTimeout
+	b.set["WriteTimeout"] = true
+	return b
+}
+
+// WithAllowOrigins sets the AllowOrigins field
+func (b *ServerBuilder) WithAllowOrigins(allowOrigins ...string) *ServerBuilder {
+	b.value.AllowOrigins = allowOrigins
+	b.set["AllowOrigins"] = true
+	return b
+}
+
+// WithDebug sets the Debug field
+func (b *ServerBuilder) WithDebug(debug bool) *ServerBuilder {
+	b.value.Debug = debug
+	b.set["Debug"] = true
+	return b
+}
+
+// Build checks that the required fields were set, applies the defaults of
+// the fields that were not and returns the Server
+func (b *ServerBuilder) Build() (Server, error) {
+	var missing []string
+	if !b.set["Host"] {
+		missing = append(missing, "Host")
+	}
+	if len(missing) > 0 {
+		return Server{}, fmt.Errorf("building Server: missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	value := b.value
+	if !b.set["Port"] {
+		value.Port = 8080
+	}
+	if !b.set["ReadTimeout"] {
+		value.ReadTimeout = 5 * time.Second
+	}
+	if !b.set["WriteTimeout"] {
+		value.WriteTimeout = 10 * time.Second
+	}
+	return value, nil
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// builderField describes the code generated for one struct field
+type builderField struct {
+	Name     string // Field name
+	Param    string // Parameter name of the WithX method
+	Type     string // Field type as written in the source
+	Variadic bool   // Slices get a variadic WithX method
+	Required bool
+	Default  string // Go expression for the default value, empty for none
+}
+
+// builderSpec describes the builder generated for one struct type
+type builderSpec struct {
+	Name       string // Struct type name
+	TypeParams string // e.g. "[K comparable, V any]", empty for non-generic types
+	TypeArgs   string // e.g. "[K, V]"
+	Fields     []builderField
+}
+
+// sourcePackage is a parsed and type-checked package
+type sourcePackage struct {
+	name  string
+	fset  *token.FileSet
+	files []*ast.File
+	info  *types.Info
+}
+
+// Generate returns the formatted source of builders for the named struct
+// types in the package in dir. The file named skip, usually a previous
+// output, is not read.
+func Generate(dir string, typeNames []string, skip string) ([]byte, error) {
+	pkg, err := loadPackage(dir, typeNames[0], skip)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := make(map[string]string) // path -> name, empty if not renamed
+	var specs []builderSpec
+	for _, name := range typeNames {
+		spec, err := pkg.builderSpec(name, imports)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+
+	var buf bytes.Buffer
+	writeBuilders(&buf, pkg.name, typeNames, specs, imports)
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+// loadPackage parses the Go files in dir that belong to the package
+// declaring typeName and type-checks them.
+//
+// Directories in this repository often hold a library and a package main
+// side by side, so files are grouped by package name rather than loaded
+// with go/build, which rejects such directories.
+func loadPackage(dir, typeName, skip string) (*sourcePackage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	byPackage := make(map[string][]*ast.File)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == skip {
+			continue
+		}
+		if match, err := build.Default.MatchFile(dir, name); err != nil || !match {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		byPackage[file.Name.Name] = append(byPackage[file.Name.Name], file)
+	}
+
+	for name, files := range byPackage {
+		if ts, _ := findTypeSpec(files, typeName); ts == nil {
+			continue
+		}
+		info := &types.Info{
+			Types: make(map[ast.Expr]types.TypeAndValue),
+			Defs:  make(map[*ast.Ident]types.Object),
+			Uses:  make(map[*ast.Ident]types.Object),
+		}
+		// Errors elsewhere in the package must not stop generation, so they
+		// are ignored; fields whose types cannot be resolved fall back to the
+		// source text
+		conf := types.Config{
+			Importer: importer.ForCompiler(fset, "source", nil),
+			Error:    func(error) {},
+		}
+		conf.Check(name, fset, files, info)
+		return &sourcePackage{name: name, fset: fset, files: files, info: info}, nil
+	}
+	return nil, fmt.Errorf("type %s not found in %s", typeName, dir)
+}
+
+// findTypeSpec returns the declaration of the named type and its file, or
+// nil if the files do not declare it
+func findTypeSpec(files []*ast.File, name string) (*ast.TypeSpec, *ast.File) {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				if ts := spec.(*ast.TypeSpec); ts.Name.Name == name {
+					return ts, file
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// builderSpec collects what is needed to generate the builder for a struct,
+// adding the imports its field types need to imports
+func (p *sourcePackage) builderSpec(typeName string, imports map[string]string) (builderSpec, error) {
+	ts, file := findTypeSpec(p.files, typeName)
+	if ts == nil {
+		return builderSpec{}, fmt.Errorf("type %s not found in package %s", typeName, p.name)
+	}
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		return builderSpec{}, fmt.Errorf("%s is not a struct type", typeName)
+	}
+
+	spec := builderSpec{Name: typeName}
+	if ts.TypeParams != nil {
+		var params, args []string
+		for _, field := range ts.TypeParams.List {
+			var names []string
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+			}
+			params = append(params, strings.Join(names, ", ")+" "+p.exprString(field.Type))
+			args = append(args, names...)
+			p.collectImports(file, field.Type, imports)
+		}
+		spec.TypeParams = "[" + strings.Join(params, ", ") + "]"
+		spec.TypeArgs = "[" + strings.Join(args, ", ") + "]"
+	}
+
+	for _, f := range st.Fields.List {
+		options, err := parseTag(f.Tag)
+		if err != nil {
+			return builderSpec{}, fmt.Errorf("%s: %w", p.fset.Position(f.Pos()), err)
+		}
+		if options.skip {
+			continue
+		}
+
+		names := make([]string, 0, len(f.Names))
+		for _, name := range f.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) == 0 {
+			names = append(names, embeddedName(f.Type))
+		}
+
+		for _, name := range names {
+			if name == "_" {
+				continue
+			}
+			field := builderField{
+				Name:     name,
+				Param:    paramName(name),
+				Type:     p.exprString(f.Type),
+				Required: options.required,
+			}
+			if slice, ok := f.Type.(*ast.ArrayType); ok && slice.Len == nil {
+				field.Variadic = true
+				field.Type = p.exprString(slice.Elt)
+			}
+			if options.hasDefault {
+				expr, err := defaultExpr(options.defaultValue, p.info.TypeOf(f.Type))
+				if err != nil {
+					return builderSpec{}, fmt.Errorf("%s: field %s: %w", p.fset.Position(f.Pos()), name, err)
+				}
+				field.Default = expr
+			}
+			spec.Fields = append(spec.Fields, field)
+		}
+		p.collectImports(file, f.Type, imports)
+	}
+	return spec, nil
+}
+
+// exprString returns the source text of a type expression
+func (p *sourcePackage) exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, p.fset, expr)
+	return buf.String()
+}
+
+// collectImports records the packages referred to by a type expression
+func (p *sourcePackage) collectImports(file *ast.File, expr ast.Expr, imports map[string]string) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if pkgName, ok := p.info.Uses[ident].(*types.PkgName); ok {
+			name := ""
+			if pkgName.Name() != pkgName.Imported().Name() {
+				name = pkgName.Name()
+			}
+			imports[pkgName.Imported().Path()] = name
+			return false
+		}
+
+		// The import could not be type-checked; match it by name instead
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			if spec.Name != nil && spec.Name.Name == ident.Name {
+				imports[path] = ident.Name
+				return false
+			}
+			if spec.Name == nil && importName(path) == ident.Name {
+				imports[path] = ""
+				return false
+			}
+		}
+		return false
+	})
+}
+
+// importName guesses the package name of an import path from its last
+// element, ignoring major version suffixes such as /v2
+func importName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// embeddedName returns the field name of an embedded type
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	default:
+		return ""
+	}
+}
+
+// paramName turns a field name into a parameter name: "Host" becomes
+// "host", "URL" becomes "url" and "HTTPClient" becomes "httpClient". Names
+// that would be keywords or clash with the receiver get a suffix.
+func paramName(field string) string {
+	runes := []rune(field)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	switch {
+	case upper == len(runes):
+		upper = len(runes)
+	case upper > 1:
+		upper-- // Keep the first letter of the next word upper case
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	name := string(runes)
+	if token.IsKeyword(name) || name == "b" || name == "_" {
+		name += "Value"
+	}
+	return name
+}
+
+// tagOptions are the parsed options of a builder struct tag
+type tagOptions struct {
+	skip         bool
+	required     bool
+	hasDefault   bool
+	defaultValue string
+}
+
+// parseTag parses the builder key of a struct tag
+func parseTag(lit *ast.BasicLit) (tagOptions, error) {
+	var options tagOptions
+	if lit == nil {
+		return options, nil
+	}
+	raw, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return options, fmt.Errorf("invalid struct tag %s", lit.Value)
+	}
+	value, ok := reflect.StructTag(raw).Lookup("builder")
+	if !ok {
+		return options, nil
+	}
+	if value == "-" {
+		options.skip = true
+		return options, nil
+	}
+
+	for value != "" {
+		option := value
+		if strings.HasPrefix(option, "default=") {
+			// The default is the rest of the tag, so it may contain commas
+			options.hasDefault = true
+			options.defaultValue = strings.TrimPrefix(option, "default=")
+			break
+		}
+		if i := strings.IndexByte(option, ','); i >= 0 {
+			option, value = option[:i], option[i+1:]
+		} else {
+			value = ""
+		}
+
+		switch strings.TrimSpace(option) {
+		case "required":
+			options.required = true
+		case "":
+		default:
+			return options, fmt.Errorf("unknown builder option %q", option)
+		}
+	}
+
+	if options.required && options.hasDefault {
+		return options, fmt.Errorf("a field cannot be both required and have a default")
+	}
+	return options, nil
+}
+
+// defaultExpr checks a default value against the field's type and returns
+// the Go expression that assigns it
+func defaultExpr(value string, typ types.Type) (string, error) {
+	if typ == nil || typ == types.Typ[types.Invalid] {
+		return "", fmt.Errorf("cannot resolve the field's type to check its default %q", value)
+	}
+
+	if named, ok := typ.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Duration" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid duration default: %w", err)
+			}
+			return durationExpr(d), nil
+		}
+	}
+
+	basic, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return "", fmt.Errorf("default values are only supported for strings, booleans, numbers and time.Duration, not %s", typ)
+	}
+
+	info := basic.Info()
+	switch {
+	case info&types.IsString != 0:
+		return strconv.Quote(value), nil
+	case info&types.IsBoolean != 0:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid bool default %q", value)
+		}
+		return strconv.FormatBool(b), nil
+	case info&types.IsUnsigned != 0:
+		if _, err := strconv.ParseUint(value, 0, basicBits(basic.Kind())); err != nil {
+			return "", fmt.Errorf("invalid %s default %q", basic.Name(), value)
+		}
+		return value, nil
+	case info&types.IsInteger != 0:
+		if _, err := strconv.ParseInt(value, 0, basicBits(basic.Kind())); err != nil {
+			return "", fmt.Errorf("invalid %s default %q", basic.Name(), value)
+		}
+		return value, nil
+	case info&types.IsFloat != 0:
+		if _, err := strconv.ParseFloat(value, basicBits(basic.Kind())); err != nil {
+			return "", fmt.Errorf("invalid %s default %q", basic.Name(), value)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("default values are not supported for %s", typ)
+	}
+}
+
+// basicBits returns the size in bits of a numeric kind
+func basicBits(kind types.BasicKind) int {
+	switch kind {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	default:
+		return 64
+	}
+}
+
+// durationExpr writes a duration with the largest unit that divides it, so
+// that 30s becomes "30 * time.Second" rather than a count of nanoseconds
+func durationExpr(d time.Duration) string {
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+	if d == 0 {
+		return "0"
+	}
+	for _, u := range units {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d * %s", d/u.unit, u.name)
+		}
+	}
+	return fmt.Sprintf("%d * time.Nanosecond", int64(d))
+}
+
+// writeBuilders writes the generated file, before formatting
+func writeBuilders(buf *bytes.Buffer, pkgName string, typeNames []string, specs []builderSpec, imports map[string]string) {
+	needsFmt := false
+	for _, spec := range specs {
+		for _, field := range spec.Fields {
+			needsFmt = needsFmt || field.Required
+		}
+	}
+	if needsFmt {
+		imports["fmt"] = ""
+		imports["strings"] = ""
+	}
+
+	fmt.Fprintf(buf, "// Code generated by \"genbuilder -type=%s\"; DO NOT EDIT.\n\n", strings.Join(typeNames, ","))
+	fmt.Fprintf(buf, "package %s\n\n", pkgName)
+
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for path := range imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		buf.WriteString("import (\n")
+		for _, path := range paths {
+			if name := imports[path]; name != "" {
+				fmt.Fprintf(buf, "\t%s %q\n", name, path)
+			} else {
+				fmt.Fprintf(buf, "\t%q\n", path)
+			}
+		}
+		buf.WriteString(")\n\n")
+	}
+
+	for _, spec := range specs {
+		writeBuilder(buf, spec)
+	}
+}
+
+// writeBuilder writes the builder type, constructor, WithX methods and
+// Build method for one struct
+func writeBuilder(buf *bytes.Buffer, spec builderSpec) {
+	builder := spec.Name + "Builder"
+	target := spec.Name + spec.TypeArgs
+	receiver := "*" + builder + spec.TypeArgs
+
+	fmt.Fprintf(buf, "// %s builds a %s one field at a time\n", builder, spec.Name)
+	fmt.Fprintf(buf, "type %s%s struct {\n\tvalue %s\n\tset map[string]bool\n}\n\n", builder, spec.TypeParams, target)
+
+	fmt.Fprintf(buf, "// New%s creates a new %s\n", builder, builder)
+	fmt.Fprintf(buf, "func New%s%s() %s {\n", builder, spec.TypeParams, receiver)
+	fmt.Fprintf(buf, "\treturn &%s%s{set: make(map[string]bool)}\n}\n\n", builder, spec.TypeArgs)
+
+	for _, field := range spec.Fields {
+		param := field.Param + " " + field.Type
+		if field.Variadic {
+			param = field.Param + " ..." + field.Type
+		}
+		fmt.Fprintf(buf, "// With%s sets the %s field\n", exportedName(field.Name), field.Name)
+		fmt.Fprintf(buf, "func (b %s) With%s(%s) %s {\n", receiver, exportedName(field.Name), param, receiver)
+		fmt.Fprintf(buf, "\tb.value.%s = %s\n", field.Name, field.Param)
+		fmt.Fprintf(buf, "\tb.set[%q] = true\n", field.Name)
+		buf.WriteString("\treturn b\n}\n\n")
+	}
+
+	fmt.Fprintf(buf, "// Build checks that the required fields were set, applies the defaults of\n")
+	fmt.Fprintf(buf, "// the fields that were not and returns the %s\n", spec.Name)
+	fmt.Fprintf(buf, "func (b %s) Build() (%s, error) {\n", receiver, target)
+
+	var required, defaults []builderField
+	for _, field := range spec.Fields {
+		if field.Required {
+			required = append(required, field)
+		}
+		if field.Default != "" {
+			defaults = append(defaults, field)
+		}
+	}
+
+	if len(required) > 0 {
+		buf.WriteString("\tvar missing []string\n")
+		for _, field := range required {
+			fmt.Fprintf(buf, "\tif !b.set[%q] {\n\t\tmissing = append(missing, %q)\n\t}\n", field.Name, field.Name)
+		}
+		buf.WriteString("\tif len(missing) > 0 {\n")
+		fmt.Fprintf(buf, "\t\treturn %s{}, fmt.Errorf(\"building %s: missing required fields: %%s\", strings.Join(missing, \", \"))\n", target, spec.Name)
+		buf.WriteString("\t}\n\n")
+	}
+
+	buf.WriteString("\tvalue := b.value\n")
+	for _, field := range defaults {
+		fmt.Fprintf(buf, "\tif !b.set[%q] {\n\t\tvalue.%s = %s\n\t}\n", field.Name, field.Name, field.Default)
+	}
+	buf.WriteString("\treturn value, nil\n}\n\n")
+}
+
+// exportedName upper-cases the first letter of a field name for its method
+func exportedName(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
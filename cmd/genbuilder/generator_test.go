@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenCases are the packages in testdata and the types generated for each
+var goldenCases = []struct {
+	dir   string
+	types []string
+}{
+	{"basic", []string{"Server", "TLSConfig"}},
+	{"generic", []string{"Cache"}},
+}
+
+// TestGoldenFiles compares the generated code with testdata/<dir>.golden.
+// Run with -update after intended changes to the output.
+func TestGoldenFiles(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.dir, func(t *testing.T) {
+			got, err := Generate(filepath.Join("testdata", c.dir), c.types, "")
+			if err != nil {
+				t.Fatalf("Generate returned error: %v", err)
+			}
+
+			golden := filepath.Join("testdata", c.dir+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Reading golden file: %v", err)
+			}
+			if string(got) != string(expected) {
+				t.Errorf("Generated code does not match %s.\nGot:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// TestGeneratedCodeTypeChecks tests that the golden files compile together
+// with their input
+func TestGeneratedCodeTypeChecks(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.dir, func(t *testing.T) {
+			fset := token.NewFileSet()
+			var files []*ast.File
+			paths, _ := filepath.Glob(filepath.Join("testdata", c.dir, "*.go"))
+			paths = append(paths, filepath.Join("testdata", c.dir+".golden"))
+			for _, path := range paths {
+				file, err := parser.ParseFile(fset, path, nil, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				files = append(files, file)
+			}
+
+			conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+			if _, err := conf.Check(c.dir, fset, files, nil); err != nil {
+				t.Errorf("Generated code does not type-check: %v", err)
+			}
+		})
+	}
+}
+
+// TestGenerateErrors tests that invalid input is reported
+func TestGenerateErrors(t *testing.T) {
+	cases := []struct {
+		name, src, typeName, message string
+	}{
+		{"missing type", "type A struct{}", "B", "type B not found"},
+		{"not a struct", "type A int", "A", "A is not a struct type"},
+		{"bad int default", "type A struct{ N int `builder:\"default=ten\"` }", "A", `invalid int default "ten"`},
+		{"overflow", "type A struct{ N int8 `builder:\"default=300\"` }", "A", `invalid int8 default "300"`},
+		{"bad duration", "import \"time\"\ntype A struct{ D time.Duration `builder:\"default=soon\"` }", "A", "invalid duration default"},
+		{"unsupported default", "type A struct{ S []string `builder:\"default=a\"` }", "A", "only supported for strings"},
+		{"required and default", "type A struct{ S string `builder:\"required,default=a\"` }", "A", "both required and have a default"},
+		{"unknown option", "type A struct{ S string `builder:\"optional\"` }", "A", `unknown builder option "optional"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"+c.src+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Generate(dir, []string{c.typeName}, "")
+			if err == nil || !strings.Contains(err.Error(), c.message) {
+				t.Errorf("Expected an error containing %q, got %v", c.message, err)
+			}
+		})
+	}
+}
+
+// TestParamName tests parameter names derived from field names
+func TestParamName(t *testing.T) {
+	cases := map[string]string{
+		"Host":       "host",
+		"URL":        "url",
+		"HTTPClient": "httpClient",
+		"Type":       "typeValue",
+		"B":          "bValue",
+		"secret":     "secret",
+	}
+	for field, expected := range cases {
+		if got := paramName(field); got != expected {
+			t.Errorf("paramName(%q) = %q, expected %q", field, got, expected)
+		}
+	}
+}
+
+// TestGeneratedBuilderRuns builds and runs a program using a generated
+// builder to check required fields and defaults at run time
+func TestGeneratedBuilderRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/run\n\ngo 1.21\n",
+		"config.go": `package main
+
+import "time"
+
+type Config struct {
+	Name    string        ` + "`builder:\"required\"`" + `
+	Retries int           ` + "`builder:\"default=3\"`" + `
+	Timeout time.Duration ` + "`builder:\"default=500ms\"`" + `
+	Hosts   []string
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	_, err := NewConfigBuilder().WithRetries(5).Build()
+	fmt.Println(err)
+
+	config, err := NewConfigBuilder().WithName("svc").WithRetries(0).WithHosts("a", "b").Build()
+	fmt.Println(config.Name, config.Retries, config.Timeout, config.Hosts, err)
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src, err := Generate(dir, []string{"Config"}, "")
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config_builder.go"), src, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+
+	expected := "building Config: missing required fields: Name\nsvc 0 500ms [a b] <nil>\n"
+	if string(out) != expected {
+		t.Errorf("Unexpected output.\nExpected:\n%s\nGot:\n%s", expected, out)
+	}
+}
@@ -0,0 +1,85 @@
+// Command genbuilder generates fluent builders for struct types.
+//
+// Put a go:generate directive in the file that declares the struct:
+//
+//	//go:generate genbuilder -type=Server
+//	type Server struct {
+//		Host    string        `builder:"required"`
+//		Port    int           `builder:"default=8080"`
+//		Timeout time.Duration `builder:"default=30s"`
+//		Tags    []string
+//	}
+//
+// Running go generate writes server_builder.go next to it, with a
+// ServerBuilder that has a WithX method for every field and a
+// Build() (Server, error) method that checks required fields and fills in
+// default values.
+//
+// The builder struct tag takes comma-separated options:
+//
+//	required       Build returns an error unless the field was set
+//	default=VALUE  Value for the field when it was not set; must come last
+//	-              No WithX method is generated for the field
+//
+// Defaults are supported for strings, booleans, numbers and time.Duration
+// (written like "30s"). They are checked when the builder is generated, so a
+// default that does not fit the field's type fails go generate rather than
+// the program.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of struct type names; must be set")
+	output    = flag.String("output", "", "output file name; default srcdir/<type>_builder.go")
+)
+
+// usage prints the command line help
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of genbuilder:\n")
+	fmt.Fprintf(os.Stderr, "\tgenbuilder [flags] -type T [directory]\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("genbuilder: ")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	dir := "."
+	switch args := flag.Args(); len(args) {
+	case 0:
+	case 1:
+		dir = args[0]
+	default:
+		log.Fatal("only one directory can be given")
+	}
+
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = filepath.Join(dir, strings.ToLower(types[0])+"_builder.go")
+	}
+
+	src, err := Generate(dir, types, filepath.Base(outputPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(outputPath, src, 0644); err != nil {
+		log.Fatalf("writing output: %v", err)
+	}
+}
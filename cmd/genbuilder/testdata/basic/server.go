@@ -0,0 +1,38 @@
+package basic
+
+import (
+	"net/http"
+	"time"
+
+	logpkg "log"
+)
+
+// Handler is an interface field type declared in the package
+type Handler interface {
+	Serve(path string) error
+}
+
+//go:generate genbuilder -type=Server,TLSConfig
+type Server struct {
+	Host      string        `builder:"required"`
+	Port      int           `builder:"default=8080"`
+	Timeout   time.Duration `builder:"default=1m30s"`
+	ReadOnly  bool          `builder:"default=true"`
+	Ratio     float32       `builder:"default=0.75"`
+	Name      string        `builder:"default=api, v1"`
+	Tags      []string
+	Handler   Handler `builder:"required"`
+	Client    *http.Client
+	Logger    *logpkg.Logger
+	TLS       *TLSConfig
+	URL, Path string
+	internal  int    `builder:"-"`
+	secret    string // Unexported fields still get a WithX method
+}
+
+// TLSConfig is generated into the same file as Server
+type TLSConfig struct {
+	CertFile   string `json:"cert" builder:"required"`
+	KeyFile    string `builder:"required"`
+	MinVersion uint16 `builder:"default=0x0303"`
+}
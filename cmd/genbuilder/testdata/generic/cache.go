@@ -0,0 +1,18 @@
+package generic
+
+import "sync"
+
+// Entry is embedded in Cache
+type Entry[V any] struct {
+	Value V
+}
+
+//go:generate genbuilder -type=Cache
+type Cache[K comparable, V any] struct {
+	*sync.Mutex
+	Entry[V]
+	Items    map[K]V `builder:"required"`
+	Capacity int     `builder:"default=128"`
+	Type     string
+	OnEvict  func(key K, value V)
+}
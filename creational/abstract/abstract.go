@@ -1,21 +1,94 @@
 package abstract
 
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Widget is the behaviour shared by every product of a family
+type Widget interface {
+	Render() string
+	// Family returns the name of the theme the widget belongs to
+	Family() string
+	// HTML returns the widget's markup. Widgets use the ui-<kind> CSS
+	// classes styled by their theme's stylesheet.
+	HTML(props WidgetProps) string
+}
+
+// WidgetProps is the content a widget displays when rendered as HTML.
+// Each widget uses the fields that apply to it.
+type WidgetProps struct {
+	ID          string
+	Label       string
+	Value       string   // Text field value
+	Placeholder string   // Text field placeholder
+	Checked     bool     // Checkbox state
+	Text        string   // Dialog body
+	Items       []string // Menu entries
+}
+
 // Button is an abstract product
 type Button interface {
-	Render() string
+	Widget
 	OnClick() string
 }
 
 // Checkbox is an abstract product
 type Checkbox interface {
-	Render() string
+	Widget
 	Toggle() string
 }
 
+// TextField is an abstract product
+type TextField interface {
+	Widget
+	Type(text string) string
+}
+
+// Dialog is an abstract product
+type Dialog interface {
+	Widget
+	Open() string
+}
+
+// Menu is an abstract product
+type Menu interface {
+	Widget
+	Select(item string) string
+}
+
 // GUIFactory is the abstract factory interface
 type GUIFactory interface {
 	CreateButton() Button
 	CreateCheckbox() Checkbox
+	CreateTextField() TextField
+	CreateDialog() Dialog
+	CreateMenu() Menu
+}
+
+// idAttr returns an id attribute, or nothing for an empty id
+func idAttr(id string) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf(` id="%s"`, html.EscapeString(id))
+}
+
+// forAttr returns the for attribute of a label, or nothing for an empty id
+func forAttr(id string) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf(` for="%s"`, html.EscapeString(id))
+}
+
+// checkedAttr returns the checked attribute of a checked checkbox
+func checkedAttr(checked bool) string {
+	if checked {
+		return " checked"
+	}
+	return ""
 }
 
 // ModernButton is a concrete product
@@ -31,6 +104,16 @@ func (b *ModernButton) OnClick() string {
 	return "Modern button clicked with smooth animation"
 }
 
+// Family returns the theme of the modern button
+func (b *ModernButton) Family() string {
+	return "modern"
+}
+
+// HTML renders the modern button as a flat button
+func (b *ModernButton) HTML(props WidgetProps) string {
+	return fmt.Sprintf(`<button type="button"%s class="ui-button">%s</button>`, idAttr(props.ID), html.EscapeString(props.Label))
+}
+
 // VintageButton is a concrete product
 type VintageButton struct{}
 
@@ -44,6 +127,16 @@ func (b *VintageButton) OnClick() string {
 	return "Vintage button clicked with click sound"
 }
 
+// Family returns the theme of the vintage button
+func (b *VintageButton) Family() string {
+	return "vintage"
+}
+
+// HTML renders the vintage button as a bevelled push button
+func (b *VintageButton) HTML(props WidgetProps) string {
+	return fmt.Sprintf(`<button type="button"%s class="ui-button ui-bevel">%s</button>`, idAttr(props.ID), html.EscapeString(props.Label))
+}
+
 // ModernCheckbox is a concrete product
 type ModernCheckbox struct{}
 
@@ -57,6 +150,17 @@ func (c *ModernCheckbox) Toggle() string {
 	return "Modern checkbox toggled with sliding animation"
 }
 
+// Family returns the theme of the modern checkbox
+func (c *ModernCheckbox) Family() string {
+	return "modern"
+}
+
+// HTML renders the modern checkbox as a sliding switch
+func (c *ModernCheckbox) HTML(props WidgetProps) string {
+	return fmt.Sprintf(`<label class="ui-checkbox ui-switch"><input type="checkbox" role="switch"%s%s><span class="ui-slider"></span> %s</label>`,
+		idAttr(props.ID), checkedAttr(props.Checked), html.EscapeString(props.Label))
+}
+
 // VintageCheckbox is a concrete product
 type VintageCheckbox struct{}
 
@@ -70,6 +174,178 @@ func (c *VintageCheckbox) Toggle() string {
 	return "Vintage checkbox toggled with mechanical sound"
 }
 
+// Family returns the theme of the vintage checkbox
+func (c *VintageCheckbox) Family() string {
+	return "vintage"
+}
+
+// HTML renders the vintage checkbox as a plain check box
+func (c *VintageCheckbox) HTML(props WidgetProps) string {
+	return fmt.Sprintf(`<label class="ui-checkbox"><input type="checkbox"%s%s> %s</label>`,
+		idAttr(props.ID), checkedAttr(props.Checked), html.EscapeString(props.Label))
+}
+
+// ModernTextField is a concrete product
+type ModernTextField struct{}
+
+// Render returns a string representation of the modern text field
+func (t *ModernTextField) Render() string {
+	return "Rendered a modern style text field"
+}
+
+// Type defines the text field's input behavior
+func (t *ModernTextField) Type(text string) string {
+	return fmt.Sprintf("Typed %q into modern text field with live validation", text)
+}
+
+// Family returns the theme of the modern text field
+func (t *ModernTextField) Family() string {
+	return "modern"
+}
+
+// HTML renders the modern text field with a floating label
+func (t *ModernTextField) HTML(props WidgetProps) string {
+	return fmt.Sprintf(`<div class="ui-textfield"><input type="text"%s placeholder="%s" value="%s"><label%s>%s</label></div>`,
+		idAttr(props.ID), html.EscapeString(props.Placeholder), html.EscapeString(props.Value),
+		forAttr(props.ID), html.EscapeString(props.Label))
+}
+
+// VintageTextField is a concrete product
+type VintageTextField struct{}
+
+// Render returns a string representation of the vintage text field
+func (t *VintageTextField) Render() string {
+	return "Rendered a vintage style text field"
+}
+
+// Type defines the text field's input behavior
+func (t *VintageTextField) Type(text string) string {
+	return fmt.Sprintf("Typed %q into vintage text field with a blinking block cursor", text)
+}
+
+// Family returns the theme of the vintage text field
+func (t *VintageTextField) Family() string {
+	return "vintage"
+}
+
+// HTML renders the vintage text field as a sunken box after its label
+func (t *VintageTextField) HTML(props WidgetProps) string {
+	return fmt.Sprintf(`<label class="ui-textfield"%s>%s: <input type="text"%s class="ui-sunken" placeholder="%s" value="%s"></label>`,
+		forAttr(props.ID), html.EscapeString(props.Label), idAttr(props.ID),
+		html.EscapeString(props.Placeholder), html.EscapeString(props.Value))
+}
+
+// ModernDialog is a concrete product
+type ModernDialog struct{}
+
+// Render returns a string representation of the modern dialog
+func (d *ModernDialog) Render() string {
+	return "Rendered a modern style dialog"
+}
+
+// Open defines the dialog's opening behavior
+func (d *ModernDialog) Open() string {
+	return "Modern dialog faded in over a blurred backdrop"
+}
+
+// Family returns the theme of the modern dialog
+func (d *ModernDialog) Family() string {
+	return "modern"
+}
+
+// HTML renders the modern dialog as a native dialog element
+func (d *ModernDialog) HTML(props WidgetProps) string {
+	return fmt.Sprintf(`<dialog%s class="ui-dialog" open><h2>%s</h2><p>%s</p><form method="dialog"><button class="ui-button">OK</button></form></dialog>`,
+		idAttr(props.ID), html.EscapeString(props.Label), html.EscapeString(props.Text))
+}
+
+// VintageDialog is a concrete product
+type VintageDialog struct{}
+
+// Render returns a string representation of the vintage dialog
+func (d *VintageDialog) Render() string {
+	return "Rendered a vintage style dialog"
+}
+
+// Open defines the dialog's opening behavior
+func (d *VintageDialog) Open() string {
+	return "Vintage dialog popped up with a system beep"
+}
+
+// Family returns the theme of the vintage dialog
+func (d *VintageDialog) Family() string {
+	return "vintage"
+}
+
+// HTML renders the vintage dialog as a window with a title bar
+func (d *VintageDialog) HTML(props WidgetProps) string {
+	return fmt.Sprintf(`<div%s class="ui-dialog" role="dialog"><div class="ui-titlebar">%s</div><div class="ui-dialog-body"><p>%s</p><button type="button" class="ui-button ui-bevel">OK</button></div></div>`,
+		idAttr(props.ID), html.EscapeString(props.Label), html.EscapeString(props.Text))
+}
+
+// ModernMenu is a concrete product
+type ModernMenu struct{}
+
+// Render returns a string representation of the modern menu
+func (m *ModernMenu) Render() string {
+	return "Rendered a modern style menu"
+}
+
+// Select defines the menu's selection behavior
+func (m *ModernMenu) Select(item string) string {
+	return fmt.Sprintf("Modern menu highlighted %q with an underline", item)
+}
+
+// Family returns the theme of the modern menu
+func (m *ModernMenu) Family() string {
+	return "modern"
+}
+
+// HTML renders the modern menu as a navigation bar
+func (m *ModernMenu) HTML(props WidgetProps) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(`<nav%s class="ui-menu" aria-label="%s"><ul role="menubar">`, idAttr(props.ID), html.EscapeString(props.Label)))
+	for _, item := range props.Items {
+		sb.WriteString(fmt.Sprintf(`<li role="none"><a role="menuitem" href="#">%s</a></li>`, html.EscapeString(item)))
+	}
+	sb.WriteString("</ul></nav>")
+	return sb.String()
+}
+
+// VintageMenu is a concrete product
+type VintageMenu struct{}
+
+// Render returns a string representation of the vintage menu
+func (m *VintageMenu) Render() string {
+	return "Rendered a vintage style menu"
+}
+
+// Select defines the menu's selection behavior
+func (m *VintageMenu) Select(item string) string {
+	return fmt.Sprintf("Vintage menu dropped down %q in inverted colors", item)
+}
+
+// Family returns the theme of the vintage menu
+func (m *VintageMenu) Family() string {
+	return "vintage"
+}
+
+// HTML renders the vintage menu as a menu bar with underlined access keys
+func (m *VintageMenu) HTML(props WidgetProps) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(`<div%s class="ui-menu" role="menubar" aria-label="%s">`, idAttr(props.ID), html.EscapeString(props.Label)))
+	for _, item := range props.Items {
+		runes := []rune(item)
+		if len(runes) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(`<span role="menuitem"><u>%s</u>%s</span>`,
+			html.EscapeString(string(runes[0])), html.EscapeString(string(runes[1:]))))
+	}
+	sb.WriteString("</div>")
+	return sb.String()
+}
+
 // ModernGUIFactory is a concrete factory implementing GUIFactory
 type ModernGUIFactory struct{}
 
@@ -83,6 +359,21 @@ func (f *ModernGUIFactory) CreateCheckbox() Checkbox {
 	return &ModernCheckbox{}
 }
 
+// CreateTextField creates a modern text field
+func (f *ModernGUIFactory) CreateTextField() TextField {
+	return &ModernTextField{}
+}
+
+// CreateDialog creates a modern dialog
+func (f *ModernGUIFactory) CreateDialog() Dialog {
+	return &ModernDialog{}
+}
+
+// CreateMenu creates a modern menu
+func (f *ModernGUIFactory) CreateMenu() Menu {
+	return &ModernMenu{}
+}
+
 // VintageGUIFactory is a concrete factory implementing GUIFactory
 type VintageGUIFactory struct{}
 
@@ -96,14 +387,27 @@ func (f *VintageGUIFactory) CreateCheckbox() Checkbox {
 	return &VintageCheckbox{}
 }
 
-// CreateGUIFactory returns a GUIFactory based on the style
+// CreateTextField creates a vintage text field
+func (f *VintageGUIFactory) CreateTextField() TextField {
+	return &VintageTextField{}
+}
+
+// CreateDialog creates a vintage dialog
+func (f *VintageGUIFactory) CreateDialog() Dialog {
+	return &VintageDialog{}
+}
+
+// CreateMenu creates a vintage menu
+func (f *VintageGUIFactory) CreateMenu() Menu {
+	return &VintageMenu{}
+}
+
+// CreateGUIFactory returns the GUIFactory of a theme registered in
+// DefaultRegistry, or the modern factory for unknown themes
 func CreateGUIFactory(style string) GUIFactory {
-	switch style {
-	case "modern":
-		return &ModernGUIFactory{}
-	case "vintage":
-		return &VintageGUIFactory{}
-	default:
+	factory, err := DefaultRegistry.Factory(style)
+	if err != nil {
 		return &ModernGUIFactory{} // Default to modern style
 	}
+	return factory
 }
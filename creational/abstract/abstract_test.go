@@ -1,6 +1,7 @@
 package abstract
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -131,3 +132,55 @@ func getButtonTypeName(v interface{}) string {
 		return "unknown"
 	}
 }
+
+func TestWidgetFamilies(t *testing.T) {
+	tests := []struct {
+		factory GUIFactory
+		family  string
+		typed   string
+		opened  string
+	}{
+		{&ModernGUIFactory{}, "modern", `Typed "hi" into modern text field with live validation`, "Modern dialog faded in over a blurred backdrop"},
+		{&VintageGUIFactory{}, "vintage", `Typed "hi" into vintage text field with a blinking block cursor`, "Vintage dialog popped up with a system beep"},
+	}
+
+	for _, test := range tests {
+		textField := test.factory.CreateTextField()
+		dialog := test.factory.CreateDialog()
+		menu := test.factory.CreateMenu()
+
+		for _, widget := range []Widget{test.factory.CreateButton(), test.factory.CreateCheckbox(), textField, dialog, menu} {
+			if widget.Family() != test.family {
+				t.Errorf("Expected %T to belong to %s, got %s", widget, test.family, widget.Family())
+			}
+		}
+		if result := textField.Type("hi"); result != test.typed {
+			t.Errorf("Expected type '%s', but got '%s'", test.typed, result)
+		}
+		if result := dialog.Open(); result != test.opened {
+			t.Errorf("Expected open '%s', but got '%s'", test.opened, result)
+		}
+		if result := menu.Select("File"); !strings.Contains(result, `"File"`) {
+			t.Errorf("Expected select to mention the item, got '%s'", result)
+		}
+	}
+}
+
+func TestApplication(t *testing.T) {
+	app := NewApplication(&VintageGUIFactory{})
+
+	rendered := app.RenderUI()
+	expected := []string{
+		"Rendered a vintage style menu",
+		"Rendered a vintage style text field",
+		"Rendered a vintage style checkbox",
+		"Rendered a vintage style button",
+		"Rendered a vintage style dialog",
+	}
+	if strings.Join(rendered, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected rendered UI %v, got %v", expected, rendered)
+	}
+	if actions := app.ExecuteActions(); len(actions) != 5 {
+		t.Errorf("Expected 5 actions, got %v", actions)
+	}
+}
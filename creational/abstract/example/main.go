@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/edgardnogueira/go-patterns/creational/abstract"
 )
 
@@ -43,6 +46,30 @@ func main() {
 		fmt.Println("  » " + result)
 	}
 
+	// Render each registered theme as an HTML page
+	if err := abstract.DefaultRegistry.CheckConsistency(); err != nil {
+		fmt.Println("\nInconsistent themes:", err)
+		return
+	}
+	outDir := filepath.Join(os.TempDir(), "abstract-factory")
+	fmt.Println("\nHTML pages:")
+	fmt.Println("-----------")
+	for _, name := range abstract.DefaultRegistry.Names() {
+		theme, _ := abstract.DefaultRegistry.Theme(name)
+		app := abstract.NewApplication(abstract.CreateGUIFactory(name))
+		ui, err := app.RenderHTML(theme, abstract.DefaultScreen())
+		if err != nil {
+			fmt.Println("  » " + err.Error())
+			continue
+		}
+		dir := filepath.Join(outDir, name)
+		if err := ui.WriteFiles(dir); err != nil {
+			fmt.Println("  » " + err.Error())
+			continue
+		}
+		fmt.Printf("  » %s: %s\n", name, filepath.Join(dir, "index.html"))
+	}
+
 	fmt.Println("\nThe client code works with factories and products through abstract interfaces,")
 	fmt.Println("so it doesn't matter which factory or product variant is used.")
 }
@@ -8,23 +8,32 @@ import (
 
 // Application represents a client using the GUI factories and products
 type Application struct {
-	button   Button
-	checkbox Checkbox
+	button    Button
+	checkbox  Checkbox
+	textField TextField
+	dialog    Dialog
+	menu      Menu
 }
 
 // NewApplication creates a new application with UI elements from the specified factory
 func NewApplication(factory GUIFactory) *Application {
 	return &Application{
-		button:   factory.CreateButton(),
-		checkbox: factory.CreateCheckbox(),
+		button:    factory.CreateButton(),
+		checkbox:  factory.CreateCheckbox(),
+		textField: factory.CreateTextField(),
+		dialog:    factory.CreateDialog(),
+		menu:      factory.CreateMenu(),
 	}
 }
 
 // RenderUI renders all UI components of the application
 func (a *Application) RenderUI() []string {
 	result := []string{
-		a.button.Render(),
+		a.menu.Render(),
+		a.textField.Render(),
 		a.checkbox.Render(),
+		a.button.Render(),
+		a.dialog.Render(),
 	}
 	return result
 }
@@ -32,8 +41,11 @@ func (a *Application) RenderUI() []string {
 // ExecuteActions simulates user interactions with UI components
 func (a *Application) ExecuteActions() []string {
 	result := []string{
-		a.button.OnClick(),
+		a.menu.Select("File"),
+		a.textField.Type("Gopher"),
 		a.checkbox.Toggle(),
+		a.button.OnClick(),
+		a.dialog.Open(),
 	}
 	return result
 }
@@ -0,0 +1,176 @@
+package abstract
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// ErrThemeNotFound is returned when no theme is registered under a name
+var ErrThemeNotFound = errors.New("theme not found")
+
+// FactoryConstructor creates the GUIFactory of a theme
+type FactoryConstructor func() GUIFactory
+
+// validThemeName restricts theme names to what can be used in CSS class
+// and file names
+var validThemeName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// ThemeRegistry maps theme names to their stylesheets and factories, so new
+// widget families can be added without changing CreateGUIFactory. It is
+// safe for concurrent use.
+type ThemeRegistry struct {
+	mu     sync.RWMutex
+	themes map[string]registeredTheme
+}
+
+// registeredTheme is a theme together with the constructor of its factory
+type registeredTheme struct {
+	theme      Theme
+	newFactory FactoryConstructor
+}
+
+// NewThemeRegistry creates an empty registry
+func NewThemeRegistry() *ThemeRegistry {
+	return &ThemeRegistry{themes: make(map[string]registeredTheme)}
+}
+
+// DefaultRegistry holds the built-in modern and vintage themes. Themes
+// registered here are available through CreateGUIFactory.
+var DefaultRegistry = newDefaultRegistry()
+
+// newDefaultRegistry registers the built-in themes
+func newDefaultRegistry() *ThemeRegistry {
+	r := NewThemeRegistry()
+	r.MustRegister(ModernTheme, func() GUIFactory { return &ModernGUIFactory{} })
+	r.MustRegister(VintageTheme, func() GUIFactory { return &VintageGUIFactory{} })
+	return r
+}
+
+// RegisterTheme registers a theme in DefaultRegistry
+func RegisterTheme(theme Theme, newFactory FactoryConstructor) error {
+	return DefaultRegistry.Register(theme, newFactory)
+}
+
+// Register adds a theme. Names must be lower case letters, digits and
+// hyphens, starting with a letter, and can only be registered once.
+func (r *ThemeRegistry) Register(theme Theme, newFactory FactoryConstructor) error {
+	if !validThemeName.MatchString(theme.Name) {
+		return fmt.Errorf("invalid theme name %q: use lower case letters, digits and hyphens", theme.Name)
+	}
+	if newFactory == nil {
+		return fmt.Errorf("theme %q: factory constructor is nil", theme.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.themes[theme.Name]; exists {
+		return fmt.Errorf("theme %q is already registered", theme.Name)
+	}
+	r.themes[theme.Name] = registeredTheme{theme: theme, newFactory: newFactory}
+	return nil
+}
+
+// MustRegister is like Register but panics on error. It is meant for
+// registering themes during package initialization.
+func (r *ThemeRegistry) MustRegister(theme Theme, newFactory FactoryConstructor) {
+	if err := r.Register(theme, newFactory); err != nil {
+		panic(err)
+	}
+}
+
+// Factory creates the GUIFactory of the named theme
+func (r *ThemeRegistry) Factory(name string) (GUIFactory, error) {
+	r.mu.RLock()
+	entry, ok := r.themes[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrThemeNotFound, name)
+	}
+	return entry.newFactory(), nil
+}
+
+// Theme returns the named theme
+func (r *ThemeRegistry) Theme(name string) (Theme, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("%w: %q", ErrThemeNotFound, name)
+	}
+	return entry.theme, nil
+}
+
+// Names returns the names of all registered themes in alphabetical order
+func (r *ThemeRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.themes))
+	for name := range r.themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// productKinds lists every product of a widget family with the CSS class
+// its markup must use
+var productKinds = []struct {
+	name   string
+	create func(GUIFactory) Widget
+}{
+	{"button", func(f GUIFactory) Widget { return f.CreateButton() }},
+	{"checkbox", func(f GUIFactory) Widget { return f.CreateCheckbox() }},
+	{"textfield", func(f GUIFactory) Widget { return f.CreateTextField() }},
+	{"dialog", func(f GUIFactory) Widget { return f.CreateDialog() }},
+	{"menu", func(f GUIFactory) Widget { return f.CreateMenu() }},
+}
+
+// CheckConsistency verifies that the factory of every registered theme
+// produces the whole family: each product must exist, belong to the
+// factory's theme, and render markup with the CSS class the theme's
+// stylesheet styles. All problems are reported together.
+func (r *ThemeRegistry) CheckConsistency() error {
+	var problems []error
+	for _, name := range r.Names() {
+		factory, err := r.Factory(name)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if isNil(factory) {
+			problems = append(problems, fmt.Errorf("theme %q: factory constructor returned nil", name))
+			continue
+		}
+
+		for _, kind := range productKinds {
+			widget := kind.create(factory)
+			if isNil(widget) {
+				problems = append(problems, fmt.Errorf("theme %q: factory does not create a %s", name, kind.name))
+				continue
+			}
+			if family := widget.Family(); family != name {
+				problems = append(problems, fmt.Errorf("theme %q: %s %T belongs to theme %q", name, kind.name, widget, family))
+			}
+			if widget.Render() == "" {
+				problems = append(problems, fmt.Errorf("theme %q: %s %T renders nothing", name, kind.name, widget))
+			}
+			if !hasCSSClass(widget.HTML(WidgetProps{Label: "check"}), "ui-"+kind.name) {
+				problems = append(problems, fmt.Errorf("theme %q: %s %T markup lacks the ui-%s class", name, kind.name, widget, kind.name))
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// isNil reports whether v is nil or an interface holding a nil pointer
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
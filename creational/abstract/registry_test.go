@@ -0,0 +1,100 @@
+package abstract
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// brokenFactory creates modern widgets, except for a missing menu and a
+// vintage dialog
+type brokenFactory struct{ ModernGUIFactory }
+
+func (f *brokenFactory) CreateDialog() Dialog { return &VintageDialog{} }
+func (f *brokenFactory) CreateMenu() Menu     { return nil }
+
+func TestThemeRegistry(t *testing.T) {
+	registry := NewThemeRegistry()
+	newFactory := func() GUIFactory { return &ModernGUIFactory{} }
+
+	if err := registry.Register(ModernTheme, newFactory); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := registry.Register(ModernTheme, newFactory); err == nil {
+		t.Error("Expected an error registering a theme twice")
+	}
+	for _, name := range []string{"", "Modern", "1st", "dark theme"} {
+		if err := registry.Register(Theme{Name: name}, newFactory); err == nil {
+			t.Errorf("Expected an error for theme name %q", name)
+		}
+	}
+	if err := registry.Register(Theme{Name: "dark"}, nil); err == nil {
+		t.Error("Expected an error for a nil constructor")
+	}
+
+	if _, err := registry.Factory("retro"); !errors.Is(err, ErrThemeNotFound) {
+		t.Errorf("Expected ErrThemeNotFound, got %v", err)
+	}
+	if _, err := registry.Theme("retro"); !errors.Is(err, ErrThemeNotFound) {
+		t.Errorf("Expected ErrThemeNotFound, got %v", err)
+	}
+	theme, err := registry.Theme("modern")
+	if err != nil || theme.Name != "modern" {
+		t.Errorf("Expected the modern theme, got %+v, %v", theme, err)
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	names := DefaultRegistry.Names()
+	if len(names) < 2 || names[0] != "modern" || names[1] != "vintage" {
+		t.Errorf("Expected the built-in themes in order, got %v", names)
+	}
+	if err := DefaultRegistry.CheckConsistency(); err != nil {
+		t.Errorf("Expected the default registry to be consistent, got: %v", err)
+	}
+}
+
+func TestRegisterThemeUsedByCreateGUIFactory(t *testing.T) {
+	custom := &VintageGUIFactory{}
+	err := RegisterTheme(Theme{Name: "test-sepia"}, func() GUIFactory { return custom })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if factory := CreateGUIFactory("test-sepia"); factory != custom {
+		t.Errorf("Expected CreateGUIFactory to use the registered factory, got %T", factory)
+	}
+}
+
+func TestCheckConsistencyReportsProblems(t *testing.T) {
+	registry := NewThemeRegistry()
+	registry.MustRegister(ModernTheme, func() GUIFactory { return &brokenFactory{} })
+	registry.MustRegister(Theme{Name: "empty"}, func() GUIFactory { return nil })
+
+	err := registry.CheckConsistency()
+	if err == nil {
+		t.Fatal("Expected consistency errors")
+	}
+	for _, problem := range []string{
+		`theme "empty": factory constructor returned nil`,
+		`theme "modern": dialog *abstract.VintageDialog belongs to theme "vintage"`,
+		`theme "modern": factory does not create a menu`,
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q in:\n%v", problem, err)
+		}
+	}
+}
+
+func TestHasCSSClass(t *testing.T) {
+	markup := `<div class="ui-dialog"><button class="ui-button ui-bevel">OK</button></div>`
+	for class, expected := range map[string]bool{
+		"ui-dialog": true,
+		"ui-bevel":  true,
+		"ui":        false,
+		"ui-menu":   false,
+	} {
+		if got := hasCSSClass(markup, class); got != expected {
+			t.Errorf("hasCSSClass(%q) = %v, expected %v", class, got, expected)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package abstract
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Screen is the content of an application's UI: a menu, a form with a text
+// field, a checkbox and a button, and a dialog
+type Screen struct {
+	Title         string
+	MenuItems     []string
+	FieldLabel    string
+	FieldValue    string
+	Placeholder   string
+	CheckboxLabel string
+	Checked       bool
+	ButtonLabel   string
+	DialogTitle   string
+	DialogText    string
+}
+
+// DefaultScreen returns sample content for rendering an application
+func DefaultScreen() Screen {
+	return Screen{
+		Title:         "Account Settings",
+		MenuItems:     []string{"File", "Edit", "View", "Help"},
+		FieldLabel:    "Display name",
+		Placeholder:   "Your name",
+		CheckboxLabel: "Send me email updates",
+		Checked:       true,
+		ButtonLabel:   "Save",
+		DialogTitle:   "Saved",
+		DialogText:    "Your settings have been saved.",
+	}
+}
+
+// RenderedUI is an HTML page and the stylesheet it links to
+type RenderedUI struct {
+	HTML    string
+	CSS     string
+	CSSFile string // File name the page links the stylesheet as
+}
+
+// WriteFiles writes the page as index.html and the stylesheet as CSSFile
+// into dir, creating dir if needed
+func (r RenderedUI) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(r.HTML), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, r.CSSFile), []byte(r.CSS), 0644)
+}
+
+// RenderHTML renders the application's widgets as a standalone HTML page
+// with the theme's stylesheet. The widgets must come from the theme's
+// family, otherwise the markup and the stylesheet would not match.
+func (a *Application) RenderHTML(theme Theme, screen Screen) (RenderedUI, error) {
+	for _, widget := range []Widget{a.menu, a.textField, a.checkbox, a.button, a.dialog} {
+		if family := widget.Family(); family != theme.Name {
+			return RenderedUI{}, fmt.Errorf("%T belongs to theme %q, not %q", widget, family, theme.Name)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n")
+	sb.WriteString("<html lang=\"en\">\n")
+	sb.WriteString("<head>\n")
+	sb.WriteString("  <meta charset=\"utf-8\">\n")
+	sb.WriteString(fmt.Sprintf("  <title>%s</title>\n", html.EscapeString(screen.Title)))
+	sb.WriteString(fmt.Sprintf("  <link rel=\"stylesheet\" href=\"%s\">\n", html.EscapeString(theme.StylesheetName())))
+	sb.WriteString("</head>\n")
+	sb.WriteString(fmt.Sprintf("<body class=\"%s\">\n", theme.ClassName()))
+	sb.WriteString("  <header>\n")
+	sb.WriteString("    " + a.menu.HTML(WidgetProps{ID: "main-menu", Label: "Main menu", Items: screen.MenuItems}) + "\n")
+	sb.WriteString("  </header>\n")
+	sb.WriteString("  <main>\n")
+	sb.WriteString(fmt.Sprintf("    <h1>%s</h1>\n", html.EscapeString(screen.Title)))
+	sb.WriteString("    <form class=\"ui-form\">\n")
+	sb.WriteString("      " + a.textField.HTML(WidgetProps{ID: "name", Label: screen.FieldLabel, Value: screen.FieldValue, Placeholder: screen.Placeholder}) + "\n")
+	sb.WriteString("      " + a.checkbox.HTML(WidgetProps{ID: "updates", Label: screen.CheckboxLabel, Checked: screen.Checked}) + "\n")
+	sb.WriteString("      " + a.button.HTML(WidgetProps{ID: "save", Label: screen.ButtonLabel}) + "\n")
+	sb.WriteString("    </form>\n")
+	sb.WriteString("    " + a.dialog.HTML(WidgetProps{ID: "saved", Label: screen.DialogTitle, Text: screen.DialogText}) + "\n")
+	sb.WriteString("  </main>\n")
+	sb.WriteString("</body>\n")
+	sb.WriteString("</html>\n")
+
+	return RenderedUI{HTML: sb.String(), CSS: theme.CSS(), CSSFile: theme.StylesheetName()}, nil
+}
+
+// classAttr matches class attributes in markup
+var classAttr = regexp.MustCompile(`class="([^"]*)"`)
+
+// hasCSSClass reports whether any element in markup has the given class
+func hasCSSClass(markup, class string) bool {
+	for _, match := range classAttr.FindAllStringSubmatch(markup, -1) {
+		for _, c := range strings.Fields(match[1]) {
+			if c == class {
+				return true
+			}
+		}
+	}
+	return false
+}
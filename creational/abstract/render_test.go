@@ -0,0 +1,83 @@
+package abstract
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	app := NewApplication(&ModernGUIFactory{})
+	screen := DefaultScreen()
+	screen.Title = "Settings & <Profile>"
+
+	ui, err := app.RenderHTML(ModernTheme, screen)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, fragment := range []string{
+		"<!DOCTYPE html>",
+		`<link rel="stylesheet" href="theme-modern.css">`,
+		`<body class="theme-modern">`,
+		"<title>Settings &amp; &lt;Profile&gt;</title>",
+		`<nav id="main-menu" class="ui-menu"`,
+		`<a role="menuitem" href="#">Help</a>`,
+		`<input type="checkbox" role="switch" id="updates" checked>`,
+		`<dialog id="saved" class="ui-dialog" open>`,
+	} {
+		if !strings.Contains(ui.HTML, fragment) {
+			t.Errorf("Expected HTML to contain %q, got:\n%s", fragment, ui.HTML)
+		}
+	}
+	if strings.Contains(ui.HTML, "<Profile>") {
+		t.Error("Expected the title to be escaped")
+	}
+	if ui.CSSFile != "theme-modern.css" || ui.CSS != ModernTheme.CSS() {
+		t.Errorf("Expected the modern stylesheet, got %q", ui.CSSFile)
+	}
+}
+
+func TestRenderHTMLThemeMismatch(t *testing.T) {
+	app := NewApplication(&VintageGUIFactory{})
+	if _, err := app.RenderHTML(ModernTheme, DefaultScreen()); err == nil {
+		t.Error("Expected an error rendering vintage widgets with the modern theme")
+	}
+}
+
+func TestThemeCSS(t *testing.T) {
+	css := VintageTheme.CSS()
+	for _, fragment := range []string{
+		".theme-vintage {\n  --ui-font:",
+		"--ui-background: #008080;",
+		".theme-vintage .ui-button {",
+		".theme-vintage .ui-titlebar {",
+	} {
+		if !strings.Contains(css, fragment) {
+			t.Errorf("Expected CSS to contain %q, got:\n%s", fragment, css)
+		}
+	}
+	if strings.Contains(css, "&") {
+		t.Error("Expected every & in ExtraCSS to be replaced")
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	ui, err := NewApplication(&VintageGUIFactory{}).RenderHTML(VintageTheme, DefaultScreen())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "site")
+	if err := ui.WriteFiles(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, expected := range map[string]string{"index.html": ui.HTML, "theme-vintage.css": ui.CSS} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Reading %s: %v", name, err)
+		}
+		if string(data) != expected {
+			t.Errorf("Unexpected contents of %s", name)
+		}
+	}
+}
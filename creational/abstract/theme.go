@@ -0,0 +1,136 @@
+package abstract
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Theme describes the look of a widget family. Its CSS method turns it into
+// the stylesheet that goes with the family's HTML markup.
+type Theme struct {
+	Name        string
+	Description string
+
+	Font       string
+	Background string
+	Surface    string // Background of dialogs, menus and inputs
+	Text       string
+	Accent     string // Background of buttons
+	AccentText string
+	Border     string
+	Radius     string
+	Shadow     string
+
+	// ExtraCSS holds rules for family-specific markup, such as the modern
+	// checkbox's switch. "&" is replaced by the theme's root selector.
+	ExtraCSS string
+}
+
+// ModernTheme is the flat, rounded look of the modern widgets
+var ModernTheme = Theme{
+	Name:        "modern",
+	Description: "Flat widgets with rounded corners and soft shadows",
+	Font:        `system-ui, -apple-system, "Segoe UI", sans-serif`,
+	Background:  "#f5f7fa",
+	Surface:     "#ffffff",
+	Text:        "#1f2933",
+	Accent:      "#3b82f6",
+	AccentText:  "#ffffff",
+	Border:      "#d2d6dc",
+	Radius:      "8px",
+	Shadow:      "0 4px 12px rgba(0, 0, 0, 0.12)",
+	ExtraCSS: `& .ui-switch input { appearance: none; width: 2.5em; height: 1.4em; border-radius: 1em; background: var(--ui-border); transition: background 0.2s; }
+& .ui-switch input:checked { background: var(--ui-accent); }
+& .ui-textfield { position: relative; display: flex; flex-direction: column-reverse; }
+& .ui-textfield label { font-size: 0.8em; color: var(--ui-accent); }
+& .ui-menu ul { display: flex; gap: 1.5em; list-style: none; margin: 0; padding: 0; }
+& .ui-menu a { color: inherit; text-decoration: none; }
+& .ui-menu a:hover { border-bottom: 2px solid var(--ui-accent); }
+`,
+}
+
+// VintageTheme is the bevelled, grey look of the vintage widgets
+var VintageTheme = Theme{
+	Name:        "vintage",
+	Description: "Bevelled widgets in the style of early desktop systems",
+	Font:        `"MS Sans Serif", Geneva, Tahoma, sans-serif`,
+	Background:  "#008080",
+	Surface:     "#c0c0c0",
+	Text:        "#000000",
+	Accent:      "#c0c0c0",
+	AccentText:  "#000000",
+	Border:      "#808080",
+	Radius:      "0",
+	Shadow:      "none",
+	ExtraCSS: `& .ui-bevel { border: 2px outset #ffffff; }
+& .ui-bevel:active { border-style: inset; }
+& .ui-sunken { border: 2px inset #ffffff; background: #ffffff; }
+& .ui-titlebar { background: #000080; color: #ffffff; font-weight: bold; padding: 2px 4px; }
+& .ui-dialog { padding: 0; border: 2px outset #ffffff; }
+& .ui-dialog-body { padding: 1em; }
+& .ui-menu span { margin-right: 1em; cursor: default; }
+& .ui-menu span:hover { background: #000080; color: #ffffff; }
+`,
+}
+
+// ClassName returns the CSS class that scopes the theme's rules
+func (t Theme) ClassName() string {
+	return "theme-" + t.Name
+}
+
+// StylesheetName returns the file name of the theme's stylesheet
+func (t Theme) StylesheetName() string {
+	return t.ClassName() + ".css"
+}
+
+// CSS returns the theme's stylesheet. All rules are scoped to the theme's
+// class, so several themes can be loaded on one page.
+func (t Theme) CSS() string {
+	root := "." + t.ClassName()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("/* Theme: %s", t.Name))
+	if t.Description != "" {
+		sb.WriteString(" - " + t.Description)
+	}
+	sb.WriteString(" */\n\n")
+
+	sb.WriteString(root + " {\n")
+	for _, v := range []struct{ name, value string }{
+		{"font", t.Font},
+		{"background", t.Background},
+		{"surface", t.Surface},
+		{"text", t.Text},
+		{"accent", t.Accent},
+		{"accent-text", t.AccentText},
+		{"border", t.Border},
+		{"radius", t.Radius},
+		{"shadow", t.Shadow},
+	} {
+		if v.value != "" {
+			sb.WriteString(fmt.Sprintf("  --ui-%s: %s;\n", v.name, v.value))
+		}
+	}
+	sb.WriteString("  font-family: var(--ui-font);\n")
+	sb.WriteString("  background: var(--ui-background);\n")
+	sb.WriteString("  color: var(--ui-text);\n")
+	sb.WriteString("}\n\n")
+
+	rules := []struct{ selector, body string }{
+		{".ui-button", "background: var(--ui-accent); color: var(--ui-accent-text); border: 1px solid var(--ui-border); border-radius: var(--ui-radius); padding: 0.5em 1.2em; cursor: pointer;"},
+		{".ui-checkbox", "display: inline-flex; align-items: center; gap: 0.5em; cursor: pointer;"},
+		{".ui-textfield", "display: block; margin: 0.5em 0;"},
+		{".ui-textfield input", "font: inherit; color: var(--ui-text); background: var(--ui-surface); border: 1px solid var(--ui-border); border-radius: var(--ui-radius); padding: 0.4em;"},
+		{".ui-dialog", "background: var(--ui-surface); border: 1px solid var(--ui-border); border-radius: var(--ui-radius); box-shadow: var(--ui-shadow); padding: 1em; max-width: 24em;"},
+		{".ui-menu", "background: var(--ui-surface); border-bottom: 1px solid var(--ui-border); padding: 0.5em 1em;"},
+	}
+	for _, rule := range rules {
+		sb.WriteString(fmt.Sprintf("%s %s { %s }\n", root, rule.selector, rule.body))
+	}
+
+	if t.ExtraCSS != "" {
+		sb.WriteString("\n")
+		sb.WriteString(strings.ReplaceAll(t.ExtraCSS, "&", root))
+	}
+	return sb.String()
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/edgardnogueira/go-patterns/creational/factory"
 )
 
@@ -21,6 +23,46 @@ func main() {
 	fmt.Println("---------------------------------------------------")
 	deliverProduct(roadLogistics)
 	deliverProduct(seaLogistics)
+
+	fmt.Println("\nPlanning multimodal routes:")
+	fmt.Println("---------------------------")
+	planRoutes()
+}
+
+// planRoutes plans the cheapest and fastest route for a shipment
+func planRoutes() {
+	network := factory.NewNetwork()
+	hubs := []struct {
+		name  string
+		types []factory.NodeType
+	}{
+		{"Shenzhen", []factory.NodeType{factory.Warehouse, factory.Port, factory.Airport}},
+		{"Rotterdam", []factory.NodeType{factory.Port, factory.RailTerminal}},
+		{"Frankfurt", []factory.NodeType{factory.Airport, factory.RailTerminal}},
+		{"Berlin", []factory.NodeType{factory.Warehouse, factory.RailTerminal}},
+	}
+	for _, hub := range hubs {
+		network.AddHub(hub.name, hub.types...)
+	}
+	network.Connect("Shenzhen", "Rotterdam", 18000, "sea")
+	network.Connect("Shenzhen", "Frankfurt", 9000, "air")
+	network.Connect("Rotterdam", "Berlin", 650, "road", "rail")
+	network.Connect("Frankfurt", "Berlin", 550, "road", "rail")
+	network.Connect("Rotterdam", "Frankfurt", 450, "road", "rail")
+
+	planner := factory.NewPlanner(network, factory.DefaultTransports())
+	planner.TransferCost = 150
+	planner.TransferTime = 4 * time.Hour
+
+	shipment := factory.Shipment{From: "Shenzhen", To: "Berlin", WeightKg: 5000}
+	for _, objective := range []factory.Objective{factory.Cheapest, factory.Fastest} {
+		itinerary, err := planner.Plan(shipment, objective)
+		if err != nil {
+			fmt.Println("Error:", err)
+			continue
+		}
+		fmt.Printf("\n%s route:\n%s", objective, itinerary)
+	}
 }
 
 // deliverProduct is a client function that works with any LogisticsService
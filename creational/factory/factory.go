@@ -3,6 +3,8 @@ package factory
 // Transport is the common interface for all types of transport
 type Transport interface {
 	Deliver() string
+	// Specs describes what the transport can carry, how fast and at what cost
+	Specs() TransportSpecs
 }
 
 // Truck is a concrete implementation of Transport
@@ -13,6 +15,18 @@ func (t *Truck) Deliver() string {
 	return "Delivering by land in a truck"
 }
 
+// Specs implements the Transport interface for Truck. Trucks are slow and
+// expensive per kilometre, but reach every kind of hub.
+func (t *Truck) Specs() TransportSpecs {
+	return TransportSpecs{
+		Mode:       "road",
+		SpeedKmh:   70,
+		CapacityKg: 24000,
+		CostPerKm:  1.5,
+		Serves:     []NodeType{Warehouse, Port, Airport, RailTerminal},
+	}
+}
+
 // Ship is a concrete implementation of Transport
 type Ship struct{}
 
@@ -21,6 +35,55 @@ func (s *Ship) Deliver() string {
 	return "Delivering by sea in a ship"
 }
 
+// Specs implements the Transport interface for Ship
+func (s *Ship) Specs() TransportSpecs {
+	return TransportSpecs{
+		Mode:       "sea",
+		SpeedKmh:   30,
+		CapacityKg: 50000000,
+		CostPerKm:  0.4,
+		Serves:     []NodeType{Port},
+	}
+}
+
+// Plane is a concrete implementation of Transport
+type Plane struct{}
+
+// Deliver implements the Transport interface for Plane
+func (p *Plane) Deliver() string {
+	return "Delivering by air in a plane"
+}
+
+// Specs implements the Transport interface for Plane
+func (p *Plane) Specs() TransportSpecs {
+	return TransportSpecs{
+		Mode:       "air",
+		SpeedKmh:   800,
+		CapacityKg: 100000,
+		CostPerKm:  8,
+		Serves:     []NodeType{Airport},
+	}
+}
+
+// Train is a concrete implementation of Transport
+type Train struct{}
+
+// Deliver implements the Transport interface for Train
+func (t *Train) Deliver() string {
+	return "Delivering by rail in a train"
+}
+
+// Specs implements the Transport interface for Train
+func (t *Train) Specs() TransportSpecs {
+	return TransportSpecs{
+		Mode:       "rail",
+		SpeedKmh:   90,
+		CapacityKg: 2000000,
+		CostPerKm:  0.8,
+		Serves:     []NodeType{RailTerminal},
+	}
+}
+
 // LogisticsService is the creator interface
 type LogisticsService interface {
 	CreateTransport() Transport
@@ -55,6 +118,34 @@ func (s *SeaLogistics) PlanDelivery() string {
 	return "Sea logistics: " + transport.Deliver()
 }
 
+// AirLogistics is a concrete creator implementing LogisticsService
+type AirLogistics struct{}
+
+// CreateTransport implements the factory method for AirLogistics
+func (a *AirLogistics) CreateTransport() Transport {
+	return &Plane{}
+}
+
+// PlanDelivery uses the factory method to create and use a transport
+func (a *AirLogistics) PlanDelivery() string {
+	transport := a.CreateTransport()
+	return "Air logistics: " + transport.Deliver()
+}
+
+// RailLogistics is a concrete creator implementing LogisticsService
+type RailLogistics struct{}
+
+// CreateTransport implements the factory method for RailLogistics
+func (r *RailLogistics) CreateTransport() Transport {
+	return &Train{}
+}
+
+// PlanDelivery uses the factory method to create and use a transport
+func (r *RailLogistics) PlanDelivery() string {
+	transport := r.CreateTransport()
+	return "Rail logistics: " + transport.Deliver()
+}
+
 // CreateLogistics is a function to create different logistics services based on type
 func CreateLogistics(logisticsType string) LogisticsService {
 	switch logisticsType {
@@ -62,6 +153,10 @@ func CreateLogistics(logisticsType string) LogisticsService {
 		return &RoadLogistics{}
 	case "sea":
 		return &SeaLogistics{}
+	case "air":
+		return &AirLogistics{}
+	case "rail":
+		return &RailLogistics{}
 	default:
 		return &RoadLogistics{} // Default to road logistics
 	}
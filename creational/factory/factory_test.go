@@ -50,6 +50,22 @@ func TestSeaLogistics(t *testing.T) {
 	}
 }
 
+func TestAirAndRailLogistics(t *testing.T) {
+	tests := []struct {
+		logistics LogisticsService
+		expected  string
+	}{
+		{&AirLogistics{}, "Air logistics: Delivering by air in a plane"},
+		{&RailLogistics{}, "Rail logistics: Delivering by rail in a train"},
+	}
+
+	for _, test := range tests {
+		if result := test.logistics.PlanDelivery(); result != test.expected {
+			t.Errorf("Expected '%s', but got '%s'", test.expected, result)
+		}
+	}
+}
+
 func TestCreateLogistics(t *testing.T) {
 	tests := []struct {
 		logisticsType string
@@ -57,6 +73,8 @@ func TestCreateLogistics(t *testing.T) {
 	}{
 		{"road", "*factory.RoadLogistics"},
 		{"sea", "*factory.SeaLogistics"},
+		{"air", "*factory.AirLogistics"},
+		{"rail", "*factory.RailLogistics"},
 		{"unknown", "*factory.RoadLogistics"}, // Default case
 	}
 
@@ -77,6 +95,10 @@ func getTypeName(v interface{}) string {
 		return "*factory.RoadLogistics"
 	case *SeaLogistics:
 		return "*factory.SeaLogistics"
+	case *AirLogistics:
+		return "*factory.AirLogistics"
+	case *RailLogistics:
+		return "*factory.RailLogistics"
 	default:
 		return "unknown"
 	}
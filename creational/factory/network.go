@@ -0,0 +1,128 @@
+package factory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Hub is a node of a logistics network
+type Hub struct {
+	Name  string     `json:"name"`
+	Types []NodeType `json:"types"`
+}
+
+// Link is a connection between two hubs that some transport modes can
+// travel along, such as a motorway, a shipping lane or a flight route
+type Link struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	DistanceKm float64  `json:"distance_km"`
+	Modes      []string `json:"modes"`
+}
+
+// Network is a graph of hubs connected by links. Links are bidirectional.
+type Network struct {
+	hubs  map[string]Hub
+	links map[string][]Link // Outgoing links by hub name
+}
+
+// NewNetwork creates an empty network
+func NewNetwork() *Network {
+	return &Network{
+		hubs:  make(map[string]Hub),
+		links: make(map[string][]Link),
+	}
+}
+
+// AddHub adds a hub of the given types
+func (n *Network) AddHub(name string, types ...NodeType) error {
+	if name == "" {
+		return fmt.Errorf("hub name must not be empty")
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("hub %q has no types", name)
+	}
+	if _, exists := n.hubs[name]; exists {
+		return fmt.Errorf("hub %q already exists", name)
+	}
+	n.hubs[name] = Hub{Name: name, Types: types}
+	return nil
+}
+
+// Connect links two hubs in both directions for the given transport modes
+func (n *Network) Connect(from, to string, distanceKm float64, modes ...string) error {
+	for _, name := range []string{from, to} {
+		if _, ok := n.hubs[name]; !ok {
+			return fmt.Errorf("unknown hub %q", name)
+		}
+	}
+	if from == to {
+		return fmt.Errorf("cannot connect hub %q to itself", from)
+	}
+	if distanceKm <= 0 {
+		return fmt.Errorf("link %s-%s: distance must be positive", from, to)
+	}
+	if len(modes) == 0 {
+		return fmt.Errorf("link %s-%s has no transport modes", from, to)
+	}
+	n.links[from] = append(n.links[from], Link{From: from, To: to, DistanceKm: distanceKm, Modes: modes})
+	n.links[to] = append(n.links[to], Link{From: to, To: from, DistanceKm: distanceKm, Modes: modes})
+	return nil
+}
+
+// Hub returns the named hub
+func (n *Network) Hub(name string) (Hub, bool) {
+	hub, ok := n.hubs[name]
+	return hub, ok
+}
+
+// Hubs returns all hubs ordered by name
+func (n *Network) Hubs() []Hub {
+	hubs := make([]Hub, 0, len(n.hubs))
+	for _, hub := range n.hubs {
+		hubs = append(hubs, hub)
+	}
+	sort.Slice(hubs, func(i, j int) bool { return hubs[i].Name < hubs[j].Name })
+	return hubs
+}
+
+// Links returns the links leaving a hub
+func (n *Network) Links(hub string) []Link {
+	return n.links[hub]
+}
+
+// networkConfig is the JSON form of a network
+type networkConfig struct {
+	Hubs  []Hub  `json:"hubs"`
+	Links []Link `json:"links"`
+}
+
+// LoadNetwork reads a network from JSON of the form
+//
+//	{
+//	  "hubs": [{"name": "Rotterdam", "types": ["port", "rail-terminal"]}, ...],
+//	  "links": [{"from": "Rotterdam", "to": "Duisburg", "distance_km": 230, "modes": ["road", "rail"]}, ...]
+//	}
+func LoadNetwork(r io.Reader) (*Network, error) {
+	var config networkConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("decoding network: %w", err)
+	}
+
+	network := NewNetwork()
+	for _, hub := range config.Hubs {
+		if err := network.AddHub(hub.Name, hub.Types...); err != nil {
+			return nil, err
+		}
+	}
+	for _, link := range config.Links {
+		if err := network.Connect(link.From, link.To, link.DistanceKm, link.Modes...); err != nil {
+			return nil, err
+		}
+	}
+	return network, nil
+}
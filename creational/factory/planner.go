@@ -0,0 +1,290 @@
+package factory
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Objective is what the planner minimizes
+type Objective int
+
+const (
+	// Cheapest minimizes the total cost
+	Cheapest Objective = iota
+	// Fastest minimizes the total travel time
+	Fastest
+)
+
+// String returns the name of the objective
+func (o Objective) String() string {
+	switch o {
+	case Cheapest:
+		return "cheapest"
+	case Fastest:
+		return "fastest"
+	default:
+		return fmt.Sprintf("Objective(%d)", int(o))
+	}
+}
+
+// ErrNoRoute is returned when no transports can carry a shipment to its
+// destination
+var ErrNoRoute = errors.New("no route")
+
+// Shipment is cargo to be moved between two hubs
+type Shipment struct {
+	From     string
+	To       string
+	WeightKg float64
+}
+
+// Leg is one link of an itinerary travelled by one transport
+type Leg struct {
+	From       string
+	To         string
+	Mode       string
+	Transport  Transport // Created by the mode's logistics service for this leg
+	DistanceKm float64
+	Duration   time.Duration
+	Cost       float64
+}
+
+// String describes the leg
+func (l Leg) String() string {
+	return fmt.Sprintf("%s -> %s (%.0f km): %s, %s, cost %.2f",
+		l.From, l.To, l.DistanceKm, l.Transport.Deliver(), l.Duration.Round(time.Minute), l.Cost)
+}
+
+// Itinerary is a planned route with its totals. Duration and Cost include
+// the transfers between modes.
+type Itinerary struct {
+	Shipment   Shipment
+	Legs       []Leg
+	Transfers  int
+	DistanceKm float64
+	Duration   time.Duration
+	Cost       float64
+}
+
+// Modes returns the modes used along the route, without repeating a mode
+// for consecutive legs
+func (it *Itinerary) Modes() []string {
+	var modes []string
+	for _, leg := range it.Legs {
+		if len(modes) == 0 || modes[len(modes)-1] != leg.Mode {
+			modes = append(modes, leg.Mode)
+		}
+	}
+	return modes
+}
+
+// String describes the itinerary leg by leg
+func (it *Itinerary) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s to %s, %.0f kg\n", it.Shipment.From, it.Shipment.To, it.Shipment.WeightKg))
+	for i, leg := range it.Legs {
+		sb.WriteString(fmt.Sprintf("  %d. %s\n", i+1, leg))
+	}
+	sb.WriteString(fmt.Sprintf("Total: %.0f km, %s, cost %.2f, %d transfer(s)\n",
+		it.DistanceKm, it.Duration.Round(time.Minute), it.Cost, it.Transfers))
+	return sb.String()
+}
+
+// Planner finds multimodal routes through a network. A transport mode can
+// travel a link if the link allows the mode, the transport serves both
+// hubs and it can carry the shipment's weight.
+type Planner struct {
+	Network    *Network
+	Transports *TransportRegistry
+
+	// TransferCost and TransferTime are added whenever the cargo changes
+	// from one mode to another at a hub
+	TransferCost float64
+	TransferTime time.Duration
+}
+
+// NewPlanner creates a planner for the network using the given transports
+func NewPlanner(network *Network, transports *TransportRegistry) *Planner {
+	return &Planner{Network: network, Transports: transports}
+}
+
+// planState is a hub reached with a mode. Arriving by a different mode
+// changes the cost of leaving, so the same hub is a different state per mode.
+type planState struct {
+	hub  string
+	mode string
+}
+
+// planStep is how a state was reached
+type planStep struct {
+	from planState
+	link Link
+}
+
+// planCost is the cost and time of reaching a state
+type planCost struct {
+	cost     float64
+	duration time.Duration
+}
+
+// less compares costs by the objective, breaking ties with the other measure
+func (c planCost) less(other planCost, objective Objective) bool {
+	if objective == Fastest {
+		if c.duration != other.duration {
+			return c.duration < other.duration
+		}
+		return c.cost < other.cost
+	}
+	if c.cost != other.cost {
+		return c.cost < other.cost
+	}
+	return c.duration < other.duration
+}
+
+// Plan finds the cheapest or fastest route for the shipment using
+// Dijkstra's algorithm. Each leg of the itinerary gets its own transport
+// from the factory of its mode.
+func (p *Planner) Plan(shipment Shipment, objective Objective) (*Itinerary, error) {
+	for _, name := range []string{shipment.From, shipment.To} {
+		if _, ok := p.Network.Hub(name); !ok {
+			return nil, fmt.Errorf("unknown hub %q", name)
+		}
+	}
+	if shipment.WeightKg < 0 {
+		return nil, fmt.Errorf("shipment weight must not be negative")
+	}
+
+	specs := make(map[string]TransportSpecs)
+	for _, mode := range p.Transports.Modes() {
+		s, err := p.Transports.Specs(mode)
+		if err != nil {
+			return nil, err
+		}
+		specs[mode] = s
+	}
+
+	start := planState{hub: shipment.From}
+	best := map[planState]planCost{start: {}}
+	steps := make(map[planState]planStep)
+	done := make(map[planState]bool)
+	queue := &planQueue{objective: objective}
+	heap.Push(queue, planItem{state: start})
+
+	var end *planState
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(planItem)
+		if done[item.state] {
+			continue
+		}
+		done[item.state] = true
+		if item.state.hub == shipment.To {
+			end = &item.state
+			break
+		}
+
+		from, _ := p.Network.Hub(item.state.hub)
+		for _, link := range p.Network.Links(item.state.hub) {
+			to, _ := p.Network.Hub(link.To)
+			for _, mode := range link.Modes {
+				s, ok := specs[mode]
+				if !ok || s.CapacityKg < shipment.WeightKg || !servesHub(s, from) || !servesHub(s, to) {
+					continue
+				}
+
+				cost := item.cost
+				cost.cost += link.DistanceKm * s.CostPerKm
+				cost.duration += legDuration(link.DistanceKm, s.SpeedKmh)
+				if item.state.mode != "" && item.state.mode != mode {
+					cost.cost += p.TransferCost
+					cost.duration += p.TransferTime
+				}
+
+				next := planState{hub: link.To, mode: mode}
+				if previous, seen := best[next]; seen && !cost.less(previous, objective) {
+					continue
+				}
+				best[next] = cost
+				steps[next] = planStep{from: item.state, link: link}
+				heap.Push(queue, planItem{state: next, cost: cost})
+			}
+		}
+	}
+	if end == nil {
+		return nil, fmt.Errorf("%w from %q to %q for %.0f kg", ErrNoRoute, shipment.From, shipment.To, shipment.WeightKg)
+	}
+
+	var path []planState
+	for state := *end; state != start; state = steps[state].from {
+		path = append(path, state)
+	}
+
+	itinerary := &Itinerary{Shipment: shipment}
+	for i := len(path) - 1; i >= 0; i-- {
+		state := path[i]
+		step := steps[state]
+		transport, err := p.Transports.CreateTransport(state.mode)
+		if err != nil {
+			return nil, err
+		}
+		s := specs[state.mode]
+		leg := Leg{
+			From:       step.link.From,
+			To:         step.link.To,
+			Mode:       state.mode,
+			Transport:  transport,
+			DistanceKm: step.link.DistanceKm,
+			Duration:   legDuration(step.link.DistanceKm, s.SpeedKmh),
+			Cost:       step.link.DistanceKm * s.CostPerKm,
+		}
+		if step.from.mode != "" && step.from.mode != state.mode {
+			itinerary.Transfers++
+		}
+		itinerary.Legs = append(itinerary.Legs, leg)
+		itinerary.DistanceKm += leg.DistanceKm
+	}
+	total := best[*end]
+	itinerary.Cost = total.cost
+	itinerary.Duration = total.duration
+	return itinerary, nil
+}
+
+// servesHub reports whether a transport can load and unload at any of the
+// hub's types
+func servesHub(specs TransportSpecs, hub Hub) bool {
+	for _, t := range hub.Types {
+		if specs.CanServe(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// legDuration returns the time to travel a distance at a speed
+func legDuration(distanceKm, speedKmh float64) time.Duration {
+	return time.Duration(distanceKm / speedKmh * float64(time.Hour))
+}
+
+// planItem is a state waiting in the planner's priority queue
+type planItem struct {
+	state planState
+	cost  planCost
+}
+
+// planQueue is a priority queue of states ordered by the objective
+type planQueue struct {
+	items     []planItem
+	objective Objective
+}
+
+func (q *planQueue) Len() int           { return len(q.items) }
+func (q *planQueue) Less(i, j int) bool { return q.items[i].cost.less(q.items[j].cost, q.objective) }
+func (q *planQueue) Swap(i, j int)      { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *planQueue) Push(x interface{}) { q.items = append(q.items, x.(planItem)) }
+func (q *planQueue) Pop() interface{} {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
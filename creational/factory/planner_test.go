@@ -0,0 +1,178 @@
+package factory
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNetwork connects a factory in Shenzhen with a customer in Berlin by
+// sea and rail through Rotterdam, or by air and rail through Frankfurt
+func testNetwork(t *testing.T) *Network {
+	t.Helper()
+	network, err := LoadNetwork(strings.NewReader(`{
+		"hubs": [
+			{"name": "Shenzhen", "types": ["warehouse", "port", "airport"]},
+			{"name": "Rotterdam", "types": ["port", "rail-terminal"]},
+			{"name": "Frankfurt", "types": ["airport", "rail-terminal"]},
+			{"name": "Berlin", "types": ["warehouse", "rail-terminal"]}
+		],
+		"links": [
+			{"from": "Shenzhen", "to": "Rotterdam", "distance_km": 18000, "modes": ["sea"]},
+			{"from": "Shenzhen", "to": "Frankfurt", "distance_km": 9000, "modes": ["air"]},
+			{"from": "Rotterdam", "to": "Berlin", "distance_km": 650, "modes": ["road", "rail"]},
+			{"from": "Frankfurt", "to": "Berlin", "distance_km": 550, "modes": ["road", "rail"]},
+			{"from": "Rotterdam", "to": "Frankfurt", "distance_km": 450, "modes": ["road", "rail"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error loading network: %v", err)
+	}
+	return network
+}
+
+// legSummary returns the legs of an itinerary as "From-To:mode"
+func legSummary(it *Itinerary) string {
+	var legs []string
+	for _, leg := range it.Legs {
+		legs = append(legs, leg.From+"-"+leg.To+":"+leg.Mode)
+	}
+	return strings.Join(legs, ", ")
+}
+
+func TestPlanCheapest(t *testing.T) {
+	planner := NewPlanner(testNetwork(t), DefaultTransports())
+	planner.TransferCost = 100
+	planner.TransferTime = 2 * time.Hour
+
+	it, err := planner.Plan(Shipment{From: "Shenzhen", To: "Berlin", WeightKg: 5000}, Cheapest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := legSummary(it); got != "Shenzhen-Rotterdam:sea, Rotterdam-Berlin:rail" {
+		t.Errorf("Unexpected route: %s", got)
+	}
+	if it.Cost != 18000*0.4+650*0.8+100 {
+		t.Errorf("Expected cost 7820, got %.2f", it.Cost)
+	}
+	if it.Transfers != 1 || it.DistanceKm != 18650 {
+		t.Errorf("Expected 1 transfer over 18650 km, got %d over %.0f km", it.Transfers, it.DistanceKm)
+	}
+
+	if _, ok := it.Legs[0].Transport.(*Ship); !ok {
+		t.Errorf("Expected the first leg to use a Ship, got %T", it.Legs[0].Transport)
+	}
+	if _, ok := it.Legs[1].Transport.(*Train); !ok {
+		t.Errorf("Expected the second leg to use a Train, got %T", it.Legs[1].Transport)
+	}
+}
+
+func TestPlanFastest(t *testing.T) {
+	planner := NewPlanner(testNetwork(t), DefaultTransports())
+	planner.TransferTime = 2 * time.Hour
+
+	it, err := planner.Plan(Shipment{From: "Shenzhen", To: "Berlin", WeightKg: 5000}, Fastest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := legSummary(it); got != "Shenzhen-Frankfurt:air, Frankfurt-Berlin:rail" {
+		t.Errorf("Unexpected route: %s", got)
+	}
+	expected := legDuration(9000, 800) + legDuration(550, 90) + 2*time.Hour
+	if it.Duration != expected {
+		t.Errorf("Expected %s, got %s", expected, it.Duration)
+	}
+	if modes := strings.Join(it.Modes(), ","); modes != "air,rail" {
+		t.Errorf("Expected modes air,rail, got %s", modes)
+	}
+}
+
+func TestPlanRespectsCapacity(t *testing.T) {
+	planner := NewPlanner(testNetwork(t), DefaultTransports())
+
+	// Too heavy for a plane, so the fastest route goes by sea
+	it, err := planner.Plan(Shipment{From: "Shenzhen", To: "Berlin", WeightKg: 500000}, Fastest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := legSummary(it); got != "Shenzhen-Rotterdam:sea, Rotterdam-Berlin:rail" {
+		t.Errorf("Unexpected route: %s", got)
+	}
+
+	// Too heavy for a train as well
+	if _, err := planner.Plan(Shipment{From: "Shenzhen", To: "Berlin", WeightKg: 5000000}, Cheapest); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("Expected ErrNoRoute, got %v", err)
+	}
+}
+
+func TestPlanTransfersBetweenStatesOfSameHub(t *testing.T) {
+	// Reaching Rotterdam by road is cheaper overall than by rail when the
+	// next leg is by road too, because it avoids a transfer
+	network := NewNetwork()
+	for _, err := range []error{
+		network.AddHub("A", Warehouse, RailTerminal),
+		network.AddHub("B", Warehouse, RailTerminal),
+		network.AddHub("C", Warehouse),
+		network.Connect("A", "B", 100, "road", "rail"),
+		network.Connect("B", "C", 10, "road"),
+	} {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	planner := NewPlanner(network, DefaultTransports())
+	planner.TransferCost = 1000
+
+	it, err := planner.Plan(Shipment{From: "A", To: "C", WeightKg: 100}, Cheapest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := legSummary(it); got != "A-B:road, B-C:road" || it.Transfers != 0 {
+		t.Errorf("Expected road all the way without transfers, got %s with %d transfers", got, it.Transfers)
+	}
+
+	planner.TransferCost = 0
+	it, _ = planner.Plan(Shipment{From: "A", To: "C", WeightKg: 100}, Cheapest)
+	if got := legSummary(it); got != "A-B:rail, B-C:road" || it.Transfers != 1 {
+		t.Errorf("Expected rail then road, got %s with %d transfers", got, it.Transfers)
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	network := testNetwork(t)
+	if err := network.AddHub("Island", Airport); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.Connect("Rotterdam", "Island", 300, "sea"); err != nil {
+		t.Fatal(err)
+	}
+	planner := NewPlanner(network, DefaultTransports())
+
+	// Ships cannot unload at an airport
+	if _, err := planner.Plan(Shipment{From: "Berlin", To: "Island"}, Cheapest); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("Expected ErrNoRoute, got %v", err)
+	}
+	if _, err := planner.Plan(Shipment{From: "Berlin", To: "Atlantis"}, Cheapest); err == nil {
+		t.Error("Expected an error for an unknown hub")
+	}
+
+	it, err := planner.Plan(Shipment{From: "Berlin", To: "Berlin"}, Cheapest)
+	if err != nil || len(it.Legs) != 0 {
+		t.Errorf("Expected an empty itinerary, got %v, %v", it, err)
+	}
+}
+
+func TestItineraryString(t *testing.T) {
+	planner := NewPlanner(testNetwork(t), DefaultTransports())
+	it, err := planner.Plan(Shipment{From: "Frankfurt", To: "Berlin", WeightKg: 1000}, Fastest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "Frankfurt to Berlin, 1000 kg\n" +
+		"  1. Frankfurt -> Berlin (550 km): Delivering by rail in a train, 6h7m0s, cost 440.00\n" +
+		"Total: 550 km, 6h7m0s, cost 440.00, 0 transfer(s)\n"
+	if got := it.String(); got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
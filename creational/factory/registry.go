@@ -0,0 +1,129 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NodeType is a kind of hub a transport can load and unload at
+type NodeType string
+
+// Hub types
+const (
+	Warehouse    NodeType = "warehouse"
+	Port         NodeType = "port"
+	Airport      NodeType = "airport"
+	RailTerminal NodeType = "rail-terminal"
+)
+
+// TransportSpecs describes the capabilities of a transport
+type TransportSpecs struct {
+	Mode       string     // Name the transport is registered and routed under, e.g. "road"
+	SpeedKmh   float64    // Average speed including stops
+	CapacityKg float64    // Heaviest shipment a single vehicle can carry
+	CostPerKm  float64    // Cost of moving one vehicle one kilometre
+	Serves     []NodeType // Hubs the transport can load and unload at
+}
+
+// CanServe reports whether the transport can load and unload at a hub of
+// the given type
+func (s TransportSpecs) CanServe(nodeType NodeType) bool {
+	for _, t := range s.Serves {
+		if t == nodeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnknownMode is returned when no transport is registered for a mode
+var ErrUnknownMode = errors.New("unknown transport mode")
+
+// TransportRegistry maps transport modes to the logistics services whose
+// factory method creates them, so new kinds of transport can be added
+// without changing the planner. It is safe for concurrent use.
+type TransportRegistry struct {
+	mu        sync.RWMutex
+	logistics map[string]LogisticsService
+}
+
+// NewTransportRegistry creates an empty registry
+func NewTransportRegistry() *TransportRegistry {
+	return &TransportRegistry{logistics: make(map[string]LogisticsService)}
+}
+
+// DefaultTransports creates a registry with trucks, ships, planes and trains
+func DefaultTransports() *TransportRegistry {
+	r := NewTransportRegistry()
+	for _, logistics := range []LogisticsService{&RoadLogistics{}, &SeaLogistics{}, &AirLogistics{}, &RailLogistics{}} {
+		if err := r.Register(logistics); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds a logistics service under the mode of the transport it
+// creates. The transport's specs must be usable for planning.
+func (r *TransportRegistry) Register(logistics LogisticsService) error {
+	transport := logistics.CreateTransport()
+	if transport == nil {
+		return fmt.Errorf("%T creates no transport", logistics)
+	}
+	specs := transport.Specs()
+	switch {
+	case specs.Mode == "":
+		return fmt.Errorf("%T has no mode", transport)
+	case specs.SpeedKmh <= 0:
+		return fmt.Errorf("transport mode %q: speed must be positive", specs.Mode)
+	case specs.CapacityKg <= 0:
+		return fmt.Errorf("transport mode %q: capacity must be positive", specs.Mode)
+	case specs.CostPerKm < 0:
+		return fmt.Errorf("transport mode %q: cost per km must not be negative", specs.Mode)
+	case len(specs.Serves) == 0:
+		return fmt.Errorf("transport mode %q serves no hubs", specs.Mode)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.logistics[specs.Mode]; exists {
+		return fmt.Errorf("transport mode %q is already registered", specs.Mode)
+	}
+	r.logistics[specs.Mode] = logistics
+	return nil
+}
+
+// CreateTransport creates a transport for the mode using the registered
+// logistics service's factory method
+func (r *TransportRegistry) CreateTransport(mode string) (Transport, error) {
+	r.mu.RLock()
+	logistics, ok := r.logistics[mode]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMode, mode)
+	}
+	return logistics.CreateTransport(), nil
+}
+
+// Specs returns the specs of the transport registered for the mode
+func (r *TransportRegistry) Specs(mode string) (TransportSpecs, error) {
+	transport, err := r.CreateTransport(mode)
+	if err != nil {
+		return TransportSpecs{}, err
+	}
+	return transport.Specs(), nil
+}
+
+// Modes returns the registered modes in alphabetical order
+func (r *TransportRegistry) Modes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	modes := make([]string, 0, len(r.logistics))
+	for mode := range r.logistics {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	return modes
+}
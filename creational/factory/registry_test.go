@@ -0,0 +1,112 @@
+package factory
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Drone is a transport for short hops between warehouses
+type Drone struct{}
+
+func (d *Drone) Deliver() string { return "Delivering by air in a drone" }
+func (d *Drone) Specs() TransportSpecs {
+	return TransportSpecs{Mode: "drone", SpeedKmh: 60, CapacityKg: 5, CostPerKm: 0.1, Serves: []NodeType{Warehouse}}
+}
+
+// DroneLogistics creates drones
+type DroneLogistics struct{}
+
+func (d *DroneLogistics) CreateTransport() Transport { return &Drone{} }
+func (d *DroneLogistics) PlanDelivery() string {
+	return "Drone logistics: " + d.CreateTransport().Deliver()
+}
+
+// brokenLogistics creates a transport without a speed
+type brokenLogistics struct{ DroneLogistics }
+
+type slowDrone struct{ Drone }
+
+func (d *slowDrone) Specs() TransportSpecs {
+	specs := d.Drone.Specs()
+	specs.SpeedKmh = 0
+	return specs
+}
+
+func (b *brokenLogistics) CreateTransport() Transport { return &slowDrone{} }
+
+func TestTransportRegistry(t *testing.T) {
+	registry := DefaultTransports()
+	if modes := strings.Join(registry.Modes(), ","); modes != "air,rail,road,sea" {
+		t.Errorf("Unexpected modes %s", modes)
+	}
+
+	transport, err := registry.CreateTransport("rail")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := transport.(*Train); !ok {
+		t.Errorf("Expected a Train, got %T", transport)
+	}
+	if _, err := registry.CreateTransport("teleport"); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("Expected ErrUnknownMode, got %v", err)
+	}
+
+	if err := registry.Register(&RailLogistics{}); err == nil {
+		t.Error("Expected an error registering a mode twice")
+	}
+	if err := registry.Register(&brokenLogistics{}); err == nil {
+		t.Error("Expected an error registering a transport without a speed")
+	}
+}
+
+func TestPlanWithRegisteredTransport(t *testing.T) {
+	registry := DefaultTransports()
+	if err := registry.Register(&DroneLogistics{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	network := NewNetwork()
+	network.AddHub("Store", Warehouse)
+	network.AddHub("Customer", Warehouse)
+	network.Connect("Store", "Customer", 8, "road", "drone")
+	planner := NewPlanner(network, registry)
+
+	it, err := planner.Plan(Shipment{From: "Store", To: "Customer", WeightKg: 2}, Cheapest)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := it.Legs[0].Transport.(*Drone); !ok {
+		t.Errorf("Expected a drone, got %T", it.Legs[0].Transport)
+	}
+
+	it, _ = planner.Plan(Shipment{From: "Store", To: "Customer", WeightKg: 20}, Cheapest)
+	if _, ok := it.Legs[0].Transport.(*Truck); !ok {
+		t.Errorf("Expected a truck for a parcel too heavy for a drone, got %T", it.Legs[0].Transport)
+	}
+}
+
+func TestNetworkValidation(t *testing.T) {
+	network := NewNetwork()
+	if err := network.AddHub("A"); err == nil {
+		t.Error("Expected an error for a hub without types")
+	}
+	network.AddHub("A", Warehouse)
+	network.AddHub("B", Port)
+	if err := network.AddHub("A", Port); err == nil {
+		t.Error("Expected an error for a duplicate hub")
+	}
+	for _, err := range []error{
+		network.Connect("A", "C", 10, "road"),
+		network.Connect("A", "A", 10, "road"),
+		network.Connect("A", "B", 0, "road"),
+		network.Connect("A", "B", 10),
+	} {
+		if err == nil {
+			t.Error("Expected an error for an invalid link")
+		}
+	}
+	if _, err := LoadNetwork(strings.NewReader(`{"hubs": [], "roads": []}`)); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
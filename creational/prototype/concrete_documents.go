@@ -44,45 +44,8 @@ func (r *ReportDocument) Clone() Prototype {
 
 // DeepClone creates a deep copy of the ReportDocument.
 func (r *ReportDocument) DeepClone() Prototype {
-	clonedDoc := r.Document.DeepClone().(*Document)
-	
-	cloned := &ReportDocument{
-		Document:        *clonedDoc,
-		Title:           r.Title,
-		ExecutiveSummary: r.ExecutiveSummary,
-		Introduction:    r.Introduction,
-		Conclusion:      r.Conclusion,
-		// Deep copy of sections
-		Sections:        make([]ReportSection, len(r.Sections)),
-		// Deep copy of references
-		References:      make([]string, len(r.References)),
-	}
-	
-	// Copy sections
-	for i, section := range r.Sections {
-		cloned.Sections[i] = ReportSection{
-			Title:   section.Title,
-			Content: section.Content,
-			Charts:  make([]Chart, len(section.Charts)),
-		}
-		
-		// Copy charts
-		for j, chart := range section.Charts {
-			cloned.Sections[i].Charts[j] = Chart{
-				Type:  chart.Type,
-				Title: chart.Title,
-				Data:  make(map[string]float64, len(chart.Data)),
-			}
-			
-			// Copy chart data
-			for k, v := range chart.Data {
-				cloned.Sections[i].Charts[j].Data[k] = v
-			}
-		}
-	}
-	
-	// Copy references
-	copy(cloned.References, r.References)
+	cloned := mustDeepCopy(r)
+	cloned.Name = r.Name + " (Copy)"
 	
 	return cloned
 }
@@ -127,34 +90,8 @@ func (f *FormDocument) Clone() Prototype {
 
 // DeepClone creates a deep copy of the FormDocument.
 func (f *FormDocument) DeepClone() Prototype {
-	clonedDoc := f.Document.DeepClone().(*Document)
-	
-	cloned := &FormDocument{
-		Document:    *clonedDoc,
-		Title:       f.Title,
-		Description: f.Description,
-		// Deep copy of fields
-		Fields:      make([]FormField, len(f.Fields)),
-		SubmitURL:   f.SubmitURL,
-		IsRequired:  f.IsRequired,
-	}
-	
-	// Copy fields
-	for i, field := range f.Fields {
-		cloned.Fields[i] = FormField{
-			Name:        field.Name,
-			Label:       field.Label,
-			Type:        field.Type,
-			Value:       field.Value,
-			Placeholder: field.Placeholder,
-			IsRequired:  field.IsRequired,
-			Validation:  field.Validation,
-			Options:     make([]string, len(field.Options)),
-		}
-		
-		// Copy options
-		copy(cloned.Fields[i].Options, field.Options)
-	}
+	cloned := mustDeepCopy(f)
+	cloned.Name = f.Name + " (Copy)"
 	
 	return cloned
 }
@@ -215,45 +152,10 @@ func (c *ContractDocument) Clone() Prototype {
 
 // DeepClone creates a deep copy of the ContractDocument.
 func (c *ContractDocument) DeepClone() Prototype {
-	clonedDoc := c.Document.DeepClone().(*Document)
-	
-	cloned := &ContractDocument{
-		Document:       *clonedDoc,
-		Title:          c.Title,
-		// Deep copy of parties
-		Parties:        make([]Party, len(c.Parties)),
-		// Deep copy of clauses
-		Clauses:        make([]Clause, len(c.Clauses)),
-		EffectiveDate:  c.EffectiveDate,
-		ExpirationDate: c.ExpirationDate,
-		IsExecuted:     false, // Reset execution status
-		Signatures:     nil,   // Reset signatures
-	}
-	
-	// Copy parties
-	for i, party := range c.Parties {
-		cloned.Parties[i] = Party{
-			Name:    party.Name,
-			Type:    party.Type,
-			Address: party.Address,
-			Contact: party.Contact,
-			Details: make(map[string]string, len(party.Details)),
-		}
-		
-		// Copy details
-		for k, v := range party.Details {
-			cloned.Parties[i].Details[k] = v
-		}
-	}
-	
-	// Copy clauses
-	for i, clause := range c.Clauses {
-		cloned.Clauses[i] = Clause{
-			Title:     clause.Title,
-			Content:   clause.Content,
-			IsRequired: clause.IsRequired,
-		}
-	}
+	cloned := mustDeepCopy(c)
+	cloned.Name = c.Name + " (Copy)"
+	cloned.IsExecuted = false // Reset execution status
+	cloned.Signatures = nil   // Reset signatures
 	
 	return cloned
 }
@@ -314,40 +216,10 @@ func (i *InvoiceDocument) Clone() Prototype {
 
 // DeepClone creates a deep copy of the InvoiceDocument.
 func (i *InvoiceDocument) DeepClone() Prototype {
-	clonedDoc := i.Document.DeepClone().(*Document)
-	
-	cloned := &InvoiceDocument{
-		Document:      *clonedDoc,
-		InvoiceNumber: "NEW-" + i.InvoiceNumber, // Generate a new invoice number
-		// Deep copy of customer info
-		CustomerInfo: Customer{
-			Name:    i.CustomerInfo.Name,
-			Address: i.CustomerInfo.Address,
-			Email:   i.CustomerInfo.Email,
-			Phone:   i.CustomerInfo.Phone,
-			ID:      i.CustomerInfo.ID,
-		},
-		// Deep copy of line items
-		LineItems:    make([]LineItem, len(i.LineItems)),
-		Subtotal:     i.Subtotal,
-		TaxRate:      i.TaxRate,
-		TaxAmount:    i.TaxAmount,
-		Total:        i.Total,
-		DueDate:      i.DueDate,
-		IsPaid:       false, // Reset payment status
-		PaymentTerms: i.PaymentTerms,
-	}
-	
-	// Copy line items
-	for j, item := range i.LineItems {
-		cloned.LineItems[j] = LineItem{
-			Description: item.Description,
-			Quantity:    item.Quantity,
-			UnitPrice:   item.UnitPrice,
-			Amount:      item.Amount,
-			TaxCategory: item.TaxCategory,
-		}
-	}
+	cloned := mustDeepCopy(i)
+	cloned.Name = i.Name + " (Copy)"
+	cloned.InvoiceNumber = "NEW-" + i.InvoiceNumber // Generate a new invoice number
+	cloned.IsPaid = false                           // Reset payment status
 	
 	return cloned
 }
@@ -0,0 +1,273 @@
+package prototype
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// Cloner lets a type decide how DeepCopy copies it. DeepCopy calls the
+// method instead of copying the value field by field. The result must be
+// assignable to the type of the receiver, or be a pointer to it.
+//
+// DeepCopy must not be called on the receiver itself from within the
+// method, as that would call the method again.
+type Cloner interface {
+	DeepCopy() (interface{}, error)
+}
+
+var (
+	clonerType   = reflect.TypeOf((*Cloner)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	locationType = reflect.TypeOf((*time.Location)(nil))
+)
+
+// DeepCopy returns a copy of v that shares no mutable memory with it.
+//
+// Pointers, slices, maps, arrays, structs and interfaces are copied
+// recursively, including unexported struct fields. Values reached more than
+// once through the same pointer, map or slice are copied once, so shared
+// references and cycles have the same shape in the copy. time.Time values
+// and *time.Location pointers are immutable and kept as they are, as are
+// functions.
+//
+// Struct fields can be tagged to change how they are copied:
+//
+//	Cache  *Cache   `clone:"-"`       // left as the zero value
+//	Logger *Logger  `clone:"shallow"` // copied by assignment
+//
+// Channels and unsafe pointers cannot be copied and return an error unless
+// the field holding them is tagged.
+func DeepCopy[T any](v T) (T, error) {
+	c := &copier{visited: make(map[visitKey]reflect.Value)}
+	src := reflect.ValueOf(&v).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	if err := c.copy(dst, src, src.Type().String()); err != nil {
+		var zero T
+		return zero, err
+	}
+	return dst.Interface().(T), nil
+}
+
+// mustDeepCopy is DeepCopy for values known to be copyable, such as the
+// documents in this package.
+func mustDeepCopy[T any](v T) T {
+	copied, err := DeepCopy(v)
+	if err != nil {
+		panic(err)
+	}
+	return copied
+}
+
+// visitKey identifies memory that has already been copied. Slices sharing
+// a backing array are only treated as the same when they have the same
+// length and capacity.
+type visitKey struct {
+	typ      reflect.Type
+	ptr      uintptr
+	len, cap int
+}
+
+// copier holds the state of one DeepCopy call.
+type copier struct {
+	visited map[visitKey]reflect.Value
+}
+
+// copy stores a deep copy of src in dst, which must be settable and hold
+// the zero value. The path is used in error messages.
+func (c *copier) copy(dst, src reflect.Value, path string) error {
+	if handled, err := c.copyWithCloner(dst, src, path); handled || err != nil {
+		return err
+	}
+
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return nil
+		}
+		if src.Type() == locationType {
+			dst.Set(src)
+			return nil
+		}
+		key := visitKey{typ: src.Type(), ptr: src.Pointer()}
+		if seen, ok := c.visited[key]; ok {
+			dst.Set(seen)
+			return nil
+		}
+		p := reflect.New(src.Type().Elem())
+		c.visited[key] = p
+		if err := c.copy(p.Elem(), src.Elem(), path); err != nil {
+			return err
+		}
+		dst.Set(p)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return nil
+		}
+		elem := src.Elem()
+		copied := reflect.New(elem.Type()).Elem()
+		if err := c.copy(copied, elem, path); err != nil {
+			return err
+		}
+		dst.Set(copied)
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return nil
+		}
+		key := visitKey{typ: src.Type(), ptr: src.Pointer(), len: src.Len(), cap: src.Cap()}
+		if seen, ok := c.visited[key]; ok {
+			dst.Set(seen)
+			return nil
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		c.visited[key] = s
+		for i := 0; i < src.Len(); i++ {
+			if err := c.copy(s.Index(i), src.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			if err := c.copy(dst.Index(i), src.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			return nil
+		}
+		key := visitKey{typ: src.Type(), ptr: src.Pointer()}
+		if seen, ok := c.visited[key]; ok {
+			dst.Set(seen)
+			return nil
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.visited[key] = m
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			if err := c.copy(k, iter.Key(), fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+			v := reflect.New(src.Type().Elem()).Elem()
+			if err := c.copy(v, iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+
+	case reflect.Struct:
+		if src.Type() == timeType {
+			dst.Set(src)
+			return nil
+		}
+		return c.copyStruct(dst, src, path)
+
+	case reflect.Chan, reflect.UnsafePointer:
+		if src.IsNil() {
+			return nil
+		}
+		return fmt.Errorf("DeepCopy: cannot copy %s at %s; tag the field with clone:\"shallow\" or clone:\"-\"", src.Type(), path)
+
+	default:
+		// Booleans, numbers, strings and functions
+		dst.Set(src)
+	}
+	return nil
+}
+
+// copyStruct copies the fields of a struct, following their clone tags.
+func (c *copier) copyStruct(dst, src reflect.Value, path string) error {
+	// Unexported fields can only be read through an addressable struct
+	if !src.CanAddr() {
+		addressable := reflect.New(src.Type()).Elem()
+		addressable.Set(src)
+		src = addressable
+	}
+
+	t := src.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("clone")
+		if tag == "-" {
+			continue
+		}
+
+		sf, df := src.Field(i), dst.Field(i)
+		if !field.IsExported() {
+			sf = reflect.NewAt(sf.Type(), unsafe.Pointer(sf.UnsafeAddr())).Elem()
+			df = reflect.NewAt(df.Type(), unsafe.Pointer(df.UnsafeAddr())).Elem()
+		}
+
+		switch tag {
+		case "shallow":
+			df.Set(sf)
+		case "":
+			if err := c.copy(df, sf, path+"."+field.Name); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("DeepCopy: unknown clone tag %q on %s.%s", tag, path, field.Name)
+		}
+	}
+	return nil
+}
+
+// copyWithCloner copies src with its DeepCopy method if it has one. It
+// reports whether src was handled.
+func (c *copier) copyWithCloner(dst, src reflect.Value, path string) (bool, error) {
+	t := src.Type()
+	var receiver reflect.Value
+	switch {
+	case t.Kind() == reflect.Interface:
+		// The dynamic value is checked when the interface is copied
+		return false, nil
+	case t.Implements(clonerType):
+		if t.Kind() == reflect.Ptr && src.IsNil() {
+			return false, nil
+		}
+		receiver = src
+	case t.Kind() != reflect.Ptr && reflect.PointerTo(t).Implements(clonerType):
+		receiver = reflect.New(t)
+		receiver.Elem().Set(src)
+	default:
+		return false, nil
+	}
+
+	var key visitKey
+	if t.Kind() == reflect.Ptr {
+		key = visitKey{typ: t, ptr: src.Pointer()}
+		if seen, ok := c.visited[key]; ok {
+			dst.Set(seen)
+			return true, nil
+		}
+	}
+
+	result, err := receiver.Interface().(Cloner).DeepCopy()
+	if err != nil {
+		return true, fmt.Errorf("DeepCopy: %s: %w", path, err)
+	}
+	copied := reflect.ValueOf(result)
+	switch {
+	case !copied.IsValid():
+		// A nil result leaves the zero value
+	case copied.Type().AssignableTo(t):
+		dst.Set(copied)
+	case copied.Kind() == reflect.Ptr && copied.Type().Elem() == t && !copied.IsNil():
+		dst.Set(copied.Elem())
+	default:
+		return true, fmt.Errorf("DeepCopy: %s: DeepCopy of %s returned %T", path, t, result)
+	}
+
+	if t.Kind() == reflect.Ptr {
+		c.visited[key] = dst
+	}
+	return true, nil
+}
@@ -0,0 +1,373 @@
+package prototype
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// graphNode has every kind DeepCopy handles, including a back pointer
+type graphNode struct {
+	Name     string
+	Values   []int
+	Attrs    map[string]interface{}
+	Children []*graphNode
+	Parent   *graphNode
+	Pair     [2][]byte
+	Any      interface{}
+	When     time.Time
+	secret   []string
+}
+
+func TestDeepCopyCyclesAndSharedReferences(t *testing.T) {
+	shared := []int{1, 2, 3}
+	root := &graphNode{Name: "root", Values: shared, secret: []string{"s"}}
+	child := &graphNode{Name: "child", Parent: root, Values: shared}
+	root.Children = []*graphNode{child, child}
+	root.Attrs = map[string]interface{}{"self": root.Attrs, "child": child}
+	root.Any = root
+
+	copied, err := DeepCopy(root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if copied == root || copied.Children[0] == child {
+		t.Fatal("Expected new nodes")
+	}
+	if copied.Children[0] != copied.Children[1] {
+		t.Error("Expected a node referenced twice to be copied once")
+	}
+	if copied.Children[0].Parent != copied || copied.Any != interface{}(copied) {
+		t.Error("Expected cycles to point into the copy")
+	}
+	if copied.Attrs["child"] != interface{}(copied.Children[0]) {
+		t.Error("Expected references through interfaces to be shared in the copy")
+	}
+
+	copied.Values[0] = 99
+	if shared[0] != 1 {
+		t.Error("Expected the copy not to share the slice")
+	}
+	if copied.Children[0].Values[0] != 99 {
+		t.Error("Expected slices shared in the original to be shared in the copy")
+	}
+
+	copied.secret[0] = "changed"
+	if root.secret[0] != "s" {
+		t.Error("Expected unexported fields to be copied")
+	}
+}
+
+func TestDeepCopyMapCycle(t *testing.T) {
+	m := map[string]interface{}{"n": 1}
+	m["self"] = m
+
+	copied, err := DeepCopy(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	copied["n"] = 2
+	if m["n"] != 1 {
+		t.Error("Expected the map to be copied")
+	}
+	if inner := copied["self"].(map[string]interface{}); inner["n"] != 2 {
+		t.Error("Expected the map cycle to point into the copy")
+	}
+}
+
+// taggedConfig has fields that are not copied deeply
+type taggedConfig struct {
+	Name   string
+	Cache  map[string]string `clone:"-"`
+	Logger *strings.Builder  `clone:"shallow"`
+	Events chan string       `clone:"shallow"`
+}
+
+func TestDeepCopyTags(t *testing.T) {
+	logger := &strings.Builder{}
+	config := taggedConfig{Name: "c", Cache: map[string]string{"k": "v"}, Logger: logger, Events: make(chan string)}
+
+	copied, err := DeepCopy(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if copied.Cache != nil {
+		t.Error("Expected clone:\"-\" fields to be left empty")
+	}
+	if copied.Logger != logger || copied.Events != config.Events {
+		t.Error("Expected clone:\"shallow\" fields to be shared")
+	}
+
+	if _, err := DeepCopy(struct{ C chan int }{make(chan int)}); err == nil || !strings.Contains(err.Error(), ".C") {
+		t.Errorf("Expected an error naming the channel field, got %v", err)
+	}
+	if _, err := DeepCopy(struct {
+		S []int `clone:"deep"`
+	}{}); err == nil {
+		t.Error("Expected an error for an unknown tag")
+	}
+}
+
+// counter copies itself without its cached total
+type counter struct {
+	Counts []int
+	total  int
+}
+
+func (c *counter) DeepCopy() (interface{}, error) {
+	return &counter{Counts: append([]int(nil), c.Counts...)}, nil
+}
+
+// failing refuses to be copied
+type failing struct{}
+
+func (failing) DeepCopy() (interface{}, error) { return nil, errors.New("not copyable") }
+
+func TestDeepCopyCloner(t *testing.T) {
+	c := &counter{Counts: []int{1, 2}, total: 3}
+	values := struct {
+		P *counter
+		Q *counter
+		V counter
+	}{c, c, *c}
+
+	copied, err := DeepCopy(values)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if copied.P == c || copied.P.total != 0 || copied.V.total != 0 {
+		t.Errorf("Expected the DeepCopy methods to be used, got %+v", copied)
+	}
+	if copied.P != copied.Q {
+		t.Error("Expected the pointer copied by DeepCopy to be reused")
+	}
+
+	if _, err := DeepCopy([]interface{}{failing{}}); err == nil || !strings.Contains(err.Error(), "not copyable") {
+		t.Errorf("Expected the DeepCopy error, got %v", err)
+	}
+}
+
+func TestDeepCopyValues(t *testing.T) {
+	when := time.Date(2025, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	copiedTime, err := DeepCopy(when)
+	if err != nil || !copiedTime.Equal(when) || copiedTime.Location() != when.Location() {
+		t.Errorf("Expected an identical time, got %v, %v", copiedTime, err)
+	}
+
+	var nilMap map[string]int
+	if copied, _ := DeepCopy(nilMap); copied != nil {
+		t.Error("Expected nil maps to stay nil")
+	}
+
+	var iface interface{} = []string{"a"}
+	copied, err := DeepCopy(iface)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	copied.([]string)[0] = "b"
+	if iface.([]string)[0] != "a" {
+		t.Error("Expected the interface's value to be copied")
+	}
+
+	array := [2]*int{new(int), new(int)}
+	copiedArray, _ := DeepCopy(array)
+	*copiedArray[0] = 5
+	if *array[0] != 0 {
+		t.Error("Expected array elements to be copied")
+	}
+}
+
+func TestDocumentDeepCloneUsesDeepCopy(t *testing.T) {
+	report := &ReportDocument{
+		Document: Document{Name: "Report", Metadata: map[string]string{"k": "v"}},
+		Sections: []ReportSection{{Charts: []Chart{{Data: map[string]float64{"Q1": 1}}}}},
+	}
+	cloned := report.DeepClone().(*ReportDocument)
+	cloned.Sections[0].Charts[0].Data["Q1"] = 2
+	cloned.Metadata["k"] = "changed"
+	if report.Sections[0].Charts[0].Data["Q1"] != 1 || report.Metadata["k"] != "v" {
+		t.Error("Expected the report to be copied deeply")
+	}
+	if cloned.Name != "Report (Copy)" {
+		t.Errorf("Unexpected name %q", cloned.Name)
+	}
+
+	contract := &ContractDocument{IsExecuted: true, Signatures: []Signature{{PartyName: "A"}}}
+	if c := contract.DeepClone().(*ContractDocument); c.IsExecuted || c.Signatures != nil {
+		t.Error("Expected the contract's execution state to be reset")
+	}
+	invoice := &InvoiceDocument{InvoiceNumber: "1", IsPaid: true}
+	if i := invoice.DeepClone().(*InvoiceDocument); i.IsPaid || i.InvoiceNumber != "NEW-1" {
+		t.Errorf("Expected a new unpaid invoice, got %+v", i)
+	}
+}
+
+// buildGraph builds a graph of nodes from fuzz input. Each byte picks an
+// operation on the node being built, so any input gives a valid graph,
+// including shared nodes and cycles.
+func buildGraph(data []byte) *graphNode {
+	nodes := []*graphNode{{Name: "root"}}
+	current := nodes[0]
+	for i, b := range data {
+		switch b % 8 {
+		case 0:
+			child := &graphNode{Name: string(rune('a' + i%26)), Parent: current}
+			current.Children = append(current.Children, child)
+			nodes = append(nodes, child)
+			current = child
+		case 1:
+			current.Values = append(current.Values, int(b))
+		case 2:
+			if current.Attrs == nil {
+				current.Attrs = make(map[string]interface{})
+			}
+			current.Attrs[string(rune('a'+b%26))] = nodes[int(b)%len(nodes)]
+		case 3:
+			current.Pair[b%2] = append(current.Pair[b%2], b)
+		case 4:
+			current.Any = nodes[int(b)%len(nodes)].Values
+		case 5:
+			current.secret = append(current.secret, string(rune(b)))
+		case 6:
+			current.When = time.Unix(int64(b)*1000, 0)
+		case 7:
+			current = nodes[int(b)%len(nodes)]
+		}
+	}
+	return nodes[0]
+}
+
+// describeGraph serializes a graph, numbering nodes in the order they are
+// first reached. Two graphs have the same description only if they hold the
+// same values and share nodes in the same way. reflect.DeepEqual is not
+// used because it takes exponential time on graphs with many shared nodes.
+func describeGraph(root *graphNode) string {
+	ids := make(map[*graphNode]int)
+	var sb strings.Builder
+	var describe func(n *graphNode) int
+	describe = func(n *graphNode) int {
+		if n == nil {
+			return -1
+		}
+		if id, ok := ids[n]; ok {
+			return id
+		}
+		id := len(ids)
+		ids[n] = id
+
+		children := make([]int, len(n.Children))
+		for i, child := range n.Children {
+			children[i] = describe(child)
+		}
+		keys := make([]string, 0, len(n.Attrs))
+		for k := range n.Attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := make([]string, len(keys))
+		for i, k := range keys {
+			node, _ := n.Attrs[k].(*graphNode)
+			attrs[i] = fmt.Sprintf("%s=%d", k, describe(node))
+		}
+		fmt.Fprintf(&sb, "%d:%q %v %v %v %v %v %v %q parent=%d\n", id, n.Name, n.Values, children, attrs,
+			n.Pair, n.Any, n.When.Unix(), n.secret, describe(n.Parent))
+		return id
+	}
+	describe(root)
+	return sb.String()
+}
+
+// mutableMemory records the addresses of all memory reachable from v that
+// could be changed through it
+func mutableMemory(v reflect.Value, seen map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		mutableMemory(v.Elem(), seen)
+	case reflect.Interface:
+		if !v.IsNil() {
+			mutableMemory(v.Elem(), seen)
+		}
+	case reflect.Slice:
+		if v.Cap() > 0 {
+			seen[v.Pointer()] = true
+		}
+		for i := 0; i < v.Len(); i++ {
+			mutableMemory(v.Index(i), seen)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			mutableMemory(v.Index(i), seen)
+		}
+	case reflect.Map:
+		if v.IsNil() || seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		iter := v.MapRange()
+		for iter.Next() {
+			mutableMemory(iter.Value(), seen)
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			mutableMemory(v.Field(i), seen)
+		}
+	}
+}
+
+func FuzzDeepCopy(f *testing.F) {
+	f.Add([]byte{0, 1, 9, 2, 3, 0, 4, 5, 7, 10})
+	f.Add([]byte{0, 0, 0, 7, 2, 18, 26, 4, 13, 6})
+	f.Add([]byte("deep copies share nothing"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		original := buildGraph(data)
+		expected := buildGraph(data)
+
+		copied, err := DeepCopy(original)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if describeGraph(copied) != describeGraph(original) {
+			t.Fatal("Expected the copy to equal the original")
+		}
+
+		originalMemory := make(map[uintptr]bool)
+		mutableMemory(reflect.ValueOf(original), originalMemory)
+		copiedMemory := make(map[uintptr]bool)
+		mutableMemory(reflect.ValueOf(copied), copiedMemory)
+		for addr := range copiedMemory {
+			if originalMemory[addr] {
+				t.Fatalf("Copy shares memory at %#x with the original", addr)
+			}
+		}
+
+		// Changing everything in the copy leaves the original as it was
+		for _, node := range []*graphNode{copied} {
+			node.Name = "changed"
+			for i := range node.Values {
+				node.Values[i]++
+			}
+			for _, child := range node.Children {
+				child.Name = "changed"
+				child.Values = append(child.Values, -1)
+			}
+			for k := range node.Attrs {
+				node.Attrs[k] = nil
+			}
+		}
+		if describeGraph(original) != describeGraph(expected) {
+			t.Fatal("Changing the copy changed the original")
+		}
+	})
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/edgardnogueira/go-patterns/creational/prototype"
@@ -137,9 +139,78 @@ func main() {
 	fmt.Printf("Shallow clone label: %s\n", shallowClone.Fields[0].Label)
 	fmt.Printf("Deep clone label: %s\n", deepClone.Fields[0].Label)
 	
+	// Use case 5: Versioned templates saved to disk, cloned with variables
+	fmt.Println("\nUse Case 5: Versioned templates and variables")
+	if err := versionedTemplates(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
 	fmt.Println("\nDemonstration complete!")
 }
 
+// versionedTemplates keeps a template with placeholders in a registry
+// saved to disk, changes it, rolls the change back and fills it in
+func versionedTemplates() error {
+	dir, err := os.MkdirTemp("", "prototype-templates")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	registry, err := prototype.OpenDocumentRegistry(dir)
+	if err != nil {
+		return err
+	}
+	letter := &prototype.ContractDocument{
+		Document: prototype.Document{Name: "Engagement Letter"},
+		Title:    "Engagement Letter for {{customer.name}}",
+		Clauses: []prototype.Clause{
+			{Title: "Scope", Content: "We will audit the accounts of {{customer.name}} for {{year}}."},
+			{Title: "Fees", Content: "Our fee is {{fee}} EUR."},
+		},
+	}
+	if err := registry.Register("engagement-letter", letter); err != nil {
+		return err
+	}
+	letter.Clauses[1].Content = "Our fee is {{fee}} EUR plus expenses."
+	if _, err := registry.Update("engagement-letter", letter, "legal", "add expenses"); err != nil {
+		return err
+	}
+	if _, err := registry.Rollback("engagement-letter", 1, "partner"); err != nil {
+		return err
+	}
+
+	// Reopening the directory restores the template and its history
+	reopened, err := prototype.OpenDocumentRegistry(dir)
+	if err != nil {
+		return err
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	fmt.Printf("Saved files: %d\n", len(files))
+	changeLog, err := reopened.ChangeLog("engagement-letter")
+	if err != nil {
+		return err
+	}
+	fmt.Print("Change log:\n" + changeLog)
+
+	cloned, err := reopened.CloneWith("engagement-letter", map[string]interface{}{
+		"customer": map[string]interface{}{"name": "XYZ Corporation"},
+		"year":     2025,
+		"fee":      "12,000",
+	})
+	if err != nil {
+		return err
+	}
+	filled := cloned.(*prototype.ContractDocument)
+	fmt.Printf("Title: %s\n", filled.Title)
+	for _, clause := range filled.Clauses {
+		fmt.Printf("  %s: %s\n", clause.Title, clause.Content)
+	}
+
+	return nil
+}
+
 // initializeRegistry initializes the registry with document templates
 func initializeRegistry(registry *prototype.DocumentRegistry) {
 	// Create a report document template
@@ -339,13 +410,3 @@ func initializeRegistry(registry *prototype.DocumentRegistry) {
 	registry.Register("monthly-invoice", invoiceTemplate)
 	registry.Register("feedback-form", formTemplate)
 }
-
-// Method to recalculate invoice totals (just for the example)
-func (i *prototype.InvoiceDocument) Recalculate() {
-	i.Subtotal = 0
-	for _, item := range i.LineItems {
-		i.Subtotal += item.Amount
-	}
-	i.TaxAmount = i.Subtotal * i.TaxRate
-	i.Total = i.Subtotal + i.TaxAmount
-}
@@ -46,26 +46,8 @@ func (d *Document) Clone() Prototype {
 
 // DeepClone creates a deep copy of the Document.
 func (d *Document) DeepClone() Prototype {
-	// Create a deep copy of the document
-	cloned := &Document{
-		ID:       d.ID,
-		Name:     d.Name + " (Copy)",
-		Creator:  d.Creator,
-		Created:  d.Created,
-		Modified: d.Modified,
-		// For deep copy, we create a new slice with the same content
-		Tags:     make([]string, len(d.Tags)),
-		// For deep copy, we create a new map with the same content
-		Metadata: make(map[string]string, len(d.Metadata)),
-	}
-	
-	// Copy the tags
-	copy(cloned.Tags, d.Tags)
-	
-	// Copy the metadata
-	for k, v := range d.Metadata {
-		cloned.Metadata[k] = v
-	}
+	cloned := mustDeepCopy(d)
+	cloned.Name = d.Name + " (Copy)"
 	
 	return cloned
 }
@@ -2,88 +2,317 @@ package prototype
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
+// ErrPrototypeNotFound is returned when no prototype is registered under a name.
+var ErrPrototypeNotFound = errors.New("prototype not found")
+
+// Revision is one version of a template in the registry.
+type Revision struct {
+	Version  int
+	Time     time.Time
+	Author   string
+	Message  string
+	Changes  []string // Paths of the fields that changed since the previous version
+	Document Prototype
+}
+
+// templateHistory holds every version of a template, oldest first.
+type templateHistory struct {
+	revisions []Revision
+}
+
+// latest returns the current version of the template.
+func (h *templateHistory) latest() Revision {
+	return h.revisions[len(h.revisions)-1]
+}
+
 // DocumentRegistry is a registry that stores and manages document prototypes.
 // It allows registering, retrieving, and cloning prototypes by name.
+//
+// Every change to a template is kept as a new version, so earlier versions
+// can be inspected and rolled back to. A registry opened with
+// OpenDocumentRegistry also saves each template to a directory.
 type DocumentRegistry struct {
-	prototypes map[string]Prototype
-	mutex      sync.RWMutex
+	templates map[string]*templateHistory
+	dir       string           // Directory templates are saved to, if any
+	now       func() time.Time // Clock for revision times
+	mutex     sync.RWMutex
 }
 
-// NewDocumentRegistry creates a new document registry.
+// NewDocumentRegistry creates a new document registry that is kept in memory.
 func NewDocumentRegistry() *DocumentRegistry {
 	return &DocumentRegistry{
-		prototypes: make(map[string]Prototype),
+		templates: make(map[string]*templateHistory),
+		now:       time.Now,
+	}
+}
+
+// OpenDocumentRegistry creates a registry that is saved to dir. Templates
+// already saved there are loaded with their history, and every later change
+// is written back immediately. The directory is created if needed.
+func OpenDocumentRegistry(dir string) (*DocumentRegistry, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	r := NewDocumentRegistry()
+	templates, err := loadTemplates(dir)
+	if err != nil {
+		return nil, err
 	}
+	r.templates = templates
+	r.dir = dir
+
+	return r, nil
+}
+
+// Register adds a prototype to the registry. If a prototype is already
+// registered under the name, it becomes a new version of that template.
+func (r *DocumentRegistry) Register(name string, prototype Prototype) error {
+	_, err := r.commit(name, prototype, "", "", false)
+	return err
+}
+
+// Update stores a new version of an existing template and returns its
+// version number. The author and message are recorded in the change log.
+func (r *DocumentRegistry) Update(name string, prototype Prototype, author, message string) (int, error) {
+	return r.commit(name, prototype, author, message, true)
 }
 
-// Register adds a prototype to the registry.
-func (r *DocumentRegistry) Register(name string, prototype Prototype) {
+// commit stores a snapshot of the prototype as the next version of a template.
+func (r *DocumentRegistry) commit(name string, prototype Prototype, author, message string, mustExist bool) (int, error) {
+	if name == "" {
+		return 0, errors.New("prototype name must not be empty")
+	}
+	snapshot, err := snapshotOf(prototype)
+	if err != nil {
+		return 0, err
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	
-	r.prototypes[name] = prototype
+
+	history, exists := r.templates[name]
+	if !exists && mustExist {
+		return 0, fmt.Errorf("%w: %s", ErrPrototypeNotFound, name)
+	}
+
+	revision := Revision{
+		Version:  1,
+		Time:     r.now().UTC(),
+		Author:   author,
+		Message:  message,
+		Document: snapshot,
+	}
+	if exists {
+		previous := history.latest()
+		revision.Version = previous.Version + 1
+		revision.Changes = changedFields(previous.Document, snapshot)
+		if revision.Message == "" {
+			revision.Message = "update"
+		}
+	} else {
+		history = &templateHistory{}
+		if revision.Message == "" {
+			revision.Message = "register"
+		}
+	}
+
+	return r.append(name, history, revision)
 }
 
-// Unregister removes a prototype from the registry.
-func (r *DocumentRegistry) Unregister(name string) {
+// append adds a revision to a template's history and saves the template.
+// The caller must hold the write lock.
+func (r *DocumentRegistry) append(name string, history *templateHistory, revision Revision) (int, error) {
+	updated := &templateHistory{revisions: append(history.revisions[:len(history.revisions):len(history.revisions)], revision)}
+	if r.dir != "" {
+		if err := saveTemplate(r.dir, name, updated); err != nil {
+			return 0, err
+		}
+	}
+	r.templates[name] = updated
+
+	return revision.Version, nil
+}
+
+// Rollback makes an earlier version of a template current again. The
+// earlier version is stored as a new version, so the history is kept.
+func (r *DocumentRegistry) Rollback(name string, version int, author string) (int, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	
-	delete(r.prototypes, name)
+
+	history, exists := r.templates[name]
+	if !exists {
+		return 0, fmt.Errorf("%w: %s", ErrPrototypeNotFound, name)
+	}
+	target, err := history.revision(name, version)
+	if err != nil {
+		return 0, err
+	}
+	snapshot, err := snapshotOf(target.Document)
+	if err != nil {
+		return 0, err
+	}
+
+	previous := history.latest()
+	return r.append(name, history, Revision{
+		Version:  previous.Version + 1,
+		Time:     r.now().UTC(),
+		Author:   author,
+		Message:  fmt.Sprintf("rollback to version %d", version),
+		Changes:  changedFields(previous.Document, snapshot),
+		Document: snapshot,
+	})
+}
+
+// revision returns the given version of a template.
+func (h *templateHistory) revision(name string, version int) (Revision, error) {
+	for _, revision := range h.revisions {
+		if revision.Version == version {
+			return revision, nil
+		}
+	}
+	return Revision{}, fmt.Errorf("prototype %s has no version %d", name, version)
 }
 
-// Get retrieves a prototype from the registry without cloning it.
+// Unregister removes a prototype and its history from the registry.
+func (r *DocumentRegistry) Unregister(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.dir != "" {
+		if err := removeTemplate(r.dir, name); err != nil {
+			return err
+		}
+	}
+	delete(r.templates, name)
+
+	return nil
+}
+
+// Get retrieves the current version of a prototype from the registry without
+// cloning it. The prototype must not be modified; use Update to change it.
 func (r *DocumentRegistry) Get(name string) (Prototype, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	
-	prototype, exists := r.prototypes[name]
+
+	history, exists := r.templates[name]
 	if !exists {
-		return nil, errors.New("prototype not found: " + name)
+		return nil, fmt.Errorf("%w: %s", ErrPrototypeNotFound, name)
 	}
-	
-	return prototype, nil
+
+	return history.latest().Document, nil
 }
 
-// Clone retrieves a shallow clone of a prototype from the registry.
-func (r *DocumentRegistry) Clone(name string) (Prototype, error) {
+// GetVersion retrieves a version of a prototype without cloning it.
+func (r *DocumentRegistry) GetVersion(name string, version int) (Prototype, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	
-	prototype, exists := r.prototypes[name]
+
+	history, exists := r.templates[name]
 	if !exists {
-		return nil, errors.New("prototype not found: " + name)
+		return nil, fmt.Errorf("%w: %s", ErrPrototypeNotFound, name)
 	}
-	
-	return prototype.Clone(), nil
+	revision, err := history.revision(name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision.Document, nil
 }
 
-// DeepClone retrieves a deep clone of a prototype from the registry.
-func (r *DocumentRegistry) DeepClone(name string) (Prototype, error) {
+// History returns every version of a prototype, oldest first.
+func (r *DocumentRegistry) History(name string) ([]Revision, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	
-	prototype, exists := r.prototypes[name]
+
+	history, exists := r.templates[name]
 	if !exists {
-		return nil, errors.New("prototype not found: " + name)
+		return nil, fmt.Errorf("%w: %s", ErrPrototypeNotFound, name)
+	}
+
+	revisions := make([]Revision, len(history.revisions))
+	copy(revisions, history.revisions)
+	return revisions, nil
+}
+
+// ChangeLog describes the versions of a prototype, newest first, with the
+// fields each version changed.
+func (r *DocumentRegistry) ChangeLog(name string) (string, error) {
+	revisions, err := r.History(name)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i := len(revisions) - 1; i >= 0; i-- {
+		revision := revisions[i]
+		author := revision.Author
+		if author == "" {
+			author = "unknown"
+		}
+		sb.WriteString(fmt.Sprintf("v%d %s %s: %s\n", revision.Version, revision.Time.Format(time.RFC3339), author, revision.Message))
+		for _, change := range revision.Changes {
+			sb.WriteString("  changed " + change + "\n")
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// Clone retrieves a shallow clone of a prototype from the registry.
+func (r *DocumentRegistry) Clone(name string) (Prototype, error) {
+	prototype, err := r.Get(name)
+	if err != nil {
+		return nil, err
 	}
-	
+
+	return prototype.Clone(), nil
+}
+
+// DeepClone retrieves a deep clone of a prototype from the registry.
+func (r *DocumentRegistry) DeepClone(name string) (Prototype, error) {
+	prototype, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
 	return prototype.DeepClone(), nil
 }
 
-// List returns a list of all prototype names in the registry.
+// CloneWith retrieves a deep clone of a prototype and replaces the
+// placeholders in its text, such as {{customer.name}}, with variables.
+// See Substitute for how variables are looked up.
+func (r *DocumentRegistry) CloneWith(name string, variables map[string]interface{}) (Prototype, error) {
+	cloned, err := r.DeepClone(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := Substitute(cloned, variables); err != nil {
+		return nil, fmt.Errorf("cloning %s: %w", name, err)
+	}
+
+	return cloned, nil
+}
+
+// List returns the names of all prototypes in the registry in alphabetical order.
 func (r *DocumentRegistry) List() []string {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	
-	names := make([]string, 0, len(r.prototypes))
-	for name := range r.prototypes {
+
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
 		names = append(names, name)
 	}
-	
+	sort.Strings(names)
+
 	return names
 }
 
@@ -91,6 +320,6 @@ func (r *DocumentRegistry) List() []string {
 func (r *DocumentRegistry) Count() int {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	
-	return len(r.prototypes)
+
+	return len(r.templates)
 }
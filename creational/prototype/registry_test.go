@@ -0,0 +1,223 @@
+package prototype
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contractTemplate returns a contract with placeholders for the customer
+func contractTemplate() *ContractDocument {
+	return &ContractDocument{
+		Document: Document{ID: "CONTRACT-TEMPLATE", Name: "Service Agreement", Tags: []string{"legal"}},
+		Title:    "Service Agreement with {{customer.name}}",
+		Parties: []Party{
+			{Name: "ACME Corp", Type: "company"},
+			{Name: "{{customer.name}}", Type: "company", Details: map[string]string{"contact": "{{ customer.email }}"}},
+		},
+		Clauses: []Clause{
+			{Title: "Services", Content: "ACME provides services to {{customer.name}}."},
+			{Title: "Fees", Content: "The monthly fee is {{fee}} EUR."},
+		},
+	}
+}
+
+// fixedClock returns a clock that advances one minute per call
+func fixedClock() func() time.Time {
+	now := time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+}
+
+func TestRegistryVersions(t *testing.T) {
+	registry := NewDocumentRegistry()
+	registry.now = fixedClock()
+
+	template := contractTemplate()
+	if err := registry.Register("service", template); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Changing the registered value must not change the stored version
+	template.Title = "Changed behind the registry's back"
+	if current, _ := registry.Get("service"); current.(*ContractDocument).Title == template.Title {
+		t.Error("Expected the registry to store a copy of the prototype")
+	}
+
+	updated := contractTemplate()
+	updated.Clauses[1].Content = "The monthly fee is {{fee}} USD."
+	version, err := registry.Update("service", updated, "alice", "switch to USD")
+	if err != nil || version != 2 {
+		t.Fatalf("Expected version 2, got %d, %v", version, err)
+	}
+
+	version, err = registry.Rollback("service", 1, "bob")
+	if err != nil || version != 3 {
+		t.Fatalf("Expected version 3, got %d, %v", version, err)
+	}
+	current, _ := registry.Get("service")
+	if content := current.(*ContractDocument).Clauses[1].Content; content != "The monthly fee is {{fee}} EUR." {
+		t.Errorf("Expected the rollback to restore EUR, got %q", content)
+	}
+	old, err := registry.GetVersion("service", 2)
+	if err != nil || old.(*ContractDocument).Clauses[1].Content != updated.Clauses[1].Content {
+		t.Errorf("Expected version 2 to be kept, got %v, %v", old, err)
+	}
+
+	log, err := registry.ChangeLog("service")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "v3 2025-01-02T15:07:00Z bob: rollback to version 1\n" +
+		"  changed Clauses[1].Content\n" +
+		"v2 2025-01-02T15:06:00Z alice: switch to USD\n" +
+		"  changed Clauses[1].Content\n" +
+		"v1 2025-01-02T15:05:00Z unknown: register\n"
+	if log != expected {
+		t.Errorf("Expected change log:\n%s\nGot:\n%s", expected, log)
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	registry := NewDocumentRegistry()
+	if _, err := registry.Get("missing"); !errors.Is(err, ErrPrototypeNotFound) {
+		t.Errorf("Expected ErrPrototypeNotFound, got %v", err)
+	}
+	if _, err := registry.Update("missing", &Document{}, "", ""); !errors.Is(err, ErrPrototypeNotFound) {
+		t.Errorf("Expected ErrPrototypeNotFound, got %v", err)
+	}
+	if err := registry.Register("nil", (*Document)(nil)); err == nil {
+		t.Error("Expected an error registering a nil prototype")
+	}
+
+	registry.Register("doc", &Document{Name: "Doc"})
+	if _, err := registry.Rollback("doc", 7, ""); err == nil {
+		t.Error("Expected an error rolling back to a missing version")
+	}
+}
+
+func TestRegistryPersistence(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "templates")
+	registry, err := OpenDocumentRegistry(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	templates := map[string]Prototype{
+		"quarterly/report": &ReportDocument{
+			Document: Document{Name: "Report", Metadata: map[string]string{"owner": "finance"}},
+			Title:    "Q{{quarter}} Report",
+			Sections: []ReportSection{{Title: "Revenue", Charts: []Chart{{Type: "bar", Data: map[string]float64{"Q1": 1.5}}}}},
+		},
+		"feedback":  &FormDocument{Title: "Feedback", Fields: []FormField{{Name: "rating", Options: []string{"1", "2"}}}},
+		"contract":  contractTemplate(),
+		"invoice":   &InvoiceDocument{InvoiceNumber: "INV-1", LineItems: []LineItem{{Description: "Hosting", Quantity: 2, UnitPrice: 10, Amount: 20}}},
+		"plain-doc": &Document{Name: "Plain"},
+	}
+	for name, template := range templates {
+		if err := registry.Register(name, template); err != nil {
+			t.Fatalf("Registering %s: %v", name, err)
+		}
+	}
+	registry.Update("feedback", &FormDocument{Title: "Customer Feedback"}, "carol", "rename")
+	if err := registry.Unregister("plain-doc"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reopened, err := OpenDocumentRegistry(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error reopening: %v", err)
+	}
+	if names := strings.Join(reopened.List(), ","); names != "contract,feedback,invoice,quarterly/report" {
+		t.Errorf("Unexpected templates after reopening: %s", names)
+	}
+
+	report, err := reopened.Get("quarterly/report")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r, ok := report.(*ReportDocument); !ok || r.Sections[0].Charts[0].Data["Q1"] != 1.5 || r.Metadata["owner"] != "finance" {
+		t.Errorf("Expected the report to round-trip, got %#v", report)
+	}
+	if invoice, _ := reopened.Get("invoice"); invoice.(*InvoiceDocument).LineItems[0].Description != "Hosting" {
+		t.Errorf("Expected the invoice to round-trip, got %#v", invoice)
+	}
+
+	history, err := reopened.History("feedback")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected 2 versions of feedback, got %d, %v", len(history), err)
+	}
+	if history[1].Author != "carol" || history[1].Document.(*FormDocument).Title != "Customer Feedback" {
+		t.Errorf("Unexpected second version: %+v", history[1])
+	}
+	if _, err := reopened.Rollback("feedback", 1, "carol"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if form, _ := reopened.Get("feedback"); form.(*FormDocument).Fields[0].Options[1] != "2" {
+		t.Errorf("Expected the rollback to restore the fields, got %#v", form)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "quarterly%2Freport.template.json")); err != nil {
+		t.Errorf("Expected the name to be escaped in the file name: %v", err)
+	}
+}
+
+func TestCloneWithVariables(t *testing.T) {
+	registry := NewDocumentRegistry()
+	registry.Register("service", contractTemplate())
+
+	cloned, err := registry.CloneWith("service", map[string]interface{}{
+		"customer": map[string]interface{}{"name": "XYZ Corp", "email": "ceo@xyz.example"},
+		"fee":      1200,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	contract := cloned.(*ContractDocument)
+	if contract.Title != "Service Agreement with XYZ Corp" {
+		t.Errorf("Unexpected title %q", contract.Title)
+	}
+	if contract.Parties[1].Name != "XYZ Corp" || contract.Parties[1].Details["contact"] != "ceo@xyz.example" {
+		t.Errorf("Unexpected party %+v", contract.Parties[1])
+	}
+	if contract.Clauses[1].Content != "The monthly fee is 1200 EUR." {
+		t.Errorf("Unexpected clause %q", contract.Clauses[1].Content)
+	}
+
+	// The template keeps its placeholders
+	template, _ := registry.Get("service")
+	if template.(*ContractDocument).Parties[1].Details["contact"] != "{{ customer.email }}" {
+		t.Error("Expected the template to be unchanged")
+	}
+
+	_, err = registry.CloneWith("service", map[string]interface{}{"customer.name": "XYZ Corp"})
+	if !errors.Is(err, ErrMissingVariable) || !strings.Contains(err.Error(), "customer.email, fee") {
+		t.Errorf("Expected the missing variables to be listed, got %v", err)
+	}
+
+	if names := strings.Join(Placeholders(template), ","); names != "customer.email,customer.name,fee" {
+		t.Errorf("Unexpected placeholders %s", names)
+	}
+}
+
+func TestSubstituteInvoiceLineItems(t *testing.T) {
+	invoice := &InvoiceDocument{
+		CustomerInfo: Customer{Name: "{{customer.name}}"},
+		LineItems:    []LineItem{{Description: "Support for {{month}}"}},
+	}
+	err := Substitute(invoice, map[string]interface{}{
+		"customer": map[string]string{"name": "XYZ"},
+		"month":    "March",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if invoice.CustomerInfo.Name != "XYZ" || invoice.LineItems[0].Description != "Support for March" {
+		t.Errorf("Unexpected invoice %+v", invoice)
+	}
+}
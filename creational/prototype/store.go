@@ -0,0 +1,299 @@
+package prototype
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// templateExt is the extension of the files templates are saved in.
+const templateExt = ".template.json"
+
+// documentTypes maps the type names used in saved templates to the
+// concrete prototypes they decode into.
+var documentTypes = struct {
+	sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}{
+	byName: make(map[string]reflect.Type),
+	byType: make(map[reflect.Type]string),
+}
+
+func init() {
+	RegisterDocumentType("document", &Document{})
+	RegisterDocumentType("report", &ReportDocument{})
+	RegisterDocumentType("form", &FormDocument{})
+	RegisterDocumentType("contract", &ContractDocument{})
+	RegisterDocumentType("invoice", &InvoiceDocument{})
+}
+
+// RegisterDocumentType makes a prototype type storable under a type name.
+// The example must be a pointer to a struct that encoding/json can handle.
+// The built-in document types are registered as document, report, form,
+// contract and invoice.
+func RegisterDocumentType(name string, example Prototype) {
+	t := reflect.TypeOf(example)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("prototype: document type %s must be a pointer to a struct, got %T", name, example))
+	}
+
+	documentTypes.Lock()
+	defer documentTypes.Unlock()
+
+	if existing, ok := documentTypes.byName[name]; ok && existing != t {
+		panic(fmt.Sprintf("prototype: document type %s is already registered for %s", name, existing))
+	}
+	documentTypes.byName[name] = t
+	documentTypes.byType[t] = name
+}
+
+// documentTypeName returns the type name a prototype is saved under.
+func documentTypeName(prototype Prototype) (string, error) {
+	documentTypes.RLock()
+	defer documentTypes.RUnlock()
+
+	name, ok := documentTypes.byType[reflect.TypeOf(prototype)]
+	if !ok {
+		return "", fmt.Errorf("unsupported prototype type %T; register it with RegisterDocumentType", prototype)
+	}
+	return name, nil
+}
+
+// newDocument creates an empty prototype of a saved type name.
+func newDocument(typeName string) (Prototype, error) {
+	documentTypes.RLock()
+	defer documentTypes.RUnlock()
+
+	t, ok := documentTypes.byName[typeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown document type %q", typeName)
+	}
+	return reflect.New(t.Elem()).Interface().(Prototype), nil
+}
+
+// snapshotOf returns an independent copy of a prototype by encoding and
+// decoding it. Unlike DeepClone, it leaves names and numbers unchanged.
+func snapshotOf(prototype Prototype) (Prototype, error) {
+	if prototype == nil || reflect.ValueOf(prototype).IsNil() {
+		return nil, fmt.Errorf("prototype must not be nil")
+	}
+	typeName, err := documentTypeName(prototype)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(prototype)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := newDocument(typeName)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// savedTemplate is the JSON form of a template and its history.
+type savedTemplate struct {
+	Name      string          `json:"name"`
+	Revisions []savedRevision `json:"revisions"`
+}
+
+// savedRevision is the JSON form of a revision. The type name tells which
+// prototype the document decodes into.
+type savedRevision struct {
+	Version  int             `json:"version"`
+	Time     time.Time       `json:"time"`
+	Author   string          `json:"author,omitempty"`
+	Message  string          `json:"message,omitempty"`
+	Changes  []string        `json:"changes,omitempty"`
+	Type     string          `json:"type"`
+	Document json.RawMessage `json:"document"`
+}
+
+// templatePath returns the file a template is saved in. The name is escaped
+// so that any name gives a valid file name inside dir.
+func templatePath(dir, name string) string {
+	return filepath.Join(dir, url.PathEscape(name)+templateExt)
+}
+
+// saveTemplate writes a template with its history to dir. The file is
+// replaced atomically, so a failed save leaves the previous one intact.
+func saveTemplate(dir, name string, history *templateHistory) error {
+	saved := savedTemplate{Name: name}
+	for _, revision := range history.revisions {
+		typeName, err := documentTypeName(revision.Document)
+		if err != nil {
+			return err
+		}
+		document, err := json.Marshal(revision.Document)
+		if err != nil {
+			return fmt.Errorf("encoding %s version %d: %w", name, revision.Version, err)
+		}
+		saved.Revisions = append(saved.Revisions, savedRevision{
+			Version:  revision.Version,
+			Time:     revision.Time,
+			Author:   revision.Author,
+			Message:  revision.Message,
+			Changes:  revision.Changes,
+			Type:     typeName,
+			Document: document,
+		})
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), templatePath(dir, name))
+}
+
+// removeTemplate deletes the file of a template, if there is one.
+func removeTemplate(dir, name string) error {
+	err := os.Remove(templatePath(dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// loadTemplates reads every template saved in dir.
+func loadTemplates(dir string) (map[string]*templateHistory, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]*templateHistory, len(paths))
+	for _, path := range paths {
+		name, history, err := loadTemplate(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", filepath.Base(path), err)
+		}
+		templates[name] = history
+	}
+
+	return templates, nil
+}
+
+// loadTemplate reads one template file.
+func loadTemplate(path string) (string, *templateHistory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	var saved savedTemplate
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return "", nil, err
+	}
+	if saved.Name == "" || len(saved.Revisions) == 0 {
+		return "", nil, fmt.Errorf("template has no name or no versions")
+	}
+
+	history := &templateHistory{}
+	for _, revision := range saved.Revisions {
+		document, err := newDocument(revision.Type)
+		if err != nil {
+			return "", nil, fmt.Errorf("version %d: %w", revision.Version, err)
+		}
+		if err := json.Unmarshal(revision.Document, document); err != nil {
+			return "", nil, fmt.Errorf("version %d: %w", revision.Version, err)
+		}
+		history.revisions = append(history.revisions, Revision{
+			Version:  revision.Version,
+			Time:     revision.Time,
+			Author:   revision.Author,
+			Message:  revision.Message,
+			Changes:  revision.Changes,
+			Document: document,
+		})
+	}
+
+	return saved.Name, history, nil
+}
+
+// changedFields returns the paths of the fields that differ between two
+// versions of a document, such as "Title" or "Clauses[1].Content".
+func changedFields(old, new Prototype) []string {
+	var oldValue, newValue interface{}
+	if data, err := json.Marshal(old); err == nil {
+		json.Unmarshal(data, &oldValue)
+	}
+	if data, err := json.Marshal(new); err == nil {
+		json.Unmarshal(data, &newValue)
+	}
+
+	var changes []string
+	diffValues("", oldValue, newValue, &changes)
+	return changes
+}
+
+// diffValues compares two decoded JSON values and records the paths where
+// they differ. Embedded structs are flattened by encoding/json, so the
+// fields of Document appear at the top level.
+func diffValues(path string, old, new interface{}, changes *[]string) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range o {
+			keys[k] = true
+		}
+		for k := range n {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			diffValues(child, o[k], n[k], changes)
+		}
+		return
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok || len(n) != len(o) {
+			break
+		}
+		for i := range o {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), o[i], n[i], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, strings.TrimPrefix(path, "."))
+	}
+}
@@ -0,0 +1,157 @@
+package prototype
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrMissingVariable is returned when a placeholder has no value.
+var ErrMissingVariable = errors.New("missing variable")
+
+// placeholder matches {{name}} placeholders. Names are dotted paths such as
+// customer.name, and spaces inside the braces are allowed.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+(?:\.[A-Za-z0-9_]+)*)\s*\}\}`)
+
+// Substitute replaces the placeholders in every string of a document, such
+// as titles, clauses and line item descriptions, in place.
+//
+// A placeholder like {{customer.name}} is looked up first as the key
+// "customer.name" and then as the path customer -> name through nested
+// maps. Values are formatted with fmt.Sprint. If any placeholder has no
+// value, the document is left unchanged and the error lists every missing
+// variable.
+func Substitute(document Prototype, variables map[string]interface{}) error {
+	v := reflect.ValueOf(document)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("cannot substitute variables in %T", document)
+	}
+
+	missing := make(map[string]bool)
+	walkStrings(v, func(s string) string {
+		for _, name := range placeholderNames(s) {
+			if _, ok := lookupVariable(variables, name); !ok {
+				missing[name] = true
+			}
+		}
+		return s
+	})
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%w: %s", ErrMissingVariable, strings.Join(names, ", "))
+	}
+
+	walkStrings(v, func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(match string) string {
+			value, _ := lookupVariable(variables, placeholder.FindStringSubmatch(match)[1])
+			return fmt.Sprint(value)
+		})
+	})
+	return nil
+}
+
+// Placeholders returns the names of the variables a document uses, in
+// alphabetical order.
+func Placeholders(document Prototype) []string {
+	seen := make(map[string]bool)
+	walkStrings(reflect.ValueOf(document), func(s string) string {
+		for _, name := range placeholderNames(s) {
+			seen[name] = true
+		}
+		return s
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// placeholderNames returns the variable names used in a string.
+func placeholderNames(s string) []string {
+	var names []string
+	for _, match := range placeholder.FindAllStringSubmatch(s, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// lookupVariable finds the value of a dotted variable name.
+func lookupVariable(variables map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := variables[name]; ok {
+		return value, true
+	}
+
+	var current interface{} = variables
+	for _, part := range strings.Split(name, ".") {
+		switch m := current.(type) {
+		case map[string]interface{}:
+			value, ok := m[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case map[string]string:
+			value, ok := m[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// walkStrings calls replace for every settable string reachable from v,
+// including strings in slices, arrays, nested structs and map values, and
+// stores the result.
+func walkStrings(v reflect.Value, replace func(string) string) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), replace)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkStrings(v.Field(i), replace)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStrings(v.Index(i), replace)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			value := iter.Value()
+			if value.Kind() == reflect.String {
+				if replaced := replace(value.String()); replaced != value.String() {
+					v.SetMapIndex(iter.Key(), reflect.ValueOf(replaced).Convert(value.Type()))
+				}
+				continue
+			}
+			// Map values are not addressable, so nested values are
+			// replaced through a copy
+			copied := reflect.New(value.Type()).Elem()
+			copied.Set(value)
+			walkStrings(copied, replace)
+			v.SetMapIndex(iter.Key(), copied)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(replace(v.String()))
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package singleton
+
+// The reset hooks live in a test file, so only this package's tests can
+// discard shared instances. Resetting a singleton in a running program
+// would hand different callers different instances.
+
+// ResetInstanceForTesting discards the singleton, so the next GetInstance
+// creates a fresh one
+func ResetInstanceForTesting() {
+	instance.ResetForTesting()
+}
+
+// ResetForTesting discards the value, so the next Get calls init again.
+// The state is swapped atomically, so Get may run concurrently.
+func (l *Lazy[T]) ResetForTesting() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.state.Store(nil)
+}
+
+// ResetForTesting discards all instances
+func (m *Multiton[K, V]) ResetForTesting() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.instances = make(map[K]*Lazy[V])
+}
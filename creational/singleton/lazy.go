@@ -0,0 +1,66 @@
+package singleton
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Lazy holds a value that is created on first use. Unlike sync.Once, the
+// init function can fail: the error is returned to the caller and the next
+// Get tries again, so a temporary failure such as an unreachable database
+// does not break the value for the life of the process.
+//
+// A Lazy must not be copied after first use.
+type Lazy[T any] struct {
+	init  func() (T, error)
+	mu    sync.Mutex
+	state atomic.Pointer[lazyState[T]] // nil until init succeeds
+}
+
+// lazyState is the created value. It is published with a single atomic
+// store, so Get never sees a value without it being complete.
+type lazyState[T any] struct {
+	value T
+}
+
+// NewLazy creates a Lazy that calls init to create its value
+func NewLazy[T any](init func() (T, error)) *Lazy[T] {
+	return &Lazy[T]{init: init}
+}
+
+// Get returns the value, creating it if this is the first successful call.
+// Concurrent callers wait for a single call of init. If init fails, its
+// error is returned and nothing is stored.
+func (l *Lazy[T]) Get() (T, error) {
+	if s := l.state.Load(); s != nil {
+		return s.value, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s := l.state.Load(); s != nil {
+		return s.value, nil
+	}
+
+	value, err := l.init()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	l.state.Store(&lazyState[T]{value: value})
+	return value, nil
+}
+
+// MustGet is like Get but panics if the value cannot be created
+func (l *Lazy[T]) MustGet() T {
+	value, err := l.Get()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Initialized reports whether the value has been created
+func (l *Lazy[T]) Initialized() bool {
+	return l.state.Load() != nil
+}
@@ -0,0 +1,177 @@
+package singleton
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	var calls atomic.Int32
+	lazy := NewLazy(func() (string, error) {
+		calls.Add(1)
+		return "value", nil
+	})
+	if lazy.Initialized() {
+		t.Error("Expected the value not to be created before Get")
+	}
+
+	const numGoroutines = 100
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines)
+	for i := 0; i < numGoroutines; i++ {
+		go func() {
+			defer wg.Done()
+			if value, err := lazy.Get(); err != nil || value != "value" {
+				t.Errorf("Expected value, got %q, %v", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected init to be called once, got %d", calls.Load())
+	}
+	if !lazy.Initialized() {
+		t.Error("Expected the value to be created")
+	}
+}
+
+func TestLazyRetriesAfterFailure(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+	attempts := 0
+	lazy := NewLazy(func() (int, error) {
+		attempts++
+		if attempts < 3 {
+			return 0, errUnavailable
+		}
+		return 42, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := lazy.Get(); !errors.Is(err, errUnavailable) {
+			t.Fatalf("Attempt %d: expected the init error, got %v", i+1, err)
+		}
+		if lazy.Initialized() {
+			t.Fatal("Expected a failed init not to be stored")
+		}
+	}
+	if value := lazy.MustGet(); value != 42 {
+		t.Errorf("Expected 42, got %d", value)
+	}
+	lazy.Get()
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	lazy.ResetForTesting()
+	if lazy.Initialized() {
+		t.Error("Expected reset to discard the value")
+	}
+	lazy.Get()
+	if attempts != 4 {
+		t.Errorf("Expected init to run again after reset, got %d attempts", attempts)
+	}
+}
+
+func TestLazyResetDuringGet(t *testing.T) {
+	var created atomic.Int32
+	lazy := NewLazy(func() (*connection, error) {
+		created.Add(1)
+		return &connection{dsn: "primary"}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if conn := lazy.MustGet(); conn == nil || conn.dsn != "primary" {
+					t.Errorf("Expected a complete value, got %v", conn)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		lazy.ResetForTesting()
+	}
+	wg.Wait()
+
+	if created.Load() < 1 {
+		t.Error("Expected init to run")
+	}
+}
+
+func TestMustGetPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected MustGet to panic")
+		}
+	}()
+	NewLazy(func() (int, error) { return 0, errors.New("failed") }).MustGet()
+}
+
+// connection is an instance created per key
+type connection struct {
+	dsn string
+}
+
+func TestMultiton(t *testing.T) {
+	var calls sync.Map
+	pools := NewMultiton(func(dsn string) (*connection, error) {
+		count, _ := calls.LoadOrStore(dsn, new(atomic.Int32))
+		count.(*atomic.Int32).Add(1)
+		if dsn == "" {
+			return nil, errors.New("empty DSN")
+		}
+		return &connection{dsn: dsn}, nil
+	})
+
+	const numGoroutines = 50
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines)
+	for i := 0; i < numGoroutines; i++ {
+		dsn := []string{"primary", "replica"}[i%2]
+		go func() {
+			defer wg.Done()
+			conn, err := pools.Get(dsn)
+			if err != nil || conn.dsn != dsn {
+				t.Errorf("Expected a connection to %s, got %v, %v", dsn, conn, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	primary1, _ := pools.Get("primary")
+	primary2, _ := pools.Get("primary")
+	replica, _ := pools.Get("replica")
+	if primary1 != primary2 || primary1 == replica {
+		t.Error("Expected one instance per key")
+	}
+	for _, dsn := range []string{"primary", "replica"} {
+		if count, _ := calls.Load(dsn); count.(*atomic.Int32).Load() != 1 {
+			t.Errorf("Expected %s to be created once, got %d", dsn, count.(*atomic.Int32).Load())
+		}
+	}
+
+	if _, err := pools.Get(""); err == nil {
+		t.Error("Expected the init error")
+	}
+	keys := pools.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "primary" || keys[1] != "replica" || pools.Len() != 2 {
+		t.Errorf("Expected the created keys, got %v", keys)
+	}
+
+	pools.ResetForTesting()
+	if pools.Len() != 0 {
+		t.Error("Expected reset to discard all instances")
+	}
+	if fresh, _ := pools.Get("primary"); fresh == primary1 {
+		t.Error("Expected a new instance after reset")
+	}
+}
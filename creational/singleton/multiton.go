@@ -0,0 +1,57 @@
+package singleton
+
+import (
+	"sync"
+)
+
+// Multiton holds one instance per key, each created on first use. Instances
+// for different keys are created independently, so a slow or failing key
+// does not block the others. Failed creations are retried on the next Get,
+// as with Lazy.
+type Multiton[K comparable, V any] struct {
+	init      func(key K) (V, error)
+	mu        sync.Mutex
+	instances map[K]*Lazy[V]
+}
+
+// NewMultiton creates a Multiton that calls init to create the instance
+// for a key
+func NewMultiton[K comparable, V any](init func(key K) (V, error)) *Multiton[K, V] {
+	return &Multiton[K, V]{
+		init:      init,
+		instances: make(map[K]*Lazy[V]),
+	}
+}
+
+// Get returns the instance for a key, creating it if needed
+func (m *Multiton[K, V]) Get(key K) (V, error) {
+	m.mu.Lock()
+	lazy, ok := m.instances[key]
+	if !ok {
+		lazy = NewLazy(func() (V, error) { return m.init(key) })
+		m.instances[key] = lazy
+	}
+	m.mu.Unlock()
+
+	return lazy.Get()
+}
+
+// Keys returns the keys whose instances have been created, in no
+// particular order
+func (m *Multiton[K, V]) Keys() []K {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]K, 0, len(m.instances))
+	for key, lazy := range m.instances {
+		if lazy.Initialized() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of instances that have been created
+func (m *Multiton[K, V]) Len() int {
+	return len(m.Keys())
+}
@@ -1,32 +1,32 @@
 package singleton
 
 import (
-	"sync"
+	"sync/atomic"
 )
 
-type singleton struct {
-	count int
+// Singleton is the single shared instance returned by GetInstance. Its
+// methods are safe for concurrent use.
+type Singleton struct {
+	count atomic.Int64
 }
 
-var (
-	instance *singleton
-	once     sync.Once
-)
+// instance creates the singleton on first use
+var instance = NewLazy(func() (*Singleton, error) {
+	return &Singleton{}, nil
+})
 
-// GetInstance returns the single instance of singleton
-func GetInstance() *singleton {
-	once.Do(func() {
-		instance = &singleton{count: 0}
-	})
-	return instance
+// GetInstance returns the single instance of Singleton
+func GetInstance() *Singleton {
+	s, _ := instance.Get() // Creating the singleton cannot fail
+	return s
 }
 
 // IncrementCount increments the counter
-func (s *singleton) IncrementCount() {
-	s.count++
+func (s *Singleton) IncrementCount() {
+	s.count.Add(1)
 }
 
 // GetCount returns the current count
-func (s *singleton) GetCount() int {
-	return s.count
+func (s *Singleton) GetCount() int {
+	return int(s.count.Load())
 }
@@ -6,6 +6,7 @@ import (
 )
 
 func TestSingleton(t *testing.T) {
+	ResetInstanceForTesting()
 	instance1 := GetInstance()
 	instance2 := GetInstance()
 
@@ -24,6 +25,7 @@ func TestSingleton(t *testing.T) {
 }
 
 func TestConcurrentSingleton(t *testing.T) {
+	ResetInstanceForTesting()
 	const numGoroutines = 100
 	var wg sync.WaitGroup
 
@@ -42,3 +44,17 @@ func TestConcurrentSingleton(t *testing.T) {
 		t.Errorf("Expected count to be %d, got %d", numGoroutines, instance.GetCount())
 	}
 }
+
+func TestResetInstanceForTesting(t *testing.T) {
+	instance := GetInstance()
+	instance.IncrementCount()
+
+	ResetInstanceForTesting()
+	fresh := GetInstance()
+	if fresh == instance {
+		t.Error("Expected a new instance after reset")
+	}
+	if fresh.GetCount() != 0 {
+		t.Errorf("Expected count to be 0, got %d", fresh.GetCount())
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/edgardnogueira/go-patterns
 
-go 1.21
+go 1.24
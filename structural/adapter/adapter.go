@@ -1,88 +1,147 @@
 package adapter
 
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
 // MediaPlayer is the target interface that the client expects
 type MediaPlayer interface {
-	Play(audioType, fileName string)
+	Play(fileName string) (TrackInfo, error)
 }
 
-// AdvancedMediaPlayer is the adaptee interface
+// AdvancedMediaPlayer is the adaptee interface. Its methods read a whole
+// stream and return the format's own header structures rather than the
+// TrackInfo the client expects.
 type AdvancedMediaPlayer interface {
-	PlayFLAC(fileName string)
-	PlayWAV(fileName string)
+	PlayFLAC(r io.Reader) (*FLACStream, error)
+	PlayWAV(r io.Reader) (*WAVStream, error)
 }
 
 // FLACPlayer is a concrete implementation of AdvancedMediaPlayer for FLAC files
 type FLACPlayer struct{}
 
-// PlayFLAC plays FLAC files
-func (p *FLACPlayer) PlayFLAC(fileName string) string {
-	return "Playing FLAC file: " + fileName
+// PlayFLAC reads the metadata of a FLAC stream
+func (p *FLACPlayer) PlayFLAC(r io.Reader) (*FLACStream, error) {
+	return ParseFLAC(r)
 }
 
 // PlayWAV is not implemented for FLAC player
-func (p *FLACPlayer) PlayWAV(fileName string) string {
-	return "FLAC player cannot play WAV files"
+func (p *FLACPlayer) PlayWAV(r io.Reader) (*WAVStream, error) {
+	return nil, fmt.Errorf("%w: FLAC player cannot play WAV files", ErrUnsupportedFormat)
 }
 
 // WAVPlayer is a concrete implementation of AdvancedMediaPlayer for WAV files
 type WAVPlayer struct{}
 
 // PlayFLAC is not implemented for WAV player
-func (p *WAVPlayer) PlayFLAC(fileName string) string {
-	return "WAV player cannot play FLAC files"
+func (p *WAVPlayer) PlayFLAC(r io.Reader) (*FLACStream, error) {
+	return nil, fmt.Errorf("%w: WAV player cannot play FLAC files", ErrUnsupportedFormat)
 }
 
-// PlayWAV plays WAV files
-func (p *WAVPlayer) PlayWAV(fileName string) string {
-	return "Playing WAV file: " + fileName
+// PlayWAV reads the header of a WAV stream
+func (p *WAVPlayer) PlayWAV(r io.Reader) (*WAVStream, error) {
+	return ParseWAV(r)
 }
 
 // MediaAdapter is the adapter that adapts AdvancedMediaPlayer to MediaPlayer
 type MediaAdapter struct {
 	AdvancedMediaPlayer AdvancedMediaPlayer
+	Format              Format
 }
 
-// NewMediaAdapter creates a new MediaAdapter for the given audio type
-func NewMediaAdapter(audioType string) *MediaAdapter {
-	switch audioType {
-	case "flac":
-		return &MediaAdapter{AdvancedMediaPlayer: &FLACPlayer{}}
-	case "wav":
-		return &MediaAdapter{AdvancedMediaPlayer: &WAVPlayer{}}
+// NewMediaAdapter creates a new MediaAdapter for the given format, or
+// returns nil if no advanced player supports it
+func NewMediaAdapter(format Format) *MediaAdapter {
+	switch format {
+	case FormatFLAC:
+		return &MediaAdapter{AdvancedMediaPlayer: &FLACPlayer{}, Format: format}
+	case FormatWAV:
+		return &MediaAdapter{AdvancedMediaPlayer: &WAVPlayer{}, Format: format}
 	default:
 		return nil
 	}
 }
 
 // Play adapts the AdvancedMediaPlayer to MediaPlayer interface
-func (a *MediaAdapter) Play(audioType, fileName string) string {
-	switch audioType {
-	case "flac":
-		return a.AdvancedMediaPlayer.PlayFLAC(fileName)
-	case "wav":
-		return a.AdvancedMediaPlayer.PlayWAV(fileName)
+func (a *MediaAdapter) Play(fileName string) (TrackInfo, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return TrackInfo{}, err
+	}
+	defer f.Close()
+
+	info, err := a.PlayReader(f)
+	if err != nil {
+		return TrackInfo{}, fmt.Errorf("%s: %w", fileName, err)
+	}
+	info.FileName = fileName
+	return info, nil
+}
+
+// PlayReader reads a stream with the advanced player and converts its
+// header into a TrackInfo
+func (a *MediaAdapter) PlayReader(r io.Reader) (TrackInfo, error) {
+	switch a.Format {
+	case FormatFLAC:
+		stream, err := a.AdvancedMediaPlayer.PlayFLAC(r)
+		if err != nil {
+			return TrackInfo{}, err
+		}
+		return stream.TrackInfo(), nil
+	case FormatWAV:
+		stream, err := a.AdvancedMediaPlayer.PlayWAV(r)
+		if err != nil {
+			return TrackInfo{}, err
+		}
+		return stream.TrackInfo(), nil
 	default:
-		return "Invalid media type"
+		return TrackInfo{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, a.Format)
 	}
 }
 
 // AudioPlayer is the client that uses the MediaPlayer interface
 type AudioPlayer struct{}
 
-// Play plays the audio file
-func (p *AudioPlayer) Play(audioType, fileName string) string {
-	// Native support for mp3 format
-	if audioType == "mp3" {
-		return "Playing MP3 file: " + fileName
+// Play reads the header of an audio file. The format is detected from the
+// file's first bytes, not its extension: MP3 is supported natively, and
+// FLAC and WAV through the adapter.
+func (p *AudioPlayer) Play(fileName string) (TrackInfo, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return TrackInfo{}, err
 	}
+	defer f.Close()
 
-	// For other formats, use adapter
-	if audioType == "flac" || audioType == "wav" {
-		adapter := NewMediaAdapter(audioType)
-		if adapter != nil {
-			return adapter.Play(audioType, fileName)
+	info, err := p.PlayReader(f)
+	if err != nil {
+		return TrackInfo{}, fmt.Errorf("%s: %w", fileName, err)
+	}
+	info.FileName = fileName
+	return info, nil
+}
+
+// PlayReader is like Play but reads from r
+func (p *AudioPlayer) PlayReader(r io.Reader) (TrackInfo, error) {
+	format, r, err := DetectFormat(r)
+	if err != nil {
+		return TrackInfo{}, err
+	}
+
+	// Native support for mp3 format
+	if format == FormatMP3 {
+		stream, err := ParseMP3(r)
+		if err != nil {
+			return TrackInfo{}, err
 		}
+		return stream.TrackInfo(), nil
 	}
 
-	return "Invalid media type: " + audioType + ", file not supported"
+	// For other formats, use adapter
+	adapter := NewMediaAdapter(format)
+	if adapter == nil {
+		return TrackInfo{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	return adapter.PlayReader(r)
 }
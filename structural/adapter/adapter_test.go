@@ -1,111 +1,336 @@
 package adapter
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
-func TestAudioPlayerWithMP3(t *testing.T) {
-	player := &AudioPlayer{}
-	result := player.Play("mp3", "song.mp3")
+// approx reports whether two durations are within a millisecond
+func approx(a, b time.Duration) bool {
+	d := a - b
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+func TestAudioPlayerWithWAV(t *testing.T) {
+	path := writeFixture(t, "tone.wav", wavFixture(44100, 2, 22050, map[string]string{
+		"INAM": "Tone",
+		"IART": "Generator",
+		"ICMT": "odd",
+	}))
 
-	expected := "Playing MP3 file: song.mp3"
-	if result != expected {
-		t.Errorf("Expected '%s', got '%s'", expected, result)
+	info, err := (&AudioPlayer{}).Play(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.Format != FormatWAV || info.SampleRate != 44100 || info.Channels != 2 || info.BitDepth != 16 {
+		t.Errorf("Unexpected format: %+v", info)
+	}
+	if info.Bitrate != 1411200 || info.Duration != 500*time.Millisecond {
+		t.Errorf("Expected 1411200 bps for 500ms, got %d bps for %s", info.Bitrate, info.Duration)
+	}
+	if info.Title != "Tone" || info.Artist != "Generator" || info.Tags["COMMENT"] != "odd" {
+		t.Errorf("Unexpected tags: %+v", info.Tags)
+	}
+	if info.FileName != path {
+		t.Errorf("Expected file name %s, got %s", path, info.FileName)
+	}
+	expected := "Playing WAV file: " + path + " (44100 Hz, 2 ch, 16-bit, 1411 kbps, 500ms) - Generator - Tone"
+	if info.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, info.String())
 	}
 }
 
 func TestAudioPlayerWithFLAC(t *testing.T) {
-	player := &AudioPlayer{}
-	result := player.Play("flac", "song.flac")
+	path := writeFixture(t, "song.flac", flacFixture(48000, 2, 24, 96000,
+		"TITLE=Song", "ARTIST=First", "artist=Second", "album=Album"))
 
-	expected := "Playing FLAC file: song.flac"
-	if result != expected {
-		t.Errorf("Expected '%s', got '%s'", expected, result)
+	info, err := (&AudioPlayer{}).Play(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.Format != FormatFLAC || info.SampleRate != 48000 || info.Channels != 2 || info.BitDepth != 24 {
+		t.Errorf("Unexpected format: %+v", info)
+	}
+	if info.Duration != 2*time.Second {
+		t.Errorf("Expected 2s, got %s", info.Duration)
+	}
+	if info.Title != "Song" || info.Artist != "First; Second" || info.Album != "Album" {
+		t.Errorf("Unexpected tags: %+v", info.Tags)
+	}
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	stream, err := (&FLACPlayer{}).PlayFLAC(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stream.Vendor != "reference libFLAC 1.4.3" || stream.MaxBlockSize != 4096 || stream.MD5[0] != 0xAB {
+		t.Errorf("Unexpected STREAMINFO: %+v", stream)
 	}
 }
 
-func TestAudioPlayerWithWAV(t *testing.T) {
-	player := &AudioPlayer{}
-	result := player.Play("wav", "song.wav")
+func TestAudioPlayerWithMP3(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		title    string
+		artist   string
+		version  string
+		channels int
+		duration time.Duration
+	}{
+		{
+			name: "ID3v2.3 with UTF-16 and Latin-1 text, constant bitrate",
+			data: append(id3Tag(3,
+				id3Frame(3, "TIT2", utf16Text("Beyond the Horizon")),
+				id3Frame(3, "TPE1", []byte("\x00Mot\xf6rhead")),
+				id3Frame(3, "COMM", []byte("\x00engdesc\x00A comment")),
+				id3Frame(3, "APIC", []byte("\x00image/png\x00\x03\x00PNG...")),
+			), mpegFrames(40, false, false)...),
+			title:    "Beyond the Horizon",
+			artist:   "Motörhead",
+			version:  "2.3.0",
+			channels: 2,
+			duration: 40 * 417 * 8 * time.Second / 128000,
+		},
+		{
+			name: "ID3v2.4 with UTF-8 text and TLEN",
+			data: append(id3Tag(4,
+				id3Frame(4, "TIT2", []byte("\x03Caf\xc3\xa9")),
+				id3Frame(4, "TPE1", []byte("\x03A\x00B")),
+				id3Frame(4, "TLEN", []byte("\x03123456")),
+			), mpegFrames(3, true, false)...),
+			title:    "Café",
+			artist:   "A; B",
+			version:  "2.4.0",
+			channels: 1,
+			duration: 123456 * time.Millisecond,
+		},
+		{
+			name:     "no tag with an Info header",
+			data:     mpegFrames(100, false, true),
+			version:  "",
+			channels: 2,
+			duration: 100 * 1152 * time.Second / 44100,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFixture(t, "track.mp3", test.data)
+			info, err := (&AudioPlayer{}).Play(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if info.Format != FormatMP3 || info.SampleRate != 44100 || info.Channels != test.channels || info.Bitrate != 128000 {
+				t.Errorf("Unexpected format: %+v", info)
+			}
+			if info.Title != test.title || info.Artist != test.artist {
+				t.Errorf("Expected %q by %q, got %q by %q", test.title, test.artist, info.Title, info.Artist)
+			}
+			if !approx(info.Duration, test.duration) {
+				t.Errorf("Expected %s, got %s", test.duration, info.Duration)
+			}
+
+			f, _ := os.Open(path)
+			defer f.Close()
+			stream, err := ParseMP3(f)
+			if err != nil || stream.ID3Version != test.version || stream.MPEGVersion != "1" || stream.Layer != 3 {
+				t.Errorf("Unexpected stream %+v, %v", stream, err)
+			}
+		})
+	}
+
+	path := writeFixture(t, "comment.mp3", append(id3Tag(3, id3Frame(3, "COMM", []byte("\x00engdesc\x00A comment"))), mpegFrames(1, false, false)...))
+	if info, _ := (&AudioPlayer{}).Play(path); info.Tags["COMMENT"] != "A comment" {
+		t.Errorf("Expected the comment text, got %q", info.Tags["COMMENT"])
+	}
+}
 
-	expected := "Playing WAV file: song.wav"
-	if result != expected {
-		t.Errorf("Expected '%s', got '%s'", expected, result)
+func TestFormatDetectedFromContent(t *testing.T) {
+	// A WAV file with an mp3 extension is still played as WAV
+	path := writeFixture(t, "misnamed.mp3", wavFixture(8000, 1, 800, nil))
+	info, err := (&AudioPlayer{}).Play(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.Format != FormatWAV || info.Duration != 100*time.Millisecond {
+		t.Errorf("Expected a 100ms WAV, got %+v", info)
+	}
+
+	// Readers that cannot seek are buffered
+	reader := io.MultiReader(strings.NewReader(string(flacFixture(44100, 1, 16, 44100))))
+	info, err = (&AudioPlayer{}).PlayReader(reader)
+	if err != nil || info.Format != FormatFLAC || info.Duration != time.Second {
+		t.Errorf("Expected a 1s FLAC, got %+v, %v", info, err)
+	}
+	reader = io.MultiReader(strings.NewReader(string(wavFixture(8000, 1, 800, nil))))
+	if info, err := (&AudioPlayer{}).PlayReader(reader); err != nil || info.Duration != 100*time.Millisecond {
+		t.Errorf("Expected a 100ms WAV, got %+v, %v", info, err)
 	}
 }
 
-func TestAudioPlayerWithInvalidFormat(t *testing.T) {
-	player := &AudioPlayer{}
-	result := player.Play("aac", "song.aac")
+func TestAudioPlayerErrors(t *testing.T) {
+	wav := wavFixture(8000, 1, 800, nil)
+	badFLAC := append([]byte("fLaC"), flacBlock(4, true, nil)...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		err      error
+		contains string
+	}{
+		{"text file", []byte("just some text"), ErrUnknownFormat, "6a 75 73 74"},
+		{"empty file", nil, ErrUnknownFormat, "empty"},
+		{"truncated chunk header", wav[:30], ErrMalformed, "chunk header is truncated"},
+		{"truncated WAV", wav[:40], ErrMalformed, "fmt chunk is truncated"},
+		{"WAV without fmt", riffChunk("RIFF", []byte("WAVE")), ErrMalformed, "missing fmt chunk"},
+		{"WAV without data", wav[:12+12+24], ErrMalformed, "missing data chunk"},
+		{"FLAC without STREAMINFO", badFLAC, ErrMalformed, "want STREAMINFO"},
+		{"FLAC with a bad comment", append(flacFixture(44100, 2, 16, 0)[:42], flacBlock(4, true, []byte{200, 0, 0, 0})...), ErrMalformed, "vendor string is 200 bytes"},
+		{"MP3 tag without audio", id3Tag(3, id3Frame(3, "TIT2", []byte("\x00x"))), ErrMalformed, "no MPEG audio frame"},
+		{"MP3 with an oversized frame", id3Tag(3, []byte{'T', 'I', 'T', '2', 0, 0, 0x10, 0, 0, 0}), ErrMalformed, "only"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFixture(t, "file", test.data)
+			_, err := (&AudioPlayer{}).Play(path)
+			if !errors.Is(err, test.err) || !strings.Contains(err.Error(), test.contains) {
+				t.Errorf("Expected %v containing %q, got %v", test.err, test.contains, err)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), path+": ") {
+				t.Errorf("Expected the error to name the file, got %v", err)
+			}
+		})
+	}
 
-	if !strings.Contains(result, "Invalid media type") {
-		t.Errorf("Expected error message containing 'Invalid media type', got '%s'", result)
+	if _, err := (&AudioPlayer{}).Play("missing.wav"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", err)
 	}
 }
 
 func TestFLACPlayer(t *testing.T) {
 	player := &FLACPlayer{}
-	
-	// Test playing FLAC
-	result := player.PlayFLAC("song.flac")
-	expected := "Playing FLAC file: song.flac"
-	if result != expected {
-		t.Errorf("Expected '%s', got '%s'", expected, result)
-	}
-	
+
 	// Test playing WAV with FLAC player
-	result = player.PlayWAV("song.wav")
-	if !strings.Contains(result, "cannot play WAV") {
-		t.Errorf("Expected error about not being able to play WAV, got '%s'", result)
+	_, err := player.PlayWAV(strings.NewReader(""))
+	if !errors.Is(err, ErrUnsupportedFormat) || !strings.Contains(err.Error(), "cannot play WAV") {
+		t.Errorf("Expected error about not being able to play WAV, got '%v'", err)
 	}
 }
 
 func TestWAVPlayer(t *testing.T) {
 	player := &WAVPlayer{}
-	
-	// Test playing WAV
-	result := player.PlayWAV("song.wav")
-	expected := "Playing WAV file: song.wav"
-	if result != expected {
-		t.Errorf("Expected '%s', got '%s'", expected, result)
-	}
-	
+
 	// Test playing FLAC with WAV player
-	result = player.PlayFLAC("song.flac")
-	if !strings.Contains(result, "cannot play FLAC") {
-		t.Errorf("Expected error about not being able to play FLAC, got '%s'", result)
+	_, err := player.PlayFLAC(strings.NewReader(""))
+	if !errors.Is(err, ErrUnsupportedFormat) || !strings.Contains(err.Error(), "cannot play FLAC") {
+		t.Errorf("Expected error about not being able to play FLAC, got '%v'", err)
 	}
 }
 
 func TestMediaAdapter(t *testing.T) {
 	// Test FLAC adapter
-	flacAdapter := NewMediaAdapter("flac")
+	flacAdapter := NewMediaAdapter(FormatFLAC)
 	if flacAdapter == nil {
-		t.Error("Expected FLAC adapter to be created, got nil")
-	} else {
-		result := flacAdapter.Play("flac", "song.flac")
-		expected := "Playing FLAC file: song.flac"
-		if result != expected {
-			t.Errorf("Expected '%s', got '%s'", expected, result)
-		}
+		t.Fatal("Expected FLAC adapter to be created, got nil")
 	}
-	
+	path := writeFixture(t, "song.flac", flacFixture(44100, 2, 16, 88200))
+	info, err := flacAdapter.Play(path)
+	if err != nil || info.Format != FormatFLAC || info.Duration != 2*time.Second {
+		t.Errorf("Expected a 2s FLAC, got %+v, %v", info, err)
+	}
+
+	// The adapter only plays its own format
+	wavPath := writeFixture(t, "song.wav", wavFixture(8000, 1, 80, nil))
+	if _, err := flacAdapter.Play(wavPath); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected the FLAC adapter to reject a WAV file, got %v", err)
+	}
+
 	// Test WAV adapter
-	wavAdapter := NewMediaAdapter("wav")
+	wavAdapter := NewMediaAdapter(FormatWAV)
 	if wavAdapter == nil {
-		t.Error("Expected WAV adapter to be created, got nil")
-	} else {
-		result := wavAdapter.Play("wav", "song.wav")
-		expected := "Playing WAV file: song.wav"
-		if result != expected {
-			t.Errorf("Expected '%s', got '%s'", expected, result)
-		}
+		t.Fatal("Expected WAV adapter to be created, got nil")
+	}
+	if info, err := wavAdapter.Play(wavPath); err != nil || info.Format != FormatWAV {
+		t.Errorf("Expected a WAV, got %+v, %v", info, err)
 	}
-	
+
 	// Test invalid adapter
-	invalidAdapter := NewMediaAdapter("mp3")
+	invalidAdapter := NewMediaAdapter(FormatMP3)
 	if invalidAdapter != nil {
 		t.Errorf("Expected nil adapter for unsupported format, got %v", invalidAdapter)
 	}
 }
+
+// Headers whose sizes claim far more data than the file holds
+var (
+	hugeFmtChunk = []byte("RIFF0\x00\x00\x00WAVEfmt j'6\xd8")
+	hugeListInfo = []byte("RIFF\x00\x00\x00\x00WAVE" +
+		"fmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\x80\x3e\x00\x00\x02\x00\x10\x00" +
+		"LIST\xf0\xff\xff\xffINFO")
+	hugeID3Tag = []byte("ID3\x04\x00\x00\x7f\x7f\x7f\x7fTIT2\x00\x00\x00\x05\x00\x00\x03abcd")
+)
+
+func TestParseIgnoresDeclaredSizes(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		parse func(io.Reader) error
+	}{
+		{"WAV fmt chunk", hugeFmtChunk, func(r io.Reader) error { _, err := ParseWAV(r); return err }},
+		{"WAV LIST INFO chunk", hugeListInfo, func(r io.Reader) error { _, err := ParseWAV(r); return err }},
+		{"ID3v2 tag", hugeID3Tag, func(r io.Reader) error { _, err := ParseMP3(r); return err }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			err := test.parse(bytes.NewReader(test.data))
+			runtime.ReadMemStats(&after)
+
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("Expected ErrMalformed, got %v", err)
+			}
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+				t.Errorf("Expected a %d byte file to need little memory, allocated %d bytes", len(test.data), allocated)
+			}
+		})
+	}
+}
+
+func FuzzParseWAV(f *testing.F) {
+	f.Add(hugeFmtChunk)
+	f.Add(hugeListInfo)
+	f.Add(wavFixture(8000, 2, 16, map[string]string{"INAM": "Title"}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, r := range []io.Reader{bytes.NewReader(data), io.MultiReader(bytes.NewReader(data))} {
+			if _, err := ParseWAV(r); err != nil && !errors.Is(err, ErrMalformed) {
+				t.Fatalf("Expected nil or ErrMalformed, got %v", err)
+			}
+		}
+	})
+}
+
+func FuzzParseMP3(f *testing.F) {
+	f.Add(hugeID3Tag)
+	f.Add(append(id3Tag(3, id3Frame(3, "TIT2", utf16Text("Title"))), mpegFrames(2, false, true)...))
+	f.Add(append([]byte("ID3\x03\x00\xc0\x00\x00\x00\x20\x00\x00\x00\x06"), mpegFrames(1, true, false)...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, r := range []io.Reader{bytes.NewReader(data), io.MultiReader(bytes.NewReader(data))} {
+			if _, err := ParseMP3(r); err != nil && !errors.Is(err, ErrMalformed) {
+				t.Fatalf("Expected nil or ErrMalformed, got %v", err)
+			}
+		}
+	})
+}
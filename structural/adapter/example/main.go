@@ -1,41 +1,92 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
 	"github.com/edgardnogueira/go-patterns/structural/adapter"
 )
 
 func main() {
 	fmt.Println("Adapter Pattern Example")
 	fmt.Println("=======================")
-	fmt.Println("This example demonstrates adapting advanced media players to work with a"
-		+ "\nsimple media player interface that only natively supports MP3.")
+	fmt.Println("This example demonstrates adapting advanced media players to work with a" +
+		"\nsimple media player interface that only natively supports MP3.")
 	fmt.Println()
 
+	dir, err := os.MkdirTemp("", "adapter-example")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
 	// Create audio player
 	audioPlayer := &adapter.AudioPlayer{}
 
-	// Play different file formats
-	fmt.Println("\nTesting different audio formats:")
-	fmt.Println("--------------------------")
+	fmt.Println("1. Play a generated WAV file (using adapter):")
+	tone := filepath.Join(dir, "tone.wav")
+	if err := os.WriteFile(tone, toneWAV(44100, 440, 1.5), 0644); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	play(audioPlayer, tone)
 
-	fmt.Println("1. Play MP3 file (native support):")
-	result := audioPlayer.Play("mp3", "beyond_the_horizon.mp3")
-	fmt.Printf("   » %s\n", result)
+	fmt.Println("\n2. The format comes from the contents, not the extension:")
+	misnamed := filepath.Join(dir, "tone.mp3")
+	os.Rename(tone, misnamed)
+	play(audioPlayer, misnamed)
 
-	fmt.Println("\n2. Play FLAC file (using adapter):")
-	result = audioPlayer.Play("flac", "alone.flac")
-	fmt.Printf("   » %s\n", result)
+	fmt.Println("\n3. Try to play an unsupported file:")
+	text := filepath.Join(dir, "notes.aac")
+	os.WriteFile(text, []byte("not really audio"), 0644)
+	play(audioPlayer, text)
 
-	fmt.Println("\n3. Play WAV file (using adapter):")
-	result = audioPlayer.Play("wav", "far_away.wav")
-	fmt.Printf("   » %s\n", result)
-
-	fmt.Println("\n4. Try to play unsupported format (AAC):")
-	result = audioPlayer.Play("aac", "mind_me.aac")
-	fmt.Printf("   » %s\n", result)
+	// Play any files given on the command line
+	if len(os.Args) > 1 {
+		fmt.Println("\n4. Play files from the command line:")
+		for _, fileName := range os.Args[1:] {
+			play(audioPlayer, fileName)
+		}
+	}
 
 	fmt.Println("\nThe Adapter pattern allows our AudioPlayer to work with advanced")
 	fmt.Println("media formats without changing its interface. The client code")
 	fmt.Println("doesn't need to know about the adapters or advanced players.")
 }
+
+// play prints the track information of a file or the error reading it
+func play(player adapter.MediaPlayer, fileName string) {
+	info, err := player.Play(fileName)
+	if err != nil {
+		fmt.Printf("   » Error: %v\n", err)
+		return
+	}
+	fmt.Printf("   » %s\n", info)
+}
+
+// toneWAV generates a mono 16-bit WAV file with a sine tone
+func toneWAV(sampleRate int, frequency, seconds float64) []byte {
+	var samples bytes.Buffer
+	for i := 0; i < int(seconds*float64(sampleRate)); i++ {
+		sample := int16(math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)) * 8000)
+		binary.Write(&samples, binary.LittleEndian, sample)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+samples.Len()))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))
+	binary.Write(&b, binary.LittleEndian, []uint16{1, 1})
+	binary.Write(&b, binary.LittleEndian, []uint32{uint32(sampleRate), uint32(sampleRate * 2)})
+	binary.Write(&b, binary.LittleEndian, []uint16{2, 16})
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(samples.Len()))
+	b.Write(samples.Bytes())
+	return b.Bytes()
+}
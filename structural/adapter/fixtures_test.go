@@ -0,0 +1,169 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"unicode/utf16"
+)
+
+// writeFixture writes generated file contents to a temporary directory
+func writeFixture(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tagNames returns the names of tags in alphabetical order
+func tagNames(tags map[string]string) []string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// riffChunk encodes a RIFF chunk, padded to an even size
+func riffChunk(id string, data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(id)
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	if len(data)%2 == 1 {
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+// wavFixture generates a 16-bit PCM WAV file with a 440 Hz tone. The info
+// tags are written in a LIST chunk after the data, and an odd-sized chunk
+// before fmt checks that padding is handled.
+func wavFixture(sampleRate, channels, frames int, info map[string]string) []byte {
+	var format bytes.Buffer
+	blockAlign := channels * 2
+	binary.Write(&format, binary.LittleEndian, []uint16{1, uint16(channels)})
+	binary.Write(&format, binary.LittleEndian, []uint32{uint32(sampleRate), uint32(sampleRate * blockAlign)})
+	binary.Write(&format, binary.LittleEndian, []uint16{uint16(blockAlign), 16})
+
+	var samples bytes.Buffer
+	for i := 0; i < frames; i++ {
+		sample := int16(math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)) * 8000)
+		for c := 0; c < channels; c++ {
+			binary.Write(&samples, binary.LittleEndian, sample)
+		}
+	}
+
+	body := []byte("WAVE")
+	body = append(body, riffChunk("junk", []byte{1, 2, 3})...)
+	body = append(body, riffChunk("fmt ", format.Bytes())...)
+	body = append(body, riffChunk("data", samples.Bytes())...)
+	if len(info) > 0 {
+		list := []byte("INFO")
+		for _, id := range tagNames(info) {
+			list = append(list, riffChunk(id, append([]byte(info[id]), 0))...)
+		}
+		body = append(body, riffChunk("LIST", list)...)
+	}
+	return riffChunk("RIFF", body)
+}
+
+// flacBlock encodes a FLAC metadata block header and body
+func flacBlock(blockType byte, last bool, data []byte) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	n := len(data)
+	return append([]byte{blockType, byte(n >> 16), byte(n >> 8), byte(n)}, data...)
+}
+
+// flacFixture generates the metadata of a FLAC file followed by a few
+// bytes standing in for audio frames
+func flacFixture(sampleRate, channels, bitsPerSample int, totalSamples uint64, comments ...string) []byte {
+	var info bytes.Buffer
+	binary.Write(&info, binary.BigEndian, []uint16{4096, 4096})
+	info.Write([]byte{0, 0, 16, 0, 32, 0}) // Frame sizes
+	packed := uint64(sampleRate)<<44 | uint64(channels-1)<<41 | uint64(bitsPerSample-1)<<36 | totalSamples
+	binary.Write(&info, binary.BigEndian, packed)
+	info.Write(bytes.Repeat([]byte{0xAB}, 16)) // MD5
+
+	var vorbis bytes.Buffer
+	vendor := "reference libFLAC 1.4.3"
+	binary.Write(&vorbis, binary.LittleEndian, uint32(len(vendor)))
+	vorbis.WriteString(vendor)
+	binary.Write(&vorbis, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(&vorbis, binary.LittleEndian, uint32(len(comment)))
+		vorbis.WriteString(comment)
+	}
+
+	data := []byte("fLaC")
+	data = append(data, flacBlock(flacStreamInfo, false, info.Bytes())...)
+	data = append(data, flacBlock(3, false, make([]byte, 18))...) // SEEKTABLE
+	data = append(data, flacBlock(flacVorbisComment, false, vorbis.Bytes())...)
+	data = append(data, flacBlock(1, true, make([]byte, 64))...) // PADDING
+	return append(data, 0xFF, 0xF8, 0x69, 0x08)
+}
+
+// id3Frame encodes an ID3v2.3 or 2.4 frame
+func id3Frame(major byte, id string, body []byte) []byte {
+	size := make([]byte, 4)
+	if major == 4 {
+		n := len(body)
+		size = []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+	} else {
+		binary.BigEndian.PutUint32(size, uint32(len(body)))
+	}
+	frame := append([]byte(id), size...)
+	frame = append(frame, 0, 0) // Flags
+	return append(frame, body...)
+}
+
+// utf16Text encodes an ID3v2 text frame body in UTF-16 with a BOM
+func utf16Text(s string) []byte {
+	body := []byte{1, 0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		body = binary.LittleEndian.AppendUint16(body, unit)
+	}
+	return body
+}
+
+// id3Tag encodes an ID3v2 tag with the given frames and some padding
+func id3Tag(major byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	body = append(body, make([]byte, 32)...)
+	n := len(body)
+	header := []byte{'I', 'D', '3', major, 0, 0, byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+	return append(header, body...)
+}
+
+// mpegFrames generates MPEG-1 Layer III frames at 128 kbps and 44.1 kHz.
+// Each frame is 417 bytes. If xing is set, the first frame holds an Info
+// header with the frame count.
+func mpegFrames(count int, mono, xing bool) []byte {
+	header := []byte{0xFF, 0xFB, 0x90, 0x64}
+	sideInfo := 32
+	if mono {
+		header[3] = 0xC4
+		sideInfo = 17
+	}
+	var data []byte
+	for i := 0; i < count; i++ {
+		frame := make([]byte, 417)
+		copy(frame, header)
+		if i == 0 && xing {
+			copy(frame[4+sideInfo:], "Info")
+			binary.BigEndian.PutUint32(frame[4+sideInfo+4:], 1)
+			binary.BigEndian.PutUint32(frame[4+sideInfo+8:], uint32(count))
+		}
+		data = append(data, frame...)
+	}
+	return data
+}
@@ -0,0 +1,175 @@
+package adapter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// FLAC metadata block types
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+)
+
+// FLACStream is the metadata of a FLAC file: its STREAMINFO block and the
+// tags in its VORBIS_COMMENT block
+type FLACStream struct {
+	MinBlockSize  uint16
+	MaxBlockSize  uint16
+	MinFrameSize  uint32
+	MaxFrameSize  uint32
+	SampleRate    uint32
+	Channels      uint8
+	BitsPerSample uint8
+	TotalSamples  uint64 // Samples per channel; 0 if unknown
+	MD5           [16]byte
+	Vendor        string
+	Comments      map[string][]string // Vorbis comments by upper case name
+}
+
+// Duration returns the playing time
+func (f *FLACStream) Duration() time.Duration {
+	if f.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(float64(f.TotalSamples) / float64(f.SampleRate) * float64(time.Second))
+}
+
+// TrackInfo converts the metadata to a TrackInfo. A comment that appears
+// more than once, such as several artists, is joined with "; ".
+func (f *FLACStream) TrackInfo() TrackInfo {
+	tags := make(map[string]string, len(f.Comments))
+	for name, values := range f.Comments {
+		tags[name] = strings.Join(values, "; ")
+	}
+	return TrackInfo{
+		Format:     FormatFLAC,
+		SampleRate: int(f.SampleRate),
+		Channels:   int(f.Channels),
+		BitDepth:   int(f.BitsPerSample),
+		Duration:   f.Duration(),
+	}.withTags(tags)
+}
+
+// ParseFLAC reads the metadata blocks of a FLAC stream up to the first
+// audio frame. STREAMINFO must be the first block, as the format requires.
+func ParseFLAC(r io.Reader) (*FLACStream, error) {
+	var magic [4]byte
+	if err := readFull(r, magic[:], "FLAC marker"); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != "fLaC" {
+		return nil, fmt.Errorf("%w: missing fLaC marker", ErrMalformed)
+	}
+
+	stream := &FLACStream{}
+	for first := true; ; first = false {
+		var header [4]byte
+		if err := readFull(r, header[:], "metadata block header"); err != nil {
+			return nil, err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		if first && blockType != flacStreamInfo {
+			return nil, fmt.Errorf("%w: first metadata block is type %d, want STREAMINFO", ErrMalformed, blockType)
+		}
+
+		switch blockType {
+		case flacStreamInfo:
+			if length != 34 {
+				return nil, fmt.Errorf("%w: STREAMINFO is %d bytes, want 34", ErrMalformed, length)
+			}
+			var data [34]byte
+			if err := readFull(r, data[:], "STREAMINFO"); err != nil {
+				return nil, err
+			}
+			stream.parseStreamInfo(data)
+			if stream.SampleRate == 0 {
+				return nil, fmt.Errorf("%w: STREAMINFO has a sample rate of 0", ErrMalformed)
+			}
+		case flacVorbisComment:
+			data := make([]byte, length)
+			if err := readFull(r, data, "VORBIS_COMMENT"); err != nil {
+				return nil, err
+			}
+			if err := stream.parseVorbisComment(data); err != nil {
+				return nil, err
+			}
+		default:
+			if err := skip(r, length); err != nil {
+				return nil, fmt.Errorf("%w: metadata block type %d is truncated", ErrMalformed, blockType)
+			}
+		}
+
+		if last {
+			return stream, nil
+		}
+	}
+}
+
+// parseStreamInfo decodes the packed STREAMINFO fields:
+// 16 bits min block size, 16 max block size, 24 min frame size, 24 max
+// frame size, 20 sample rate, 3 channels - 1, 5 bits per sample - 1,
+// 36 total samples and 128 bits of MD5
+func (f *FLACStream) parseStreamInfo(data [34]byte) {
+	f.MinBlockSize = binary.BigEndian.Uint16(data[0:2])
+	f.MaxBlockSize = binary.BigEndian.Uint16(data[2:4])
+	f.MinFrameSize = uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6])
+	f.MaxFrameSize = uint32(data[7])<<16 | uint32(data[8])<<8 | uint32(data[9])
+
+	packed := binary.BigEndian.Uint64(data[10:18])
+	f.SampleRate = uint32(packed >> 44)
+	f.Channels = uint8(packed>>41&0x07) + 1
+	f.BitsPerSample = uint8(packed>>36&0x1F) + 1
+	f.TotalSamples = packed & (1<<36 - 1)
+	copy(f.MD5[:], data[18:34])
+}
+
+// parseVorbisComment decodes a VORBIS_COMMENT block. Unlike the rest of
+// FLAC, its lengths are little-endian.
+func (f *FLACStream) parseVorbisComment(data []byte) error {
+	next := func(what string) ([]byte, error) {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: VORBIS_COMMENT %s is truncated", ErrMalformed, what)
+		}
+		n := binary.LittleEndian.Uint32(data[0:4])
+		if uint64(n) > uint64(len(data)-4) {
+			return nil, fmt.Errorf("%w: VORBIS_COMMENT %s is %d bytes, only %d left", ErrMalformed, what, n, len(data)-4)
+		}
+		value := data[4 : 4+n]
+		data = data[4+n:]
+		return value, nil
+	}
+
+	vendor, err := next("vendor string")
+	if err != nil {
+		return err
+	}
+	f.Vendor = string(vendor)
+
+	if len(data) < 4 {
+		return fmt.Errorf("%w: VORBIS_COMMENT count is truncated", ErrMalformed)
+	}
+	count := binary.LittleEndian.Uint32(data[0:4])
+	data = data[4:]
+
+	f.Comments = make(map[string][]string)
+	for i := uint32(0); i < count; i++ {
+		comment, err := next(fmt.Sprintf("comment %d", i))
+		if err != nil {
+			return err
+		}
+		name, value, ok := strings.Cut(string(comment), "=")
+		if !ok {
+			return fmt.Errorf("%w: VORBIS_COMMENT comment %d has no '='", ErrMalformed, i)
+		}
+		name = strings.ToUpper(name)
+		f.Comments[name] = append(f.Comments[name], value)
+	}
+	return nil
+}
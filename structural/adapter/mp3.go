@@ -0,0 +1,457 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// MP3Stream is the ID3v2 tag and first MPEG audio frame header of an MP3 file
+type MP3Stream struct {
+	ID3Version  string            // Such as "2.4.0"; empty without a tag
+	Frames      map[string]string // Text of the ID3v2 frames by frame ID, such as TIT2
+	MPEGVersion string            // "1", "2" or "2.5"
+	Layer       int
+	Bitrate     int // Bits per second of the first frame
+	SampleRate  int
+	Channels    int
+	AudioFrames uint32 // Number of audio frames from a Xing or Info header; 0 if unknown
+
+	duration time.Duration
+}
+
+// Duration returns the playing time. It comes from the TLEN frame if the
+// tag has one, otherwise from the Xing header's frame count, otherwise it
+// is estimated from the file size and the first frame's bitrate.
+func (m *MP3Stream) Duration() time.Duration {
+	return m.duration
+}
+
+// id3Tags maps ID3v2.3/2.4 and ID3v2.2 frame IDs to common tag names
+var id3Tags = map[string]string{
+	"TIT2": "TITLE", "TT2": "TITLE",
+	"TPE1": "ARTIST", "TP1": "ARTIST",
+	"TALB": "ALBUM", "TAL": "ALBUM",
+	"TYER": "DATE", "TDRC": "DATE", "TYE": "DATE",
+	"TCON": "GENRE", "TCO": "GENRE",
+	"TRCK": "TRACKNUMBER", "TRK": "TRACKNUMBER",
+	"COMM": "COMMENT", "COM": "COMMENT",
+	"TSSE": "ENCODER", "TSS": "ENCODER",
+}
+
+// TrackInfo converts the header to a TrackInfo
+func (m *MP3Stream) TrackInfo() TrackInfo {
+	tags := make(map[string]string)
+	for id, value := range m.Frames {
+		if name, ok := id3Tags[id]; ok {
+			tags[name] = value
+		} else {
+			tags[id] = value
+		}
+	}
+	return TrackInfo{
+		Format:     FormatMP3,
+		SampleRate: m.SampleRate,
+		Channels:   m.Channels,
+		Bitrate:    m.Bitrate,
+		Duration:   m.Duration(),
+	}.withTags(tags)
+}
+
+// mp3SyncWindow is how far past the tag ParseMP3 looks for the first frame
+const mp3SyncWindow = 64 * 1024
+
+// ParseMP3 reads the ID3v2 tag, if any, and the first audio frame header of
+// an MP3 stream. If r can seek, the file size is used to estimate the
+// duration of constant bitrate files.
+func ParseMP3(r io.Reader) (*MP3Stream, error) {
+	size := int64(-1)
+	if rs, ok := r.(io.Seeker); ok {
+		if start, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := rs.Seek(0, io.SeekEnd); err == nil {
+				size = end - start
+			}
+			if _, err := rs.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	stream := &MP3Stream{}
+	var header [10]byte
+	n, err := io.ReadFull(r, header[:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("%w: file is too short", ErrMalformed)
+	}
+
+	offset := int64(0) // Position of the data in head
+	head := header[:n]
+	if n == 10 && string(header[0:3]) == "ID3" {
+		tagSize, err := stream.readID3(r, header)
+		if err != nil {
+			return nil, err
+		}
+		offset = tagSize
+		head = nil
+	}
+
+	window := make([]byte, mp3SyncWindow)
+	m, err := io.ReadFull(r, window)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	data := append(head, window[:m]...)
+
+	pos := findMPEGFrame(data)
+	if pos < 0 {
+		return nil, fmt.Errorf("%w: no MPEG audio frame within %d bytes of the start of the audio", ErrMalformed, mp3SyncWindow)
+	}
+	frame := data[pos:]
+	samplesPerFrame := stream.parseFrameHeader(binary.BigEndian.Uint32(frame[0:4]))
+	stream.AudioFrames = xingFrames(frame, stream.MPEGVersion, stream.Channels)
+
+	audioStart := offset + int64(pos)
+	switch {
+	case stream.duration > 0:
+		// Set from TLEN
+	case stream.AudioFrames > 0:
+		seconds := float64(stream.AudioFrames) * float64(samplesPerFrame) / float64(stream.SampleRate)
+		stream.duration = time.Duration(seconds * float64(time.Second))
+	case size > audioStart && stream.Bitrate > 0:
+		seconds := float64(size-audioStart) * 8 / float64(stream.Bitrate)
+		stream.duration = time.Duration(seconds * float64(time.Second))
+	}
+	return stream, nil
+}
+
+// id3MaxTextFrame is the largest text or comment frame ParseMP3 reads into
+// memory; larger ones are skipped like pictures
+const id3MaxTextFrame = 64 * 1024
+
+// readID3 reads an ID3v2 tag whose 10-byte header has been read, and
+// returns the total size of the tag including its header and footer. The
+// tag is read frame by frame, so its size is never trusted for an
+// allocation.
+func (m *MP3Stream) readID3(r io.Reader, header [10]byte) (int64, error) {
+	major, revision, flags := header[3], header[4], header[5]
+	if major < 2 || major > 4 {
+		return 0, fmt.Errorf("%w: unsupported ID3v2 version 2.%d", ErrMalformed, major)
+	}
+	size, ok := syncsafe(header[6:10])
+	if !ok {
+		return 0, fmt.Errorf("%w: ID3v2 tag size is not syncsafe", ErrMalformed)
+	}
+	m.ID3Version = fmt.Sprintf("2.%d.%d", major, revision)
+
+	tag := &io.LimitedReader{R: r, N: int64(size)}
+	var body io.Reader = tag
+	if flags&0x80 != 0 {
+		// Unsynchronisation inserts a zero after every 0xFF
+		body = &unsyncReader{r: tag}
+	}
+	if flags&0x40 != 0 && major >= 3 {
+		skipExtendedHeader(body, major)
+	}
+
+	frames, err := parseID3Frames(body, tag, major)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.CopyN(io.Discard, tag, tag.N); err != nil {
+		return 0, fmt.Errorf("%w: ID3v2 tag is truncated", ErrMalformed)
+	}
+	total := int64(10 + size)
+	if major == 4 && flags&0x10 != 0 {
+		var footer [10]byte
+		if err := readFull(r, footer[:], "ID3v2 footer"); err != nil {
+			return 0, err
+		}
+		total += 10
+	}
+
+	m.Frames = frames
+	if length, ok := frames["TLEN"]; ok {
+		if ms, err := strconv.Atoi(length); err == nil && ms > 0 {
+			m.duration = time.Duration(ms) * time.Millisecond
+		}
+	} else if length, ok := frames["TLE"]; ok {
+		if ms, err := strconv.Atoi(length); err == nil && ms > 0 {
+			m.duration = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return total, nil
+}
+
+// unsyncReader undoes ID3v2 unsynchronisation by dropping the zero that
+// follows every 0xFF. It reads no further ahead than it returns.
+type unsyncReader struct {
+	r       io.Reader
+	afterFF bool
+}
+
+func (u *unsyncReader) Read(p []byte) (int, error) {
+	for {
+		n, err := u.r.Read(p)
+		out := 0
+		for _, b := range p[:n] {
+			if u.afterFF && b == 0 {
+				u.afterFF = false
+				continue
+			}
+			u.afterFF = b == 0xFF
+			p[out] = b
+			out++
+		}
+		if out > 0 || err != nil {
+			return out, err
+		}
+	}
+}
+
+// skipExtendedHeader skips the extended header at the start of a tag. A
+// truncated header leaves nothing to read, so the tag has no frames.
+func skipExtendedHeader(body io.Reader, major byte) {
+	var field [4]byte
+	if _, err := io.ReadFull(body, field[:]); err != nil {
+		return
+	}
+	var size int64
+	if major == 4 {
+		s, _ := syncsafe(field[:])
+		size = int64(s) - 4 // Includes the size field
+	} else {
+		size = int64(binary.BigEndian.Uint32(field[:]))
+	}
+	if size > 0 {
+		io.CopyN(io.Discard, body, size)
+	}
+}
+
+// parseID3Frames decodes the text of the frames read from body, which
+// reads from tag. Frames that are not text, such as pictures, are skipped.
+func parseID3Frames(body io.Reader, tag *io.LimitedReader, major byte) (map[string]string, error) {
+	idLen, headerLen := 4, 10
+	if major == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	frames := make(map[string]string)
+	for {
+		var header [10]byte
+		if _, err := io.ReadFull(body, header[:headerLen]); err != nil {
+			break // Too short for another frame
+		}
+		id := string(header[:idLen])
+		if header[0] == 0 {
+			break // Padding
+		}
+		var size int64
+		switch major {
+		case 2:
+			size = int64(header[3])<<16 | int64(header[4])<<8 | int64(header[5])
+		case 3:
+			size = int64(binary.BigEndian.Uint32(header[4:8]))
+		case 4:
+			s, ok := syncsafe(header[4:8])
+			if !ok {
+				return nil, fmt.Errorf("%w: ID3v2 frame %s size is not syncsafe", ErrMalformed, id)
+			}
+			size = int64(s)
+		}
+		// Unsynchronisation only makes the remaining bytes an upper bound
+		if size > tag.N {
+			return nil, fmt.Errorf("%w: ID3v2 frame %s is %d bytes, only %d left in the tag", ErrMalformed, id, size, tag.N)
+		}
+
+		text := id[0] == 'T' && id != "TXXX" && id != "TXX"
+		comment := id == "COMM" || id == "COM"
+		if !(text || comment) || size > id3MaxTextFrame {
+			if _, err := io.CopyN(io.Discard, body, size); err != nil {
+				return nil, fmt.Errorf("%w: ID3v2 frame %s is truncated", ErrMalformed, id)
+			}
+			continue
+		}
+		data := make([]byte, size)
+		if err := readFull(body, data, "ID3v2 frame "+id); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case comment:
+			if text, ok := decodeComment(data); ok {
+				frames[id] = text
+			}
+		case len(data) > 0:
+			frames[id] = decodeID3Text(data[0], data[1:])
+		}
+	}
+	return frames, nil
+}
+
+// decodeComment returns the text of a comment frame: an encoding byte, a
+// three letter language, a description and the text
+func decodeComment(body []byte) (string, bool) {
+	if len(body) < 4 {
+		return "", false
+	}
+	encoding, rest := body[0], body[4:]
+	terminator := []byte{0}
+	if encoding == 1 || encoding == 2 {
+		terminator = []byte{0, 0}
+	}
+	for i := 0; i+len(terminator) <= len(rest); i += len(terminator) {
+		if bytes.Equal(rest[i:i+len(terminator)], terminator) {
+			return decodeID3Text(encoding, rest[i+len(terminator):]), true
+		}
+	}
+	return "", false
+}
+
+// decodeID3Text decodes text in one of the ID3v2 encodings: 0 ISO-8859-1,
+// 1 UTF-16 with byte order mark, 2 UTF-16BE and 3 UTF-8. Several values
+// separated by zeros are joined with "; ".
+func decodeID3Text(encoding byte, data []byte) string {
+	var text string
+	switch encoding {
+	case 1, 2:
+		bigEndian := encoding == 2
+		if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE {
+			bigEndian, data = false, data[2:]
+		} else if len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF {
+			bigEndian, data = true, data[2:]
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			if bigEndian {
+				units = append(units, binary.BigEndian.Uint16(data[i:]))
+			} else {
+				units = append(units, binary.LittleEndian.Uint16(data[i:]))
+			}
+		}
+		text = string(utf16.Decode(units))
+		text = strings.ReplaceAll(text, "\uFEFF", "") // BOMs of later values
+	case 3:
+		text = string(data)
+	default:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+
+	var values []string
+	for _, value := range strings.Split(text, "\x00") {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, "; ")
+}
+
+// syncsafe decodes a 28-bit integer stored in the low 7 bits of 4 bytes
+func syncsafe(b []byte) (int, bool) {
+	n := 0
+	for _, c := range b[:4] {
+		if c&0x80 != 0 {
+			return 0, false
+		}
+		n = n<<7 | int(c)
+	}
+	return n, true
+}
+
+// MPEG audio bitrates in kbps by version (1 or 2/2.5), layer and index
+var mpegBitrates = map[[2]int][15]int{
+	{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// MPEG audio sample rates by version
+var mpegSampleRates = map[string][3]int{
+	"1":   {44100, 48000, 32000},
+	"2":   {22050, 24000, 16000},
+	"2.5": {11025, 12000, 8000},
+}
+
+// validMPEGHeader reports whether four bytes are a usable MPEG audio frame
+// header: frame sync, a known version and layer, and a bitrate and sample
+// rate index that are not reserved or free format
+func validMPEGHeader(h uint32) bool {
+	return h>>21 == 0x7FF &&
+		h>>19&0x3 != 1 &&
+		h>>17&0x3 != 0 &&
+		h>>12&0xF != 0 && h>>12&0xF != 0xF &&
+		h>>10&0x3 != 3
+}
+
+// findMPEGFrame returns the position of the first frame header in data
+func findMPEGFrame(data []byte) int {
+	for i := 0; i+4 <= len(data); i++ {
+		if data[i] == 0xFF && validMPEGHeader(binary.BigEndian.Uint32(data[i:])) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseFrameHeader sets the audio properties from a valid frame header and
+// returns the number of samples per frame
+func (m *MP3Stream) parseFrameHeader(h uint32) int {
+	m.MPEGVersion = map[uint32]string{0: "2.5", 2: "2", 3: "1"}[h>>19&0x3]
+	m.Layer = int(4 - h>>17&0x3)
+
+	table := 2
+	if m.MPEGVersion == "1" {
+		table = 1
+	}
+	m.Bitrate = mpegBitrates[[2]int{table, m.Layer}][h>>12&0xF] * 1000
+	m.SampleRate = mpegSampleRates[m.MPEGVersion][h>>10&0x3]
+	m.Channels = 2
+	if h>>6&0x3 == 3 {
+		m.Channels = 1
+	}
+
+	switch {
+	case m.Layer == 1:
+		return 384
+	case m.Layer == 3 && m.MPEGVersion != "1":
+		return 576
+	default:
+		return 1152
+	}
+}
+
+// xingFrames returns the frame count of a Xing or Info header in the
+// first frame, which VBR encoders write to give the real length
+func xingFrames(frame []byte, version string, channels int) uint32 {
+	offset := 4 + 32
+	switch {
+	case version == "1" && channels == 1:
+		offset = 4 + 17
+	case version != "1" && channels == 2:
+		offset = 4 + 17
+	case version != "1":
+		offset = 4 + 9
+	}
+	if len(frame) < offset+12 {
+		return 0
+	}
+	id := string(frame[offset : offset+4])
+	if id != "Xing" && id != "Info" {
+		return 0
+	}
+	if binary.BigEndian.Uint32(frame[offset+4:])&0x1 == 0 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(frame[offset+8:])
+}
@@ -0,0 +1,150 @@
+package adapter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format is an audio file format
+type Format string
+
+// Supported formats
+const (
+	FormatWAV  Format = "wav"
+	FormatFLAC Format = "flac"
+	FormatMP3  Format = "mp3"
+)
+
+var (
+	// ErrUnknownFormat is returned when a file's first bytes match no
+	// supported format
+	ErrUnknownFormat = errors.New("unknown audio format")
+	// ErrUnsupportedFormat is returned when a player cannot handle a format
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	// ErrMalformed is returned when a header is truncated or inconsistent
+	ErrMalformed = errors.New("malformed audio header")
+)
+
+// TrackInfo describes an audio track in the same way for every format
+type TrackInfo struct {
+	FileName   string
+	Format     Format
+	SampleRate int // Samples per second per channel
+	Channels   int
+	BitDepth   int // Bits per sample; 0 for lossy formats
+	Bitrate    int // Bits per second
+	Duration   time.Duration
+	Title      string
+	Artist     string
+	Album      string
+	Tags       map[string]string // All tags, with upper case names
+}
+
+// String describes the track
+func (t TrackInfo) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Playing %s file: %s (%d Hz, %d ch", strings.ToUpper(string(t.Format)), t.FileName, t.SampleRate, t.Channels))
+	if t.BitDepth > 0 {
+		sb.WriteString(fmt.Sprintf(", %d-bit", t.BitDepth))
+	}
+	if t.Bitrate > 0 {
+		sb.WriteString(fmt.Sprintf(", %d kbps", t.Bitrate/1000))
+	}
+	sb.WriteString(", " + t.Duration.Round(time.Millisecond).String() + ")")
+	if t.Artist != "" || t.Title != "" {
+		sb.WriteString(" - " + strings.Trim(t.Artist+" - "+t.Title, " -"))
+	}
+	return sb.String()
+}
+
+// withTags sets the tag map and the well-known tags taken from it
+func (t TrackInfo) withTags(tags map[string]string) TrackInfo {
+	if len(tags) == 0 {
+		return t
+	}
+	t.Tags = tags
+	t.Title = tags["TITLE"]
+	t.Artist = tags["ARTIST"]
+	t.Album = tags["ALBUM"]
+	return t
+}
+
+// magicLen is the number of bytes needed to detect a format
+const magicLen = 12
+
+// DetectFormat identifies the format of a stream from its first bytes. It
+// returns a reader that still starts at the beginning of the stream: r
+// itself if it can seek, otherwise a buffered reader wrapping it.
+func DetectFormat(r io.Reader) (Format, io.Reader, error) {
+	var magic []byte
+	if rs, ok := r.(io.ReadSeeker); ok {
+		buf := make([]byte, magicLen)
+		n, err := io.ReadFull(rs, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return "", nil, err
+		}
+		if _, err := rs.Seek(int64(-n), io.SeekCurrent); err != nil {
+			return "", nil, err
+		}
+		magic = buf[:n]
+	} else {
+		br := bufio.NewReader(r)
+		peeked, err := br.Peek(magicLen)
+		if err != nil && err != io.EOF {
+			return "", nil, err
+		}
+		magic = peeked
+		r = br
+	}
+
+	format, err := detectMagic(magic)
+	return format, r, err
+}
+
+// detectMagic identifies a format from its magic bytes
+func detectMagic(magic []byte) (Format, error) {
+	switch {
+	case len(magic) >= 12 && string(magic[0:4]) == "RIFF" && string(magic[8:12]) == "WAVE":
+		return FormatWAV, nil
+	case len(magic) >= 4 && string(magic[0:4]) == "fLaC":
+		return FormatFLAC, nil
+	case len(magic) >= 3 && string(magic[0:3]) == "ID3":
+		return FormatMP3, nil
+	case len(magic) >= 2 && magic[0] == 0xFF && magic[1]&0xE0 == 0xE0:
+		// MPEG audio frame sync without an ID3v2 tag
+		return FormatMP3, nil
+	case len(magic) == 0:
+		return "", fmt.Errorf("%w: file is empty", ErrUnknownFormat)
+	default:
+		return "", fmt.Errorf("%w: file starts with % x", ErrUnknownFormat, magic)
+	}
+}
+
+// skip discards n bytes from r, seeking if possible
+func skip(r io.Reader, n int64) error {
+	if rs, ok := r.(io.Seeker); ok {
+		_, err := rs.Seek(n, io.SeekCurrent)
+		return err
+	}
+	copied, err := io.CopyN(io.Discard, r, n)
+	if err == io.EOF && copied < n {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readFull reads exactly len(buf) bytes, reporting a short read as a
+// malformed header of the given part
+func readFull(r io.Reader, buf []byte, part string) error {
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: %s is truncated", ErrMalformed, part)
+		}
+		return err
+	}
+	return nil
+}
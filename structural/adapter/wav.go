@@ -0,0 +1,185 @@
+package adapter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WAVStream is the header of a RIFF WAVE file
+type WAVStream struct {
+	AudioFormat   uint16 // 1 for PCM, 3 for IEEE float, 0xFFFE for extensible
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+	DataSize      uint32            // Size of the sample data in bytes
+	Info          map[string]string // LIST INFO tags by chunk ID, such as INAM
+}
+
+// Duration returns the playing time of the sample data
+func (w *WAVStream) Duration() time.Duration {
+	if w.ByteRate == 0 {
+		return 0
+	}
+	return time.Duration(float64(w.DataSize) / float64(w.ByteRate) * float64(time.Second))
+}
+
+// wavInfoTags maps LIST INFO chunk IDs to common tag names
+var wavInfoTags = map[string]string{
+	"INAM": "TITLE",
+	"IART": "ARTIST",
+	"IPRD": "ALBUM",
+	"ICRD": "DATE",
+	"IGNR": "GENRE",
+	"ICMT": "COMMENT",
+	"ITRK": "TRACKNUMBER",
+	"ISFT": "ENCODER",
+}
+
+// TrackInfo converts the header to a TrackInfo
+func (w *WAVStream) TrackInfo() TrackInfo {
+	tags := make(map[string]string)
+	for id, value := range w.Info {
+		if name, ok := wavInfoTags[id]; ok {
+			tags[name] = value
+		} else {
+			tags[id] = value
+		}
+	}
+	return TrackInfo{
+		Format:     FormatWAV,
+		SampleRate: int(w.SampleRate),
+		Channels:   int(w.Channels),
+		BitDepth:   int(w.BitsPerSample),
+		Bitrate:    int(w.ByteRate) * 8,
+		Duration:   w.Duration(),
+	}.withTags(tags)
+}
+
+// Limits on the chunks ParseWAV reads into memory. A fmt chunk is at most
+// 40 bytes, the size of WAVE_FORMAT_EXTENSIBLE; LIST INFO holds a few
+// short strings.
+const (
+	wavMaxFmt  = 40
+	wavMaxInfo = 1 << 20
+)
+
+// ParseWAV reads the chunks of a RIFF WAVE stream. The fmt and data chunks
+// are required; a LIST INFO chunk is read for tags if present. Sample data
+// is skipped, by seeking if r supports it.
+func ParseWAV(r io.Reader) (*WAVStream, error) {
+	var header [12]byte
+	if err := readFull(r, header[:], "RIFF header"); err != nil {
+		return nil, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%w: not a RIFF WAVE file", ErrMalformed)
+	}
+
+	stream := &WAVStream{}
+	var haveFmt, haveData bool
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("%w: chunk header is truncated", ErrMalformed)
+		}
+		id := string(chunk[0:4])
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+		padded := int64(size) + int64(size%2) // Chunks are padded to an even size
+
+		switch id {
+		case "fmt ":
+			if size < 16 || size > wavMaxFmt {
+				return nil, fmt.Errorf("%w: fmt chunk is %d bytes, want 16 to %d", ErrMalformed, size, wavMaxFmt)
+			}
+			var buf [wavMaxFmt + 1]byte
+			data := buf[:padded]
+			if err := readFull(r, data, "fmt chunk"); err != nil {
+				return nil, err
+			}
+			stream.AudioFormat = binary.LittleEndian.Uint16(data[0:2])
+			stream.Channels = binary.LittleEndian.Uint16(data[2:4])
+			stream.SampleRate = binary.LittleEndian.Uint32(data[4:8])
+			stream.ByteRate = binary.LittleEndian.Uint32(data[8:12])
+			stream.BlockAlign = binary.LittleEndian.Uint16(data[12:14])
+			stream.BitsPerSample = binary.LittleEndian.Uint16(data[14:16])
+			if stream.Channels == 0 || stream.SampleRate == 0 {
+				return nil, fmt.Errorf("%w: fmt chunk has %d channels at %d Hz", ErrMalformed, stream.Channels, stream.SampleRate)
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, fmt.Errorf("%w: data chunk comes before fmt chunk", ErrMalformed)
+			}
+			stream.DataSize = size
+			haveData = true
+			if err := skip(r, padded); err != nil {
+				// Files cut short while recording often have a data size
+				// larger than the file; the header is still usable
+				return stream, nil
+			}
+		case "LIST":
+			if padded < 4 {
+				if err := skip(r, padded); err != nil {
+					return nil, fmt.Errorf("%w: LIST chunk is truncated", ErrMalformed)
+				}
+				continue
+			}
+			var listType [4]byte
+			if err := readFull(r, listType[:], "LIST chunk"); err != nil {
+				return nil, err
+			}
+			if string(listType[:]) != "INFO" {
+				// Other lists, such as adtl cue labels, are not needed
+				if err := skip(r, padded-4); err != nil {
+					return nil, fmt.Errorf("%w: LIST chunk is truncated", ErrMalformed)
+				}
+				continue
+			}
+			if padded-4 > wavMaxInfo {
+				return nil, fmt.Errorf("%w: LIST INFO chunk is %d bytes, limit is %d", ErrMalformed, size, wavMaxInfo)
+			}
+			data := make([]byte, padded-4)
+			if err := readFull(r, data, "LIST chunk"); err != nil {
+				return nil, err
+			}
+			stream.Info = parseWAVInfo(data)
+		default:
+			if err := skip(r, padded); err != nil {
+				return nil, fmt.Errorf("%w: %q chunk is truncated", ErrMalformed, id)
+			}
+		}
+	}
+
+	if !haveFmt {
+		return nil, fmt.Errorf("%w: missing fmt chunk", ErrMalformed)
+	}
+	if !haveData {
+		return nil, fmt.Errorf("%w: missing data chunk", ErrMalformed)
+	}
+	return stream, nil
+}
+
+// parseWAVInfo reads the subchunks of a LIST INFO chunk that follow its
+// list type
+func parseWAVInfo(data []byte) map[string]string {
+	info := make(map[string]string)
+	for pos := 0; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		pos += 8
+		if pos+size > len(data) {
+			break
+		}
+		info[id] = strings.TrimRight(string(data[pos:pos+size]), "\x00")
+		pos += size + size%2
+	}
+	return info
+}
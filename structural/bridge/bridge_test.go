@@ -216,8 +216,8 @@ func TestDrawingApp(t *testing.T) {
 		t.Errorf("SVG shapes count = %d, want 1", len(svgShapes))
 	}
 	
-	// Test Draw method
-	drawing := app.Draw()
+	// Test DrawShapes method
+	drawing := app.DrawShapes()
 	if !strings.Contains(drawing, "Vector circle") || !strings.Contains(drawing, "<rect") {
 		t.Errorf("Drawing output doesn't contain expected content: %s", drawing)
 	}
@@ -2,6 +2,10 @@ package bridge
 
 import (
 	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // DrawingAPI is the Implementor interface in the Bridge pattern.
@@ -135,9 +139,9 @@ func (s *SVGRenderer) DrawLine(x1, y1, x2, y2 float64) string {
 	return fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" />`, x1, y1, x2, y2)
 }
 
-// DrawText draws text using SVG commands.
+// DrawText draws text using SVG commands. The text is escaped.
 func (s *SVGRenderer) DrawText(x, y float64, text string) string {
-	return fmt.Sprintf(`<text x="%.1f" y="%.1f">%s</text>`, x, y, text)
+	return fmt.Sprintf(`<text x="%.1f" y="%.1f">%s</text>`, x, y, html.EscapeString(text))
 }
 
 // GetName returns the name of the renderer.
@@ -183,3 +187,55 @@ func (t *TextRenderer) DrawText(x, y float64, text string) string {
 func (t *TextRenderer) GetName() string {
 	return "ASCII"
 }
+
+// renderers maps lower case renderer names to functions creating them, so
+// that saved drawings can recreate the drawing API of each shape.
+var renderers = struct {
+	sync.RWMutex
+	byName map[string]func() DrawingAPI
+}{
+	byName: make(map[string]func() DrawingAPI),
+}
+
+func init() {
+	RegisterRenderer("Vector", func() DrawingAPI { return NewVectorRenderer() })
+	RegisterRenderer("Raster", func() DrawingAPI { return NewRasterRenderer() })
+	RegisterRenderer("SVG", func() DrawingAPI { return NewSVGRenderer() })
+	RegisterRenderer("ASCII", func() DrawingAPI { return NewTextRenderer() })
+}
+
+// RegisterRenderer makes a drawing API available by name, which should be
+// the name its GetName method returns. Names are case-insensitive. The
+// Vector, Raster, SVG and ASCII renderers are registered by default.
+func RegisterRenderer(name string, newRenderer func() DrawingAPI) {
+	renderers.Lock()
+	defer renderers.Unlock()
+
+	renderers.byName[strings.ToLower(name)] = newRenderer
+}
+
+// NewRenderer creates a registered drawing API by name.
+func NewRenderer(name string) (DrawingAPI, error) {
+	renderers.RLock()
+	defer renderers.RUnlock()
+
+	newRenderer, ok := renderers.byName[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown renderer %q; register it with RegisterRenderer", name)
+	}
+	return newRenderer(), nil
+}
+
+// RendererNames returns the names of the registered renderers in lower case
+// and alphabetical order.
+func RendererNames() []string {
+	renderers.RLock()
+	defer renderers.RUnlock()
+
+	names := make([]string, 0, len(renderers.byName))
+	for name := range renderers.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,22 +1,60 @@
 package bridge
 
 import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
 	"strings"
 )
 
 // DrawingApp demonstrates how the Bridge Pattern allows shapes and drawing
 // implementations to vary independently.
 type DrawingApp struct {
-	shapes []Shape
+	shapes     []Shape
+	width      float64
+	height     float64
+	viewBox    *ViewBox
+	background string
 }
 
-// NewDrawingApp creates a new DrawingApp instance.
+// NewDrawingApp creates a new DrawingApp instance with an 800x600 canvas.
 func NewDrawingApp() *DrawingApp {
 	return &DrawingApp{
 		shapes: make([]Shape, 0),
+		width:  800,
+		height: 600,
 	}
 }
 
+// SetSize sets the size of the drawing in the SVG document.
+func (d *DrawingApp) SetSize(width, height float64) {
+	d.width = width
+	d.height = height
+}
+
+// SetViewBox sets the region of user coordinates the drawing shows. By
+// default it is the rectangle from the origin to the drawing's size.
+func (d *DrawingApp) SetViewBox(viewBox ViewBox) {
+	d.viewBox = &viewBox
+}
+
+// GetViewBox returns the region of user coordinates the drawing shows.
+func (d *DrawingApp) GetViewBox() ViewBox {
+	if d.viewBox != nil {
+		return *d.viewBox
+	}
+	return ViewBox{Width: d.width, Height: d.height}
+}
+
+// SetBackground sets the color the drawing is filled with before any shape
+// is drawn. An empty color leaves the background transparent.
+func (d *DrawingApp) SetBackground(color string) {
+	d.background = color
+}
+
 // AddShape adds a shape to the drawing app.
 func (d *DrawingApp) AddShape(shape Shape) {
 	d.shapes = append(d.shapes, shape)
@@ -32,15 +70,125 @@ func (d *DrawingApp) GetShapes() []Shape {
 	return d.shapes
 }
 
-// Draw renders all shapes in the drawing app.
+// Draw renders all shapes as a standalone SVG document. Every shape is drawn
+// as SVG with its style and transform, whatever its own drawing API is;
+// use DrawShapes to draw each shape with its own API.
 func (d *DrawingApp) Draw() string {
 	var result strings.Builder
-	
+	viewBox := d.GetViewBox()
+
+	result.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	result.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" ` +
+		svgAttribute("width", formatNumber(d.width)) + " " +
+		svgAttribute("height", formatNumber(d.height)) + " " +
+		svgAttribute("viewBox", viewBox.String()) + ">\n")
+	if d.background != "" {
+		background := NewSVGRenderer().DrawRectangle(viewBox.MinX, viewBox.MinY, viewBox.MinX+viewBox.Width, viewBox.MinY+viewBox.Height)
+		result.WriteString("  " + withAttributes(background, []string{svgAttribute("fill", d.background)}) + "\n")
+	}
+
+	svg := NewSVGRenderer()
+	for _, shape := range d.shapes {
+		writeSVG(&result, svg, shape, 1)
+	}
+	result.WriteString("</svg>\n")
+
+	return result.String()
+}
+
+// Rasterize paints all shapes into a new image of the given size in pixels.
+// The view box is scaled to fit the image and centred, like an SVG document
+// with the default preserveAspectRatio. Like Draw, every shape is painted
+// with its style and transform whatever its own drawing API is.
+func (d *DrawingApp) Rasterize(width, height int) (*image.RGBA, error) {
+	viewBox := d.GetViewBox()
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("image size must be positive, got %dx%d", width, height)
+	}
+	if viewBox.Width <= 0 || viewBox.Height <= 0 {
+		return nil, fmt.Errorf("view box size must be positive, got %s", viewBox)
+	}
+	for _, shape := range d.shapes {
+		if err := validateStyles(shape); err != nil {
+			return nil, err
+		}
+	}
+	background, err := ParseColor(d.background)
+	if err != nil {
+		return nil, fmt.Errorf("background: %w", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if background != nil {
+		draw.Draw(img, img.Bounds(), &image.Uniform{*background}, image.Point{}, draw.Src)
+	}
+
+	scale := math.Min(float64(width)/viewBox.Width, float64(height)/viewBox.Height)
+	fit := Translate(-viewBox.MinX, -viewBox.MinY).
+		Then(Scale(scale, scale)).
+		Then(Translate((float64(width)-viewBox.Width*scale)/2, (float64(height)-viewBox.Height*scale)/2))
+
+	renderer := NewImageRenderer(img)
+	for _, shape := range d.shapes {
+		rasterize(renderer, shape, fit, Style{})
+	}
+
+	return img, nil
+}
+
+// WritePNG rasterizes all shapes and writes them as a PNG image.
+func (d *DrawingApp) WritePNG(w io.Writer, width, height int) error {
+	img, err := d.Rasterize(width, height)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// rasterize paints a shape with the image renderer. Groups pass their
+// transform and style on to the shapes they contain.
+func rasterize(renderer *ImageRenderer, shape Shape, parent Transform, inherited Style) {
+	transform := shape.GetTransform().Then(parent)
+	style := shape.GetStyle().inherit(inherited)
+
+	if group, ok := shape.(*Group); ok {
+		for _, child := range group.GetShapes() {
+			rasterize(renderer, child, transform, style)
+		}
+		return
+	}
+
+	renderer.Transform = transform
+	renderer.Style = style
+	if shape := withRenderer(shape, renderer); shape != nil {
+		shape.Draw()
+	}
+}
+
+// validateStyles checks the styles of a shape and the shapes it contains.
+func validateStyles(shape Shape) error {
+	if err := shape.GetStyle().Validate(); err != nil {
+		return fmt.Errorf("%s: %w", shape.GetName(), err)
+	}
+	if group, ok := shape.(*Group); ok {
+		for _, child := range group.GetShapes() {
+			if err := validateStyles(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DrawShapes renders each shape with its own drawing API, one per line.
+func (d *DrawingApp) DrawShapes() string {
+	var result strings.Builder
+
 	for _, shape := range d.shapes {
 		result.WriteString(shape.Draw())
 		result.WriteString("\n")
 	}
-	
+
 	return result.String()
 }
 
@@ -94,6 +242,8 @@ func (d *DrawingApp) CreateShapeCollection(shapeType string, x, y float64) {
 			shape = NewRectangle(renderer, x, y+yOffset, 80, 40)
 		case "triangle":
 			shape = NewTriangle(renderer, x, y+yOffset, x+40, y+yOffset-40, x+80, y+yOffset)
+		case "line":
+			shape = NewLine(renderer, x, y+yOffset, x+80, y+yOffset)
 		case "text":
 			shape = NewText(renderer, x, y+yOffset, "Hello, Bridge Pattern!")
 		default:
@@ -110,19 +260,12 @@ func (d *DrawingApp) CreateShapeCollection(shapeType string, x, y float64) {
 // This demonstrates the flexibility of the Bridge Pattern by changing the
 // implementation (renderer) without changing the abstraction (shapes).
 func (d *DrawingApp) ChangeAllRenderers(rendererType string) {
-	var renderer DrawingAPI
-	
 	// Create the appropriate renderer
-	switch strings.ToLower(rendererType) {
-	case "vector":
-		renderer = NewVectorRenderer()
-	case "raster":
-		renderer = NewRasterRenderer()
-	case "svg":
-		renderer = NewSVGRenderer()
-	case "text", "ascii":
-		renderer = NewTextRenderer()
-	default:
+	if strings.EqualFold(rendererType, "text") {
+		rendererType = "ASCII"
+	}
+	renderer, err := NewRenderer(rendererType)
+	if err != nil {
 		// Default to vector if the renderer type is unknown
 		renderer = NewVectorRenderer()
 	}
@@ -131,22 +274,7 @@ func (d *DrawingApp) ChangeAllRenderers(rendererType string) {
 	newShapes := make([]Shape, 0, len(d.shapes))
 	
 	for _, shape := range d.shapes {
-		var newShape Shape
-		
-		switch s := shape.(type) {
-		case *Circle:
-			newShape = NewCircle(renderer, s.x, s.y, s.radius)
-		case *Square:
-			newShape = NewSquare(renderer, s.x, s.y, s.sideLength)
-		case *Rectangle:
-			newShape = NewRectangle(renderer, s.x, s.y, s.width, s.height)
-		case *Triangle:
-			newShape = NewTriangle(renderer, s.x, s.y, s.x2, s.y2, s.x3, s.y3)
-		case *Text:
-			newShape = NewText(renderer, s.x, s.y, s.content)
-		}
-		
-		if newShape != nil {
+		if newShape := withRenderer(shape, renderer); newShape != nil {
 			newShapes = append(newShapes, newShape)
 		}
 	}
@@ -155,12 +283,48 @@ func (d *DrawingApp) ChangeAllRenderers(rendererType string) {
 	d.shapes = newShapes
 }
 
+// withRenderer returns a copy of a shape, with its style and transform,
+// that is drawn with another renderer. Shapes in groups are copied too.
+func withRenderer(shape Shape, renderer DrawingAPI) Shape {
+	var newShape Shape
+
+	switch s := shape.(type) {
+	case *Circle:
+		newShape = NewCircle(renderer, s.x, s.y, s.radius)
+	case *Square:
+		newShape = NewSquare(renderer, s.x, s.y, s.sideLength)
+	case *Rectangle:
+		newShape = NewRectangle(renderer, s.x, s.y, s.width, s.height)
+	case *Triangle:
+		newShape = NewTriangle(renderer, s.x, s.y, s.x2, s.y2, s.x3, s.y3)
+	case *Line:
+		newShape = NewLine(renderer, s.x, s.y, s.x2, s.y2)
+	case *Text:
+		newShape = NewText(renderer, s.x, s.y, s.content)
+	case *Group:
+		group := NewGroup()
+		group.x, group.y, group.width, group.height = s.x, s.y, s.width, s.height
+		for _, child := range s.shapes {
+			if newChild := withRenderer(child, renderer); newChild != nil {
+				group.Add(newChild)
+			}
+		}
+		newShape = group
+	default:
+		return nil
+	}
+
+	newShape.SetStyle(shape.GetStyle())
+	newShape.SetTransform(shape.GetTransform())
+	return newShape
+}
+
 // GetShapesByRenderer returns a list of shapes that use the specified renderer type.
 func (d *DrawingApp) GetShapesByRenderer(rendererType string) []Shape {
 	filteredShapes := make([]Shape, 0)
 	
 	for _, shape := range d.shapes {
-		if strings.EqualFold(shape.GetDrawingAPI().GetName(), rendererType) {
+		if api := shape.GetDrawingAPI(); api != nil && strings.EqualFold(api.GetName(), rendererType) {
 			filteredShapes = append(filteredShapes, shape)
 		}
 	}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/edgardnogueira/go-patterns/structural/bridge"
-	"strings"
 )
 
 func main() {
@@ -35,7 +37,7 @@ func main() {
 	app.AddShape(triangle)
 	
 	// Draw all shapes
-	fmt.Println(app.Draw())
+	fmt.Println(app.DrawShapes())
 	
 	fmt.Println("\n2. Demonstrating shape operations:")
 	fmt.Println("--------------------------------")
@@ -58,7 +60,7 @@ func main() {
 	app.CreateShapeCollection("circle", 150, 150)
 	
 	// Draw all shapes
-	fmt.Println(app.Draw())
+	fmt.Println(app.DrawShapes())
 	
 	fmt.Println("\n4. Showing shape descriptions:")
 	fmt.Println("----------------------------")
@@ -73,7 +75,7 @@ func main() {
 	app.ChangeAllRenderers("svg")
 	
 	// Draw all shapes
-	fmt.Println(app.Draw())
+	fmt.Println(app.DrawShapes())
 	
 	fmt.Println("\n6. Creating a default scene:")
 	fmt.Println("-------------------------")
@@ -85,7 +87,7 @@ func main() {
 	app.CreateDefaultScene()
 	
 	// Draw all shapes
-	fmt.Println(app.Draw())
+	fmt.Println(app.DrawShapes())
 	
 	fmt.Println("\n7. Filter shapes by renderer type:")
 	fmt.Println("-------------------------------")
@@ -103,6 +105,76 @@ func main() {
 		fmt.Printf("- %s: %s\n", shape.GetName(), shape.Draw())
 	}
 	
+	fmt.Println("\n8. Exporting the scene as SVG and PNG:")
+	fmt.Println("------------------------------------")
+
+	// Style the shapes and group two of them under a rotation
+	app.SetBackground("white")
+	for i, shape := range app.GetShapes() {
+		shape.SetStyle(bridge.Style{Fill: []string{"#3366cc", "orange", "green", "purple"}[i%4], Stroke: "black", StrokeWidth: 2})
+	}
+	label := bridge.NewText(svgRenderer, 560, 120, "Bridge Pattern")
+	label.SetStyle(bridge.Style{FontSize: 24})
+	group := bridge.NewGroup(bridge.NewSquare(vectorRenderer, 600, 200, 80), label)
+	group.SetStyle(bridge.Style{Fill: "#c0c0c0"})
+	group.SetTransform(bridge.Rotate(-15, 640, 240))
+	app.AddShape(group)
+
+	dir := filepath.Join(os.TempDir(), "bridge-example")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	svgPath := filepath.Join(dir, "scene.svg")
+	if err := os.WriteFile(svgPath, []byte(app.Draw()), 0644); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	pngPath := filepath.Join(dir, "scene.png")
+	f, err := os.Create(pngPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	defer f.Close()
+	if err := app.WritePNG(f, 800, 600); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Wrote %s and %s\n", svgPath, pngPath)
+
+	fmt.Println("\n9. Editing the scene:")
+	fmt.Println("--------------------")
+
+	// Pick the shape under a point and bring it to the front
+	if shape := app.HitTest(240, 240); shape != nil {
+		fmt.Printf("Shape at (240,240): %s with bounds %+v\n", shape.GetDescription(), shape.Bounds())
+		app.BringToFront(shape)
+	}
+
+	// Save the scene and load it back; each shape keeps its renderer
+	jsonPath := filepath.Join(dir, "scene.json")
+	saved, err := os.Create(jsonPath)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	defer saved.Close()
+	if err := app.Save(saved); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	saved.Seek(0, 0)
+	loaded, err := bridge.LoadDrawing(saved)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Saved %s and loaded %d shapes:\n", jsonPath, loaded.GetShapeCount())
+	for _, shape := range loaded.GetShapes() {
+		fmt.Printf("- %s\n", shape.GetDescription())
+	}
+
 	fmt.Println("\nBridge Pattern Benefits:")
 	fmt.Println("----------------------")
 	fmt.Println("1. Separation of abstraction (shapes) from implementation (renderers)")
@@ -0,0 +1,241 @@
+package bridge
+
+import (
+	"math"
+)
+
+// lineHitTolerance is how far from a line, in drawing coordinates, a point
+// still counts as on it, so that thin lines can be picked.
+const lineHitTolerance = 2.0
+
+// Rect is an axis-aligned rectangle. The zero value is an empty rectangle.
+type Rect struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// rectOf returns the smallest rectangle containing the points.
+func rectOf(points ...point) Rect {
+	if len(points) == 0 {
+		return Rect{}
+	}
+	r := Rect{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+	for _, p := range points {
+		r.MinX, r.MaxX = math.Min(r.MinX, p.x), math.Max(r.MaxX, p.x)
+		r.MinY, r.MaxY = math.Min(r.MinY, p.y), math.Max(r.MaxY, p.y)
+	}
+	return r
+}
+
+// Width returns the width of the rectangle.
+func (r Rect) Width() float64 {
+	return r.MaxX - r.MinX
+}
+
+// Height returns the height of the rectangle.
+func (r Rect) Height() float64 {
+	return r.MaxY - r.MinY
+}
+
+// Empty reports whether the rectangle contains no points.
+func (r Rect) Empty() bool {
+	return r.MinX > r.MaxX || r.MinY > r.MaxY || r == Rect{}
+}
+
+// Union returns the smallest rectangle containing both rectangles.
+func (r Rect) Union(other Rect) Rect {
+	switch {
+	case r.Empty():
+		return other
+	case other.Empty():
+		return r
+	}
+	return Rect{
+		MinX: math.Min(r.MinX, other.MinX),
+		MinY: math.Min(r.MinY, other.MinY),
+		MaxX: math.Max(r.MaxX, other.MaxX),
+		MaxY: math.Max(r.MaxY, other.MaxY),
+	}
+}
+
+// ContainsPoint reports whether a point is inside the rectangle or on its
+// edge.
+func (r Rect) ContainsPoint(x, y float64) bool {
+	return !r.Empty() && x >= r.MinX && x <= r.MaxX && y >= r.MinY && y <= r.MaxY
+}
+
+// transformRect returns the bounds of a rectangle after a transform.
+func transformRect(t Transform, r Rect) Rect {
+	if r.Empty() {
+		return r
+	}
+	return transformPoints(t, point{r.MinX, r.MinY}, point{r.MaxX, r.MinY}, point{r.MaxX, r.MaxY}, point{r.MinX, r.MaxY})
+}
+
+// transformPoints returns the bounds of points after a transform.
+func transformPoints(t Transform, points ...point) Rect {
+	for i, p := range points {
+		x, y := t.Apply(p.x, p.y)
+		points[i] = point{x, y}
+	}
+	return rectOf(points...)
+}
+
+// Invert returns the transform that undoes t. It reports false if t
+// collapses points onto a line or a single point.
+func (t Transform) Invert() (Transform, bool) {
+	det := t.A*t.D - t.B*t.C
+	if det == 0 {
+		return Transform{}, false
+	}
+	return Transform{
+		A: t.D / det,
+		B: -t.B / det,
+		C: -t.C / det,
+		D: t.A / det,
+		E: (t.C*t.F - t.D*t.E) / det,
+		F: (t.B*t.E - t.A*t.F) / det,
+	}, true
+}
+
+// toLocal maps a point to the coordinates of the shape before its
+// transform.
+func (b *BaseShape) toLocal(x, y float64) (float64, float64, bool) {
+	inverse, ok := b.transform.Invert()
+	if !ok {
+		return 0, 0, false
+	}
+	x, y = inverse.Apply(x, y)
+	return x, y, true
+}
+
+// Bounds returns the bounding box of the circle.
+func (c *Circle) Bounds() Rect {
+	// An ellipse is the exact image of a circle under an affine transform
+	t := c.transform
+	cx, cy := t.Apply(c.x, c.y)
+	dx := c.radius * math.Hypot(t.A, t.C)
+	dy := c.radius * math.Hypot(t.B, t.D)
+	return Rect{MinX: cx - dx, MinY: cy - dy, MaxX: cx + dx, MaxY: cy + dy}
+}
+
+// Contains reports whether a point is inside the circle.
+func (c *Circle) Contains(x, y float64) bool {
+	x, y, ok := c.toLocal(x, y)
+	return ok && math.Hypot(x-c.x, y-c.y) <= c.radius
+}
+
+// Bounds returns the bounding box of the square.
+func (s *Square) Bounds() Rect {
+	return transformRect(s.transform, Rect{s.x, s.y, s.x + s.sideLength, s.y + s.sideLength})
+}
+
+// Contains reports whether a point is inside the square.
+func (s *Square) Contains(x, y float64) bool {
+	x, y, ok := s.toLocal(x, y)
+	return ok && rectOf(point{s.x, s.y}, point{s.x + s.sideLength, s.y + s.sideLength}).ContainsPoint(x, y)
+}
+
+// Bounds returns the bounding box of the rectangle.
+func (r *Rectangle) Bounds() Rect {
+	return transformPoints(r.transform, point{r.x, r.y}, point{r.x + r.width, r.y}, point{r.x + r.width, r.y + r.height}, point{r.x, r.y + r.height})
+}
+
+// Contains reports whether a point is inside the rectangle.
+func (r *Rectangle) Contains(x, y float64) bool {
+	x, y, ok := r.toLocal(x, y)
+	return ok && rectOf(point{r.x, r.y}, point{r.x + r.width, r.y + r.height}).ContainsPoint(x, y)
+}
+
+// Bounds returns the bounding box of the triangle.
+func (t *Triangle) Bounds() Rect {
+	return transformPoints(t.transform, point{t.x, t.y}, point{t.x2, t.y2}, point{t.x3, t.y3})
+}
+
+// Contains reports whether a point is inside the triangle or on its edges.
+func (t *Triangle) Contains(x, y float64) bool {
+	x, y, ok := t.toLocal(x, y)
+	if !ok {
+		return false
+	}
+	// The point is inside when it is on the same side of all three edges
+	p := point{x, y}
+	d1 := cross(point{t.x, t.y}, point{t.x2, t.y2}, p)
+	d2 := cross(point{t.x2, t.y2}, point{t.x3, t.y3}, p)
+	d3 := cross(point{t.x3, t.y3}, point{t.x, t.y}, p)
+	negative := d1 < 0 || d2 < 0 || d3 < 0
+	positive := d1 > 0 || d2 > 0 || d3 > 0
+	return !(negative && positive)
+}
+
+// cross returns the cross product of b-a and p-a, whose sign tells which
+// side of the line through a and b the point p is on.
+func cross(a, b, p point) float64 {
+	return (b.x-a.x)*(p.y-a.y) - (b.y-a.y)*(p.x-a.x)
+}
+
+// Bounds returns the bounding box of the line.
+func (l *Line) Bounds() Rect {
+	return transformPoints(l.transform, point{l.x, l.y}, point{l.x2, l.y2})
+}
+
+// Contains reports whether a point is within half the stroke width of the
+// line, or within a small tolerance for thin lines.
+func (l *Line) Contains(x, y float64) bool {
+	x, y, ok := l.toLocal(x, y)
+	if !ok {
+		return false
+	}
+	tolerance := math.Max(l.style.StrokeWidth/2, lineHitTolerance)
+	return distanceToSegment(point{x, y}, point{l.x, l.y}, point{l.x2, l.y2}) <= tolerance
+}
+
+// distanceToSegment returns the distance from p to the segment from a to b.
+func distanceToSegment(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return math.Hypot(p.x-a.x, p.y-a.y)
+	}
+	t := math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/lengthSquared))
+	return math.Hypot(p.x-(a.x+t*dx), p.y-(a.y+t*dy))
+}
+
+// textRect returns the box of a text before its transform. The text's
+// baseline starts at its position, so the box extends above it.
+func (t *Text) textRect() Rect {
+	return Rect{MinX: t.x, MinY: t.y - t.height, MaxX: t.x + t.width, MaxY: t.y}
+}
+
+// Bounds returns the bounding box of the text, from its width and height.
+func (t *Text) Bounds() Rect {
+	return transformRect(t.transform, t.textRect())
+}
+
+// Contains reports whether a point is inside the box of the text.
+func (t *Text) Contains(x, y float64) bool {
+	x, y, ok := t.toLocal(x, y)
+	return ok && t.textRect().ContainsPoint(x, y)
+}
+
+// Bounds returns the bounding box of the shapes in the group.
+func (g *Group) Bounds() Rect {
+	var bounds Rect
+	for _, shape := range g.shapes {
+		bounds = bounds.Union(transformRect(g.transform, shape.Bounds()))
+	}
+	return bounds
+}
+
+// Contains reports whether a point is inside any shape in the group.
+func (g *Group) Contains(x, y float64) bool {
+	x, y, ok := g.toLocal(x, y)
+	if !ok {
+		return false
+	}
+	for _, shape := range g.shapes {
+		if shape.Contains(x, y) {
+			return true
+		}
+	}
+	return false
+}
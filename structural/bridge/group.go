@@ -0,0 +1,70 @@
+package bridge
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Group is a Shape made of other shapes. Its style is inherited by the
+// shapes it contains and its transform applies to all of them, like an SVG
+// <g> element. A group has no drawing API of its own: each shape in it is
+// drawn with its own.
+type Group struct {
+	BaseShape
+	shapes []Shape
+}
+
+// NewGroup creates a new Group containing the given shapes.
+func NewGroup(shapes ...Shape) *Group {
+	return &Group{
+		BaseShape: NewBaseShape("Group", nil, 0, 0, 0, 0),
+		shapes:    shapes,
+	}
+}
+
+// Add adds shapes to the group.
+func (g *Group) Add(shapes ...Shape) {
+	g.shapes = append(g.shapes, shapes...)
+}
+
+// GetShapes returns the shapes in the group.
+func (g *Group) GetShapes() []Shape {
+	return g.shapes
+}
+
+// Draw renders every shape in the group, one per line.
+func (g *Group) Draw() string {
+	lines := make([]string, len(g.shapes))
+	for i, shape := range g.shapes {
+		lines[i] = shape.Draw()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ResizeTo updates the dimensions of the group without resizing its
+// shapes. Use a scale transform to resize them together.
+func (g *Group) ResizeTo(width, height float64) Shape {
+	g.width = width
+	g.height = height
+	return g
+}
+
+// MoveTo moves the group's origin to the specified coordinates, moving
+// every shape in it by the same offset.
+func (g *Group) MoveTo(x, y float64) Shape {
+	dx, dy := x-g.x, y-g.y
+	for _, shape := range g.shapes {
+		if anchored, ok := shape.(interface{ position() (float64, float64) }); ok {
+			sx, sy := anchored.position()
+			shape.MoveTo(sx+dx, sy+dy)
+		}
+	}
+	g.x = x
+	g.y = y
+	return g
+}
+
+// GetDescription returns a description of the group.
+func (g *Group) GetDescription() string {
+	return fmt.Sprintf("Group of %d shapes", len(g.shapes))
+}
@@ -0,0 +1,255 @@
+package bridge
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// circleSegments is the number of sides of the polygon circles are drawn as.
+const circleSegments = 96
+
+// ImageRenderer is a ConcreteImplementor in the Bridge pattern.
+// It implements the DrawingAPI interface by painting pixels into an image.
+// Shapes are filled and stroked as in SVG, without anti-aliasing, and text
+// is drawn with a built-in 5x7 bitmap font.
+type ImageRenderer struct {
+	Image     *image.RGBA
+	Style     Style     // Fill, stroke and font of the shapes drawn next
+	Transform Transform // Maps drawing coordinates to pixels
+}
+
+// NewImageRenderer creates a new ImageRenderer that paints into img.
+func NewImageRenderer(img *image.RGBA) *ImageRenderer {
+	return &ImageRenderer{Image: img, Transform: Identity()}
+}
+
+// point is a point in drawing coordinates.
+type point struct {
+	x, y float64
+}
+
+// DrawCircle paints a circle.
+func (r *ImageRenderer) DrawCircle(x, y, radius float64) string {
+	r.paint(r.fillColor(), [][]point{circlePolygon(x, y, radius)})
+
+	// The stroke is a ring centred on the outline
+	half := r.strokeWidth() / 2
+	outer := circlePolygon(x, y, radius+half)
+	inner := circlePolygon(x, y, math.Max(radius-half, 0))
+	ring := make([][]point, circleSegments)
+	for i := range ring {
+		j := (i + 1) % circleSegments
+		ring[i] = []point{outer[i], outer[j], inner[j], inner[i]}
+	}
+	r.paint(r.strokeColor(), ring)
+
+	return fmt.Sprintf("Image circle at (%.1f,%.1f) with radius %.1f", x, y, radius)
+}
+
+// DrawRectangle paints a rectangle.
+func (r *ImageRenderer) DrawRectangle(x1, y1, x2, y2 float64) string {
+	r.drawPolygon([]point{{x1, y1}, {x2, y1}, {x2, y2}, {x1, y2}})
+	return fmt.Sprintf("Image rectangle at (%.1f,%.1f)-(%.1f,%.1f)", x1, y1, x2, y2)
+}
+
+// DrawTriangle paints a triangle.
+func (r *ImageRenderer) DrawTriangle(x1, y1, x2, y2, x3, y3 float64) string {
+	r.drawPolygon([]point{{x1, y1}, {x2, y2}, {x3, y3}})
+	return fmt.Sprintf("Image triangle at (%.1f,%.1f), (%.1f,%.1f), (%.1f,%.1f)", x1, y1, x2, y2, x3, y3)
+}
+
+// DrawLine paints a line. As in SVG, lines are only visible with a stroke.
+func (r *ImageRenderer) DrawLine(x1, y1, x2, y2 float64) string {
+	if quad := r.strokeSegment(point{x1, y1}, point{x2, y2}, 0); quad != nil {
+		r.paint(r.strokeColor(), [][]point{quad})
+	}
+	return fmt.Sprintf("Image line from (%.1f,%.1f) to (%.1f,%.1f)", x1, y1, x2, y2)
+}
+
+// DrawText paints text with its baseline starting at (x, y) in the fill
+// color. Text is not stroked.
+func (r *ImageRenderer) DrawText(x, y float64, text string) string {
+	size := r.Style.FontSize
+	if size == 0 {
+		size = defaultFontSize
+	}
+	scale := size * glyphScale
+
+	var pixels [][]point
+	for i, ch := range []rune(text) {
+		left := x + float64(i*glyphAdvance)*scale
+		bitmap := glyph(ch)
+		for row, bits := range bitmap {
+			top := y - float64(glyphHeight-row)*scale
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				px := left + float64(col)*scale
+				pixels = append(pixels, []point{{px, top}, {px + scale, top}, {px + scale, top + scale}, {px, top + scale}})
+			}
+		}
+	}
+	r.paint(r.fillColor(), pixels)
+
+	return fmt.Sprintf("Image text '%s' at (%.1f,%.1f)", text, x, y)
+}
+
+// GetName returns the name of the renderer.
+func (r *ImageRenderer) GetName() string {
+	return "Image"
+}
+
+// drawPolygon fills and strokes a closed polygon.
+func (r *ImageRenderer) drawPolygon(points []point) {
+	r.paint(r.fillColor(), [][]point{points})
+
+	// Each edge is extended by half the stroke width so that the strokes
+	// of neighbouring edges meet at the corners
+	half := r.strokeWidth() / 2
+	var edges [][]point
+	for i := range points {
+		if quad := r.strokeSegment(points[i], points[(i+1)%len(points)], half); quad != nil {
+			edges = append(edges, quad)
+		}
+	}
+	r.paint(r.strokeColor(), edges)
+}
+
+// strokeSegment returns the quadrilateral covered by the stroke of a
+// segment, extended by the given length at both ends.
+func (r *ImageRenderer) strokeSegment(a, b point, extend float64) []point {
+	dx, dy := b.x-a.x, b.y-a.y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return nil
+	}
+	dx, dy = dx/length, dy/length
+	half := r.strokeWidth() / 2
+	a = point{a.x - dx*extend, a.y - dy*extend}
+	b = point{b.x + dx*extend, b.y + dy*extend}
+	nx, ny := -dy*half, dx*half
+	return []point{{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny}, {b.x - nx, b.y - ny}, {a.x - nx, a.y - ny}}
+}
+
+// circlePolygon approximates a circle with a regular polygon.
+func circlePolygon(x, y, radius float64) []point {
+	points := make([]point, circleSegments)
+	for i := range points {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / circleSegments)
+		points[i] = point{x + radius*cos, y + radius*sin}
+	}
+	return points
+}
+
+// fillColor returns the fill color of the current style, black by default.
+func (r *ImageRenderer) fillColor() *color.RGBA {
+	fill := r.Style.Fill
+	if fill == "" {
+		fill = defaultFill
+	}
+	c, _ := ParseColor(fill)
+	return c
+}
+
+// strokeColor returns the stroke color of the current style, if any.
+func (r *ImageRenderer) strokeColor() *color.RGBA {
+	c, _ := ParseColor(r.Style.Stroke)
+	return c
+}
+
+// strokeWidth returns the stroke width of the current style.
+func (r *ImageRenderer) strokeWidth() float64 {
+	if r.Style.StrokeWidth == 0 {
+		return defaultStrokeWidth
+	}
+	return r.Style.StrokeWidth
+}
+
+// paint fills the union of polygons with a color. A pixel is covered when
+// its centre is inside one of the polygons, and covered pixels are blended
+// once even where polygons overlap.
+func (r *ImageRenderer) paint(c *color.RGBA, polygons [][]point) {
+	if c == nil || len(polygons) == 0 {
+		return
+	}
+
+	// Map the polygons to pixel coordinates and find the rows they cover
+	bounds := r.Image.Bounds()
+	top, bottom := math.Inf(1), math.Inf(-1)
+	transformed := make([][]point, len(polygons))
+	for i, polygon := range polygons {
+		transformed[i] = make([]point, len(polygon))
+		for j, p := range polygon {
+			x, y := r.Transform.Apply(p.x, p.y)
+			transformed[i][j] = point{x, y}
+			top, bottom = math.Min(top, y), math.Max(bottom, y)
+		}
+	}
+	firstRow := pixelIndex(top, bounds.Min.Y, bounds.Max.Y)
+	lastRow := pixelIndex(bottom, bounds.Min.Y, bounds.Max.Y)
+
+	covered := make([]bool, bounds.Dx())
+	var crossings []float64
+	for py := firstRow; py < lastRow; py++ {
+		for i := range covered {
+			covered[i] = false
+		}
+		cy := float64(py) + 0.5
+		for _, polygon := range transformed {
+			crossings = crossings[:0]
+			for i, a := range polygon {
+				b := polygon[(i+1)%len(polygon)]
+				if (a.y <= cy) != (b.y <= cy) {
+					crossings = append(crossings, a.x+(cy-a.y)*(b.x-a.x)/(b.y-a.y))
+				}
+			}
+			sort.Float64s(crossings)
+			for i := 0; i+1 < len(crossings); i += 2 {
+				from := pixelIndex(crossings[i], bounds.Min.X, bounds.Max.X)
+				to := pixelIndex(crossings[i+1], bounds.Min.X, bounds.Max.X)
+				for px := from; px < to; px++ {
+					covered[px-bounds.Min.X] = true
+				}
+			}
+		}
+		for i, ok := range covered {
+			if ok {
+				r.blend(bounds.Min.X+i, py, c)
+			}
+		}
+	}
+}
+
+// pixelIndex returns the first pixel whose centre is at or after a
+// coordinate, clamped to [lo, hi].
+func pixelIndex(v float64, lo, hi int) int {
+	i := math.Ceil(v - 0.5)
+	switch {
+	case i < float64(lo):
+		return lo
+	case i > float64(hi):
+		return hi
+	default:
+		return int(i)
+	}
+}
+
+// blend draws a premultiplied color over a pixel.
+func (r *ImageRenderer) blend(x, y int, c *color.RGBA) {
+	if c.A == 255 {
+		r.Image.SetRGBA(x, y, *c)
+		return
+	}
+	dst := r.Image.RGBAAt(x, y)
+	keep := 255 - uint32(c.A)
+	r.Image.SetRGBA(x, y, color.RGBA{
+		R: c.R + uint8(uint32(dst.R)*keep/255),
+		G: c.G + uint8(uint32(dst.G)*keep/255),
+		B: c.B + uint8(uint32(dst.B)*keep/255),
+		A: c.A + uint8(uint32(dst.A)*keep/255),
+	})
+}
@@ -0,0 +1,298 @@
+package bridge
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenScene builds a drawing that uses every shape, style and a rotated
+// group. The shapes use different renderers, which Draw and Rasterize
+// ignore.
+func goldenScene() *DrawingApp {
+	app := NewDrawingApp()
+	app.SetSize(200, 150)
+	app.SetBackground("white")
+
+	rectangle := NewRectangle(NewVectorRenderer(), 10, 10, 80, 50)
+	rectangle.SetStyle(Style{Fill: "#3366cc", Stroke: "black", StrokeWidth: 2})
+	app.AddShape(rectangle)
+
+	circle := NewCircle(NewRasterRenderer(), 150, 40, 30)
+	circle.SetStyle(Style{Fill: "orange", Stroke: "#333", StrokeWidth: 3})
+	app.AddShape(circle)
+
+	triangle := NewTriangle(NewSVGRenderer(), 20, 140, 60, 80, 100, 140)
+	triangle.SetStyle(Style{Fill: "green"})
+	app.AddShape(triangle)
+
+	line := NewLine(NewTextRenderer(), 110, 80, 190, 145)
+	line.SetStyle(Style{Stroke: "red", StrokeWidth: 4})
+	app.AddShape(line)
+
+	badge := NewCircle(NewSVGRenderer(), 150, 110, 5)
+	badge.SetStyle(Style{Fill: "red"})
+	group := NewGroup(NewSquare(NewSVGRenderer(), 135, 95, 30), badge)
+	group.SetStyle(Style{Fill: "#c0c0c0", Stroke: "navy"})
+	group.SetTransform(Rotate(30, 150, 110))
+	app.AddShape(group)
+
+	text := NewText(NewSVGRenderer(), 10, 75, "Bridge <1>")
+	text.SetStyle(Style{Fill: "navy", FontSize: 12})
+	app.AddShape(text)
+
+	return app
+}
+
+// checkGolden compares output with testdata/name, or updates the file with
+// -update.
+func checkGolden(t *testing.T, name string, got []byte) []byte {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Reading golden file: %v", err)
+	}
+	return expected
+}
+
+func TestDrawSVGDocument(t *testing.T) {
+	got := goldenScene().Draw()
+	expected := checkGolden(t, "scene.svg", []byte(got))
+	if got != string(expected) {
+		t.Errorf("SVG document does not match testdata/scene.svg.\nGot:\n%s", got)
+	}
+
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="200" height="150" viewBox="0 0 200 150">`,
+		`<g fill="#c0c0c0" stroke="navy" transform="matrix(`,
+		`<text fill="navy" font-size="12" x="10.0" y="75.0">Bridge &lt;1&gt;</text>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SVG document does not contain %q", want)
+		}
+	}
+}
+
+func TestDrawViewBox(t *testing.T) {
+	app := NewDrawingApp()
+	app.SetSize(400, 300)
+	app.SetViewBox(ViewBox{MinX: -50, MinY: -50, Width: 100, Height: 75})
+	app.AddShape(NewCircle(NewVectorRenderer(), 0, 0, 10))
+
+	got := app.Draw()
+	if !strings.Contains(got, `width="400" height="300" viewBox="-50 -50 100 75"`) {
+		t.Errorf("Unexpected svg element:\n%s", got)
+	}
+	if !strings.Contains(got, "  <circle cx=\"0.0\" cy=\"0.0\" r=\"10.0\" />\n") {
+		t.Errorf("Expected an unstyled circle:\n%s", got)
+	}
+}
+
+func TestRasterizeGolden(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+	}{
+		{"scene.png", 200, 150},
+		// A wider image leaves margins on both sides of the view box
+		{"scene_wide.png", 400, 150},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := goldenScene().WritePNG(&buf, tt.width, tt.height); err != nil {
+				t.Fatalf("WritePNG returned error: %v", err)
+			}
+			expected := checkGolden(t, tt.name, buf.Bytes())
+
+			got, _ := png.Decode(bytes.NewReader(buf.Bytes()))
+			want, err := png.Decode(bytes.NewReader(expected))
+			if err != nil {
+				t.Fatalf("Decoding golden image: %v", err)
+			}
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("Image bounds = %v, want %v", got.Bounds(), want.Bounds())
+			}
+			differing := 0
+			for y := got.Bounds().Min.Y; y < got.Bounds().Max.Y; y++ {
+				for x := got.Bounds().Min.X; x < got.Bounds().Max.X; x++ {
+					if got.At(x, y) != want.At(x, y) {
+						differing++
+					}
+				}
+			}
+			if differing > 0 {
+				actual := filepath.Join(t.TempDir(), tt.name)
+				os.WriteFile(actual, buf.Bytes(), 0644)
+				t.Errorf("%d pixels differ from testdata/%s; the image was written to %s", differing, tt.name, actual)
+			}
+		})
+	}
+}
+
+func TestRasterizePixels(t *testing.T) {
+	img, err := goldenScene().Rasterize(200, 150)
+	if err != nil {
+		t.Fatalf("Rasterize returned error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		{"background", 195, 5, color.RGBA{255, 255, 255, 255}},
+		{"rectangle fill", 50, 35, color.RGBA{0x33, 0x66, 0xcc, 255}},
+		{"rectangle stroke", 10, 35, color.RGBA{0, 0, 0, 255}},
+		{"circle fill", 150, 40, color.RGBA{255, 165, 0, 255}},
+		{"circle stroke", 180, 40, color.RGBA{0x33, 0x33, 0x33, 255}},
+		{"triangle fill", 60, 130, color.RGBA{0, 128, 0, 255}},
+		{"line stroke", 150, 112, color.RGBA{255, 0, 0, 255}},
+		{"group fill inherited", 140, 115, color.RGBA{192, 192, 192, 255}},
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s at (%d,%d) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	// The rotated square's corner is no longer where it was
+	if got := img.RGBAAt(136, 96); got == (color.RGBA{192, 192, 192, 255}) {
+		t.Errorf("Expected the group to be rotated, found its fill at the unrotated corner")
+	}
+}
+
+func TestImageRendererAsDrawingAPI(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	renderer := NewImageRenderer(img)
+
+	circle := NewCircle(renderer, 10, 10, 5)
+	if got := circle.Draw(); got != "Image circle at (10.0,10.0) with radius 5.0" {
+		t.Errorf("Circle.Draw() = %q", got)
+	}
+	if got := img.RGBAAt(10, 10); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("Circle centre = %v, want black", got)
+	}
+	if got := img.RGBAAt(1, 1); got.A != 0 {
+		t.Errorf("Corner = %v, want transparent", got)
+	}
+
+	// Lines without a stroke are not painted, as in SVG
+	NewLine(renderer, 0, 18, 20, 18).Draw()
+	if got := img.RGBAAt(10, 18); got.A != 0 {
+		t.Errorf("Unstroked line painted %v", got)
+	}
+
+	// Semi-transparent colors are blended
+	renderer.Style = Style{Fill: "#ff000080"}
+	NewRectangle(renderer, 0, 0, 20, 20).Draw()
+	if got := img.RGBAAt(10, 10); got.R != 128 || got.A != 255 {
+		t.Errorf("Blended centre = %v, want red over black", got)
+	}
+
+	// Text is painted with the bitmap font
+	text := image.NewRGBA(image.Rect(0, 0, 60, 20))
+	NewText(NewImageRenderer(text), 0, 16, "Hi").Draw()
+	painted := 0
+	for _, v := range text.Pix {
+		if v != 0 {
+			painted++
+		}
+	}
+	if painted == 0 {
+		t.Error("Expected text to be painted")
+	}
+}
+
+func TestRasterizeErrors(t *testing.T) {
+	app := NewDrawingApp()
+	circle := NewCircle(NewVectorRenderer(), 10, 10, 5)
+	circle.SetStyle(Style{Fill: "not-a-color"})
+	app.AddShape(NewGroup(circle))
+
+	if _, err := app.Rasterize(100, 100); err == nil || !strings.Contains(err.Error(), `Circle: fill: unknown color "not-a-color"`) {
+		t.Errorf("Expected an unknown color error, got %v", err)
+	}
+	if _, err := NewDrawingApp().Rasterize(0, 100); err == nil {
+		t.Error("Expected an error for an empty image")
+	}
+
+	app = NewDrawingApp()
+	app.SetViewBox(ViewBox{Width: 0, Height: 10})
+	if _, err := app.Rasterize(10, 10); err == nil {
+		t.Error("Expected an error for an empty view box")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input string
+		want  *color.RGBA
+	}{
+		{"", nil},
+		{"none", nil},
+		{"Red", &color.RGBA{255, 0, 0, 255}},
+		{"#f80", &color.RGBA{255, 136, 0, 255}},
+		{"#3366CC", &color.RGBA{0x33, 0x66, 0xcc, 255}},
+		{"#ffffff00", &color.RGBA{0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.input)
+		if err != nil {
+			t.Errorf("ParseColor(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ParseColor(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"#12", "#ggg", "bleu", "rgb(1,2,3)"} {
+		if _, err := ParseColor(input); err == nil {
+			t.Errorf("ParseColor(%q) should fail", input)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	transform := Translate(10, 0).Then(Scale(2, 3))
+	if x, y := transform.Apply(1, 1); x != 22 || y != 3 {
+		t.Errorf("Apply(1, 1) = (%v, %v), want (22, 3)", x, y)
+	}
+
+	rotate := Rotate(90, 10, 10)
+	if x, y := rotate.Apply(20, 10); formatNumber(x) != "10" || formatNumber(y) != "20" {
+		t.Errorf("Rotate(90) moved (20, 10) to (%v, %v), want (10, 20)", x, y)
+	}
+
+	tests := []struct {
+		transform Transform
+		want      string
+	}{
+		{Identity(), ""},
+		{Translate(1.5, -2), "translate(1.5 -2)"},
+		{Scale(2, 0.5), "scale(2 0.5)"},
+		{Rotate(90, 0, 0), "matrix(0 1 -1 0 0 0)"},
+	}
+	for _, tt := range tests {
+		if got := tt.transform.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
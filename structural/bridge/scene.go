@@ -0,0 +1,150 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrShapeNotFound is returned when a shape is not in the drawing.
+var ErrShapeNotFound = errors.New("shape not found in drawing")
+
+// indexOf returns the position of a shape in the drawing, or -1.
+func (d *DrawingApp) indexOf(shape Shape) int {
+	for i, s := range d.shapes {
+		if s == shape {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveShape moves a shape to another position in the drawing order. Shapes
+// later in the order are drawn on top.
+func (d *DrawingApp) moveShape(shape Shape, position func(current int) int) error {
+	i := d.indexOf(shape)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrShapeNotFound, shape.GetName())
+	}
+	j := position(i)
+	if j < 0 {
+		j = 0
+	}
+	if j >= len(d.shapes) {
+		j = len(d.shapes) - 1
+	}
+
+	d.shapes = append(d.shapes[:i], d.shapes[i+1:]...)
+	d.shapes = append(d.shapes[:j], append([]Shape{shape}, d.shapes[j:]...)...)
+	return nil
+}
+
+// BringToFront draws a shape above all the others.
+func (d *DrawingApp) BringToFront(shape Shape) error {
+	return d.moveShape(shape, func(int) int { return len(d.shapes) - 1 })
+}
+
+// SendToBack draws a shape below all the others.
+func (d *DrawingApp) SendToBack(shape Shape) error {
+	return d.moveShape(shape, func(int) int { return 0 })
+}
+
+// BringForward draws a shape above the next shape up.
+func (d *DrawingApp) BringForward(shape Shape) error {
+	return d.moveShape(shape, func(i int) int { return i + 1 })
+}
+
+// SendBackward draws a shape below the next shape down.
+func (d *DrawingApp) SendBackward(shape Shape) error {
+	return d.moveShape(shape, func(i int) int { return i - 1 })
+}
+
+// RemoveShape removes a shape from the drawing.
+func (d *DrawingApp) RemoveShape(shape Shape) error {
+	i := d.indexOf(shape)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrShapeNotFound, shape.GetName())
+	}
+	d.shapes = append(d.shapes[:i], d.shapes[i+1:]...)
+	return nil
+}
+
+// GroupShapes replaces shapes in the drawing with a group containing them.
+// The shapes keep their drawing order within the group, and the group takes
+// the place of the topmost one.
+func (d *DrawingApp) GroupShapes(shapes ...Shape) (*Group, error) {
+	if len(shapes) == 0 {
+		return nil, errors.New("no shapes to group")
+	}
+	selected := make(map[Shape]bool, len(shapes))
+	for _, shape := range shapes {
+		if d.indexOf(shape) < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrShapeNotFound, shape.GetName())
+		}
+		selected[shape] = true
+	}
+
+	group := NewGroup()
+	remaining := make([]Shape, 0, len(d.shapes))
+	position := 0
+	for _, shape := range d.shapes {
+		if selected[shape] {
+			group.Add(shape)
+			position = len(remaining)
+			continue
+		}
+		remaining = append(remaining, shape)
+	}
+	d.shapes = append(remaining[:position], append([]Shape{group}, remaining[position:]...)...)
+
+	return group, nil
+}
+
+// Ungroup replaces a group in the drawing with the shapes it contains. The
+// group's transform and style are applied to the shapes so that the
+// drawing looks the same.
+func (d *DrawingApp) Ungroup(group *Group) ([]Shape, error) {
+	i := d.indexOf(group)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrShapeNotFound, group.GetName())
+	}
+
+	shapes := group.GetShapes()
+	for _, shape := range shapes {
+		shape.SetTransform(shape.GetTransform().Then(group.GetTransform()))
+		shape.SetStyle(shape.GetStyle().inherit(group.GetStyle()))
+	}
+	d.shapes = append(d.shapes[:i], append(append([]Shape{}, shapes...), d.shapes[i+1:]...)...)
+
+	return shapes, nil
+}
+
+// HitTest returns the topmost shape containing a point in drawing
+// coordinates, or nil if there is none. Groups are returned as a whole.
+func (d *DrawingApp) HitTest(x, y float64) Shape {
+	for i := len(d.shapes) - 1; i >= 0; i-- {
+		if d.shapes[i].Contains(x, y) {
+			return d.shapes[i]
+		}
+	}
+	return nil
+}
+
+// ShapesAt returns every shape containing a point, topmost first.
+func (d *DrawingApp) ShapesAt(x, y float64) []Shape {
+	var shapes []Shape
+	for i := len(d.shapes) - 1; i >= 0; i-- {
+		if d.shapes[i].Contains(x, y) {
+			shapes = append(shapes, d.shapes[i])
+		}
+	}
+	return shapes
+}
+
+// Bounds returns the bounding box of all shapes in the drawing.
+func (d *DrawingApp) Bounds() Rect {
+	var bounds Rect
+	for _, shape := range d.shapes {
+		bounds = bounds.Union(shape.Bounds())
+	}
+	return bounds
+}
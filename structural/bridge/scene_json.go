@@ -0,0 +1,201 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// sceneVersion is the version of the saved drawing format.
+const sceneVersion = 1
+
+// savedScene is the JSON form of a drawing.
+type savedScene struct {
+	Version    int          `json:"version"`
+	Width      float64      `json:"width"`
+	Height     float64      `json:"height"`
+	ViewBox    *ViewBox     `json:"viewBox,omitempty"`
+	Background string       `json:"background,omitempty"`
+	Shapes     []savedShape `json:"shapes"`
+}
+
+// savedShape is the JSON form of a shape. Which fields are used depends on
+// the type: circles have a centre and radius, squares a corner and size,
+// rectangles and text a corner and dimensions, triangles and lines their
+// points, and groups the shapes they contain. Every shape except groups
+// records the name of its drawing API.
+type savedShape struct {
+	Type      string       `json:"type"`
+	Renderer  string       `json:"renderer,omitempty"`
+	X         float64      `json:"x,omitempty"`
+	Y         float64      `json:"y,omitempty"`
+	Radius    float64      `json:"radius,omitempty"`
+	Size      float64      `json:"size,omitempty"`
+	Width     float64      `json:"width,omitempty"`
+	Height    float64      `json:"height,omitempty"`
+	Points    [][2]float64 `json:"points,omitempty"`
+	Text      string       `json:"text,omitempty"`
+	Style     *Style       `json:"style,omitempty"`
+	Transform *[6]float64  `json:"transform,omitempty"`
+	Shapes    []savedShape `json:"shapes,omitempty"`
+}
+
+// Save writes the drawing as JSON, including the drawing API of every
+// shape. Only the shapes of this package can be saved.
+func (d *DrawingApp) Save(w io.Writer) error {
+	scene := savedScene{
+		Version:    sceneVersion,
+		Width:      d.width,
+		Height:     d.height,
+		ViewBox:    d.viewBox,
+		Background: d.background,
+		Shapes:     make([]savedShape, 0, len(d.shapes)),
+	}
+	for i, shape := range d.shapes {
+		saved, err := saveShape(shape)
+		if err != nil {
+			return fmt.Errorf("shapes[%d]: %w", i, err)
+		}
+		scene.Shapes = append(scene.Shapes, saved)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(scene)
+}
+
+// saveShape returns the JSON form of a shape.
+func saveShape(shape Shape) (savedShape, error) {
+	var saved savedShape
+
+	switch s := shape.(type) {
+	case *Circle:
+		saved = savedShape{Type: "circle", X: s.x, Y: s.y, Radius: s.radius}
+	case *Square:
+		saved = savedShape{Type: "square", X: s.x, Y: s.y, Size: s.sideLength}
+	case *Rectangle:
+		saved = savedShape{Type: "rectangle", X: s.x, Y: s.y, Width: s.width, Height: s.height}
+	case *Triangle:
+		saved = savedShape{Type: "triangle", Points: [][2]float64{{s.x, s.y}, {s.x2, s.y2}, {s.x3, s.y3}}}
+	case *Line:
+		saved = savedShape{Type: "line", Points: [][2]float64{{s.x, s.y}, {s.x2, s.y2}}}
+	case *Text:
+		saved = savedShape{Type: "text", X: s.x, Y: s.y, Width: s.width, Height: s.height, Text: s.content}
+	case *Group:
+		saved = savedShape{Type: "group", X: s.x, Y: s.y, Width: s.width, Height: s.height, Shapes: make([]savedShape, 0, len(s.shapes))}
+		for i, child := range s.shapes {
+			savedChild, err := saveShape(child)
+			if err != nil {
+				return savedShape{}, fmt.Errorf("shapes[%d]: %w", i, err)
+			}
+			saved.Shapes = append(saved.Shapes, savedChild)
+		}
+	default:
+		return savedShape{}, fmt.Errorf("cannot save shape type %T", shape)
+	}
+
+	if saved.Type != "group" {
+		if shape.GetDrawingAPI() == nil {
+			return savedShape{}, fmt.Errorf("%s has no drawing API", shape.GetName())
+		}
+		saved.Renderer = shape.GetDrawingAPI().GetName()
+	}
+	if style := shape.GetStyle(); style != (Style{}) {
+		saved.Style = &style
+	}
+	if t := shape.GetTransform(); !t.IsIdentity() {
+		saved.Transform = &[6]float64{t.A, t.B, t.C, t.D, t.E, t.F}
+	}
+	return saved, nil
+}
+
+// LoadDrawing reads a drawing saved with Save. Each shape gets a new
+// instance of the drawing API it was saved with, which must be registered
+// with RegisterRenderer.
+func LoadDrawing(r io.Reader) (*DrawingApp, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	var scene savedScene
+	if err := decoder.Decode(&scene); err != nil {
+		return nil, fmt.Errorf("decoding drawing: %w", err)
+	}
+	if scene.Version != sceneVersion {
+		return nil, fmt.Errorf("unsupported drawing version %d", scene.Version)
+	}
+
+	d := NewDrawingApp()
+	d.width, d.height = scene.Width, scene.Height
+	d.viewBox = scene.ViewBox
+	d.background = scene.Background
+	for i, saved := range scene.Shapes {
+		shape, err := loadShape(saved)
+		if err != nil {
+			return nil, fmt.Errorf("shapes[%d]: %w", i, err)
+		}
+		d.AddShape(shape)
+	}
+
+	return d, nil
+}
+
+// loadShape creates a shape from its JSON form.
+func loadShape(saved savedShape) (Shape, error) {
+	var renderer DrawingAPI
+	if saved.Type != "group" {
+		var err error
+		if renderer, err = NewRenderer(saved.Renderer); err != nil {
+			return nil, err
+		}
+	}
+	if saved.Radius < 0 || saved.Size < 0 || saved.Width < 0 || saved.Height < 0 {
+		return nil, fmt.Errorf("%s has a negative size", saved.Type)
+	}
+	points := map[string]int{"triangle": 3, "line": 2}[saved.Type]
+	if len(saved.Points) != points {
+		return nil, fmt.Errorf("%s has %d points, want %d", saved.Type, len(saved.Points), points)
+	}
+
+	var shape Shape
+	switch saved.Type {
+	case "circle":
+		shape = NewCircle(renderer, saved.X, saved.Y, saved.Radius)
+	case "square":
+		shape = NewSquare(renderer, saved.X, saved.Y, saved.Size)
+	case "rectangle":
+		shape = NewRectangle(renderer, saved.X, saved.Y, saved.Width, saved.Height)
+	case "triangle":
+		p := saved.Points
+		shape = NewTriangle(renderer, p[0][0], p[0][1], p[1][0], p[1][1], p[2][0], p[2][1])
+	case "line":
+		p := saved.Points
+		shape = NewLine(renderer, p[0][0], p[0][1], p[1][0], p[1][1])
+	case "text":
+		text := NewText(renderer, saved.X, saved.Y, saved.Text)
+		text.ResizeTo(saved.Width, saved.Height)
+		shape = text
+	case "group":
+		group := NewGroup()
+		group.x, group.y, group.width, group.height = saved.X, saved.Y, saved.Width, saved.Height
+		for i, savedChild := range saved.Shapes {
+			child, err := loadShape(savedChild)
+			if err != nil {
+				return nil, fmt.Errorf("shapes[%d]: %w", i, err)
+			}
+			group.Add(child)
+		}
+		shape = group
+	default:
+		return nil, fmt.Errorf("unknown shape type %q", saved.Type)
+	}
+
+	if saved.Style != nil {
+		if err := saved.Style.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", saved.Type, err)
+		}
+		shape.SetStyle(*saved.Style)
+	}
+	if t := saved.Transform; t != nil {
+		shape.SetTransform(Transform{A: t[0], B: t[1], C: t[2], D: t[3], E: t[4], F: t[5]})
+	}
+	return shape, nil
+}
@@ -0,0 +1,342 @@
+package bridge
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// approxRect reports whether two rectangles are equal to within rounding
+func approxRect(a, b Rect) bool {
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return near(a.MinX, b.MinX) && near(a.MinY, b.MinY) && near(a.MaxX, b.MaxX) && near(a.MaxY, b.MaxY)
+}
+
+func TestBounds(t *testing.T) {
+	renderer := NewVectorRenderer()
+	rotated := NewSquare(renderer, 0, 0, 10)
+	rotated.SetTransform(Rotate(45, 5, 5))
+	scaled := NewCircle(renderer, 10, 10, 5)
+	scaled.SetTransform(Scale(2, 1))
+	group := NewGroup(NewRectangle(renderer, 0, 0, 10, 10), NewCircle(renderer, 30, 5, 5))
+	group.SetTransform(Translate(100, 100))
+
+	half := 5 * math.Sqrt2
+	tests := []struct {
+		name  string
+		shape Shape
+		want  Rect
+	}{
+		{"circle", NewCircle(renderer, 10, 20, 5), Rect{5, 15, 15, 25}},
+		{"scaled circle", scaled, Rect{10, 5, 30, 15}},
+		{"square", NewSquare(renderer, 1, 2, 3), Rect{1, 2, 4, 5}},
+		{"rotated square", rotated, Rect{5 - half, 5 - half, 5 + half, 5 + half}},
+		{"rectangle", NewRectangle(renderer, 10, 20, 30, 40), Rect{10, 20, 40, 60}},
+		{"triangle", NewTriangle(renderer, 0, 10, 5, 0, 10, 10), Rect{0, 0, 10, 10}},
+		{"line", NewLine(renderer, 10, 0, 0, 20), Rect{0, 0, 10, 20}},
+		{"text", NewText(renderer, 10, 50, "Hi"), Rect{10, 30, 30, 50}},
+		{"group", group, Rect{100, 100, 135, 110}},
+		{"empty group", NewGroup(), Rect{}},
+	}
+	for _, tt := range tests {
+		if got := tt.shape.Bounds(); !approxRect(got, tt.want) {
+			t.Errorf("%s bounds = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	app := NewDrawingApp()
+	app.AddShape(NewCircle(renderer, 10, 20, 5))
+	app.AddShape(group)
+	if got := app.Bounds(); got != (Rect{5, 15, 135, 110}) {
+		t.Errorf("Drawing bounds = %+v", got)
+	}
+}
+
+func TestContains(t *testing.T) {
+	renderer := NewVectorRenderer()
+	rotated := NewRectangle(renderer, 0, 0, 20, 2)
+	rotated.SetTransform(Rotate(90, 0, 0))
+	thick := NewLine(renderer, 0, 0, 100, 0)
+	thick.SetStyle(Style{StrokeWidth: 10})
+	group := NewGroup(NewCircle(renderer, 0, 0, 5))
+	group.SetTransform(Translate(50, 50))
+	collapsed := NewCircle(renderer, 0, 0, 5)
+	collapsed.SetTransform(Scale(0, 1))
+
+	tests := []struct {
+		name  string
+		shape Shape
+		x, y  float64
+		want  bool
+	}{
+		{"circle centre", NewCircle(renderer, 10, 10, 5), 10, 10, true},
+		{"circle edge", NewCircle(renderer, 10, 10, 5), 15, 10, true},
+		{"outside circle", NewCircle(renderer, 10, 10, 5), 14, 14, false},
+		{"square", NewSquare(renderer, 0, 0, 10), 10, 10, true},
+		{"triangle", NewTriangle(renderer, 0, 0, 10, 0, 0, 10), 2, 2, true},
+		{"outside triangle", NewTriangle(renderer, 0, 0, 10, 0, 0, 10), 6, 6, false},
+		{"reversed triangle", NewTriangle(renderer, 0, 0, 0, 10, 10, 0), 2, 2, true},
+		{"rotated rectangle", rotated, -1, 15, true},
+		{"unrotated position", rotated, 15, 1, false},
+		{"near thin line", NewLine(renderer, 0, 0, 100, 0), 50, 1.5, true},
+		{"far from thin line", NewLine(renderer, 0, 0, 100, 0), 50, 3, false},
+		{"past line end", NewLine(renderer, 0, 0, 100, 0), 103, 0, false},
+		{"thick line", thick, 50, 4.5, true},
+		{"text", NewText(renderer, 0, 20, "Hello"), 25, 10, true},
+		{"above text", NewText(renderer, 0, 20, "Hello"), 25, -1, false},
+		{"group", group, 52, 52, true},
+		{"group origin", group, 0, 0, false},
+		{"collapsed", collapsed, 0, 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.shape.Contains(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s: Contains(%v, %v) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+// shapeNames returns the names of the shapes in a drawing, bottom first
+func shapeNames(app *DrawingApp) string {
+	var names []string
+	for _, shape := range app.GetShapes() {
+		names = append(names, shape.GetName())
+	}
+	return strings.Join(names, ",")
+}
+
+func TestZOrder(t *testing.T) {
+	renderer := NewVectorRenderer()
+	app := NewDrawingApp()
+	circle := NewCircle(renderer, 0, 0, 1)
+	square := NewSquare(renderer, 0, 0, 1)
+	rectangle := NewRectangle(renderer, 0, 0, 1, 1)
+	text := NewText(renderer, 0, 0, "A")
+	for _, shape := range []Shape{circle, square, rectangle, text} {
+		app.AddShape(shape)
+	}
+
+	steps := []struct {
+		name string
+		move func() error
+		want string
+	}{
+		{"BringToFront", func() error { return app.BringToFront(circle) }, "Square,Rectangle,Text,Circle"},
+		{"SendToBack", func() error { return app.SendToBack(text) }, "Text,Square,Rectangle,Circle"},
+		{"BringForward", func() error { return app.BringForward(square) }, "Text,Rectangle,Square,Circle"},
+		{"SendBackward", func() error { return app.SendBackward(circle) }, "Text,Rectangle,Circle,Square"},
+		{"BringForward at top", func() error { return app.BringForward(square) }, "Text,Rectangle,Circle,Square"},
+		{"SendBackward at bottom", func() error { return app.SendBackward(text) }, "Text,Rectangle,Circle,Square"},
+		{"RemoveShape", func() error { return app.RemoveShape(rectangle) }, "Text,Circle,Square"},
+	}
+	for _, step := range steps {
+		if err := step.move(); err != nil {
+			t.Fatalf("%s returned error: %v", step.name, err)
+		}
+		if got := shapeNames(app); got != step.want {
+			t.Errorf("After %s order = %s, want %s", step.name, got, step.want)
+		}
+	}
+
+	if err := app.BringToFront(rectangle); !errors.Is(err, ErrShapeNotFound) {
+		t.Errorf("Expected ErrShapeNotFound, got %v", err)
+	}
+}
+
+func TestHitTest(t *testing.T) {
+	renderer := NewVectorRenderer()
+	app := NewDrawingApp()
+	back := NewRectangle(renderer, 0, 0, 100, 100)
+	front := NewCircle(renderer, 50, 50, 20)
+	line := NewLine(renderer, 0, 150, 100, 150)
+	app.AddShape(back)
+	app.AddShape(front)
+	app.AddShape(line)
+
+	if got := app.HitTest(50, 50); got != front {
+		t.Errorf("HitTest(50, 50) = %v, want the circle", got)
+	}
+	if got := app.HitTest(5, 5); got != back {
+		t.Errorf("HitTest(5, 5) = %v, want the rectangle", got)
+	}
+	if got := app.HitTest(50, 151); got != line {
+		t.Errorf("HitTest(50, 151) = %v, want the line", got)
+	}
+	if got := app.HitTest(200, 200); got != nil {
+		t.Errorf("HitTest(200, 200) = %v, want nil", got)
+	}
+	if got := app.ShapesAt(50, 50); len(got) != 2 || got[0] != front || got[1] != back {
+		t.Errorf("ShapesAt(50, 50) = %v, want the circle and the rectangle", got)
+	}
+
+	app.SendToBack(front)
+	if got := app.HitTest(50, 50); got != back {
+		t.Errorf("HitTest after SendToBack = %v, want the rectangle", got)
+	}
+
+	// Groups are hit as a whole
+	group, err := app.GroupShapes(front, line)
+	if err != nil {
+		t.Fatalf("GroupShapes returned error: %v", err)
+	}
+	if got := app.HitTest(50, 151); got != group {
+		t.Errorf("HitTest on a grouped line = %v, want the group", got)
+	}
+}
+
+func TestGroupShapes(t *testing.T) {
+	scene := goldenScene()
+	before, _ := scene.Rasterize(200, 150)
+	shapes := scene.GetShapes()
+	rectangle, triangle, text := shapes[0], shapes[2], shapes[5]
+
+	group, err := scene.GroupShapes(text, rectangle, triangle)
+	if err != nil {
+		t.Fatalf("GroupShapes returned error: %v", err)
+	}
+	if got := shapeNames(scene); got != "Circle,Line,Group,Group" {
+		t.Errorf("Order after grouping = %s", got)
+	}
+	if got := group.GetShapes(); len(got) != 3 || got[0] != rectangle || got[1] != triangle || got[2] != text {
+		t.Errorf("Group should keep the drawing order of its shapes, got %v", got)
+	}
+
+	// Moving the group moves its shapes
+	group.MoveTo(10, 5)
+	if got := rectangle.Bounds(); got != (Rect{20, 15, 100, 65}) {
+		t.Errorf("Rectangle bounds after moving the group = %+v", got)
+	}
+	group.MoveTo(0, 0)
+
+	// Ungrouping keeps the drawing the same
+	group.SetTransform(Rotate(10, 100, 75))
+	group.SetStyle(Style{Stroke: "purple", StrokeWidth: 3})
+	grouped, _ := scene.Rasterize(200, 150)
+	if bytes.Equal(grouped.Pix, before.Pix) {
+		t.Fatal("Expected the group's transform to change the drawing")
+	}
+	if _, err := scene.Ungroup(group); err != nil {
+		t.Fatalf("Ungroup returned error: %v", err)
+	}
+	if got := shapeNames(scene); got != "Circle,Line,Group,Rectangle,Triangle,Text" {
+		t.Errorf("Order after ungrouping = %s", got)
+	}
+	ungrouped, _ := scene.Rasterize(200, 150)
+	if !bytes.Equal(ungrouped.Pix, grouped.Pix) {
+		t.Error("Ungrouping changed the drawing")
+	}
+	if got := rectangle.GetStyle(); got.Fill != "#3366cc" || got.Stroke != "black" || got.StrokeWidth != 2 {
+		t.Errorf("The shape's own style should win over the group's, got %+v", got)
+	}
+	if got := triangle.GetStyle(); got.Stroke != "purple" || got.StrokeWidth != 3 {
+		t.Errorf("The group's style should be inherited, got %+v", got)
+	}
+
+	if _, err := scene.GroupShapes(group); !errors.Is(err, ErrShapeNotFound) {
+		t.Errorf("Expected ErrShapeNotFound, got %v", err)
+	}
+	if _, err := scene.GroupShapes(); err == nil {
+		t.Error("Expected an error for an empty selection")
+	}
+}
+
+// recordingRenderer is a custom drawing API for testing the renderer
+// registry
+type recordingRenderer struct {
+	VectorRenderer
+}
+
+func (r *recordingRenderer) GetName() string {
+	return "Recording"
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	scene := goldenScene()
+	scene.SetViewBox(ViewBox{MinX: -10, MinY: -10, Width: 220, Height: 170})
+	var saved bytes.Buffer
+	if err := scene.Save(&saved); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	expected := checkGolden(t, "scene.json", saved.Bytes())
+	if saved.String() != string(expected) {
+		t.Errorf("Saved drawing does not match testdata/scene.json.\nGot:\n%s", saved.String())
+	}
+
+	loaded, err := LoadDrawing(bytes.NewReader(saved.Bytes()))
+	if err != nil {
+		t.Fatalf("LoadDrawing returned error: %v", err)
+	}
+	if loaded.Draw() != scene.Draw() {
+		t.Errorf("Loaded SVG differs.\nGot:\n%s\nWant:\n%s", loaded.Draw(), scene.Draw())
+	}
+	// Each shape is drawn with the renderer it was saved with
+	if loaded.DrawShapes() != scene.DrawShapes() {
+		t.Errorf("Loaded shapes differ.\nGot:\n%s\nWant:\n%s", loaded.DrawShapes(), scene.DrawShapes())
+	}
+
+	var resaved bytes.Buffer
+	loaded.Save(&resaved)
+	if resaved.String() != saved.String() {
+		t.Error("Saving a loaded drawing changed it")
+	}
+
+	// Custom renderers are saved by name
+	RegisterRenderer("Recording", func() DrawingAPI { return &recordingRenderer{} })
+	custom := NewDrawingApp()
+	custom.AddShape(NewCircle(&recordingRenderer{}, 1, 2, 3))
+	saved.Reset()
+	custom.Save(&saved)
+	loaded, err = LoadDrawing(&saved)
+	if err != nil {
+		t.Fatalf("LoadDrawing returned error: %v", err)
+	}
+	if _, ok := loaded.GetShapes()[0].GetDrawingAPI().(*recordingRenderer); !ok {
+		t.Errorf("Expected the custom renderer, got %T", loaded.GetShapes()[0].GetDrawingAPI())
+	}
+}
+
+func TestSaveErrors(t *testing.T) {
+	app := NewDrawingApp()
+	app.AddShape(NewGroup(NewCircle(nil, 0, 0, 1)))
+	if err := app.Save(&bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "shapes[0]: shapes[0]: Circle has no drawing API") {
+		t.Errorf("Expected a missing drawing API error, got %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		contains string
+	}{
+		{"bad version", `{"version": 2, "shapes": []}`, "unsupported drawing version 2"},
+		{"unknown field", `{"version": 1, "colour": "red", "shapes": []}`, `unknown field "colour"`},
+		{"unknown type", `{"version": 1, "shapes": [{"type": "star", "renderer": "SVG"}]}`, `shapes[0]: unknown shape type "star"`},
+		{"unknown renderer", `{"version": 1, "shapes": [{"type": "circle", "renderer": "OpenGL"}]}`, `unknown renderer "OpenGL"`},
+		{"missing points", `{"version": 1, "shapes": [{"type": "triangle", "renderer": "SVG", "points": [[0, 0]]}]}`, "triangle has 1 points, want 3"},
+		{"negative size", `{"version": 1, "shapes": [{"type": "circle", "renderer": "SVG", "radius": -1}]}`, "negative size"},
+		{"bad color", `{"version": 1, "shapes": [{"type": "group", "shapes": [{"type": "circle", "renderer": "SVG", "style": {"fill": "bleu"}}]}]}`, `shapes[0]: shapes[0]: circle: fill: unknown color "bleu"`},
+	}
+	for _, tt := range tests {
+		_, err := LoadDrawing(strings.NewReader(tt.json))
+		if err == nil || !strings.Contains(err.Error(), tt.contains) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.contains, err)
+		}
+	}
+}
+
+func TestTransformInvert(t *testing.T) {
+	transform := Rotate(30, 5, 5).Then(Scale(2, 3)).Then(Translate(7, -1))
+	inverse, ok := transform.Invert()
+	if !ok {
+		t.Fatal("Expected the transform to be invertible")
+	}
+	x, y := inverse.Apply(transform.Apply(3, 4))
+	if math.Abs(x-3) > 1e-9 || math.Abs(y-4) > 1e-9 {
+		t.Errorf("Inverse mapped the point back to (%v, %v), want (3, 4)", x, y)
+	}
+	if _, ok := Scale(0, 1).Invert(); ok {
+		t.Error("Expected a collapsing transform not to be invertible")
+	}
+}
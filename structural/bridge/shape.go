@@ -2,7 +2,6 @@ package bridge
 
 import (
 	"fmt"
-	"strings"
 )
 
 // Shape is the Abstraction in the Bridge pattern.
@@ -25,6 +24,26 @@ type Shape interface {
 	
 	// GetDescription returns a description of the shape.
 	GetDescription() string
+
+	// GetStyle returns the fill, stroke and font of the shape.
+	GetStyle() Style
+
+	// SetStyle sets the fill, stroke and font of the shape.
+	SetStyle(style Style)
+
+	// GetTransform returns the transform applied to the shape.
+	GetTransform() Transform
+
+	// SetTransform sets the transform applied to the shape.
+	SetTransform(transform Transform)
+
+	// Bounds returns the smallest axis-aligned rectangle containing the
+	// shape after its transform.
+	Bounds() Rect
+
+	// Contains reports whether a point, in the coordinates the shape is
+	// drawn in, is inside the shape.
+	Contains(x, y float64) bool
 }
 
 // BaseShape is a base struct that implements common functionality for shapes.
@@ -36,6 +55,8 @@ type BaseShape struct {
 	y          float64
 	width      float64
 	height     float64
+	style      Style
+	transform  Transform
 }
 
 // NewBaseShape creates a new BaseShape with the given parameters.
@@ -47,6 +68,7 @@ func NewBaseShape(name string, drawingAPI DrawingAPI, x, y, width, height float6
 		y:          y,
 		width:      width,
 		height:     height,
+		transform:  Identity(),
 	}
 }
 
@@ -65,6 +87,32 @@ func (b *BaseShape) GetDescription() string {
 	return fmt.Sprintf("%s rendered using %s renderer", b.GetName(), b.drawingAPI.GetName())
 }
 
+// GetStyle returns the fill, stroke and font of the shape.
+func (b *BaseShape) GetStyle() Style {
+	return b.style
+}
+
+// SetStyle sets the fill, stroke and font of the shape.
+func (b *BaseShape) SetStyle(style Style) {
+	b.style = style
+}
+
+// GetTransform returns the transform applied to the shape.
+func (b *BaseShape) GetTransform() Transform {
+	return b.transform
+}
+
+// SetTransform sets the transform applied to the shape, such as a rotation
+// or scale. Coordinates are given before the transform.
+func (b *BaseShape) SetTransform(transform Transform) {
+	b.transform = transform
+}
+
+// position returns the point the shape is anchored at.
+func (b *BaseShape) position() (float64, float64) {
+	return b.x, b.y
+}
+
 // Circle is a concrete Shape implementation that represents a circle.
 type Circle struct {
 	BaseShape
@@ -241,6 +289,48 @@ func (t *Triangle) MoveTo(x, y float64) Shape {
 	return t
 }
 
+// Line is a concrete Shape implementation that represents a line segment.
+type Line struct {
+	BaseShape
+	x2, y2 float64
+}
+
+// NewLine creates a new Line with the given drawing API from (x1, y1) to (x2, y2).
+func NewLine(drawingAPI DrawingAPI, x1, y1, x2, y2 float64) *Line {
+	return &Line{
+		BaseShape: NewBaseShape("Line", drawingAPI, x1, y1, abs(x2-x1), abs(y2-y1)),
+		x2:        x2,
+		y2:        y2,
+	}
+}
+
+// Draw renders the line using the associated drawing API.
+func (l *Line) Draw() string {
+	return l.drawingAPI.DrawLine(l.x, l.y, l.x2, l.y2)
+}
+
+// ResizeTo scales the line relative to its start point.
+func (l *Line) ResizeTo(width, height float64) Shape {
+	if l.width != 0 {
+		l.x2 = l.x + (l.x2-l.x)*width/l.width
+		l.width = width
+	}
+	if l.height != 0 {
+		l.y2 = l.y + (l.y2-l.y)*height/l.height
+		l.height = height
+	}
+	return l
+}
+
+// MoveTo moves the start of the line to the specified coordinates, preserving its length.
+func (l *Line) MoveTo(x, y float64) Shape {
+	l.x2 += x - l.x
+	l.y2 += y - l.y
+	l.x = x
+	l.y = y
+	return l
+}
+
 // Text is a concrete Shape implementation that represents text.
 type Text struct {
 	BaseShape
@@ -304,3 +394,10 @@ func max(a, b float64) float64 {
 	}
 	return b
 }
+
+func abs(a float64) float64 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
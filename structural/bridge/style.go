@@ -0,0 +1,150 @@
+package bridge
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Style describes how a shape is painted. Colors are CSS colors such as
+// "red", "#f80" or "#ff8800", or "none". Empty fields are inherited from
+// the enclosing group, and otherwise take the SVG defaults: a black fill,
+// no stroke, a stroke width of 1 and a font size of 16.
+type Style struct {
+	Fill        string  `json:"fill,omitempty"`
+	Stroke      string  `json:"stroke,omitempty"`
+	StrokeWidth float64 `json:"strokeWidth,omitempty"`
+	FontSize    float64 `json:"fontSize,omitempty"`
+	FontFamily  string  `json:"fontFamily,omitempty"`
+}
+
+// Default style values, as in SVG
+const (
+	defaultFill        = "black"
+	defaultStrokeWidth = 1.0
+	defaultFontSize    = 16.0
+)
+
+// inherit fills the empty fields of a style from its parent's style.
+func (s Style) inherit(parent Style) Style {
+	if s.Fill == "" {
+		s.Fill = parent.Fill
+	}
+	if s.Stroke == "" {
+		s.Stroke = parent.Stroke
+	}
+	if s.StrokeWidth == 0 {
+		s.StrokeWidth = parent.StrokeWidth
+	}
+	if s.FontSize == 0 {
+		s.FontSize = parent.FontSize
+	}
+	if s.FontFamily == "" {
+		s.FontFamily = parent.FontFamily
+	}
+	return s
+}
+
+// Validate reports whether the colors of a style can be parsed.
+func (s Style) Validate() error {
+	if _, err := ParseColor(s.Fill); err != nil {
+		return fmt.Errorf("fill: %w", err)
+	}
+	if _, err := ParseColor(s.Stroke); err != nil {
+		return fmt.Errorf("stroke: %w", err)
+	}
+	if s.StrokeWidth < 0 || s.FontSize < 0 {
+		return fmt.Errorf("stroke width and font size must not be negative")
+	}
+	return nil
+}
+
+// svgAttributes returns the SVG presentation attributes of the fields that
+// are set.
+func (s Style) svgAttributes() []string {
+	var attrs []string
+	if s.Fill != "" {
+		attrs = append(attrs, svgAttribute("fill", s.Fill))
+	}
+	if s.Stroke != "" {
+		attrs = append(attrs, svgAttribute("stroke", s.Stroke))
+	}
+	if s.StrokeWidth != 0 {
+		attrs = append(attrs, svgAttribute("stroke-width", formatNumber(s.StrokeWidth)))
+	}
+	if s.FontSize != 0 {
+		attrs = append(attrs, svgAttribute("font-size", formatNumber(s.FontSize)))
+	}
+	if s.FontFamily != "" {
+		attrs = append(attrs, svgAttribute("font-family", s.FontFamily))
+	}
+	return attrs
+}
+
+// namedColors are the CSS color keywords ParseColor understands.
+var namedColors = map[string]color.RGBA{
+	"black":   {0, 0, 0, 255},
+	"white":   {255, 255, 255, 255},
+	"red":     {255, 0, 0, 255},
+	"green":   {0, 128, 0, 255},
+	"lime":    {0, 255, 0, 255},
+	"blue":    {0, 0, 255, 255},
+	"navy":    {0, 0, 128, 255},
+	"yellow":  {255, 255, 0, 255},
+	"orange":  {255, 165, 0, 255},
+	"purple":  {128, 0, 128, 255},
+	"teal":    {0, 128, 128, 255},
+	"gray":    {128, 128, 128, 255},
+	"grey":    {128, 128, 128, 255},
+	"silver":  {192, 192, 192, 255},
+	"maroon":  {128, 0, 0, 255},
+	"olive":   {128, 128, 0, 255},
+	"aqua":    {0, 255, 255, 255},
+	"cyan":    {0, 255, 255, 255},
+	"fuchsia": {255, 0, 255, 255},
+	"magenta": {255, 0, 255, 255},
+}
+
+// ParseColor parses a CSS color: a color keyword, "#rgb", "#rrggbb" or
+// "#rrggbbaa". It returns nil for "none", "transparent" and the empty
+// string.
+func ParseColor(s string) (*color.RGBA, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "none", "transparent":
+		return nil, nil
+	}
+	if c, ok := namedColors[s]; ok {
+		return &c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if hex == s || (len(hex) != 3 && len(hex) != 6 && len(hex) != 8) {
+		return nil, fmt.Errorf("unknown color %q", s)
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unknown color %q", s)
+	}
+	c := color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+	premultiplied := color.RGBAModel.Convert(c).(color.RGBA)
+	return &premultiplied, nil
+}
+
+// formatNumber formats a coordinate with at most three decimals and no
+// trailing zeros.
+func formatNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 3, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
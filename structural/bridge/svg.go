@@ -0,0 +1,64 @@
+package bridge
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// ViewBox is the region of user coordinates a drawing shows, as in the SVG
+// viewBox attribute.
+type ViewBox struct {
+	MinX   float64 `json:"minX"`
+	MinY   float64 `json:"minY"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// String returns the view box as an SVG viewBox attribute value.
+func (v ViewBox) String() string {
+	return fmt.Sprintf("%s %s %s %s", formatNumber(v.MinX), formatNumber(v.MinY), formatNumber(v.Width), formatNumber(v.Height))
+}
+
+// svgAttribute formats an SVG attribute with an escaped value.
+func svgAttribute(name, value string) string {
+	return fmt.Sprintf(`%s="%s"`, name, html.EscapeString(value))
+}
+
+// withAttributes adds attributes to the first tag of an SVG element.
+func withAttributes(element string, attrs []string) string {
+	if len(attrs) == 0 || !strings.HasPrefix(element, "<") {
+		return element
+	}
+	end := strings.IndexAny(element, " />")
+	if end < 0 {
+		return element
+	}
+	return element[:end] + " " + strings.Join(attrs, " ") + element[end:]
+}
+
+// shapeAttributes returns the style and transform attributes of a shape.
+func shapeAttributes(shape Shape) []string {
+	attrs := shape.GetStyle().svgAttributes()
+	if transform := shape.GetTransform().String(); transform != "" {
+		attrs = append(attrs, svgAttribute("transform", transform))
+	}
+	return attrs
+}
+
+// writeSVG writes the SVG element of a shape. Every shape is drawn with
+// the SVG renderer, whatever its own drawing API is.
+func writeSVG(sb *strings.Builder, svg *SVGRenderer, shape Shape, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if group, ok := shape.(*Group); ok {
+		sb.WriteString(indent + withAttributes("<g>", shapeAttributes(group)) + "\n")
+		for _, child := range group.GetShapes() {
+			writeSVG(sb, svg, child, depth+1)
+		}
+		sb.WriteString(indent + "</g>\n")
+		return
+	}
+
+	element := withRenderer(shape, svg).Draw()
+	sb.WriteString(indent + withAttributes(element, shapeAttributes(shape)) + "\n")
+}
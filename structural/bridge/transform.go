@@ -0,0 +1,75 @@
+package bridge
+
+import (
+	"fmt"
+	"math"
+)
+
+// Transform is a 2D affine transformation, stored like the SVG matrix(a b c
+// d e f): a point (x, y) maps to (a*x + c*y + e, b*x + d*y + f).
+type Transform struct {
+	A, B, C, D, E, F float64
+}
+
+// Identity returns the transform that leaves points unchanged.
+func Identity() Transform {
+	return Transform{A: 1, D: 1}
+}
+
+// Translate returns a transform that moves points by (dx, dy).
+func Translate(dx, dy float64) Transform {
+	return Transform{A: 1, D: 1, E: dx, F: dy}
+}
+
+// Scale returns a transform that scales points from the origin.
+func Scale(sx, sy float64) Transform {
+	return Transform{A: sx, D: sy}
+}
+
+// Rotate returns a transform that rotates points clockwise on screen by the
+// given angle in degrees around (cx, cy), like the SVG rotate(angle cx cy).
+func Rotate(degrees, cx, cy float64) Transform {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	return Translate(-cx, -cy).
+		Then(Transform{A: cos, B: sin, C: -sin, D: cos}).
+		Then(Translate(cx, cy))
+}
+
+// Then returns the transform that applies t and then u.
+func (t Transform) Then(u Transform) Transform {
+	return Transform{
+		A: u.A*t.A + u.C*t.B,
+		B: u.B*t.A + u.D*t.B,
+		C: u.A*t.C + u.C*t.D,
+		D: u.B*t.C + u.D*t.D,
+		E: u.A*t.E + u.C*t.F + u.E,
+		F: u.B*t.E + u.D*t.F + u.F,
+	}
+}
+
+// Apply transforms a point.
+func (t Transform) Apply(x, y float64) (float64, float64) {
+	return t.A*x + t.C*y + t.E, t.B*x + t.D*y + t.F
+}
+
+// IsIdentity reports whether the transform leaves points unchanged.
+func (t Transform) IsIdentity() bool {
+	return t == Identity()
+}
+
+// String returns the transform as an SVG transform attribute value, or an
+// empty string for the identity.
+func (t Transform) String() string {
+	switch {
+	case t.IsIdentity():
+		return ""
+	case t.A == 1 && t.B == 0 && t.C == 0 && t.D == 1:
+		return fmt.Sprintf("translate(%s %s)", formatNumber(t.E), formatNumber(t.F))
+	case t.B == 0 && t.C == 0 && t.E == 0 && t.F == 0:
+		return fmt.Sprintf("scale(%s %s)", formatNumber(t.A), formatNumber(t.D))
+	default:
+		return fmt.Sprintf("matrix(%s %s %s %s %s %s)",
+			formatNumber(t.A), formatNumber(t.B), formatNumber(t.C),
+			formatNumber(t.D), formatNumber(t.E), formatNumber(t.F))
+	}
+}
@@ -0,0 +1,361 @@
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// User is the identity FileSystemManager operations run as
+type User struct {
+	Name   string
+	Groups []string // The first group is given to the nodes the user creates
+}
+
+// Superuser may do anything. Operations of a new FileSystemManager run as
+// the superuser, and new nodes are owned by it
+var Superuser = User{Name: "root", Groups: []string{"root"}}
+
+var (
+	// ErrNotFound is returned when a path does not name a node
+	ErrNotFound = errors.New("node not found")
+	// ErrQuotaExceeded is returned when a change would take a directory over
+	// its quota
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// IsSuperuser reports whether u is the superuser
+func (u User) IsSuperuser() bool {
+	return u.Name == Superuser.Name
+}
+
+// InGroup reports whether u is a member of group
+func (u User) InGroup(group string) bool {
+	for _, g := range u.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Owns reports whether u may change the permissions and group of node
+func (u User) Owns(node FileSystemNode) bool {
+	return u.IsSuperuser() || node.Owner() == u.Name
+}
+
+// Can reports whether u has all of perm on node. perm is given with the
+// owner bits, such as Read|Write, and checked against the owner, group or
+// other bits of the node depending on who u is
+func (u User) Can(node FileSystemNode, perm Permission) bool {
+	return u.allowed(node.Owner(), node.Group(), node.GetPermissions(), perm)
+}
+
+// allowed reports whether u has all of perm on a node with the given owner,
+// group and permissions
+func (u User) allowed(owner, group string, granted, perm Permission) bool {
+	if u.IsSuperuser() {
+		return true
+	}
+	switch {
+	case owner == u.Name:
+	case u.InGroup(group):
+		granted >>= 3
+	default:
+		granted >>= 6
+	}
+	return granted&perm == perm
+}
+
+// primaryGroup returns the group given to the nodes u creates
+func (u User) primaryGroup() string {
+	if len(u.Groups) == 0 {
+		return ""
+	}
+	return u.Groups[0]
+}
+
+// checkAccess returns an *fs.PathError for op unless user is nil or has
+// perm on node
+func checkAccess(op, path string, node FileSystemNode, perm Permission, user *User) error {
+	if user == nil || user.Can(node, perm) {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: path, Err: fs.ErrPermission}
+}
+
+// Quota limits the content of a directory, including its subdirectories
+type Quota struct {
+	MaxBytes  int64 // Total size of files; 0 means no limit
+	MaxInodes int64 // Number of files and directories; 0 means no limit
+}
+
+// Quota returns the quota of the directory
+func (d *Directory) Quota() Quota {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.quota
+}
+
+// Usage returns the total size of the files under d and the number of files
+// and directories under it, which count towards its quota
+func (d *Directory) Usage() (bytes, inodes int64) {
+	bytes, inodes = usage(d)
+	return bytes, inodes - 1
+}
+
+// usage returns the size of node and the number of nodes in its subtree,
+// including itself
+func usage(node FileSystemNode) (bytes, inodes int64) {
+	inodes = 1
+	if dir, ok := node.(*Directory); ok {
+		for _, child := range dir.Children() {
+			_, n := usage(child)
+			inodes += n
+		}
+	}
+	return node.Size(), inodes
+}
+
+// checkQuota checks that adding bytes and inodes under dir keeps dir and
+// its ancestors within their quotas. moved is a node being moved, if any,
+// which already counts towards the directories it is in
+func checkQuota(dir *Directory, bytes, inodes int64, moved FileSystemNode) error {
+	for d := dir; d != nil; d = d.parent.Load() {
+		quota := d.Quota()
+		if quota == (Quota{}) || (moved != nil && isWithin(moved, d)) {
+			continue
+		}
+		used, count := d.Usage()
+		if quota.MaxBytes > 0 && used+bytes > quota.MaxBytes {
+			return fmt.Errorf("%w: '%s' is limited to %d bytes", ErrQuotaExceeded, d.Path(), quota.MaxBytes)
+		}
+		if quota.MaxInodes > 0 && count+inodes > quota.MaxInodes {
+			return fmt.Errorf("%w: '%s' is limited to %d files and directories", ErrQuotaExceeded, d.Path(), quota.MaxInodes)
+		}
+	}
+	return nil
+}
+
+// isWithin reports whether node is dir or is inside it
+func isWithin(node FileSystemNode, dir *Directory) bool {
+	for node != nil {
+		if node == FileSystemNode(dir) {
+			return true
+		}
+		parent := parentOf(node)
+		if parent == nil {
+			return false
+		}
+		node = parent
+	}
+	return false
+}
+
+// parentOf returns the directory containing node, or nil for a root
+func parentOf(node FileSystemNode) *Directory {
+	switch n := node.(type) {
+	case *File:
+		return n.parent.Load()
+	case *Directory:
+		return n.parent.Load()
+	}
+	return nil
+}
+
+// As returns a manager for the same tree whose operations run as user
+func (m *FileSystemManager) As(user User) *FileSystemManager {
+	return &FileSystemManager{root: m.root, user: user, tree: m.tree}
+}
+
+// User returns the identity the manager's operations run as
+func (m *FileSystemManager) User() User {
+	return m.user
+}
+
+// Chmod sets the permissions of the node at path. Only its owner and the
+// superuser may change them
+func (m *FileSystemManager) Chmod(path string, perm Permission) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	node, err := m.resolve("chmod", path)
+	if err != nil {
+		return err
+	}
+	if !m.user.Owns(node) {
+		return &fs.PathError{Op: "chmod", Path: path, Err: fs.ErrPermission}
+	}
+	node.SetPermissions(perm)
+	return nil
+}
+
+// Chown sets the owner and group of the node at path, leaving either
+// unchanged if it is empty. Only the superuser may give a node to another
+// user. The owner of a node may change its group to one of their own
+func (m *FileSystemManager) Chown(path, owner, group string) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	node, err := m.resolve("chown", path)
+	if err != nil {
+		return err
+	}
+	if owner == "" {
+		owner = node.Owner()
+	}
+	if group == "" {
+		group = node.Group()
+	}
+	if !m.user.IsSuperuser() && (!m.user.Owns(node) || owner != node.Owner() || !m.user.InGroup(group)) {
+		return &fs.PathError{Op: "chown", Path: path, Err: fs.ErrPermission}
+	}
+	node.SetOwner(owner, group)
+	return nil
+}
+
+// SetQuota limits the size and number of nodes under the directory at path.
+// Only the superuser may set quotas. A quota below the current usage stops
+// the directory from growing but leaves its content in place
+func (m *FileSystemManager) SetQuota(path string, quota Quota) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	node, err := m.resolve("setquota", path)
+	if err != nil {
+		return err
+	}
+	dir, ok := node.(*Directory)
+	if !ok {
+		return fmt.Errorf("'%s' is not a directory", path)
+	}
+	if !m.user.IsSuperuser() {
+		return &fs.PathError{Op: "setquota", Path: path, Err: fs.ErrPermission}
+	}
+	if quota.MaxBytes < 0 || quota.MaxInodes < 0 {
+		return errors.New("quota limits must not be negative")
+	}
+	dir.quotaMu.Lock()
+	dir.mu.Lock()
+	dir.quota = quota
+	dir.mu.Unlock()
+	dir.quotaMu.Unlock()
+	dir.changed()
+	return nil
+}
+
+// WriteFile replaces the content of the file at path, creating it if it
+// does not exist
+func (m *FileSystemManager) WriteFile(path string, content []byte) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+
+	node, err := m.resolve("write", path)
+	if errors.Is(err, ErrNotFound) {
+		_, err = m.createFile(path, content)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	file, ok := node.(*File)
+	if !ok {
+		return fmt.Errorf("'%s' is a directory", path)
+	}
+	if err := checkAccess("write", path, file, Write, &m.user); err != nil {
+		return err
+	}
+	parent := file.parent.Load()
+	defer lockQuotas(parent)()
+	if err := checkQuota(parent, int64(len(content))-file.Size(), 0, nil); err != nil {
+		return err
+	}
+	file.SetContent(content)
+	return nil
+}
+
+// resolve finds the node at path, which needs execute permission on each
+// directory leading to it
+func (m *FileSystemManager) resolve(op, path string) (FileSystemNode, error) {
+	var node FileSystemNode = m.root
+	for _, part := range splitPath(path) {
+		dir, ok := node.(*Directory)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		if err := checkAccess(op, path, dir, Execute, &m.user); err != nil {
+			return nil, err
+		}
+		if node = dir.GetChild(part); node == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+	}
+	return node, nil
+}
+
+// create adds a new node, and any nodes inside it, to parent on behalf of
+// the user. The user needs write and execute permission on parent and the
+// quotas of parent and its ancestors must allow the new nodes. If parent
+// already has a child with the same name, create returns it instead
+func (m *FileSystemManager) create(op, path string, parent *Directory, node FileSystemNode) (existing FileSystemNode, err error) {
+	if err := checkAccess(op, path, parent, Write|Execute, &m.user); err != nil {
+		return nil, err
+	}
+	defer lockQuotas(parent)()
+	bytes, inodes := usage(node)
+	if err := checkQuota(parent, bytes, inodes, nil); err != nil {
+		return nil, err
+	}
+	m.own(node)
+	s := capture(node)
+
+	parent.mu.Lock()
+	existing = parent.child(node.Name())
+	if existing == nil {
+		parent.add(node)
+		parent.treeIndex().nodeAdded(parent, s)
+	}
+	parent.mu.Unlock()
+	if existing == nil {
+		parent.changed()
+	}
+	return existing, nil
+}
+
+// own gives node and any nodes inside it to the user
+func (m *FileSystemManager) own(node FileSystemNode) {
+	node.SetOwner(m.user.Name, m.user.primaryGroup())
+	if dir, ok := node.(*Directory); ok {
+		for _, child := range dir.Children() {
+			m.own(child)
+		}
+	}
+}
+
+// checkReadable checks that the user may read the file or directory tree at
+// path, as needed to copy it
+func (m *FileSystemManager) checkReadable(path string, node FileSystemNode) error {
+	dir, ok := node.(*Directory)
+	if !ok {
+		return checkAccess("read", path, node, Read, &m.user)
+	}
+	if err := checkAccess("read", path, dir, Read|Execute, &m.user); err != nil {
+		return err
+	}
+	for _, child := range dir.Children() {
+		if err := m.checkReadable(filepath.Join(path, child.Name()), child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitPath returns the names in a slash separated path from the root
+func splitPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Clean("/"+path)), "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
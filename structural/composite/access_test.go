@@ -0,0 +1,339 @@
+package composite
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var (
+	alice   = User{Name: "alice", Groups: []string{"alice", "staff"}}
+	bob     = User{Name: "bob", Groups: []string{"bob", "staff"}}
+	mallory = User{Name: "mallory", Groups: []string{"mallory"}}
+)
+
+// newHomes returns a manager with a home directory for alice and bob, each
+// owned by its user and the staff group with mode 0750, and a world
+// writable /tmp
+func newHomes(t *testing.T) *FileSystemManager {
+	t.Helper()
+	fsm := NewFileSystemManager()
+	for _, user := range []User{alice, bob} {
+		home := "/home/" + user.Name
+		if _, err := fsm.CreateDirectoryPath(home); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsm.Chown(home, user.Name, "staff"); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsm.Chmod(home, Read|Write|Execute|GroupRead|GroupExecute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := fsm.CreateDirectory("/tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsm.Chmod("/tmp", DefaultDirectoryPermissions|GroupWrite|OtherWrite); err != nil {
+		t.Fatal(err)
+	}
+	return fsm
+}
+
+// expectDenied fails the test unless err is a permission error
+func expectDenied(t *testing.T, what string, err error) {
+	t.Helper()
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("%s: expected a permission error, got %v", what, err)
+	}
+}
+
+// TestOwnership tests that new nodes belong to the user creating them
+func TestOwnership(t *testing.T) {
+	fsm := newHomes(t)
+
+	file, err := fsm.As(alice).CreateFile("/home/alice/notes.txt", []byte("notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Owner() != "alice" || file.Group() != "alice" || file.GetPermissions() != DefaultFilePermissions {
+		t.Errorf("Unexpected new file: %s:%s %v", file.Owner(), file.Group(), file.GetPermissions().Mode())
+	}
+
+	if err := fsm.As(bob).CopyNode("/home/alice/notes.txt", "/tmp/notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	copied, _ := fsm.FindNode("/tmp/notes.txt")
+	if copied.Owner() != "bob" || copied.Group() != "bob" {
+		t.Errorf("Expected the copy to belong to bob, got %s:%s", copied.Owner(), copied.Group())
+	}
+
+	if err := fsm.As(alice).MoveNode("/home/alice/notes.txt", "/tmp/moved.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if file.Owner() != "alice" {
+		t.Errorf("Expected a moved node to keep its owner, got %s", file.Owner())
+	}
+
+	root, _ := fsm.FindNode("/")
+	if root.Owner() != "root" || fsm.User().Name != "root" {
+		t.Error("Expected a new manager to run as and be owned by root")
+	}
+}
+
+// TestPermissionChecks tests that operations check read, write and execute
+// bits for the owner, the group and everyone else
+func TestPermissionChecks(t *testing.T) {
+	fsm := newHomes(t)
+	asAlice, asBob, asMallory := fsm.As(alice), fsm.As(bob), fsm.As(mallory)
+	if _, err := asAlice.CreateFile("/home/alice/notes.txt", []byte("notes")); err != nil {
+		t.Fatal(err)
+	}
+
+	// bob is in the staff group, which may read alice's home but not write it
+	if _, err := asBob.ReadFile("home/alice/notes.txt"); err != nil {
+		t.Errorf("Expected bob to read alice's notes, got %v", err)
+	}
+	_, err := asBob.CreateFile("/home/alice/bob.txt", nil)
+	expectDenied(t, "bob creating in alice's home", err)
+	expectDenied(t, "bob deleting alice's file", asBob.DeleteNode("/home/alice/notes.txt"))
+	expectDenied(t, "bob moving alice's file", asBob.MoveNode("/home/alice/notes.txt", "/home/bob/notes.txt"))
+	expectDenied(t, "bob writing alice's file", asBob.WriteFile("/home/alice/notes.txt", []byte("bob was here")))
+	expectDenied(t, "alice moving into bob's home", asAlice.MoveNode("/home/alice/notes.txt", "/home/bob/notes.txt"))
+
+	// mallory cannot even look inside the homes
+	_, err = asMallory.FindNode("/home/alice/notes.txt")
+	expectDenied(t, "mallory finding alice's file", err)
+	_, err = asMallory.ReadFile("home/alice/notes.txt")
+	expectDenied(t, "mallory reading alice's file", err)
+	_, err = asMallory.ReadDir("home/bob")
+	expectDenied(t, "mallory listing bob's home", err)
+	expectDenied(t, "mallory copying alice's home", asMallory.CopyNode("/home/alice", "/tmp/stolen"))
+	if _, err := asMallory.ReadDir("home"); err != nil {
+		t.Errorf("Expected mallory to list /home, got %v", err)
+	}
+
+	// Once alice makes her notes private, bob can no longer read or copy them
+	if err := asAlice.Chmod("/home/alice/notes.txt", Read|Write); err != nil {
+		t.Fatal(err)
+	}
+	_, err = asBob.ReadFile("home/alice/notes.txt")
+	expectDenied(t, "bob reading private notes", err)
+	expectDenied(t, "bob copying private notes", asBob.CopyNode("/home/alice/notes.txt", "/tmp/notes.txt"))
+	_, err = asBob.Open("home/alice/notes.txt")
+	expectDenied(t, "bob opening private notes", err)
+	if _, err := asBob.Stat("home/alice/notes.txt"); err != nil {
+		t.Errorf("Expected bob to stat private notes, got %v", err)
+	}
+
+	// A read-only file cannot be written even by its owner, but the
+	// superuser may do anything
+	if err := asAlice.Chmod("/home/alice/notes.txt", Read); err != nil {
+		t.Fatal(err)
+	}
+	expectDenied(t, "alice writing a read-only file", asAlice.WriteFile("/home/alice/notes.txt", []byte("x")))
+	if err := fsm.WriteFile("/home/alice/notes.txt", []byte("root")); err != nil {
+		t.Errorf("Expected root to write any file, got %v", err)
+	}
+
+	// The owner may still delete it because the directory is writable
+	if err := asAlice.DeleteNode("/home/alice/notes.txt"); err != nil {
+		t.Errorf("Expected alice to delete her file, got %v", err)
+	}
+
+	// The error names the operation and path
+	err = asBob.DeleteNode("/home/alice")
+	if err == nil || err.Error() != "remove /home/alice: permission denied" {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+// TestChmodChown tests who may change permissions and ownership
+func TestChmodChown(t *testing.T) {
+	fsm := newHomes(t)
+	asAlice, asBob := fsm.As(alice), fsm.As(bob)
+	if _, err := asAlice.CreateFile("/home/alice/notes.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expectDenied(t, "bob chmod", asBob.Chmod("/home/alice/notes.txt", 0))
+	expectDenied(t, "alice giving a file away", asAlice.Chown("/home/alice/notes.txt", "bob", ""))
+	expectDenied(t, "alice using a group she is not in", asAlice.Chown("/home/alice/notes.txt", "", "wheel"))
+	expectDenied(t, "bob changing the group", asBob.Chown("/home/alice/notes.txt", "", "staff"))
+
+	if err := asAlice.Chown("/home/alice/notes.txt", "", "staff"); err != nil {
+		t.Errorf("Expected alice to change the group, got %v", err)
+	}
+	if err := fsm.Chown("/home/alice/notes.txt", "bob", "bob"); err != nil {
+		t.Errorf("Expected root to give the file away, got %v", err)
+	}
+	node, _ := fsm.FindNode("/home/alice/notes.txt")
+	if node.Owner() != "bob" || node.Group() != "bob" {
+		t.Errorf("Expected bob:bob, got %s:%s", node.Owner(), node.Group())
+	}
+	if err := asBob.Chmod("/home/alice/notes.txt", Read); err != nil {
+		t.Errorf("Expected the new owner to chmod, got %v", err)
+	}
+}
+
+// TestUpdatePermissionsOwnership tests that UpdatePermissions only changes
+// the nodes the user owns
+func TestUpdatePermissionsOwnership(t *testing.T) {
+	fsm := newHomes(t)
+	asAlice := fsm.As(alice)
+	asAlice.CreateFile("/tmp/alice.txt", nil)
+	fsm.As(bob).CreateFile("/tmp/bob.txt", nil)
+	asAlice.CreateFile("/tmp/alice.md", nil)
+
+	modified, err := asAlice.UpdatePermissions("/tmp", Execute, 0, true, false, true, ".txt")
+	expectDenied(t, "alice updating bob's file", err)
+	if err == nil || !strings.Contains(err.Error(), "/tmp/bob.txt") {
+		t.Errorf("Expected the error to name bob's file, got %v", err)
+	}
+	if modified != 1 {
+		t.Errorf("Expected 1 file to be modified, got %d", modified)
+	}
+	for path, expected := range map[string]bool{"/tmp/alice.txt": true, "/tmp/bob.txt": false, "/tmp/alice.md": false} {
+		node, _ := fsm.FindNode(path)
+		if node.GetPermissions()&Execute != 0 != expected {
+			t.Errorf("%s: expected execute %v", path, expected)
+		}
+	}
+
+	// Non-recursive updates check ownership too
+	modified, err = asAlice.UpdatePermissions("/tmp", 0, Read, true, true, false, "")
+	expectDenied(t, "alice updating /tmp", err)
+	if modified != 2 {
+		t.Errorf("Expected alice's 2 files to be modified, got %d", modified)
+	}
+
+	// Nodes whose permissions would not change are not denied
+	if _, err := asAlice.UpdatePermissions("/tmp/bob.txt", 0, Execute, true, false, false, ""); err != nil {
+		t.Errorf("Expected no error for an unchanged node, got %v", err)
+	}
+}
+
+// TestQuotas tests byte and inode quotas on create, write, copy, move and
+// import
+func TestQuotas(t *testing.T) {
+	fsm := newHomes(t)
+	asAlice := fsm.As(alice)
+
+	expectDenied(t, "alice setting a quota", asAlice.SetQuota("/home/alice", Quota{MaxBytes: 1}))
+	if err := fsm.SetQuota("/home/alice", Quota{MaxBytes: 100, MaxInodes: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if fsm.Root().FindByPath("home/alice").(*Directory).Quota().MaxBytes != 100 {
+		t.Error("Expected the quota to be set")
+	}
+
+	expectQuota := func(what string, err error) {
+		t.Helper()
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("%s: expected ErrQuotaExceeded, got %v", what, err)
+		}
+	}
+
+	if _, err := asAlice.CreateFile("/home/alice/a.txt", make([]byte, 60)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := asAlice.CreateFile("/home/alice/b.txt", make([]byte, 41))
+	expectQuota("create", err)
+	expectQuota("write", asAlice.WriteFile("/home/alice/a.txt", make([]byte, 101)))
+	if err := asAlice.WriteFile("/home/alice/a.txt", make([]byte, 100)); err != nil {
+		t.Errorf("Expected to fill the quota exactly, got %v", err)
+	}
+	expectQuota("copy", asAlice.CopyNode("/home/alice/a.txt", "/home/alice/b.txt"))
+
+	asAlice.CreateFile("/tmp/small.txt", []byte("x"))
+	expectQuota("move in", asAlice.MoveNode("/tmp/small.txt", "/home/alice/small.txt"))
+
+	// Moving within the directory does not change its usage
+	if err := asAlice.MoveNode("/home/alice/a.txt", "/home/alice/docs/a.txt"); err != nil {
+		t.Errorf("Expected a move within the quota, got %v", err)
+	}
+
+	// The home now has docs and docs/a.txt; two more nodes fit
+	if _, err := asAlice.CreateDirectoryPath("/home/alice/x/y"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = asAlice.CreateDirectory("/home/alice/z")
+	expectQuota("inodes", err)
+	used, inodes := fsm.Root().FindByPath("home/alice").(*Directory).Usage()
+	if used != 100 || inodes != 4 {
+		t.Errorf("Expected usage of 100 bytes and 4 inodes, got %d and %d", used, inodes)
+	}
+
+	// Quotas of parent directories apply too
+	fsm.SetQuota("/home", Quota{MaxBytes: 110})
+	_, err = fsm.As(bob).CreateFile("/home/bob/big.txt", make([]byte, 11))
+	expectQuota("parent quota", err)
+
+	// Imports are checked as they go
+	var buf bytes.Buffer
+	src := NewFileSystemManager()
+	src.CreateFile("/big.txt", make([]byte, 20))
+	src.ExportTar(&buf, "/", TransferOptions{})
+	expectQuota("import", fsm.As(bob).ImportTar(&buf, "/home/bob", TransferOptions{}))
+
+	if err := fsm.SetQuota("/home/alice", Quota{MaxBytes: -1}); err == nil {
+		t.Error("Expected an error for a negative quota")
+	}
+}
+
+// TestMoveIntoItself tests that a directory cannot be moved inside itself
+func TestMoveIntoItself(t *testing.T) {
+	fsm := newHomes(t)
+	err := fsm.MoveNode("/home", "/home/alice/home")
+	if err == nil || !strings.Contains(err.Error(), "inside itself") {
+		t.Errorf("Expected an error moving a directory inside itself, got %v", err)
+	}
+}
+
+// TestTransferPermissions tests that imports and exports run as the
+// manager's user
+func TestTransferPermissions(t *testing.T) {
+	fsm := newHomes(t)
+	asAlice := fsm.As(alice)
+	asAlice.CreateFile("/home/alice/secret.txt", []byte("secret"))
+	asAlice.Chmod("/home/alice/secret.txt", Read|Write)
+
+	expectDenied(t, "bob exporting alice's secret", fsm.As(bob).ExportTar(&bytes.Buffer{}, "/home/alice", TransferOptions{}))
+
+	var buf bytes.Buffer
+	if err := asAlice.ExportTar(&buf, "/home/alice", TransferOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+	expectDenied(t, "bob importing into alice's home", fsm.As(bob).ImportTar(bytes.NewReader(archive), "/home/alice/copy", TransferOptions{}))
+	if err := fsm.As(bob).ImportTar(bytes.NewReader(archive), "/home/bob/copy", TransferOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	node, _ := fsm.FindNode("/home/bob/copy/secret.txt")
+	if node.Owner() != "bob" || node.GetPermissions() != Read|Write {
+		t.Errorf("Expected bob to own the imported file with its permissions, got %s %v", node.Owner(), node.GetPermissions().Mode())
+	}
+}
+
+// TestUserFS tests the io/fs view of a manager running as a user
+func TestUserFS(t *testing.T) {
+	fsm := newHomes(t)
+	asAlice := fsm.As(alice)
+	asAlice.CreateFile("/home/alice/notes.txt", []byte("notes"))
+	asAlice.CreateFile("/home/alice/docs/readme.md", []byte("# README"))
+
+	home, err := fs.Sub(asAlice, "home/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(home, "notes.txt", "docs/readme.md"); err != nil {
+		t.Error(err)
+	}
+
+	err = fs.WalkDir(fsm.As(mallory), ".", func(path string, d fs.DirEntry, err error) error {
+		return err
+	})
+	expectDenied(t, "mallory walking the tree", err)
+}
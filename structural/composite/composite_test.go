@@ -137,12 +137,12 @@ func TestHierarchy(t *testing.T) {
 	dataDir.Add(dataFile)
 	
 	// Test sizes
-	if root.Size() != 36 {
-		t.Errorf("Expected root size to be 36, got %d", root.Size())
+	if root.Size() != 34 {
+		t.Errorf("Expected root size to be 34, got %d", root.Size())
 	}
 	
-	if docsDir.Size() != 21 {
-		t.Errorf("Expected docs size to be 21, got %d", docsDir.Size())
+	if docsDir.Size() != 20 {
+		t.Errorf("Expected docs size to be 20, got %d", docsDir.Size())
 	}
 	
 	// Test paths
@@ -262,6 +262,10 @@ func TestFileSystemManager(t *testing.T) {
 		t.Errorf("Error finding moved node: %v", err)
 	}
 	
+	if moved != file3 {
+		t.Error("Expected the moved node to be the same instance")
+	}
+	
 	if moved.Name() != "temp.txt" {
 		t.Errorf("Expected moved node name to be 'temp.txt', got '%s'", moved.Name())
 	}
@@ -294,6 +298,55 @@ func TestFileSystemManager(t *testing.T) {
 	}
 }
 
+// TestCopyDirectory tests copying a directory with children. CopyNode used
+// to copy the children into an implicitly created directory and then add
+// an empty copy next to it, leaving two nodes with the same name
+func TestCopyDirectory(t *testing.T) {
+	fsm := NewFileSystemManager()
+	fsm.CreateFile("/data/numbers.csv", []byte("1,2,3"))
+	fsm.CreateFile("/data/processed/2023/summary.txt", []byte("total: 6"))
+	fsm.Chmod("/data/processed", Read|Write|Execute)
+	
+	err := fsm.CopyNode("/data", "/archive/data")
+	if err != nil {
+		t.Fatalf("Error copying directory: %v", err)
+	}
+	
+	archive, err := fsm.FindNode("/archive")
+	if err != nil {
+		t.Fatalf("Error finding archive directory: %v", err)
+	}
+	
+	if len(archive.(*Directory).Children()) != 1 {
+		t.Errorf("Expected the copied directory once, got %d children", len(archive.(*Directory).Children()))
+	}
+	
+	summary, err := fsm.FindNode("/archive/data/processed/2023/summary.txt")
+	if err != nil {
+		t.Fatalf("Error finding copied file: %v", err)
+	}
+	
+	if string(summary.(*File).Content()) != "total: 6" {
+		t.Errorf("Expected the nested file to be copied, got %q", summary.(*File).Content())
+	}
+	
+	processed, err := fsm.FindNode("/archive/data/processed")
+	if err != nil {
+		t.Fatalf("Error finding copied subdirectory: %v", err)
+	}
+	
+	if processed.GetPermissions() != Read|Write|Execute {
+		t.Errorf("Expected the copy to keep permissions, got %o", processed.GetPermissions())
+	}
+	
+	// The copy is independent of the original
+	fsm.WriteFile("/archive/data/numbers.csv", []byte("7,8,9"))
+	original, _ := fsm.FindNode("/data/numbers.csv")
+	if string(original.(*File).Content()) != "1,2,3" {
+		t.Errorf("Expected the original to be unchanged, got %q", original.(*File).Content())
+	}
+}
+
 func TestVisitors(t *testing.T) {
 	fsm := NewFileSystemManager()
 	
@@ -315,9 +368,9 @@ func TestVisitors(t *testing.T) {
 		t.Errorf("Error applying size visitor: %v", err)
 	}
 	
-	// We have 4 .md files with total size 50 bytes (excluding hidden files)
-	if sizeVisitor.TotalSize != 50 {
-		t.Errorf("Expected .md files size to be 50, got %d", sizeVisitor.TotalSize)
+	// We have 4 .md files with total size 59 bytes (excluding hidden files)
+	if sizeVisitor.TotalSize != 59 {
+		t.Errorf("Expected .md files size to be 59, got %d", sizeVisitor.TotalSize)
 	}
 	
 	// Test SearchVisitor
@@ -384,8 +437,8 @@ func TestVisitors(t *testing.T) {
 		t.Errorf("Error calculating statistics: %v", err)
 	}
 	
-	if stats.FileCount != 9 {
-		t.Errorf("Expected 9 files, got %d", stats.FileCount)
+	if stats.FileCount != 8 {
+		t.Errorf("Expected 8 files, got %d", stats.FileCount)
 	}
 	
 	if stats.DirectoryCount != 4 {
@@ -0,0 +1,298 @@
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// checkTree checks that every node below dir is in the tree once and points
+// back at its parent, and that names are unique within each directory
+func checkTree(t *testing.T, dir *Directory, seen map[FileSystemNode]bool) {
+	t.Helper()
+	names := map[string]bool{}
+	for _, child := range dir.Children() {
+		if seen[child] {
+			t.Fatalf("'%s' appears twice in the tree", child.Path())
+		}
+		seen[child] = true
+		if names[child.Name()] {
+			t.Fatalf("'%s' has two children named '%s'", dir.Path(), child.Name())
+		}
+		names[child.Name()] = true
+		if parent := parentOf(child); parent != dir {
+			t.Fatalf("'%s' is in '%s' but its parent is '%v'", child.Name(), dir.Path(), parent)
+		}
+		if sub, ok := child.(*Directory); ok {
+			checkTree(t, sub, seen)
+		}
+	}
+}
+
+// runConcurrently runs fn on the given number of goroutines, failing the
+// test if they have not all returned within a generous time
+func runConcurrently(t *testing.T, workers int, fn func(worker int)) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			fn(w)
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		t.Fatal("operations did not finish, probably deadlocked")
+	}
+}
+
+// TestConcurrentOperations runs random operations on one tree from many
+// goroutines, then checks that the tree and its index are consistent
+func TestConcurrentOperations(t *testing.T) {
+	fsm := NewFileSystemManager()
+	const dirs = 4
+	for d := 0; d < dirs; d++ {
+		if _, err := fsm.CreateDirectoryPath(fmt.Sprintf("/d%d/sub", d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice := fsm.As(User{Name: "alice", Groups: []string{"staff"}})
+
+	iterations := 300
+	if testing.Short() {
+		iterations = 50
+	}
+	var snapshots atomic.Pointer[Snapshot]
+	snapshots.Store(fsm.Snapshot())
+
+	runConcurrently(t, 8, func(worker int) {
+		rng := rand.New(rand.NewSource(int64(worker)))
+		path := func() string {
+			switch rng.Intn(3) {
+			case 0:
+				return fmt.Sprintf("/d%d/f%d", rng.Intn(dirs), rng.Intn(8))
+			case 1:
+				return fmt.Sprintf("/d%d/sub/f%d", rng.Intn(dirs), rng.Intn(8))
+			}
+			return fmt.Sprintf("/d%d/sub", rng.Intn(dirs))
+		}
+		for i := 0; i < iterations; i++ {
+			switch rng.Intn(12) {
+			case 0, 1:
+				fsm.CreateFile(path(), []byte(fmt.Sprintf("worker %d wrote %d", worker, i)))
+			case 2:
+				fsm.WriteFile(path(), []byte("rewritten content"))
+			case 3, 4:
+				fsm.MoveNode(path(), path())
+			case 5:
+				fsm.CopyNode(path(), path())
+			case 6:
+				fsm.DeleteNode(path())
+			case 7:
+				fsm.Chmod(path(), Permission(rng.Intn(0o1000)))
+			case 8:
+				fsm.Find(Query{Text: "worker"}, func(Result) bool { return true })
+			case 9:
+				s := fsm.Snapshot()
+				Diff(snapshots.Swap(s), s)
+			case 10:
+				alice.ReadFile(path()[1:])
+				alice.CreateFile(path(), []byte("alice"))
+			case 11:
+				if node, err := fsm.FindNode(path()); err == nil {
+					node.Accept(NewSearchVisitor("f", "worker", false, 0, 0, 0))
+					node.Size()
+					node.Path()
+				}
+			}
+		}
+	})
+
+	checkTree(t, fsm.Root(), map[FileSystemNode]bool{})
+	checkIndex(t, fsm, "after concurrent operations")
+}
+
+// TestConcurrentMoves moves nodes between two directories in opposite
+// directions at once, including moves of the directories into each other,
+// which must neither deadlock nor lose nodes
+func TestConcurrentMoves(t *testing.T) {
+	fsm := NewFileSystemManager()
+	const files = 16
+	for i := 0; i < files; i++ {
+		if _, err := fsm.CreateFile(fmt.Sprintf("/left/f%d", i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsm.CreateDirectory("/right")
+
+	iterations := 500
+	if testing.Short() {
+		iterations = 100
+	}
+	runConcurrently(t, 8, func(worker int) {
+		rng := rand.New(rand.NewSource(int64(worker)))
+		for i := 0; i < iterations; i++ {
+			switch f := rng.Intn(files); {
+			case worker == 0:
+				// Move the directories into each other and back
+				fsm.MoveNode("/left", "/right/left")
+				fsm.MoveNode("/right/left", "/left")
+			case worker == 1:
+				fsm.MoveNode("/right", "/left/right")
+				fsm.MoveNode("/left/right", "/right")
+			case worker%2 == 0:
+				fsm.MoveNode(fmt.Sprintf("/left/f%d", f), fmt.Sprintf("/right/f%d", f))
+			default:
+				fsm.MoveNode(fmt.Sprintf("/right/f%d", f), fmt.Sprintf("/left/f%d", f))
+			}
+		}
+	})
+
+	// A move recreates its destination directory if another move took it
+	// away, so only the files are counted
+	seen := map[FileSystemNode]bool{}
+	checkTree(t, fsm.Root(), seen)
+	count := 0
+	for node := range seen {
+		if !node.IsDirectory() {
+			count++
+		}
+	}
+	if count != files {
+		t.Errorf("Expected %d files after the moves, got %d", files, count)
+	}
+}
+
+// TestConcurrentQuota tests that concurrent creations cannot together take
+// a directory over its quota
+func TestConcurrentQuota(t *testing.T) {
+	fsm := NewFileSystemManager()
+	fsm.CreateDirectoryPath("/limited/a")
+	fsm.CreateDirectory("/limited/b")
+	if err := fsm.SetQuota("/limited", Quota{MaxInodes: 12}); err != nil {
+		t.Fatal(err)
+	}
+
+	var created, rejected atomic.Int64
+	runConcurrently(t, 20, func(worker int) {
+		_, err := fsm.CreateFile(fmt.Sprintf("/limited/%c/f%d", 'a'+worker%2, worker), []byte("x"))
+		switch {
+		case err == nil:
+			created.Add(1)
+		case errors.Is(err, ErrQuotaExceeded):
+			rejected.Add(1)
+		default:
+			t.Error(err)
+		}
+	})
+
+	if created.Load() != 10 || rejected.Load() != 10 {
+		t.Errorf("Expected 10 files created and 10 rejected, got %d and %d", created.Load(), rejected.Load())
+	}
+	dir, _ := fsm.FindNode("/limited")
+	if _, inodes := dir.(*Directory).Usage(); inodes != 12 {
+		t.Errorf("Expected 12 nodes under the quota, got %d", inodes)
+	}
+}
+
+// TestUnrelatedSubtrees tests that a locked directory does not block
+// changes elsewhere in the tree
+func TestUnrelatedSubtrees(t *testing.T) {
+	fsm := NewFileSystemManager()
+	busy, _ := fsm.CreateDirectory("/busy")
+	fsm.CreateDirectory("/other")
+
+	busy.mu.Lock()
+	defer busy.mu.Unlock()
+
+	runConcurrently(t, 1, func(int) {
+		if _, err := fsm.CreateFile("/other/file.txt", []byte("content")); err != nil {
+			t.Error(err)
+		}
+		if err := fsm.MoveNode("/other/file.txt", "/other/renamed.txt"); err != nil {
+			t.Error(err)
+		}
+		if _, err := fsm.ReadFile("other/renamed.txt"); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// TestDisjointSubtrees tests that moves and quota-checked writes in one
+// subtree go ahead while the same kinds of operation hold their locks in
+// another
+func TestDisjointSubtrees(t *testing.T) {
+	fsm := NewFileSystemManager()
+	for _, top := range []string{"/a", "/b"} {
+		fsm.CreateFile(top+"/x/file.txt", []byte("content"))
+		fsm.CreateDirectory(top + "/y")
+		if err := fsm.SetQuota(top, Quota{MaxBytes: 100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Hold the locks of a move from /a/x to /a/y and of a write under /a
+	find := func(p string) FileSystemNode {
+		node, err := fsm.FindNode(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return node
+	}
+	a := find("/a").(*Directory)
+	unlockQuota := lockQuotas(a)
+	unlockMove := lockMove(find("/a/x").(*Directory), find("/a/y").(*Directory), find("/a/x/file.txt"))
+	defer unlockQuota()
+	defer unlockMove()
+
+	done := make(chan error, 1)
+	go func() {
+		if err := fsm.MoveNode("/b/x/file.txt", "/b/y/file.txt"); err != nil {
+			done <- err
+			return
+		}
+		if err := fsm.WriteFile("/b/y/file.txt", []byte("new content")); err != nil {
+			done <- err
+			return
+		}
+		_, err := fsm.CreateFile("/b/x/other.txt", []byte("more"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Operations under /b waited for the locks held under /a")
+	}
+	if _, err := fsm.ReadFile("b/y/file.txt"); err != nil {
+		t.Errorf("Expected the move to have happened: %v", err)
+	}
+}
+
+// BenchmarkConcurrentCreate measures creating files from many goroutines,
+// each in its own directory
+func BenchmarkConcurrentCreate(b *testing.B) {
+	fsm := NewFileSystemManager()
+	var workers atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		dir := fmt.Sprintf("/w%d", workers.Add(1))
+		for i := 0; pb.Next(); i++ {
+			if _, err := fsm.CreateFile(fmt.Sprintf("%s/f%d", dir, i), []byte("content")); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io/fs"
 	"github.com/edgardnogueira/go-patterns/structural/composite"
 )
 
@@ -111,8 +112,32 @@ func main() {
 		fmt.Printf("Modified permissions for %d files\n", modified)
 	}
 	
+	// Walk the tree with the standard library
+	fmt.Println("\n5. Using io/fs:")
+	fmt.Println("---------------")
+	err = fs.WalkDir(fsm, "documents", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %6d %s\n", info.Mode(), info.Size(), path)
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error walking the file system: %v\n", err)
+	}
+	readme, err := fs.ReadFile(fsm, "documents/projects/project1/README.md")
+	if err != nil {
+		fmt.Printf("Error reading file: %v\n", err)
+	} else {
+		fmt.Printf("README.md: %q\n", readme)
+	}
+	
 	// Show final file system structure
-	fmt.Println("\n5. Final File System Structure:")
+	fmt.Println("\n6. Final File System Structure:")
 	fmt.Println("------------------------------")
 	fmt.Println(fsm.PrintFileSystem())
 	
@@ -1,9 +1,12 @@
 package composite
 
 import (
+	"bytes"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,6 +28,24 @@ type FileSystemNode interface {
 	// CreationTime returns when the node was created
 	CreationTime() time.Time
 	
+	// ModTime returns when the node was last modified
+	ModTime() time.Time
+	
+	// Owner returns the name of the user owning the node
+	Owner() string
+	
+	// Group returns the name of the group owning the node
+	Group() string
+	
+	// GetPermissions returns the permissions of the node
+	GetPermissions() Permission
+	
+	// SetPermissions sets the permissions of the node
+	SetPermissions(perm Permission)
+	
+	// SetOwner sets the user and group owning the node
+	SetOwner(owner, group string)
+	
 	// Print displays the node information
 	Print(prefix string) string
 	
@@ -32,7 +53,9 @@ type FileSystemNode interface {
 	Accept(visitor Visitor) error
 }
 
-// Permission represents file system permissions
+// Permission represents file system permissions. The low three bits apply
+// to the owner of a node, the next three to its group and the last three
+// to everyone else
 type Permission int
 
 const (
@@ -44,6 +67,22 @@ const (
 	Execute
 )
 
+// Permissions for members of the node's group and for everyone else
+const (
+	GroupRead    = Read << 3
+	GroupWrite   = Write << 3
+	GroupExecute = Execute << 3
+	OtherRead    = Read << 6
+	OtherWrite   = Write << 6
+	OtherExecute = Execute << 6
+)
+
+// Default permissions of new nodes, like 0644 and 0755 on Unix
+const (
+	DefaultFilePermissions      = Read | Write | GroupRead | OtherRead
+	DefaultDirectoryPermissions = Read | Write | Execute | GroupRead | GroupExecute | OtherRead | OtherExecute
+)
+
 // Visitor defines an interface for visiting nodes in the file system.
 // This allows operations to be performed on the structure without changing the classes.
 type Visitor interface {
@@ -54,49 +93,105 @@ type Visitor interface {
 	VisitDirectory(directory *Directory) error
 }
 
-// baseNode contains common attributes for both file and directory nodes
+// baseNode contains common attributes for both file and directory nodes.
+// Nodes are safe for concurrent use. Code that locks more than one node
+// takes the locks in order of id.
 type baseNode struct {
-	name         string
-	parent       *Directory
+	mu           sync.RWMutex // Guards the fields up to modTime and the content or children
+	name         string       // Also guarded by the parent's lock; changing it takes both
 	permissions  Permission
+	owner        string
+	group        string
 	creationTime time.Time
+	modTime      time.Time
+	parent       atomic.Pointer[Directory]
+	id           uint64                         // Identifies the node across snapshots
+	version      atomic.Uint64                  // Counts changes to the node and the nodes inside it
+	snap         atomic.Pointer[cachedSnapshot] // The node as last captured
+	index        *searchIndex                   // Set on the root of a managed tree
 }
 
 // Name returns the name of the node
 func (b *baseNode) Name() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return b.name
 }
 
 // Path returns the full path of the node
 func (b *baseNode) Path() string {
-	if b.parent == nil {
-		return b.name
+	parent := b.parent.Load()
+	if parent == nil {
+		return b.Name()
 	}
 	
-	return filepath.Join(b.parent.Path(), b.name)
+	return filepath.Join(parent.Path(), b.Name())
 }
 
 // CreationTime returns when the node was created
 func (b *baseNode) CreationTime() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return b.creationTime
 }
 
+// ModTime returns when the node was last modified. For a file this is when
+// its content last changed, and for a directory when a child was last added
+// or removed.
+func (b *baseNode) ModTime() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.modTime
+}
+
+// Owner returns the name of the user owning the node
+func (b *baseNode) Owner() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.owner
+}
+
+// Group returns the name of the group owning the node
+func (b *baseNode) Group() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.group
+}
+
+// SetOwner sets the user and group owning the node. It does not check that
+// the change is allowed; use FileSystemManager.Chown for that
+func (b *baseNode) SetOwner(owner, group string) {
+	b.mu.Lock()
+	b.owner = owner
+	b.group = group
+	b.treeIndex().attrsChanged(b)
+	b.mu.Unlock()
+	b.changed()
+}
+
 // SetPermissions sets the permissions for the node
 func (b *baseNode) SetPermissions(perm Permission) {
+	b.mu.Lock()
 	b.permissions = perm
+	b.treeIndex().attrsChanged(b)
+	b.mu.Unlock()
+	b.changed()
 }
 
 // GetPermissions returns the permissions for the node
 func (b *baseNode) GetPermissions() Permission {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return b.permissions
 }
 
-// HasPermission checks if the node has the specified permission
+// HasPermission checks if the node has any of the specified permissions
 func (b *baseNode) HasPermission(perm Permission) bool {
-	return b.permissions&perm != 0
+	return b.GetPermissions()&perm != 0
 }
 
-// PermissionsString returns a string representation of the permissions
+// PermissionsString returns a string representation of the owner's
+// permissions
 func (b *baseNode) PermissionsString() string {
 	perms := []string{}
 	
@@ -126,20 +221,28 @@ type File struct {
 	content []byte
 }
 
-// NewFile creates a new file with the given name and content
+// NewFile creates a new file with the given name and content, owned by the
+// superuser. The file keeps a copy of content.
 func NewFile(name string, content []byte) *File {
+	now := time.Now()
 	return &File{
 		baseNode: baseNode{
 			name:         name,
-			permissions:  Read | Write,
-			creationTime: time.Now(),
+			permissions:  DefaultFilePermissions,
+			owner:        Superuser.Name,
+			group:        Superuser.Groups[0],
+			creationTime: now,
+			modTime:      now,
+			id:           newNodeID(),
 		},
-		content: content,
+		content: bytes.Clone(content),
 	}
 }
 
 // Size returns the size of the file in bytes
 func (f *File) Size() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	return int64(len(f.content))
 }
 
@@ -148,20 +251,29 @@ func (f *File) IsDirectory() bool {
 	return false
 }
 
-// Content returns the content of the file
+// Content returns a copy of the content of the file
 func (f *File) Content() []byte {
-	return f.content
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return bytes.Clone(f.content)
 }
 
-// SetContent updates the content of the file
+// SetContent replaces the content of the file with a copy of content.
+// The file never shares its content with callers, so snapshots can share
+// it with the file.
 func (f *File) SetContent(content []byte) {
-	f.content = content
+	f.mu.Lock()
+	f.content = bytes.Clone(content)
+	f.modTime = time.Now()
+	f.treeIndex().contentChanged(f)
+	f.mu.Unlock()
+	f.changed()
 }
 
 // Print returns a string representation of the file
 func (f *File) Print(prefix string) string {
 	return fmt.Sprintf("%s- %s (file, size: %d bytes, permissions: %s)",
-		prefix, f.name, f.Size(), f.PermissionsString())
+		prefix, f.Name(), f.Size(), f.PermissionsString())
 }
 
 // Accept allows a visitor to visit the file
@@ -174,15 +286,27 @@ func (f *File) Accept(visitor Visitor) error {
 type Directory struct {
 	baseNode
 	children []FileSystemNode
+	quota    Quota
+
+	// quotaMu is held while checking and making a change inside a
+	// directory with a quota, so that concurrent changes cannot together
+	// exceed it
+	quotaMu sync.Mutex
 }
 
-// NewDirectory creates a new directory with the given name
+// NewDirectory creates a new directory with the given name, owned by the
+// superuser
 func NewDirectory(name string) *Directory {
+	now := time.Now()
 	return &Directory{
 		baseNode: baseNode{
 			name:         name,
-			permissions:  Read | Write | Execute,
-			creationTime: time.Now(),
+			permissions:  DefaultDirectoryPermissions,
+			owner:        Superuser.Name,
+			group:        Superuser.Groups[0],
+			creationTime: now,
+			modTime:      now,
+			id:           newNodeID(),
 		},
 		children: []FileSystemNode{},
 	}
@@ -191,7 +315,7 @@ func NewDirectory(name string) *Directory {
 // Size returns the total size of all children in the directory
 func (d *Directory) Size() int64 {
 	var size int64
-	for _, child := range d.children {
+	for _, child := range d.Children() {
 		size += child.Size()
 	}
 	return size
@@ -204,22 +328,48 @@ func (d *Directory) IsDirectory() bool {
 
 // Add adds a child node to the directory
 func (d *Directory) Add(node FileSystemNode) {
+	s := capture(node)
+	d.mu.Lock()
+	d.add(node)
+	d.treeIndex().nodeAdded(d, s)
+	d.mu.Unlock()
+	d.changed()
+}
+
+// add adds a child node to the directory, whose lock must be held
+func (d *Directory) add(node FileSystemNode) {
 	// Set parent for the node if it's a baseNode
 	switch n := node.(type) {
 	case *File:
-		n.parent = d
+		n.parent.Store(d)
 	case *Directory:
-		n.parent = d
+		n.parent.Store(d)
 	}
 	
 	d.children = append(d.children, node)
+	d.modTime = time.Now()
 }
 
 // Remove removes a child node from the directory
 func (d *Directory) Remove(node FileSystemNode) bool {
+	d.mu.Lock()
+	removed := d.remove(node)
+	if b := nodeBase(node); removed && b != nil {
+		d.treeIndex().nodeRemoved(d, b.id)
+	}
+	d.mu.Unlock()
+	if removed {
+		d.changed()
+	}
+	return removed
+}
+
+// remove removes a child node from the directory, whose lock must be held
+func (d *Directory) remove(node FileSystemNode) bool {
 	for i, child := range d.children {
 		if child == node {
 			d.children = append(d.children[:i], d.children[i+1:]...)
+			d.modTime = time.Now()
 			return true
 		}
 	}
@@ -228,17 +378,27 @@ func (d *Directory) Remove(node FileSystemNode) bool {
 
 // GetChild returns a child node by name
 func (d *Directory) GetChild(name string) FileSystemNode {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.child(name)
+}
+
+// child returns a child node by name. The directory's lock must be held
+func (d *Directory) child(name string) FileSystemNode {
 	for _, child := range d.children {
-		if child.Name() == name {
+		// Read the name directly, as the child may be locked by the caller
+		if b := nodeBase(child); b != nil && b.name == name || b == nil && child.Name() == name {
 			return child
 		}
 	}
 	return nil
 }
 
-// Children returns all child nodes
+// Children returns a copy of the list of child nodes
 func (d *Directory) Children() []FileSystemNode {
-	return d.children
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]FileSystemNode(nil), d.children...)
 }
 
 // Print returns a string representation of the directory and its children
@@ -246,10 +406,10 @@ func (d *Directory) Print(prefix string) string {
 	var result strings.Builder
 	
 	result.WriteString(fmt.Sprintf("%s+ %s (directory, size: %d bytes, permissions: %s)\n",
-		prefix, d.name, d.Size(), d.PermissionsString()))
+		prefix, d.Name(), d.Size(), d.PermissionsString()))
 	
 	childPrefix := prefix + "  "
-	for _, child := range d.children {
+	for _, child := range d.Children() {
 		result.WriteString(child.Print(childPrefix) + "\n")
 	}
 	
@@ -269,7 +429,7 @@ func (d *Directory) Accept(visitor Visitor) error {
 		return err
 	}
 	
-	for _, child := range d.children {
+	for _, child := range d.Children() {
 		err = child.Accept(visitor)
 		if err != nil {
 			return err
@@ -294,7 +454,7 @@ func (d *Directory) FindByPath(path string) FileSystemNode {
 	}
 	
 	// If the first component is the current directory
-	if components[0] == d.name {
+	if components[0] == d.Name() {
 		if len(components) == 1 {
 			return d
 		}
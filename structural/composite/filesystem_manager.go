@@ -3,20 +3,30 @@ package composite
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"path/filepath"
 	"strings"
 )
 
 // FileSystemManager provides utility functions for working with a file system.
 // It uses the Composite pattern for file system operations.
+// Operations run as a User: they check the permissions of the nodes they
+// touch and the quotas of the directories they change.
+// It is safe for concurrent use, and so are the managers returned by As.
 type FileSystemManager struct {
 	root *Directory
+	user User
+	tree *tree
 }
 
 // NewFileSystemManager creates a new file system manager with a root directory.
+// Its operations run as the Superuser; use As to act as another user.
 func NewFileSystemManager() *FileSystemManager {
+	root := NewDirectory("/")
 	return &FileSystemManager{
-		root: NewDirectory("/"),
+		root: root,
+		user: Superuser,
+		tree: newTree(root),
 	}
 }
 
@@ -27,10 +37,17 @@ func (m *FileSystemManager) Root() *Directory {
 
 // CreateFile creates a new file at the specified path.
 func (m *FileSystemManager) CreateFile(path string, content []byte) (*File, error) {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	return m.createFile(path, content)
+}
+
+// createFile creates a new file at the specified path.
+func (m *FileSystemManager) createFile(path string, content []byte) (*File, error) {
 	dir, fileName := filepath.Split(path)
 	
 	// Get or create parent directory
-	parentDir, err := m.CreateDirectoryPath(dir)
+	parentDir, err := m.createDirectoryPath(dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create parent directory: %w", err)
 	}
@@ -40,15 +57,22 @@ func (m *FileSystemManager) CreateFile(path string, content []byte) (*File, erro
 		return nil, fmt.Errorf("a node named '%s' already exists", fileName)
 	}
 	
-	// Create the file
+	// Create the file, unless another one took its name first
 	file := NewFile(fileName, content)
-	parentDir.Add(file)
+	if existing, err := m.create("create", path, parentDir, file); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("a node named '%s' already exists", fileName)
+	}
 	
 	return file, nil
 }
 
 // CreateDirectory creates a new directory at the specified path.
 func (m *FileSystemManager) CreateDirectory(path string) (*Directory, error) {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	
 	// Handle root directory
 	if path == "/" || path == "" {
 		return m.root, nil
@@ -69,29 +93,40 @@ func (m *FileSystemManager) CreateDirectory(path string) (*Directory, error) {
 	}
 	
 	// Get or create parent directory
-	parentDir, err := m.CreateDirectoryPath(dir)
+	parentDir, err := m.createDirectoryPath(dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create parent directory: %w", err)
 	}
 	
 	// Check if a node with this name already exists
-	if existingNode := parentDir.GetChild(dirName); existingNode != nil {
-		// If it's a directory, return it
-		if existingDir, ok := existingNode.(*Directory); ok {
-			return existingDir, nil
+	existingNode := parentDir.GetChild(dirName)
+	if existingNode == nil {
+		// Create the directory, unless another node took its name first
+		newDir := NewDirectory(dirName)
+		if existingNode, err = m.create("mkdir", path, parentDir, newDir); err != nil {
+			return nil, err
+		}
+		if existingNode == nil {
+			return newDir, nil
 		}
-		return nil, fmt.Errorf("a file named '%s' already exists", dirName)
 	}
 	
-	// Create the directory
-	newDir := NewDirectory(dirName)
-	parentDir.Add(newDir)
-	
-	return newDir, nil
+	// If it's a directory, return it
+	if existingDir, ok := existingNode.(*Directory); ok {
+		return existingDir, nil
+	}
+	return nil, fmt.Errorf("a file named '%s' already exists", dirName)
 }
 
 // CreateDirectoryPath creates all directories in a path and returns the last one.
 func (m *FileSystemManager) CreateDirectoryPath(path string) (*Directory, error) {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	return m.createDirectoryPath(path)
+}
+
+// createDirectoryPath creates all directories in a path and returns the last one.
+func (m *FileSystemManager) createDirectoryPath(path string) (*Directory, error) {
 	// Handle root directory
 	if path == "/" || path == "" || path == "." {
 		return m.root, nil
@@ -116,21 +151,31 @@ func (m *FileSystemManager) CreateDirectoryPath(path string) (*Directory, error)
 			continue
 		}
 		
+		// Looking up a child needs execute permission
+		if err := checkAccess("mkdir", path, currentDir, Execute, &m.user); err != nil {
+			return nil, err
+		}
+		
 		// Check if the directory already exists
 		child := currentDir.GetChild(component)
-		if child != nil {
-			// If it's a directory, move to it
-			if dir, ok := child.(*Directory); ok {
-				currentDir = dir
-			} else {
-				return nil, fmt.Errorf("path component '%s' exists but is a file", component)
-			}
-		} else {
-			// Create a new directory
+		if child == nil {
+			// Create a new directory, unless another node took its name first
 			newDir := NewDirectory(component)
-			currentDir.Add(newDir)
-			currentDir = newDir
+			existing, err := m.create("mkdir", path, currentDir, newDir)
+			if err != nil {
+				return nil, err
+			}
+			if child = existing; child == nil {
+				child = newDir
+			}
+		}
+		
+		// If it's a directory, move to it
+		dir, ok := child.(*Directory)
+		if !ok {
+			return nil, fmt.Errorf("path component '%s' exists but is a file", component)
 		}
+		currentDir = dir
 	}
 	
 	return currentDir, nil
@@ -144,16 +189,14 @@ func (m *FileSystemManager) FindNode(path string) (FileSystemNode, error) {
 	}
 	
 	// Find the node starting from the root
-	node := m.root.FindByPath(path)
-	if node == nil {
-		return nil, fmt.Errorf("node not found: %s", path)
-	}
-	
-	return node, nil
+	return m.resolve("stat", path)
 }
 
 // DeleteNode deletes a node by its path.
 func (m *FileSystemManager) DeleteNode(path string) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	
 	// Cannot delete root
 	if path == "/" || path == "" {
 		return errors.New("cannot delete root directory")
@@ -181,6 +224,11 @@ func (m *FileSystemManager) DeleteNode(path string) error {
 		return fmt.Errorf("parent of '%s' is not a directory", path)
 	}
 	
+	// Removing a node needs write permission on its parent
+	if err := checkAccess("remove", path, parentDir, Write|Execute, &m.user); err != nil {
+		return err
+	}
+	
 	// Remove the node from its parent
 	if !parentDir.Remove(node) {
 		return fmt.Errorf("failed to remove node '%s'", path)
@@ -191,6 +239,9 @@ func (m *FileSystemManager) DeleteNode(path string) error {
 
 // MoveNode moves a node from one path to another.
 func (m *FileSystemManager) MoveNode(sourcePath, destPath string) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	
 	// Cannot move root
 	if sourcePath == "/" || sourcePath == "" {
 		return errors.New("cannot move root directory")
@@ -206,16 +257,11 @@ func (m *FileSystemManager) MoveNode(sourcePath, destPath string) error {
 	destDir, destName := filepath.Split(destPath)
 	
 	// Create parent directories if needed
-	destParent, err := m.CreateDirectoryPath(destDir)
+	destParent, err := m.createDirectoryPath(destDir)
 	if err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 	
-	// Check if a node with the destination name already exists
-	if existingNode := destParent.GetChild(destName); existingNode != nil {
-		return fmt.Errorf("a node named '%s' already exists at destination", destName)
-	}
-	
 	// Get the source parent directory
 	sourceParentPath := filepath.Dir(sourcePath)
 	if sourceParentPath == "." {
@@ -232,40 +278,95 @@ func (m *FileSystemManager) MoveNode(sourcePath, destPath string) error {
 		return fmt.Errorf("parent of '%s' is not a directory", sourcePath)
 	}
 	
+	// Moving needs write permission on both parents, and room in the
+	// destination for the node if it leaves a directory with a quota
+	if err := checkAccess("rename", sourcePath, sourceParent, Write|Execute, &m.user); err != nil {
+		return err
+	}
+	if err := checkAccess("rename", destPath, destParent, Write|Execute, &m.user); err != nil {
+		return err
+	}
+	defer lockQuotas(sourceParent, destParent)()
+	bytes, inodes := usage(sourceNode)
+	if err := checkQuota(destParent, bytes, inodes, sourceNode); err != nil {
+		return err
+	}
+	
+	// Lock both parents so the node leaves one and joins the other at once,
+	// and check that the move still makes sense with them locked
+	unlock := lockMove(sourceParent, destParent, sourceNode)
+	
+	// The source or destination may have been deleted meanwhile
+	if commonAncestor(sourceParent, destParent) == nil {
+		unlock()
+		return fmt.Errorf("%w: %s", ErrNotFound, sourcePath)
+	}
+	
+	// A directory cannot be moved inside itself
+	if dir, ok := sourceNode.(*Directory); ok && isWithin(destParent, dir) {
+		unlock()
+		return fmt.Errorf("cannot move '%s' inside itself", sourcePath)
+	}
+	
+	// Check if a node with the destination name already exists
+	if existingNode := destParent.child(destName); existingNode != nil {
+		unlock()
+		return fmt.Errorf("a node named '%s' already exists at destination", destName)
+	}
+	
 	// Remove the node from its current parent
-	if !sourceParent.Remove(sourceNode) {
+	if !sourceParent.remove(sourceNode) {
+		unlock()
 		return fmt.Errorf("failed to remove node from source '%s'", sourcePath)
 	}
 	
-	// Rename the node if necessary
-	if sourceNode.Name() != destName {
-		switch node := sourceNode.(type) {
-		case *File:
-			node.name = destName
-		case *Directory:
-			node.name = destName
-		}
+	// Rename the node if necessary; lockMove holds its lock
+	node := nodeBase(sourceNode)
+	renamed := node != nil && node.name != destName
+	if renamed {
+		node.name = destName
 	}
 	
 	// Add the node to its new parent
-	destParent.Add(sourceNode)
+	destParent.add(sourceNode)
+	if node != nil {
+		destParent.treeIndex().nodeMoved(sourceParent, destParent, node)
+	}
+	unlock()
+	
+	sourceParent.changed()
+	if renamed {
+		node.changed()
+	} else {
+		destParent.changed()
+	}
 	
 	return nil
 }
 
-// CopyNode copies a node from one path to another.
+// CopyNode copies a node from one path to another. The copy keeps the
+// permissions and modification times of the original and is owned by the
+// manager's user, who needs read permission on everything copied.
 func (m *FileSystemManager) CopyNode(sourcePath, destPath string) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	
 	// Get the source node
 	sourceNode, err := m.FindNode(sourcePath)
 	if err != nil {
 		return err
 	}
 	
+	// Check the whole source can be read
+	if err := m.checkReadable(sourcePath, sourceNode); err != nil {
+		return err
+	}
+	
 	// Get the destination directory
 	destDir, destName := filepath.Split(destPath)
 	
 	// Create parent directories if needed
-	destParent, err := m.CreateDirectoryPath(destDir)
+	destParent, err := m.createDirectoryPath(destDir)
 	if err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
@@ -275,43 +376,13 @@ func (m *FileSystemManager) CopyNode(sourcePath, destPath string) error {
 		return fmt.Errorf("a node named '%s' already exists at destination", destName)
 	}
 	
-	// Create a copy of the node based on its type
-	var newNode FileSystemNode
-	
-	switch node := sourceNode.(type) {
-	case *File:
-		// Copy file content
-		contentCopy := make([]byte, len(node.content))
-		copy(contentCopy, node.content)
-		
-		newFile := NewFile(destName, contentCopy)
-		newFile.SetPermissions(node.GetPermissions())
-		newNode = newFile
-		
-	case *Directory:
-		// Create new directory
-		newDir := NewDirectory(destName)
-		newDir.SetPermissions(node.GetPermissions())
-		newNode = newDir
-		
-		// Recursively copy children
-		for _, child := range node.Children() {
-			childSourcePath := filepath.Join(sourcePath, child.Name())
-			childDestPath := filepath.Join(destPath, child.Name())
-			
-			if err := m.CopyNode(childSourcePath, childDestPath); err != nil {
-				return fmt.Errorf("failed to copy child '%s': %w", child.Name(), err)
-			}
-		}
-		
-	default:
-		return fmt.Errorf("unknown node type")
+	// Copy the node with its children and add it in one step, so that a
+	// quota is checked against the whole copy
+	existing, err := m.create("copy", destPath, destParent, copyTree(sourceNode, destName))
+	if err == nil && existing != nil {
+		err = fmt.Errorf("a node named '%s' already exists at destination", destName)
 	}
-	
-	// Add the new node to the destination parent
-	destParent.Add(newNode)
-	
-	return nil
+	return err
 }
 
 // ApplyVisitor applies a visitor to a node at the specified path.
@@ -339,6 +410,7 @@ func (m *FileSystemManager) CalculateStatistics() (*StatisticsVisitor, error) {
 }
 
 // Search searches for nodes matching criteria and returns results.
+// It visits every node; Find uses an index and scales to large trees.
 func (m *FileSystemManager) Search(name, content string, bySize bool, minSize, maxSize int64, maxResults int) ([]FileSystemNode, error) {
 	visitor := NewSearchVisitor(name, content, bySize, minSize, maxSize, maxResults)
 	err := m.ApplyVisitor("/", visitor)
@@ -352,79 +424,35 @@ func (m *FileSystemManager) Search(name, content string, bySize bool, minSize, m
 }
 
 // UpdatePermissions updates permissions on nodes matching criteria.
+// Nodes the manager's user does not own are left unchanged, and reported
+// with a permission error once the other nodes have been updated.
 func (m *FileSystemManager) UpdatePermissions(path string, add, remove Permission, affectFiles, affectDirs, recursive bool, extension string) (int, error) {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+	
 	visitor := NewPermissionUpdaterVisitor(add, remove, affectFiles, affectDirs, recursive, extension)
+	visitor.User = &m.user
 	
 	node, err := m.FindNode(path)
 	if err != nil {
 		return 0, err
 	}
 	
-	// Apply visitor only to the directory itself if it's not recursive
-	if !recursive {
-		switch n := node.(type) {
-		case *Directory:
-			if affectDirs {
-				currentPerms := n.GetPermissions()
-				newPerms := (currentPerms | add) &^ remove
-				
-				if currentPerms != newPerms {
-					n.SetPermissions(newPerms)
-					visitor.Modified++
-				}
-			}
-			
-			// Process immediate children only
-			for _, child := range n.Children() {
-				switch c := child.(type) {
-				case *File:
-					if affectFiles {
-						if extension == "" || strings.HasSuffix(c.Name(), extension) {
-							currentPerms := c.GetPermissions()
-							newPerms := (currentPerms | add) &^ remove
-							
-							if currentPerms != newPerms {
-								c.SetPermissions(newPerms)
-								visitor.Modified++
-							}
-						}
-					}
-				case *Directory:
-					if affectDirs {
-						currentPerms := c.GetPermissions()
-						newPerms := (currentPerms | add) &^ remove
-						
-						if currentPerms != newPerms {
-							c.SetPermissions(newPerms)
-							visitor.Modified++
-						}
-					}
-				}
-			}
-		case *File:
-			if affectFiles {
-				if extension == "" || strings.HasSuffix(n.Name(), extension) {
-					currentPerms := n.GetPermissions()
-					newPerms := (currentPerms | add) &^ remove
-					
-					if currentPerms != newPerms {
-						n.SetPermissions(newPerms)
-						visitor.Modified++
-					}
-				}
-			}
+	if recursive {
+		err = node.Accept(visitor)
+	} else {
+		// Update only the node itself and its immediate children
+		nodes := []FileSystemNode{node}
+		if dir, ok := node.(*Directory); ok {
+			nodes = append(nodes, dir.Children()...)
+		}
+		for _, n := range nodes {
+			visitor.update(n)
 		}
-		
-		return visitor.Modified, nil
 	}
 	
-	// Apply visitor recursively
-	err = node.Accept(visitor)
-	
-	// If the error is "not recursive", it's not a real error because
-	// we're handling non-recursive mode ourselves
-	if err != nil && err.Error() == "not recursive" {
-		err = nil
+	if err == nil && len(visitor.Denied) > 0 {
+		err = &fs.PathError{Op: "chmod", Path: visitor.Denied[0].Path(), Err: fs.ErrPermission}
 	}
 	
 	return visitor.Modified, err
@@ -0,0 +1,731 @@
+package composite
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Query selects nodes for FileSystemManager.Find and FindPage. Every
+// criterion that is set must match
+type Query struct {
+	// Glob matches paths. A glob without a slash matches base names
+	// anywhere, such as "*.go". A glob with a slash matches paths from the
+	// root, such as "/docs/**/*.md", where "**" matches any number of
+	// directories and the other elements follow path.Match
+	Glob string
+
+	// Name matches base names
+	Name *regexp.Regexp
+
+	// Text lists words that must all appear in a file's content. Words are
+	// matched whole and without regard to case
+	Text string
+
+	MinSize        int64     // 0 means no limit
+	MaxSize        int64     // 0 means no limit
+	ModifiedAfter  time.Time // The zero time means no limit
+	ModifiedBefore time.Time // The zero time means no limit
+}
+
+// Result is a node found by FileSystemManager.Find
+type Result struct {
+	Path    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	Score   float64 // Relevance to the query's Text, or 0 without Text
+}
+
+// Page is a page of results from FileSystemManager.FindPage
+type Page struct {
+	Results []Result
+	Next    string // Cursor of the next page, or empty on the last page
+}
+
+// ErrInvalidCursor is returned by FindPage for a cursor it did not return
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Find calls fn with each node matching q until fn returns false. Nodes
+// the manager's user cannot reach are left out, and so are files the user
+// cannot read when q has Text. Results come in no particular order; use
+// FindPage for ranked or sorted results.
+//
+// Find reads an index of the tree rather than visiting every node, and
+// stops reading as soon as fn returns false. Every change to the tree
+// updates the index as it is made, so searches never wait for it to catch
+// up. fn runs while the index is locked against changes, so it must not
+// change the tree or use its nodes; act on the results once Find returns
+func (m *FileSystemManager) Find(q Query, fn func(Result) bool) error {
+	glob, words, err := compileQuery(q)
+	if err != nil {
+		return err
+	}
+	ix := m.tree.index
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	ix.search(glob, q, words, &m.user, fn)
+	return nil
+}
+
+// FindPage returns up to limit results of q that follow cursor, which is
+// empty for the first page. Results are ranked by relevance when q has
+// Text, and sorted by path otherwise. Changes between pages do not cause
+// results to be repeated or skipped, apart from the changed nodes
+// themselves
+func (m *FileSystemManager) FindPage(q Query, cursor string, limit int) (Page, error) {
+	if limit <= 0 {
+		return Page{}, fmt.Errorf("page limit must be positive, got %d", limit)
+	}
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	glob, words, err := compileQuery(q)
+	if err != nil {
+		return Page{}, err
+	}
+
+	var results []Result
+	ix := m.tree.index
+	ix.mu.RLock()
+	ix.search(glob, q, words, &m.user, func(r Result) bool {
+		results = append(results, r)
+		return true
+	})
+	ix.mu.RUnlock()
+	sort.Slice(results, func(i, j int) bool {
+		return resultBefore(results[i], results[j].Score, results[j].Path)
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(results), func(i int) bool {
+			return resultBefore(*after, results[i].Score, results[i].Path)
+		})
+	}
+	page := Page{Results: results[start:]}
+	if len(page.Results) > limit {
+		page.Results = page.Results[:limit]
+		page.Next = encodeCursor(page.Results[limit-1])
+	}
+	return page, nil
+}
+
+// compileQuery returns the compiled glob and the words of q
+func compileQuery(q Query) (*globPattern, []string, error) {
+	glob, err := compileGlob(q.Glob)
+	if err != nil {
+		return nil, nil, err
+	}
+	words := tokenize(q.Text)
+	if q.Text != "" && len(words) == 0 {
+		return nil, nil, fmt.Errorf("search text %q has no words", q.Text)
+	}
+	return glob, words, nil
+}
+
+// search calls fn with each result of q that user may see, until fn
+// returns false. The index's lock must be held
+func (ix *searchIndex) search(glob *globPattern, q Query, words []string, user *User, fn func(Result) bool) {
+	visible := map[*indexDoc]bool{}
+	ix.candidates(glob, q.Name, words, func(doc *indexDoc) bool {
+		if doc.parent == nil || !ix.matches(doc, glob, q, words) {
+			return true
+		}
+		if !ix.reachable(doc.parent, user, visible) {
+			return true
+		}
+		if len(words) > 0 && !user.allowed(doc.owner, doc.group, doc.permissions, Read) {
+			return true
+		}
+		return fn(Result{
+			Path:    doc.path(),
+			IsDir:   doc.dir(),
+			Size:    doc.size,
+			ModTime: doc.modTime,
+			Score:   ix.score(doc, words),
+		})
+	})
+}
+
+// resultBefore reports whether r comes before the result with the given
+// score and path: higher scores come first, then paths in order
+func resultBefore(r Result, score float64, path string) bool {
+	if r.Score != score {
+		return r.Score > score
+	}
+	return r.Path < path
+}
+
+// encodeCursor returns a cursor for the results following r
+func encodeCursor(r Result) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatFloat(r.Score, 'g', -1, 64) + "\n" + r.Path))
+}
+
+// decodeCursor returns the result a cursor follows, or nil for an empty
+// cursor
+func decodeCursor(cursor string) (*Result, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	score, p, ok := strings.Cut(string(raw), "\n")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	r := Result{Path: p}
+	if r.Score, err = strconv.ParseFloat(score, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &r, nil
+}
+
+// searchIndex indexes the nodes of a tree by name, extension and content
+// words. Each change to the tree updates it while holding the locks of the
+// nodes it changes, so searches see every change whole. Documents are keyed
+// by node id and linked to their parents, so moving a directory only moves
+// its document
+type searchIndex struct {
+	mu     sync.RWMutex // Taken after the locks of any nodes
+	root   *indexDoc
+	docs   map[uint64]*indexDoc
+	names  map[string]map[uint64]struct{}
+	exts   map[string]map[uint64]struct{}
+	terms  map[string]map[uint64]int // Occurrences of each word in each file
+	files  int
+	length int // Total number of words in files
+}
+
+// indexDoc is an indexed node
+type indexDoc struct {
+	id          uint64
+	parent      *indexDoc            // nil for the root
+	children    map[uint64]*indexDoc // nil for files
+	name        string
+	size        int64 // For a directory, the total size of the files inside it
+	permissions Permission
+	owner       string
+	group       string
+	modTime     time.Time
+	terms       map[string]int
+	length      int
+}
+
+// newSearchIndex returns an empty index
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		docs:  map[uint64]*indexDoc{},
+		names: map[string]map[uint64]struct{}{},
+		exts:  map[string]map[uint64]struct{}{},
+		terms: map[string]map[uint64]int{},
+	}
+}
+
+// treeIndex returns the index of the tree the node is in, or nil if the
+// tree has none
+func (b *baseNode) treeIndex() *searchIndex {
+	for n := b; ; {
+		parent := n.parent.Load()
+		if parent == nil {
+			return n.index
+		}
+		n = &parent.baseNode
+	}
+}
+
+// reset makes the index describe the snapshot root from scratch
+func (ix *searchIndex) reset(root *snapshotNode) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	clear(ix.docs)
+	clear(ix.names)
+	clear(ix.exts)
+	clear(ix.terms)
+	ix.files, ix.length = 0, 0
+	ix.root = ix.build(nil, root)
+}
+
+// nodeAdded indexes s, a snapshot of the node just added to dir, whose lock
+// the caller holds. The methods of a nil index, for a node outside a
+// managed tree, do nothing
+func (ix *searchIndex) nodeAdded(dir *Directory, s *snapshotNode) {
+	if ix == nil {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	parent := ix.docs[dir.id]
+	if parent == nil {
+		// The directory left the tree meanwhile
+		return
+	}
+	parent.setAttrs(&dir.baseNode)
+	if doc := ix.docs[s.id]; doc != nil {
+		ix.detach(doc)
+		ix.drop(doc)
+	}
+	doc := ix.build(parent, s)
+	grow(parent, doc.size)
+}
+
+// nodeRemoved drops the node with the given id, just removed from dir, and
+// the nodes inside it. The caller holds the lock of dir
+func (ix *searchIndex) nodeRemoved(dir *Directory, id uint64) {
+	if ix == nil {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	parent := ix.docs[dir.id]
+	doc := ix.docs[id]
+	if parent == nil || doc == nil || doc.parent != parent {
+		return
+	}
+	parent.setAttrs(&dir.baseNode)
+	ix.detach(doc)
+	ix.drop(doc)
+}
+
+// nodeMoved records that node moved from source to dest, and may have been
+// renamed. The caller holds the locks of all three
+func (ix *searchIndex) nodeMoved(source, dest *Directory, node *baseNode) {
+	if ix == nil {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for _, dir := range []*Directory{source, dest} {
+		if doc := ix.docs[dir.id]; doc != nil {
+			doc.setAttrs(&dir.baseNode)
+		}
+	}
+	doc := ix.docs[node.id]
+	if doc == nil {
+		return
+	}
+	ix.detach(doc)
+	parent := ix.docs[dest.id]
+	if parent == nil {
+		// The destination left the tree meanwhile
+		ix.drop(doc)
+		return
+	}
+	if doc.name != node.name {
+		ix.indexName(doc, false)
+		doc.name = node.name
+		ix.indexName(doc, true)
+	}
+	doc.parent = parent
+	parent.children[doc.id] = doc
+	grow(parent, doc.size)
+}
+
+// contentChanged reindexes the content of f, whose lock the caller holds
+func (ix *searchIndex) contentChanged(f *File) {
+	if ix == nil {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if doc := ix.docs[f.id]; doc != nil {
+		doc.setAttrs(&f.baseNode)
+		ix.indexContent(doc, f.content)
+		grow(doc, int64(len(f.content))-doc.size)
+	}
+}
+
+// attrsChanged records the permissions, owners and modification time of b,
+// whose lock the caller holds
+func (ix *searchIndex) attrsChanged(b *baseNode) {
+	if ix == nil {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if doc := ix.docs[b.id]; doc != nil {
+		doc.setAttrs(b)
+	}
+}
+
+// build indexes s and the nodes inside it under parent, and returns its
+// document
+func (ix *searchIndex) build(parent *indexDoc, s *snapshotNode) *indexDoc {
+	doc := &indexDoc{
+		id:          s.id,
+		parent:      parent,
+		name:        s.name,
+		size:        s.size,
+		permissions: s.permissions,
+		owner:       s.owner,
+		group:       s.group,
+		modTime:     s.modTime,
+	}
+	ix.docs[doc.id] = doc
+	if parent != nil {
+		parent.children[doc.id] = doc
+	}
+	ix.indexName(doc, true)
+	if !s.dir {
+		ix.files++
+		ix.indexContent(doc, s.content)
+		return doc
+	}
+	doc.children = make(map[uint64]*indexDoc, len(s.children))
+	for _, child := range s.children {
+		ix.build(doc, child)
+	}
+	return doc
+}
+
+// detach unlinks doc from its parent
+func (ix *searchIndex) detach(doc *indexDoc) {
+	if doc.parent == nil {
+		return
+	}
+	delete(doc.parent.children, doc.id)
+	grow(doc.parent, -doc.size)
+	doc.parent = nil
+}
+
+// drop removes doc and the documents inside it from the index
+func (ix *searchIndex) drop(doc *indexDoc) {
+	delete(ix.docs, doc.id)
+	ix.indexName(doc, false)
+	if !doc.dir() {
+		ix.indexContent(doc, nil)
+		ix.files--
+		return
+	}
+	for _, child := range doc.children {
+		ix.drop(child)
+	}
+}
+
+// grow adds delta to the size of doc and the directories above it
+func grow(doc *indexDoc, delta int64) {
+	for d := doc; d != nil; d = d.parent {
+		d.size += delta
+	}
+}
+
+// setAttrs copies the permissions, owners and modification time of b
+func (d *indexDoc) setAttrs(b *baseNode) {
+	d.permissions, d.owner, d.group, d.modTime = b.permissions, b.owner, b.group, b.modTime
+}
+
+// dir reports whether the document is a directory
+func (d *indexDoc) dir() bool {
+	return d.children != nil
+}
+
+// path returns the path of the document
+func (d *indexDoc) path() string {
+	if d.parent == nil {
+		return "/"
+	}
+	var parts []string
+	for n := d; n.parent != nil; n = n.parent {
+		parts = append(parts, n.name)
+	}
+	slices.Reverse(parts)
+	return "/" + strings.Join(parts, "/")
+}
+
+// child returns the child of a directory document with the given name
+func (d *indexDoc) child(name string) *indexDoc {
+	for _, child := range d.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// indexName adds doc to or removes it from the name and extension indexes
+func (ix *searchIndex) indexName(doc *indexDoc, add bool) {
+	if add {
+		addPosting(ix.names, doc.name, doc.id)
+		addPosting(ix.exts, path.Ext(doc.name), doc.id)
+	} else {
+		removePosting(ix.names, doc.name, doc.id)
+		removePosting(ix.exts, path.Ext(doc.name), doc.id)
+	}
+}
+
+// indexContent replaces the words of doc with those of content
+func (ix *searchIndex) indexContent(doc *indexDoc, content []byte) {
+	for term := range doc.terms {
+		postings := ix.terms[term]
+		delete(postings, doc.id)
+		if len(postings) == 0 {
+			delete(ix.terms, term)
+		}
+	}
+	ix.length -= doc.length
+
+	words := tokenize(string(content))
+	doc.terms = make(map[string]int, len(words))
+	for _, word := range words {
+		doc.terms[word]++
+	}
+	for term, count := range doc.terms {
+		postings := ix.terms[term]
+		if postings == nil {
+			postings = map[uint64]int{}
+			ix.terms[term] = postings
+		}
+		postings[doc.id] = count
+	}
+	doc.length = len(words)
+	ix.length += doc.length
+}
+
+// addPosting adds id to the set under key
+func addPosting(index map[string]map[uint64]struct{}, key string, id uint64) {
+	ids := index[key]
+	if ids == nil {
+		ids = map[uint64]struct{}{}
+		index[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+// removePosting removes id from the set under key
+func removePosting(index map[string]map[uint64]struct{}, key string, id uint64) {
+	ids := index[key]
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(index, key)
+	}
+}
+
+// candidates calls yield with a superset of the documents matching a query,
+// read from the most selective index the query can use, until yield
+// returns false
+func (ix *searchIndex) candidates(glob *globPattern, name *regexp.Regexp, words []string, yield func(*indexDoc) bool) {
+	each := func(ids map[uint64]struct{}) bool {
+		for id := range ids {
+			if !yield(ix.docs[id]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case len(words) > 0:
+		// Start from the rarest word
+		rarest := ix.terms[words[0]]
+		for _, word := range words[1:] {
+			if postings := ix.terms[word]; len(postings) < len(rarest) {
+				rarest = postings
+			}
+		}
+		for id := range rarest {
+			if !yield(ix.docs[id]) {
+				return
+			}
+		}
+	case glob != nil && glob.name != "":
+		each(ix.names[glob.name])
+	case glob != nil && glob.ext != "":
+		each(ix.exts[glob.ext])
+	case name != nil:
+		for n, ids := range ix.names {
+			if name.MatchString(n) && !each(ids) {
+				return
+			}
+		}
+	case glob != nil && len(glob.prefix) > 0:
+		doc := ix.root
+		for _, part := range glob.prefix {
+			if doc = doc.child(part); doc == nil {
+				return
+			}
+		}
+		walk(doc, yield)
+	default:
+		for _, doc := range ix.docs {
+			if !yield(doc) {
+				return
+			}
+		}
+	}
+}
+
+// walk calls fn with doc and each document inside it until fn returns
+// false, and reports whether it never did
+func walk(doc *indexDoc, fn func(*indexDoc) bool) bool {
+	if !fn(doc) {
+		return false
+	}
+	for _, child := range doc.children {
+		if !walk(child, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether doc meets every criterion of q, whose glob and
+// words are given compiled
+func (ix *searchIndex) matches(doc *indexDoc, glob *globPattern, q Query, words []string) bool {
+	if q.Name != nil && !q.Name.MatchString(doc.name) {
+		return false
+	}
+	if (q.MinSize > 0 && doc.size < q.MinSize) || (q.MaxSize > 0 && doc.size > q.MaxSize) {
+		return false
+	}
+	if !q.ModifiedAfter.IsZero() && !doc.modTime.After(q.ModifiedAfter) {
+		return false
+	}
+	if !q.ModifiedBefore.IsZero() && !doc.modTime.Before(q.ModifiedBefore) {
+		return false
+	}
+	for _, word := range words {
+		if doc.terms[word] == 0 {
+			return false
+		}
+	}
+	if glob != nil {
+		// Base name globs only need the name
+		p := doc.name
+		if !glob.base {
+			p = doc.path()
+		}
+		return glob.match(p)
+	}
+	return true
+}
+
+// reachable reports whether user may look up the nodes in directory doc,
+// which needs execute permission on it and on each directory above it.
+// Answers are cached in visible
+func (ix *searchIndex) reachable(doc *indexDoc, user *User, visible map[*indexDoc]bool) bool {
+	if doc == nil {
+		return true
+	}
+	if ok, cached := visible[doc]; cached {
+		return ok
+	}
+	ok := user.allowed(doc.owner, doc.group, doc.permissions, Execute) && ix.reachable(doc.parent, user, visible)
+	visible[doc] = ok
+	return ok
+}
+
+// score ranks a file for the given words with BM25, which favours words
+// that are frequent in the file, rare in other files, and files that are
+// short
+func (ix *searchIndex) score(doc *indexDoc, words []string) float64 {
+	const k1, b = 1.2, 0.75
+	if len(words) == 0 || ix.files == 0 {
+		return 0
+	}
+	average := float64(ix.length) / float64(ix.files)
+	var score float64
+	for _, word := range words {
+		tf := float64(doc.terms[word])
+		df := float64(len(ix.terms[word]))
+		idf := math.Log(1 + (float64(ix.files)-df+0.5)/(df+0.5))
+		score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.length)/average))
+	}
+	return score
+}
+
+// tokenize splits text into lower case words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// globPattern is a compiled Query.Glob
+type globPattern struct {
+	parts  []string // Elements of the pattern, from the root
+	base   bool     // Whether the pattern matches base names
+	prefix []string // Leading elements without wildcards
+	name   string   // The base name every match has, if known
+	ext    string   // The extension every match has, if known
+}
+
+// compileGlob compiles a glob, returning nil for an empty one
+func compileGlob(pattern string) (*globPattern, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	g := &globPattern{base: !strings.Contains(pattern, "/")}
+	for _, part := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if _, err := path.Match(part, ""); err != nil {
+			return nil, fmt.Errorf("glob %q: %w", pattern, err)
+		}
+		g.parts = append(g.parts, part)
+	}
+	for _, part := range g.parts {
+		if hasMeta(part) {
+			break
+		}
+		g.prefix = append(g.prefix, part)
+	}
+	last := g.parts[len(g.parts)-1]
+	switch ext := path.Ext(last); {
+	case !hasMeta(last):
+		g.name = last
+	case strings.HasPrefix(last, "*") && last == "*"+ext && !hasMeta(ext):
+		g.ext = ext
+	}
+	if g.base {
+		g.prefix = nil
+	}
+	return g, nil
+}
+
+// hasMeta reports whether a glob element has wildcards
+func hasMeta(part string) bool {
+	return strings.ContainsAny(part, `*?[\`)
+}
+
+// match reports whether the glob matches the absolute path p
+func (g *globPattern) match(p string) bool {
+	if g.base {
+		ok, _ := path.Match(g.parts[0], path.Base(p))
+		return ok
+	}
+	return matchParts(g.parts, strings.Split(strings.Trim(p, "/"), "/"))
+}
+
+// matchParts matches path elements against glob elements, where "**"
+// matches any number of path elements
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,461 @@
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// findPaths returns the paths of the results of q, ranked by score and
+// then sorted by path like the results of FindPage
+func findPaths(t testing.TB, fsm *FileSystemManager, q Query) []string {
+	t.Helper()
+	var results []Result
+	err := fsm.Find(q, func(r Result) bool {
+		results = append(results, r)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return resultBefore(results[i], results[j].Score, results[j].Path)
+	})
+	paths := make([]string, len(results))
+	for i, r := range results {
+		paths[i] = r.Path
+	}
+	return paths
+}
+
+// expectPaths fails the test unless q finds the expected paths in order
+func expectPaths(t *testing.T, fsm *FileSystemManager, q Query, expected ...string) {
+	t.Helper()
+	if got := findPaths(t, fsm, q); strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("%+v: expected %v, got %v", q, expected, got)
+	}
+}
+
+// TestFindGlob tests matching paths with globs
+func TestFindGlob(t *testing.T) {
+	fsm := newTestTree(t)
+
+	cases := []struct {
+		glob     string
+		expected []string
+	}{
+		{"*.md", []string{"/docs/guide.md", "/docs/readme.md"}},
+		{"readme.md", []string{"/docs/readme.md"}},
+		{"/docs/**/*.go", []string{"/docs/examples/example1.go"}},
+		{"docs/*", []string{"/docs/examples", "/docs/guide.md", "/docs/readme.md"}},
+		{"/docs/**", []string{"/docs", "/docs/examples", "/docs/examples/example1.go", "/docs/guide.md", "/docs/readme.md"}},
+		{"/**/examples", []string{"/docs/examples"}},
+		{"/**/*.tmpl", []string{"/templates/footer.tmpl", "/templates/page.tmpl"}},
+		{"/t*/p?ge.*", []string{"/templates/page.tmpl"}},
+		{"/*", []string{"/docs", "/empty", "/index.html", "/templates"}},
+		{"/missing/**", nil},
+	}
+	for _, c := range cases {
+		expectPaths(t, fsm, Query{Glob: c.glob}, c.expected...)
+	}
+
+	err := fsm.Find(Query{Glob: "/docs/[a-"}, func(Result) bool { return true })
+	if err == nil || !strings.Contains(err.Error(), "syntax error in pattern") {
+		t.Errorf("Expected a bad pattern error, got %v", err)
+	}
+}
+
+// TestFindNameAndFilters tests regular expressions on names and size and
+// time filters
+func TestFindNameAndFilters(t *testing.T) {
+	fsm := newTestTree(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, p := range []string{"/index.html", "/docs/readme.md", "/docs/guide.md", "/docs/examples/example1.go"} {
+		node, _ := fsm.FindNode(p)
+		nodeBase(node).setModTime(base.Add(time.Duration(i) * 24 * time.Hour))
+	}
+
+	expectPaths(t, fsm, Query{Name: regexp.MustCompile(`^(page|footer)\.`)}, "/templates/footer.tmpl", "/templates/page.tmpl")
+	expectPaths(t, fsm, Query{Name: regexp.MustCompile(`^e`)}, "/docs/examples", "/docs/examples/example1.go", "/empty")
+	expectPaths(t, fsm, Query{Glob: "*.md", MinSize: 8}, "/docs/readme.md")
+	expectPaths(t, fsm, Query{Glob: "/docs/**", MaxSize: 8, MinSize: 8}, "/docs/readme.md")
+	expectPaths(t, fsm, Query{
+		Glob:           "/**/*.*",
+		ModifiedAfter:  base,
+		ModifiedBefore: base.Add(72 * time.Hour),
+	}, "/docs/guide.md", "/docs/readme.md")
+	expectPaths(t, fsm, Query{
+		Name:          regexp.MustCompile(`\.(go|html)$`),
+		ModifiedAfter: base.Add(-time.Hour),
+		MaxSize:       12,
+	}, "/docs/examples/example1.go")
+}
+
+// TestFindText tests full-text search and ranking
+func TestFindText(t *testing.T) {
+	fsm := NewFileSystemManager()
+	files := map[string]string{
+		"/composite.md": "The Composite pattern composes objects into trees. Composite objects and leaves are treated alike.",
+		"/decorator.md": "The Decorator pattern wraps objects to add behaviour.",
+		"/patterns.md":  "Patterns: composite, decorator, facade, flyweight and proxy.",
+		"/recipe.txt":   "Mix the flour and the eggs.",
+	}
+	for p, content := range files {
+		fsm.CreateFile(p, []byte(content))
+	}
+
+	// Results are ranked, and words are matched whole and without case
+	expectPaths(t, fsm, Query{Text: "composite"}, "/composite.md", "/patterns.md")
+	expectPaths(t, fsm, Query{Text: "PATTERN objects"}, "/decorator.md", "/composite.md")
+	expectPaths(t, fsm, Query{Text: "pattern"}, "/decorator.md", "/composite.md")
+	expectPaths(t, fsm, Query{Text: "patterns"}, "/patterns.md")
+	expectPaths(t, fsm, Query{Text: "the", Glob: "*.md"}, "/decorator.md", "/composite.md")
+	expectPaths(t, fsm, Query{Text: "missing"})
+
+	var scores []float64
+	page, _ := fsm.FindPage(Query{Text: "composite"}, "", 10)
+	for _, r := range page.Results {
+		scores = append(scores, r.Score)
+	}
+	if len(scores) != 2 || scores[0] <= scores[1] || scores[1] <= 0 {
+		t.Errorf("Expected decreasing positive scores, got %v", scores)
+	}
+
+	if err := fsm.Find(Query{Text: "?!"}, func(Result) bool { return true }); err == nil {
+		t.Error("Expected an error for text without words")
+	}
+}
+
+// TestFindStreaming tests stopping a search early
+func TestFindStreaming(t *testing.T) {
+	fsm := newTestTree(t)
+	var calls int
+	fsm.Find(Query{}, func(Result) bool {
+		calls++
+		return calls < 3
+	})
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+// TestFindPage tests paginating results
+func TestFindPage(t *testing.T) {
+	fsm := NewFileSystemManager()
+	for i := 0; i < 25; i++ {
+		fsm.CreateFile(fmt.Sprintf("/logs/%02d.log", i), []byte(strings.Repeat("error ", i+1)))
+	}
+
+	for _, q := range []Query{{Glob: "*.log"}, {Text: "error"}} {
+		var paged []string
+		cursor := ""
+		pages := 0
+		for {
+			page, err := fsm.FindPage(q, cursor, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages++
+			for _, r := range page.Results {
+				paged = append(paged, r.Path)
+			}
+			if page.Next == "" {
+				break
+			}
+			cursor = page.Next
+		}
+		if pages != 3 {
+			t.Errorf("%+v: expected 3 pages, got %d", q, pages)
+		}
+		if all := findPaths(t, fsm, q); strings.Join(paged, " ") != strings.Join(all, " ") {
+			t.Errorf("%+v: expected pages to list %v, got %v", q, all, paged)
+		}
+	}
+
+	// Removing results already returned does not shift later pages
+	first, _ := fsm.FindPage(Query{Glob: "*.log"}, "", 10)
+	fsm.DeleteNode("/logs/00.log")
+	fsm.DeleteNode("/logs/05.log")
+	second, _ := fsm.FindPage(Query{Glob: "*.log"}, first.Next, 10)
+	if second.Results[0].Path != "/logs/10.log" {
+		t.Errorf("Expected the second page to start at /logs/10.log, got %s", second.Results[0].Path)
+	}
+
+	// An exactly full last page has no next cursor
+	page, _ := fsm.FindPage(Query{Glob: "/logs/1*.log"}, "", 10)
+	if len(page.Results) != 10 || page.Next != "" {
+		t.Errorf("Expected a single full page, got %d results and cursor %q", len(page.Results), page.Next)
+	}
+
+	if _, err := fsm.FindPage(Query{}, "not a cursor", 10); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := fsm.FindPage(Query{}, "", 0); err == nil {
+		t.Error("Expected an error for a zero limit")
+	}
+}
+
+// TestFindPermissions tests that results are limited to what the user can
+// reach and read
+func TestFindPermissions(t *testing.T) {
+	fsm := newHomes(t)
+	asAlice := fsm.As(alice)
+	asAlice.CreateFile("/home/alice/public.txt", []byte("shared secret"))
+	asAlice.CreateFile("/home/alice/private.txt", []byte("secret"))
+	asAlice.Chmod("/home/alice/private.txt", Read|Write)
+	fsm.As(mallory).CreateFile("/tmp/mallory.txt", []byte("no secret"))
+
+	expectPaths(t, asAlice, Query{Text: "secret"}, "/home/alice/private.txt", "/home/alice/public.txt", "/tmp/mallory.txt")
+	expectPaths(t, fsm.As(bob), Query{Glob: "*.txt"}, "/home/alice/private.txt", "/home/alice/public.txt", "/tmp/mallory.txt")
+	expectPaths(t, fsm.As(bob), Query{Text: "secret"}, "/home/alice/public.txt", "/tmp/mallory.txt")
+	expectPaths(t, fsm.As(mallory), Query{Glob: "*.txt"}, "/tmp/mallory.txt")
+}
+
+// indexState describes everything an index holds, for comparing an updated
+// index with one built from scratch
+func indexState(ix *searchIndex) string {
+	var lines []string
+	for id, doc := range ix.docs {
+		terms := make([]string, 0, len(doc.terms))
+		for term, count := range doc.terms {
+			terms = append(terms, fmt.Sprintf("%s=%d", term, count))
+		}
+		sort.Strings(terms)
+		parent := "-"
+		if doc.parent != nil {
+			parent = doc.parent.path()
+			if doc.parent.children[id] != doc || ix.docs[doc.parent.id] != doc.parent {
+				lines = append(lines, doc.path()+" not linked to its parent")
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s parent=%s name=%s dir=%v size=%d perm=%o owner=%s:%s mod=%s children=%d %v",
+			doc.path(), parent, doc.name, doc.dir(), doc.size, doc.permissions, doc.owner, doc.group,
+			doc.modTime.Format(time.RFC3339Nano), len(doc.children), terms))
+		if _, ok := ix.names[doc.name][id]; !ok {
+			lines = append(lines, doc.path()+" missing from names")
+		}
+		if _, ok := ix.exts[path.Ext(doc.name)][id]; !ok {
+			lines = append(lines, doc.path()+" missing from extensions")
+		}
+	}
+	for name, ids := range ix.names {
+		lines = append(lines, fmt.Sprintf("name %s: %d", name, len(ids)))
+	}
+	for ext, ids := range ix.exts {
+		lines = append(lines, fmt.Sprintf("ext %q: %d", ext, len(ids)))
+	}
+	for term, postings := range ix.terms {
+		lines = append(lines, fmt.Sprintf("term %s: %d", term, len(postings)))
+	}
+	lines = append(lines, fmt.Sprintf("files=%d length=%d", ix.files, ix.length))
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// checkIndex compares the manager's index with one built from scratch
+func checkIndex(t *testing.T, fsm *FileSystemManager, step string) {
+	t.Helper()
+	fresh := newSearchIndex()
+	fresh.reset(capture(fsm.root))
+	if got, expected := indexState(fsm.tree.index), indexState(fresh); got != expected {
+		t.Fatalf("%s: index out of date\nexpected:\n%s\ngot:\n%s", step, expected, got)
+	}
+}
+
+// TestIndexUpdates tests that the index follows changes to the tree
+func TestIndexUpdates(t *testing.T) {
+	fsm := newTestTree(t)
+	checkIndex(t, fsm, "initial")
+	snapshot := fsm.Snapshot()
+
+	steps := []struct {
+		name   string
+		change func() error
+	}{
+		{"create", func() error { _, err := fsm.CreateFile("/docs/new.md", []byte("new words")); return err }},
+		{"write", func() error { return fsm.WriteFile("/docs/guide.md", []byte("# Guide to new things")) }},
+		{"set content", func() error {
+			node, _ := fsm.FindNode("/index.html")
+			node.(*File).SetContent([]byte("<h1>Home sweet home</h1>"))
+			return nil
+		}},
+		{"rename", func() error { return fsm.MoveNode("/docs/readme.md", "/docs/README") }},
+		{"move a directory", func() error { return fsm.MoveNode("/docs", "/archive/2024/docs") }},
+		{"move back and edit", func() error {
+			if err := fsm.MoveNode("/archive/2024/docs", "/docs"); err != nil {
+				return err
+			}
+			return fsm.WriteFile("/docs/examples/example1.go", []byte("package example"))
+		}},
+		{"move out and delete", func() error {
+			if err := fsm.MoveNode("/templates/page.tmpl", "/empty/page.tmpl"); err != nil {
+				return err
+			}
+			return fsm.DeleteNode("/templates")
+		}},
+		{"copy", func() error { return fsm.CopyNode("/docs", "/docs2") }},
+		{"delete", func() error { return fsm.DeleteNode("/archive") }},
+		{"add directly", func() error {
+			dir := NewDirectory("direct")
+			dir.Add(NewFile("notes.txt", []byte("direct words")))
+			fsm.Root().Add(dir)
+			dir.Add(NewDirectory("later"))
+			return nil
+		}},
+		{"chmod", func() error { return fsm.Chmod("/direct/notes.txt", Read) }},
+		{"remove directly", func() error {
+			dir, _ := fsm.FindNode("/direct")
+			fsm.Root().Remove(dir)
+			return nil
+		}},
+		{"restore", func() error { return fsm.Restore(snapshot) }},
+	}
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		checkIndex(t, fsm, step.name)
+	}
+	expectPaths(t, fsm, Query{Text: "new"})
+	expectPaths(t, fsm, Query{Text: "readme"}, "/docs/readme.md")
+}
+
+// TestIndexRandomUpdates tests the index against random changes
+func TestIndexRandomUpdates(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	fsm := NewFileSystemManager()
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon"}
+	randomPath := func() string {
+		parts := []string{""}
+		for i := rng.Intn(3); i >= 0; i-- {
+			parts = append(parts, fmt.Sprintf("d%d", rng.Intn(3)))
+		}
+		return strings.Join(parts, "/") + fmt.Sprintf("/f%d.txt", rng.Intn(5))
+	}
+	randomNode := func() string {
+		paths := findPaths(t, fsm, Query{})
+		if len(paths) == 0 {
+			return "/missing"
+		}
+		return paths[rng.Intn(len(paths))]
+	}
+
+	for i := 0; i < 300; i++ {
+		// Several changes between checks
+		for j := rng.Intn(4); j >= 0; j-- {
+			switch rng.Intn(5) {
+			case 0, 1:
+				fsm.WriteFile(randomPath(), []byte(words[rng.Intn(5)]+" "+words[rng.Intn(5)]))
+			case 2:
+				fsm.MoveNode(randomNode(), randomPath())
+			case 3:
+				fsm.CopyNode(randomNode(), randomPath())
+			case 4:
+				fsm.DeleteNode(randomNode())
+			}
+		}
+		checkIndex(t, fsm, fmt.Sprintf("step %d", i))
+	}
+}
+
+// newLargeTree returns a tree of 1,000 directories holding 100 files each
+func newLargeTree(b *testing.B) *FileSystemManager {
+	b.Helper()
+	words := strings.Fields("composite decorator facade proxy adapter bridge flyweight visitor observer strategy")
+	fsm := NewFileSystemManager()
+	for i := 0; i < 1000; i++ {
+		dir := NewDirectory(fmt.Sprintf("dir%03d", i))
+		fsm.Root().Add(dir)
+		for j := 0; j < 99; j++ {
+			content := fmt.Sprintf("file %d of %d about %s and %s", j, i, words[j%10], words[(i+j)%10])
+			if i == 500 && j == 50 {
+				content += " needle"
+			}
+			name := fmt.Sprintf("file%02d.txt", j)
+			if j%10 == 0 {
+				name = fmt.Sprintf("file%02d.go", j)
+			}
+			dir.Add(NewFile(name, []byte(content)))
+		}
+	}
+	return fsm
+}
+
+// BenchmarkSearchVisitor finds files by name by visiting every node
+func BenchmarkSearchVisitor(b *testing.B) {
+	fsm := newLargeTree(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fsm.Search("file10.go", "", false, 0, 0, 0)
+	}
+}
+
+// BenchmarkSearchVisitorContent finds files by content by visiting every
+// node
+func BenchmarkSearchVisitorContent(b *testing.B) {
+	fsm := newLargeTree(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fsm.Search("", "needle", false, 0, 0, 0)
+	}
+}
+
+// benchmarkFind runs q against the large tree with an up to date index
+func benchmarkFind(b *testing.B, q Query, expected int) {
+	fsm := newLargeTree(b)
+	if got := len(findPaths(b, fsm, q)); got != expected {
+		b.Fatalf("Expected %d results, got %d", expected, got)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		findPaths(b, fsm, q)
+	}
+}
+
+func BenchmarkFindName(b *testing.B) {
+	benchmarkFind(b, Query{Glob: "file10.go"}, 1000)
+}
+
+func BenchmarkFindGlob(b *testing.B) {
+	benchmarkFind(b, Query{Glob: "/dir5*/**/*.go"}, 1000)
+}
+
+func BenchmarkFindRegexp(b *testing.B) {
+	benchmarkFind(b, Query{Name: regexp.MustCompile(`^file1[0-4]\.`)}, 5000)
+}
+
+func BenchmarkFindText(b *testing.B) {
+	benchmarkFind(b, Query{Text: "needle"}, 1)
+}
+
+func BenchmarkFindRankedText(b *testing.B) {
+	benchmarkFind(b, Query{Text: "composite proxy"}, 2000)
+}
+
+// BenchmarkFindAfterChange changes a file before each search, which also
+// updates the index
+func BenchmarkFindAfterChange(b *testing.B) {
+	fsm := newLargeTree(b)
+	findPaths(b, fsm, Query{Text: "needle"})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fsm.WriteFile("/dir123/file45.txt", []byte(fmt.Sprintf("version %d", i)))
+		findPaths(b, fsm, Query{Text: "needle"})
+	}
+}
+
+// BenchmarkIndexBuild indexes the large tree from scratch
+func BenchmarkIndexBuild(b *testing.B) {
+	fsm := newLargeTree(b)
+	root := capture(fsm.Root())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newSearchIndex().reset(root)
+	}
+}
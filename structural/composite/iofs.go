@@ -0,0 +1,389 @@
+package composite
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A *Directory and a FileSystemManager can be used wherever the standard
+// library takes an fs.FS, such as http.FS, fs.WalkDir or template.ParseFS
+var (
+	_ fs.ReadDirFS  = (*Directory)(nil)
+	_ fs.StatFS     = (*Directory)(nil)
+	_ fs.ReadFileFS = (*Directory)(nil)
+	_ fs.ReadDirFS  = (*FileSystemManager)(nil)
+	_ fs.StatFS     = (*FileSystemManager)(nil)
+	_ fs.ReadFileFS = (*FileSystemManager)(nil)
+)
+
+var (
+	errIsDir  = errors.New("is a directory")
+	errNotDir = errors.New("not a directory")
+)
+
+// permissionClasses are the shifts of the owner, group and other bits in a
+// Permission and in a fs.FileMode
+var permissionClasses = [...]struct{ perm, mode uint }{{0, 6}, {3, 3}, {6, 0}}
+
+// Mode returns the fs.FileMode permission bits of a permission set
+func (p Permission) Mode() fs.FileMode {
+	var mode fs.FileMode
+	for _, c := range permissionClasses {
+		bits := p >> c.perm
+		if bits&Read != 0 {
+			mode |= 04 << c.mode
+		}
+		if bits&Write != 0 {
+			mode |= 02 << c.mode
+		}
+		if bits&Execute != 0 {
+			mode |= 01 << c.mode
+		}
+	}
+	return mode
+}
+
+// PermissionFromMode returns the permission set of a file mode
+func PermissionFromMode(mode fs.FileMode) Permission {
+	var perm Permission
+	for _, c := range permissionClasses {
+		bits := mode >> c.mode
+		if bits&04 != 0 {
+			perm |= Read << c.perm
+		}
+		if bits&02 != 0 {
+			perm |= Write << c.perm
+		}
+		if bits&01 != 0 {
+			perm |= Execute << c.perm
+		}
+	}
+	return perm
+}
+
+// Open opens the named file or directory for reading. Names are slash
+// separated and relative to d, as described by fs.ValidPath. Permissions
+// are not checked
+func (d *Directory) Open(name string) (fs.File, error) {
+	return d.open(name, nil)
+}
+
+// Stat returns a fs.FileInfo describing the named file or directory
+func (d *Directory) Stat(name string) (fs.FileInfo, error) {
+	return d.stat(name, nil)
+}
+
+// ReadDir returns the entries of the named directory sorted by name
+func (d *Directory) ReadDir(name string) ([]fs.DirEntry, error) {
+	return d.readDir(name, nil)
+}
+
+// ReadFile returns a copy of the content of the named file
+func (d *Directory) ReadFile(name string) ([]byte, error) {
+	return d.readFile(name, nil)
+}
+
+// Open opens the named file or directory, relative to the root. The
+// manager's user needs read permission on it and execute permission on the
+// directories leading to it
+func (m *FileSystemManager) Open(name string) (fs.File, error) {
+	return m.root.open(name, &m.user)
+}
+
+// Stat returns a fs.FileInfo describing the named file or directory
+func (m *FileSystemManager) Stat(name string) (fs.FileInfo, error) {
+	return m.root.stat(name, &m.user)
+}
+
+// ReadDir returns the entries of the named directory sorted by name
+func (m *FileSystemManager) ReadDir(name string) ([]fs.DirEntry, error) {
+	return m.root.readDir(name, &m.user)
+}
+
+// ReadFile returns a copy of the content of the named file
+func (m *FileSystemManager) ReadFile(name string) ([]byte, error) {
+	return m.root.readFile(name, &m.user)
+}
+
+// open implements Open, checking permissions for user unless it is nil
+func (d *Directory) open(name string, user *User) (fs.File, error) {
+	node, err := d.lookup("open", name, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAccess("open", name, node, Read, user); err != nil {
+		return nil, err
+	}
+	info := newFileInfo(name, node)
+	if dir, ok := node.(*Directory); ok {
+		return &openDir{name: name, info: info, entries: dir.dirEntries()}, nil
+	}
+	return &openFile{name: name, info: info, reader: bytes.NewReader(node.(*File).Content())}, nil
+}
+
+// stat implements Stat, checking permissions for user unless it is nil
+func (d *Directory) stat(name string, user *User) (fs.FileInfo, error) {
+	node, err := d.lookup("stat", name, user)
+	if err != nil {
+		return nil, err
+	}
+	return newFileInfo(name, node), nil
+}
+
+// readDir implements ReadDir, checking permissions for user unless it is
+// nil
+func (d *Directory) readDir(name string, user *User) ([]fs.DirEntry, error) {
+	node, err := d.lookup("readdir", name, user)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := node.(*Directory)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	if err := checkAccess("readdir", name, node, Read, user); err != nil {
+		return nil, err
+	}
+	return dir.dirEntries(), nil
+}
+
+// readFile implements ReadFile, checking permissions for user unless it is
+// nil
+func (d *Directory) readFile(name string, user *User) ([]byte, error) {
+	node, err := d.lookup("read", name, user)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := node.(*File)
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	if err := checkAccess("read", name, node, Read, user); err != nil {
+		return nil, err
+	}
+	return file.Content(), nil
+}
+
+// lookup finds the node at a slash separated path relative to d, returning
+// an *fs.PathError for op if the path is invalid or does not exist. Unless
+// user is nil, it needs execute permission on each directory it passes
+// through
+func (d *Directory) lookup(op, name string, user *User) (FileSystemNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return d, nil
+	}
+
+	var node FileSystemNode = d
+	for _, part := range strings.Split(name, "/") {
+		dir, ok := node.(*Directory)
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if err := checkAccess(op, name, dir, Execute, user); err != nil {
+			return nil, err
+		}
+		if node = dir.GetChild(part); node == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	return node, nil
+}
+
+// dirEntries returns the children of d as directory entries sorted by name
+func (d *Directory) dirEntries() []fs.DirEntry {
+	children := d.Children()
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, newFileInfo(child.Name(), child))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+// fileInfo describes a node as both a fs.FileInfo and a fs.DirEntry
+type fileInfo struct {
+	name string
+	node FileSystemNode
+}
+
+// newFileInfo describes node, naming it after the last element of name
+func newFileInfo(name string, node FileSystemNode) *fileInfo {
+	return &fileInfo{name: path.Base(name), node: node}
+}
+
+// Name returns the base name of the node
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
+
+// Size returns the length of a file's content, or 0 for a directory
+func (fi *fileInfo) Size() int64 {
+	if fi.node.IsDirectory() {
+		return 0
+	}
+	return fi.node.Size()
+}
+
+// Mode returns the permission bits of the node, with fs.ModeDir set for a
+// directory
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.node.IsDirectory() {
+		return fs.ModeDir | fi.node.GetPermissions().Mode()
+	}
+	return fi.node.GetPermissions().Mode()
+}
+
+// ModTime returns when the node was last modified
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.node.ModTime()
+}
+
+// IsDir reports whether the node is a directory
+func (fi *fileInfo) IsDir() bool {
+	return fi.node.IsDirectory()
+}
+
+// Sys returns the underlying FileSystemNode
+func (fi *fileInfo) Sys() any {
+	return fi.node
+}
+
+// Type returns the type bits of the node
+func (fi *fileInfo) Type() fs.FileMode {
+	return fi.Mode().Type()
+}
+
+// Info returns the node's fs.FileInfo
+func (fi *fileInfo) Info() (fs.FileInfo, error) {
+	return fi, nil
+}
+
+// String formats the node with fs.FormatFileInfo
+func (fi *fileInfo) String() string {
+	return fs.FormatFileInfo(fi)
+}
+
+// openFile is an open file. It reads the content the file had when it was
+// opened
+type openFile struct {
+	name   string
+	info   *fileInfo
+	reader *bytes.Reader
+	closed bool
+}
+
+// Stat returns the file's fs.FileInfo
+func (f *openFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, f.error("stat", fs.ErrClosed)
+	}
+	return f.info, nil
+}
+
+// Read reads from the current offset
+func (f *openFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, f.error("read", fs.ErrClosed)
+	}
+	return f.reader.Read(p)
+}
+
+// ReadAt reads from the given offset
+func (f *openFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, f.error("read", fs.ErrClosed)
+	}
+	return f.reader.ReadAt(p, off)
+}
+
+// Seek sets the offset for the next Read
+func (f *openFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, f.error("seek", fs.ErrClosed)
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+// Close closes the file
+func (f *openFile) Close() error {
+	if f.closed {
+		return f.error("close", fs.ErrClosed)
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *openFile) error(op string, err error) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: err}
+}
+
+// openDir is an open directory. It lists the children the directory had
+// when it was opened
+type openDir struct {
+	name    string
+	info    *fileInfo
+	entries []fs.DirEntry
+	offset  int
+	closed  bool
+}
+
+// Stat returns the directory's fs.FileInfo
+func (d *openDir) Stat() (fs.FileInfo, error) {
+	if d.closed {
+		return nil, d.error("stat", fs.ErrClosed)
+	}
+	return d.info, nil
+}
+
+// Read fails because a directory has no content
+func (d *openDir) Read([]byte) (int, error) {
+	if d.closed {
+		return 0, d.error("read", fs.ErrClosed)
+	}
+	return 0, d.error("read", errIsDir)
+}
+
+// ReadDir returns the next n entries, or all remaining entries if n <= 0,
+// as described by fs.ReadDirFile
+func (d *openDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, d.error("readdir", fs.ErrClosed)
+	}
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
+
+// Close closes the directory
+func (d *openDir) Close() error {
+	if d.closed {
+		return d.error("close", fs.ErrClosed)
+	}
+	d.closed = true
+	return nil
+}
+
+func (d *openDir) error(op string, err error) error {
+	return &fs.PathError{Op: op, Path: d.name, Err: err}
+}
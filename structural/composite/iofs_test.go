@@ -0,0 +1,268 @@
+package composite
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+)
+
+// newTestTree returns a manager with files at several depths
+func newTestTree(t *testing.T) *FileSystemManager {
+	t.Helper()
+	fsm := NewFileSystemManager()
+	files := map[string]string{
+		"/index.html":                "<h1>Home</h1>",
+		"/docs/readme.md":            "# README",
+		"/docs/guide.md":             "# Guide",
+		"/docs/examples/example1.go": "package main",
+		"/templates/page.tmpl":       `{{define "page"}}<p>{{.}}</p>{{template "footer"}}{{end}}`,
+		"/templates/footer.tmpl":     `{{define "footer"}}<footer/>{{end}}`,
+	}
+	for path, content := range files {
+		if _, err := fsm.CreateFile(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := fsm.CreateDirectory("/empty"); err != nil {
+		t.Fatal(err)
+	}
+	return fsm
+}
+
+// TestFSConformance runs the standard library's fs.FS checks on a manager
+// and on a subdirectory
+func TestFSConformance(t *testing.T) {
+	fsm := newTestTree(t)
+
+	if err := fstest.TestFS(fsm, "index.html", "docs/readme.md", "docs/examples/example1.go", "empty", "templates/page.tmpl"); err != nil {
+		t.Error(err)
+	}
+
+	docs, err := fsm.FindNode("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(docs.(*Directory), "readme.md", "guide.md", "examples/example1.go"); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestFSWalkDir tests walking the tree with fs.WalkDir
+func TestFSWalkDir(t *testing.T) {
+	fsm := newTestTree(t)
+
+	var paths []string
+	err := fs.WalkDir(fsm, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "templates" {
+			return fs.SkipDir
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ". docs docs/examples docs/examples/example1.go docs/guide.md docs/readme.md empty index.html"
+	if got := strings.Join(paths, " "); got != expected {
+		t.Errorf("Expected walk %q, got %q", expected, got)
+	}
+}
+
+// TestFSModes tests that file modes follow node permissions
+func TestFSModes(t *testing.T) {
+	fsm := newTestTree(t)
+
+	cases := []struct {
+		path     string
+		perm     Permission
+		expected fs.FileMode
+	}{
+		{"index.html", DefaultFilePermissions, 0644},
+		{"docs", DefaultDirectoryPermissions, fs.ModeDir | 0755},
+		{"docs/readme.md", Read | GroupRead | OtherRead, 0444},
+		{"docs/guide.md", Read | Execute | GroupRead | OtherExecute, 0541},
+		{"empty", Write | GroupWrite, fs.ModeDir | 0220},
+		{"docs/examples/example1.go", 0, 0},
+	}
+	for _, c := range cases {
+		node, err := fsm.FindNode("/" + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.SetPermissions(c.perm)
+
+		info, err := fs.Stat(fsm, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != c.expected {
+			t.Errorf("%s: expected mode %v, got %v", c.path, c.expected, info.Mode())
+		}
+		if info.IsDir() != node.IsDirectory() {
+			t.Errorf("%s: expected IsDir %v", c.path, node.IsDirectory())
+		}
+		if info.Sys() != node {
+			t.Errorf("%s: expected Sys to return the node", c.path)
+		}
+	}
+
+	info, err := fsm.Stat(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "." || !info.IsDir() || info.Size() != 0 {
+		t.Errorf("Unexpected root info: %v", info)
+	}
+}
+
+// TestFSModTime tests that writing a file updates its modification time
+func TestFSModTime(t *testing.T) {
+	fsm := newTestTree(t)
+	node, _ := fsm.FindNode("/index.html")
+	file := node.(*File)
+
+	before := file.ModTime()
+	file.SetContent([]byte("<h1>Changed</h1>"))
+	if file.ModTime().Before(before) {
+		t.Error("Expected SetContent to update the modification time")
+	}
+
+	info, err := fsm.Stat("index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(file.ModTime()) || info.Size() != 16 {
+		t.Errorf("Unexpected info after SetContent: %v", info)
+	}
+}
+
+// TestFSErrors tests the errors returned for bad paths
+func TestFSErrors(t *testing.T) {
+	fsm := newTestTree(t)
+
+	cases := []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{"absolute path", func() error { _, err := fsm.Open("/index.html"); return err }, fs.ErrInvalid},
+		{"dot dot", func() error { _, err := fsm.Stat("docs/../index.html"); return err }, fs.ErrInvalid},
+		{"trailing slash", func() error { _, err := fsm.ReadDir("docs/"); return err }, fs.ErrInvalid},
+		{"missing", func() error { _, err := fsm.Open("missing.txt"); return err }, fs.ErrNotExist},
+		{"through a file", func() error { _, err := fsm.Stat("index.html/x"); return err }, fs.ErrNotExist},
+		{"readdir of a file", func() error { _, err := fsm.ReadDir("index.html"); return err }, errNotDir},
+		{"readfile of a directory", func() error { _, err := fsm.ReadFile("docs"); return err }, errIsDir},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.call()
+			var pathErr *fs.PathError
+			if !errors.As(err, &pathErr) {
+				t.Fatalf("Expected a *fs.PathError, got %v", err)
+			}
+			if !errors.Is(err, c.expected) {
+				t.Errorf("Expected %v, got %v", c.expected, err)
+			}
+		})
+	}
+}
+
+// TestFSOpenFile tests reading, seeking and closing an open file
+func TestFSOpenFile(t *testing.T) {
+	fsm := newTestTree(t)
+
+	f, err := fsm.Open("docs/readme.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := f.(io.ReadSeeker)
+	if _, err := rs.Seek(2, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(rs)
+	if err != nil || string(rest) != "README" {
+		t.Errorf("Expected README after seeking, got %q, %v", rest, err)
+	}
+
+	// The open file keeps the content it had when it was opened
+	node, _ := fsm.FindNode("/docs/readme.md")
+	node.(*File).SetContent([]byte("changed"))
+	buf := make([]byte, 1)
+	if _, err := f.(io.ReaderAt).ReadAt(buf, 0); err != nil || buf[0] != '#' {
+		t.Errorf("Expected '#', got %q, %v", buf, err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Read(buf); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("Expected fs.ErrClosed after Close, got %v", err)
+	}
+
+	// ReadFile returns a copy
+	content, _ := fsm.ReadFile("docs/readme.md")
+	content[0] = 'X'
+	if string(node.(*File).Content()) != "changed" {
+		t.Error("Expected ReadFile to return a copy of the content")
+	}
+}
+
+// TestFSHTTPFileServer tests serving the tree over HTTP
+func TestFSHTTPFileServer(t *testing.T) {
+	fsm := newTestTree(t)
+	server := httptest.NewServer(http.FileServer(http.FS(fsm)))
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	if status, body := get("/docs/guide.md"); status != http.StatusOK || body != "# Guide" {
+		t.Errorf("GET /docs/guide.md: %d %q", status, body)
+	}
+	if status, body := get("/"); status != http.StatusOK || body != "<h1>Home</h1>" {
+		t.Errorf("GET /: %d %q", status, body)
+	}
+	if status, body := get("/docs/"); status != http.StatusOK || !strings.Contains(body, `<a href="examples/">examples/</a>`) {
+		t.Errorf("GET /docs/: %d %q", status, body)
+	}
+	if status, _ := get("/missing"); status != http.StatusNotFound {
+		t.Errorf("GET /missing: expected 404, got %d", status)
+	}
+}
+
+// TestFSTemplateParseFS tests loading templates from the tree
+func TestFSTemplateParseFS(t *testing.T) {
+	fsm := newTestTree(t)
+
+	tmpl, err := template.ParseFS(fsm, "templates/*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := tmpl.ExecuteTemplate(&sb, "page", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "<p>hello</p><footer/>" {
+		t.Errorf("Unexpected template output: %q", sb.String())
+	}
+}
@@ -0,0 +1,140 @@
+package composite
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// tree is the state shared by the managers of one tree, such as those
+// returned by As.
+//
+// Each node has its own lock, so operations on unrelated subtrees do not
+// block each other. Locks are taken in a fixed order to avoid deadlocks:
+// mu, then the quota locks of directories, then the locks of nodes, then
+// the lock of the index. When an operation holds several quota locks or
+// several node locks, it takes them in order of node id, which unlike the
+// shape of the tree never changes
+type tree struct {
+	// mu is held for reading by every change made through a manager, and
+	// for writing to capture or restore the whole tree at once
+	mu sync.RWMutex
+
+	index *searchIndex // Also reachable from the root, for changes made on nodes
+}
+
+// newTree returns the shared state of a new tree with the given root
+func newTree(root *Directory) *tree {
+	t := &tree{index: newSearchIndex()}
+	t.index.reset(capture(root))
+	root.index = t.index
+	return t
+}
+
+// lockQuotas takes the quota locks of the directories with a quota among
+// dirs and the directories above them, and returns the function that
+// releases them. Changes under different quotas do not wait for each other.
+// The directories are looked up again once locked, and the locks taken
+// again if a move changed them meanwhile
+func lockQuotas(dirs ...*Directory) func() {
+	for {
+		locked := quotaDirs(dirs)
+		for _, d := range locked {
+			d.quotaMu.Lock()
+		}
+		if slices.Equal(locked, quotaDirs(dirs)) {
+			return func() {
+				for i := len(locked) - 1; i >= 0; i-- {
+					locked[i].quotaMu.Unlock()
+				}
+			}
+		}
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].quotaMu.Unlock()
+		}
+	}
+}
+
+// quotaDirs returns the directories with a quota among dirs and the
+// directories above them, in order of id
+func quotaDirs(dirs []*Directory) []*Directory {
+	var found []*Directory
+	for _, dir := range dirs {
+		for d := dir; d != nil; d = d.parent.Load() {
+			if d.Quota() != (Quota{}) && !slices.Contains(found, d) {
+				found = append(found, d)
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].id < found[j].id })
+	return found
+}
+
+// lockMove locks the nodes a move changes: the source and destination
+// directories, the node itself, whose name may change, and the directories
+// from each parent up to the lowest directory above both. Any move that
+// could put one of the directories inside the node locks that same common
+// ancestor, so two moves cannot pass each other's checks at once, and no
+// directory on the way can leave the tree halfway through the move. Moves
+// in other subtrees go ahead. If a concurrent change moved one of the
+// directories before it was locked, the locks are taken again. It returns
+// the function that unlocks them
+func lockMove(source, dest *Directory, node FileSystemNode) func() {
+	for {
+		dirs := movePath(source, dest)
+		var locked []*baseNode
+		if b := nodeBase(node); b != nil {
+			locked = append(locked, b)
+		}
+		for _, d := range dirs {
+			if b := &d.baseNode; !slices.Contains(locked, b) {
+				locked = append(locked, b)
+			}
+		}
+		sort.Slice(locked, func(i, j int) bool { return locked[i].id < locked[j].id })
+
+		for _, b := range locked {
+			b.mu.Lock()
+		}
+		unlock := func() {
+			for i := len(locked) - 1; i >= 0; i-- {
+				locked[i].mu.Unlock()
+			}
+		}
+		if slices.Equal(movePath(source, dest), dirs) {
+			return unlock
+		}
+		unlock()
+	}
+}
+
+// movePath returns the directories from source and from dest up to their
+// common ancestor, or up to the top of their trees if they have none
+func movePath(source, dest *Directory) []*Directory {
+	ancestor := commonAncestor(source, dest)
+	var dirs []*Directory
+	for _, d := range []*Directory{source, dest} {
+		for ; d != nil && d != ancestor; d = d.parent.Load() {
+			dirs = append(dirs, d)
+		}
+	}
+	if ancestor != nil {
+		dirs = append(dirs, ancestor)
+	}
+	return dirs
+}
+
+// commonAncestor returns the lowest directory that is or contains both a
+// and b, or nil if they are in different trees
+func commonAncestor(a, b *Directory) *Directory {
+	above := map[*Directory]bool{}
+	for d := a; d != nil; d = d.parent.Load() {
+		above[d] = true
+	}
+	for d := b; d != nil; d = d.parent.Load() {
+		if above[d] {
+			return d
+		}
+	}
+	return nil
+}
@@ -0,0 +1,497 @@
+package composite
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is an immutable copy of a tree, taken with
+// FileSystemManager.Snapshot. Snapshots share the nodes that did not change
+// between them, and share file content with the tree, which files never
+// modify in place or hand out
+type Snapshot struct {
+	root *snapshotNode
+	time time.Time
+}
+
+// snapshotNode is a node as it was when a snapshot was taken. It is never
+// modified once created, so any number of snapshots can share it
+type snapshotNode struct {
+	id           uint64
+	name         string
+	dir          bool
+	content      []byte
+	size         int64
+	permissions  Permission
+	owner        string
+	group        string
+	creationTime time.Time
+	modTime      time.Time
+	quota        Quota
+	children     []*snapshotNode
+}
+
+// cachedSnapshot is the snapshot of a node as it was at a version
+type cachedSnapshot struct {
+	node    *snapshotNode
+	version uint64
+}
+
+// lastNodeID is the id given to the most recently created node
+var lastNodeID atomic.Uint64
+
+// newNodeID returns an id no other node has
+func newNodeID() uint64 {
+	return lastNodeID.Add(1)
+}
+
+// changed records a change to the node by moving it and the directories
+// containing it, whose snapshots include the node, to a new version. The
+// snapshots cached at older versions are then out of date
+func (b *baseNode) changed() {
+	for n := b; ; {
+		n.version.Add(1)
+		parent := n.parent.Load()
+		if parent == nil {
+			return
+		}
+		n = &parent.baseNode
+	}
+}
+
+// setModTime sets when the node was last modified
+func (b *baseNode) setModTime(t time.Time) {
+	b.mu.Lock()
+	b.modTime = t
+	b.treeIndex().attrsChanged(b)
+	b.mu.Unlock()
+	b.changed()
+}
+
+// nodeBase returns the attributes node has in common with other nodes
+func nodeBase(node FileSystemNode) *baseNode {
+	switch n := node.(type) {
+	case *File:
+		return &n.baseNode
+	case *Directory:
+		return &n.baseNode
+	}
+	return nil
+}
+
+// Snapshot returns a snapshot of the whole tree. Only the nodes that changed
+// since the previous snapshot are copied, so taking one is cheap however
+// large the tree is. File content is shared rather than copied, which is
+// safe because files copy content on the way in and out
+func (m *FileSystemManager) Snapshot() *Snapshot {
+	m.tree.mu.Lock()
+	defer m.tree.mu.Unlock()
+	return &Snapshot{root: capture(m.root), time: time.Now()}
+}
+
+// Restore rolls the tree back to snapshot s. Only the superuser may restore
+// a snapshot. Nodes that still exist are kept, wherever they were moved, so
+// references to them stay valid. Nodes that did not exist when s was taken
+// leave the tree
+func (m *FileSystemManager) Restore(s *Snapshot) error {
+	if !m.user.IsSuperuser() {
+		return &fs.PathError{Op: "restore", Path: m.root.Path(), Err: fs.ErrPermission}
+	}
+	m.tree.mu.Lock()
+	defer m.tree.mu.Unlock()
+	before := liveNodes(m.root)
+	restore(m.root, s.root, before)
+	after := liveNodes(m.root)
+	for id, node := range before {
+		if after[id] != node {
+			detach(node, after)
+		}
+	}
+	m.tree.index.reset(capture(m.root))
+	return nil
+}
+
+// Time returns when the snapshot was taken
+func (s *Snapshot) Time() time.Time {
+	return s.time
+}
+
+// Size returns the total size of the files in the snapshot
+func (s *Snapshot) Size() int64 {
+	return s.root.size
+}
+
+// capture returns the snapshot of node, reusing the cached one of each node
+// that has not changed since it was taken. A node that changes while it is
+// being captured is captured again next time, because its version changed
+func capture(node FileSystemNode) *snapshotNode {
+	b := nodeBase(node)
+	version := b.version.Load()
+	if cached := b.snap.Load(); cached != nil && cached.version == version {
+		return cached.node
+	}
+
+	var children []FileSystemNode
+	b.mu.RLock()
+	s := &snapshotNode{
+		id:           b.id,
+		name:         b.name,
+		permissions:  b.permissions,
+		owner:        b.owner,
+		group:        b.group,
+		creationTime: b.creationTime,
+		modTime:      b.modTime,
+	}
+	switch n := node.(type) {
+	case *File:
+		s.content = n.content
+		s.size = int64(len(n.content))
+	case *Directory:
+		s.dir = true
+		s.quota = n.quota
+		children = append(children, n.children...)
+	}
+	b.mu.RUnlock()
+
+	if s.dir {
+		s.children = make([]*snapshotNode, 0, len(children))
+		for _, child := range children {
+			c := capture(child)
+			s.children = append(s.children, c)
+			s.size += c.size
+		}
+	}
+	b.snap.Store(&cachedSnapshot{node: s, version: version})
+	return s
+}
+
+// liveNodes returns node and the nodes inside it by id
+func liveNodes(node FileSystemNode) map[uint64]FileSystemNode {
+	nodes := map[uint64]FileSystemNode{}
+	var visit func(FileSystemNode)
+	visit = func(node FileSystemNode) {
+		nodes[nodeBase(node).id] = node
+		if dir, ok := node.(*Directory); ok {
+			for _, child := range dir.Children() {
+				visit(child)
+			}
+		}
+	}
+	visit(node)
+	return nodes
+}
+
+// detach cuts node, which a restore left out of the tree, loose from the
+// nodes still in it, given by id in live. A node left out along with its
+// parent stays in its parent, so dropped subtrees stay whole
+func detach(node FileSystemNode, live map[uint64]FileSystemNode) {
+	b := nodeBase(node)
+	if parent := b.parent.Load(); parent != nil && live[parent.id] == FileSystemNode(parent) {
+		b.parent.Store(nil)
+	}
+	if dir, ok := node.(*Directory); ok {
+		dir.mu.Lock()
+		kept := dir.children[:0]
+		for _, child := range dir.children {
+			if nodeBase(child).parent.Load() == dir {
+				kept = append(kept, child)
+			}
+		}
+		dir.children = kept
+		dir.mu.Unlock()
+	}
+}
+
+// restore makes node match s. Children of s are restored from the live node
+// with the same id, found in live wherever it is now, and the others are
+// created anew
+func restore(node FileSystemNode, s *snapshotNode, live map[uint64]FileSystemNode) {
+	b := nodeBase(node)
+	if cached := b.snap.Load(); cached != nil && cached.node == s && cached.version == b.version.Load() {
+		return
+	}
+
+	var children []FileSystemNode
+	b.mu.Lock()
+	b.permissions = s.permissions
+	b.owner = s.owner
+	b.group = s.group
+	b.creationTime = s.creationTime
+	b.modTime = s.modTime
+
+	switch n := node.(type) {
+	case *File:
+		n.content = s.content
+	case *Directory:
+		n.quota = s.quota
+		n.children = make([]FileSystemNode, 0, len(s.children))
+		for _, c := range s.children {
+			child, ok := live[c.id]
+			if !ok || child.IsDirectory() != c.dir {
+				if c.dir {
+					child = &Directory{baseNode: baseNode{id: c.id}}
+				} else {
+					child = &File{baseNode: baseNode{id: c.id}}
+				}
+			}
+			cb := nodeBase(child)
+			cb.mu.Lock()
+			cb.name = c.name
+			cb.mu.Unlock()
+			cb.parent.Store(n)
+			n.children = append(n.children, child)
+		}
+		children = n.children
+	}
+	b.mu.Unlock()
+
+	for i, child := range children {
+		restore(child, s.children[i], live)
+	}
+	b.snap.Store(&cachedSnapshot{node: s, version: b.version.Add(1)})
+}
+
+// differs reports whether the content or attributes of two nodes of the
+// same kind differ. The children of directories are not compared
+func (s *snapshotNode) differs(other *snapshotNode) bool {
+	if s.permissions != other.permissions || s.owner != other.owner || s.group != other.group {
+		return true
+	}
+	if s.dir {
+		return s.quota != other.quota
+	}
+	return !bytes.Equal(s.content, other.content)
+}
+
+// ChangeKind is the kind of a Change
+type ChangeKind int
+
+const (
+	// Added nodes are only in the newer snapshot
+	Added ChangeKind = iota
+	// Removed nodes are only in the older snapshot
+	Removed
+	// Modified nodes have different content, permissions, owner or quota
+	Modified
+	// Moved nodes have a different name or parent directory
+	Moved
+)
+
+// String returns the name of the kind
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	case Moved:
+		return "moved"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change describes how a node differs between two snapshots. A node that was
+// moved and modified has a change of each kind
+type Change struct {
+	Kind    ChangeKind
+	Path    string // Path in the newer snapshot, or the older one if removed
+	OldPath string // Path in the older snapshot of a moved node
+	Size    int64  // Size in the newer snapshot, or the older one if removed
+	OldSize int64  // Size in the older snapshot of a modified or moved node
+	IsDir   bool
+}
+
+// String formats the change, such as "moved /a.txt -> /b.txt (5 bytes)".
+// Directory paths end with a slash
+func (c Change) String() string {
+	suffix := ""
+	if c.IsDir {
+		suffix = "/"
+	}
+	size := fmt.Sprintf("%d bytes", c.Size)
+	if (c.Kind == Modified || c.Kind == Moved) && c.OldSize != c.Size {
+		size = fmt.Sprintf("%d -> %d bytes", c.OldSize, c.Size)
+	}
+	if c.Kind == Moved {
+		return fmt.Sprintf("%s %s%s -> %s%s (%s)", c.Kind, c.OldPath, suffix, c.Path, suffix, size)
+	}
+	return fmt.Sprintf("%s %s%s (%s)", c.Kind, c.Path, suffix, size)
+}
+
+// Diff lists the changes that turn snapshot a into snapshot b, sorted by
+// path. Nodes are matched by identity, so a node that was moved is reported
+// as moved rather than removed and added. Only the top of a moved directory
+// is reported, while every node of an added or removed directory is.
+// Subtrees the snapshots share are skipped, so comparing snapshots of a
+// large tree costs little when few nodes changed
+func Diff(a, b *Snapshot) []Change {
+	d := &differ{removed: map[uint64]diffEntry{}}
+	d.compare("/", "/", a.root, b.root)
+
+	// Look for each added node among the removed ones, opening added
+	// directories until every added node has been seen, then removed ones
+	// for nodes moved out of them
+	for {
+		d.matchMoves()
+		if d.expandAdded() || d.expandRemoved() {
+			continue
+		}
+		break
+	}
+
+	// Nodes replaced by another node at the same path are modified
+	removed := make(map[string]diffEntry, len(d.removed)+len(d.gone))
+	for _, e := range d.removed {
+		removed[e.path] = e
+	}
+	for _, e := range d.gone {
+		removed[e.path] = e
+	}
+	for _, e := range append(d.added, d.born...) {
+		r, ok := removed[e.path]
+		if !ok || r.node.dir != e.node.dir {
+			d.change(Added, "", e.path, nil, e.node)
+			continue
+		}
+		delete(removed, e.path)
+		if r.node.differs(e.node) {
+			d.change(Modified, "", e.path, r.node, e.node)
+		}
+	}
+	for _, r := range removed {
+		d.change(Removed, "", r.path, nil, r.node)
+	}
+
+	sort.Slice(d.changes, func(i, j int) bool {
+		if d.changes[i].Path != d.changes[j].Path {
+			return d.changes[i].Path < d.changes[j].Path
+		}
+		return d.changes[i].Kind < d.changes[j].Kind
+	})
+	return d.changes
+}
+
+// differ holds the state of Diff
+type differ struct {
+	changes []Change
+	removed map[uint64]diffEntry // Nodes only in a, by id, not yet matched
+	added   []diffEntry          // Nodes only in b not yet matched
+	gone    []diffEntry          // Removed directories that have been opened
+	born    []diffEntry          // Added directories that have been opened
+}
+
+// diffEntry is a node at a path in one of the snapshots
+type diffEntry struct {
+	path string
+	node *snapshotNode
+}
+
+// change records a change to node, which was old in the older snapshot
+func (d *differ) change(kind ChangeKind, oldPath, path string, old, node *snapshotNode) {
+	c := Change{Kind: kind, OldPath: oldPath, Path: path, Size: node.size, IsDir: node.dir}
+	if old != nil {
+		c.OldSize = old.size
+	}
+	d.changes = append(d.changes, c)
+}
+
+// compare compares node a at pathA with node b at pathB. Nodes that are not
+// the same node are set aside as removed and added
+func (d *differ) compare(pathA, pathB string, a, b *snapshotNode) {
+	if a == b {
+		return
+	}
+	if a.id != b.id || a.dir != b.dir {
+		d.removed[a.id] = diffEntry{pathA, a}
+		d.added = append(d.added, diffEntry{pathB, b})
+		return
+	}
+	if a.differs(b) {
+		d.change(Modified, "", pathB, a, b)
+	}
+
+	children := make(map[string]*snapshotNode, len(a.children))
+	for _, child := range a.children {
+		children[child.name] = child
+	}
+	for _, child := range b.children {
+		if old, ok := children[child.name]; ok {
+			delete(children, child.name)
+			d.compare(path.Join(pathA, child.name), path.Join(pathB, child.name), old, child)
+		} else {
+			d.added = append(d.added, diffEntry{path.Join(pathB, child.name), child})
+		}
+	}
+	for _, child := range a.children {
+		if _, ok := children[child.name]; ok {
+			d.removed[child.id] = diffEntry{path.Join(pathA, child.name), child}
+		}
+	}
+}
+
+// matchMoves reports added nodes that were removed from elsewhere as moved,
+// and compares their old and new versions
+func (d *differ) matchMoves() {
+	for matched := true; matched; {
+		matched = false
+		added := d.added
+		d.added = nil
+		for _, e := range added {
+			r, ok := d.removed[e.node.id]
+			if !ok || r.node.dir != e.node.dir {
+				d.added = append(d.added, e)
+				continue
+			}
+			matched = true
+			delete(d.removed, e.node.id)
+			d.change(Moved, r.path, e.path, r.node, e.node)
+			d.compare(r.path, e.path, r.node, e.node)
+		}
+	}
+}
+
+// expandAdded opens the added directories not yet matched, so that their
+// children can be matched. It reports whether there were any
+func (d *differ) expandAdded() bool {
+	expanded := false
+	added := d.added
+	d.added = nil
+	for _, e := range added {
+		if !e.node.dir {
+			d.added = append(d.added, e)
+			continue
+		}
+		expanded = true
+		d.born = append(d.born, e)
+		for _, child := range e.node.children {
+			d.added = append(d.added, diffEntry{path.Join(e.path, child.name), child})
+		}
+	}
+	return expanded
+}
+
+// expandRemoved opens the removed directories not yet matched, so that
+// their children can be matched. It reports whether there were any
+func (d *differ) expandRemoved() bool {
+	var dirs []diffEntry
+	for id, e := range d.removed {
+		if e.node.dir {
+			dirs = append(dirs, e)
+			delete(d.removed, id)
+		}
+	}
+	for _, e := range dirs {
+		d.gone = append(d.gone, e)
+		for _, child := range e.node.children {
+			d.removed[child.id] = diffEntry{path.Join(e.path, child.name), child}
+		}
+	}
+	return len(dirs) > 0
+}
@@ -0,0 +1,390 @@
+package composite
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// snapshotNodeAt returns the node at a slash separated path in s, or nil
+func snapshotNodeAt(s *Snapshot, name string) *snapshotNode {
+	node := s.root
+	for _, part := range splitPath(name) {
+		var next *snapshotNode
+		for _, child := range node.children {
+			if child.name == part {
+				next = child
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// TestSnapshotRestore tests undoing bulk changes by restoring a snapshot
+func TestSnapshotRestore(t *testing.T) {
+	fsm := newTransferTree(t)
+	before := listing(t, fsm, 0)
+	index, _ := fsm.FindNode("/index.html")
+	snapshot := fsm.Snapshot()
+
+	if _, err := fsm.UpdatePermissions("/", Execute, Read, true, true, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	steps := []error{
+		fsm.MoveNode("/docs/examples", "/examples"),
+		fsm.WriteFile("/docs/guide.md", []byte("# Rewritten")),
+		fsm.DeleteNode("/templates"),
+		fsm.CopyNode("/docs", "/backup/docs"),
+		fsm.Chown("/docs/readme.md", "alice", "staff"),
+		fsm.SetQuota("/docs", Quota{MaxInodes: 10}),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if listing(t, fsm, 0) == before {
+		t.Fatal("Expected the tree to change")
+	}
+
+	if err := fsm.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if after := listing(t, fsm, 0); after != before {
+		t.Errorf("Expected the restored tree to match the snapshot\nexpected:\n%s\ngot:\n%s", before, after)
+	}
+	if node, _ := fsm.FindNode("/index.html"); node != index {
+		t.Error("Expected an unchanged node to be kept")
+	}
+	readme, _ := fsm.FindNode("/docs/readme.md")
+	if readme.Owner() != "root" || readme.Group() != "root" {
+		t.Errorf("Expected the owner to be restored, got %s:%s", readme.Owner(), readme.Group())
+	}
+	docs, _ := fsm.FindNode("/docs")
+	if docs.(*Directory).Quota() != (Quota{}) {
+		t.Error("Expected the quota to be restored")
+	}
+	if changes := Diff(snapshot, fsm.Snapshot()); len(changes) != 0 {
+		t.Errorf("Expected no changes after restoring, got %v", changes)
+	}
+
+	// The restored tree works as before, and the snapshot is unaffected by
+	// later changes
+	if _, err := fsm.CreateFile("/docs/examples/example2.go", []byte("package main")); err != nil {
+		t.Fatal(err)
+	}
+	if parent := fsm.Root().FindByPath("docs/examples/example2.go").(*File).parent.Load(); parent.Path() != "/docs/examples" {
+		t.Errorf("Unexpected parent %s", parent.Path())
+	}
+	if snapshotNodeAt(snapshot, "/docs/examples/example2.go") != nil {
+		t.Error("Expected the snapshot not to change")
+	}
+	if err := fsm.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if after := listing(t, fsm, 0); after != before {
+		t.Errorf("Expected the tree to be restored again, got:\n%s", after)
+	}
+}
+
+// TestRestoreMoved tests that restoring a snapshot puts moved nodes back
+// rather than replacing them, and cuts nodes created since loose from the
+// tree
+func TestRestoreMoved(t *testing.T) {
+	fsm := NewFileSystemManager()
+	file, _ := fsm.CreateFile("/a/x.txt", []byte("alpha"))
+	dir, _ := fsm.CreateDirectory("/a/sub")
+	fsm.CreateFile("/a/sub/y.txt", []byte("beta"))
+	fsm.CreateDirectory("/b")
+	snapshot := fsm.Snapshot()
+
+	for _, move := range [][2]string{{"/a/x.txt", "/b/x.txt"}, {"/a/sub", "/b/renamed"}} {
+		if err := fsm.MoveNode(move[0], move[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	created, _ := fsm.CreateFile("/new/z.txt", []byte("gamma"))
+	fsm.MoveNode("/b/renamed/y.txt", "/new/y.txt")
+	newDir, _ := fsm.FindNode("/new")
+
+	if err := fsm.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := fsm.FindNode("/a/x.txt"); node != file {
+		t.Error("Expected the moved file to be put back")
+	}
+	if node, _ := fsm.FindNode("/a/sub"); node != dir {
+		t.Error("Expected the moved directory to be put back")
+	}
+	if file.Path() != "/a/x.txt" || dir.Path() != "/a/sub" {
+		t.Errorf("Unexpected paths %s and %s", file.Path(), dir.Path())
+	}
+	if changes := Diff(snapshot, fsm.Snapshot()); len(changes) != 0 {
+		t.Errorf("Expected no changes after restoring, got %v", changes)
+	}
+
+	// Nodes created since the snapshot are no longer in the tree, and do
+	// not hold on to the nodes that are
+	if created.Path() != "new/z.txt" || newDir.(*Directory).parent.Load() != nil {
+		t.Errorf("Expected /new to be cut loose whole, got %s", created.Path())
+	}
+	if names := newDir.(*Directory).Children(); len(names) != 1 || names[0] != FileSystemNode(created) {
+		t.Errorf("Expected /new to keep only its own file, got %v", names)
+	}
+
+	// Changes to the restored nodes are indexed at their restored paths,
+	// and changes to nodes outside the tree are not indexed
+	file.SetContent([]byte("zebra"))
+	created.SetContent([]byte("zebra"))
+	expectPaths(t, fsm, Query{Text: "zebra"}, "/a/x.txt")
+	checkIndex(t, fsm, "restore after moves")
+}
+
+// TestSnapshotSharing tests that snapshots share unchanged nodes
+func TestSnapshotSharing(t *testing.T) {
+	fsm := newTestTree(t)
+	first := fsm.Snapshot()
+	if second := fsm.Snapshot(); second.root != first.root {
+		t.Error("Expected snapshots of an unchanged tree to share the root")
+	}
+
+	fsm.WriteFile("/docs/examples/example1.go", []byte("package example"))
+	second := fsm.Snapshot()
+
+	for _, name := range []string{"/", "/docs", "/docs/examples", "/docs/examples/example1.go"} {
+		if snapshotNodeAt(first, name) == snapshotNodeAt(second, name) {
+			t.Errorf("%s: expected a new node", name)
+		}
+	}
+	for _, name := range []string{"/index.html", "/templates", "/docs/readme.md", "/empty"} {
+		if snapshotNodeAt(first, name) != snapshotNodeAt(second, name) {
+			t.Errorf("%s: expected a shared node", name)
+		}
+	}
+	if first.Size() != 132 || second.Size() != 135 {
+		t.Errorf("Unexpected snapshot sizes %d and %d", first.Size(), second.Size())
+	}
+	if second.Time().Before(first.Time()) {
+		t.Error("Expected snapshot times to increase")
+	}
+
+	// Moving a directory only copies the directories it left and entered
+	fsm.MoveNode("/templates", "/docs/templates")
+	third := fsm.Snapshot()
+	if snapshotNodeAt(second, "/templates") != snapshotNodeAt(third, "/docs/templates") {
+		t.Error("Expected a moved directory to be shared")
+	}
+
+	// A renamed node is copied
+	fsm.MoveNode("/docs/readme.md", "/docs/README.md")
+	if snapshotNodeAt(fsm.Snapshot(), "/docs/README.md") == snapshotNodeAt(third, "/docs/readme.md") {
+		t.Error("Expected a renamed node to be copied")
+	}
+}
+
+// TestSnapshotIsolation tests that changing the slices passed to or
+// returned by a file does not change a snapshot
+func TestSnapshotIsolation(t *testing.T) {
+	fsm := NewFileSystemManager()
+	buf := []byte("hello")
+	f, err := fsm.CreateFile("/greeting.txt", buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snapshot := fsm.Snapshot()
+
+	buf[0] = 'J'
+	f.Content()[1] = 'E'
+	replacement := []byte("world")
+	f.SetContent(replacement)
+	replacement[0] = 'W'
+	if got := string(f.Content()); got != "world" {
+		t.Errorf("Expected the file to keep its own copy, got %q", got)
+	}
+
+	if err := fsm.Restore(snapshot); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := string(f.Content()); got != "hello" {
+		t.Errorf("Expected the restored content to be %q, got %q", "hello", got)
+	}
+	if got := string(snapshotNodeAt(snapshot, "/greeting.txt").content); got != "hello" {
+		t.Errorf("Expected the snapshot to keep %q, got %q", "hello", got)
+	}
+}
+
+// TestSnapshotPermissions tests that only the superuser may restore
+func TestSnapshotPermissions(t *testing.T) {
+	fsm := newHomes(t)
+	asAlice := fsm.As(alice)
+	snapshot := asAlice.Snapshot()
+	asAlice.CreateFile("/home/alice/notes.txt", nil)
+
+	err := asAlice.Restore(snapshot)
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected a permission error, got %v", err)
+	}
+	if err := fsm.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsm.FindNode("/home/alice/notes.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the file to be gone, got %v", err)
+	}
+}
+
+// TestDiff tests the changes reported between snapshots
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name     string
+		change   func(fsm *FileSystemManager) error
+		expected []string
+	}{
+		{
+			"nothing",
+			func(fsm *FileSystemManager) error { return nil },
+			nil,
+		},
+		{
+			"add and remove",
+			func(fsm *FileSystemManager) error {
+				fsm.CreateFile("/new/hello.txt", []byte("hello"))
+				return fsm.DeleteNode("/docs/examples")
+			},
+			[]string{
+				"removed /docs/examples/ (12 bytes)",
+				"removed /docs/examples/example1.go (12 bytes)",
+				"added /new/ (5 bytes)",
+				"added /new/hello.txt (5 bytes)",
+			},
+		},
+		{
+			"modify",
+			func(fsm *FileSystemManager) error {
+				fsm.WriteFile("/index.html", []byte("<h1>Welcome</h1>"))
+				fsm.Chmod("/docs", Read|Execute)
+				return fsm.SetQuota("/empty", Quota{MaxBytes: 10})
+			},
+			[]string{
+				"modified /docs/ (27 bytes)",
+				"modified /empty/ (0 bytes)",
+				"modified /index.html (13 -> 16 bytes)",
+			},
+		},
+		{
+			"rewrite with the same content",
+			func(fsm *FileSystemManager) error {
+				return fsm.WriteFile("/index.html", []byte("<h1>Home</h1>"))
+			},
+			nil,
+		},
+		{
+			"move a directory",
+			func(fsm *FileSystemManager) error {
+				return fsm.MoveNode("/docs/examples", "/examples")
+			},
+			[]string{"moved /docs/examples/ -> /examples/ (12 bytes)"},
+		},
+		{
+			"move and modify",
+			func(fsm *FileSystemManager) error {
+				fsm.MoveNode("/docs", "/documentation")
+				return fsm.WriteFile("/documentation/guide.md", []byte("# User Guide"))
+			},
+			[]string{
+				"moved /docs/ -> /documentation/ (27 -> 32 bytes)",
+				"modified /documentation/guide.md (7 -> 12 bytes)",
+			},
+		},
+		{
+			"move into a new directory",
+			func(fsm *FileSystemManager) error {
+				return fsm.MoveNode("/docs/readme.md", "/archive/old/README.md")
+			},
+			[]string{
+				"added /archive/ (8 bytes)",
+				"added /archive/old/ (8 bytes)",
+				"moved /docs/readme.md -> /archive/old/README.md (8 bytes)",
+			},
+		},
+		{
+			"move out of a removed directory",
+			func(fsm *FileSystemManager) error {
+				fsm.MoveNode("/templates/page.tmpl", "/page.tmpl")
+				return fsm.DeleteNode("/templates")
+			},
+			[]string{
+				"moved /templates/page.tmpl -> /page.tmpl (57 bytes)",
+				"removed /templates/ (92 bytes)",
+				"removed /templates/footer.tmpl (35 bytes)",
+			},
+		},
+		{
+			"replace with a new node",
+			func(fsm *FileSystemManager) error {
+				fsm.DeleteNode("/docs")
+				fsm.CreateFile("/docs/readme.md", []byte("# README"))
+				fsm.CreateFile("/docs/guide.md", []byte("# New Guide"))
+				return fsm.CopyNode("/index.html", "/docs/examples/example1.go")
+			},
+			[]string{
+				"modified /docs/examples/example1.go (12 -> 13 bytes)",
+				"modified /docs/guide.md (7 -> 11 bytes)",
+			},
+		},
+		{
+			"swap names",
+			func(fsm *FileSystemManager) error {
+				fsm.MoveNode("/docs/readme.md", "/docs/tmp")
+				fsm.MoveNode("/docs/guide.md", "/docs/readme.md")
+				return fsm.MoveNode("/docs/tmp", "/docs/guide.md")
+			},
+			[]string{
+				"moved /docs/readme.md -> /docs/guide.md (8 bytes)",
+				"moved /docs/guide.md -> /docs/readme.md (7 bytes)",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fsm := newTestTree(t)
+			before := fsm.Snapshot()
+			if err := c.change(fsm); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, change := range Diff(before, fsm.Snapshot()) {
+				got = append(got, change.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(c.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(c.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+// TestDiffUnrelated tests comparing snapshots of different trees, whose
+// nodes are matched by path
+func TestDiffUnrelated(t *testing.T) {
+	a, b := newTestTree(t), newTestTree(t)
+	b.WriteFile("/docs/guide.md", []byte("# Other"))
+	b.DeleteNode("/empty")
+
+	changes := Diff(a.Snapshot(), b.Snapshot())
+	expected := []Change{
+		{Kind: Modified, Path: "/docs/guide.md", Size: 7, OldSize: 7},
+		{Kind: Removed, Path: "/empty", IsDir: true},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], changes[i])
+		}
+	}
+}
@@ -0,0 +1,807 @@
+package composite
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SymlinkPolicy says what to do with symbolic links when importing
+type SymlinkPolicy int
+
+const (
+	// SkipSymlinks ignores symbolic links
+	SkipSymlinks SymlinkPolicy = iota
+	// FollowSymlinks imports a copy of the file or directory a link points
+	// to. The target must be inside the directory or archive being imported
+	FollowSymlinks
+	// RejectSymlinks fails the import at the first symbolic link
+	RejectSymlinks
+)
+
+var (
+	// ErrUnsafePath is returned for paths that would escape the directory
+	// being imported or exported, such as "../x" in an archive
+	ErrUnsafePath = errors.New("unsafe path")
+	// ErrTooLarge is returned when a transfer exceeds a size limit
+	ErrTooLarge = errors.New("size limit exceeded")
+	// ErrSymlink is returned for symbolic links when they are rejected
+	ErrSymlink = errors.New("symbolic link not allowed")
+)
+
+// maxLinkTarget is the longest symbolic link target read from a zip archive
+const maxLinkTarget = 4096
+
+// TransferOptions controls imports and exports. The zero value transfers
+// everything and skips symbolic links.
+//
+// Globs use path.Match syntax. A glob without a slash matches the name of a
+// file or directory anywhere in the tree, and a glob with a slash matches
+// its whole path relative to the directory being transferred. Excluding a
+// directory excludes everything in it. Include globs only select files, so
+// directories are transferred unless they are excluded
+type TransferOptions struct {
+	Symlinks     SymlinkPolicy
+	MaxFileSize  int64    // Largest file, in bytes; 0 means no limit
+	MaxTotalSize int64    // Most bytes of content in total; 0 means no limit
+	Include      []string // Globs of files to transfer; empty means all files
+	Exclude      []string // Globs of files and directories to skip
+}
+
+// validate checks the policy and globs
+func (o TransferOptions) validate() error {
+	if o.Symlinks < SkipSymlinks || o.Symlinks > RejectSymlinks {
+		return fmt.Errorf("unknown symlink policy %d", o.Symlinks)
+	}
+	if o.MaxFileSize < 0 || o.MaxTotalSize < 0 {
+		return errors.New("size limits must not be negative")
+	}
+	for _, pattern := range append(append([]string(nil), o.Include...), o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// excluded reports whether rel or one of its parent directories matches an
+// exclude glob
+func (o TransferOptions) excluded(rel string) bool {
+	for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+		if matchAny(o.Exclude, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// includesFile reports whether the file at rel should be transferred
+func (o TransferOptions) includesFile(rel string) bool {
+	if o.excluded(rel) {
+		return false
+	}
+	return len(o.Include) == 0 || matchAny(o.Include, rel)
+}
+
+// matchAny reports whether rel matches one of the globs
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportDir copies the directory dir on disk into the tree at destPath,
+// creating destPath if needed. Content, modification times and permissions
+// are preserved. Existing directories are merged and existing files are
+// overwritten
+func (m *FileSystemManager) ImportDir(dir, destPath string, opts TransferOptions) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(dir)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return err
+	}
+	dest, err := m.createDirectoryPath(destPath)
+	if err != nil {
+		return err
+	}
+
+	im := &importer{m: m, dest: dest, opts: opts}
+	if err := im.importDisk(root, root, ".", map[string]bool{root: true}); err != nil {
+		return err
+	}
+	return im.finish()
+}
+
+// ImportTar reads a tar stream into the tree at destPath, creating destPath
+// if needed. Entries with absolute names or names containing ".." are
+// rejected with ErrUnsafePath
+func (m *FileSystemManager) ImportTar(r io.Reader, destPath string, opts TransferOptions) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	dest, err := m.createDirectoryPath(destPath)
+	if err != nil {
+		return err
+	}
+
+	im := &importer{m: m, dest: dest, opts: opts}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		// Insecure names are rejected by archivePath below
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			return err
+		}
+		rel, err := archivePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			continue
+		}
+
+		info := hdr.FileInfo()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = im.addDir(rel, info.Mode(), hdr.ModTime)
+		case tar.TypeReg:
+			err = im.addFile(rel, tr, info.Mode(), hdr.ModTime)
+		case tar.TypeSymlink:
+			err = im.addLink(rel, hdr.Linkname, false)
+		case tar.TypeLink:
+			// Hard links name their target relative to the archive root
+			err = im.addLink(rel, hdr.Linkname, true)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return im.finish()
+}
+
+// ImportZip reads a zip archive of the given size into the tree at
+// destPath, creating destPath if needed. Entries with absolute names or
+// names containing ".." are rejected with ErrUnsafePath
+func (m *FileSystemManager) ImportZip(r io.ReaderAt, size int64, destPath string, opts TransferOptions) error {
+	m.tree.mu.RLock()
+	defer m.tree.mu.RUnlock()
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return err
+	}
+	dest, err := m.createDirectoryPath(destPath)
+	if err != nil {
+		return err
+	}
+
+	im := &importer{m: m, dest: dest, opts: opts}
+	for _, f := range zr.File {
+		rel, err := archivePath(f.Name)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			continue
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = im.addDir(rel, mode, f.Modified)
+		case mode&fs.ModeSymlink != 0:
+			err = im.addZipSymlink(rel, f)
+		case mode.IsRegular():
+			err = im.addZipFile(rel, f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return im.finish()
+}
+
+// ExportDir writes the subtree at srcPath to the directory dir on disk,
+// creating it if needed. Modification times and permissions are preserved.
+// Existing files are overwritten, but the export fails rather than write
+// through a symbolic link
+func (m *FileSystemManager) ExportDir(srcPath, dir string, opts TransferOptions) error {
+	type pending struct {
+		path string
+		info fs.FileInfo
+	}
+	var dirs []pending
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	err := m.exportWalk(srcPath, opts, func(rel string, info fs.FileInfo, content []byte) error {
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		existing, err := os.Lstat(target)
+		switch {
+		case err == nil && existing.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf("%w: %s is a symbolic link", ErrUnsafePath, target)
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return err
+		}
+
+		if info.IsDir() {
+			// Permissions are set once the children are written, in case
+			// the directory is read only
+			if err := os.Mkdir(target, 0700); err != nil && !errors.Is(err, fs.ErrExist) {
+				return err
+			}
+			dirs = append(dirs, pending{target, info})
+			return nil
+		}
+
+		if err := os.WriteFile(target, content, 0600); err != nil {
+			return err
+		}
+		if err := os.Chmod(target, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].info.ModTime(), dirs[i].info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportTar writes the subtree at srcPath to w as a tar stream, with names
+// relative to srcPath
+func (m *FileSystemManager) ExportTar(w io.Writer, srcPath string, opts TransferOptions) error {
+	tw := tar.NewWriter(w)
+	err := m.exportWalk(srcPath, opts, func(rel string, info fs.FileInfo, content []byte) error {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		hdr.Format = tar.FormatPAX
+		if info.IsDir() {
+			hdr.Name += "/"
+			return tw.WriteHeader(hdr)
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(content)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ExportZip writes the subtree at srcPath to w as a zip archive, with names
+// relative to srcPath. Zip stores modification times to the second
+func (m *FileSystemManager) ExportZip(w io.Writer, srcPath string, opts TransferOptions) error {
+	zw := zip.NewWriter(w)
+	err := m.exportWalk(srcPath, opts, func(rel string, info fs.FileInfo, content []byte) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil || info.IsDir() {
+			return err
+		}
+		_, err = fw.Write(content)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// exportWalk calls fn, parents first, for each node under the directory at
+// srcPath that the options select. rel is the slash separated path relative
+// to srcPath, and content is nil for directories. The manager's user needs
+// read permission on everything exported
+func (m *FileSystemManager) exportWalk(srcPath string, opts TransferOptions, fn func(rel string, info fs.FileInfo, content []byte) error) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	node, err := m.FindNode(srcPath)
+	if err != nil {
+		return err
+	}
+	if !node.IsDirectory() {
+		return fmt.Errorf("'%s' is not a directory", srcPath)
+	}
+	name := "."
+	if parts := splitPath(srcPath); len(parts) > 0 {
+		name = path.Join(parts...)
+	}
+	fsys, err := fs.Sub(m, name)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	return fs.WalkDir(fsys, ".", func(rel string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			if opts.excluded(rel) {
+				return fs.SkipDir
+			}
+		} else if !opts.includesFile(rel) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fn(rel, info, nil)
+		}
+		total += info.Size()
+		if err := opts.checkSize(rel, info.Size(), total); err != nil {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, rel)
+		if err != nil {
+			return err
+		}
+		return fn(rel, info, content)
+	})
+}
+
+// checkSize checks a file's size and the running total against the limits
+func (o TransferOptions) checkSize(rel string, size, total int64) error {
+	if o.MaxFileSize > 0 && size > o.MaxFileSize {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrTooLarge, rel, o.MaxFileSize)
+	}
+	if o.MaxTotalSize > 0 && total > o.MaxTotalSize {
+		return fmt.Errorf("%w: more than %d bytes in total at %s", ErrTooLarge, o.MaxTotalSize, rel)
+	}
+	return nil
+}
+
+// archivePath returns the slash separated path of an archive entry relative
+// to the archive root, or "." for the root itself
+func archivePath(name string) (string, error) {
+	rel := strings.TrimSuffix(strings.TrimPrefix(name, "./"), "/")
+	if rel == "" || rel == "." {
+		return ".", nil
+	}
+	if strings.Contains(rel, `\`) || !fs.ValidPath(rel) {
+		return "", fmt.Errorf("%w: archive entry %q", ErrUnsafePath, name)
+	}
+	return rel, nil
+}
+
+// importer adds imported files and directories under dest on behalf of
+// the user of m
+type importer struct {
+	m     *FileSystemManager
+	dest  *Directory
+	opts  TransferOptions
+	total int64
+	dirs  []importedDir
+	links []importedLink
+}
+
+// importedDir is a directory whose modification time is set once all of its
+// children have been added
+type importedDir struct {
+	dir     *Directory
+	modTime time.Time
+}
+
+// importedLink is a symbolic link from an archive, copied from its target
+// once the whole archive has been read
+type importedLink struct {
+	rel, target string
+}
+
+// importDisk imports the children of the directory realDir on disk, which
+// is at rel in the import. active holds the directories being imported, to
+// detect symbolic link loops
+func (im *importer) importDisk(root, realDir, rel string, active map[string]bool) error {
+	entries, err := os.ReadDir(realDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		childRel := path.Join(rel, entry.Name())
+		full := filepath.Join(realDir, entry.Name())
+		info, err := os.Lstat(full)
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			switch im.opts.Symlinks {
+			case SkipSymlinks:
+				continue
+			case RejectSymlinks:
+				return fmt.Errorf("%w: %s", ErrSymlink, childRel)
+			}
+			if full, err = filepath.EvalSymlinks(full); err != nil {
+				return err
+			}
+			if inside, err := filepath.Rel(root, full); err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("%w: %s links outside the imported directory", ErrUnsafePath, childRel)
+			}
+			if info, err = os.Stat(full); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			if im.opts.excluded(childRel) {
+				continue
+			}
+			if active[full] {
+				return fmt.Errorf("%s: symbolic link loop", childRel)
+			}
+			if err := im.addDir(childRel, info.Mode(), info.ModTime()); err != nil {
+				return err
+			}
+			active[full] = true
+			err = im.importDisk(root, full, childRel, active)
+			delete(active, full)
+		case info.Mode().IsRegular():
+			err = im.importDiskFile(full, childRel, info)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importDiskFile imports the regular file at full on disk
+func (im *importer) importDiskFile(full, rel string, info fs.FileInfo) error {
+	if !im.opts.includesFile(rel) {
+		return nil
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return im.addFile(rel, f, info.Mode(), info.ModTime())
+}
+
+// addZipFile imports a regular file from a zip archive
+func (im *importer) addZipFile(rel string, f *zip.File) error {
+	if !im.opts.includesFile(rel) {
+		return nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return im.addFile(rel, rc, f.Mode(), f.Modified)
+}
+
+// addZipSymlink imports a symbolic link from a zip archive, where the
+// content of the entry is the link target
+func (im *importer) addZipSymlink(rel string, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	target, err := io.ReadAll(io.LimitReader(rc, maxLinkTarget))
+	if err != nil {
+		return err
+	}
+	return im.addLink(rel, string(target), false)
+}
+
+// addDir creates the directory at rel with the given mode and time
+func (im *importer) addDir(rel string, mode fs.FileMode, modTime time.Time) error {
+	if im.opts.excluded(rel) {
+		return nil
+	}
+	dir, err := im.makeDirs(rel)
+	if err != nil {
+		return err
+	}
+	if im.m.user.Owns(dir) {
+		dir.SetPermissions(PermissionFromMode(mode))
+		im.dirs = append(im.dirs, importedDir{dir, modTime})
+	}
+	return nil
+}
+
+// addFile creates or overwrites the file at rel with the content of r
+func (im *importer) addFile(rel string, r io.Reader, mode fs.FileMode, modTime time.Time) error {
+	if !im.opts.includesFile(rel) {
+		return nil
+	}
+
+	// Read at most one byte more than the limits allow, so that a file
+	// whose header understates its size cannot exhaust memory
+	limit := im.opts.MaxFileSize
+	if im.opts.MaxTotalSize > 0 && (limit == 0 || im.opts.MaxTotalSize-im.total < limit) {
+		limit = im.opts.MaxTotalSize - im.total
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", rel, err)
+	}
+	im.total += int64(len(content))
+	if err := im.opts.checkSize(rel, int64(len(content)), im.total); err != nil {
+		return err
+	}
+
+	parent, err := im.makeDirs(path.Dir(rel))
+	if err != nil {
+		return err
+	}
+	name := path.Base(rel)
+	var file *File
+	switch existing := parent.GetChild(name).(type) {
+	case *File:
+		file = existing
+		if err := checkAccess("write", im.path(rel), file, Write, &im.m.user); err != nil {
+			return err
+		}
+		unlock := lockQuotas(parent)
+		err := checkQuota(parent, int64(len(content))-file.Size(), 0, nil)
+		if err == nil {
+			file.SetContent(content)
+		}
+		unlock()
+		if err != nil {
+			return err
+		}
+	case nil:
+		file = NewFile(name, content)
+		existing, err := im.m.create("create", im.path(rel), parent, file)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("a node named '%s' already exists", rel)
+		}
+	default:
+		return fmt.Errorf("a directory named '%s' already exists", rel)
+	}
+	if im.m.user.Owns(file) {
+		file.SetPermissions(PermissionFromMode(mode))
+	}
+	file.setModTime(modTime)
+	return nil
+}
+
+// addLink records a link at rel to target, which is relative to the archive
+// root if fromRoot is set and otherwise to the directory containing the link
+func (im *importer) addLink(rel, target string, fromRoot bool) error {
+	switch im.opts.Symlinks {
+	case SkipSymlinks:
+		return nil
+	case RejectSymlinks:
+		return fmt.Errorf("%w: %s", ErrSymlink, rel)
+	}
+	if im.opts.excluded(rel) {
+		return nil
+	}
+	resolved := path.Clean(target)
+	if !fromRoot {
+		resolved = path.Join(path.Dir(rel), target)
+	}
+	if path.IsAbs(target) || !fs.ValidPath(resolved) {
+		return fmt.Errorf("%w: %s links to %q", ErrUnsafePath, rel, target)
+	}
+	im.links = append(im.links, importedLink{rel, resolved})
+	return nil
+}
+
+// finish copies the targets of symbolic links into place and sets the
+// modification times of imported directories. Links are resolved in
+// rounds, so a link may point at another link
+func (im *importer) finish() error {
+	for pending := im.links; len(pending) > 0; {
+		var next []importedLink
+		for _, link := range pending {
+			if link.target == "." || strings.HasPrefix(link.rel+"/", link.target+"/") {
+				return fmt.Errorf("%s: symbolic link loop", link.rel)
+			}
+			target, err := im.dest.lookup("readlink", link.target, &im.m.user)
+			if errors.Is(err, fs.ErrPermission) {
+				return err
+			}
+			if err != nil {
+				next = append(next, link)
+				continue
+			}
+			if err := im.addCopy(link.rel, target); err != nil {
+				return err
+			}
+		}
+		if len(next) == len(pending) {
+			return fmt.Errorf("%s: link target %q not found", next[0].rel, next[0].target)
+		}
+		pending = next
+	}
+
+	for _, d := range im.dirs {
+		d.dir.setModTime(d.modTime)
+	}
+	return nil
+}
+
+// addCopy adds a copy of node at rel. The copy goes through the same
+// filters and size limits as the files read from the import
+func (im *importer) addCopy(rel string, node FileSystemNode) error {
+	if node.IsDirectory() {
+		if im.opts.excluded(rel) {
+			return nil
+		}
+	} else if !im.opts.includesFile(rel) {
+		return nil
+	}
+
+	if err := im.m.checkReadable(im.path(rel), node); err != nil {
+		return err
+	}
+	parent, err := im.makeDirs(path.Dir(rel))
+	if err != nil {
+		return err
+	}
+	copied := copyTree(node, path.Base(rel))
+	if err := im.admit(rel, copied); err != nil {
+		return err
+	}
+	existing, err := im.m.create("copy", im.path(rel), parent, copied)
+	if err == nil && existing != nil {
+		err = fmt.Errorf("a node named '%s' already exists", rel)
+	}
+	return err
+}
+
+// admit counts the files of copied, a copy about to be added at rel,
+// against the size limits, and drops the nodes inside it that the include
+// and exclude globs skip. The copy is not in the tree yet, so its fields
+// are used without locking
+func (im *importer) admit(rel string, copied FileSystemNode) error {
+	switch n := copied.(type) {
+	case *File:
+		size := int64(len(n.content))
+		im.total += size
+		return im.opts.checkSize(rel, size, im.total)
+	case *Directory:
+		kept := n.children[:0]
+		for _, child := range n.children {
+			childRel := path.Join(rel, nodeBase(child).name)
+			skip := !im.opts.includesFile(childRel)
+			if child.IsDirectory() {
+				skip = im.opts.excluded(childRel)
+			}
+			if skip {
+				nodeBase(child).parent.Store(nil)
+				continue
+			}
+			if err := im.admit(childRel, child); err != nil {
+				return err
+			}
+			kept = append(kept, child)
+		}
+		n.children = kept
+	}
+	return nil
+}
+
+// path returns the path in the tree of rel
+func (im *importer) path(rel string) string {
+	return filepath.Join(im.dest.Path(), rel)
+}
+
+// makeDirs returns the directory at the slash separated path rel under the
+// destination, creating missing directories
+func (im *importer) makeDirs(rel string) (*Directory, error) {
+	current := im.dest
+	if rel == "." {
+		return current, nil
+	}
+	for _, part := range strings.Split(rel, "/") {
+		if err := checkAccess("mkdir", im.path(rel), current, Execute, &im.m.user); err != nil {
+			return nil, err
+		}
+		child := current.GetChild(part)
+		if child == nil {
+			dir := NewDirectory(part)
+			existing, err := im.m.create("mkdir", im.path(rel), current, dir)
+			if err != nil {
+				return nil, err
+			}
+			if child = existing; child == nil {
+				child = dir
+			}
+		}
+		dir, ok := child.(*Directory)
+		if !ok {
+			return nil, fmt.Errorf("path component '%s' exists but is a file", part)
+		}
+		current = dir
+	}
+	return current, nil
+}
+
+// copyTree returns a deep copy of node named name, keeping permissions and
+// modification times
+func copyTree(node FileSystemNode, name string) FileSystemNode {
+	switch n := node.(type) {
+	case *File:
+		file := NewFile(name, n.Content())
+		file.permissions = n.GetPermissions()
+		file.modTime = n.ModTime()
+		return file
+	case *Directory:
+		dir := NewDirectory(name)
+		for _, child := range n.Children() {
+			dir.add(copyTree(child, child.Name()))
+		}
+		dir.permissions = n.GetPermissions()
+		dir.modTime = n.ModTime()
+		return dir
+	}
+	return nil
+}
@@ -0,0 +1,472 @@
+package composite
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listing describes every node of fsys, one per line, with its mode,
+// modification time and content
+func listing(t *testing.T, fsys fs.FS, precision time.Duration) string {
+	t.Helper()
+	var sb strings.Builder
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&sb, "%v %s %s", info.Mode(), info.ModTime().Truncate(precision).UTC().Format(time.RFC3339Nano), path)
+		if !d.IsDir() {
+			content, err := fs.ReadFile(fsys, path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&sb, " %q", content)
+		}
+		sb.WriteString("\n")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+// newTransferTree returns the test tree with varied permissions and fixed
+// modification times
+func newTransferTree(t *testing.T) *FileSystemManager {
+	t.Helper()
+	fsm := newTestTree(t)
+	perms := map[string]Permission{
+		"/docs/readme.md":            Read | GroupRead | OtherRead,
+		"/docs/examples/example1.go": Read | Write | Execute | GroupRead | GroupExecute,
+		"/docs/examples":             Read | Write | Execute,
+	}
+	for path, perm := range perms {
+		node, err := fsm.FindNode(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.SetPermissions(perm)
+	}
+
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	var setTimes func(node FileSystemNode)
+	setTimes = func(node FileSystemNode) {
+		modTime = modTime.Add(time.Hour)
+		if dir, ok := node.(*Directory); ok {
+			for _, child := range dir.Children() {
+				setTimes(child)
+			}
+		}
+		nodeBase(node).setModTime(modTime)
+	}
+	setTimes(fsm.Root())
+	return fsm
+}
+
+// subtree returns the directory at path as an fs.FS
+func subtree(t *testing.T, fsm *FileSystemManager, path string) fs.FS {
+	t.Helper()
+	node, err := fsm.FindNode(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node.(*Directory)
+}
+
+// TestDiskRoundTrip tests exporting to disk and importing back
+func TestDiskRoundTrip(t *testing.T) {
+	fsm := newTransferTree(t)
+	dir := t.TempDir()
+
+	if err := fsm.ExportDir("/", dir, TransferOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := listing(t, fsm, 0)
+	if got := listing(t, os.DirFS(dir), 0); got != expected {
+		t.Errorf("Unexpected files on disk.\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+
+	imported := NewFileSystemManager()
+	if err := imported.ImportDir(dir, "/restored", TransferOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := listing(t, subtree(t, imported, "/restored"), 0); got != expected {
+		t.Errorf("Unexpected imported tree.\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+
+	// Importing again overwrites files and merges directories
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := imported.ImportDir(dir, "/restored", TransferOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := imported.ReadFile("restored/index.html")
+	if string(content) != "changed" {
+		t.Errorf("Expected the file to be overwritten, got %q", content)
+	}
+}
+
+// TestArchiveRoundTrip tests exporting to archives and importing back
+func TestArchiveRoundTrip(t *testing.T) {
+	cases := []struct {
+		name      string
+		export    func(*FileSystemManager, *bytes.Buffer) error
+		importer  func(*FileSystemManager, *bytes.Buffer) error
+		precision time.Duration
+	}{
+		{
+			"tar",
+			func(fsm *FileSystemManager, buf *bytes.Buffer) error {
+				return fsm.ExportTar(buf, "/", TransferOptions{})
+			},
+			func(fsm *FileSystemManager, buf *bytes.Buffer) error {
+				return fsm.ImportTar(buf, "/", TransferOptions{})
+			},
+			0,
+		},
+		{
+			"zip",
+			func(fsm *FileSystemManager, buf *bytes.Buffer) error {
+				return fsm.ExportZip(buf, "/", TransferOptions{})
+			},
+			func(fsm *FileSystemManager, buf *bytes.Buffer) error {
+				return fsm.ImportZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "/", TransferOptions{})
+			},
+			time.Second,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fsm := newTransferTree(t)
+			var buf bytes.Buffer
+			if err := c.export(fsm, &buf); err != nil {
+				t.Fatal(err)
+			}
+			imported := NewFileSystemManager()
+			if err := c.importer(imported, &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := listing(t, fsm, c.precision)
+			if got := listing(t, imported, c.precision); got != expected {
+				t.Errorf("Unexpected imported tree.\nExpected:\n%s\nGot:\n%s", expected, got)
+			}
+		})
+	}
+}
+
+// TestTransferFilters tests include and exclude globs
+func TestTransferFilters(t *testing.T) {
+	fsm := newTransferTree(t)
+
+	cases := []struct {
+		name     string
+		opts     TransferOptions
+		expected string
+	}{
+		{"all", TransferOptions{}, "docs docs/examples docs/examples/example1.go docs/guide.md docs/readme.md empty index.html templates templates/footer.tmpl templates/page.tmpl"},
+		{"include names", TransferOptions{Include: []string{"*.md", "*.html"}}, "docs docs/examples docs/guide.md docs/readme.md empty index.html templates"},
+		{"include paths", TransferOptions{Include: []string{"docs/*"}}, "docs docs/examples docs/guide.md docs/readme.md empty templates"},
+		{"exclude directory", TransferOptions{Exclude: []string{"examples", "templates"}}, "docs docs/guide.md docs/readme.md empty index.html"},
+		{"exclude path", TransferOptions{Exclude: []string{"docs/r*"}}, "docs docs/examples docs/examples/example1.go docs/guide.md empty index.html templates templates/footer.tmpl templates/page.tmpl"},
+		{"both", TransferOptions{Include: []string{"*.md"}, Exclude: []string{"guide.md", "empty"}}, "docs docs/examples docs/readme.md templates"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := fsm.ExportTar(&buf, "/", c.opts); err != nil {
+				t.Fatal(err)
+			}
+			exported := NewFileSystemManager()
+			if err := exported.ImportTar(&buf, "/", TransferOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Importing with the same options from disk selects the same nodes
+			dir := t.TempDir()
+			if err := fsm.ExportDir("/", dir, TransferOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			imported := NewFileSystemManager()
+			if err := imported.ImportDir(dir, "/", c.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, tree := range map[string]*FileSystemManager{"export": exported, "import": imported} {
+				var paths []string
+				fs.WalkDir(tree, ".", func(path string, d fs.DirEntry, err error) error {
+					if path != "." {
+						paths = append(paths, path)
+					}
+					return err
+				})
+				if got := strings.Join(paths, " "); got != c.expected {
+					t.Errorf("%s: expected %q, got %q", name, c.expected, got)
+				}
+			}
+		})
+	}
+
+	if err := fsm.ExportTar(&bytes.Buffer{}, "/", TransferOptions{Exclude: []string{"["}}); err == nil || !strings.Contains(err.Error(), "invalid glob") {
+		t.Errorf("Expected an invalid glob error, got %v", err)
+	}
+}
+
+// TestTransferSizeLimits tests that size limits stop transfers
+func TestTransferSizeLimits(t *testing.T) {
+	fsm := newTransferTree(t)
+
+	// The largest file has 57 bytes and the tree has 132 bytes in total
+	for _, opts := range []TransferOptions{{MaxFileSize: 56}, {MaxTotalSize: 131}} {
+		if err := fsm.ExportTar(&bytes.Buffer{}, "/", opts); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%+v: expected ErrTooLarge, got %v", opts, err)
+		}
+	}
+	if err := fsm.ExportTar(&bytes.Buffer{}, "/", TransferOptions{MaxFileSize: 57, MaxTotalSize: 132}); err != nil {
+		t.Errorf("Expected the export to fit the limits, got %v", err)
+	}
+
+	// A highly compressible zip entry is only read up to the limit
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("zeros")
+	w.Write(make([]byte, 10<<20))
+	zw.Close()
+	err := NewFileSystemManager().ImportZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "/", TransferOptions{MaxTotalSize: 1 << 20})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for a zip bomb, got %v", err)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "big"), make([]byte, 100), 0644)
+	if err := NewFileSystemManager().ImportDir(dir, "/", TransferOptions{MaxFileSize: 99}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge from disk, got %v", err)
+	}
+}
+
+// tarEntry is an entry of a hand-built tar archive
+type tarEntry struct {
+	name, content, link string
+	typeflag            byte
+}
+
+// buildTar returns a tar archive of the entries
+func buildTar(t *testing.T, entries ...tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Linkname: e.link, Typeflag: e.typeflag, Mode: 0644, Size: int64(len(e.content))}
+		if e.typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		} else {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// TestArchivePathTraversal tests that archive entries cannot escape the
+// destination
+func TestArchivePathTraversal(t *testing.T) {
+	cases := []struct {
+		name  string
+		entry tarEntry
+	}{
+		{"parent", tarEntry{name: "../evil", content: "x"}},
+		{"nested parent", tarEntry{name: "a/../../evil", content: "x"}},
+		{"absolute", tarEntry{name: "/etc/passwd", content: "x"}},
+		{"backslashes", tarEntry{name: `a\..\..\evil`, content: "x"}},
+		{"directory", tarEntry{name: "../evil/", typeflag: tar.TypeDir}},
+		{"symlink out", tarEntry{name: "a/link", link: "../../etc/passwd", typeflag: tar.TypeSymlink}},
+		{"absolute symlink", tarEntry{name: "link", link: "/etc/passwd", typeflag: tar.TypeSymlink}},
+		{"hard link out", tarEntry{name: "link", link: "../secret", typeflag: tar.TypeLink}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fsm := NewFileSystemManager()
+			err := fsm.ImportTar(buildTar(t, c.entry), "/sandbox", TransferOptions{Symlinks: FollowSymlinks})
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("Expected ErrUnsafePath, got %v", err)
+			}
+			if len(fsm.Root().Children()) != 1 {
+				t.Errorf("Expected nothing outside the sandbox, got %s", fsm.PrintFileSystem())
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("../evil")
+	zw.Close()
+	err := NewFileSystemManager().ImportZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "/", TransferOptions{})
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath from zip, got %v", err)
+	}
+}
+
+// TestArchiveSymlinks tests the symlink policies for archives
+func TestArchiveSymlinks(t *testing.T) {
+	entries := []tarEntry{
+		{name: "latest", link: "docs/readme.md", typeflag: tar.TypeSymlink},
+		{name: "chain", link: "latest", typeflag: tar.TypeSymlink},
+		{name: "docs/", typeflag: tar.TypeDir},
+		{name: "docs/readme.md", content: "# README"},
+		{name: "docs/up", link: "../docs/readme.md", typeflag: tar.TypeSymlink},
+		{name: "alias", link: "docs", typeflag: tar.TypeSymlink},
+		{name: "hard", link: "docs/readme.md", typeflag: tar.TypeLink},
+	}
+
+	fsm := NewFileSystemManager()
+	if err := fsm.ImportTar(buildTar(t, entries...), "/", TransferOptions{Symlinks: FollowSymlinks}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"latest", "chain", "docs/up", "alias/readme.md", "hard"} {
+		if content, err := fsm.ReadFile(path); err != nil || string(content) != "# README" {
+			t.Errorf("%s: expected a copy of the target, got %q, %v", path, content, err)
+		}
+	}
+
+	fsm = NewFileSystemManager()
+	if err := fsm.ImportTar(buildTar(t, entries...), "/", TransferOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := fs.Glob(fsm, "*"); strings.Join(names, " ") != "docs" {
+		t.Errorf("Expected links to be skipped, got %v", names)
+	}
+
+	err := NewFileSystemManager().ImportTar(buildTar(t, entries...), "/", TransferOptions{Symlinks: RejectSymlinks})
+	if !errors.Is(err, ErrSymlink) {
+		t.Errorf("Expected ErrSymlink, got %v", err)
+	}
+
+	for _, entry := range []tarEntry{
+		{name: "docs/loop", link: "..", typeflag: tar.TypeSymlink},
+		{name: "docs/self", link: ".", typeflag: tar.TypeSymlink},
+	} {
+		err = NewFileSystemManager().ImportTar(buildTar(t, entries[2], entry), "/", TransferOptions{Symlinks: FollowSymlinks})
+		if err == nil || !strings.Contains(err.Error(), "loop") {
+			t.Errorf("%s: expected a loop error, got %v", entry.name, err)
+		}
+	}
+
+	err = NewFileSystemManager().ImportTar(buildTar(t, tarEntry{name: "dangling", link: "missing", typeflag: tar.TypeSymlink}), "/", TransferOptions{Symlinks: FollowSymlinks})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+// TestArchiveSymlinkLimits tests that copies of link targets count
+// against the size limits and go through the filters
+func TestArchiveSymlinkLimits(t *testing.T) {
+	entries := []tarEntry{{name: "big", content: strings.Repeat("x", 1000)}}
+	for i := 0; i < 50; i++ {
+		entries = append(entries, tarEntry{name: fmt.Sprintf("link%02d", i), link: "big", typeflag: tar.TypeSymlink})
+	}
+	fsm := NewFileSystemManager()
+	err := fsm.ImportTar(buildTar(t, entries...), "/", TransferOptions{Symlinks: FollowSymlinks, MaxTotalSize: 5000})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+	if size := fsm.Root().Size(); size > 5000 {
+		t.Errorf("Expected at most 5000 bytes imported, got %d", size)
+	}
+
+	fsm = NewFileSystemManager()
+	err = fsm.ImportTar(buildTar(t,
+		tarEntry{name: "src/main.go", content: "package main"},
+		tarEntry{name: "src/secret.key", content: "secret"},
+		tarEntry{name: "src/keys/old.key", content: "old"},
+		tarEntry{name: "alias", link: "src", typeflag: tar.TypeSymlink},
+	), "/", TransferOptions{Symlinks: FollowSymlinks, Exclude: []string{"alias/*.key", "alias/keys"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := fs.Glob(fsm, "alias/*"); strings.Join(names, " ") != "alias/main.go" {
+		t.Errorf("Expected excluded files to stay out of the linked directory, got %v", names)
+	}
+	if _, err := fsm.Stat("src/secret.key"); err != nil {
+		t.Errorf("Expected the target itself to be imported, got %v", err)
+	}
+}
+
+// TestDiskSymlinks tests the symlink policies for disk imports and that
+// exports do not write through links
+func TestDiskSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644)
+
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+	os.WriteFile(filepath.Join(dir, "docs", "readme.md"), []byte("# README"), 0644)
+	if err := os.Symlink("docs/readme.md", filepath.Join(dir, "latest")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	os.Symlink("docs", filepath.Join(dir, "alias"))
+
+	fsm := NewFileSystemManager()
+	if err := fsm.ImportDir(dir, "/", TransferOptions{Symlinks: FollowSymlinks}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"latest", "alias/readme.md"} {
+		if content, err := fsm.ReadFile(path); err != nil || string(content) != "# README" {
+			t.Errorf("%s: expected a copy of the target, got %q, %v", path, content, err)
+		}
+	}
+
+	fsm = NewFileSystemManager()
+	if err := fsm.ImportDir(dir, "/", TransferOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := fs.Glob(fsm, "*"); strings.Join(names, " ") != "docs" {
+		t.Errorf("Expected links to be skipped, got %v", names)
+	}
+
+	if err := NewFileSystemManager().ImportDir(dir, "/", TransferOptions{Symlinks: RejectSymlinks}); !errors.Is(err, ErrSymlink) {
+		t.Errorf("Expected ErrSymlink, got %v", err)
+	}
+
+	os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "docs", "secret"))
+	if err := NewFileSystemManager().ImportDir(dir, "/", TransferOptions{Symlinks: FollowSymlinks}); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath for a link outside, got %v", err)
+	}
+	os.Remove(filepath.Join(dir, "docs", "secret"))
+
+	os.Symlink("..", filepath.Join(dir, "docs", "up"))
+	err := NewFileSystemManager().ImportDir(dir, "/", TransferOptions{Symlinks: FollowSymlinks})
+	if err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("Expected a loop error, got %v", err)
+	}
+
+	// Exporting does not follow a link in the destination
+	dest := t.TempDir()
+	os.Symlink(outside, filepath.Join(dest, "docs"))
+	if err := newTransferTree(t).ExportDir("/", dest, TransferOptions{}); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath when exporting through a link, got %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 1 {
+		t.Errorf("Expected nothing written outside, got %d entries", len(entries))
+	}
+}
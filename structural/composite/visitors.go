@@ -130,6 +130,11 @@ func (v *SearchVisitor) VisitDirectory(directory *Directory) error {
 		return nil
 	}
 	
+	// Directories have no content to match
+	if v.ContentMatch != "" {
+		return nil
+	}
+	
 	// Check size constraints if needed
 	if v.BySize {
 		dirSize := directory.Size()
@@ -144,6 +149,8 @@ func (v *SearchVisitor) VisitDirectory(directory *Directory) error {
 }
 
 // PermissionUpdaterVisitor updates permissions on nodes matching specific criteria.
+// If User is set, only the nodes that user owns are changed and the others
+// are collected in Denied.
 type PermissionUpdaterVisitor struct {
 	PermissionsToAdd    Permission
 	PermissionsToRemove Permission
@@ -151,7 +158,9 @@ type PermissionUpdaterVisitor struct {
 	AffectDirectories   bool
 	Recursive           bool
 	Extension           string
+	User                *User
 	Modified            int
+	Denied              []FileSystemNode
 }
 
 // NewPermissionUpdaterVisitor creates a new PermissionUpdaterVisitor
@@ -168,42 +177,13 @@ func NewPermissionUpdaterVisitor(add, remove Permission, affectFiles, affectDirs
 
 // VisitFile processes a file node
 func (v *PermissionUpdaterVisitor) VisitFile(file *File) error {
-	// Only proceed if we're affecting files
-	if !v.AffectFiles {
-		return nil
-	}
-	
-	// Check extension if specified
-	if v.Extension != "" && !strings.HasSuffix(file.Name(), v.Extension) {
-		return nil
-	}
-	
-	// Update permissions
-	currentPerms := file.GetPermissions()
-	newPerms := (currentPerms | v.PermissionsToAdd) &^ v.PermissionsToRemove
-	
-	// Only count as modified if permissions actually changed
-	if currentPerms != newPerms {
-		file.SetPermissions(newPerms)
-		v.Modified++
-	}
-	
+	v.update(file)
 	return nil
 }
 
 // VisitDirectory processes a directory node
 func (v *PermissionUpdaterVisitor) VisitDirectory(directory *Directory) error {
-	// Update permissions on this directory if applicable
-	if v.AffectDirectories {
-		currentPerms := directory.GetPermissions()
-		newPerms := (currentPerms | v.PermissionsToAdd) &^ v.PermissionsToRemove
-		
-		// Only count as modified if permissions actually changed
-		if currentPerms != newPerms {
-			directory.SetPermissions(newPerms)
-			v.Modified++
-		}
-	}
+	v.update(directory)
 	
 	// If not recursive, skip the children by returning an error to stop traversal
 	if !v.Recursive {
@@ -213,6 +193,39 @@ func (v *PermissionUpdaterVisitor) VisitDirectory(directory *Directory) error {
 	return nil
 }
 
+// update changes the permissions of a node if it matches the criteria
+func (v *PermissionUpdaterVisitor) update(node FileSystemNode) {
+	// Only proceed if we're affecting this kind of node
+	if node.IsDirectory() && !v.AffectDirectories {
+		return
+	}
+	if !node.IsDirectory() {
+		if !v.AffectFiles {
+			return
+		}
+		
+		// Check extension if specified
+		if v.Extension != "" && !strings.HasSuffix(node.Name(), v.Extension) {
+			return
+		}
+	}
+	
+	// Update permissions
+	currentPerms := node.GetPermissions()
+	newPerms := (currentPerms | v.PermissionsToAdd) &^ v.PermissionsToRemove
+	
+	// Only count as modified if permissions actually changed
+	if currentPerms == newPerms {
+		return
+	}
+	if v.User != nil && !v.User.Owns(node) {
+		v.Denied = append(v.Denied, node)
+		return
+	}
+	node.SetPermissions(newPerms)
+	v.Modified++
+}
+
 // StatisticsVisitor collects statistics about the file system structure.
 type StatisticsVisitor struct {
 	FileCount      int
@@ -263,7 +263,7 @@ func TestUtilityDecorators(t *testing.T) {
 // TestChainOfDecorators tests chaining multiple decorators together
 func TestChainOfDecorators(t *testing.T) {
 	// Create a chain of decorators
-	processor := NewBasicTextProcessor()
+	var processor TextProcessor = NewBasicTextProcessor()
 	processor = NewHighlightingDecorator(processor, "important", "_", "_")
 	processor = NewMarkdownFormattingDecorator(processor)
 	processor = NewLoggingDecorator(processor, false, false, false, 0)
@@ -288,7 +288,7 @@ func TestChainOfDecorators(t *testing.T) {
 	// Test that the chain order is correctly maintained
 	if textDecorator, ok := processor.(*LoggingDecorator); ok {
 		if formattingDecorator, ok := textDecorator.wrapped.(*HighlightingDecorator); ok {
-			if basicProcessor, ok := formattingDecorator.wrapped.(*MarkdownFormattingDecorator); ok {
+			if basicProcessor, ok := formattingDecorator.wrapped.(*FormattingDecorator); ok {
 				// Chain is in wrong order
 				t.Errorf("Decorator chain is in wrong order: %T -> %T -> %T", 
 					textDecorator, formattingDecorator, basicProcessor)
@@ -307,22 +307,11 @@ func TestGetProcessingChain(t *testing.T) {
 		"test", "*", "*",
 	)
 	
-	chain := decorated.(*HighlightingDecorator).GetProcessingChain()
-	
-	// Check that all decorators are listed in the chain
-	if !strings.Contains(chain, "Text Highlighter") ||
-	   !strings.Contains(chain, "Markdown Formatter") ||
-	   !strings.Contains(chain, "Logging Processor") ||
-	   !strings.Contains(chain, "Basic Text Processor") {
-		t.Errorf("Processing chain doesn't list all decorators: %s", chain)
-	}
-	
-	// Check the order of the chain
-	if !strings.HasPrefix(chain, "Text Highlighter") {
-		t.Errorf("Processing chain has incorrect order: %s", chain)
-	}
+	chain := decorated.GetProcessingChain()
 	
-	if !strings.HasSuffix(chain, "Basic Text Processor") {
-		t.Errorf("Processing chain doesn't end with the base processor: %s", chain)
+	// The chain is a pipeline spec listing the decorators in the order
+	// they process text
+	if chain != "log | markdown | highlight(test,start=*,end=*)" {
+		t.Errorf("Unexpected processing chain: %s", chain)
 	}
 }
@@ -49,7 +49,7 @@ func main() {
 	// Example 3: Chaining Multiple Decorators
 	fmt.Println("=== Example 3: Chaining Multiple Decorators ===")
 	// Start with the basic processor
-	processor := basicProcessor
+	var processor decorator.TextProcessor = basicProcessor
 	
 	// Add logging
 	processor = decorator.NewLoggingDecorator(processor, true, true, true, 100)
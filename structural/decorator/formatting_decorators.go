@@ -3,6 +3,7 @@ package decorator
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -20,6 +21,7 @@ func NewHTMLFormattingDecorator(processor TextProcessor) *FormattingDecorator {
 			wrapped:     processor,
 			name:        "HTML Formatter",
 			description: "Formats text as HTML with proper tags",
+			spec:        "html",
 		},
 		formatType: "html",
 	}
@@ -32,6 +34,7 @@ func NewMarkdownFormattingDecorator(processor TextProcessor) *FormattingDecorato
 			wrapped:     processor,
 			name:        "Markdown Formatter",
 			description: "Formats text as Markdown with proper syntax",
+			spec:        "markdown",
 		},
 		formatType: "markdown",
 	}
@@ -44,6 +47,7 @@ func NewPlainTextFormattingDecorator(processor TextProcessor) *FormattingDecorat
 			wrapped:     processor,
 			name:        "Plain Text Formatter",
 			description: "Strips formatting and converts to plain text",
+			spec:        "plain",
 		},
 		formatType: "plain",
 	}
@@ -73,10 +77,10 @@ func (f *FormattingDecorator) Process(text string) (string, error) {
 
 // formatAsHTML converts text to HTML format.
 func (f *FormattingDecorator) formatAsHTML(text string) string {
-	// Convert paragraphs
+	// Convert paragraphs, leaving headings to be converted below
 	paragraphs := strings.Split(text, "\n\n")
 	for i, p := range paragraphs {
-		if p != "" {
+		if p != "" && !strings.HasPrefix(p, "#") {
 			paragraphs[i] = "<p>" + p + "</p>"
 		}
 	}
@@ -125,12 +129,20 @@ func (f *FormattingDecorator) formatAsMarkdown(text string) string {
 		text = strings.Join(lines, "\n")
 	}
 	
-	// Add emphasis to important words
+	// Add emphasis to important words outside headings, keeping their case
 	importantWords := []string{"important", "note", "warning", "caution", "danger"}
-	for _, word := range importantWords {
-		re := regexp.MustCompile(`(?i)\b` + word + `\b`)
-		text = re.ReplaceAllString(text, "**"+word+"**")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, word := range importantWords {
+			re := regexp.MustCompile(`(?i)\b` + word + `\b`)
+			line = re.ReplaceAllString(line, "**${0}**")
+		}
+		lines[i] = line
 	}
+	text = strings.Join(lines, "\n")
 	
 	// Convert simple bullet points
 	text = regexp.MustCompile(`(?m)^(\*)\s+(.+)$`).ReplaceAllString(text, "- $2")
@@ -192,6 +204,7 @@ func NewHighlightingDecorator(processor TextProcessor, pattern, startTag, endTag
 			wrapped:     processor,
 			name:        "Text Highlighter",
 			description: fmt.Sprintf("Highlights text matching pattern '%s'", pattern),
+			spec:        stageSpec("highlight", highlightParams, map[string]string{"pattern": pattern, "start": startTag, "end": endTag}),
 		},
 		pattern:        pattern,
 		highlightStart: startTag,
@@ -214,7 +227,7 @@ func (h *HighlightingDecorator) Process(text string) (string, error) {
 		return processedText, fmt.Errorf("invalid highlight pattern: %w", err)
 	}
 
-	return re.ReplaceAllString(processedText, h.highlightStart+"$0"+h.highlightEnd), nil
+	return re.ReplaceAllString(processedText, h.highlightStart+"${0}"+h.highlightEnd), nil
 }
 
 // IndentationDecorator is a concrete decorator that indents text.
@@ -231,6 +244,7 @@ func NewIndentationDecorator(processor TextProcessor, indentation string, indent
 			wrapped:     processor,
 			name:        "Indentation Processor",
 			description: fmt.Sprintf("Indents each line with '%s'", indentation),
+			spec:        stageSpec("indent", indentParams, map[string]string{"prefix": indentation, "first": strconv.FormatBool(indentFirstLine)}),
 		},
 		indentation:    indentation,
 		indentFirstLine: indentFirstLine,
@@ -0,0 +1,285 @@
+package decorator
+
+import (
+	"html"
+	"strings"
+)
+
+// htmlTokenKind is the kind of an htmlToken.
+type htmlTokenKind int
+
+const (
+	textToken htmlTokenKind = iota
+	startTagToken
+	endTagToken
+	commentToken
+	doctypeToken
+)
+
+// htmlToken is a piece of an HTML document.
+type htmlToken struct {
+	kind        htmlTokenKind
+	data        string // Text with entities decoded, lower case tag name, or comment
+	attrs       []htmlAttr
+	selfClosing bool
+}
+
+// htmlAttr is an attribute of a tag, with entities in its value decoded.
+type htmlAttr struct {
+	name, value string
+}
+
+// rawTextTags are the elements whose content is text up to their end tag,
+// even if it looks like markup. The content of the RCDATA elements,
+// textarea and title, may contain entities.
+var rawTextTags = map[string]bool{
+	"script": true, "style": true, "xmp": true, "iframe": true,
+	"noembed": true, "noframes": true, "noscript": true, "plaintext": true,
+	"textarea": true, "title": true,
+}
+
+// htmlTokenizer splits HTML into tokens the way browsers do, so that what
+// the sanitizer sees is what a browser would parse. It never fails: like a
+// browser, it reads malformed markup as text, comments or tags.
+type htmlTokenizer struct {
+	s       string
+	pos     int
+	rawText string // Element whose raw text content comes next
+}
+
+// newHTMLTokenizer returns a tokenizer of s.
+func newHTMLTokenizer(s string) *htmlTokenizer {
+	return &htmlTokenizer{s: s}
+}
+
+// next returns the next token, or false at the end of the input.
+func (t *htmlTokenizer) next() (htmlToken, bool) {
+	for t.pos < len(t.s) {
+		if t.rawText != "" {
+			return t.readRawText(), true
+		}
+
+		// Text runs up to the next < that starts markup
+		start, i := t.pos, t.pos
+		for {
+			j := strings.IndexByte(t.s[i:], '<')
+			if j < 0 {
+				i = len(t.s)
+				break
+			}
+			if i += j; t.startsMarkup(i) {
+				break
+			}
+			i++
+		}
+		if i > start {
+			t.pos = i
+			return t.text(start, i), true
+		}
+
+		// A tag cut off by the end of the input is dropped
+		if token, ok := t.readMarkup(); ok {
+			return token, true
+		}
+	}
+	return htmlToken{}, false
+}
+
+// text returns the text between start and end as a token.
+func (t *htmlTokenizer) text(start, end int) htmlToken {
+	return htmlToken{kind: textToken, data: html.UnescapeString(t.s[start:end])}
+}
+
+// startsMarkup reports whether the < at i starts a tag, comment or doctype
+// rather than being text.
+func (t *htmlTokenizer) startsMarkup(i int) bool {
+	if i+1 >= len(t.s) {
+		return false
+	}
+	switch c := t.s[i+1]; {
+	case isASCIILetter(c), c == '!', c == '?':
+		return true
+	case c == '/':
+		return i+2 < len(t.s)
+	default:
+		return false
+	}
+}
+
+// readMarkup reads the markup at the current position, which is a <.
+func (t *htmlTokenizer) readMarkup() (htmlToken, bool) {
+	rest := t.s[t.pos+1:]
+	switch {
+	case strings.HasPrefix(rest, "!--"):
+		t.pos += 4
+		// A comment may be closed early by --> or by > right after <!--
+		body := t.s[t.pos:]
+		if strings.HasPrefix(body, ">") || strings.HasPrefix(body, "->") {
+			t.pos += strings.IndexByte(body, '>') + 1
+			return htmlToken{kind: commentToken}, true
+		}
+		end := strings.Index(body, "-->")
+		if end < 0 {
+			t.pos = len(t.s)
+			return htmlToken{kind: commentToken, data: body}, true
+		}
+		t.pos += end + 3
+		return htmlToken{kind: commentToken, data: body[:end]}, true
+	case strings.HasPrefix(strings.ToLower(rest), "!doctype"):
+		return htmlToken{kind: doctypeToken, data: t.bogusComment(9)}, true
+	case rest[0] == '!' || rest[0] == '?':
+		return htmlToken{kind: commentToken, data: t.bogusComment(2)}, true
+	case rest[0] == '/' && rest[1] == '>':
+		// </> is ignored
+		t.pos += 3
+		return htmlToken{kind: commentToken}, true
+	case rest[0] == '/' && !isASCIILetter(rest[1]):
+		// </ followed by anything but a letter is a bogus comment
+		return htmlToken{kind: commentToken, data: t.bogusComment(2)}, true
+	case rest[0] == '/':
+		t.pos += 2
+		token, ok := t.readTag(endTagToken)
+		return token, ok
+	default:
+		t.pos++
+		token, ok := t.readTag(startTagToken)
+		// Browsers ignore the slash of <script/>, so its content is still raw
+		if ok && rawTextTags[token.data] {
+			t.rawText = token.data
+		}
+		return token, ok
+	}
+}
+
+// bogusComment reads up to the next > after skipping n bytes and returns
+// what it read.
+func (t *htmlTokenizer) bogusComment(n int) string {
+	start := min(t.pos+n, len(t.s))
+	end := strings.IndexByte(t.s[start:], '>')
+	if end < 0 {
+		t.pos = len(t.s)
+		return t.s[start:]
+	}
+	t.pos = start + end + 1
+	return t.s[start : start+end]
+}
+
+// readTag reads the name and attributes of a tag. It returns false if the
+// input ends before the tag does.
+func (t *htmlTokenizer) readTag(kind htmlTokenKind) (htmlToken, bool) {
+	token := htmlToken{kind: kind}
+	start := t.pos
+	for t.pos < len(t.s) && !isHTMLSpace(t.s[t.pos]) && t.s[t.pos] != '/' && t.s[t.pos] != '>' {
+		t.pos++
+	}
+	token.data = strings.ToLower(strings.ReplaceAll(t.s[start:t.pos], "\x00", "�"))
+
+	seen := map[string]bool{}
+	for {
+		for t.pos < len(t.s) && (isHTMLSpace(t.s[t.pos]) || t.s[t.pos] == '/') {
+			token.selfClosing = t.s[t.pos] == '/'
+			t.pos++
+		}
+		if t.pos >= len(t.s) {
+			return token, false
+		}
+		if t.s[t.pos] == '>' {
+			t.pos++
+			if kind == endTagToken {
+				token.attrs, token.selfClosing = nil, false
+			}
+			return token, true
+		}
+		token.selfClosing = false
+
+		// An attribute name may start with =, but not contain one
+		start := t.pos
+		t.pos++
+		for t.pos < len(t.s) && !isHTMLSpace(t.s[t.pos]) && !strings.ContainsRune("/>=", rune(t.s[t.pos])) {
+			t.pos++
+		}
+		attr := htmlAttr{name: strings.ToLower(t.s[start:t.pos])}
+		t.skipSpace()
+		if t.pos < len(t.s) && t.s[t.pos] == '=' {
+			t.pos++
+			t.skipSpace()
+			attr.value = t.readAttrValue()
+		}
+		// Browsers keep the first of repeated attributes
+		if !seen[attr.name] {
+			seen[attr.name] = true
+			token.attrs = append(token.attrs, attr)
+		}
+	}
+}
+
+// readAttrValue reads a quoted or unquoted attribute value.
+func (t *htmlTokenizer) readAttrValue() string {
+	if t.pos >= len(t.s) {
+		return ""
+	}
+	if quote := t.s[t.pos]; quote == '"' || quote == '\'' {
+		t.pos++
+		end := strings.IndexByte(t.s[t.pos:], quote)
+		if end < 0 {
+			t.pos = len(t.s)
+			return ""
+		}
+		value := t.s[t.pos : t.pos+end]
+		t.pos += end + 1
+		return html.UnescapeString(value)
+	}
+	start := t.pos
+	for t.pos < len(t.s) && !isHTMLSpace(t.s[t.pos]) && t.s[t.pos] != '>' {
+		t.pos++
+	}
+	return html.UnescapeString(t.s[start:t.pos])
+}
+
+// readRawText reads the content of a raw text element up to its end tag.
+func (t *htmlTokenizer) readRawText() htmlToken {
+	name := t.rawText
+	t.rawText = ""
+	start := t.pos
+	for i := start; name != "plaintext"; {
+		end := strings.Index(t.s[i:], "</")
+		if end < 0 {
+			break
+		}
+		i += end
+		after := i + 2 + len(name)
+		if after <= len(t.s) && strings.EqualFold(t.s[i+2:after], name) &&
+			(after == len(t.s) || isHTMLSpace(t.s[after]) || t.s[after] == '/' || t.s[after] == '>') {
+			t.pos = i
+			return t.rawToken(name, t.s[start:i])
+		}
+		i += 2
+	}
+	t.pos = len(t.s)
+	return t.rawToken(name, t.s[start:])
+}
+
+// rawToken returns the content of a raw text element as a text token.
+func (t *htmlTokenizer) rawToken(name, text string) htmlToken {
+	if name == "textarea" || name == "title" {
+		text = html.UnescapeString(text)
+	}
+	return htmlToken{kind: textToken, data: text}
+}
+
+// skipSpace moves past white space.
+func (t *htmlTokenizer) skipSpace() {
+	for t.pos < len(t.s) && isHTMLSpace(t.s[t.pos]) {
+		t.pos++
+	}
+}
+
+// isHTMLSpace reports whether c is white space in HTML.
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// isASCIILetter reports whether c is an ASCII letter.
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
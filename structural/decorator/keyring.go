@@ -0,0 +1,226 @@
+package decorator
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Envelopes written by Keyring.Seal look like
+//
+//	v1:<key ID>:<base64 of salt, nonce and ciphertext>
+//
+// The text before the last colon names the format version and the key, and
+// is authenticated along with the ciphertext. Base64 has no colons, so
+// envelopes cannot be confused with the output of the legacy modes.
+const (
+	envelopeVersion = "v1"
+	saltSize        = 16
+	nonceSize       = 12 // Standard GCM nonce
+	tagSize         = 16
+	keySize         = 32 // AES-256
+)
+
+// hkdfInfo binds derived keys to their use.
+const hkdfInfo = "go-patterns decorator AES-256-GCM envelope v1"
+
+var (
+	// ErrUnknownKey is returned when an envelope names a key that is not in
+	// the keyring.
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrInvalidEnvelope is returned when text is not an envelope of a
+	// supported version.
+	ErrInvalidEnvelope = errors.New("invalid encryption envelope")
+	// ErrAuthentication is returned when an envelope has been tampered with
+	// or was sealed with a different key.
+	ErrAuthentication = errors.New("message authentication failed")
+)
+
+// Keyring holds the passphrases used for authenticated encryption. Text is
+// sealed with the primary key, and envelopes sealed with any key still in
+// the keyring can be opened, so keys can be rotated without re-encrypting
+// existing data. A Keyring is safe for concurrent use.
+//
+// Each envelope gets a key of its own, derived from the passphrase with
+// HKDF-SHA256 and a random salt.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	primary string
+}
+
+// NewKeyring creates an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string][]byte)}
+}
+
+// Add adds a passphrase under id. The first key added becomes the primary
+// key. IDs are stored in envelopes in the clear, so they must not contain
+// colons or white space, and should not reveal anything about the key.
+func (k *Keyring) Add(id, passphrase string) error {
+	if id == "" || len(id) > 255 || strings.ContainsAny(id, ": \t\r\n") {
+		return fmt.Errorf("invalid key ID %q", id)
+	}
+	if passphrase == "" {
+		return fmt.Errorf("empty passphrase for key %q", id)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, exists := k.keys[id]; exists {
+		return fmt.Errorf("key %q already exists", id)
+	}
+	k.keys[id] = []byte(passphrase)
+	if k.primary == "" {
+		k.primary = id
+	}
+	return nil
+}
+
+// Rotate adds a passphrase under id and makes it the primary key. Older
+// keys stay in the keyring to open existing envelopes.
+func (k *Keyring) Rotate(id, passphrase string) error {
+	if err := k.Add(id, passphrase); err != nil {
+		return err
+	}
+	return k.SetPrimary(id)
+}
+
+// SetPrimary makes the key called id the one that new text is sealed with.
+func (k *Keyring) SetPrimary(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	k.primary = id
+	return nil
+}
+
+// Remove removes a key that is no longer needed. Envelopes sealed with it
+// can no longer be opened. The primary key cannot be removed.
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	if id == k.primary {
+		return fmt.Errorf("cannot remove primary key %q", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// Primary returns the ID of the primary key.
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// IDs returns the IDs of the keys in the keyring, sorted.
+func (k *Keyring) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Seal encrypts and authenticates text with the primary key using
+// AES-256-GCM and returns the envelope.
+func (k *Keyring) Seal(text string) (string, error) {
+	k.mu.RLock()
+	id, passphrase := k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+	if id == "" {
+		return "", fmt.Errorf("keyring has no keys")
+	}
+
+	// The payload is the salt, the nonce and the sealed text
+	payload := make([]byte, saltSize+nonceSize, saltSize+nonceSize+len(text)+tagSize)
+	if _, err := io.ReadFull(rand.Reader, payload); err != nil {
+		return "", err
+	}
+	aead, err := newGCM(passphrase, payload[:saltSize])
+	if err != nil {
+		return "", err
+	}
+	header := envelopeVersion + ":" + id
+	payload = aead.Seal(payload, payload[saltSize:], []byte(text), []byte(header))
+	return header + ":" + base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// Open checks and decrypts an envelope returned by Seal.
+func (k *Keyring) Open(envelope string) (string, error) {
+	sep := strings.LastIndexByte(envelope, ':')
+	if sep < 0 {
+		return "", fmt.Errorf("%w: missing header", ErrInvalidEnvelope)
+	}
+	header := envelope[:sep]
+	version, id, ok := strings.Cut(header, ":")
+	if !ok {
+		return "", fmt.Errorf("%w: missing key ID", ErrInvalidEnvelope)
+	}
+	if version != envelopeVersion {
+		return "", fmt.Errorf("%w: unsupported version %q", ErrInvalidEnvelope, version)
+	}
+
+	k.mu.RLock()
+	passphrase, known := k.keys[id]
+	k.mu.RUnlock()
+	if !known {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope[sep+1:])
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	if len(payload) < saltSize+nonceSize+tagSize {
+		return "", fmt.Errorf("%w: too short", ErrInvalidEnvelope)
+	}
+	aead, err := newGCM(passphrase, payload[:saltSize])
+	if err != nil {
+		return "", err
+	}
+	nonce, ciphertext := payload[saltSize:saltSize+nonceSize], payload[saltSize+nonceSize:]
+	text, err := aead.Open(nil, nonce, ciphertext, []byte(header))
+	if err != nil {
+		return "", ErrAuthentication
+	}
+	return string(text), nil
+}
+
+// isEnvelope reports whether text looks like an envelope rather than the
+// output of a legacy mode.
+func isEnvelope(text string) bool {
+	return strings.ContainsRune(text, ':')
+}
+
+// newGCM returns AES-256-GCM with the key derived from passphrase and salt
+// using HKDF-SHA256.
+func newGCM(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, passphrase, salt, hkdfInfo, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
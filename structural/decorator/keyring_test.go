@@ -0,0 +1,198 @@
+package decorator
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestEnvelopeCompatibility checks that an envelope sealed by an earlier
+// version still opens, so key derivation has not changed
+func TestEnvelopeCompatibility(t *testing.T) {
+	keyring := NewKeyring()
+	if err := keyring.Add("2024", "old passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	decrypt := NewKeyringDecryptionDecorator(NewBasicTextProcessor(), keyring, "", "")
+
+	envelope := "v1:2024:LYjZ03sDidqYSTvrqiq9k1AwVkVq1mOGU7IvFET4s9n+4jzLjtwd4sapzA+Qohg4AY4ZdFLQOCWvg+vRrAZcFSR+aCbq"
+	if got, err := decrypt.Process(envelope); err != nil || got != "sealed before crypto/hkdf" {
+		t.Errorf("Expected the envelope to open, got %q, %v", got, err)
+	}
+}
+
+// TestKeyringRotation tests that envelopes sealed with older keys can still
+// be opened after the primary key changes
+func TestKeyringRotation(t *testing.T) {
+	basic := NewBasicTextProcessor()
+	keyring := NewKeyring()
+	if err := keyring.Add("2024", "old passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	encrypt := NewKeyringEncryptionDecorator(basic, keyring)
+	decrypt := NewKeyringDecryptionDecorator(basic, keyring, "", "")
+
+	old, err := encrypt.Process("written in 2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(old, "v1:2024:") {
+		t.Errorf("Unexpected envelope: %s", old)
+	}
+
+	if err := keyring.Rotate("2025", "new passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	current, err := encrypt.Process("written in 2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(current, "v1:2025:") {
+		t.Errorf("New text was not sealed with the primary key: %s", current)
+	}
+
+	for envelope, expected := range map[string]string{old: "written in 2024", current: "written in 2025"} {
+		if got, err := decrypt.Process(envelope); err != nil || got != expected {
+			t.Errorf("Decryption returned %q, %v", got, err)
+		}
+	}
+
+	// Sealing the same text twice gives different envelopes
+	if again, _ := encrypt.Process("written in 2025"); again == current {
+		t.Error("Envelopes repeat")
+	}
+
+	// Once the old key is removed, its envelopes can no longer be opened
+	if err := keyring.Remove("2025"); err == nil {
+		t.Error("Expected the primary key to be kept")
+	}
+	if err := keyring.Remove("2024"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decrypt.Process(old); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	if ids := keyring.IDs(); len(ids) != 1 || ids[0] != "2025" || keyring.Primary() != "2025" {
+		t.Errorf("Unexpected keys: %v, primary %s", ids, keyring.Primary())
+	}
+
+	// Keys are checked when they are added
+	for _, c := range [][2]string{{"2025", "again"}, {"", "passphrase"}, {"a:b", "passphrase"}, {"new", ""}} {
+		if err := keyring.Add(c[0], c[1]); err == nil {
+			t.Errorf("Expected Add(%q, %q) to fail", c[0], c[1])
+		}
+	}
+	if err := keyring.SetPrimary("missing"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+// TestKeyringTampering tests that changes to envelopes are detected
+func TestKeyringTampering(t *testing.T) {
+	keyring := NewKeyring()
+	keyring.Add("a", "first passphrase")
+	keyring.Add("b", "second passphrase")
+	envelope, err := keyring.Seal("Transfer 100 to Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := envelope[:strings.LastIndexByte(envelope, ':')+1]
+	payload, _ := base64.StdEncoding.DecodeString(envelope[len(header):])
+
+	flipped := func(i int) string {
+		changed := append([]byte(nil), payload...)
+		changed[i] ^= 1
+		return header + base64.StdEncoding.EncodeToString(changed)
+	}
+
+	cases := []struct {
+		name     string
+		envelope string
+		err      error
+	}{
+		{"ciphertext", flipped(len(payload) - 20), ErrAuthentication},
+		{"tag", flipped(len(payload) - 1), ErrAuthentication},
+		{"nonce", flipped(saltSize), ErrAuthentication},
+		{"salt", flipped(0), ErrAuthentication},
+		{"key ID", strings.Replace(envelope, "v1:a:", "v1:b:", 1), ErrAuthentication},
+		{"unknown key", strings.Replace(envelope, "v1:a:", "v1:c:", 1), ErrUnknownKey},
+		{"version", strings.Replace(envelope, "v1:", "v2:", 1), ErrInvalidEnvelope},
+		{"truncated", envelope[:len(header)+20], ErrInvalidEnvelope},
+		{"not base64", header + "!!!", ErrInvalidEnvelope},
+		{"no header", envelope[len(header):], ErrInvalidEnvelope},
+		{"no key ID", "v1:" + envelope[len(header):], ErrInvalidEnvelope},
+	}
+	for _, c := range cases {
+		if text, err := keyring.Open(c.envelope); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %q, %v", c.name, c.err, text, err)
+		}
+	}
+
+	if text, err := keyring.Open(envelope); err != nil || text != "Transfer 100 to Alice" {
+		t.Errorf("Open returned %q, %v", text, err)
+	}
+	if _, err := NewKeyring().Seal("text"); err == nil {
+		t.Error("Expected an empty keyring to fail")
+	}
+}
+
+// TestLegacyDecryption tests that data written in the legacy modes can be
+// read by a gcm decrypter, while envelopes are never read as legacy data
+func TestLegacyDecryption(t *testing.T) {
+	basic := NewBasicTextProcessor()
+	keyring := NewKeyring()
+	keyring.Add("k1", "new passphrase")
+
+	for _, mode := range []string{"aes", "base64", "rot13"} {
+		legacy, err := NewEncryptionDecorator(basic, "old passphrase", mode).Process("Legacy record")
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypt := NewKeyringDecryptionDecorator(basic, keyring, "old passphrase", mode)
+		if got, err := decrypt.Process(legacy); err != nil || got != "Legacy record" {
+			t.Errorf("%s: legacy decryption returned %q, %v", mode, got, err)
+		}
+
+		// A tampered envelope fails rather than being read in a legacy mode
+		envelope, _ := keyring.Seal("Current record")
+		if _, err := decrypt.Process(envelope[:len(envelope)-4] + "AAA="); !errors.Is(err, ErrAuthentication) {
+			t.Errorf("%s: expected ErrAuthentication, got %v", mode, err)
+		}
+	}
+
+	// Without a legacy mode, only envelopes are accepted
+	legacy, _ := NewEncryptionDecorator(basic, "old passphrase", "aes").Process("Legacy record")
+	if _, err := NewKeyringDecryptionDecorator(basic, keyring, "", "").Process(legacy); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Expected ErrInvalidEnvelope, got %v", err)
+	}
+}
+
+// TestGCMMode tests the gcm mode of the passphrase-based constructors and
+// pipeline specs
+func TestGCMMode(t *testing.T) {
+	basic := NewBasicTextProcessor()
+	text := "Confidential: the launch is on Monday."
+
+	encrypted, err := NewEncryptionDecorator(basic, "secret", "gcm").Process(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewDecryptionDecorator(basic, "secret", "gcm").Process(encrypted); err != nil || got != text {
+		t.Errorf("Decryption returned %q, %v", got, err)
+	}
+	if _, err := NewDecryptionDecorator(basic, "wrong", "gcm").Process(encrypted); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Expected ErrAuthentication with the wrong key, got %v", err)
+	}
+
+	processor := parseSpec(t, "compress(gzip) | encrypt(gcm,key=$KEY) | decrypt(gcm,key=$KEY) | decompress(gzip)")
+	if got, err := processor.Process(text); err != nil || got != text {
+		t.Errorf("Pipeline returned %q, %v", got, err)
+	}
+	if got := processorSpec(NewKeyringEncryptionDecorator(basic, NewKeyring())); got != "encrypt(gcm,key=$KEY)" {
+		t.Errorf("Unexpected processing chain: %s", got)
+	}
+	if _, err := NewDefaultRegistry().Parse("encrypt(gcm)", testVars); err == nil {
+		t.Error("Expected gcm without a key to be rejected")
+	}
+}
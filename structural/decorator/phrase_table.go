@@ -0,0 +1,442 @@
+package decorator
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PhraseTable is a Translator that looks phrases up in a table, such as a
+// glossary kept in a CSV or TMX file.
+//
+// At each point in the text it translates the longest phrase in the table,
+// so "thank you very much" is preferred to "thank you". Phrases are matched
+// regardless of case, and their translations follow the case of the text:
+// "Hello", "hello" and "HELLO" become "Bonjour", "bonjour" and "BONJOUR".
+// Text that is not in the table, punctuation and white space are kept as
+// they are. Placeholders such as {name} are never translated; phrases may
+// contain them, as in "welcome {name}", provided that their translations
+// contain the same ones.
+//
+// A PhraseTable is safe for concurrent use.
+type PhraseTable struct {
+	mu    sync.RWMutex
+	pairs map[[2]string]*phraseSet
+}
+
+// phraseSet holds the phrases of a language pair.
+type phraseSet struct {
+	phrases map[string]string // Translations by phrase key
+	longest int               // Tokens in the longest phrase
+}
+
+// NewPhraseTable creates an empty phrase table.
+func NewPhraseTable() *PhraseTable {
+	return &PhraseTable{pairs: make(map[[2]string]*phraseSet)}
+}
+
+// LoadPhraseTable reads a phrase table from a CSV or TMX file, depending on
+// its extension.
+func LoadPhraseTable(path string) (*PhraseTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var table *PhraseTable
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		table, err = ReadPhraseTableCSV(f)
+	case ".tmx", ".xml":
+		table, err = ReadPhraseTableTMX(f)
+	default:
+		return nil, fmt.Errorf("unsupported phrase table format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return table, nil
+}
+
+// ReadPhraseTableCSV reads a phrase table from CSV. The first row holds
+// language codes and each other row holds a phrase in those languages, so
+//
+//	en,fr,es
+//	thank you,merci,gracias
+//
+// translates between English, French and Spanish in every direction. Cells
+// may be left empty, and lines starting with # are ignored.
+func ReadPhraseTableCSV(r io.Reader) (*PhraseTable, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	languages, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	table := NewPhraseTable()
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		variants := map[string]string{}
+		for i, phrase := range row {
+			if i < len(languages) && strings.TrimSpace(phrase) != "" {
+				variants[languages[i]] = phrase
+			}
+		}
+		if err := table.addVariants(variants); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// tmxDocument is the part of a TMX document that phrase tables use.
+type tmxDocument struct {
+	Units []struct {
+		Variants []struct {
+			Attrs   []xml.Attr `xml:",any,attr"`
+			Segment string     `xml:"seg"`
+		} `xml:"tuv"`
+	} `xml:"body>tu"`
+}
+
+// ReadPhraseTableTMX reads a phrase table from a TMX (Translation Memory
+// eXchange) document. Each translation unit translates between all of its
+// variants.
+func ReadPhraseTableTMX(r io.Reader) (*PhraseTable, error) {
+	var doc tmxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	table := NewPhraseTable()
+	for i, unit := range doc.Units {
+		variants := map[string]string{}
+		for _, variant := range unit.Variants {
+			for _, attr := range variant.Attrs {
+				// xml:lang in TMX 1.4, lang in earlier versions
+				if attr.Name.Local == "lang" && strings.TrimSpace(variant.Segment) != "" {
+					variants[attr.Value] = variant.Segment
+				}
+			}
+		}
+		if err := table.addVariants(variants); err != nil {
+			return nil, fmt.Errorf("translation unit %d: %w", i+1, err)
+		}
+	}
+	return table, nil
+}
+
+// addVariants adds translations between each pair of variants of a phrase.
+func (t *PhraseTable) addVariants(variants map[string]string) error {
+	for source, phrase := range variants {
+		for target, translation := range variants {
+			if source == target {
+				continue
+			}
+			if err := t.Add(source, target, phrase, translation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Add adds the translation of phrase from source to target. If the phrase
+// is already in the table, the first translation is kept.
+func (t *PhraseTable) Add(source, target, phrase, translation string) error {
+	tokens := splitPhrase(phrase)
+	key, count := phraseKey(tokens)
+	if count == 0 {
+		return fmt.Errorf("empty phrase")
+	}
+	if !slices.Equal(placeholders(tokens), placeholders(splitPhrase(translation))) {
+		return fmt.Errorf("translation %q of %q has different placeholders", translation, phrase)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pair := [2]string{normalizeLanguage(source), normalizeLanguage(target)}
+	set, ok := t.pairs[pair]
+	if !ok {
+		set = &phraseSet{phrases: map[string]string{}}
+		t.pairs[pair] = set
+	}
+	if _, exists := set.phrases[key]; !exists {
+		set.phrases[key] = strings.TrimSpace(translation)
+		set.longest = max(set.longest, count)
+	}
+	return nil
+}
+
+// Translate translates text phrase by phrase. It returns ErrUnsupportedPair
+// if the table has no phrases from source to target.
+func (t *PhraseTable) Translate(text, source, target string) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	set := t.lookup(source, target)
+	if set == nil {
+		return "", fmt.Errorf("%w: %s to %s", ErrUnsupportedPair, source, target)
+	}
+
+	tokens := splitPhrase(text)
+	var out strings.Builder
+	for i := 0; i < len(tokens); {
+		if tokens[i].kind == spaceToken {
+			out.WriteString(tokens[i].text)
+			i++
+			continue
+		}
+
+		// Find the longest phrase starting here. Phrases do not span lines.
+		var ends []int
+		for j := i; j < len(tokens) && len(ends) < set.longest; j++ {
+			if tokens[j].kind != spaceToken {
+				ends = append(ends, j+1)
+			} else if strings.ContainsAny(tokens[j].text, "\r\n") {
+				break
+			}
+		}
+		end := i + 1
+		translation, found := "", false
+		for n := len(ends); n > 0 && !found; n-- {
+			key, _ := phraseKey(tokens[i:ends[n-1]])
+			if translation, found = set.phrases[key]; found {
+				end = ends[n-1]
+			}
+		}
+
+		if found {
+			out.WriteString(matchCase(tokens[i:end], translation))
+		} else {
+			out.WriteString(tokens[i].text)
+		}
+		i = end
+	}
+	return out.String(), nil
+}
+
+// lookup returns the phrases from source to target, falling back to the
+// primary language subtags, so that a table for "en" serves "en-US". The
+// caller must hold t.mu.
+func (t *PhraseTable) lookup(source, target string) *phraseSet {
+	source, target = normalizeLanguage(source), normalizeLanguage(target)
+	for _, pair := range [][2]string{
+		{source, target},
+		{baseLanguage(source), target},
+		{source, baseLanguage(target)},
+		{baseLanguage(source), baseLanguage(target)},
+	} {
+		if set, ok := t.pairs[pair]; ok {
+			return set
+		}
+	}
+	return nil
+}
+
+// normalizeLanguage returns a language code in lower case, with - between
+// subtags.
+func normalizeLanguage(code string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(code)), "_", "-")
+}
+
+// baseLanguage returns the primary subtag of a language code.
+func baseLanguage(code string) string {
+	base, _, _ := strings.Cut(code, "-")
+	return base
+}
+
+// phraseTokenKind is the kind of a phraseToken.
+type phraseTokenKind int
+
+const (
+	wordToken phraseTokenKind = iota
+	placeholderToken
+	punctuationToken
+	spaceToken
+)
+
+// phraseToken is a word, placeholder, punctuation mark or run of white
+// space.
+type phraseToken struct {
+	kind phraseTokenKind
+	text string
+}
+
+// splitPhrase splits text into tokens. Words may contain apostrophes and
+// hyphens, as in "don't" and "e-mail".
+func splitPhrase(text string) []phraseToken {
+	var tokens []phraseToken
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		start := i
+		kind := punctuationToken
+		switch {
+		case unicode.IsSpace(r):
+			kind = spaceToken
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				i += size
+			}
+		case r == '{' && placeholderLength(text[i:]) > 0:
+			kind = placeholderToken
+			i += placeholderLength(text[i:])
+		case isPhraseRune(r):
+			kind = wordToken
+			for i += size; i < len(text); {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if isPhraseRune(r) {
+					i += size
+					continue
+				}
+				// An apostrophe or hyphen between letters joins them
+				next, _ := utf8.DecodeRuneInString(text[i+size:])
+				if strings.ContainsRune("'’-", r) && i+size < len(text) && isPhraseRune(next) {
+					i += size
+					continue
+				}
+				break
+			}
+		default:
+			i += size
+		}
+		tokens = append(tokens, phraseToken{kind: kind, text: text[start:i]})
+	}
+	return tokens
+}
+
+// isPhraseRune reports whether r is part of a word in a phrase.
+func isPhraseRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// placeholderLength returns the length of the placeholder, such as {name},
+// at the start of s, or 0 if there is none.
+func placeholderLength(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '}' && i > 1:
+			return i + 1
+		case c == '_' || c == '.' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// phraseKey returns the key under which tokens are kept in a phrase table,
+// and the number of tokens other than white space.
+func phraseKey(tokens []phraseToken) (string, int) {
+	var parts []string
+	for _, token := range tokens {
+		switch token.kind {
+		case wordToken:
+			parts = append(parts, strings.ToLower(token.text))
+		case placeholderToken, punctuationToken:
+			parts = append(parts, token.text)
+		}
+	}
+	return strings.Join(parts, " "), len(parts)
+}
+
+// placeholders returns the placeholders among tokens, sorted.
+func placeholders(tokens []phraseToken) []string {
+	var names []string
+	for _, token := range tokens {
+		if token.kind == placeholderToken {
+			names = append(names, token.text)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// matchCase returns translation in the case of the original tokens: upper
+// case if they are, title case if each word is capitalized, or capitalized
+// if the first word is.
+func matchCase(original []phraseToken, translation string) string {
+	var words []string
+	for _, token := range original {
+		if token.kind == wordToken {
+			words = append(words, token.text)
+		}
+	}
+	if len(words) == 0 {
+		return translation
+	}
+
+	joined := strings.Join(words, "")
+	if utf8.RuneCountInString(joined) > 1 && strings.ToUpper(joined) == joined && strings.ToLower(joined) != joined {
+		return replaceWords(translation, func(i int, word string) string { return strings.ToUpper(word) })
+	}
+	titled := len(words) > 1
+	for _, word := range words {
+		titled = titled && isCapitalized(word)
+	}
+	switch {
+	case titled:
+		return replaceWords(translation, func(i int, word string) string { return capitalize(word) })
+	case isCapitalized(words[0]):
+		return replaceWords(translation, func(i int, word string) string {
+			if i == 0 {
+				return capitalize(word)
+			}
+			return word
+		})
+	default:
+		return translation
+	}
+}
+
+// replaceWords applies f to the words of text, leaving placeholders alone.
+// It passes f the index of the word among the words and placeholders, so
+// a translation starting with a placeholder is not capitalized.
+func replaceWords(text string, f func(i int, word string) string) string {
+	var out strings.Builder
+	i := 0
+	for _, token := range splitPhrase(text) {
+		switch token.kind {
+		case wordToken:
+			out.WriteString(f(i, token.text))
+			i++
+		case placeholderToken:
+			out.WriteString(token.text)
+			i++
+		default:
+			out.WriteString(token.text)
+		}
+	}
+	return out.String()
+}
+
+// isCapitalized reports whether word starts with an upper case letter.
+func isCapitalized(word string) bool {
+	r, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsUpper(r)
+}
+
+// capitalize returns word with its first letter in upper case.
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
@@ -0,0 +1,909 @@
+package decorator
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParamKind is the type of a pipeline stage parameter.
+type ParamKind int
+
+const (
+	// StringParam takes any text.
+	StringParam ParamKind = iota
+	// IntParam takes a whole number.
+	IntParam
+	// BoolParam takes true or false. Writing its name alone sets it to true.
+	BoolParam
+	// MapParam collects the named arguments that match no other parameter.
+	MapParam
+)
+
+// Param describes a parameter of a pipeline stage.
+type Param struct {
+	Name       string
+	Kind       ParamKind
+	Positional bool     // May be given without its name, in order
+	Required   bool     // Must be given
+	Default    string   // Value when not given
+	Choices    []string // Allowed values, if limited
+	Secret     bool     // Must be given as a variable, such as $KEY, and is printed as one
+}
+
+// Stage describes a stage of a pipeline spec and builds its decorator.
+type Stage struct {
+	Name        string
+	Description string
+	Params      []Param
+	// Build wraps processor in the stage's decorator.
+	Build func(processor TextProcessor, args Args) (TextProcessor, error)
+}
+
+// Args holds the arguments of a pipeline stage, checked against its
+// parameters and with defaults filled in.
+type Args struct {
+	values map[string]string // Values by parameter name
+	vars   map[string]string // Names of the variables that values came from
+	fields map[string]string // Arguments collected by a MapParam
+}
+
+// String returns the value of a parameter.
+func (a Args) String(name string) string {
+	return a.values[name]
+}
+
+// Int returns the value of an IntParam.
+func (a Args) Int(name string) int {
+	n, _ := strconv.Atoi(a.values[name])
+	return n
+}
+
+// Bool returns the value of a BoolParam.
+func (a Args) Bool(name string) bool {
+	b, _ := strconv.ParseBool(a.values[name])
+	return b
+}
+
+// Map returns the arguments collected by the MapParam.
+func (a Args) Map() map[string]string {
+	fields := make(map[string]string, len(a.fields))
+	for k, v := range a.fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+// spec formats the arguments as a stage of a pipeline spec. Positional
+// parameters are written without their names and parameters with their
+// default values are left out.
+func (a Args) spec(stage string, params []Param) string {
+	var parts []string
+	positional := true
+	for _, p := range params {
+		if p.Kind == MapParam {
+			names := make([]string, 0, len(a.fields))
+			for name := range a.fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				parts = append(parts, specValue(name)+"="+specValue(a.fields[name]))
+			}
+			positional = false
+			continue
+		}
+
+		value, set := a.values[p.Name]
+		ref, isVar := a.vars[p.Name]
+		if !isVar && (!set || (!p.Required && isDefault(p, value))) {
+			positional = false
+			continue
+		}
+
+		formatted := specValue(value)
+		if isVar {
+			formatted = "$" + ref
+		}
+		switch {
+		case p.Kind == BoolParam && !isVar && value == "true":
+			parts = append(parts, p.Name)
+		case p.Positional && positional:
+			// A bare word naming a flag would be read as the flag
+			if slices.Contains(flags(params), formatted) {
+				formatted = strconv.Quote(value)
+			}
+			parts = append(parts, formatted)
+		default:
+			parts = append(parts, p.Name+"="+formatted)
+			positional = false
+		}
+	}
+	if len(parts) == 0 {
+		return stage
+	}
+	return stage + "(" + strings.Join(parts, ",") + ")"
+}
+
+// isDefault reports whether value is the default of p.
+func isDefault(p Param, value string) bool {
+	switch p.Kind {
+	case IntParam:
+		n, _ := strconv.Atoi(value)
+		d, _ := strconv.Atoi(p.Default)
+		return n == d
+	case BoolParam:
+		b, _ := strconv.ParseBool(value)
+		d, _ := strconv.ParseBool(p.Default)
+		return b == d
+	default:
+		return value == p.Default
+	}
+}
+
+// specValue formats a value for a pipeline spec, quoting it unless it is
+// a plain word.
+func specValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if !isWordRune(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// isWordRune reports whether r may appear in an unquoted word.
+func isWordRune(r rune) bool {
+	return r > ' ' && !strings.ContainsRune(`|(),=$"`, r) && r != utf8.RuneError
+}
+
+// Parameters of the built-in stages, which decorators also use to print
+// themselves as pipeline specs.
+var (
+	highlightParams = []Param{
+		{Name: "pattern", Positional: true, Required: true},
+		{Name: "start", Default: "**"},
+		{Name: "end", Default: "**"},
+	}
+	indentParams = []Param{
+		{Name: "prefix", Positional: true, Required: true},
+		{Name: "first", Kind: BoolParam, Default: "false"},
+	}
+	cryptParams = []Param{
+		{Name: "mode", Positional: true, Required: true, Choices: []string{"gcm", "aes", "base64", "rot13"}},
+		{Name: "key", Secret: true},
+	}
+	hashParams = []Param{
+		{Name: "algorithm", Positional: true, Required: true, Choices: []string{"md5", "sha256"}},
+		{Name: "append", Kind: BoolParam, Default: "false"},
+	}
+	validateParams = []Param{
+		{Name: "notempty", Kind: BoolParam, Default: "false"},
+		{Name: "min", Kind: IntParam, Default: "0"},
+		{Name: "max", Kind: IntParam, Default: "0"},
+		{Name: "contains"},
+	}
+	compressParams = []Param{
+		{Name: "algorithm", Positional: true, Required: true, Choices: []string{"gzip", "zlib"}},
+		{Name: "base64", Kind: BoolParam, Default: "false"},
+	}
+	logParams = []Param{
+		{Name: "input", Kind: BoolParam, Default: "false"},
+		{Name: "output", Kind: BoolParam, Default: "false"},
+		{Name: "timing", Kind: BoolParam, Default: "false"},
+		{Name: "max", Kind: IntParam, Default: "0"},
+	}
+	metadataParams = []Param{
+		{Name: "position", Positional: true, Default: "suffix", Choices: []string{"prefix", "suffix"}},
+		{Name: "fields", Kind: MapParam},
+	}
+	translateParams = []Param{
+		{Name: "from", Positional: true, Required: true},
+		{Name: "to", Positional: true, Required: true},
+		{Name: "table"},
+		{Name: "url"},
+		{Name: "pivot"},
+	}
+)
+
+// NewDefaultRegistry returns a registry of the built-in stages:
+//
+//	html, markdown, plain               Formatting
+//	sanitize                            Removing unsafe HTML, with the default policy
+//	highlight(pattern,start=,end=)      Wrapping matches of a regular expression
+//	indent(prefix,first)                Indenting lines
+//	encrypt(mode,key=), decrypt(...)    gcm, aes, base64 or rot13; the key must be a variable
+//	base64                              Same as encrypt(base64)
+//	hash(algorithm,append)              md5 or sha256
+//	validate(notempty,min=,max=,contains=)
+//	compress(algorithm,base64)          gzip or zlib, and decompress(...)
+//	log(input,output,timing,max=)
+//	metadata(position,name=value...)    prefix or suffix
+//	translate(from,to,table=,url=,pivot=) A CSV or TMX phrase table, a translation service
+//	                                    or the built-in demonstration table
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, s := range []Stage{
+		{Name: "html", Description: "Formats text as HTML", Build: func(p TextProcessor, _ Args) (TextProcessor, error) {
+			return NewHTMLFormattingDecorator(p), nil
+		}},
+		{Name: "markdown", Description: "Formats text as Markdown", Build: func(p TextProcessor, _ Args) (TextProcessor, error) {
+			return NewMarkdownFormattingDecorator(p), nil
+		}},
+		{Name: "plain", Description: "Strips formatting", Build: func(p TextProcessor, _ Args) (TextProcessor, error) {
+			return NewPlainTextFormattingDecorator(p), nil
+		}},
+		{Name: "sanitize", Description: "Removes unsafe HTML", Build: func(p TextProcessor, _ Args) (TextProcessor, error) {
+			return NewSanitizeDecorator(p, nil), nil
+		}},
+		{Name: "highlight", Description: "Highlights text matching a regular expression", Params: highlightParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				return NewHighlightingDecorator(p, a.String("pattern"), a.String("start"), a.String("end")), nil
+			}},
+		{Name: "indent", Description: "Indents each line", Params: indentParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				return NewIndentationDecorator(p, a.String("prefix"), a.Bool("first")), nil
+			}},
+		{Name: "encrypt", Description: "Encrypts text", Params: cryptParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				if err := checkKey(a); err != nil {
+					return nil, err
+				}
+				return NewEncryptionDecorator(p, a.String("key"), a.String("mode")), nil
+			}},
+		{Name: "decrypt", Description: "Decrypts text", Params: cryptParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				if err := checkKey(a); err != nil {
+					return nil, err
+				}
+				return NewDecryptionDecorator(p, a.String("key"), a.String("mode")), nil
+			}},
+		{Name: "base64", Description: "Encodes text as base64", Build: func(p TextProcessor, _ Args) (TextProcessor, error) {
+			return NewEncryptionDecorator(p, "", "base64"), nil
+		}},
+		{Name: "hash", Description: "Hashes text", Params: hashParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				return NewHashingDecorator(p, a.String("algorithm"), a.Bool("append")), nil
+			}},
+		{Name: "validate", Description: "Validates text", Params: validateParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				return newValidationDecoratorFromArgs(p, a), nil
+			}},
+		{Name: "compress", Description: "Compresses text", Params: compressParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				return NewCompressionDecorator(p, a.String("algorithm"), a.Bool("base64")), nil
+			}},
+		{Name: "decompress", Description: "Decompresses text", Params: compressParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				return NewDecompressionDecorator(p, a.String("algorithm"), a.Bool("base64")), nil
+			}},
+		{Name: "log", Description: "Logs details about text processing", Params: logParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				return NewLoggingDecorator(p, a.Bool("input"), a.Bool("output"), a.Bool("timing"), a.Int("max")), nil
+			}},
+		{Name: "metadata", Description: "Adds metadata to text", Params: metadataParams,
+			Build: func(p TextProcessor, a Args) (TextProcessor, error) {
+				return NewMetadataDecorator(p, a.Map(), a.String("position")), nil
+			}},
+		{Name: "translate", Description: "Translates text", Params: translateParams, Build: buildTranslation},
+	} {
+		if err := r.Register(s); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// buildTranslation builds the decorator of a translate stage.
+func buildTranslation(p TextProcessor, a Args) (TextProcessor, error) {
+	var translator Translator
+	switch table, url := a.String("table"), a.String("url"); {
+	case table != "" && url != "":
+		return nil, fmt.Errorf("give either table or url, not both")
+	case table != "":
+		phrases, err := LoadPhraseTable(table)
+		if err != nil {
+			return nil, err
+		}
+		translator = phrases
+	case url != "":
+		translator = NewHTTPTranslator(url, nil)
+	default:
+		translator = demoPhraseTable()
+	}
+	if pivot := a.String("pivot"); pivot != "" {
+		translator = NewPivotTranslator(translator, pivot)
+	}
+	return NewTranslationDecorator(p, translator, a.String("from"), a.String("to")), nil
+}
+
+// checkKey checks that gcm and aes encryption are given a key.
+func checkKey(a Args) error {
+	if mode := a.String("mode"); (mode == "gcm" || mode == "aes") && a.String("key") == "" {
+		return fmt.Errorf("%s needs a key, such as key=$KEY", mode)
+	}
+	return nil
+}
+
+// ParsePipeline builds the TextProcessor chain described by spec using the
+// built-in stages, taking variables such as $KEY from the environment.
+//
+// A spec lists stages in the order they process text, separated by |.
+// Arguments follow the stage name in parentheses and are given in order or
+// by name, for example
+//
+//	validate(notempty,max=1000) | html | compress(gzip) | encrypt(aes,key=$KEY) | base64
+//
+// Values containing spaces or punctuation are written as Go string literals.
+func ParsePipeline(spec string) (TextProcessor, error) {
+	return NewDefaultRegistry().Parse(spec, os.LookupEnv)
+}
+
+// Registry maps stage names to the decorators they build.
+type Registry struct {
+	stages map[string]Stage
+}
+
+// NewRegistry returns an empty registry. Use NewDefaultRegistry for one
+// with the built-in stages.
+func NewRegistry() *Registry {
+	return &Registry{stages: make(map[string]Stage)}
+}
+
+// Register adds a stage to the registry.
+func (r *Registry) Register(stage Stage) error {
+	if stage.Name == "" || specValue(stage.Name) != stage.Name {
+		return fmt.Errorf("invalid stage name %q", stage.Name)
+	}
+	if _, exists := r.stages[stage.Name]; exists {
+		return fmt.Errorf("stage %q is already registered", stage.Name)
+	}
+	if stage.Build == nil {
+		return fmt.Errorf("stage %q has no Build function", stage.Name)
+	}
+	seen := map[string]bool{}
+	for _, p := range stage.Params {
+		switch {
+		case seen[p.Name]:
+			return fmt.Errorf("stage %q has two parameters named %q", stage.Name, p.Name)
+		case p.Kind == MapParam && seen[""]:
+			return fmt.Errorf("stage %q has more than one MapParam", stage.Name)
+		case p.Kind == BoolParam && p.Positional:
+			return fmt.Errorf("parameter %q of stage %q is a BoolParam and cannot be positional", p.Name, stage.Name)
+		case p.Kind != MapParam && p.Default != "":
+			if err := checkValue(stage.Name, p, p.Default); err != nil {
+				return fmt.Errorf("invalid default: %w", err)
+			}
+		}
+		seen[p.Name] = true
+		if p.Kind == MapParam {
+			seen[""] = true
+		}
+	}
+	r.stages[stage.Name] = stage
+	return nil
+}
+
+// Stages returns the registered stages sorted by name.
+func (r *Registry) Stages() []Stage {
+	stages := make([]Stage, 0, len(r.stages))
+	for _, s := range r.stages {
+		stages = append(stages, s)
+	}
+	sort.Slice(stages, func(i, j int) bool {
+		return stages[i].Name < stages[j].Name
+	})
+	return stages
+}
+
+// Parse builds the TextProcessor chain described by spec, as explained for
+// ParsePipeline. lookup returns the values of variables; if it is nil,
+// specs may not use variables. Errors are returned as *SpecError.
+func (r *Registry) Parse(spec string, lookup func(name string) (string, bool)) (TextProcessor, error) {
+	p := &specParser{spec: spec}
+	stages, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var processor TextProcessor = NewBasicTextProcessor()
+	for _, parsed := range stages {
+		stage, ok := r.stages[parsed.name]
+		if !ok {
+			msg := fmt.Sprintf("unknown stage %q", parsed.name)
+			if suggestion := closest(parsed.name, r.names()); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			return nil, p.errorAt(parsed.pos, msg)
+		}
+		args, err := r.bind(p, stage, parsed, lookup)
+		if err != nil {
+			return nil, err
+		}
+		next, err := stage.Build(processor, args)
+		if err != nil {
+			e := p.errorAt(parsed.pos, fmt.Sprintf("%s: %v", stage.Name, err))
+			e.Err = err
+			return nil, e
+		}
+		if d, ok := next.(interface{ setSpec(string) }); ok {
+			d.setSpec(args.spec(stage.Name, stage.Params))
+		}
+		processor = next
+	}
+	return processor, nil
+}
+
+// names returns the names of the registered stages.
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.stages))
+	for name := range r.stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bind checks the arguments of a parsed stage against its parameters.
+func (r *Registry) bind(p *specParser, stage Stage, parsed parsedStage, lookup func(string) (string, bool)) (Args, error) {
+	args := Args{values: map[string]string{}, vars: map[string]string{}, fields: map[string]string{}}
+	var mapParam *Param
+	params := map[string]Param{}
+	var names []string
+	for i, param := range stage.Params {
+		if param.Kind == MapParam {
+			mapParam = &stage.Params[i]
+			continue
+		}
+		params[param.Name] = param
+		names = append(names, param.Name)
+	}
+
+	next := 0 // Index of the next positional parameter to fill
+	for _, arg := range parsed.args {
+		name := arg.name
+		if name == "" {
+			// A bare word naming an unset flag sets it
+			if param, ok := params[arg.value]; ok && param.Kind == BoolParam && !arg.quoted && arg.variable == "" {
+				if _, set := args.values[param.Name]; !set {
+					args.values[param.Name] = "true"
+					continue
+				}
+			}
+			for next < len(stage.Params) && (!stage.Params[next].Positional || hasValue(args, stage.Params[next].Name)) {
+				next++
+			}
+			if next == len(stage.Params) {
+				msg := fmt.Sprintf("too many arguments for %s", stage.Name)
+				if suggestion := closest(arg.value, flags(stage.Params)); suggestion != "" && !arg.quoted && arg.variable == "" {
+					msg += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				return args, p.errorAt(arg.pos, msg+usage(stage))
+			}
+			name = stage.Params[next].Name
+		}
+
+		param, ok := params[name]
+		if !ok {
+			if mapParam != nil {
+				value, err := p.resolve(arg, lookup)
+				if err != nil {
+					return args, err
+				}
+				if _, dup := args.fields[name]; dup {
+					return args, p.errorAt(arg.namePos, fmt.Sprintf("%s is given twice", name))
+				}
+				args.fields[name] = value
+				continue
+			}
+			msg := fmt.Sprintf("%s has no parameter %q", stage.Name, name)
+			if suggestion := closest(name, names); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			return args, p.errorAt(arg.namePos, msg+usage(stage))
+		}
+		if hasValue(args, name) {
+			return args, p.errorAt(arg.namePos, fmt.Sprintf("%s of %s is given twice", name, stage.Name))
+		}
+		if param.Secret && arg.variable == "" {
+			return args, p.errorAt(arg.pos, fmt.Sprintf("%s of %s is secret and must be a variable, such as %s=$%s",
+				name, stage.Name, name, strings.ToUpper(name)))
+		}
+		value, err := p.resolve(arg, lookup)
+		if err != nil {
+			return args, err
+		}
+		if err := checkValue(stage.Name, param, value); err != nil {
+			return args, p.errorAt(arg.pos, err.Error())
+		}
+		args.values[name] = value
+		if arg.variable != "" {
+			args.vars[name] = arg.variable
+		}
+	}
+
+	for _, param := range stage.Params {
+		if param.Kind == MapParam || hasValue(args, param.Name) {
+			continue
+		}
+		if param.Required {
+			return args, p.errorAt(parsed.end, fmt.Sprintf("%s needs %s%s", stage.Name, param.Name, usage(stage)))
+		}
+		args.values[param.Name] = param.Default
+	}
+	return args, nil
+}
+
+// flags returns the names of the BoolParams in params.
+func flags(params []Param) []string {
+	var names []string
+	for _, p := range params {
+		if p.Kind == BoolParam {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// hasValue reports whether the parameter called name has been given.
+func hasValue(args Args, name string) bool {
+	_, ok := args.values[name]
+	return ok
+}
+
+// checkValue checks that value suits param of stage.
+func checkValue(stage string, param Param, value string) error {
+	switch param.Kind {
+	case IntParam:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s of %s must be a whole number, not %q", param.Name, stage, value)
+		}
+	case BoolParam:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s of %s must be true or false, not %q", param.Name, stage, value)
+		}
+	}
+	if len(param.Choices) > 0 {
+		for _, choice := range param.Choices {
+			if value == choice {
+				return nil
+			}
+		}
+		msg := fmt.Sprintf("%s of %s must be one of %s, not %q", param.Name, stage, strings.Join(param.Choices, ", "), value)
+		if suggestion := closest(value, param.Choices); suggestion != "" {
+			msg += fmt.Sprintf("; did you mean %q?", suggestion)
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// usage describes the parameters of stage, for error messages.
+func usage(stage Stage) string {
+	if len(stage.Params) == 0 {
+		return "; it takes no arguments"
+	}
+	var params []string
+	for _, p := range stage.Params {
+		switch {
+		case p.Kind == MapParam:
+			params = append(params, "name=value...")
+		case p.Kind == BoolParam:
+			params = append(params, p.Name)
+		case p.Positional && p.Required:
+			params = append(params, p.Name)
+		default:
+			params = append(params, p.Name+"=")
+		}
+	}
+	return fmt.Sprintf("; usage: %s(%s)", stage.Name, strings.Join(params, ","))
+}
+
+// closest returns the candidate nearest to name by edit distance, if any
+// is near enough to be a likely typo.
+func closest(name string, candidates []string) string {
+	best, bestDistance := "", max(1, utf8.RuneCountInString(name)/3)+1
+	for _, c := range candidates {
+		if d := editDistance(name, c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// SpecError reports a problem in a pipeline spec and where it is.
+type SpecError struct {
+	Spec   string
+	Offset int    // Byte offset of the problem in Spec
+	Msg    string // Description of the problem
+	Err    error  // Error returned by a stage's Build function, if any
+}
+
+// Error describes the problem and points at it in the spec.
+func (e *SpecError) Error() string {
+	column := utf8.RuneCountInString(e.Spec[:e.Offset])
+	return fmt.Sprintf("pipeline spec: %s at column %d\n\t%s\n\t%s^", e.Msg, column+1, e.Spec, strings.Repeat(" ", column))
+}
+
+// Unwrap returns the error returned by a stage's Build function, if any.
+func (e *SpecError) Unwrap() error {
+	return e.Err
+}
+
+// parsedStage is a stage of a spec before it is checked against the registry.
+type parsedStage struct {
+	name string
+	pos  int // Offset of the name
+	end  int // Offset just after the stage
+	args []parsedArg
+}
+
+// parsedArg is an argument of a parsed stage.
+type parsedArg struct {
+	name     string // Empty for a positional argument
+	namePos  int
+	value    string
+	pos      int  // Offset of the value
+	quoted   bool // Written as a string literal
+	variable string
+}
+
+// specParser parses pipeline specs.
+type specParser struct {
+	spec string
+	pos  int
+}
+
+// errorAt returns a *SpecError at offset.
+func (p *specParser) errorAt(offset int, msg string) *SpecError {
+	return &SpecError{Spec: p.spec, Offset: offset, Msg: msg}
+}
+
+// parse returns the stages of the spec.
+func (p *specParser) parse() ([]parsedStage, error) {
+	var stages []parsedStage
+	if p.skipSpace(); p.pos == len(p.spec) {
+		return nil, nil
+	}
+	for {
+		stage, err := p.stage()
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+
+		p.skipSpace()
+		switch {
+		case p.pos == len(p.spec):
+			return stages, nil
+		case p.spec[p.pos] == '|':
+			p.pos++
+		default:
+			return nil, p.errorAt(p.pos, fmt.Sprintf("unexpected %s, expected | between stages", p.describe()))
+		}
+	}
+}
+
+// stage parses a stage name and its arguments.
+func (p *specParser) stage() (parsedStage, error) {
+	p.skipSpace()
+	stage := parsedStage{pos: p.pos}
+	stage.name = p.word()
+	if stage.name == "" {
+		return stage, p.errorAt(p.pos, fmt.Sprintf("expected a stage name, found %s", p.describe()))
+	}
+	p.skipSpace()
+	if p.pos == len(p.spec) || p.spec[p.pos] != '(' {
+		stage.end = p.pos
+		return stage, nil
+	}
+	open := p.pos
+	p.pos++
+
+	if p.skipSpace(); p.pos < len(p.spec) && p.spec[p.pos] == ')' {
+		p.pos++
+		stage.end = p.pos - 1
+		return stage, nil
+	}
+	for {
+		arg, err := p.arg()
+		if err != nil {
+			return stage, err
+		}
+		stage.args = append(stage.args, arg)
+
+		p.skipSpace()
+		switch {
+		case p.pos == len(p.spec):
+			return stage, p.errorAt(open, "missing ) to close this (")
+		case p.spec[p.pos] == ',':
+			p.pos++
+		case p.spec[p.pos] == ')':
+			p.pos++
+			stage.end = p.pos - 1
+			return stage, nil
+		default:
+			return stage, p.errorAt(p.pos, fmt.Sprintf("unexpected %s, expected , or )", p.describe()))
+		}
+	}
+}
+
+// arg parses an argument, which is a value optionally preceded by name=.
+func (p *specParser) arg() (parsedArg, error) {
+	p.skipSpace()
+	start := p.pos
+	if word := p.word(); word != "" {
+		p.skipSpace()
+		if p.pos < len(p.spec) && p.spec[p.pos] == '=' {
+			p.pos++
+			arg, err := p.value()
+			arg.name, arg.namePos = word, start
+			return arg, err
+		}
+		return parsedArg{value: word, pos: start, namePos: start}, nil
+	}
+	arg, err := p.value()
+	arg.namePos = arg.pos
+	return arg, err
+}
+
+// value parses a word, a string literal or a variable.
+func (p *specParser) value() (parsedArg, error) {
+	p.skipSpace()
+	arg := parsedArg{pos: p.pos}
+	switch {
+	case p.pos == len(p.spec):
+		return arg, p.errorAt(p.pos, "expected a value, found the end of the spec")
+	case p.spec[p.pos] == '"':
+		literal, err := strconv.QuotedPrefix(p.spec[p.pos:])
+		if err != nil {
+			return arg, p.errorAt(p.pos, "unterminated or invalid string")
+		}
+		arg.value, _ = strconv.Unquote(literal)
+		arg.quoted = true
+		p.pos += len(literal)
+	case p.spec[p.pos] == '$':
+		p.pos++
+		braced := p.pos < len(p.spec) && p.spec[p.pos] == '{'
+		if braced {
+			p.pos++
+		}
+		start := p.pos
+		for p.pos < len(p.spec) && isVarByte(p.spec[p.pos], p.pos == start) {
+			p.pos++
+		}
+		arg.variable = p.spec[start:p.pos]
+		if arg.variable == "" {
+			return arg, p.errorAt(p.pos, "expected a variable name after $")
+		}
+		if braced {
+			if p.pos == len(p.spec) || p.spec[p.pos] != '}' {
+				return arg, p.errorAt(p.pos, "missing } after variable name")
+			}
+			p.pos++
+		}
+	default:
+		if arg.value = p.word(); arg.value == "" {
+			return arg, p.errorAt(p.pos, fmt.Sprintf("expected a value, found %s", p.describe()))
+		}
+	}
+	return arg, nil
+}
+
+// resolve returns the value of arg, looking up its variable if it has one.
+func (p *specParser) resolve(arg parsedArg, lookup func(string) (string, bool)) (string, error) {
+	if arg.variable == "" {
+		return arg.value, nil
+	}
+	if lookup == nil {
+		return "", p.errorAt(arg.pos, "variables are not available here")
+	}
+	value, ok := lookup(arg.variable)
+	if !ok {
+		return "", p.errorAt(arg.pos, fmt.Sprintf("variable $%s is not set", arg.variable))
+	}
+	return value, nil
+}
+
+// word parses an unquoted word.
+func (p *specParser) word() string {
+	start := p.pos
+	for p.pos < len(p.spec) {
+		r, size := utf8.DecodeRuneInString(p.spec[p.pos:])
+		if !isWordRune(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.spec[start:p.pos]
+}
+
+// skipSpace moves past white space.
+func (p *specParser) skipSpace() {
+	for p.pos < len(p.spec) && strings.IndexByte(" \t\r\n", p.spec[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// describe names what is at the current position, for error messages.
+func (p *specParser) describe() string {
+	if p.pos == len(p.spec) {
+		return "the end of the spec"
+	}
+	r, _ := utf8.DecodeRuneInString(p.spec[p.pos:])
+	return strconv.QuoteRune(r)
+}
+
+// isVarByte reports whether c may appear in a variable name.
+func isVarByte(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+// chainSpec returns the pipeline spec of a chain whose last stage is spec
+// and whose earlier stages are processor.
+func chainSpec(processor interface{ GetName() string }, spec string) string {
+	if inner := processorSpec(processor); inner != "" {
+		return inner + " | " + spec
+	}
+	return spec
+}
+
+// processorSpec returns the pipeline spec of processor, which is empty for
+// the basic processors that chains start from.
+func processorSpec(processor interface{ GetName() string }) string {
+	switch p := processor.(type) {
+	case *BasicTextProcessor, *BasicStreamProcessor:
+		return ""
+	case interface{ GetProcessingChain() string }:
+		return p.GetProcessingChain()
+	default:
+		return specValue(p.GetName())
+	}
+}
+
+// stageSpec formats a stage of a pipeline spec from its parameter values.
+func stageSpec(stage string, params []Param, values map[string]string) string {
+	return Args{values: values}.spec(stage, params)
+}
+
+// cryptSpec formats an encrypt or decrypt stage. Keys are never printed;
+// gcm and aes stages refer to the variable $KEY instead.
+func cryptSpec(stage, mode string) string {
+	if stage == "encrypt" && mode == "base64" {
+		return "base64"
+	}
+	args := Args{values: map[string]string{"mode": mode}}
+	if mode == "gcm" || mode == "aes" {
+		args.vars = map[string]string{"key": "KEY"}
+	}
+	return args.spec(stage, cryptParams)
+}
@@ -0,0 +1,268 @@
+package decorator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testVars are the variables available to the specs in these tests
+func testVars(name string) (string, bool) {
+	vars := map[string]string{"KEY": "secret", "OTHER": "other secret", "WORD": "important"}
+	value, ok := vars[name]
+	return value, ok
+}
+
+// parseSpec parses spec with the default registry and testVars
+func parseSpec(t *testing.T, spec string) TextProcessor {
+	t.Helper()
+	processor, err := NewDefaultRegistry().Parse(spec, testVars)
+	if err != nil {
+		t.Fatalf("Parse(%q) returned error: %v", spec, err)
+	}
+	return processor
+}
+
+// TestParsePipelineRoundTrip tests that printed chains parse back into the
+// same chain
+func TestParsePipelineRoundTrip(t *testing.T) {
+	cases := []struct {
+		spec      string
+		canonical string
+	}{
+		{"", ""},
+		{"html", "html"},
+		{"validate(notempty,max=1000) | html | compress(gzip) | encrypt(aes,key=$KEY) | base64",
+			"validate(notempty,max=1000) | html | compress(gzip) | encrypt(aes,key=$KEY) | base64"},
+		{" markdown|plain ", "markdown | plain"},
+		{"html()", "html"},
+		{`highlight("\\bword\\b", start="<b>", end="</b>")`, `highlight(\bword\b,start=<b>,end=</b>)`},
+		{"highlight(pattern=test,start=**)", "highlight(test)"},
+		{"highlight($WORD)", "highlight($WORD)"},
+		{`indent("  ",first)`, `indent("  ",first)`},
+		{`indent("first")`, `indent("first")`},
+		{"encrypt(mode=rot13) | decrypt(rot13)", "encrypt(rot13) | decrypt(rot13)"},
+		{"encrypt(aes,key=${OTHER}) | decrypt(aes,key=$OTHER)", "encrypt(aes,key=$OTHER) | decrypt(aes,key=$OTHER)"},
+		{"encrypt(base64)", "encrypt(base64)"},
+		{"hash(md5,append=true)", "hash(md5,append)"},
+		{"hash(sha256,append=false)", "hash(sha256)"},
+		{"validate(min=0,contains=\"a b\")", `validate(contains="a b")`},
+		{"compress(zlib,base64) | decompress(zlib,base64)", "compress(zlib,base64) | decompress(zlib,base64)"},
+		{"log(timing,max=80)", "log(timing,max=80)"},
+		{"metadata(prefix,version=1.0,author=\"Jane Doe\")", `metadata(prefix,author="Jane Doe",version=1.0)`},
+		{"metadata(suffix,status=draft)", "metadata(status=draft)"},
+		{"translate(en,fr)", "translate(en,fr)"},
+	}
+
+	for _, c := range cases {
+		processor := parseSpec(t, c.spec)
+		if got := processorSpec(processor); got != c.canonical {
+			t.Errorf("Parse(%q) printed as %q, expected %q", c.spec, got, c.canonical)
+		}
+		if got := processorSpec(parseSpec(t, c.canonical)); got != c.canonical {
+			t.Errorf("Canonical spec %q printed as %q", c.canonical, got)
+		}
+	}
+}
+
+// TestParsePipelineMatchesDecorators tests that parsed chains match chains
+// built with the constructors, both in output and in how they print
+func TestParsePipelineMatchesDecorators(t *testing.T) {
+	basic := NewBasicTextProcessor()
+	text := "# Welcome\n\nHello world, this is an important message.\nPlease read it."
+
+	cases := []struct {
+		spec  string
+		built TextProcessor
+	}{
+		{"html | highlight(important,start=<mark>,end=</mark>)",
+			NewHighlightingDecorator(NewHTMLFormattingDecorator(basic), "important", "<mark>", "</mark>")},
+		{"markdown | indent(>,first)",
+			NewIndentationDecorator(NewMarkdownFormattingDecorator(basic), ">", true)},
+		{"plain | hash(sha256,append)",
+			NewHashingDecorator(NewPlainTextFormattingDecorator(basic), "sha256", true)},
+		{"compress(gzip,base64) | decompress(gzip,base64)",
+			NewDecompressionDecorator(NewCompressionDecorator(basic, "gzip", true), "gzip", true)},
+		{"encrypt(aes,key=$KEY) | decrypt(aes,key=$KEY)",
+			NewDecryptionDecorator(NewEncryptionDecorator(basic, "secret", "aes"), "secret", "aes")},
+		{"base64 | encrypt(rot13)",
+			NewEncryptionDecorator(NewEncryptionDecorator(basic, "", "base64"), "", "rot13")},
+		{"translate(en,es) | metadata(prefix,lang=es,source=en)",
+			NewMetadataDecorator(NewLanguageTranslationDecorator(basic, "en", "es"), map[string]string{"source": "en", "lang": "es"}, "prefix")},
+		{"log(max=10)", NewLoggingDecorator(basic, false, false, false, 10)},
+	}
+
+	for _, c := range cases {
+		parsed := parseSpec(t, c.spec)
+		if got := processorSpec(c.built); got != c.spec {
+			t.Errorf("Built chain printed as %q, expected %q", got, c.spec)
+		}
+		expected, err := c.built.Process(text)
+		if err != nil {
+			t.Fatalf("%s: Process returned error: %v", c.spec, err)
+		}
+		got, err := parsed.Process(text)
+		if err != nil {
+			t.Fatalf("%s: Process returned error: %v", c.spec, err)
+		}
+		if got != expected {
+			t.Errorf("%s: parsed chain output differs.\nExpected: %q\nGot:      %q", c.spec, expected, got)
+		}
+	}
+
+	// Validation built from a spec checks what the spec says
+	validate := parseSpec(t, "validate(notempty,min=3,max=10,contains=b)")
+	for input, valid := range map[string]bool{"abc": true, "  ": false, "ab": false, "ccc": false, "abcdefghijk": false} {
+		if _, err := validate.Process(input); (err == nil) != valid {
+			t.Errorf("Validation of %q returned %v", input, err)
+		}
+	}
+}
+
+// TestParsePipelineErrors tests that mistakes in specs are reported with
+// their position and a helpful message
+func TestParsePipelineErrors(t *testing.T) {
+	cases := []struct {
+		spec    string
+		offset  int
+		message string
+	}{
+		{"html | compres(gzip)", 7, `unknown stage "compres", did you mean "compress"?`},
+		{"html |", 6, "expected a stage name, found the end of the spec"},
+		{"| html", 0, `expected a stage name, found '|'`},
+		{"html markdown", 5, "expected | between stages"},
+		{"compress(gzip", 8, "missing ) to close this ("},
+		{"compress(gzip base64)", 14, "expected , or )"},
+		{"compress(gzip,)", 14, "expected a value"},
+		{"compress(algorithm=)", 19, "expected a value"},
+		{"compress(lzma)", 9, `algorithm of compress must be one of gzip, zlib, not "lzma"`},
+		{"compress(gzipp)", 9, `did you mean "gzip"?`},
+		{"compress", 8, "compress needs algorithm; usage: compress(algorithm,base64)"},
+		{"compress(gzip,level=9)", 14, `compress has no parameter "level"`},
+		{"compress(gzip,bas64)", 14, `too many arguments for compress, did you mean "base64"?`},
+		{"hash(md5,append,x)", 16, "too many arguments for hash; usage: hash(algorithm,append)"},
+		{"compress(gzip,base46=true)", 14, `did you mean "base64"?`},
+		{"compress(gzip,algorithm=zlib)", 14, "algorithm of compress is given twice"},
+		{"validate(max=ten)", 13, `max of validate must be a whole number, not "ten"`},
+		{"hash(md5,append=maybe)", 16, `append of hash must be true or false, not "maybe"`},
+		{"html(x)", 5, "too many arguments for html; it takes no arguments"},
+		{`highlight("unterminated)`, 10, "unterminated or invalid string"},
+		{"encrypt(aes,key=secret)", 16, "key of encrypt is secret and must be a variable, such as key=$KEY"},
+		{"encrypt(aes,key=$MISSING)", 16, "variable $MISSING is not set"},
+		{"encrypt(aes,key=$)", 17, "expected a variable name after $"},
+		{"encrypt(aes,key=${KEY)", 21, "missing } after variable name"},
+		{"encrypt(aes)", 0, "encrypt: aes needs a key, such as key=$KEY"},
+		{"metadata(a=1,a=2)", 13, "a is given twice"},
+	}
+
+	for _, c := range cases {
+		_, err := NewDefaultRegistry().Parse(c.spec, testVars)
+		var specErr *SpecError
+		if !errors.As(err, &specErr) {
+			t.Errorf("Parse(%q) returned %v, expected a *SpecError", c.spec, err)
+			continue
+		}
+		if specErr.Offset != c.offset || !strings.Contains(specErr.Msg, c.message) {
+			t.Errorf("Parse(%q) reported %q at %d, expected %q at %d", c.spec, specErr.Msg, specErr.Offset, c.message, c.offset)
+		}
+	}
+
+	// The message points at the problem
+	_, err := ParsePipeline("html | compres(gzip)")
+	expected := "pipeline spec: unknown stage \"compres\", did you mean \"compress\"? at column 8\n" +
+		"\thtml | compres(gzip)\n" +
+		"\t       ^"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error message:\n%v", err)
+	}
+
+	// Without a lookup function, variables cannot be used
+	if _, err := NewDefaultRegistry().Parse("encrypt(aes,key=$KEY)", nil); err == nil || !strings.Contains(err.Error(), "variables are not available") {
+		t.Errorf("Expected variables to be unavailable, got %v", err)
+	}
+}
+
+// TestRegistry tests registering custom stages
+func TestRegistry(t *testing.T) {
+	errBuild := errors.New("no repeat count")
+	registry := NewDefaultRegistry()
+	err := registry.Register(Stage{
+		Name:        "repeat",
+		Description: "Repeats text",
+		Params: []Param{
+			{Name: "count", Kind: IntParam, Positional: true, Default: "2"},
+			{Name: "separator", Default: " "},
+		},
+		Build: func(p TextProcessor, args Args) (TextProcessor, error) {
+			if args.Int("count") < 1 {
+				return nil, errBuild
+			}
+			return &repeatDecorator{
+				TextProcessorDecorator: TextProcessorDecorator{wrapped: p, name: "Repeater"},
+				count:                  args.Int("count"),
+				separator:              args.String("separator"),
+			}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+
+	processor, err := registry.Parse(`plain | repeat(3,separator="-")`, nil)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if got, _ := processor.Process("ab"); got != "ab-ab-ab" {
+		t.Errorf("Unexpected output: %q", got)
+	}
+	if got := processorSpec(processor); got != `plain | repeat(3,separator=-)` {
+		t.Errorf("Unexpected processing chain: %s", got)
+	}
+	if processor, err = registry.Parse("repeat(2,separator=\" \")", nil); err != nil || processorSpec(processor) != "repeat" {
+		t.Errorf("Defaults are printed: %v", err)
+	}
+
+	// Errors from Build are wrapped
+	if _, err := registry.Parse("repeat(0)", nil); !errors.Is(err, errBuild) {
+		t.Errorf("Expected the Build error, got %v", err)
+	}
+
+	// Invalid stages are rejected
+	invalid := []Stage{
+		{Name: "repeat", Build: func(p TextProcessor, _ Args) (TextProcessor, error) { return p, nil }},
+		{Name: "two words", Build: func(p TextProcessor, _ Args) (TextProcessor, error) { return p, nil }},
+		{Name: "nobuild"},
+		{Name: "flag", Params: []Param{{Name: "on", Kind: BoolParam, Positional: true}},
+			Build: func(p TextProcessor, _ Args) (TextProcessor, error) { return p, nil }},
+		{Name: "number", Params: []Param{{Name: "n", Kind: IntParam, Default: "many"}},
+			Build: func(p TextProcessor, _ Args) (TextProcessor, error) { return p, nil }},
+	}
+	for _, s := range invalid {
+		if err := registry.Register(s); err == nil {
+			t.Errorf("Expected stage %q to be rejected", s.Name)
+		}
+	}
+
+	names := []string{}
+	for _, s := range registry.Stages() {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, " ") != "base64 compress decompress decrypt encrypt hash highlight html indent log markdown metadata plain repeat sanitize translate validate" {
+		t.Errorf("Unexpected stages: %v", names)
+	}
+}
+
+// repeatDecorator is the decorator of the custom stage in TestRegistry
+type repeatDecorator struct {
+	TextProcessorDecorator
+	count     int
+	separator string
+}
+
+func (r *repeatDecorator) Process(text string) (string, error) {
+	processed, err := r.wrapped.Process(text)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.Repeat(processed+r.separator, r.count), r.separator), nil
+}
@@ -0,0 +1,277 @@
+package decorator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SanitizePolicy lists the markup that a SanitizeDecorator keeps.
+type SanitizePolicy struct {
+	// Tags maps the tags to keep to the attributes each of them may have.
+	Tags map[string][]string
+	// GlobalAttributes may appear on any tag that is kept.
+	GlobalAttributes []string
+	// URLSchemes are the schemes allowed in attributes holding URLs, such
+	// as href and src. Relative URLs are always allowed.
+	URLSchemes []string
+}
+
+// DefaultSanitizePolicy returns a policy that keeps common formatting, such
+// as the output of the HTML formatter, links to http, https and mailto
+// URLs, and images.
+func DefaultSanitizePolicy() *SanitizePolicy {
+	p := &SanitizePolicy{
+		Tags:             map[string][]string{},
+		GlobalAttributes: []string{"title", "lang", "dir"},
+		URLSchemes:       []string{"http", "https", "mailto"},
+	}
+	for _, tag := range []string{
+		"html", "body", "p", "br", "hr", "div", "span",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+		"strong", "b", "em", "i", "u", "s", "del", "ins", "mark", "small", "sub", "sup",
+		"code", "pre", "kbd", "samp", "abbr",
+		"ul", "li", "dl", "dt", "dd",
+		"table", "thead", "tbody", "tfoot", "tr", "caption",
+	} {
+		p.AllowTag(tag)
+	}
+	p.AllowTag("a", "href", "rel", "target")
+	p.AllowTag("img", "src", "alt", "width", "height")
+	p.AllowTag("blockquote", "cite")
+	p.AllowTag("q", "cite")
+	p.AllowTag("ol", "start")
+	p.AllowTag("th", "colspan", "rowspan")
+	p.AllowTag("td", "colspan", "rowspan")
+	return p
+}
+
+// AllowTag adds a tag and attributes it may have to the policy.
+func (p *SanitizePolicy) AllowTag(tag string, attributes ...string) {
+	tag = strings.ToLower(tag)
+	p.Tags[tag] = append(p.Tags[tag], attributes...)
+}
+
+// allowsAttribute reports whether tag may have the attribute called name.
+func (p *SanitizePolicy) allowsAttribute(tag, name string) bool {
+	if strings.HasPrefix(name, "on") || forbiddenAttributes[name] {
+		return false
+	}
+	return slices.Contains(p.Tags[tag], name) || slices.Contains(p.GlobalAttributes, name)
+}
+
+// allowsURL reports whether url is relative or has an allowed scheme.
+func (p *SanitizePolicy) allowsURL(url string) bool {
+	// Browsers ignore white space and control characters in schemes, as in
+	// "java\tscript:"
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+	i := strings.IndexAny(cleaned, ":/?#")
+	if i < 0 || cleaned[i] != ':' {
+		return true
+	}
+	return slices.Contains(p.URLSchemes, strings.ToLower(cleaned[:i]))
+}
+
+// unsafeTags are never kept, whatever the policy, and their content is
+// dropped along with them.
+var unsafeTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "base": true, "meta": true, "link": true,
+	"svg": true, "math": true, "template": true, "noscript": true, "noembed": true,
+	"noframes": true, "xmp": true, "plaintext": true,
+}
+
+// forbiddenAttributes are never kept, whatever the policy. Neither are
+// event handlers, whose names start with "on".
+var forbiddenAttributes = map[string]bool{"style": true, "srcdoc": true}
+
+// urlAttributes are the attributes whose values are URLs.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "cite": true, "action": true, "formaction": true,
+	"poster": true, "background": true, "longdesc": true, "usemap": true,
+	"data": true, "codebase": true, "xlink:href": true,
+}
+
+// voidTags have no content or end tag.
+var voidTags = map[string]bool{
+	"area": true, "br": true, "col": true, "hr": true, "img": true,
+	"input": true, "source": true, "track": true, "wbr": true,
+}
+
+// SanitizeDecorator is a concrete decorator that makes HTML safe to show,
+// keeping only the tags and attributes its policy allows.
+//
+// Scripts, styles, embedded content and comments are removed, along with
+// event handlers and URLs with schemes such as javascript:. Other tags
+// that the policy does not allow are removed but their text is kept.
+// Entities are decoded and only &, <, > and " are escaped again, tags are
+// balanced, and links are given rel="noopener".
+type SanitizeDecorator struct {
+	TextProcessorDecorator
+	policy *SanitizePolicy
+}
+
+// NewSanitizeDecorator creates a decorator that sanitizes HTML with policy,
+// or with DefaultSanitizePolicy if policy is nil.
+func NewSanitizeDecorator(processor TextProcessor, policy *SanitizePolicy) *SanitizeDecorator {
+	if policy == nil {
+		policy = DefaultSanitizePolicy()
+	}
+	return &SanitizeDecorator{
+		TextProcessorDecorator: TextProcessorDecorator{
+			wrapped:     processor,
+			name:        "HTML Sanitizer",
+			description: "Removes unsafe HTML",
+			spec:        "sanitize",
+		},
+		policy: policy,
+	}
+}
+
+// Process first processes the text using the wrapped processor,
+// then sanitizes the resulting HTML.
+func (s *SanitizeDecorator) Process(text string) (string, error) {
+	// First, let the wrapped processor do its work
+	processedText, err := s.wrapped.Process(text)
+	if err != nil {
+		return "", fmt.Errorf("error in wrapped processor: %w", err)
+	}
+
+	return s.sanitize(processedText), nil
+}
+
+// sanitize returns the markup of text that the policy allows.
+func (s *SanitizeDecorator) sanitize(text string) string {
+	var out strings.Builder
+	var open []string // Tags that have been kept and not yet closed
+	skip, depth := "", 0
+
+	tokenizer := newHTMLTokenizer(text)
+	for {
+		token, ok := tokenizer.next()
+		if !ok {
+			break
+		}
+
+		// Drop everything inside an element whose content is unsafe
+		if skip != "" {
+			switch {
+			case token.kind == startTagToken && token.data == skip:
+				depth++
+			case token.kind == endTagToken && token.data == skip:
+				if depth--; depth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+
+		switch token.kind {
+		case textToken:
+			out.WriteString(escapeText(token.data))
+		case doctypeToken:
+			if _, ok := s.policy.Tags["html"]; ok {
+				out.WriteString("<!DOCTYPE html>")
+			}
+		case startTagToken:
+			name := token.data
+			_, allowed := s.policy.Tags[name]
+			if unsafeTags[name] || !allowed && rawTextTags[name] {
+				if !voidTags[name] {
+					skip, depth = name, 1
+				}
+				continue
+			}
+			if !allowed {
+				continue
+			}
+			s.writeStartTag(&out, token)
+			if !voidTags[name] {
+				open = append(open, name)
+			}
+		case endTagToken:
+			// Close the element and any left open inside it
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == token.data {
+					for len(open) > i {
+						out.WriteString("</" + open[len(open)-1] + ">")
+						open = open[:len(open)-1]
+					}
+					break
+				}
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i] + ">")
+	}
+	return out.String()
+}
+
+// writeStartTag writes a start tag with the attributes the policy allows.
+func (s *SanitizeDecorator) writeStartTag(out *strings.Builder, token htmlToken) {
+	name := token.data
+	out.WriteString("<" + name)
+	var rel []string
+	hasHref := false
+	for _, attr := range token.attrs {
+		if !s.policy.allowsAttribute(name, attr.name) {
+			continue
+		}
+		if urlAttributes[attr.name] && !s.policy.allowsURL(attr.value) {
+			continue
+		}
+		if attr.name == "srcset" && !s.allowsSrcset(attr.value) {
+			continue
+		}
+		switch attr.name {
+		case "rel":
+			rel = strings.Fields(strings.ToLower(attr.value))
+			continue
+		case "href":
+			hasHref = true
+		}
+		out.WriteString(" " + attr.name + `="` + escapeAttr(attr.value) + `"`)
+	}
+
+	// Stop linked pages from reaching back through window.opener
+	if (name == "a" || name == "area") && hasHref && !slices.Contains(rel, "noopener") {
+		rel = append(rel, "noopener")
+	}
+	if len(rel) > 0 {
+		out.WriteString(` rel="` + escapeAttr(strings.Join(rel, " ")) + `"`)
+	}
+	out.WriteString(">")
+}
+
+// allowsSrcset reports whether all the URLs in a srcset attribute are
+// allowed.
+func (s *SanitizeDecorator) allowsSrcset(value string) bool {
+	for _, candidate := range strings.Split(value, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 && !s.policy.allowsURL(fields[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\x00", "")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\x00", "")
+)
+
+// escapeText escapes text for use between tags.
+func escapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// escapeAttr escapes text for use in a quoted attribute value.
+func escapeAttr(text string) string {
+	return attrEscaper.Replace(text)
+}
@@ -0,0 +1,214 @@
+package decorator
+
+import (
+	"strings"
+	"testing"
+)
+
+// xssVectors are attempts to run script through HTML, collected from the
+// OWASP XSS filter evasion cheat sheet and browser parsing quirks
+var xssVectors = []string{
+	`<script>alert(1)</script>`,
+	`<SCRIPT SRC=http://xss.rocks/xss.js></SCRIPT>`,
+	`<SCRIPT/XSS SRC="http://xss.rocks/xss.js"></SCRIPT>`,
+	`<script/>alert(1)</script>`,
+	`<<SCRIPT>alert("XSS");//<</SCRIPT>`,
+	`<SCRIPT SRC=http://xss.rocks/xss.js?< B >`,
+	`</script><script>alert(1)</script>`,
+	`<scr<script>ipt>alert(1)</script>`,
+	`<IMG SRC="javascript:alert('XSS');">`,
+	`<IMG SRC=javascript:alert('XSS')>`,
+	`<IMG SRC=JaVaScRiPt:alert('XSS')>`,
+	"<IMG SRC=`javascript:alert(\"RSnake says, 'XSS'\")`>",
+	`<IMG """><SCRIPT>alert("XSS")</SCRIPT>">`,
+	`<IMG SRC=&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;&#97;&#108;&#101;&#114;&#116;&#40;&#39;&#88;&#83;&#83;&#39;&#41;>`,
+	`<IMG SRC=&#0000106&#0000097&#0000118&#0000097&#0000115&#0000099&#0000114&#0000105&#0000112&#0000116&#0000058&#0000097&#0000108&#0000101&#0000114&#0000116&#0000040&#0000039&#0000088&#0000083&#0000083&#0000039&#0000041>`,
+	`<IMG SRC=&#x6A&#x61&#x76&#x61&#x73&#x63&#x72&#x69&#x70&#x74&#x3A&#x61&#x6C&#x65&#x72&#x74&#x28&#x27&#x58&#x53&#x53&#x27&#x29>`,
+	"<IMG SRC=\"jav\tascript:alert('XSS');\">",
+	`<IMG SRC="jav&#x09;ascript:alert('XSS');">`,
+	`<IMG SRC="jav&#x0A;ascript:alert('XSS');">`,
+	`<IMG SRC=" &#14;  javascript:alert('XSS');">`,
+	`<IMG SRC="` + "`<javascript:alert>`('XSS')\"",
+	`<img src=x onerror=alert(1)//`,
+	`<img src="x" alt="a" onerror="alert(1)" ONERROR="alert(2)">`,
+	`<img src=x:alert(alt) onerror=eval(src) alt=0>`,
+	`<a onmouseover="alert(document.cookie)">xxs link</a>`,
+	`<a href="javascript:alert(1)">click</a>`,
+	`<a href=javascript:alert(1)>click</a>`,
+	`<a href="JAVASCRIPT&colon;alert(1)">click</a>`,
+	`<a href="&#x6a;avascript:alert(1)">click</a>`,
+	`<a href="  javascript:alert(1)">click</a>`,
+	`<a href="java&#0;script:alert(1)">click</a>`,
+	`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">click</a>`,
+	`<a href="vbscript:msgbox(1)">click</a>`,
+	`<BODY onload!#$%&()*~+-_.,:;?@[/|\]^` + "`" + `=alert("XSS")>`,
+	`<BODY BACKGROUND="javascript:alert('XSS')">`,
+	`<INPUT TYPE="IMAGE" SRC="javascript:alert('XSS');">`,
+	`<iframe src=http://xss.rocks/scriptlet.html <`,
+	`<iframe srcdoc="<script>alert(1)</script>"></iframe>`,
+	`</TITLE><SCRIPT>alert("XSS");</SCRIPT>`,
+	`<title><script>alert(1)</script></title>`,
+	`<textarea><script>alert(1)</script></textarea>`,
+	`<template><script>alert(1)</script></template>`,
+	`<svg/onload=alert('XSS')>`,
+	`<svg><script>alert(1)</script></svg>`,
+	`<svg><svg><a xlink:href="javascript:alert(1)">x</a></svg></svg>`,
+	`<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+	`<STYLE>li {list-style-image: url("javascript:alert('XSS')");}</STYLE><UL><LI>XSS</br>`,
+	`<div style="background-image: url(javascript:alert('XSS'))">x</div>`,
+	`<META HTTP-EQUIV="refresh" CONTENT="0;url=javascript:alert('XSS');">`,
+	`<BASE HREF="javascript:alert('XSS');//">`,
+	`<LINK REL="stylesheet" HREF="javascript:alert('XSS');">`,
+	`<OBJECT TYPE="text/x-scriptlet" DATA="http://xss.rocks/scriptlet.html"></OBJECT>`,
+	`<EMBED SRC="data:image/svg+xml;base64,PHN2Zz48c2NyaXB0PmFsZXJ0KDEpPC9zY3JpcHQ+PC9zdmc+">`,
+	`<!--[if gte IE 4]><SCRIPT>alert('XSS');</SCRIPT><![endif]-->`,
+	`<!--><script>alert(1)</script>-->`,
+	`<!-- --!><script>alert(1)</script> -->`,
+	`<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+	`<xmp><img src=x onerror=alert(1)></xmp>`,
+	`<plaintext><img src=x onerror=alert(1)>`,
+	`<form action="javascript:alert(1)"><button formaction="javascript:alert(1)">x</button></form>`,
+	`<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+	`<a href="/x" onclick=alert(1) onclick="">x</a>`,
+	"<img src=\"x\"\x00onerror=alert(1)>",
+	`<p title="a" title="b" onmouseover=alert(1)>x</p>`,
+	`<a href='javascript:alert(1)' title='"><script>alert(1)</script>'>x</a>`,
+}
+
+// checkSafe fails if html contains markup that policy does not allow
+func checkSafe(t *testing.T, policy *SanitizePolicy, input, html string) {
+	t.Helper()
+	lower := strings.ToLower(html)
+	for _, banned := range []string{"<script", "javascript:", "vbscript:", "data:"} {
+		if strings.Contains(lower, banned) {
+			t.Errorf("Sanitized %q contains %s: %q", input, banned, html)
+		}
+	}
+
+	tokenizer := newHTMLTokenizer(html)
+	for {
+		token, ok := tokenizer.next()
+		if !ok {
+			break
+		}
+		switch token.kind {
+		case startTagToken:
+			if _, allowed := policy.Tags[token.data]; !allowed || unsafeTags[token.data] {
+				t.Errorf("Sanitized %q keeps <%s>: %q", input, token.data, html)
+			}
+			for _, attr := range token.attrs {
+				if attr.name == "rel" {
+					continue
+				}
+				if !policy.allowsAttribute(token.data, attr.name) || urlAttributes[attr.name] && !policy.allowsURL(attr.value) {
+					t.Errorf("Sanitized %q keeps %s=%q: %q", input, attr.name, attr.value, html)
+				}
+			}
+		case commentToken:
+			t.Errorf("Sanitized %q keeps a comment: %q", input, html)
+		}
+	}
+}
+
+// TestSanitizeXSS tests that no XSS vector survives sanitization
+func TestSanitizeXSS(t *testing.T) {
+	sanitizer := NewSanitizeDecorator(NewBasicTextProcessor(), nil)
+	for _, vector := range xssVectors {
+		html, err := sanitizer.Process(vector)
+		if err != nil {
+			t.Fatalf("Process returned error: %v", err)
+		}
+		checkSafe(t, sanitizer.policy, vector, html)
+
+		// Sanitized HTML is left as it is
+		if again, _ := sanitizer.Process(html); again != html {
+			t.Errorf("Sanitizing %q is not stable:\n%q\n%q", vector, html, again)
+		}
+	}
+}
+
+// TestSanitize tests what the sanitizer keeps and how it rewrites it
+func TestSanitize(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`<p>Hello <b onclick="steal()">world</b></p>`, `<p>Hello <b>world</b></p>`},
+		{`<a href="https://example.com" target="_blank">ok</a>`, `<a href="https://example.com" target="_blank" rel="noopener">ok</a>`},
+		{`<a href="/docs" rel="nofollow">docs</a>`, `<a href="/docs" rel="nofollow noopener">docs</a>`},
+		{`<a href="mailto:a@example.com" rel="NOOPENER">mail</a>`, `<a href="mailto:a@example.com" rel="noopener">mail</a>`},
+		{`<a href="javascript:alert(1)">click</a>`, `<a>click</a>`},
+		{`<img src="cat.png" alt="A &quot;cat&quot;" onerror="alert(1)">`, `<img src="cat.png" alt="A &quot;cat&quot;">`},
+		{`<p>Caf&eacute; &amp; &#39;bar&#x27; &AMP; &copy</p>`, `<p>Café &amp; 'bar' &amp; ©</p>`},
+		{`1 < 2 && 3 > 2`, `1 &lt; 2 &amp;&amp; 3 &gt; 2`},
+		{`<p>unclosed <em>text`, `<p>unclosed <em>text</em></p>`},
+		{`<p><em>crossed</p></em>`, `<p><em>crossed</em></p>`},
+		{`</div>stray`, `stray`},
+		{`<font color="red">text</font>`, `text`},
+		{`before<script>alert(1)</script>after`, `beforeafter`},
+		{`<P TITLE=Hi LANG=en>x</P>`, `<p title="Hi" lang="en">x</p>`},
+		{`<br/><hr><img src=a.png />`, `<br><hr><img src="a.png">`},
+		{`<!-- comment -->text<!DOCTYPE html>`, `text<!DOCTYPE html>`},
+	}
+
+	sanitizer := NewSanitizeDecorator(NewBasicTextProcessor(), nil)
+	for _, c := range cases {
+		if got, _ := sanitizer.Process(c.input); got != c.expected {
+			t.Errorf("Sanitizing %q\nExpected: %q\nGot:      %q", c.input, c.expected, got)
+		}
+	}
+}
+
+// TestSanitizePolicy tests custom policies
+func TestSanitizePolicy(t *testing.T) {
+	policy := &SanitizePolicy{Tags: map[string][]string{}, URLSchemes: []string{"https"}}
+	policy.AllowTag("B")
+	policy.AllowTag("a", "href")
+	policy.AllowTag("script")
+	policy.AllowTag("span", "class", "style", "onclick")
+	sanitizer := NewSanitizeDecorator(NewBasicTextProcessor(), policy)
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`<p><b>bold</b> <i>italic</i></p>`, `<b>bold</b> italic`},
+		{`<a href="http://example.com">x</a><a href="https://example.com">y</a>`, `<a>x</a><a href="https://example.com" rel="noopener">y</a>`},
+		{`<script>alert(1)</script>`, ``},
+		{`<span class="note" style="color:red" onclick="x()">s</span>`, `<span class="note">s</span>`},
+		{`<!DOCTYPE html><html><body>x</body></html>`, `x`},
+	}
+	for _, c := range cases {
+		if got, _ := sanitizer.Process(c.input); got != c.expected {
+			t.Errorf("Sanitizing %q\nExpected: %q\nGot:      %q", c.input, c.expected, got)
+		}
+	}
+}
+
+// TestSanitizeFormatting tests sanitizing the output of the formatting
+// decorators, which the default policy keeps
+func TestSanitizeFormatting(t *testing.T) {
+	basic := NewBasicTextProcessor()
+	text := "# Title\n\nSome **bold** text and a [link](https://example.com)."
+
+	formatted, err := NewHTMLFormattingDecorator(basic).Process(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(formatted, `<a href="https://example.com">`, `<a href="https://example.com" rel="noopener">`, 1)
+	if got, _ := NewSanitizeDecorator(NewHTMLFormattingDecorator(basic), nil).Process(text); got != expected {
+		t.Errorf("Sanitizing formatted text changed it.\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	processor := parseSpec(t, "html | highlight(important,start=<mark>,end=</mark>) | sanitize")
+	got, err := processor.Process("An important [link](javascript:alert) <script>alert(1)</script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "<p>An <mark>important</mark> <a>link</a> </p>") {
+		t.Errorf("Unexpected output: %q", got)
+	}
+	if processorSpec(processor) != "html | highlight(important,start=<mark>,end=</mark>) | sanitize" {
+		t.Errorf("Unexpected processing chain: %s", processorSpec(processor))
+	}
+}
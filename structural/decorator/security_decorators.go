@@ -5,57 +5,121 @@ import (
 	"crypto/cipher"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"strconv"
 	"strings"
 )
 
 // EncryptionDecorator is a concrete decorator that encrypts and decrypts text.
+//
+// The gcm mode seals text in an authenticated envelope, so that tampering
+// is detected on decryption. The aes, base64 and rot13 modes are kept for
+// reading existing data.
 type EncryptionDecorator struct {
 	TextProcessorDecorator
 	key         []byte
 	encrypt     bool
 	encryptMode string
+	keyring     *Keyring             // Keys of the gcm mode
+	legacy      *EncryptionDecorator // Decrypts text that is not an envelope
 }
 
-// NewEncryptionDecorator creates a decorator that encrypts text.
+// NewEncryptionDecorator creates a decorator that encrypts text. The gcm
+// mode uses a keyring holding key as its only key; use
+// NewKeyringEncryptionDecorator for key rotation.
 func NewEncryptionDecorator(processor TextProcessor, key string, mode string) *EncryptionDecorator {
-	// Create a fixed size key using MD5 (for simplicity - not for production use)
-	hasher := md5.New()
-	hasher.Write([]byte(key))
-	keyBytes := hasher.Sum(nil)
+	keyBytes := deriveKey(key)
 
 	return &EncryptionDecorator{
 		TextProcessorDecorator: TextProcessorDecorator{
 			wrapped:     processor,
 			name:        "Encryption Processor",
 			description: fmt.Sprintf("Encrypts text using %s mode", mode),
+			spec:        cryptSpec("encrypt", mode),
 		},
 		key:         keyBytes,
 		encrypt:     true,
 		encryptMode: mode,
+		keyring:     passphraseKeyring(key),
 	}
 }
 
 // NewDecryptionDecorator creates a decorator that decrypts text.
 func NewDecryptionDecorator(processor TextProcessor, key string, mode string) *EncryptionDecorator {
-	// Create a fixed size key using MD5 (for simplicity - not for production use)
-	hasher := md5.New()
-	hasher.Write([]byte(key))
-	keyBytes := hasher.Sum(nil)
+	keyBytes := deriveKey(key)
 
 	return &EncryptionDecorator{
 		TextProcessorDecorator: TextProcessorDecorator{
 			wrapped:     processor,
 			name:        "Decryption Processor",
 			description: fmt.Sprintf("Decrypts text using %s mode", mode),
+			spec:        cryptSpec("decrypt", mode),
 		},
 		key:         keyBytes,
 		encrypt:     false,
 		encryptMode: mode,
+		keyring:     passphraseKeyring(key),
+	}
+}
+
+// NewKeyringEncryptionDecorator creates a decorator that encrypts text in
+// gcm mode with the primary key of keyring.
+func NewKeyringEncryptionDecorator(processor TextProcessor, keyring *Keyring) *EncryptionDecorator {
+	return &EncryptionDecorator{
+		TextProcessorDecorator: TextProcessorDecorator{
+			wrapped:     processor,
+			name:        "Encryption Processor",
+			description: "Encrypts text using gcm mode with a keyring",
+			spec:        cryptSpec("encrypt", "gcm"),
+		},
+		encrypt:     true,
+		encryptMode: "gcm",
+		keyring:     keyring,
+	}
+}
+
+// NewKeyringDecryptionDecorator creates a decorator that decrypts text in
+// gcm mode with the keys of keyring. If legacyMode is not empty, text that
+// is not an envelope is decrypted with legacyKey in that mode instead, so
+// that data written before the switch to gcm can still be read. As the
+// legacy modes are not authenticated, leave legacyMode empty once that
+// data has been re-encrypted.
+func NewKeyringDecryptionDecorator(processor TextProcessor, keyring *Keyring, legacyKey, legacyMode string) *EncryptionDecorator {
+	d := &EncryptionDecorator{
+		TextProcessorDecorator: TextProcessorDecorator{
+			wrapped:     processor,
+			name:        "Decryption Processor",
+			description: "Decrypts text using gcm mode with a keyring",
+			spec:        cryptSpec("decrypt", "gcm"),
+		},
+		encrypt:     false,
+		encryptMode: "gcm",
+		keyring:     keyring,
 	}
+	if legacyMode != "" {
+		d.legacy = NewDecryptionDecorator(NewBasicTextProcessor(), legacyKey, legacyMode)
+	}
+	return d
+}
+
+// passphraseKeyring returns a keyring holding key as its only key.
+func passphraseKeyring(key string) *Keyring {
+	keyring := NewKeyring()
+	keyring.Add("default", key)
+	return keyring
+}
+
+// deriveKey creates a fixed size key from a passphrase using MD5 (for
+// simplicity - not for production use).
+func deriveKey(key string) []byte {
+	hasher := md5.New()
+	hasher.Write([]byte(key))
+	return hasher.Sum(nil)
 }
 
 // Process first processes the text using the wrapped processor,
@@ -70,6 +134,8 @@ func (e *EncryptionDecorator) Process(text string) (string, error) {
 	// Then apply encryption or decryption
 	if e.encrypt {
 		switch e.encryptMode {
+		case "gcm":
+			return e.keyring.Seal(processedText)
 		case "aes":
 			return e.encryptAES(processedText)
 		case "base64":
@@ -81,6 +147,11 @@ func (e *EncryptionDecorator) Process(text string) (string, error) {
 		}
 	} else {
 		switch e.encryptMode {
+		case "gcm":
+			if e.legacy != nil && !isEnvelope(processedText) {
+				return e.legacy.Process(processedText)
+			}
+			return e.keyring.Open(processedText)
 		case "aes":
 			return e.decryptAES(processedText)
 		case "base64":
@@ -93,7 +164,8 @@ func (e *EncryptionDecorator) Process(text string) (string, error) {
 	}
 }
 
-// encryptAES encrypts text using AES-256 in CFB mode.
+// encryptAES encrypts text using AES-128 in CFB mode. It does not detect
+// tampering, and is kept for compatibility; prefer the gcm mode.
 func (e *EncryptionDecorator) encryptAES(text string) (string, error) {
 	block, err := aes.NewCipher(e.key)
 	if err != nil {
@@ -115,7 +187,7 @@ func (e *EncryptionDecorator) encryptAES(text string) (string, error) {
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-// decryptAES decrypts text using AES-256 in CFB mode.
+// decryptAES decrypts text using AES-128 in CFB mode.
 func (e *EncryptionDecorator) decryptAES(text string) (string, error) {
 	ciphertext, err := base64.StdEncoding.DecodeString(text)
 	if err != nil {
@@ -193,6 +265,7 @@ func NewHashingDecorator(processor TextProcessor, algorithm string, appendHash b
 			wrapped:     processor,
 			name:        "Hashing Processor",
 			description: fmt.Sprintf("Hashes text using %s algorithm", algorithm),
+			spec:        stageSpec("hash", hashParams, map[string]string{"algorithm": algorithm, "append": strconv.FormatBool(appendHash)}),
 		},
 		algorithm: algorithm,
 		appendHash: appendHash,
@@ -209,15 +282,12 @@ func (h *HashingDecorator) Process(text string) (string, error) {
 	}
 
 	// Calculate the hash
-	var hash string
-	switch h.algorithm {
-	case "md5":
-		hasher := md5.New()
-		hasher.Write([]byte(processedText))
-		hash = hex.EncodeToString(hasher.Sum(nil))
-	default:
-		return processedText, fmt.Errorf("unsupported hash algorithm: %s", h.algorithm)
+	hasher, err := newHash(h.algorithm)
+	if err != nil {
+		return processedText, err
 	}
+	hasher.Write([]byte(processedText))
+	hash := hex.EncodeToString(hasher.Sum(nil))
 
 	// Either append the hash or return it
 	if h.appendHash {
@@ -226,6 +296,18 @@ func (h *HashingDecorator) Process(text string) (string, error) {
 	return hash, nil
 }
 
+// newHash returns a hasher for the specified algorithm.
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algorithm)
+	}
+}
+
 // ValidationDecorator is a concrete decorator that validates text.
 type ValidationDecorator struct {
 	TextProcessorDecorator
@@ -233,17 +315,42 @@ type ValidationDecorator struct {
 }
 
 // NewValidationDecorator creates a decorator that validates text.
+// As validators are functions, the decorator appears in its processing
+// chain as a plain "validate" stage; use ParsePipeline to build validation
+// that can be printed in full.
 func NewValidationDecorator(processor TextProcessor, validators ...func(string) error) *ValidationDecorator {
 	return &ValidationDecorator{
 		TextProcessorDecorator: TextProcessorDecorator{
 			wrapped:     processor,
 			name:        "Validation Processor",
 			description: "Validates text against specified rules",
+			spec:        "validate",
 		},
 		validators: validators,
 	}
 }
 
+// newValidationDecoratorFromArgs creates a ValidationDecorator from the
+// arguments of a validate stage.
+func newValidationDecoratorFromArgs(processor TextProcessor, args Args) *ValidationDecorator {
+	var validators []func(string) error
+	if args.Bool("notempty") {
+		validators = append(validators, ValidateNotEmpty)
+	}
+	if minLength := args.Int("min"); minLength > 0 {
+		validators = append(validators, ValidateMinLength(minLength))
+	}
+	if maxLength := args.Int("max"); maxLength > 0 {
+		validators = append(validators, ValidateMaxLength(maxLength))
+	}
+	if contains := args.String("contains"); contains != "" {
+		validators = append(validators, ValidateContains(contains))
+	}
+	v := NewValidationDecorator(processor, validators...)
+	v.spec = args.spec("validate", validateParams)
+	return v
+}
+
 // Process first processes the text using the wrapped processor,
 // then validates the text against the specified rules.
 func (v *ValidationDecorator) Process(text string) (string, error) {
@@ -0,0 +1,411 @@
+package decorator
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CompressionStreamDecorator is the streaming counterpart of
+// CompressionDecorator. It produces the same output for the same settings.
+type CompressionStreamDecorator struct {
+	StreamProcessorDecorator
+	algorithm    string
+	compress     bool
+	encodeBase64 bool
+}
+
+// NewCompressionStreamDecorator creates a stream decorator that compresses data.
+func NewCompressionStreamDecorator(processor StreamProcessor, algorithm string, encodeBase64 bool) *CompressionStreamDecorator {
+	return &CompressionStreamDecorator{
+		StreamProcessorDecorator: StreamProcessorDecorator{
+			wrapped:     processor,
+			name:        "Compression Processor",
+			description: fmt.Sprintf("Compresses data using %s algorithm", algorithm),
+			spec:        stageSpec("compress", compressParams, map[string]string{"algorithm": algorithm, "base64": strconv.FormatBool(encodeBase64)}),
+		},
+		algorithm:    algorithm,
+		compress:     true,
+		encodeBase64: encodeBase64,
+	}
+}
+
+// NewDecompressionStreamDecorator creates a stream decorator that decompresses data.
+func NewDecompressionStreamDecorator(processor StreamProcessor, algorithm string, decodeBase64 bool) *CompressionStreamDecorator {
+	return &CompressionStreamDecorator{
+		StreamProcessorDecorator: StreamProcessorDecorator{
+			wrapped:     processor,
+			name:        "Decompression Processor",
+			description: fmt.Sprintf("Decompresses data using %s algorithm", algorithm),
+			spec:        stageSpec("decompress", compressParams, map[string]string{"algorithm": algorithm, "base64": strconv.FormatBool(decodeBase64)}),
+		},
+		algorithm:    algorithm,
+		compress:     false,
+		encodeBase64: decodeBase64,
+	}
+}
+
+// NewWriter returns a writer that passes data through the wrapped processor,
+// then compresses or decompresses it and writes the result to w.
+func (c *CompressionStreamDecorator) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if err := c.checkAlgorithm(); err != nil {
+		return nil, err
+	}
+	if c.compress {
+		return c.chainWriter(w, c.compressor)
+	}
+	return c.chainWriter(w, func(w io.Writer) (io.WriteCloser, error) {
+		return pipeWriter(w, c.decompressor), nil
+	})
+}
+
+// NewReader returns a reader of the data read from r through the wrapped
+// processor, compressed or decompressed.
+func (c *CompressionStreamDecorator) NewReader(r io.Reader) (io.ReadCloser, error) {
+	if err := c.checkAlgorithm(); err != nil {
+		return nil, err
+	}
+	if c.compress {
+		return c.chainReader(r, func(r io.Reader) (io.ReadCloser, error) {
+			return pipeReader(r, c.compressor), nil
+		})
+	}
+	return c.chainReader(r, c.decompressor)
+}
+
+// checkAlgorithm returns an error if the algorithm is not supported.
+func (c *CompressionStreamDecorator) checkAlgorithm() error {
+	switch c.algorithm {
+	case "gzip", "zlib":
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression algorithm: %s", c.algorithm)
+	}
+}
+
+// compressor returns a writer that compresses data into w.
+func (c *CompressionStreamDecorator) compressor(w io.Writer) (io.WriteCloser, error) {
+	var encoder io.WriteCloser = nopWriteCloser{w}
+	if c.encodeBase64 {
+		encoder = base64.NewEncoder(base64.StdEncoding, w)
+	}
+
+	var compressor io.WriteCloser
+	switch c.algorithm {
+	case "gzip":
+		compressor = gzip.NewWriter(encoder)
+	case "zlib":
+		compressor = zlib.NewWriter(encoder)
+	}
+	return &chainedWriter{inner: compressor, outer: encoder}, nil
+}
+
+// decompressor returns a reader of the decompressed data in r.
+func (c *CompressionStreamDecorator) decompressor(r io.Reader) (io.ReadCloser, error) {
+	if c.encodeBase64 {
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+
+	switch c.algorithm {
+	case "gzip":
+		decompressor, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip reader creation error: %w", err)
+		}
+		return decompressor, nil
+	default:
+		decompressor, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("zlib reader creation error: %w", err)
+		}
+		return decompressor, nil
+	}
+}
+
+// EncryptionStreamDecorator is the streaming counterpart of
+// EncryptionDecorator. Its output can be decrypted by EncryptionDecorator
+// and the other way round, as both use the same keys and formats.
+type EncryptionStreamDecorator struct {
+	StreamProcessorDecorator
+	key         []byte
+	encrypt     bool
+	encryptMode string
+}
+
+// NewEncryptionStreamDecorator creates a stream decorator that encrypts data.
+func NewEncryptionStreamDecorator(processor StreamProcessor, key string, mode string) *EncryptionStreamDecorator {
+	return &EncryptionStreamDecorator{
+		StreamProcessorDecorator: StreamProcessorDecorator{
+			wrapped:     processor,
+			name:        "Encryption Processor",
+			description: fmt.Sprintf("Encrypts data using %s mode", mode),
+			spec:        cryptSpec("encrypt", mode),
+		},
+		key:         deriveKey(key),
+		encrypt:     true,
+		encryptMode: mode,
+	}
+}
+
+// NewDecryptionStreamDecorator creates a stream decorator that decrypts data.
+func NewDecryptionStreamDecorator(processor StreamProcessor, key string, mode string) *EncryptionStreamDecorator {
+	return &EncryptionStreamDecorator{
+		StreamProcessorDecorator: StreamProcessorDecorator{
+			wrapped:     processor,
+			name:        "Decryption Processor",
+			description: fmt.Sprintf("Decrypts data using %s mode", mode),
+			spec:        cryptSpec("decrypt", mode),
+		},
+		key:         deriveKey(key),
+		encrypt:     false,
+		encryptMode: mode,
+	}
+}
+
+// NewWriter returns a writer that passes data through the wrapped processor,
+// then encrypts or decrypts it and writes the result to w.
+func (e *EncryptionStreamDecorator) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	switch e.encryptMode {
+	case "aes", "base64":
+	case "rot13":
+		return e.chainWriter(w, func(w io.Writer) (io.WriteCloser, error) {
+			return nopWriteCloser{rot13Writer{w}}, nil
+		})
+	default:
+		return nil, e.unsupported()
+	}
+	if e.encrypt {
+		return e.chainWriter(w, e.encrypter)
+	}
+	return e.chainWriter(w, func(w io.Writer) (io.WriteCloser, error) {
+		return pipeWriter(w, e.decrypter), nil
+	})
+}
+
+// NewReader returns a reader of the data read from r through the wrapped
+// processor, encrypted or decrypted.
+func (e *EncryptionStreamDecorator) NewReader(r io.Reader) (io.ReadCloser, error) {
+	switch e.encryptMode {
+	case "aes", "base64":
+	case "rot13":
+		return e.chainReader(r, func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(rot13Reader{r}), nil
+		})
+	default:
+		return nil, e.unsupported()
+	}
+	if e.encrypt {
+		return e.chainReader(r, func(r io.Reader) (io.ReadCloser, error) {
+			return pipeReader(r, e.encrypter), nil
+		})
+	}
+	return e.chainReader(r, e.decrypter)
+}
+
+// unsupported returns the error for an unsupported mode.
+func (e *EncryptionStreamDecorator) unsupported() error {
+	if e.encrypt {
+		return fmt.Errorf("unsupported encryption mode: %s", e.encryptMode)
+	}
+	return fmt.Errorf("unsupported decryption mode: %s", e.encryptMode)
+}
+
+// encrypter returns a writer that encrypts data into w. In aes mode the
+// output is the base64 encoded initialization vector and ciphertext of
+// AES-256 in CFB mode, as written by EncryptionDecorator.
+func (e *EncryptionStreamDecorator) encrypter(w io.Writer) (io.WriteCloser, error) {
+	encoder := base64.NewEncoder(base64.StdEncoding, w)
+	if e.encryptMode == "base64" {
+		return encoder, nil
+	}
+
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+	if _, err := encoder.Write(iv); err != nil {
+		return nil, err
+	}
+	// Closing the stream writer closes the encoder, which flushes it
+	return &cipher.StreamWriter{S: cipher.NewCFBEncrypter(block, iv), W: encoder}, nil
+}
+
+// decrypter returns a reader of the decrypted data in r.
+func (e *EncryptionStreamDecorator) decrypter(r io.Reader) (io.ReadCloser, error) {
+	decoder := base64.NewDecoder(base64.StdEncoding, r)
+	if e.encryptMode == "base64" {
+		return io.NopCloser(decoder), nil
+	}
+
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(decoder, iv); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("ciphertext too short")
+		}
+		return nil, err
+	}
+	return io.NopCloser(cipher.StreamReader{S: cipher.NewCFBDecrypter(block, iv), R: decoder}), nil
+}
+
+// rot13Writer applies ROT13 to the data written to it.
+type rot13Writer struct {
+	w io.Writer
+}
+
+// Write writes p to the underlying writer with ROT13 applied.
+func (r rot13Writer) Write(p []byte) (int, error) {
+	out := make([]byte, len(p))
+	for i, b := range p {
+		out[i] = byte(rot13(rune(b)))
+	}
+	return r.w.Write(out)
+}
+
+// rot13Reader applies ROT13 to the data read through it.
+type rot13Reader struct {
+	r io.Reader
+}
+
+// Read reads from the underlying reader and applies ROT13.
+func (r rot13Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i, b := range p[:n] {
+		p[i] = byte(rot13(rune(b)))
+	}
+	return n, err
+}
+
+// HashingStreamDecorator is the streaming counterpart of HashingDecorator.
+// The hash is computed incrementally as data passes through.
+type HashingStreamDecorator struct {
+	StreamProcessorDecorator
+	algorithm  string
+	appendHash bool
+}
+
+// NewHashingStreamDecorator creates a stream decorator that hashes data.
+// If appendHash is set the data is passed on followed by its hash, as by
+// HashingDecorator; otherwise only the hash is.
+func NewHashingStreamDecorator(processor StreamProcessor, algorithm string, appendHash bool) *HashingStreamDecorator {
+	return &HashingStreamDecorator{
+		StreamProcessorDecorator: StreamProcessorDecorator{
+			wrapped:     processor,
+			name:        "Hashing Processor",
+			description: fmt.Sprintf("Hashes data using %s algorithm", algorithm),
+			spec:        stageSpec("hash", hashParams, map[string]string{"algorithm": algorithm, "append": strconv.FormatBool(appendHash)}),
+		},
+		algorithm:  algorithm,
+		appendHash: appendHash,
+	}
+}
+
+// NewWriter returns a writer that passes data through the wrapped processor,
+// then hashes it and writes the result to w.
+func (h *HashingStreamDecorator) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	hasher, err := newHash(h.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return h.chainWriter(w, func(w io.Writer) (io.WriteCloser, error) {
+		return &hashWriter{w: w, hash: hasher, appendHash: h.appendHash}, nil
+	})
+}
+
+// NewReader returns a reader of the data read from r through the wrapped
+// processor, hashed.
+func (h *HashingStreamDecorator) NewReader(r io.Reader) (io.ReadCloser, error) {
+	hasher, err := newHash(h.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return h.chainReader(r, func(r io.Reader) (io.ReadCloser, error) {
+		return &hashReader{r: r, hash: hasher, appendHash: h.appendHash}, nil
+	})
+}
+
+// hashTrailer returns what follows the data once it has been hashed.
+func hashTrailer(hasher hash.Hash, appendHash bool) string {
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if appendHash {
+		return fmt.Sprintf(" [Hash: %s]", sum)
+	}
+	return sum
+}
+
+// hashWriter is the writer returned by HashingStreamDecorator.NewWriter.
+type hashWriter struct {
+	w          io.Writer
+	hash       hash.Hash
+	appendHash bool
+}
+
+// Write hashes p and, if the hash is appended, writes it on.
+func (h *hashWriter) Write(p []byte) (int, error) {
+	h.hash.Write(p)
+	if h.appendHash {
+		return h.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// Close writes the hash.
+func (h *hashWriter) Close() error {
+	_, err := io.WriteString(h.w, hashTrailer(h.hash, h.appendHash))
+	return err
+}
+
+// hashReader is the reader returned by HashingStreamDecorator.NewReader.
+type hashReader struct {
+	r          io.Reader
+	hash       hash.Hash
+	appendHash bool
+	trailer    *strings.Reader
+}
+
+// Read returns the data, if the hash is appended, and then the hash.
+func (h *hashReader) Read(p []byte) (int, error) {
+	if h.trailer != nil {
+		return h.trailer.Read(p)
+	}
+	if !h.appendHash {
+		if _, err := io.Copy(h.hash, h.r); err != nil {
+			return 0, err
+		}
+		h.trailer = strings.NewReader(hashTrailer(h.hash, false))
+		return h.trailer.Read(p)
+	}
+
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF {
+		h.trailer = strings.NewReader(hashTrailer(h.hash, true))
+		if n == 0 {
+			return h.trailer.Read(p)
+		}
+		err = nil
+	}
+	return n, err
+}
+
+// Close does nothing.
+func (h *hashReader) Close() error {
+	return nil
+}
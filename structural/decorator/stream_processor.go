@@ -0,0 +1,364 @@
+package decorator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamProcessor is the streaming counterpart of TextProcessor.
+// Rather than taking whole documents as strings, it wraps the io.Writer
+// that processed data is written to or the io.Reader it is read from,
+// so that data of any size can be processed in constant memory.
+type StreamProcessor interface {
+	// NewWriter returns a writer that processes the data written to it and
+	// writes the result to w. Closing the writer flushes the result, but
+	// does not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader of the processed data read from r.
+	// Closing the reader releases its resources, but does not close r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+
+	// GetName returns the name of the processor.
+	GetName() string
+
+	// GetDescription returns information about what the processor does.
+	GetDescription() string
+}
+
+// BasicStreamProcessor is the ConcreteComponent of stream processing chains.
+// It passes data through unchanged.
+type BasicStreamProcessor struct {
+	name        string
+	description string
+}
+
+// NewBasicStreamProcessor creates a new BasicStreamProcessor.
+func NewBasicStreamProcessor() *BasicStreamProcessor {
+	return &BasicStreamProcessor{
+		name:        "Basic Stream Processor",
+		description: "Passes data through without any transformations",
+	}
+}
+
+// NewWriter returns a writer that writes data to w unchanged.
+func (b *BasicStreamProcessor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+// NewReader returns a reader of the data in r unchanged.
+func (b *BasicStreamProcessor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+// GetName returns the name of the processor.
+func (b *BasicStreamProcessor) GetName() string {
+	return b.name
+}
+
+// GetDescription returns information about what the processor does.
+func (b *BasicStreamProcessor) GetDescription() string {
+	return b.description
+}
+
+// StreamProcessorDecorator is the base Decorator of stream processing chains.
+// It wraps a StreamProcessor and delegates operations to it.
+type StreamProcessorDecorator struct {
+	wrapped     StreamProcessor
+	name        string
+	description string
+	spec        string // The decorator as a stage of a pipeline spec
+}
+
+// NewWriter delegates to the wrapped StreamProcessor.
+func (d *StreamProcessorDecorator) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return d.wrapped.NewWriter(w)
+}
+
+// NewReader delegates to the wrapped StreamProcessor.
+func (d *StreamProcessorDecorator) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return d.wrapped.NewReader(r)
+}
+
+// GetName returns the name of the decorator.
+func (d *StreamProcessorDecorator) GetName() string {
+	return d.name
+}
+
+// GetDescription returns information about what the decorator does.
+func (d *StreamProcessorDecorator) GetDescription() string {
+	return d.description
+}
+
+// GetWrappedName returns the name of the wrapped processor.
+func (d *StreamProcessorDecorator) GetWrappedName() string {
+	return d.wrapped.GetName()
+}
+
+// GetProcessingChain returns the chain of processors as a pipeline spec.
+func (d *StreamProcessorDecorator) GetProcessingChain() string {
+	return chainSpec(d.wrapped, d.spec)
+}
+
+// chainWriter returns a writer that passes data through the wrapped
+// processor and then through the writer that stage returns for w.
+func (d *StreamProcessorDecorator) chainWriter(w io.Writer, stage func(io.Writer) (io.WriteCloser, error)) (io.WriteCloser, error) {
+	outer, err := stage(w)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := d.wrapped.NewWriter(outer)
+	if err != nil {
+		outer.Close()
+		return nil, fmt.Errorf("error in wrapped processor: %w", err)
+	}
+	return &chainedWriter{inner: inner, outer: outer}, nil
+}
+
+// chainReader returns a reader of the data read from r through the wrapped
+// processor and then through the reader that stage returns.
+func (d *StreamProcessorDecorator) chainReader(r io.Reader, stage func(io.Reader) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	inner, err := d.wrapped.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error in wrapped processor: %w", err)
+	}
+	outer, err := stage(inner)
+	if err != nil {
+		inner.Close()
+		return nil, err
+	}
+	return &chainedReader{inner: inner, outer: outer}, nil
+}
+
+// chainedWriter writes to inner, which writes to outer. Closing it closes
+// inner first, so that it flushes into outer.
+type chainedWriter struct {
+	inner, outer io.WriteCloser
+}
+
+// Write writes p to the inner writer.
+func (c *chainedWriter) Write(p []byte) (int, error) {
+	return c.inner.Write(p)
+}
+
+// Close closes the inner writer and then the outer one.
+func (c *chainedWriter) Close() error {
+	err := c.inner.Close()
+	if closeErr := c.outer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// chainedReader reads from outer, which reads from inner. Closing it
+// closes both.
+type chainedReader struct {
+	inner, outer io.ReadCloser
+}
+
+// Read reads from the outer reader.
+func (c *chainedReader) Read(p []byte) (int, error) {
+	return c.outer.Read(p)
+}
+
+// Close closes the outer reader and then the inner one.
+func (c *chainedReader) Close() error {
+	err := c.outer.Close()
+	if closeErr := c.inner.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// nopWriteCloser is a writer whose Close does nothing.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing.
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// pipeReader returns a reader of the data that the writer returned by stage
+// produces from the content of r. The writer runs in its own goroutine.
+func pipeReader(r io.Reader, stage func(io.Writer) (io.WriteCloser, error)) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := stage(pw)
+		if err == nil {
+			_, err = io.Copy(w, r)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// pipeWriter returns a writer that passes the data written to it through
+// the reader returned by stage and on to w. The reader runs in its own
+// goroutine, whose error is returned by Close.
+func pipeWriter(w io.Writer, stage func(io.Reader) (io.ReadCloser, error)) io.WriteCloser {
+	pr, pw := io.Pipe()
+	p := &pipedWriter{PipeWriter: pw, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		r, err := stage(pr)
+		if err == nil {
+			_, err = io.Copy(w, r)
+			if closeErr := r.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		// Fail any further writes rather than block them
+		pr.CloseWithError(err)
+		p.err = err
+	}()
+	return p
+}
+
+// pipedWriter is the writer returned by pipeWriter.
+type pipedWriter struct {
+	*io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+// Close signals the end of the data and waits for it to be processed.
+func (p *pipedWriter) Close() error {
+	p.PipeWriter.Close()
+	<-p.done
+	return p.err
+}
+
+// StreamAdapter adapts a TextProcessor to the StreamProcessor interface.
+// As a TextProcessor needs whole documents, the adapter reads all of its
+// input into memory before processing it. It lets any decorator take part
+// in a stream processing chain.
+type StreamAdapter struct {
+	processor TextProcessor
+}
+
+// NewStreamAdapter creates a StreamProcessor that processes data with processor.
+func NewStreamAdapter(processor TextProcessor) *StreamAdapter {
+	return &StreamAdapter{processor: processor}
+}
+
+// NewWriter returns a writer that collects the data written to it and
+// writes the processed text to w when it is closed.
+func (a *StreamAdapter) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return &bufferedWriter{w: w, processor: a.processor}, nil
+}
+
+// NewReader returns a reader of the processed text of r, which is read in
+// full on the first call to Read.
+func (a *StreamAdapter) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return &bufferedReader{r: r, processor: a.processor}, nil
+}
+
+// GetName returns the name of the adapted processor.
+func (a *StreamAdapter) GetName() string {
+	return a.processor.GetName()
+}
+
+// GetDescription returns the description of the adapted processor.
+func (a *StreamAdapter) GetDescription() string {
+	return a.processor.GetDescription()
+}
+
+// GetProcessingChain returns the processing chain of the adapted processor.
+func (a *StreamAdapter) GetProcessingChain() string {
+	return processorSpec(a.processor)
+}
+
+// bufferedWriter is the writer returned by StreamAdapter.NewWriter.
+type bufferedWriter struct {
+	bytes.Buffer
+	w         io.Writer
+	processor TextProcessor
+}
+
+// Close processes the collected text and writes the result.
+func (b *bufferedWriter) Close() error {
+	result, err := b.processor.Process(b.String())
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(b.w, result)
+	return err
+}
+
+// bufferedReader is the reader returned by StreamAdapter.NewReader.
+type bufferedReader struct {
+	r         io.Reader
+	processor TextProcessor
+	result    *strings.Reader
+}
+
+// Read returns the processed text, processing the input on the first call.
+func (b *bufferedReader) Read(p []byte) (int, error) {
+	if b.result == nil {
+		data, err := io.ReadAll(b.r)
+		if err != nil {
+			return 0, err
+		}
+		result, err := b.processor.Process(string(data))
+		if err != nil {
+			return 0, err
+		}
+		b.result = strings.NewReader(result)
+	}
+	return b.result.Read(p)
+}
+
+// Close does nothing.
+func (b *bufferedReader) Close() error {
+	return nil
+}
+
+// TextAdapter adapts a StreamProcessor to the TextProcessor interface, so
+// that a stream processing chain can be used wherever a TextProcessor is.
+type TextAdapter struct {
+	processor StreamProcessor
+}
+
+// NewTextAdapter creates a TextProcessor that processes text with processor.
+func NewTextAdapter(processor StreamProcessor) *TextAdapter {
+	return &TextAdapter{processor: processor}
+}
+
+// Process writes text through the stream processor and returns the result.
+func (a *TextAdapter) Process(text string) (string, error) {
+	var result strings.Builder
+	w, err := a.processor.NewWriter(&result)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.WriteString(w, text)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// GetName returns the name of the adapted processor.
+func (a *TextAdapter) GetName() string {
+	return a.processor.GetName()
+}
+
+// GetDescription returns the description of the adapted processor.
+func (a *TextAdapter) GetDescription() string {
+	return a.processor.GetDescription()
+}
+
+// GetProcessingChain returns the processing chain of the adapted processor.
+func (a *TextAdapter) GetProcessingChain() string {
+	return processorSpec(a.processor)
+}
@@ -0,0 +1,307 @@
+package decorator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// logReader produces an endless log, one numbered line at a time
+type logReader struct {
+	line    int
+	pending []byte
+}
+
+func (l *logReader) Read(p []byte) (int, error) {
+	if len(l.pending) == 0 {
+		l.line++
+		l.pending = []byte(fmt.Sprintf("2025-04-25T12:00:00Z INFO request %d served in %dms\n", l.line, l.line%97))
+	}
+	n := copy(p, l.pending)
+	l.pending = l.pending[n:]
+	return n, nil
+}
+
+// streamText processes text with the writer and the reader of processor,
+// writing and reading one byte at a time, and checks that both agree
+func streamText(t *testing.T, processor StreamProcessor, text string) string {
+	t.Helper()
+	var written bytes.Buffer
+	w, err := processor.NewWriter(&written)
+	if err != nil {
+		t.Fatalf("NewWriter returned error: %v", err)
+	}
+	for i := 0; i < len(text); i++ {
+		if _, err := w.Write([]byte{text[i]}); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	r, err := processor.NewReader(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+	read, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	r.Close()
+
+	if string(read) != written.String() {
+		t.Fatalf("Reader and writer disagree.\nWriter: %q\nReader: %q", written.String(), read)
+	}
+	return written.String()
+}
+
+// TestStreamMatchesText tests that stream decorators produce the same output
+// as the string-based decorators they mirror
+func TestStreamMatchesText(t *testing.T) {
+	basic := NewBasicTextProcessor()
+	stream := NewBasicStreamProcessor()
+	text := "This is a test message that should be compressible with lots of repeating text. " +
+		"This is a test message that should be compressible with lots of repeating text. Ünïcödé."
+
+	cases := []struct {
+		name      string
+		processor TextProcessor
+		stream    StreamProcessor
+		input     string
+	}{
+		{"gzip", NewCompressionDecorator(basic, "gzip", true), NewCompressionStreamDecorator(stream, "gzip", true), text},
+		{"zlib", NewCompressionDecorator(basic, "zlib", false), NewCompressionStreamDecorator(stream, "zlib", false), text},
+		{"base64", NewEncryptionDecorator(basic, "key", "base64"), NewEncryptionStreamDecorator(stream, "key", "base64"), text},
+		{"rot13", NewEncryptionDecorator(basic, "key", "rot13"), NewEncryptionStreamDecorator(stream, "key", "rot13"), text},
+		{"md5 appended", NewHashingDecorator(basic, "md5", true), NewHashingStreamDecorator(stream, "md5", true), text},
+		{"sha256", NewHashingDecorator(basic, "sha256", false), NewHashingStreamDecorator(stream, "sha256", false), text},
+		{"empty", NewHashingDecorator(basic, "md5", true), NewHashingStreamDecorator(stream, "md5", true), ""},
+		{"chain",
+			NewHashingDecorator(NewCompressionDecorator(basic, "gzip", true), "md5", true),
+			NewHashingStreamDecorator(NewCompressionStreamDecorator(stream, "gzip", true), "md5", true),
+			text},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expected, err := c.processor.Process(c.input)
+			if err != nil {
+				t.Fatalf("Process returned error: %v", err)
+			}
+			if got := streamText(t, c.stream, c.input); got != expected {
+				t.Errorf("Stream output differs.\nExpected: %q\nGot:      %q", expected, got)
+			}
+			if got, err := NewTextAdapter(c.stream).Process(c.input); err != nil || got != expected {
+				t.Errorf("TextAdapter returned %q, %v", got, err)
+			}
+		})
+	}
+}
+
+// TestStreamRoundTrip tests that streamed encryption and compression can be
+// reversed by both the stream and the string-based decorators
+func TestStreamRoundTrip(t *testing.T) {
+	stream := NewBasicStreamProcessor()
+	basic := NewBasicTextProcessor()
+	text := strings.Repeat("Confidential log line with some repetition.\n", 50)
+
+	for _, mode := range []string{"aes", "base64", "rot13"} {
+		t.Run(mode, func(t *testing.T) {
+			encrypt := NewEncryptionStreamDecorator(NewCompressionStreamDecorator(stream, "gzip", false), "secret", mode)
+			decrypt := NewDecompressionStreamDecorator(NewDecryptionStreamDecorator(stream, "secret", mode), "gzip", false)
+
+			// aes output differs each time, so the writer and reader of the
+			// encrypting chain are checked separately
+			encrypted, err := NewTextAdapter(encrypt).Process(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := streamText(t, decrypt, encrypted); got != text {
+				t.Errorf("Stream round trip through the writer failed: %q", got)
+			}
+			r, err := encrypt.NewReader(strings.NewReader(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := streamText(t, decrypt, string(read)); got != text {
+				t.Errorf("Stream round trip through the reader failed: %q", got)
+			}
+
+			// The string-based decorators read the same format, apart from
+			// ROT13 which they apply to text rather than binary data
+			if mode == "rot13" {
+				return
+			}
+			decrypted, err := NewDecompressionDecorator(NewDecryptionDecorator(basic, "secret", mode), "gzip", false).Process(encrypted)
+			if err != nil || decrypted != text {
+				t.Errorf("String-based decryption returned %q, %v", decrypted, err)
+			}
+
+			// And write it
+			encrypted, err = NewEncryptionDecorator(NewCompressionDecorator(basic, "gzip", false), "secret", mode).Process(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := streamText(t, decrypt, encrypted); got != text {
+				t.Errorf("Stream decryption of string-based encryption failed: %q", got)
+			}
+		})
+	}
+
+	// The wrong key yields garbage rather than the text
+	encrypted, _ := NewTextAdapter(NewEncryptionStreamDecorator(stream, "secret", "aes")).Process(text)
+	if got, _ := NewTextAdapter(NewDecryptionStreamDecorator(stream, "other", "aes")).Process(encrypted); got == text {
+		t.Error("Decryption with the wrong key returned the text")
+	}
+}
+
+// TestStreamAdapters tests mixing string-based decorators into stream chains
+func TestStreamAdapters(t *testing.T) {
+	html := NewStreamAdapter(NewHTMLFormattingDecorator(NewBasicTextProcessor()))
+	chain := NewHashingStreamDecorator(NewCompressionStreamDecorator(html, "gzip", true), "sha256", true)
+
+	expected, err := NewHashingDecorator(
+		NewCompressionDecorator(NewHTMLFormattingDecorator(NewBasicTextProcessor()), "gzip", true),
+		"sha256", true,
+	).Process("# Title\n\nThis is **bold**.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := streamText(t, chain, "# Title\n\nThis is **bold**."); got != expected {
+		t.Errorf("Adapted chain output differs.\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	if got := chain.GetProcessingChain(); got != "html | compress(gzip,base64) | hash(sha256,append)" {
+		t.Errorf("Unexpected processing chain: %s", got)
+	}
+	adapter := NewTextAdapter(chain)
+	if adapter.GetName() != "Hashing Processor" || adapter.GetProcessingChain() != chain.GetProcessingChain() {
+		t.Errorf("TextAdapter does not describe the adapted chain: %s", adapter.GetProcessingChain())
+	}
+
+	// Errors of adapted processors are returned when the data is processed
+	validating := NewStreamAdapter(NewValidationDecorator(NewBasicTextProcessor(), ValidateNotEmpty))
+	if _, err := NewTextAdapter(NewCompressionStreamDecorator(validating, "gzip", true)).Process("  "); err == nil {
+		t.Error("Expected a validation error")
+	}
+}
+
+// TestStreamErrors tests the errors of stream decorators
+func TestStreamErrors(t *testing.T) {
+	stream := NewBasicStreamProcessor()
+
+	unsupported := []StreamProcessor{
+		NewCompressionStreamDecorator(stream, "lzma", false),
+		NewEncryptionStreamDecorator(stream, "key", "des"),
+		NewHashingStreamDecorator(stream, "crc", true),
+		NewHashingStreamDecorator(NewCompressionStreamDecorator(stream, "lzma", false), "md5", true),
+	}
+	for _, p := range unsupported {
+		if _, err := p.NewWriter(io.Discard); err == nil {
+			t.Errorf("Expected %s to reject its settings", p.GetDescription())
+		}
+		if _, err := p.NewReader(strings.NewReader("")); err == nil {
+			t.Errorf("Expected %s to reject its settings", p.GetDescription())
+		}
+	}
+
+	corrupt := []struct {
+		processor StreamProcessor
+		input     string
+	}{
+		{NewDecompressionStreamDecorator(stream, "gzip", false), "not gzip data"},
+		{NewDecompressionStreamDecorator(stream, "zlib", true), "!!!"},
+		{NewDecryptionStreamDecorator(stream, "key", "aes"), "c2hvcnQ="},
+		{NewDecryptionStreamDecorator(stream, "key", "base64"), "not base64!"},
+	}
+	for _, c := range corrupt {
+		if _, err := NewTextAdapter(c.processor).Process(c.input); err == nil {
+			t.Errorf("Expected %s to fail on %q", c.processor.GetDescription(), c.input)
+		}
+		r, err := c.processor.NewReader(strings.NewReader(c.input))
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+		if err == nil {
+			t.Errorf("Expected the reader of %s to fail on %q", c.processor.GetDescription(), c.input)
+		}
+	}
+}
+
+// TestStreamLargeInput pushes a large log through a chain in both
+// directions and checks that it comes back unchanged
+func TestStreamLargeInput(t *testing.T) {
+	size := int64(16 << 20)
+	if testing.Short() {
+		size = 1 << 20
+	}
+	stream := NewBasicStreamProcessor()
+	encrypt := NewEncryptionStreamDecorator(NewCompressionStreamDecorator(stream, "gzip", false), "secret", "aes")
+	decrypt := NewDecompressionStreamDecorator(NewDecryptionStreamDecorator(stream, "secret", "aes"), "gzip", false)
+	checksum := NewHashingStreamDecorator(stream, "sha256", false)
+
+	// Encrypt by reading, decrypt by writing, and hash the result
+	expected := sha256.New()
+	encrypted, err := encrypt.NewReader(io.TeeReader(io.LimitReader(&logReader{}, size), expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encrypted.Close()
+
+	var sum bytes.Buffer
+	hashing, err := checksum.NewWriter(&sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypting, err := decrypt.NewWriter(hashing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(decrypting, encrypted); err != nil {
+		t.Fatal(err)
+	}
+	if err := decrypting.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := hashing.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum.String() != hex.EncodeToString(expected.Sum(nil)) {
+		t.Errorf("Data changed on its way through the chain")
+	}
+}
+
+// BenchmarkStreamChain measures a compressing, encrypting and hashing chain
+func BenchmarkStreamChain(b *testing.B) {
+	chain := NewHashingStreamDecorator(
+		NewEncryptionStreamDecorator(
+			NewCompressionStreamDecorator(NewBasicStreamProcessor(), "gzip", false),
+			"secret", "aes"),
+		"sha256", false)
+	const size = 1 << 20
+	b.SetBytes(size)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w, err := chain.NewWriter(io.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(w, io.LimitReader(&logReader{}, size)); err != nil {
+			b.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
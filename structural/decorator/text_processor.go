@@ -50,6 +50,7 @@ type TextProcessorDecorator struct {
 	wrapped     TextProcessor
 	name        string
 	description string
+	spec        string // The decorator as a stage of a pipeline spec
 }
 
 // Process delegates the processing to the wrapped TextProcessor.
@@ -72,10 +73,14 @@ func (d *TextProcessorDecorator) GetWrappedName() string {
 	return d.wrapped.GetName()
 }
 
-// GetProcessingChain returns a string representing the chain of processors.
+// GetProcessingChain returns the chain of processors as a pipeline spec,
+// such as "validate(notempty) | html | compress(gzip)", which
+// ParsePipeline turns back into the same chain.
 func (d *TextProcessorDecorator) GetProcessingChain() string {
-	if decorator, ok := d.wrapped.(*TextProcessorDecorator); ok {
-		return d.name + " → " + decorator.GetProcessingChain()
-	}
-	return d.name + " → " + d.wrapped.GetName()
+	return chainSpec(d.wrapped, d.spec)
+}
+
+// setSpec sets how the decorator is written in a pipeline spec.
+func (d *TextProcessorDecorator) setSpec(spec string) {
+	d.spec = spec
 }
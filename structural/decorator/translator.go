@@ -0,0 +1,175 @@
+package decorator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupportedPair is returned by a Translator that cannot translate
+// between two languages.
+var ErrUnsupportedPair = errors.New("unsupported language pair")
+
+// Translator translates text between languages, which are named by codes
+// such as "en" or "pt-BR".
+type Translator interface {
+	Translate(text, source, target string) (string, error)
+}
+
+// PivotTranslator translates through a pivot language when the translator
+// it wraps cannot translate between two languages directly, for example
+// from French to Spanish through English.
+type PivotTranslator struct {
+	translator Translator
+	pivot      string
+}
+
+// NewPivotTranslator creates a translator that falls back to translating
+// through pivot.
+func NewPivotTranslator(translator Translator, pivot string) *PivotTranslator {
+	return &PivotTranslator{translator: translator, pivot: pivot}
+}
+
+// Translate translates text directly if it can, and through the pivot
+// language otherwise.
+func (p *PivotTranslator) Translate(text, source, target string) (string, error) {
+	result, err := p.translator.Translate(text, source, target)
+	if !errors.Is(err, ErrUnsupportedPair) || strings.EqualFold(source, p.pivot) || strings.EqualFold(target, p.pivot) {
+		return result, err
+	}
+
+	pivoted, err := p.translator.Translate(text, source, p.pivot)
+	if err != nil {
+		return "", fmt.Errorf("translating through %s: %w", p.pivot, err)
+	}
+	result, err = p.translator.Translate(pivoted, p.pivot, target)
+	if err != nil {
+		return "", fmt.Errorf("translating through %s: %w", p.pivot, err)
+	}
+	return result, nil
+}
+
+// HTTPTranslator is a Translator backed by a translation service with a
+// LibreTranslate-compatible API, such as one running locally. It asks the
+// service which languages it supports on first use, and returns
+// ErrUnsupportedPair for the others so that it can be used with a
+// PivotTranslator.
+type HTTPTranslator struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	targets map[string][]string // Target languages by source; nil until fetched
+}
+
+// NewHTTPTranslator creates a translator that calls the service at url,
+// such as "http://localhost:5000". If client is nil, a client with a ten
+// second timeout is used.
+func NewHTTPTranslator(url string, client *http.Client) *HTTPTranslator {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPTranslator{url: strings.TrimSuffix(url, "/"), client: client}
+}
+
+// Translate asks the service to translate text.
+func (h *HTTPTranslator) Translate(text, source, target string) (string, error) {
+	supported, err := h.supports(source, target)
+	if err != nil {
+		return "", err
+	}
+	if !supported {
+		return "", fmt.Errorf("%w: %s to %s", ErrUnsupportedPair, source, target)
+	}
+
+	body, _ := json.Marshal(map[string]string{"q": text, "source": source, "target": target, "format": "text"})
+	var response struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := h.call(http.MethodPost, "/translate", body, &response); err != nil {
+		return "", err
+	}
+	return response.TranslatedText, nil
+}
+
+// supports reports whether the service translates from source to target.
+// Services that do not list their languages are assumed to support all.
+func (h *HTTPTranslator) supports(source, target string) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.targets == nil {
+		var languages []struct {
+			Code    string   `json:"code"`
+			Targets []string `json:"targets"`
+		}
+		err := h.call(http.MethodGet, "/languages", nil, &languages)
+		var status *httpStatusError
+		switch {
+		case errors.As(err, &status) && status.code == http.StatusNotFound:
+			h.targets = map[string][]string{}
+		case err != nil:
+			return false, err
+		default:
+			h.targets = make(map[string][]string, len(languages))
+			for _, l := range languages {
+				h.targets[strings.ToLower(l.Code)] = l.Targets
+			}
+		}
+	}
+	if len(h.targets) == 0 {
+		return true, nil
+	}
+	for _, t := range h.targets[strings.ToLower(source)] {
+		if strings.EqualFold(t, target) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// httpStatusError is returned for responses with an error status.
+type httpStatusError struct {
+	code    int
+	message string
+}
+
+func (e *httpStatusError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("translation service returned %d %s", e.code, http.StatusText(e.code))
+	}
+	return fmt.Sprintf("translation service returned %d %s: %s", e.code, http.StatusText(e.code), e.message)
+}
+
+// call sends a request to the service and decodes the JSON response into
+// result.
+func (h *HTTPTranslator) call(method, path string, body []byte, result interface{}) error {
+	req, err := http.NewRequest(method, h.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("translation service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return &httpStatusError{code: resp.StatusCode, message: failure.Error}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("translation service: invalid response: %w", err)
+	}
+	return nil
+}
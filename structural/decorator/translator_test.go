@@ -0,0 +1,256 @@
+package decorator
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// glossaryCSV is a phrase table between English, French and German
+const glossaryCSV = `# A small glossary
+en,fr,de
+hello,bonjour,hallo
+world,monde,Welt
+thank you,merci,danke
+thank you very much,merci beaucoup,vielen Dank
+welcome {name},bienvenue {name},willkommen {name}
+{count} new messages,{count} nouveaux messages,
+good morning,bonjour,guten Morgen
+e-mail,courriel,
+don't,ne pas,
+`
+
+// glossaryTMX holds Spanish and Italian phrases with English, for pivoting
+const glossaryTMX = `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header srclang="en" datatype="plaintext" segtype="phrase" adminlang="en" creationtool="test" creationtoolversion="1" o-tmf="none"/>
+  <body>
+    <tu>
+      <tuv xml:lang="en"><seg>hello</seg></tuv>
+      <tuv xml:lang="es"><seg>hola</seg></tuv>
+      <tuv xml:lang="it"><seg>ciao</seg></tuv>
+    </tu>
+    <tu>
+      <tuv lang="EN"><seg>good night</seg></tuv>
+      <tuv lang="ES"><seg>buenas noches</seg></tuv>
+    </tu>
+    <tu>
+      <tuv xml:lang="en"><seg>see you {day}</seg></tuv>
+      <tuv xml:lang="es"><seg>hasta el {day}</seg></tuv>
+      <tuv xml:lang="it"><seg>a {day}</seg></tuv>
+    </tu>
+  </body>
+</tmx>`
+
+// TestPhraseTable tests longest-match translation with a CSV phrase table
+func TestPhraseTable(t *testing.T) {
+	table, err := ReadPhraseTableCSV(strings.NewReader(glossaryCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		text, source, target, expected string
+	}{
+		{"hello world", "en", "fr", "bonjour monde"},
+		{"Hello, World!", "en", "fr", "Bonjour, Monde!"},
+		{"HELLO WORLD", "en", "fr", "BONJOUR MONDE"},
+		{"Thank you very much.", "en", "fr", "Merci beaucoup."},
+		{"Thank You Very Much", "en", "fr", "Merci Beaucoup"},
+		{"thank you, very much", "en", "fr", "merci, very much"},
+		{"thank you\nvery much", "en", "fr", "merci\nvery much"},
+		{"Welcome {name}!", "en", "fr", "Bienvenue {name}!"},
+		{"welcome {user}", "en", "fr", "welcome {user}"},
+		{"{count} new messages", "en", "fr", "{count} nouveaux messages"},
+		{"Hello {world}", "en", "fr", "Bonjour {world}"},
+		{"Send an e-mail", "en", "fr", "Send an courriel"},
+		{"I don't know", "en", "fr", "I ne pas know"},
+		{"  hello\t world  ", "en", "fr", "  bonjour\t monde  "},
+		{"bonjour", "fr", "en", "hello"},
+		{"Merci beaucoup", "fr", "de", "Vielen Dank"},
+		{"hello world", "en-US", "de-DE", "hallo Welt"},
+		{"", "en", "fr", ""},
+	}
+	for _, c := range cases {
+		got, err := table.Translate(c.text, c.source, c.target)
+		if err != nil || got != c.expected {
+			t.Errorf("Translate(%q, %s, %s) = %q, %v, expected %q", c.text, c.source, c.target, got, err, c.expected)
+		}
+	}
+
+	if _, err := table.Translate("hello", "en", "es"); !errors.Is(err, ErrUnsupportedPair) {
+		t.Errorf("Expected ErrUnsupportedPair, got %v", err)
+	}
+
+	// Placeholders must survive translation
+	if err := table.Add("en", "fr", "hello {name}", "bonjour {nom}"); err == nil {
+		t.Error("Expected a translation with different placeholders to be rejected")
+	}
+	if _, err := ReadPhraseTableCSV(strings.NewReader("en,fr\nhi {name},salut\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+}
+
+// TestPhraseTableFiles tests loading phrase tables from files and
+// translating through a pivot language
+func TestPhraseTableFiles(t *testing.T) {
+	dir := t.TempDir()
+	tmx := filepath.Join(dir, "glossary.tmx")
+	if err := os.WriteFile(tmx, []byte(glossaryTMX), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := LoadPhraseTable(tmx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := table.Translate("Hello, see you {day}.", "en", "es"); err != nil || got != "Hola, hasta el {day}." {
+		t.Errorf("Unexpected translation %q, %v", got, err)
+	}
+	if got, err := table.Translate("Good night", "en", "es"); err != nil || got != "Buenas noches" {
+		t.Errorf("Unexpected translation %q, %v", got, err)
+	}
+
+	// Every pair of languages in a unit is added, not only those with English
+	if got, err := table.Translate("hola", "es", "it"); err != nil || got != "ciao" {
+		t.Errorf("Unexpected translation %q, %v", got, err)
+	}
+	csv := filepath.Join(dir, "glossary.csv")
+	if err := os.WriteFile(csv, []byte("en,es\nhello,hola\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	split := NewPhraseTable()
+	split.Add("es", "en", "buenas noches", "good night")
+	split.Add("en", "it", "good night", "buona notte")
+	if _, err := split.Translate("buenas noches", "es", "it"); !errors.Is(err, ErrUnsupportedPair) {
+		t.Errorf("Expected ErrUnsupportedPair, got %v", err)
+	}
+	pivot := NewPivotTranslator(split, "en")
+	if got, err := pivot.Translate("Buenas noches!", "es", "it"); err != nil || got != "Buona notte!" {
+		t.Errorf("Pivot translation returned %q, %v", got, err)
+	}
+	if _, err := pivot.Translate("hola", "es", "fr"); !errors.Is(err, ErrUnsupportedPair) {
+		t.Errorf("Expected ErrUnsupportedPair through the pivot, got %v", err)
+	}
+
+	// Files are read according to their extension
+	if _, err := LoadPhraseTable(csv); err != nil {
+		t.Errorf("LoadPhraseTable returned %v", err)
+	}
+	if _, err := LoadPhraseTable(filepath.Join(dir, "glossary.txt")); err == nil {
+		t.Error("Expected a missing file to fail")
+	}
+	os.WriteFile(filepath.Join(dir, "glossary.po"), nil, 0o644)
+	if _, err := LoadPhraseTable(filepath.Join(dir, "glossary.po")); err == nil {
+		t.Error("Expected an unknown format to fail")
+	}
+
+	// The translate stage reads phrase tables
+	processor := parseSpec(t, `translate(es,it,table="`+tmx+`",pivot=en)`)
+	if got, err := processor.Process("Hola"); err != nil || got != "Ciao" {
+		t.Errorf("Translate stage returned %q, %v", got, err)
+	}
+	if _, err := NewDefaultRegistry().Parse(`translate(en,fr,table="`+tmx+`",url="http://localhost")`, nil); err == nil {
+		t.Error("Expected table and url together to be rejected")
+	}
+}
+
+// newTranslationServer starts a LibreTranslate-compatible server that
+// translates from English to French by upper-casing text
+func newTranslationServer(t *testing.T, listLanguages bool) (*httptest.Server, *int32) {
+	var requests int32
+	mux := http.NewServeMux()
+	if listLanguages {
+		mux.HandleFunc("/languages", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"code": "en", "name": "English", "targets": []string{"fr"}},
+				{"code": "fr", "name": "French", "targets": []string{"en"}},
+			})
+		})
+	}
+	mux.HandleFunc("/translate", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var req struct{ Q, Source, Target, Format string }
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad request"})
+			return
+		}
+		if req.Source != "en" || req.Target != "fr" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": req.Source + " to " + req.Target + " is not supported"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"translatedText": strings.ToUpper(req.Q)})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// TestHTTPTranslator tests the translation service adapter
+func TestHTTPTranslator(t *testing.T) {
+	server, requests := newTranslationServer(t, true)
+	translator := NewHTTPTranslator(server.URL+"/", nil)
+
+	if got, err := translator.Translate("hello", "en", "fr"); err != nil || got != "HELLO" {
+		t.Errorf("Translate returned %q, %v", got, err)
+	}
+
+	// Pairs the service does not list are rejected without asking it
+	if _, err := translator.Translate("hello", "en", "de"); !errors.Is(err, ErrUnsupportedPair) {
+		t.Errorf("Expected ErrUnsupportedPair, got %v", err)
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("Expected 1 translation request, got %d", n)
+	}
+
+	// Services that do not list languages report errors themselves
+	server, _ = newTranslationServer(t, false)
+	translator = NewHTTPTranslator(server.URL, server.Client())
+	if _, err := translator.Translate("hello", "en", "de"); err == nil || !strings.Contains(err.Error(), "en to de is not supported") {
+		t.Errorf("Expected the service's error, got %v", err)
+	}
+
+	// The decorator and the translate stage use the adapter
+	decorated := NewTranslationDecorator(NewBasicTextProcessor(), translator, "en", "fr")
+	if got, err := decorated.Process("hello"); err != nil || got != "HELLO" {
+		t.Errorf("Decorator returned %q, %v", got, err)
+	}
+	processor := parseSpec(t, `translate(en,fr,url="`+server.URL+`")`)
+	if got, err := processor.Process("hello"); err != nil || got != "HELLO" {
+		t.Errorf("Translate stage returned %q, %v", got, err)
+	}
+
+	// Unreachable services fail
+	server.Close()
+	if _, err := translator.Translate("hello", "en", "fr"); err == nil {
+		t.Error("Expected an error from a closed server")
+	}
+}
+
+// TestLanguageTranslationDecorator tests the built-in demonstration table
+func TestLanguageTranslationDecorator(t *testing.T) {
+	basic := NewBasicTextProcessor()
+	cases := []struct {
+		text, target, expected string
+	}{
+		{"Hello world! Thank you, and goodbye.", "fr", "Bonjour monde! Merci, and au revoir."},
+		{"Welcome, please.", "es", "Bienvenido, por favor."},
+		{"Hello world", "en", "Hello world"},
+	}
+	for _, c := range cases {
+		if got, err := NewLanguageTranslationDecorator(basic, "en", c.target).Process(c.text); err != nil || got != c.expected {
+			t.Errorf("Translating %q to %s returned %q, %v", c.text, c.target, got, err)
+		}
+	}
+	if _, err := NewLanguageTranslationDecorator(basic, "en", "de").Process("hello"); !errors.Is(err, ErrUnsupportedPair) {
+		t.Errorf("Expected ErrUnsupportedPair, got %v", err)
+	}
+}
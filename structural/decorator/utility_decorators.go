@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -27,6 +29,7 @@ func NewCompressionDecorator(processor TextProcessor, algorithm string, encodeBa
 			wrapped:     processor,
 			name:        "Compression Processor",
 			description: fmt.Sprintf("Compresses text using %s algorithm", algorithm),
+			spec:        stageSpec("compress", compressParams, map[string]string{"algorithm": algorithm, "base64": strconv.FormatBool(encodeBase64)}),
 		},
 		algorithm:  algorithm,
 		compress:   true,
@@ -41,6 +44,7 @@ func NewDecompressionDecorator(processor TextProcessor, algorithm string, decode
 			wrapped:     processor,
 			name:        "Decompression Processor",
 			description: fmt.Sprintf("Decompresses text using %s algorithm", algorithm),
+			spec:        stageSpec("decompress", compressParams, map[string]string{"algorithm": algorithm, "base64": strconv.FormatBool(decodeBase64)}),
 		},
 		algorithm:  algorithm,
 		compress:   false,
@@ -168,6 +172,7 @@ func NewLoggingDecorator(processor TextProcessor, logInput, logOutput, logTiming
 			wrapped:     processor,
 			name:        "Logging Processor",
 			description: "Logs details about text processing",
+			spec:        stageSpec("log", logParams, map[string]string{"input": strconv.FormatBool(logInput), "output": strconv.FormatBool(logOutput), "timing": strconv.FormatBool(logTiming), "max": strconv.Itoa(maxContentLength)}),
 		},
 		logInput:       logInput,
 		logOutput:      logOutput,
@@ -239,6 +244,7 @@ func NewMetadataDecorator(processor TextProcessor, metadata map[string]string, p
 			wrapped:     processor,
 			name:        "Metadata Processor",
 			description: "Adds metadata to the text",
+			spec:        Args{values: map[string]string{"position": strings.ToLower(position)}, fields: metadata}.spec("metadata", metadataParams),
 		},
 		metadata: metadata,
 		position: strings.ToLower(position),
@@ -257,64 +263,86 @@ func (m *MetadataDecorator) Process(text string) (string, error) {
 	// Generate the metadata section
 	var metadataSection strings.Builder
 	metadataSection.WriteString("--- Metadata ---\n")
-	for key, value := range m.metadata {
-		metadataSection.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+	keys := make([]string, 0, len(m.metadata))
+	for key := range m.metadata {
+		keys = append(keys, key)
 	}
-	metadataSection.WriteString("---------------\n")
+	sort.Strings(keys)
+	for _, key := range keys {
+		metadataSection.WriteString(fmt.Sprintf("%s: %s\n", key, m.metadata[key]))
+	}
+	metadataSection.WriteString("---------------")
 
 	// Add the metadata based on the position
 	if m.position == "prefix" {
-		return metadataSection.String() + processedText, nil
+		return metadataSection.String() + "\n" + processedText, nil
 	} else {
 		return processedText + "\n" + metadataSection.String(), nil
 	}
 }
 
-// LanguageTranslationDecorator is a concrete decorator that simulates language translation.
+// LanguageTranslationDecorator is a concrete decorator that translates text
+// with a Translator.
 type LanguageTranslationDecorator struct {
 	TextProcessorDecorator
 	sourceLanguage string
 	targetLanguage string
-	translations  map[string]map[string]string
+	translator     Translator
 }
 
-// NewLanguageTranslationDecorator creates a decorator that simulates translating text.
-// Note: This is a simple simulation for demonstration purposes.
+// NewLanguageTranslationDecorator creates a decorator that translates text
+// with a small built-in phrase table from English to French and Spanish.
+// Note: This is for demonstration purposes; use NewTranslationDecorator
+// with a PhraseTable or an HTTPTranslator for real translations.
 func NewLanguageTranslationDecorator(processor TextProcessor, sourceLanguage, targetLanguage string) *LanguageTranslationDecorator {
-	// Initialize with some common words and phrases for demonstration
-	translations := map[string]map[string]string{
-		"en": {
-			"fr": map[string]string{
-				"hello": "bonjour",
-				"world": "monde",
-				"welcome": "bienvenue",
-				"thank you": "merci",
-				"please": "s'il vous plaît",
-				"goodbye": "au revoir",
-			},
-			"es": map[string]string{
-				"hello": "hola",
-				"world": "mundo",
-				"welcome": "bienvenido",
-				"thank you": "gracias",
-				"please": "por favor",
-				"goodbye": "adiós",
-			},
-		},
-	}
+	return NewTranslationDecorator(processor, demoPhraseTable(), sourceLanguage, targetLanguage)
+}
 
+// NewTranslationDecorator creates a decorator that translates text with
+// translator.
+func NewTranslationDecorator(processor TextProcessor, translator Translator, sourceLanguage, targetLanguage string) *LanguageTranslationDecorator {
 	return &LanguageTranslationDecorator{
 		TextProcessorDecorator: TextProcessorDecorator{
 			wrapped:     processor,
 			name:        "Translation Processor",
 			description: fmt.Sprintf("Translates text from %s to %s", sourceLanguage, targetLanguage),
+			spec:        stageSpec("translate", translateParams, map[string]string{"from": sourceLanguage, "to": targetLanguage}),
 		},
 		sourceLanguage: sourceLanguage,
 		targetLanguage: targetLanguage,
-		translations:  translations,
+		translator:     translator,
 	}
 }
 
+// demoPhraseTable returns the phrase table of NewLanguageTranslationDecorator.
+func demoPhraseTable() *PhraseTable {
+	translations := map[string]map[string]string{
+		"fr": {
+			"hello":     "bonjour",
+			"world":     "monde",
+			"welcome":   "bienvenue",
+			"thank you": "merci",
+			"please":    "s'il vous plaît",
+			"goodbye":   "au revoir",
+		},
+		"es": {
+			"hello":     "hola",
+			"world":     "mundo",
+			"welcome":   "bienvenido",
+			"thank you": "gracias",
+			"please":    "por favor",
+			"goodbye":   "adiós",
+		},
+	}
+	table := NewPhraseTable()
+	for target, phrases := range translations {
+		for phrase, translation := range phrases {
+			table.Add("en", target, phrase, translation)
+		}
+	}
+	return table
+}
+
 // Process first processes the text using the wrapped processor,
 // then translates the text from the source language to the target language.
 func (t *LanguageTranslationDecorator) Process(text string) (string, error) {
@@ -324,51 +352,13 @@ func (t *LanguageTranslationDecorator) Process(text string) (string, error) {
 		return "", fmt.Errorf("error in wrapped processor: %w", err)
 	}
 
-	// Translate the text
-	// Note: This is a simple demonstration; in a real app you would use a translation API
-	if t.sourceLanguage == t.targetLanguage {
+	if strings.EqualFold(t.sourceLanguage, t.targetLanguage) {
 		return processedText, nil
 	}
 
-	// Check if we have translations for the source language
-	sourceLang, ok := t.translations[t.sourceLanguage]
-	if !ok {
-		return processedText, fmt.Errorf("translation from %s not supported", t.sourceLanguage)
-	}
-
-	// Check if we have translations for the target language
-	targetDict, ok := sourceLang[t.targetLanguage]
-	if !ok {
-		return processedText, fmt.Errorf("translation to %s not supported", t.targetLanguage)
-	}
-
-	// Simple word-by-word translation for demonstration
-	words := strings.Fields(processedText)
-	for i, word := range words {
-		// Convert to lowercase for dictionary lookup
-		lowerWord := strings.ToLower(word)
-		
-		// Strip any trailing punctuation
-		trimmedWord := lowerWord
-		var punctuation string
-		for j := len(trimmedWord) - 1; j >= 0; j-- {
-			if !strings.ContainsRune(",.!?:;", rune(trimmedWord[j])) {
-				break
-			}
-			punctuation = string(trimmedWord[j]) + punctuation
-			trimmedWord = trimmedWord[:j]
-		}
-		
-		// Check if we have a translation for this word
-		if translation, ok := targetDict[trimmedWord]; ok {
-			// Preserve capitalization
-			if word[0] >= 'A' && word[0] <= 'Z' {
-				translation = strings.ToUpper(translation[:1]) + translation[1:]
-			}
-			// Reattach punctuation
-			words[i] = translation + punctuation
-		}
+	translated, err := t.translator.Translate(processedText, t.sourceLanguage, t.targetLanguage)
+	if err != nil {
+		return processedText, fmt.Errorf("translation error: %w", err)
 	}
-
-	return strings.Join(words, " "), nil
+	return translated, nil
 }